@@ -0,0 +1,18 @@
+module tenant_balancer
+
+go 1.20
+
+require (
+	github.com/twmb/franz-go v1.13.0
+	github.com/twmb/franz-go/pkg/kmsg v1.4.0
+)
+
+require (
+	github.com/klauspost/compress v1.16.3 // indirect
+	github.com/pierrec/lz4/v4 v4.1.17 // indirect
+)
+
+replace (
+	github.com/twmb/franz-go => ../..
+	github.com/twmb/franz-go/pkg/kmsg => ../../pkg/kmsg
+)
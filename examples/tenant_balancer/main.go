@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+var (
+	simulate    = flag.Bool("simulate", true, "if true, simulate balancing a group rather than consuming")
+	seedBrokers = flag.String("brokers", "localhost:9092", "comma delimited list of seed brokers")
+	topics      = flag.String("topics", "a.orders,b.orders", "comma delimited list of topics to consume")
+	group       = flag.String("group", "tenants", "group to consume within")
+	tenant      = flag.String("tenant", "a", "tenant this member serves")
+)
+
+func die(msg string, args ...any) {
+	fmt.Fprintf(os.Stderr, msg, args...)
+	os.Exit(1)
+}
+
+// tenantBalancer assigns topics named "<tenant>.<name>" only to members that
+// serve the tenant, round robin. Topics for tenants that no member serves are
+// assigned round robin to all members.
+type tenantBalancer struct {
+	tenant string // the tenant this member serves
+}
+
+func (*tenantBalancer) ProtocolName() string { return "tenant" }
+func (*tenantBalancer) IsCooperative() bool  { return false }
+
+func (b *tenantBalancer) JoinGroupMetadata(interests []string, owned map[string][]int32, generation int32) []byte {
+	meta := kgo.ConsumerProtocolMetadata{
+		Topics:          interests,
+		OwnedPartitions: owned,
+		Generation:      generation,
+		UserData:        []byte(b.tenant),
+	}
+	return meta.AppendTo(nil)
+}
+
+func (*tenantBalancer) ParseSyncAssignment(assignment []byte) (map[string][]int32, error) {
+	return kgo.ParseConsumerSyncAssignment(assignment)
+}
+
+func (b *tenantBalancer) MemberBalancer(members []kmsg.JoinGroupResponseMember) (kgo.GroupMemberBalancer, map[string]struct{}, error) {
+	cb, err := kgo.NewConsumerBalancer(b, members)
+	return cb, cb.MemberTopics(), err
+}
+
+// Balance satisfies kgo.ConsumerBalancerBalance.
+func (*tenantBalancer) Balance(cb *kgo.ConsumerBalancer, topics map[string]int32) kgo.IntoSyncAssignment {
+	var all []*kmsg.JoinGroupResponseMember
+	byTenant := make(map[string][]*kmsg.JoinGroupResponseMember)
+	cb.EachMember(func(member *kmsg.JoinGroupResponseMember, meta *kmsg.ConsumerMemberMetadata) {
+		all = append(all, member)
+		byTenant[string(meta.UserData)] = append(byTenant[string(meta.UserData)], member)
+	})
+
+	sorted := make([]string, 0, len(topics))
+	for topic := range topics {
+		sorted = append(sorted, topic)
+	}
+	sort.Strings(sorted)
+
+	plan := cb.NewPlan()
+	for _, topic := range sorted {
+		tenant, _, _ := strings.Cut(topic, ".")
+		members := byTenant[tenant]
+		if len(members) == 0 {
+			members = all
+		}
+		for partition := int32(0); partition < topics[topic]; partition++ {
+			plan.AddPartition(members[int(partition)%len(members)], topic, partition)
+		}
+	}
+	return plan
+}
+
+func main() {
+	flag.Parse()
+
+	if *simulate {
+		var members []kgo.SimulatedGroupMember
+		for _, tenant := range []string{"a", "a", "b"} {
+			members = append(members, kgo.SimulatedGroupMember{
+				MemberID: fmt.Sprintf("member-%d-%s", len(members), tenant),
+				Topics:   []string{"a.orders", "b.orders", "c.orders"},
+				Balancer: &tenantBalancer{tenant: tenant}, // each member advertises its own tenant
+			})
+		}
+		assignments, err := kgo.SimulateGroupBalance(
+			&tenantBalancer{tenant: "a"}, // the leader's balancer
+			1,
+			map[string]int32{"a.orders": 4, "b.orders": 2, "c.orders": 3},
+			members,
+		)
+		if err != nil {
+			die("unable to simulate balancing: %v\n", err)
+		}
+		for _, m := range members {
+			fmt.Printf("%s: %v\n", m.MemberID, assignments[m.MemberID])
+		}
+		return
+	}
+
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(strings.Split(*seedBrokers, ",")...),
+		kgo.ConsumerGroup(*group),
+		kgo.ConsumeTopics(strings.Split(*topics, ",")...),
+		kgo.Balancers(&tenantBalancer{tenant: *tenant}),
+	)
+	if err != nil {
+		die("unable to create client: %v\n", err)
+	}
+	defer cl.Close()
+
+	for {
+		fetches := cl.PollFetches(context.Background())
+		if fetches.IsClientClosed() {
+			return
+		}
+		fetches.EachError(func(t string, p int32, err error) {
+			die("fetch err topic %s partition %d: %v\n", t, p, err)
+		})
+		fetches.EachRecord(func(r *kgo.Record) {
+			fmt.Printf("tenant %s consumed %s/%d@%d: %s\n", *tenant, r.Topic, r.Partition, r.Offset, r.Value)
+		})
+	}
+}
@@ -10,3 +10,5 @@ require (
 )
 
 retract v1.11.4 // This version is actually a breaking change and requires a major version change.
+
+replace github.com/twmb/franz-go/pkg/kmsg => ./pkg/kmsg
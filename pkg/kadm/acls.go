@@ -3,6 +3,7 @@ package kadm
 import (
 	"context"
 	"fmt"
+	"math"
 	"strings"
 	"sync"
 
@@ -461,6 +462,23 @@ const (
 	OpIdempotentWrite ACLOperation = kmsg.ACLOperationIdempotentWrite
 )
 
+// DecodeAuthorizedOperations decodes an authorized operations bitfield, as
+// returned in Kafka responses (KIP-430), into the operations it contains,
+// sorted. The sentinel value Kafka uses when operations were not requested
+// (math.MinInt32) decodes to nil.
+func DecodeAuthorizedOperations(bitfield int32) []ACLOperation {
+	if bitfield == math.MinInt32 {
+		return nil
+	}
+	ops := []ACLOperation{}
+	for i := 0; i < 32; i++ {
+		if bitfield&(1<<i) != 0 {
+			ops = append(ops, ACLOperation(i))
+		}
+	}
+	return ops
+}
+
 // Operations sets operations to allow or deny. Passing no operations defaults
 // to OpAny.
 //
@@ -534,6 +552,32 @@ func (b *ACLBuilder) ResourcePatternType(pattern ACLPattern) *ACLBuilder {
 	return b
 }
 
+// Prefixed sets the pattern type to PREFIXED, meaning resource names match
+// any resource that begins with the name. This is a shortcut for
+// ResourcePatternType(ACLPatternPrefixed):
+//
+//	// Allow User:foo to read every topic beginning with "foo-".
+//	kadm.NewACLs().
+//		Topics("foo-").
+//		Prefixed().
+//		Allow("User:foo").
+//		Operations(kadm.OpRead)
+//
+// This returns the input pointer.
+func (b *ACLBuilder) Prefixed() *ACLBuilder { return b.ResourcePatternType(ACLPatternPrefixed) }
+
+// Literal sets the pattern type to LITERAL, the default. This is a shortcut for
+// ResourcePatternType(ACLPatternLiteral).
+//
+// This returns the input pointer.
+func (b *ACLBuilder) Literal() *ACLBuilder { return b.ResourcePatternType(ACLPatternLiteral) }
+
+// Match sets the pattern type to MATCH, which is only valid for describing
+// and deleting. This is a shortcut for ResourcePatternType(ACLPatternMatch).
+//
+// This returns the input pointer.
+func (b *ACLBuilder) Match() *ACLBuilder { return b.ResourcePatternType(ACLPatternMatch) }
+
 // ValidateCreate returns an error if the builder is invalid for creating ACLs.
 func (b *ACLBuilder) ValidateCreate() error {
 	for _, op := range b.ops {
@@ -652,30 +696,13 @@ func (b *ACLBuilder) dup() *ACLBuilder { // shallow copy
 	return &d
 }
 
-// CreateACLsResult is a result for an individual ACL creation.
-type CreateACLsResult struct {
-	Principal string
-	Host      string
-
-	Type       kmsg.ACLResourceType   // Type is the type of resource this is.
-	Name       string                 // Name is the name of the resource allowed / denied.
-	Pattern    ACLPattern             // Pattern is the name pattern.
-	Operation  ACLOperation           // Operation is the operation allowed / denied.
-	Permission kmsg.ACLPermissionType // Permission is whether this is allowed / denied.
-
-	Err error // Err is the error for this ACL creation.
-}
-
-// CreateACLsResults contains all results to created ACLs.
-type CreateACLsResults []CreateACLsResult
-
-// CreateACLs creates a batch of ACLs using the ACL builder, validating the
-// input before issuing the CreateACLs request.
+// Creations returns the ACL creations that CreateACLs would issue for this
+// builder: the cross product of every resource, operation, principal, and
+// host. This can be used to preview what will be created before creating.
 //
-// If the input is invalid, or if the response fails, or if the response does
-// not contain as many ACLs as we issued in our create request, this returns an
-// error.
-func (cl *Client) CreateACLs(ctx context.Context, b *ACLBuilder) (CreateACLsResults, error) {
+// If allowed or denied principals have no hosts, the hosts default to "*".
+// This returns an error if the builder is invalid for creating ACLs.
+func (b *ACLBuilder) Creations() ([]kmsg.CreateACLsRequestCreation, error) {
 	if err := b.ValidateCreate(); err != nil {
 		return nil, err
 	}
@@ -691,7 +718,7 @@ func (cl *Client) CreateACLs(ctx context.Context, b *ACLBuilder) (CreateACLsResu
 		clusters = []string{"kafka-cluster"}
 	}
 
-	req := kmsg.NewPtrCreateACLsRequest()
+	var creations []kmsg.CreateACLsRequestCreation
 	for _, typeNames := range []struct {
 		t     kmsg.ACLResourceType
 		names []string
@@ -722,15 +749,48 @@ func (cl *Client) CreateACLs(ctx context.Context, b *ACLBuilder) (CreateACLsResu
 							c.Principal = principal
 							c.Host = host
 							c.PermissionType = perm.permType
-							req.Creations = append(req.Creations, c)
+							creations = append(creations, c)
 						}
 					}
 				}
 			}
 		}
 	}
+	return creations, nil
+}
+
+// CreateACLsResult is a result for an individual ACL creation.
+type CreateACLsResult struct {
+	Principal string
+	Host      string
+
+	Type       kmsg.ACLResourceType   // Type is the type of resource this is.
+	Name       string                 // Name is the name of the resource allowed / denied.
+	Pattern    ACLPattern             // Pattern is the name pattern.
+	Operation  ACLOperation           // Operation is the operation allowed / denied.
+	Permission kmsg.ACLPermissionType // Permission is whether this is allowed / denied.
+
+	Err error // Err is the error for this ACL creation.
+}
+
+// CreateACLsResults contains all results to created ACLs.
+type CreateACLsResults []CreateACLsResult
+
+// CreateACLs creates a batch of ACLs using the ACL builder, validating the
+// input before issuing the CreateACLs request.
+//
+// If the input is invalid, or if the response fails, or if the response does
+// not contain as many ACLs as we issued in our create request, this returns an
+// error.
+func (cl *Client) CreateACLs(ctx context.Context, b *ACLBuilder) (CreateACLsResults, error) {
+	creations, err := b.Creations()
+	if err != nil {
+		return nil, err
+	}
+	req := kmsg.NewPtrCreateACLsRequest()
+	req.Creations = creations
 
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
@@ -819,7 +879,7 @@ func (cl *Client) DeleteACLs(ctx context.Context, b *ACLBuilder) (DeleteACLsResu
 
 	req := kmsg.NewPtrDeleteACLsRequest()
 	req.Filters = dels
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
@@ -929,7 +989,7 @@ func (cl *Client) DescribeACLs(ctx context.Context, b *ACLBuilder) (DescribeACLs
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			resp, err := req.RequestWith(ictx, cl.cl)
+			resp, err := req.RequestWith(ictx, cl.requestor())
 			resps[myIdx] = resp
 			if err == nil {
 				return
@@ -0,0 +1,163 @@
+package kadm
+
+import (
+	"context"
+	"regexp"
+	"time"
+)
+
+// Admin is the set of admin operations supported by a *Client. Services that
+// perform admin operations can accept an Admin rather than a *Client, allowing
+// unit tests to substitute a fake implementation without a live broker.
+// Tests can embed Admin in a struct and override only the methods they use:
+//
+//	type fakeAdmin struct {
+//		kadm.Admin // nil; calling any method not overridden panics
+//	}
+//
+//	func (fakeAdmin) ListTopics(context.Context, ...string) (kadm.TopicDetails, error) {
+//		return kadm.TopicDetails{"foo": {Topic: "foo"}}, nil
+//	}
+//
+// Methods that configure a client (WithOpts, ForBroker, SetTimeoutMillis) are
+// not part of this interface, nor are deprecated methods.
+// New methods may be added to this interface as they are added to Client;
+// implementations should embed Admin to remain forward compatible.
+type Admin interface {
+	// Close closes the underlying client.
+	Close()
+
+	// Topics
+
+	CreatePartitions(ctx context.Context, add int, topics ...string) (CreatePartitionsResponses, error)
+	CreateTopic(ctx context.Context, partitions int32, replicationFactor int16, configs map[string]*string, topic string) (CreateTopicResponse, error)
+	CreateTopicWithAssignment(ctx context.Context, assignment ReplicaAssignment, configs map[string]*string, topic string) (CreateTopicResponse, error)
+	CreateTopics(ctx context.Context, partitions int32, replicationFactor int16, configs map[string]*string, topics ...string) (CreateTopicResponses, error)
+	CreateTopicsWithAssignments(ctx context.Context, assignments map[string]ReplicaAssignment, configs map[string]*string) (CreateTopicResponses, error)
+	DeleteRecords(ctx context.Context, os Offsets) (DeleteRecordsResponses, error)
+	DeleteTopics(ctx context.Context, topics ...string) (DeleteTopicResponses, error)
+	ListTopics(ctx context.Context, topics ...string) (TopicDetails, error)
+	ListTopicsFunc(ctx context.Context, fn func(TopicDetail) bool) (TopicDetails, error)
+	ListTopicsPrefix(ctx context.Context, prefix string) (TopicDetails, error)
+	ListTopicsRe(ctx context.Context, re *regexp.Regexp) (TopicDetails, error)
+	ListTopicsWithInternal(ctx context.Context, topics ...string) (TopicDetails, error)
+	UpdatePartitions(ctx context.Context, set int, topics ...string) (CreatePartitionsResponses, error)
+	ValidateCreatePartitions(ctx context.Context, add int, topics ...string) (CreatePartitionsResponses, error)
+	ValidateCreateTopics(ctx context.Context, partitions int32, replicationFactor int16, configs map[string]*string, topics ...string) (CreateTopicResponses, error)
+	ValidateCreateTopicsWithAssignments(ctx context.Context, assignments map[string]ReplicaAssignment, configs map[string]*string) (CreateTopicResponses, error)
+	ValidateUpdatePartitions(ctx context.Context, set int, topics ...string) (CreatePartitionsResponses, error)
+
+	// Metadata, topic details, and offsets
+
+	BrokerMetadata(ctx context.Context) (Metadata, error)
+	DescribeCluster(ctx context.Context) (DescribedCluster, error)
+	DescribeTopicPartitions(ctx context.Context, topics ...string) (TopicDetails, error)
+	DescribeTopicPartitionsPages(ctx context.Context, partitionLimit int32, fn func(TopicDetails) error, topics ...string) error
+	ListBrokers(ctx context.Context) (BrokerDetails, error)
+	ListCommittedOffsets(ctx context.Context, topics ...string) (ListedOffsets, error)
+	ListCommittedOffsetsForPartitions(ctx context.Context, s TopicsSet) (ListedOffsets, error)
+	ListEndOffsets(ctx context.Context, topics ...string) (ListedOffsets, error)
+	ListEndOffsetsForPartitions(ctx context.Context, s TopicsSet) (ListedOffsets, error)
+	ListMaxTimestampOffsets(ctx context.Context, topics ...string) (ListedOffsets, error)
+	ListMaxTimestampOffsetsForPartitions(ctx context.Context, s TopicsSet) (ListedOffsets, error)
+	ListOffsetsAfterMilli(ctx context.Context, millisecond int64, topics ...string) (ListedOffsets, error)
+	ListOffsetsAfterMilliForPartitions(ctx context.Context, millisecond int64, s TopicsSet) (ListedOffsets, error)
+	ListOffsetsFunc(ctx context.Context, spec ListOffsetsSpec, s TopicsSet, fn func(ListedOffsets) error) error
+	ListStartOffsets(ctx context.Context, topics ...string) (ListedOffsets, error)
+	ListStartOffsetsForPartitions(ctx context.Context, s TopicsSet) (ListedOffsets, error)
+	ListTopicsForIDs(ctx context.Context, ids ...TopicID) (TopicDetails, error)
+	Metadata(ctx context.Context, topics ...string) (Metadata, error)
+	MetadataForIDs(ctx context.Context, ids ...TopicID) (Metadata, error)
+
+	// Configs
+
+	AlterBrokerConfigs(ctx context.Context, configs []AlterConfig, brokers ...int32) (AlterConfigsResponses, error)
+	AlterBrokerConfigsState(ctx context.Context, configs []AlterConfig, brokers ...int32) (AlterConfigsResponses, error)
+	AlterTopicConfigs(ctx context.Context, configs []AlterConfig, topics ...string) (AlterConfigsResponses, error)
+	AlterTopicConfigsState(ctx context.Context, configs []AlterConfig, topics ...string) (AlterConfigsResponses, error)
+	DescribeBrokerConfigs(ctx context.Context, brokers ...int32) (ResourceConfigs, error)
+	DescribeTopicConfigs(ctx context.Context, topics ...string) (ResourceConfigs, error)
+	ValidateAlterBrokerConfigs(ctx context.Context, configs []AlterConfig, brokers ...int32) (AlterConfigsResponses, error)
+	ValidateAlterBrokerConfigsState(ctx context.Context, configs []AlterConfig, brokers ...int32) (AlterConfigsResponses, error)
+	ValidateAlterTopicConfigs(ctx context.Context, configs []AlterConfig, topics ...string) (AlterConfigsResponses, error)
+	ValidateAlterTopicConfigsState(ctx context.Context, configs []AlterConfig, topics ...string) (AlterConfigsResponses, error)
+
+	// Groups
+
+	CommitAllOffsets(ctx context.Context, group string, os Offsets) error
+	CommitOffsets(ctx context.Context, group string, os Offsets) (OffsetResponses, error)
+	DeleteGroups(ctx context.Context, groups ...string) (DeleteGroupResponses, error)
+	DeleteOffsets(ctx context.Context, group string, s TopicsSet) (DeleteOffsetsResponses, error)
+	DescribeConsumerGroups(ctx context.Context, groups ...string) (DescribedConsumerGroups, error)
+	DescribeGroups(ctx context.Context, groups ...string) (DescribedGroups, error)
+	FetchManyOffsets(ctx context.Context, groups ...string) FetchOffsetsResponses
+	FetchOffsets(ctx context.Context, group string) (OffsetResponses, error)
+	FetchOffsetsForTopics(ctx context.Context, group string, topics ...string) (OffsetResponses, error)
+	LeaveGroup(ctx context.Context, b *LeaveGroupBuilder) (LeaveGroupResponses, error)
+	ListGroups(ctx context.Context, filterStates ...string) (ListedGroups, error)
+	ListGroupsPages(ctx context.Context, filter ListGroupsFilter, pageSize int, fn func(ListedGroups) error) error
+
+	// Transactions
+
+	DescribeProducers(ctx context.Context, s TopicsSet) (DescribedProducersTopics, error)
+	DescribeTransactions(ctx context.Context, txnIDs ...string) (DescribedTransactions, error)
+	ListTransactionalIDs(ctx context.Context, filter ListTransactionsFilter) ([]string, error)
+	ListTransactions(ctx context.Context, producerIDs []int64, filterStates []string) (ListedTransactions, error)
+	WriteTxnMarkers(ctx context.Context, markers ...TxnMarkers) (TxnMarkersResponses, error)
+
+	// ACLs
+
+	CreateACLs(ctx context.Context, b *ACLBuilder) (CreateACLsResults, error)
+	DeleteACLs(ctx context.Context, b *ACLBuilder) (DeleteACLsResults, error)
+	DescribeACLs(ctx context.Context, b *ACLBuilder) (DescribeACLsResults, error)
+
+	// Partition assignments
+
+	AlterPartitionAssignments(ctx context.Context, req AlterPartitionAssignmentsReq) (AlterPartitionAssignmentsResponses, error)
+	ListAllPartitionReassignments(ctx context.Context) (ListPartitionReassignmentsResponses, error)
+	ListPartitionReassignments(ctx context.Context, s TopicsSet) (ListPartitionReassignmentsResponses, error)
+
+	// Reassignment
+
+	DrainBroker(ctx context.Context, b *DrainBrokerBuilder) (ReassignmentPlan, error)
+	MonitorReassignments(ctx context.Context, b *MonitorReassignmentsBuilder, fn func(ReassignmentProgress)) error
+	PlanReassignment(ctx context.Context, brokers []int32, topics ...string) (ReassignmentPlan, error)
+
+	// Log directories
+
+	AlterAllReplicaLogDirs(ctx context.Context, alter AlterReplicaLogDirsReq) (AlterAllReplicaLogDirsResponses, error)
+	AlterBrokerReplicaLogDirs(ctx context.Context, broker int32, alter AlterReplicaLogDirsReq) (AlterReplicaLogDirsResponses, error)
+	DescribeAllLogDirs(ctx context.Context, s TopicsSet) (DescribedAllLogDirs, error)
+	DescribeBrokerLogDirs(ctx context.Context, broker int32, s TopicsSet) (DescribedLogDirs, error)
+	TopicSizes(ctx context.Context, topics ...string) (TopicSizes, error)
+
+	// Quotas, SCRAM, leader elections, and miscellaneous
+
+	AlterClientQuotas(ctx context.Context, entries []AlterClientQuotaEntry) (AlteredClientQuotas, error)
+	AlterUserSCRAMs(ctx context.Context, del []DeleteSCRAM, upsert []UpsertSCRAM) (AlteredUserSCRAMs, error)
+	ApiVersions(ctx context.Context) (BrokersApiVersions, error)
+	DescribeClientQuotas(ctx context.Context, strict bool, entityComponents []DescribeClientQuotaComponent) (DescribedClientQuotas, error)
+	DescribeUserSCRAMs(ctx context.Context, users ...string) (DescribedUserSCRAMs, error)
+	ElectLeaders(ctx context.Context, how ElectLeadersHow, s TopicsSet) (ElectLeadersResults, error)
+	FindGroupCoordinators(ctx context.Context, groups ...string) FindCoordinatorResponses
+	FindTxnCoordinators(ctx context.Context, txnIDs ...string) FindCoordinatorResponses
+	OffsetForLeaderEpoch(ctx context.Context, r OffsetForLeaderEpochRequest) (OffsetsForLeaderEpochs, error)
+	ValidateAlterClientQuotas(ctx context.Context, entries []AlterClientQuotaEntry) (AlteredClientQuotas, error)
+
+	// Delegation tokens
+
+	CreateDelegationToken(ctx context.Context, d CreateDelegationToken) (DelegationToken, error)
+	DescribeDelegationTokens(ctx context.Context, owners ...Principal) (DelegationTokens, error)
+	ExpireDelegationToken(ctx context.Context, hmac []byte, expiry time.Duration) (expiryTimestamp time.Time, err error)
+	RenewDelegationToken(ctx context.Context, hmac []byte, renewTime time.Duration) (expiryTimestamp time.Time, err error)
+
+	// Cluster health
+
+	Health(ctx context.Context) (ClusterHealth, error)
+
+	// Throttles
+
+	ThrottleReport(ctx context.Context, trackers ...*ThrottleTracker) (ThrottleReport, error)
+}
+
+var _ Admin = (*Client)(nil)
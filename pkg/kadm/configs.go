@@ -114,7 +114,7 @@ func (cl *Client) describeConfigs(
 		rr.ResourceType = kind
 		req.Resources = append(req.Resources, rr)
 	}
-	shards := cl.cl.RequestSharded(ctx, req)
+	shards := cl.requestSharded(ctx, req)
 
 	var configs []ResourceConfig
 	return configs, shardErrEach(req, shards, func(kr kmsg.Response) error {
@@ -183,8 +183,10 @@ type AlterConfig struct {
 
 // AlteredConfigsResponse contains the response for an individual alteration.
 type AlterConfigsResponse struct {
-	Name string // Name is the name of this resource (topic name or broker number).
-	Err  error  // Err is non-nil if the config could not be altered.
+	Name         string // Name is the name of this resource (topic name or broker number).
+	Err          error  // Err is non-nil if the config could not be altered.
+	ErrMessage   string // ErrMessage a potential extra message describing any error.
+	ValidateOnly bool   // ValidateOnly is true if this response is from validating the alteration; no configs were altered.
 }
 
 // AlterConfigsResponses contains responses for many alterations.
@@ -283,7 +285,7 @@ func (cl *Client) alterConfigs(
 	names []string,
 ) (AlterConfigsResponses, error) {
 	req := kmsg.NewPtrIncrementalAlterConfigsRequest()
-	req.ValidateOnly = dry
+	req.ValidateOnly = dry || cl.validateOnly
 	for _, name := range names {
 		rr := kmsg.NewIncrementalAlterConfigsRequestResource()
 		rr.ResourceType = kind
@@ -307,15 +309,17 @@ func (cl *Client) alterConfigs(
 		req.Resources = append(req.Resources, rr)
 	}
 
-	shards := cl.cl.RequestSharded(ctx, req)
+	shards := cl.requestSharded(ctx, req)
 
 	var rs []AlterConfigsResponse
 	return rs, shardErrEach(req, shards, func(kr kmsg.Response) error {
 		resp := kr.(*kmsg.IncrementalAlterConfigsResponse)
 		for _, r := range resp.Resources {
 			rs = append(rs, AlterConfigsResponse{ // we are not storing in a map, no existence check possible
-				Name: r.ResourceName,
-				Err:  kerr.ErrorForCode(r.ErrorCode),
+				Name:         r.ResourceName,
+				Err:          kerr.ErrorForCode(r.ErrorCode),
+				ErrMessage:   unptrStr(r.ErrorMessage),
+				ValidateOnly: req.ValidateOnly,
 			})
 		}
 		return nil
@@ -382,7 +386,7 @@ func (cl *Client) alterConfigsState(
 	names []string,
 ) (AlterConfigsResponses, error) {
 	req := kmsg.NewPtrAlterConfigsRequest()
-	req.ValidateOnly = dry
+	req.ValidateOnly = dry || cl.validateOnly
 	for _, name := range names {
 		rr := kmsg.NewAlterConfigsRequestResource()
 		rr.ResourceType = kind
@@ -396,15 +400,17 @@ func (cl *Client) alterConfigsState(
 		req.Resources = append(req.Resources, rr)
 	}
 
-	shards := cl.cl.RequestSharded(ctx, req)
+	shards := cl.requestSharded(ctx, req)
 
 	var rs []AlterConfigsResponse
 	return rs, shardErrEach(req, shards, func(kr kmsg.Response) error {
 		resp := kr.(*kmsg.AlterConfigsResponse)
 		for _, r := range resp.Resources {
 			rs = append(rs, AlterConfigsResponse{ // we are not storing in a map, no existence check possible
-				Name: r.ResourceName,
-				Err:  kerr.ErrorForCode(r.ErrorCode),
+				Name:         r.ResourceName,
+				Err:          kerr.ErrorForCode(r.ErrorCode),
+				ErrMessage:   unptrStr(r.ErrorMessage),
+				ValidateOnly: req.ValidateOnly,
 			})
 		}
 		return nil
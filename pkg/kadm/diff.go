@@ -0,0 +1,175 @@
+package kadm
+
+import (
+	"sort"
+)
+
+// BrokerChange is a broker whose details changed between two metadata
+// snapshots.
+type BrokerChange struct {
+	Old BrokerDetail // Old is the broker in the old metadata.
+	New BrokerDetail // New is the broker in the new metadata.
+}
+
+// PartitionChange is a partition whose details changed between two metadata
+// snapshots.
+type PartitionChange struct {
+	Old PartitionDetail // Old is the partition in the old metadata.
+	New PartitionDetail // New is the partition in the new metadata.
+}
+
+// MetadataDiff contains the changes between two metadata snapshots, as
+// returned from DiffMetadata. All slices are sorted by broker, or by topic and
+// then partition.
+type MetadataDiff struct {
+	// ControllerChanged is whether the controller changed; if so, the old
+	// and new controllers are in the Old and New metadata.
+	ControllerChanged bool
+
+	BrokersAdded   []BrokerDetail // BrokersAdded are brokers in the new metadata but not the old.
+	BrokersRemoved []BrokerDetail // BrokersRemoved are brokers in the old metadata but not the new.
+	BrokersChanged []BrokerChange // BrokersChanged are brokers whose host, port, or rack changed.
+
+	TopicsAdded   []TopicDetail // TopicsAdded are topics in the new metadata but not the old.
+	TopicsRemoved []TopicDetail // TopicsRemoved are topics in the old metadata but not the new.
+
+	PartitionsAdded []PartitionDetail // PartitionsAdded are partitions added to topics that exist in both snapshots.
+
+	LeaderChanges  []PartitionChange // LeaderChanges are partitions whose leader changed.
+	ReplicaChanges []PartitionChange // ReplicaChanges are partitions whose replica set changed (i.e., were reassigned).
+	ISRShrinks     []PartitionChange // ISRShrinks are partitions that have a replica in the old ISR that is not in the new ISR.
+	ISRExpands     []PartitionChange // ISRExpands are partitions that have a replica in the new ISR that was not in the old ISR.
+
+	Old Metadata // Old is the old metadata that was diffed.
+	New Metadata // New is the new metadata that was diffed.
+}
+
+// Empty returns whether nothing changed between the two snapshots.
+func (d MetadataDiff) Empty() bool {
+	return !d.ControllerChanged &&
+		len(d.BrokersAdded) == 0 &&
+		len(d.BrokersRemoved) == 0 &&
+		len(d.BrokersChanged) == 0 &&
+		len(d.TopicsAdded) == 0 &&
+		len(d.TopicsRemoved) == 0 &&
+		len(d.PartitionsAdded) == 0 &&
+		len(d.LeaderChanges) == 0 &&
+		len(d.ReplicaChanges) == 0 &&
+		len(d.ISRShrinks) == 0 &&
+		len(d.ISRExpands) == 0
+}
+
+// DiffMetadata returns the changes from the before metadata to the after
+// metadata, which are the Old and New metadata in the returned diff. This is
+// useful for anything that periodically requests metadata and reconciles on
+// changes.
+//
+// Topics that failed to load in either snapshot with an error other than
+// UNKNOWN_TOPIC_OR_PARTITION are not compared, since their partitions are
+// unknown. Likewise, partitions that have a load error in either snapshot are
+// not compared.
+func DiffMetadata(before, after Metadata) MetadataDiff {
+	d := MetadataDiff{
+		ControllerChanged: before.Controller != after.Controller,
+		Old:               before,
+		New:               after,
+	}
+
+	oldBrokers := make(map[int32]BrokerDetail, len(before.Brokers))
+	for _, b := range before.Brokers {
+		oldBrokers[b.NodeID] = b
+	}
+	newBrokers := make(map[int32]BrokerDetail, len(after.Brokers))
+	for _, b := range after.Brokers {
+		newBrokers[b.NodeID] = b
+		o, ok := oldBrokers[b.NodeID]
+		switch {
+		case !ok:
+			d.BrokersAdded = append(d.BrokersAdded, b)
+		case o.Host != b.Host || o.Port != b.Port || unptrStr(o.Rack) != unptrStr(b.Rack):
+			d.BrokersChanged = append(d.BrokersChanged, BrokerChange{o, b})
+		}
+	}
+	for _, b := range before.Brokers {
+		if _, ok := newBrokers[b.NodeID]; !ok {
+			d.BrokersRemoved = append(d.BrokersRemoved, b)
+		}
+	}
+	sortBrokers := func(bs []BrokerDetail) {
+		sort.Slice(bs, func(i, j int) bool { return bs[i].NodeID < bs[j].NodeID })
+	}
+	sortBrokers(d.BrokersAdded)
+	sortBrokers(d.BrokersRemoved)
+	sort.Slice(d.BrokersChanged, func(i, j int) bool { return d.BrokersChanged[i].New.NodeID < d.BrokersChanged[j].New.NodeID })
+
+	for _, nt := range after.Topics.Sorted() {
+		if !after.Topics.Has(nt.Topic) {
+			continue
+		}
+		if !before.Topics.Has(nt.Topic) {
+			d.TopicsAdded = append(d.TopicsAdded, nt)
+			continue
+		}
+		ot := before.Topics[nt.Topic]
+		if ot.Err != nil || nt.Err != nil {
+			continue
+		}
+		for _, np := range nt.Partitions.Sorted() {
+			op, ok := ot.Partitions[np.Partition]
+			if !ok {
+				d.PartitionsAdded = append(d.PartitionsAdded, np)
+				continue
+			}
+			if op.Err != nil || np.Err != nil {
+				continue
+			}
+			c := PartitionChange{op, np}
+			if op.Leader != np.Leader {
+				d.LeaderChanges = append(d.LeaderChanges, c)
+			}
+			if !sameReplicas(op.Replicas, np.Replicas) {
+				d.ReplicaChanges = append(d.ReplicaChanges, c)
+			}
+			if missingReplica(op.ISR, np.ISR) {
+				d.ISRShrinks = append(d.ISRShrinks, c)
+			}
+			if missingReplica(np.ISR, op.ISR) {
+				d.ISRExpands = append(d.ISRExpands, c)
+			}
+		}
+	}
+	for _, ot := range before.Topics.Sorted() {
+		if before.Topics.Has(ot.Topic) && !after.Topics.Has(ot.Topic) {
+			d.TopicsRemoved = append(d.TopicsRemoved, ot)
+		}
+	}
+	return d
+}
+
+// sameReplicas returns whether two replica lists are identical, including
+// order (the first replica is the preferred leader).
+func sameReplicas(l, r []int32) bool {
+	if len(l) != len(r) {
+		return false
+	}
+	for i := range l {
+		if l[i] != r[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// missingReplica returns whether any replica in from is missing in to.
+func missingReplica(from, to []int32) bool {
+	in := make(map[int32]bool, len(to))
+	for _, r := range to {
+		in[r] = true
+	}
+	for _, r := range from {
+		if !in[r] {
+			return true
+		}
+	}
+	return false
+}
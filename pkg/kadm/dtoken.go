@@ -95,7 +95,7 @@ func (cl *Client) CreateDelegationToken(ctx context.Context, d CreateDelegationT
 		req.Renewers = append(req.Renewers, rr)
 	}
 	req.MaxLifetimeMillis = d.MaxLifetime.Milliseconds()
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return DelegationToken{}, err
 	}
@@ -136,7 +136,7 @@ func (cl *Client) RenewDelegationToken(ctx context.Context, hmac []byte, renewTi
 	req := kmsg.NewPtrRenewDelegationTokenRequest()
 	req.HMAC = hmac
 	req.RenewTimeMillis = renewTime.Milliseconds()
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -160,7 +160,7 @@ func (cl *Client) ExpireDelegationToken(ctx context.Context, hmac []byte, expiry
 	req := kmsg.NewPtrExpireDelegationTokenRequest()
 	req.HMAC = hmac
 	req.ExpiryPeriodMillis = expiry.Milliseconds()
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -186,7 +186,7 @@ func (cl *Client) DescribeDelegationTokens(ctx context.Context, owners ...Princi
 		ro.PrincipalName = owner.Name
 		req.Owners = append(req.Owners, ro)
 	}
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
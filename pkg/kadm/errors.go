@@ -3,6 +3,8 @@ package kadm
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/twmb/franz-go/pkg/kerr"
 	"github.com/twmb/franz-go/pkg/kgo"
@@ -57,11 +59,161 @@ type ShardError struct {
 //
 // If a request returns ShardErrors, it is possible that some aspects of the
 // request were still successful. You can check ShardErrors.AllFailed as a
-// shortcut for whether any of the response is usable or not.
+// shortcut for whether any of the response is usable or not. To see which
+// pieces failed, you can use FailedBrokers, FailedTopics, and FailedGroups;
+// to see which brokers succeeded, you can check Succeeded. The Partial
+// function can be used to split a method's return into the usable partial
+// result and the shard errors.
 type ShardErrors struct {
 	Name      string       // Name is the name of the request these shard errors are for.
 	AllFailed bool         // AllFailed indicates if the original request was entirely unsuccessful.
 	Errs      []ShardError // Errs contains all individual shard errors.
+
+	// Succeeded contains the brokers that successfully responded to
+	// their piece of the request. Data in the returned result that came
+	// from these brokers is usable. A broker that responded with an
+	// error is not included, even if the method does not return that
+	// error in Errs.
+	Succeeded []BrokerDetail
+}
+
+// FailedBrokers returns the brokers that pieces of the request failed on. A
+// broker with NodeID -1 indicates that a piece of the request failed before
+// being mapped to a broker.
+func (e *ShardErrors) FailedBrokers() []BrokerDetail {
+	seen := make(map[int32]bool)
+	var bs []BrokerDetail
+	for _, se := range e.Errs {
+		if !seen[se.Broker.NodeID] {
+			seen[se.Broker.NodeID] = true
+			bs = append(bs, se.Broker)
+		}
+	}
+	sort.Slice(bs, func(i, j int) bool { return bs[i].NodeID < bs[j].NodeID })
+	return bs
+}
+
+// FailedTopics returns the topics and partitions that were in pieces of the
+// request that failed, for requests that are split by topic and partition.
+// Topics that are split by topic only (such as config requests) have no
+// partitions in the returned set.
+func (e *ShardErrors) FailedTopics() TopicsSet {
+	s := make(TopicsSet)
+	for _, se := range e.Errs {
+		switch req := se.Req.(type) {
+		case *kmsg.ListOffsetsRequest:
+			for _, t := range req.Topics {
+				for _, p := range t.Partitions {
+					s.Add(t.Topic, p.Partition)
+				}
+			}
+		case *kmsg.OffsetForLeaderEpochRequest:
+			for _, t := range req.Topics {
+				for _, p := range t.Partitions {
+					s.Add(t.Topic, p.Partition)
+				}
+			}
+		case *kmsg.DeleteRecordsRequest:
+			for _, t := range req.Topics {
+				for _, p := range t.Partitions {
+					s.Add(t.Topic, p.Partition)
+				}
+			}
+		case *kmsg.DescribeProducersRequest:
+			for _, t := range req.Topics {
+				s.Add(t.Topic, t.Partitions...)
+			}
+		case *kmsg.DescribeLogDirsRequest:
+			for _, t := range req.Topics {
+				s.Add(t.Topic, t.Partitions...)
+			}
+		case *kmsg.DescribeConfigsRequest:
+			for _, r := range req.Resources {
+				if r.ResourceType == kmsg.ConfigResourceTypeTopic {
+					s.Add(r.ResourceName)
+				}
+			}
+		case *kmsg.IncrementalAlterConfigsRequest:
+			for _, r := range req.Resources {
+				if r.ResourceType == kmsg.ConfigResourceTypeTopic {
+					s.Add(r.ResourceName)
+				}
+			}
+		case *kmsg.AlterConfigsRequest:
+			for _, r := range req.Resources {
+				if r.ResourceType == kmsg.ConfigResourceTypeTopic {
+					s.Add(r.ResourceName)
+				}
+			}
+		}
+	}
+	return s
+}
+
+// FailedGroups returns the groups that were in pieces of the request that
+// failed, for requests that are split by group.
+func (e *ShardErrors) FailedGroups() []string {
+	var gs []string
+	for _, se := range e.Errs {
+		switch req := se.Req.(type) {
+		case *kmsg.DescribeGroupsRequest:
+			gs = append(gs, req.Groups...)
+		case *kmsg.ConsumerGroupDescribeRequest:
+			gs = append(gs, req.Groups...)
+		case *kmsg.DeleteGroupsRequest:
+			gs = append(gs, req.Groups...)
+		case *kmsg.OffsetFetchRequest:
+			if len(req.Groups) == 0 {
+				gs = append(gs, req.Group)
+			}
+			for _, g := range req.Groups {
+				gs = append(gs, g.Group)
+			}
+		}
+	}
+	sort.Strings(gs)
+	return gs
+}
+
+// PartialResult is the result of a method that may have partially failed,
+// split into the usable result and the pieces that failed. See Partial.
+type PartialResult[T any] struct {
+	// Result is the result returned from the method. If Failed is
+	// non-nil, this contains only the successful subset of the result.
+	Result T
+
+	// Failed contains the pieces of the request that failed, or is nil if
+	// the entire request was successful.
+	Failed *ShardErrors
+}
+
+// Complete returns whether the entire request was successful.
+func (p PartialResult[T]) Complete() bool { return p.Failed == nil }
+
+// Partial splits the return of a method that may return *ShardErrors into the
+// usable result and the failed pieces:
+//
+//	res, err := kadm.Partial(adm.ListEndOffsets(ctx))
+//	if err != nil {
+//		return err // nothing is usable
+//	}
+//	if !res.Complete() {
+//		log.Printf("unable to list %v", res.Failed.FailedTopics())
+//	}
+//	use(res.Result)
+//
+// This returns an error only if the input error is not *ShardErrors (i.e. the
+// method failed entirely, or returned an *AuthError), or if every shard
+// failed.
+func Partial[T any](v T, err error) (PartialResult[T], error) {
+	if err == nil {
+		return PartialResult[T]{Result: v}, nil
+	}
+	var se *ShardErrors
+	if !errors.As(err, &se) || se.AllFailed {
+		return PartialResult[T]{}, err
+	}
+	return PartialResult[T]{Result: v, Failed: se}, nil
 }
 
 func shardErrEach(req kmsg.Request, shards []kgo.ResponseShard, fn func(kmsg.Response) error) error {
@@ -70,7 +222,21 @@ func shardErrEach(req kmsg.Request, shards []kgo.ResponseShard, fn func(kmsg.Res
 	})
 }
 
+// shardErrEachBroker calls fn for every successful shard, returning any shard
+// request errors as *ShardErrors, or an *AuthError if fn returns one. Other
+// errors from fn are not returned, but the shard's broker is not considered
+// to have succeeded.
 func shardErrEachBroker(req kmsg.Request, shards []kgo.ResponseShard, fn func(BrokerDetail, kmsg.Response) error) error {
+	return shardErrEachBrokerWith(req, shards, false, fn)
+}
+
+// shardErrEachBrokerRecordFn is like shardErrEachBroker, but non-auth errors
+// from fn are also returned as a failure of the shard's broker.
+func shardErrEachBrokerRecordFn(req kmsg.Request, shards []kgo.ResponseShard, fn func(BrokerDetail, kmsg.Response) error) error {
+	return shardErrEachBrokerWith(req, shards, true, fn)
+}
+
+func shardErrEachBrokerWith(req kmsg.Request, shards []kgo.ResponseShard, recordFn bool, fn func(BrokerDetail, kmsg.Response) error) error {
 	se := ShardErrors{
 		Name: kmsg.NameForKey(req.Key()),
 	}
@@ -84,9 +250,21 @@ func shardErrEachBroker(req kmsg.Request, shards []kgo.ResponseShard, fn func(Br
 			})
 			continue
 		}
-		if err := fn(shard.Meta, shard.Resp); errors.As(err, &ae) {
+		err := fn(shard.Meta, shard.Resp)
+		if errors.As(err, &ae) {
 			return ae
 		}
+		if err != nil {
+			if recordFn {
+				se.Errs = append(se.Errs, ShardError{
+					Req:    shard.Req,
+					Err:    err,
+					Broker: shard.Meta,
+				})
+			}
+			continue
+		}
+		se.Succeeded = append(se.Succeeded, shard.Meta)
 	}
 	se.AllFailed = len(shards) == len(se.Errs)
 	return se.into()
@@ -99,18 +277,76 @@ func (se *ShardErrors) into() error {
 	return se
 }
 
-// Merges two shard errors; the input errors should come from the same request.
+// Merges two errors from pieces of the same request. An *AuthError is
+// returned as is. Otherwise, shard errors are merged, and an error that is not
+// a shard error means its piece of the request failed entirely: it is kept as
+// a shard error that failed before being mapped to a broker, or, if neither
+// error is a shard error, both errors are kept in a joined error.
 func mergeShardErrs(e1, e2 error) error {
-	var se1, se2 *ShardErrors
-	if !errors.As(e1, &se1) {
+	switch {
+	case e1 == nil:
 		return e2
-	}
-	if !errors.As(e2, &se2) {
+	case e2 == nil:
 		return e1
 	}
-	se1.Errs = append(se1.Errs, se2.Errs...)
-	se1.AllFailed = se1.AllFailed && se2.AllFailed
-	return se1
+	var ae *AuthError
+	if errors.As(e1, &ae) || errors.As(e2, &ae) {
+		return ae
+	}
+	var se1, se2 *ShardErrors
+	is1, is2 := errors.As(e1, &se1), errors.As(e2, &se2)
+	switch {
+	case is1 && is2:
+		se1.Errs = append(se1.Errs, se2.Errs...)
+		se1.Succeeded = append(se1.Succeeded, se2.Succeeded...)
+		se1.AllFailed = se1.AllFailed && se2.AllFailed
+		return se1
+	case is1:
+		se1.Errs = append(se1.Errs, ShardError{Err: e2, Broker: BrokerDetail{NodeID: -1}})
+		return se1
+	case is2:
+		se2.Errs = append(se2.Errs, ShardError{Err: e1, Broker: BrokerDetail{NodeID: -1}})
+		return se2
+	default:
+		return joinedErrs{e1, e2}
+	}
+}
+
+// joinedErrs is errors.Join, which we cannot use until we require Go 1.20.
+type joinedErrs []error
+
+func (es joinedErrs) Error() string {
+	var sb strings.Builder
+	for i, err := range es {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+func (es joinedErrs) Unwrap() []error { return es }
+
+// Is and As are implemented directly because errors.Is and errors.As only
+// follow Unwrap() []error as of Go 1.20.
+
+func (es joinedErrs) Is(target error) bool {
+	for _, err := range es {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (es joinedErrs) As(target any) bool {
+	for _, err := range es {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
 }
 
 // Error returns an error indicating the name of the request that failed, the
@@ -12,3 +12,5 @@ require (
 	github.com/klauspost/compress v1.16.3 // indirect
 	github.com/pierrec/lz4/v4 v4.1.17 // indirect
 )
+
+replace github.com/twmb/franz-go/pkg/kmsg => ../kmsg
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/twmb/franz-go/pkg/kerr"
 	"github.com/twmb/franz-go/pkg/kgo"
@@ -178,6 +179,7 @@ type ListedGroup struct {
 	Group        string // Group is the name of this group.
 	ProtocolType string // ProtocolType is the type of protocol the group is using, "consumer" for normal consumers, "connect" for Kafka connect.
 	State        string // State is the state this group is in (Empty, Dead, Stable, etc.; only if talking to Kafka 2.6+).
+	Type         string // Type is the type of this group, "classic" or "consumer" (only if talking to Kafka 3.8+).
 }
 
 // ListedGroups contains information from a list groups response.
@@ -212,7 +214,7 @@ func (ls ListedGroups) Groups() []string {
 func (cl *Client) ListGroups(ctx context.Context, filterStates ...string) (ListedGroups, error) {
 	req := kmsg.NewPtrListGroupsRequest()
 	req.StatesFilter = append(req.StatesFilter, filterStates...)
-	shards := cl.cl.RequestSharded(ctx, req)
+	shards := cl.requestSharded(ctx, req)
 	list := make(ListedGroups)
 	return list, shardErrEachBroker(req, shards, func(b BrokerDetail, kr kmsg.Response) error {
 		resp := kr.(*kmsg.ListGroupsResponse)
@@ -228,12 +230,147 @@ func (cl *Client) ListGroups(ctx context.Context, filterStates ...string) (Liste
 				Group:        g.Group,
 				ProtocolType: g.ProtocolType,
 				State:        g.GroupState,
+				Type:         g.GroupType,
 			}
 		}
 		return nil
 	})
 }
 
+// ListGroupsFilter filters which groups are returned from ListGroupsPages.
+type ListGroupsFilter struct {
+	// States, if non-empty, returns only groups in the given states.
+	// This filter is applied by the broker if talking to Kafka 2.6+, and
+	// is otherwise applied by the client.
+	States []string
+
+	// Types, if non-empty, returns only groups of the given types
+	// ("classic" for groups using the classic rebalance protocol,
+	// "consumer" for KIP-848 groups). This filter is applied by the broker
+	// if talking to Kafka 3.8+, and is otherwise applied by the client,
+	// where every group is a classic group.
+	Types []string
+
+	// ProtocolTypes, if non-empty, returns only groups using the given
+	// protocol types ("consumer" for normal consumers, "connect" for
+	// Kafka connect). Kafka has no broker side filter for protocol types,
+	// so this filter is always applied by the client.
+	ProtocolTypes []string
+}
+
+// keep returns whether a listed group passes the filter, applying client side
+// whatever the broker could not filter.
+func (f *ListGroupsFilter) keep(g ListedGroup) bool {
+	keep := func(want []string, have string) bool {
+		if len(want) == 0 {
+			return true
+		}
+		for _, w := range want {
+			if strings.EqualFold(w, have) {
+				return true
+			}
+		}
+		return false
+	}
+	// If the broker does not support filtering states, it returns no
+	// state; we cannot filter these groups client side. If the broker
+	// does not support filtering types, it returns no type; these
+	// brokers only have classic groups.
+	typ := g.Type
+	if typ == "" {
+		typ = "classic"
+	}
+	return (g.State == "" || keep(f.States, g.State)) &&
+		keep(f.Types, typ) &&
+		keep(f.ProtocolTypes, g.ProtocolType)
+}
+
+// ListGroupsPages lists groups one broker at a time, calling fn with pages of
+// at most pageSize groups, sorted by group name within each broker. A
+// pageSize of zero or less calls fn once per broker with all groups on that
+// broker. If fn returns an error, listing stops and the error is returned.
+//
+// Kafka does not support paginating ListGroups itself, but every group lives
+// on exactly one broker (its coordinator). Listing brokers individually and
+// paging through each response bounds how many groups are held in memory at
+// once to the groups on a single broker, rather than every group in the
+// cluster. This is more suitable than ListGroups for clusters with tens of
+// thousands of groups.
+//
+// If listing fails on some brokers, this continues to the remaining brokers
+// and returns *ShardErrors after all brokers have been listed.
+func (cl *Client) ListGroupsPages(
+	ctx context.Context,
+	filter ListGroupsFilter,
+	pageSize int,
+	fn func(ListedGroups) error,
+) error {
+	brokers, err := cl.ListBrokers(ctx)
+	if err != nil {
+		return err
+	}
+	if cl.toBroker {
+		brokers = BrokerDetails{{NodeID: cl.broker}}
+	}
+
+	req := kmsg.NewPtrListGroupsRequest()
+	req.StatesFilter = append(req.StatesFilter, filter.States...)
+	req.TypesFilter = append(req.TypesFilter, filter.Types...)
+	se := ShardErrors{Name: kmsg.NameForKey(req.Key())}
+	for _, b := range brokers {
+		kresp, err := cl.brokerRequestor(b.NodeID).Request(ctx, req)
+		if err == nil {
+			resp := kresp.(*kmsg.ListGroupsResponse)
+			if err = maybeAuthErr(resp.ErrorCode); err != nil {
+				return err
+			}
+			err = kerr.ErrorForCode(resp.ErrorCode)
+			if err == nil {
+				var page []ListedGroup
+				for _, g := range resp.Groups {
+					l := ListedGroup{
+						Coordinator:  b.NodeID,
+						Group:        g.Group,
+						ProtocolType: g.ProtocolType,
+						State:        g.GroupState,
+						Type:         g.GroupType,
+					}
+					if filter.keep(l) {
+						page = append(page, l)
+					}
+				}
+				sort.Slice(page, func(i, j int) bool { return page[i].Group < page[j].Group })
+				for len(page) > 0 {
+					n := len(page)
+					if pageSize > 0 && n > pageSize {
+						n = pageSize
+					}
+					ls := make(ListedGroups, n)
+					for _, l := range page[:n] {
+						ls[l.Group] = l
+					}
+					page = page[n:]
+					if err := fn(ls); err != nil {
+						return err
+					}
+				}
+				se.Succeeded = append(se.Succeeded, b)
+				continue
+			}
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		se.Errs = append(se.Errs, ShardError{
+			Req:    req,
+			Err:    err,
+			Broker: b,
+		})
+	}
+	se.AllFailed = len(se.Errs) == len(brokers)
+	return se.into()
+}
+
 // DescribeGroups describes either all groups specified, or all groups in the
 // cluster if none are specified.
 //
@@ -265,7 +402,7 @@ func (cl *Client) DescribeGroups(ctx context.Context, groups ...string) (Describ
 	req := kmsg.NewPtrDescribeGroupsRequest()
 	req.Groups = groups
 
-	shards := cl.cl.RequestSharded(ctx, req)
+	shards := cl.requestSharded(ctx, req)
 	described := make(DescribedGroups)
 	err := shardErrEachBroker(req, shards, func(b BrokerDetail, kr kmsg.Response) error {
 		resp := kr.(*kmsg.DescribeGroupsResponse)
@@ -346,6 +483,325 @@ func (cl *Client) DescribeGroups(ctx context.Context, groups ...string) (Describ
 	}
 }
 
+// DescribedConsumerGroupMember is the detail of an individual member of a
+// consumer group as returned by DescribeConsumerGroups.
+type DescribedConsumerGroupMember struct {
+	MemberID   string  // MemberID is the member ID of this group member.
+	InstanceID *string // InstanceID is a potential user assigned instance ID of this group member (KIP-345).
+	RackID     *string // RackID is a potential rack of this group member.
+	ClientID   string  // ClientID is the client ID of this group member.
+	ClientHost string  // ClientHost is the host this member is running on.
+
+	// MemberEpoch is the current epoch of this member. This is always 0
+	// for classic groups.
+	MemberEpoch int32
+
+	SubscribedTopics     []string // SubscribedTopics are the topics this member is subscribed to.
+	SubscribedTopicRegex *string  // SubscribedTopicRegex is a potential regex this member is subscribed with; this is always nil for classic groups.
+
+	// Assignment is what this member currently owns.
+	Assignment TopicsSet
+
+	// TargetAssignment is what the group coordinator wants this member to
+	// own; once the member reconciles, Assignment is the same as
+	// TargetAssignment. For classic groups, this is the same as
+	// Assignment.
+	TargetAssignment TopicsSet
+}
+
+// DescribedConsumerGroup contains data from describing a consumer group with
+// DescribeConsumerGroups.
+type DescribedConsumerGroup struct {
+	Group string // Group is the name of the described group.
+
+	Coordinator BrokerDetail // Coordinator is the coordinator broker for this group.
+
+	// Type is the type of this group: "consumer" for groups using the
+	// KIP-848 consumer group protocol, and "classic" for groups using the
+	// JoinGroup / SyncGroup protocol.
+	Type string
+
+	State           string // State is the state this group is in.
+	Epoch           int32  // Epoch is the group epoch; this is always 0 for classic groups.
+	AssignmentEpoch int32  // AssignmentEpoch is the epoch of the target assignment; this is always 0 for classic groups.
+	AssignorName    string // AssignorName is the server side assignor (KIP-848) or the client protocol (classic) of this group.
+
+	// Members contains the members of this group sorted first by
+	// InstanceID, or if nil, by MemberID.
+	Members []DescribedConsumerGroupMember
+
+	// Classic is the classic description of this group, if this group is
+	// a classic group.
+	Classic *DescribedGroup
+
+	Err        error  // Err is non-nil if the group could not be described.
+	ErrMessage string // ErrMessage a potential extra message describing any error.
+}
+
+// IsClassic returns whether this group is a classic group.
+func (d *DescribedConsumerGroup) IsClassic() bool {
+	return d.Type == "classic"
+}
+
+// AssignedPartitions returns the set of unique topics and partitions that are
+// currently assigned across all members in this group.
+func (d *DescribedConsumerGroup) AssignedPartitions() TopicsSet {
+	s := make(TopicsSet)
+	for _, m := range d.Members {
+		s.Merge(m.Assignment)
+	}
+	return s
+}
+
+// DescribedConsumerGroups contains data for multiple groups from
+// DescribeConsumerGroups.
+type DescribedConsumerGroups map[string]DescribedConsumerGroup
+
+// Sorted returns all groups sorted by group name.
+func (ds DescribedConsumerGroups) Sorted() []DescribedConsumerGroup {
+	s := make([]DescribedConsumerGroup, 0, len(ds))
+	for _, d := range ds {
+		s = append(s, d)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Group < s[j].Group })
+	return s
+}
+
+// On calls fn for the group if it exists, returning the group and the error
+// returned from fn. If fn is nil, this simply returns the group.
+//
+// The fn is given a shallow copy of the group. This function returns the copy
+// as well; any modifications within fn are modifications on the returned copy.
+//
+// If the group does not exist, this returns kerr.GroupIDNotFound.
+func (ds DescribedConsumerGroups) On(group string, fn func(*DescribedConsumerGroup) error) (DescribedConsumerGroup, error) {
+	if len(ds) > 0 {
+		d, ok := ds[group]
+		if ok {
+			if fn == nil {
+				return d, nil
+			}
+			return d, fn(&d)
+		}
+	}
+	return DescribedConsumerGroup{}, kerr.GroupIDNotFound
+}
+
+// Names returns a sorted list of all group names.
+func (ds DescribedConsumerGroups) Names() []string {
+	all := make([]string, 0, len(ds))
+	for g := range ds {
+		all = append(all, g)
+	}
+	sort.Strings(all)
+	return all
+}
+
+// Error iterates over all groups and returns the first error encountered, if
+// any.
+func (ds DescribedConsumerGroups) Error() error {
+	for _, d := range ds {
+		if d.Err != nil {
+			return d.Err
+		}
+	}
+	return nil
+}
+
+// Ok returns true if there are no errors. This is a shortcut for ds.Error() ==
+// nil.
+func (ds DescribedConsumerGroups) Ok() bool {
+	return ds.Error() == nil
+}
+
+// DescribeConsumerGroups describes consumer groups using the KIP-848
+// ConsumerGroupDescribe request, falling back to DescribeGroups for classic
+// groups and for coordinators that do not support ConsumerGroupDescribe.
+// Groups that are described with the fallback have Type "classic" and the
+// full classic description in the Classic field. If no groups are specified,
+// all groups are listed and described.
+//
+// This may return *ShardErrors or an *AuthError.
+func (cl *Client) DescribeConsumerGroups(ctx context.Context, groups ...string) (DescribedConsumerGroups, error) {
+	var seList *ShardErrors
+	if len(groups) == 0 {
+		listed, err := cl.ListGroups(ctx)
+		switch {
+		case err == nil:
+		case errors.As(err, &seList):
+		default:
+			return nil, err
+		}
+		groups = listed.Groups()
+		if len(groups) == 0 {
+			return nil, err
+		}
+	}
+
+	described := make(DescribedConsumerGroups)
+	coordinators := make(map[int32]BrokerDetail)
+	byCoordinator := make(map[int32][]string)
+	for _, c := range cl.FindGroupCoordinators(ctx, groups...).Sorted() {
+		var ae *AuthError
+		if errors.As(c.Err, &ae) {
+			return nil, ae
+		}
+		if c.Err != nil {
+			described[c.Name] = DescribedConsumerGroup{Group: c.Name, Err: c.Err, ErrMessage: c.ErrMessage}
+			continue
+		}
+		coordinators[c.NodeID] = BrokerDetail{NodeID: c.NodeID, Host: c.Host, Port: c.Port}
+		byCoordinator[c.NodeID] = append(byCoordinator[c.NodeID], c.Name)
+	}
+
+	// ConsumerGroupDescribe must be sent directly to each coordinator.
+	// The client fails the request without sending it if its cached
+	// versions show the coordinator does not support it; coordinators we
+	// cannot describe with ConsumerGroupDescribe have all of their groups
+	// described with DescribeGroups instead, which returns any error the
+	// coordinator still has.
+	var (
+		classic []string
+		shards  []kgo.ResponseShard
+	)
+	for node, gs := range byCoordinator {
+		req := kmsg.NewPtrConsumerGroupDescribeRequest()
+		req.Groups = gs
+		resp, err := cl.brokerRequestor(node).Request(ctx, req)
+		if err != nil {
+			classic = append(classic, gs...)
+			continue
+		}
+		shards = append(shards, kgo.ResponseShard{Meta: coordinators[node], Req: req, Resp: resp})
+	}
+
+	req := kmsg.NewPtrConsumerGroupDescribeRequest()
+	err := shardErrEachBroker(req, shards, func(b BrokerDetail, kr kmsg.Response) error {
+		resp := kr.(*kmsg.ConsumerGroupDescribeResponse)
+		for _, rg := range resp.Groups {
+			if err := maybeAuthErr(rg.ErrorCode); err != nil {
+				return err
+			}
+			// A group that exists but is a classic group returns
+			// GROUP_ID_NOT_FOUND.
+			if rg.ErrorCode == kerr.GroupIDNotFound.Code {
+				classic = append(classic, rg.Group)
+				continue
+			}
+			g := DescribedConsumerGroup{
+				Group:           rg.Group,
+				Coordinator:     b,
+				Type:            "consumer",
+				State:           rg.State,
+				Epoch:           rg.Epoch,
+				AssignmentEpoch: rg.AssignmentEpoch,
+				AssignorName:    rg.AssignorName,
+				Err:             kerr.ErrorForCode(rg.ErrorCode),
+				ErrMessage:      unptrStr(rg.ErrorMessage),
+			}
+			for _, rm := range rg.Members {
+				g.Members = append(g.Members, DescribedConsumerGroupMember{
+					MemberID:             rm.MemberID,
+					InstanceID:           rm.InstanceID,
+					RackID:               rm.RackID,
+					ClientID:             rm.ClientID,
+					ClientHost:           rm.ClientHost,
+					MemberEpoch:          rm.MemberEpoch,
+					SubscribedTopics:     rm.SubscribedTopics,
+					SubscribedTopicRegex: rm.SubscribedTopicRegex,
+					Assignment:           consumerGroupAssignment(rm.Assignment),
+					TargetAssignment:     consumerGroupAssignment(rm.TargetAssignment),
+				})
+			}
+			sortConsumerGroupMembers(g.Members)
+			described[g.Group] = g
+		}
+		return nil
+	})
+	var ae *AuthError
+	if errors.As(err, &ae) {
+		return nil, err
+	}
+
+	if len(classic) > 0 {
+		gs, cerr := cl.DescribeGroups(ctx, classic...)
+		if errors.As(cerr, &ae) {
+			return nil, cerr
+		}
+		for _, g := range gs {
+			g := g
+			d := DescribedConsumerGroup{
+				Group:        g.Group,
+				Coordinator:  g.Coordinator,
+				Type:         "classic",
+				State:        g.State,
+				AssignorName: g.Protocol,
+				Classic:      &g,
+				Err:          g.Err,
+			}
+			for _, m := range g.Members {
+				dm := DescribedConsumerGroupMember{
+					MemberID:   m.MemberID,
+					InstanceID: m.InstanceID,
+					ClientID:   m.ClientID,
+					ClientHost: m.ClientHost,
+					Assignment: make(TopicsSet),
+				}
+				if c, ok := m.Join.AsConsumer(); ok {
+					dm.SubscribedTopics = c.Topics
+					dm.RackID = c.Rack
+				}
+				if c, ok := m.Assigned.AsConsumer(); ok {
+					for _, t := range c.Topics {
+						dm.Assignment.Add(t.Topic, t.Partitions...)
+					}
+				}
+				dm.TargetAssignment = dm.Assignment
+				d.Members = append(d.Members, dm)
+			}
+			sortConsumerGroupMembers(d.Members)
+			described[d.Group] = d
+		}
+		err = mergeShardErrs(err, cerr)
+	}
+
+	var seDesc *ShardErrors
+	switch {
+	case err == nil:
+		return described, seList.into()
+	case errors.As(err, &seDesc):
+		if seList != nil {
+			seDesc.Errs = append(seList.Errs, seDesc.Errs...)
+		}
+		return described, seDesc.into()
+	default:
+		return nil, err
+	}
+}
+
+func consumerGroupAssignment(a kmsg.ConsumerGroupDescribeResponseGroupMemberAssignment) TopicsSet {
+	s := make(TopicsSet)
+	for _, t := range a.TopicPartitions {
+		s.Add(t.Topic, t.Partitions...)
+	}
+	return s
+}
+
+func sortConsumerGroupMembers(ms []DescribedConsumerGroupMember) {
+	sort.Slice(ms, func(i, j int) bool {
+		if ms[i].InstanceID != nil {
+			if ms[j].InstanceID == nil {
+				return true
+			}
+			return *ms[i].InstanceID < *ms[j].InstanceID
+		}
+		if ms[j].InstanceID != nil {
+			return false
+		}
+		return ms[i].MemberID < ms[j].MemberID
+	})
+}
+
 // DeleteGroupResponse contains the response for an individual deleted group.
 type DeleteGroupResponse struct {
 	Group string // Group is the group this response is for.
@@ -399,7 +855,7 @@ func (cl *Client) DeleteGroups(ctx context.Context, groups ...string) (DeleteGro
 	}
 	req := kmsg.NewPtrDeleteGroupsRequest()
 	req.Groups = append(req.Groups, groups...)
-	shards := cl.cl.RequestSharded(ctx, req)
+	shards := cl.requestSharded(ctx, req)
 
 	rs := make(map[string]DeleteGroupResponse)
 	return rs, shardErrEach(req, shards, func(kr kmsg.Response) error {
@@ -423,6 +879,8 @@ type LeaveGroupBuilder struct {
 	group       string
 	reason      *string
 	instanceIDs []*string
+	memberIDs   []string
+	all         bool
 }
 
 // LeaveGroup returns a LeaveGroupBuilder for the input group.
@@ -449,6 +907,27 @@ func (b *LeaveGroupBuilder) InstanceIDs(ids ...string) *LeaveGroupBuilder {
 	return b
 }
 
+// MemberIDs are dynamic members (members without an instance ID) to remove
+// from a group. This requires Kafka 2.4+.
+func (b *LeaveGroupBuilder) MemberIDs(ids ...string) *LeaveGroupBuilder {
+	for _, id := range ids {
+		if id != "" {
+			b.memberIDs = append(b.memberIDs, id)
+		}
+	}
+	return b
+}
+
+// AllMembers removes every member currently in the group, static or dynamic,
+// forcing the group to rebalance. The group is described with
+// DescribeConsumerGroups to find its members before leaving, meaning both
+// classic and KIP-848 consumer groups are supported; any InstanceIDs or
+// MemberIDs are ignored.
+func (b *LeaveGroupBuilder) AllMembers() *LeaveGroupBuilder {
+	b.all = true
+	return b
+}
+
 // LeaveGroupResponse contains the response for an individual instance ID that
 // left a group.
 type LeaveGroupResponse struct {
@@ -459,16 +938,23 @@ type LeaveGroupResponse struct {
 }
 
 // LeaveGroupResponses contains responses for each member of a leave group
-// request. The map key is the instance ID that was removed from the group.
+// request. The map key is the instance ID that was removed from the group, or
+// the member ID if the member had no instance ID.
 type LeaveGroupResponses map[string]LeaveGroupResponse
 
-// Sorted returns all removed group members by instance ID.
+// Sorted returns all removed group members by instance ID, and then by member
+// ID.
 func (ls LeaveGroupResponses) Sorted() []LeaveGroupResponse {
 	s := make([]LeaveGroupResponse, 0, len(ls))
 	for _, l := range ls {
 		s = append(s, l)
 	}
-	sort.Slice(s, func(i, j int) bool { return s[i].InstanceID < s[j].InstanceID })
+	sort.Slice(s, func(i, j int) bool {
+		if s[i].InstanceID != s[j].InstanceID {
+			return s[i].InstanceID < s[j].InstanceID
+		}
+		return s[i].MemberID < s[j].MemberID
+	})
 	return s
 }
 
@@ -505,27 +991,61 @@ func (ls LeaveGroupResponses) Ok() bool {
 	return ls.Error() == nil
 }
 
-// LeaveGroup causes instance IDs to leave a group.
+// LeaveGroup causes instance IDs or member IDs to leave a group.
 //
 // This function allows manually removing members using instance IDs from a
 // group, which allows for fast scale down / host replacement (see KIP-345 for
-// more detail). This returns an *AuthErr if the use is not authorized to
-// remove members from groups.
+// more detail). Removing members, or all members with AllMembers, can also be
+// used to force a group to rebalance or to free a stuck static membership.
+// This returns an *AuthErr if the use is not authorized to remove members from
+// groups.
 func (cl *Client) LeaveGroup(ctx context.Context, b *LeaveGroupBuilder) (LeaveGroupResponses, error) {
-	if b == nil || len(b.instanceIDs) == 0 {
+	if b == nil {
+		return nil, nil
+	}
+	instanceIDs, memberIDs := b.instanceIDs, b.memberIDs
+	if b.all {
+		instanceIDs, memberIDs = nil, nil
+		described, err := cl.DescribeConsumerGroups(ctx, b.group)
+		if err != nil {
+			return nil, err
+		}
+		g, err := described.On(b.group, nil)
+		if err == nil {
+			err = g.Err
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range g.Members {
+			if m.InstanceID != nil {
+				instanceIDs = append(instanceIDs, m.InstanceID)
+			} else {
+				memberIDs = append(memberIDs, m.MemberID)
+			}
+		}
+	}
+	if len(instanceIDs) == 0 && len(memberIDs) == 0 {
 		return nil, nil
 	}
+
 	req := kmsg.NewPtrLeaveGroupRequest()
 	req.Group = b.group
-	for _, id := range b.instanceIDs {
+	for _, id := range instanceIDs {
 		m := kmsg.NewLeaveGroupRequestMember()
 		id := id
 		m.InstanceID = id
 		m.Reason = b.reason
 		req.Members = append(req.Members, m)
 	}
+	for _, id := range memberIDs {
+		m := kmsg.NewLeaveGroupRequestMember()
+		m.MemberID = id
+		m.Reason = b.reason
+		req.Members = append(req.Members, m)
+	}
 
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
@@ -538,14 +1058,15 @@ func (cl *Client) LeaveGroup(ctx context.Context, b *LeaveGroupBuilder) (LeaveGr
 
 	resps := make(LeaveGroupResponses)
 	for _, m := range resp.Members {
-		if m.InstanceID == nil {
-			continue // highly unexpected, buggy kafka
+		key := m.MemberID
+		if m.InstanceID != nil {
+			key = *m.InstanceID
 		}
-		resps[*m.InstanceID] = LeaveGroupResponse{
+		resps[key] = LeaveGroupResponse{
 			Group:      b.group,
 			MemberID:   m.MemberID,
-			InstanceID: *m.InstanceID,
-			Err:        kerr.ErrorForCode(resp.ErrorCode),
+			InstanceID: unptrStr(m.InstanceID),
+			Err:        kerr.ErrorForCode(m.ErrorCode),
 		}
 	}
 	return resps, err
@@ -709,6 +1230,14 @@ func (os OffsetResponses) Ok() bool {
 // partitions manually, but want still use Kafka to checkpoint what you have
 // consumed, you can manually issue an offset commit request with this method.
 //
+// This can also be used to seed or repair the committed offsets of any group
+// that has no active members, such as a group that has not started consuming
+// yet or a group whose consumers are stopped. The commit is issued outside of
+// any group generation (generation -1, with no member ID), which Kafka only
+// accepts if the group is empty: if the group has active members, every
+// partition fails with UNKNOWN_MEMBER_ID (or ILLEGAL_GENERATION on some Kafka
+// versions), and no offsets are committed.
+//
 // This does not return on authorization failures, instead, authorization
 // failures are included in the responses.
 func (cl *Client) CommitOffsets(ctx context.Context, group string, os Offsets) (OffsetResponses, error) {
@@ -730,7 +1259,7 @@ func (cl *Client) CommitOffsets(ctx context.Context, group string, os Offsets) (
 		req.Topics = append(req.Topics, rt)
 	}
 
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
@@ -793,7 +1322,7 @@ func (cl *Client) CommitAllOffsets(ctx context.Context, group string, os Offsets
 func (cl *Client) FetchOffsets(ctx context.Context, group string) (OffsetResponses, error) {
 	req := kmsg.NewPtrOffsetFetchRequest()
 	req.Group = group
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
@@ -967,7 +1496,7 @@ func (cl *Client) FetchManyOffsets(ctx context.Context, groups ...string) FetchO
 		}
 	}
 
-	shards := cl.cl.RequestSharded(ctx, req)
+	shards := cl.requestSharded(ctx, req)
 	for _, shard := range shards {
 		req := shard.Req.(*kmsg.OffsetFetchRequest)
 		if shard.Err != nil {
@@ -1078,7 +1607,7 @@ func (cl *Client) DeleteOffsets(ctx context.Context, group string, s TopicsSet)
 		req.Topics = append(req.Topics, rt)
 	}
 
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
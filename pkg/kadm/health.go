@@ -0,0 +1,216 @@
+package kadm
+
+import (
+	"context"
+	"errors"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// BrokerLeadership contains how many partitions a broker is the preferred
+// leader for, and how many of those it actually leads.
+type BrokerLeadership struct {
+	Broker int32 // Broker is the broker this leadership is for.
+
+	Preferred int // Preferred is the number of partitions this broker is the preferred (first) replica for.
+	Leading   int // Leading is the number of partitions this broker currently leads.
+
+	// NotLeadingPreferred is the number of partitions that this broker is
+	// the preferred leader for, but another broker is currently leading.
+	NotLeadingPreferred int
+}
+
+// Imbalance returns the ratio of partitions this broker is the preferred
+// leader for but does not lead, to the number of partitions this broker is
+// the preferred leader for. This is the same ratio Kafka compares against
+// leader.imbalance.per.broker.percentage when deciding whether to trigger a
+// preferred leader election.
+func (l BrokerLeadership) Imbalance() float64 {
+	if l.Preferred == 0 {
+		return 0
+	}
+	return float64(l.NotLeadingPreferred) / float64(l.Preferred)
+}
+
+// ClusterHealth is a summary of the health of a cluster, as returned from
+// Client.Health.
+type ClusterHealth struct {
+	Brokers    BrokerDetails // Brokers are the brokers currently in the cluster.
+	Controller int32         // Controller is the controller broker, or -1 if the cluster has no active controller.
+
+	// UnderReplicated contains partitions whose ISR is smaller than their
+	// replica set.
+	UnderReplicated []PartitionDetail
+
+	// Leaderless contains partitions that currently have no leader.
+	Leaderless []PartitionDetail
+
+	// Offline contains partitions that have no replica on any live
+	// broker, either because every replica is reported offline or because
+	// every replica is on a broker that is not in the cluster. These
+	// partitions cannot recover until a broker comes back.
+	Offline []PartitionDetail
+
+	// NotPreferredLeader contains partitions whose leader is not the first
+	// replica in the partition's replica list.
+	NotPreferredLeader []PartitionDetail
+
+	// OutOfISR contains, per broker, partitions that the broker is a
+	// replica for but is not in sync on.
+	OutOfISR map[int32][]Partition
+
+	// Leadership contains per-broker preferred leadership counts.
+	Leadership map[int32]BrokerLeadership
+
+	// OfflineLogDirs contains, per broker, log directories that returned
+	// an error when described (usually KAFKA_STORAGE_ERROR).
+	OfflineLogDirs map[int32][]DescribedLogDir
+
+	// TopicErrors contains topics that could not be loaded from metadata.
+	TopicErrors []TopicDetail
+}
+
+// Healthy returns whether the cluster has an active controller, no under
+// replicated, leaderless, or offline partitions, no broker is missing from any
+// ISR, no log directory is offline, and all topics loaded. Preferred leader
+// imbalance is not considered unhealthy; check NotPreferredLeader or
+// Leadership for that.
+func (h ClusterHealth) Healthy() bool {
+	return h.Controller >= 0 &&
+		len(h.UnderReplicated) == 0 &&
+		len(h.Leaderless) == 0 &&
+		len(h.Offline) == 0 &&
+		len(h.OutOfISR) == 0 &&
+		len(h.OfflineLogDirs) == 0 &&
+		len(h.TopicErrors) == 0
+}
+
+// CalculateHealth returns the health of a cluster from metadata and described
+// log directories. The log directories can be nil, in which case
+// OfflineLogDirs is empty.
+func CalculateHealth(m Metadata, dirs DescribedAllLogDirs) ClusterHealth {
+	h := ClusterHealth{
+		Brokers:        m.Brokers,
+		Controller:     -1,
+		OutOfISR:       make(map[int32][]Partition),
+		Leadership:     make(map[int32]BrokerLeadership),
+		OfflineLogDirs: make(map[int32][]DescribedLogDir),
+	}
+	live := make(map[int32]bool, len(m.Brokers))
+	for _, b := range m.Brokers {
+		live[b.NodeID] = true
+		h.Leadership[b.NodeID] = BrokerLeadership{Broker: b.NodeID}
+	}
+	if live[m.Controller] {
+		h.Controller = m.Controller
+	}
+
+	for _, td := range m.Topics.Sorted() {
+		if td.Err != nil {
+			h.TopicErrors = append(h.TopicErrors, td)
+			continue
+		}
+		for _, p := range td.Partitions.Sorted() {
+			if len(p.ISR) < len(p.Replicas) {
+				h.UnderReplicated = append(h.UnderReplicated, p)
+			}
+			if p.Leader < 0 {
+				h.Leaderless = append(h.Leaderless, p)
+			}
+
+			offline := make(map[int32]bool, len(p.OfflineReplicas))
+			for _, r := range p.OfflineReplicas {
+				offline[r] = true
+			}
+			isr := make(map[int32]bool, len(p.ISR))
+			for _, r := range p.ISR {
+				isr[r] = true
+			}
+			var anyLive bool
+			for _, r := range p.Replicas {
+				if live[r] && !offline[r] {
+					anyLive = true
+				}
+				if !isr[r] {
+					h.OutOfISR[r] = append(h.OutOfISR[r], Partition{p.Topic, p.Partition})
+				}
+			}
+			if !anyLive && len(p.Replicas) > 0 {
+				h.Offline = append(h.Offline, p)
+			}
+
+			if p.Leader >= 0 {
+				l := h.Leadership[p.Leader]
+				l.Broker = p.Leader
+				l.Leading++
+				h.Leadership[p.Leader] = l
+			}
+			if len(p.Replicas) > 0 {
+				preferred := p.Replicas[0]
+				l := h.Leadership[preferred]
+				l.Broker = preferred
+				l.Preferred++
+				if p.Leader != preferred {
+					l.NotLeadingPreferred++
+					h.NotPreferredLeader = append(h.NotPreferredLeader, p)
+				}
+				h.Leadership[preferred] = l
+			}
+		}
+	}
+
+	for _, d := range dirs.Sorted() {
+		if d.Err != nil {
+			h.OfflineLogDirs[d.Broker] = append(h.OfflineLogDirs[d.Broker], d)
+		}
+	}
+	return h
+}
+
+// Health returns a summary of the health of the cluster, built from a
+// metadata request for all topics and a describe log dirs request to every
+// broker. The log dirs request does not ask for any partitions and only
+// checks the status of each log directory.
+//
+// If describing log dirs fails on some brokers, this returns the health
+// report as well as *ShardErrors; the report is still usable, but it will not
+// include offline log directories for the brokers that failed. This returns
+// an error with no report if the metadata request fails, or an *AuthError.
+func (cl *Client) Health(ctx context.Context) (ClusterHealth, error) {
+	m, err := cl.Metadata(ctx)
+	if err != nil {
+		return ClusterHealth{}, err
+	}
+
+	req := kmsg.NewPtrDescribeLogDirsRequest()
+	req.Topics = []kmsg.DescribeLogDirsRequestTopic{} // non-nil and empty: only describe dirs
+	shards := cl.requestSharded(ctx, req)
+	dirs := make(DescribedAllLogDirs)
+	err = shardErrEachBrokerRecordFn(req, shards, func(b BrokerDetail, kr kmsg.Response) error {
+		resp := kr.(*kmsg.DescribeLogDirsResponse)
+		if err := maybeAuthErr(resp.ErrorCode); err != nil {
+			return err
+		}
+		if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+			return err
+		}
+		dirs[b.NodeID] = newDescribeLogDirsResp(b.NodeID, resp)
+		return nil
+	})
+	var ae *AuthError
+	if errors.As(err, &ae) {
+		return ClusterHealth{}, err
+	}
+	return CalculateHealth(m, dirs), err
+}
+
+// OutOfISRBrokers returns the brokers that are out of ISR for any partition,
+// in sorted order.
+func (h ClusterHealth) OutOfISRBrokers() []int32 {
+	bs := make([]int32, 0, len(h.OutOfISR))
+	for b := range h.OutOfISR {
+		bs = append(bs, b)
+	}
+	return int32s(bs)
+}
@@ -0,0 +1,332 @@
+package kadm
+
+import "encoding/json"
+
+// Result types that contain errors implement json.Marshaler so that they can
+// be emitted directly by CLIs and HTTP APIs: the error interface otherwise
+// marshals as an empty object. Every type is marshaled as it would be by
+// default, but with Err rendered as the error's string (and omitted if nil).
+// Topic IDs are marshaled as base64 strings.
+
+// errString returns the error's string, or empty if the error is nil.
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// MarshalJSON implements json.Marshaler.
+func (l ListedOffset) MarshalJSON() ([]byte, error) {
+	type alias ListedOffset
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(l), errString(l.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p PartitionDetail) MarshalJSON() ([]byte, error) {
+	type alias PartitionDetail
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(p), errString(p.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t TopicDetail) MarshalJSON() ([]byte, error) {
+	type alias TopicDetail
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(t), errString(t.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c CreatePartitionsResponse) MarshalJSON() ([]byte, error) {
+	type alias CreatePartitionsResponse
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(c), errString(c.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c CreateTopicResponse) MarshalJSON() ([]byte, error) {
+	type alias CreateTopicResponse
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(c), errString(c.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d DeleteRecordsResponse) MarshalJSON() ([]byte, error) {
+	type alias DeleteRecordsResponse
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(d), errString(d.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d DeleteTopicResponse) MarshalJSON() ([]byte, error) {
+	type alias DeleteTopicResponse
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(d), errString(d.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a AlterConfigsResponse) MarshalJSON() ([]byte, error) {
+	type alias AlterConfigsResponse
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(a), errString(a.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r ResourceConfig) MarshalJSON() ([]byte, error) {
+	type alias ResourceConfig
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(r), errString(r.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d DeleteGroupResponse) MarshalJSON() ([]byte, error) {
+	type alias DeleteGroupResponse
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(d), errString(d.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d DescribedConsumerGroup) MarshalJSON() ([]byte, error) {
+	type alias DescribedConsumerGroup
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(d), errString(d.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d DescribedGroup) MarshalJSON() ([]byte, error) {
+	type alias DescribedGroup
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(d), errString(d.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (f FetchOffsetsResponse) MarshalJSON() ([]byte, error) {
+	type alias FetchOffsetsResponse
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(f), errString(f.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (g GroupMemberLag) MarshalJSON() ([]byte, error) {
+	type alias GroupMemberLag
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(g), errString(g.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (l LeaveGroupResponse) MarshalJSON() ([]byte, error) {
+	type alias LeaveGroupResponse
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(l), errString(l.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (o OffsetResponse) MarshalJSON() ([]byte, error) {
+	type alias OffsetResponse
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(o), errString(o.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d DescribedProducersPartition) MarshalJSON() ([]byte, error) {
+	type alias DescribedProducersPartition
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(d), errString(d.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d DescribedTransaction) MarshalJSON() ([]byte, error) {
+	type alias DescribedTransaction
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(d), errString(d.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t TxnMarkersPartitionResponse) MarshalJSON() ([]byte, error) {
+	type alias TxnMarkersPartitionResponse
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(t), errString(t.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c CreateACLsResult) MarshalJSON() ([]byte, error) {
+	type alias CreateACLsResult
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(c), errString(c.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d DeleteACLsResult) MarshalJSON() ([]byte, error) {
+	type alias DeleteACLsResult
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(d), errString(d.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d DeletedACL) MarshalJSON() ([]byte, error) {
+	type alias DeletedACL
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(d), errString(d.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d DescribeACLsResult) MarshalJSON() ([]byte, error) {
+	type alias DescribeACLsResult
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(d), errString(d.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a AlterPartitionAssignmentsResponse) MarshalJSON() ([]byte, error) {
+	type alias AlterPartitionAssignmentsResponse
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(a), errString(a.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a AlterReplicaLogDirsResponse) MarshalJSON() ([]byte, error) {
+	type alias AlterReplicaLogDirsResponse
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(a), errString(a.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d DescribedLogDir) MarshalJSON() ([]byte, error) {
+	type alias DescribedLogDir
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(d), errString(d.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a AlteredClientQuota) MarshalJSON() ([]byte, error) {
+	type alias AlteredClientQuota
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(a), errString(a.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a AlteredSCRAMMechanism) MarshalJSON() ([]byte, error) {
+	type alias AlteredSCRAMMechanism
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(a), errString(a.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a AlteredUserSCRAM) MarshalJSON() ([]byte, error) {
+	type alias AlteredUserSCRAM
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(a), errString(a.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (b BrokerApiVersions) MarshalJSON() ([]byte, error) {
+	type alias BrokerApiVersions
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(b), errString(b.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d DescribedUserSCRAM) MarshalJSON() ([]byte, error) {
+	type alias DescribedUserSCRAM
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(d), errString(d.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (e ElectLeadersResult) MarshalJSON() ([]byte, error) {
+	type alias ElectLeadersResult
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(e), errString(e.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (f FindCoordinatorResponse) MarshalJSON() ([]byte, error) {
+	type alias FindCoordinatorResponse
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(f), errString(f.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (o OffsetForLeaderEpoch) MarshalJSON() ([]byte, error) {
+	type alias OffsetForLeaderEpoch
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(o), errString(o.Err)})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m MirroredTopic) MarshalJSON() ([]byte, error) {
+	type alias MirroredTopic
+	return json.Marshal(struct {
+		alias
+		Err string `json:",omitempty"`
+	}{alias(m), errString(m.Err)})
+}
@@ -72,6 +72,7 @@ import (
 	"runtime/debug"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/twmb/franz-go/pkg/kgo"
 )
@@ -111,11 +112,22 @@ type Client struct {
 	cl *kgo.Client
 
 	timeoutMillis int32
+	retry         RetryPolicy
+
+	// Per-call overrides, see WithOpts.
+	reqTimeout   time.Duration
+	broker       int32
+	toBroker     bool
+	validateOnly bool
+	authOps      bool
+
+	offsetsChunkSize   int
+	offsetsConcurrency int
 }
 
 // NewClient returns an admin client.
 func NewClient(cl *kgo.Client) *Client {
-	return &Client{cl, 15000} // 15s timeout default, matching kmsg
+	return &Client{cl: cl, timeoutMillis: 15000} // 15s timeout default, matching kmsg
 }
 
 // NewOptClient returns a new client directly from kgo options. This is a
@@ -133,6 +145,134 @@ func (cl *Client) Close() {
 	cl.cl.Close()
 }
 
+// RequestOpt is an option that overrides how requests are issued from a
+// client returned by WithOpts.
+type RequestOpt interface {
+	apply(*Client)
+}
+
+type requestOpt struct{ fn func(*Client) }
+
+func (opt requestOpt) apply(cl *Client) { opt.fn(cl) }
+
+// Timeout bounds how long each request can take, including any retries from
+// the client's retry policy. Methods that issue multiple requests (for
+// example, ListEndOffsets issues a metadata request and then a list offsets
+// request) apply the timeout to each request individually. This also sets the
+// timeout for requests that have a timeout field (see SetTimeoutMillis).
+func Timeout(timeout time.Duration) RequestOpt {
+	return requestOpt{func(cl *Client) {
+		cl.reqTimeout = timeout
+		cl.timeoutMillis = int32(timeout.Milliseconds())
+	}}
+}
+
+// Broker issues every request directly to the given broker, rather than
+// letting the client choose (or split the request across) the appropriate
+// brokers. This is useful for debugging a single broker's view of the
+// cluster, but be aware that many requests must be sent to a specific broker:
+// for example, listing offsets must go to the partition leader, and group
+// requests must go to the group coordinator. Sending a request to the wrong
+// broker will result in errors such as NOT_LEADER_OR_FOLLOWER or
+// NOT_COORDINATOR.
+//
+// Methods that already take a broker to issue a request to are unaffected.
+func Broker(broker int32) RequestOpt {
+	return requestOpt{func(cl *Client) {
+		cl.broker = broker
+		cl.toBroker = true
+	}}
+}
+
+// ValidateOnly sets the ValidateOnly field for any request that supports it,
+// meaning the broker validates the request but does not apply it. The
+// following methods support validation:
+//
+//	CreateTopics, CreateTopicsWithAssignments
+//	CreatePartitions, UpdatePartitions
+//	AlterTopicConfigs, AlterBrokerConfigs
+//	AlterTopicConfigsState, AlterBrokerConfigsState
+//	AlterClientQuotas
+//
+// This is equivalent to using the corresponding Validate method. Any other
+// method that would modify the cluster (deleting topics, altering partition
+// assignments, ...) fails with ErrValidateOnlyUnsupported without issuing a
+// request, rather than silently applying the change. Methods that only read
+// from the cluster are unaffected.
+func ValidateOnly() RequestOpt {
+	return requestOpt{func(cl *Client) { cl.validateOnly = true }}
+}
+
+// ErrValidateOnlyUnsupported is returned when using the ValidateOnly option
+// with a method that would modify the cluster but cannot only validate.
+var ErrValidateOnlyUnsupported = errors.New("request does not support validate only")
+
+// IncludeAuthorizedOperations requests the operations the client is authorized
+// to perform on every described resource, for any request that supports it.
+// The following methods support authorized operations:
+//
+//	Metadata, ListTopics (per topic, Kafka 2.3+)
+//	DescribeCluster (for the cluster, Kafka 2.8+)
+//
+// The operations are returned in the AuthorizedOperations field of the
+// method's result; if this option is not used, or if the broker does not
+// support returning authorized operations, the field is nil.
+func IncludeAuthorizedOperations() RequestOpt {
+	return requestOpt{func(cl *Client) { cl.authOps = true }}
+}
+
+const (
+	defaultListOffsetsChunk       = 5000
+	defaultListOffsetsConcurrency = 4
+)
+
+// ListOffsetsChunking sets how many partitions are listed per list offsets
+// request, and how many of those requests can be in flight at once, for any
+// of the List.*Offsets methods. Listing offsets for very large clusters in one
+// request can exceed response size limits and request timeouts; chunking
+// keeps each request bounded. Each chunk may still be split further across
+// partition leaders. By default, chunks are 5000 partitions and four chunks
+// are listed concurrently. Non-positive values use the defaults.
+func ListOffsetsChunking(partitions, concurrency int) RequestOpt {
+	return requestOpt{func(cl *Client) {
+		cl.offsetsChunkSize = partitions
+		cl.offsetsConcurrency = concurrency
+	}}
+}
+
+// WithOpts returns a shallow copy of this client that uses the given options
+// for every request. The returned client shares the underlying *kgo.Client,
+// meaning a single admin client can serve many callers with different
+// requirements:
+//
+//	m, err := adm.WithOpts(kadm.Timeout(5*time.Second)).Metadata(ctx)
+//
+// Closing the returned client closes the underlying *kgo.Client.
+func (cl *Client) WithOpts(opts ...RequestOpt) *Client {
+	c := *cl
+	for _, opt := range opts {
+		opt.apply(&c)
+	}
+	return &c
+}
+
+// ForBroker returns a shallow copy of this client that issues every request
+// directly to the given broker. This is a shortcut for
+// WithOpts(Broker(broker)); see the Broker option for more details.
+//
+// This is most useful for inspecting a single broker's view of the cluster,
+// which can differ from other brokers' views while metadata propagates or if
+// a broker is partitioned:
+//
+//	m, err := adm.ForBroker(3).Metadata(ctx)
+//
+// Requests that are already inherently broker-specific, such as
+// DescribeBrokerConfigs for broker 3 or DescribeBrokerLogDirs, do not need
+// this: the client already routes them to the correct broker.
+func (cl *Client) ForBroker(broker int32) *Client {
+	return cl.WithOpts(Broker(broker))
+}
+
 // SetTimeoutMillis sets the timeout to use for requests that have a timeout,
 // overriding the default of 15,000 (15s).
 //
@@ -141,7 +281,7 @@ func (cl *Client) Close() {
 // have timeout fields:
 //
 //	Produce
-//	CreateTopics
+//	CreateTopics, CreateTopicsWithAssignments
 //	DeleteTopics
 //	DeleteRecords
 //	CreatePartitions
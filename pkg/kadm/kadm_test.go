@@ -1,9 +1,23 @@
 package kadm
 
 import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
+	"io"
+	"math"
+	"net"
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kbin"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
 )
 
 func input[V any](v V) V { return v }
@@ -86,3 +100,1418 @@ func TestAny(t *testing.T) {
 		}
 	}
 }
+
+func TestCalculateReassignmentPlan(t *testing.T) {
+	rack := func(s string) *string { return &s }
+	m := Metadata{
+		Brokers: BrokerDetails{
+			{NodeID: 0, Rack: rack("a")},
+			{NodeID: 1, Rack: rack("a")},
+			{NodeID: 2, Rack: rack("b")},
+			{NodeID: 3, Rack: rack("b")},
+			{NodeID: 4, Rack: rack("c")},
+			{NodeID: 5, Rack: rack("c")},
+		},
+		Topics: make(TopicDetails),
+	}
+	td := TopicDetail{Topic: "foo", Partitions: make(PartitionDetails)}
+	for p := int32(0); p < 12; p++ {
+		td.Partitions[p] = PartitionDetail{Topic: "foo", Partition: p, Replicas: []int32{0, 1, 2}}
+	}
+	m.Topics["foo"] = td
+
+	plan, err := CalculateReassignmentPlan(m)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	racks := map[int32]string{0: "a", 1: "a", 2: "b", 3: "b", 4: "c", 5: "c"}
+	leaders := make(map[int32]int)
+	replicas := make(map[int32]int)
+	plan.Each(func(r PartitionReassignment) {
+		if len(r.Proposed) != 3 {
+			t.Errorf("p%d: got %d replicas, exp 3", r.Partition, len(r.Proposed))
+		}
+		seen := make(map[string]bool)
+		for _, b := range r.Proposed {
+			if seen[racks[b]] {
+				t.Errorf("p%d: rack %s used twice in %v", r.Partition, racks[b], r.Proposed)
+			}
+			seen[racks[b]] = true
+			replicas[b]++
+		}
+		leaders[r.Proposed[0]]++
+	})
+	for b := int32(0); b < 6; b++ {
+		if leaders[b] != 2 {
+			t.Errorf("broker %d: got %d leaders, exp 2", b, leaders[b])
+		}
+		if replicas[b] != 6 {
+			t.Errorf("broker %d: got %d replicas, exp 6", b, replicas[b])
+		}
+	}
+
+	again, _ := CalculateReassignmentPlan(m)
+	if !reflect.DeepEqual(plan, again) {
+		t.Error("plan is not deterministic")
+	}
+
+	m.Brokers[0].Rack = nil
+	if _, err := CalculateReassignmentPlan(m); err != ErrMixedRacks {
+		t.Errorf("got err %v, exp ErrMixedRacks", err)
+	}
+}
+
+func TestDrainPlan(t *testing.T) {
+	rack := func(s string) *string { return &s }
+	metadata := func(brokers BrokerDetails, replicas ...[]int32) Metadata {
+		td := TopicDetail{Topic: "foo", Partitions: make(PartitionDetails)}
+		for p, rs := range replicas {
+			td.Partitions[int32(p)] = PartitionDetail{Topic: "foo", Partition: int32(p), Replicas: rs}
+		}
+		return Metadata{Brokers: brokers, Topics: TopicDetails{"foo": td}}
+	}
+
+	for _, test := range []struct {
+		name   string
+		m      Metadata
+		node   int32
+		exp    map[int32][]int32 // proposed replicas per moved partition
+		expErr bool
+	}{
+		{
+			name: "replicas are replaced in place with the least loaded broker",
+			m: metadata(
+				BrokerDetails{{NodeID: 0}, {NodeID: 1}, {NodeID: 2}, {NodeID: 3}},
+				[]int32{1, 0},
+				[]int32{0, 2},
+				[]int32{2, 1},
+			),
+			node: 1,
+			exp: map[int32][]int32{
+				0: {3, 0},
+				2: {2, 3},
+			},
+		},
+		{
+			name: "same rack is preferred over load",
+			m: metadata(
+				BrokerDetails{
+					{NodeID: 0, Rack: rack("a")},
+					{NodeID: 1, Rack: rack("a")},
+					{NodeID: 2, Rack: rack("b")},
+					{NodeID: 3, Rack: rack("b")},
+				},
+				[]int32{0, 2},
+				[]int32{1, 2},
+				[]int32{1, 3},
+			),
+			node: 0,
+			exp: map[int32][]int32{
+				0: {1, 2},
+			},
+		},
+		{
+			name: "other racks are used when the rack has no free broker",
+			m: metadata(
+				BrokerDetails{
+					{NodeID: 0, Rack: rack("a")},
+					{NodeID: 1, Rack: rack("a")},
+					{NodeID: 2, Rack: rack("b")},
+					{NodeID: 3, Rack: rack("b")},
+				},
+				[]int32{0, 1},
+				[]int32{2, 3},
+			),
+			node: 0,
+			exp: map[int32][]int32{
+				0: {2, 1},
+			},
+		},
+		{
+			name: "broker without replicas has an empty plan",
+			m: metadata(
+				BrokerDetails{{NodeID: 0}, {NodeID: 1}, {NodeID: 2}},
+				[]int32{0, 1},
+			),
+			node: 2,
+			exp:  map[int32][]int32{},
+		},
+		{
+			name: "not enough brokers to keep the replication factor",
+			m: metadata(
+				BrokerDetails{{NodeID: 0}, {NodeID: 1}, {NodeID: 2}},
+				[]int32{0, 1},
+				[]int32{0, 1, 2},
+			),
+			node:   0,
+			expErr: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			plan, err := drainPlan(test.m, test.node)
+			if gotErr := err != nil; gotErr != test.expErr {
+				t.Fatalf("got err %v, exp err? %v", err, test.expErr)
+			}
+			if test.expErr {
+				return
+			}
+			got := make(map[int32][]int32)
+			plan.Each(func(r PartitionReassignment) {
+				if !reflect.DeepEqual(r.Current, test.m.Topics["foo"].Partitions[r.Partition].Replicas) {
+					t.Errorf("p%d: got current %v, exp the partition's replicas", r.Partition, r.Current)
+				}
+				got[r.Partition] = r.Proposed
+			})
+			if !reflect.DeepEqual(got, test.exp) {
+				t.Errorf("got proposed %v, exp %v", got, test.exp)
+			}
+		})
+	}
+}
+
+func TestMonitorReassignments(t *testing.T) {
+	sizes := map[int32]int64{0: 100, 1: 300}
+	var (
+		mu    sync.Mutex
+		polls int
+	)
+	handle := func(req kmsg.Request) kmsg.Response {
+		switch req := req.(type) {
+		case *kmsg.ListPartitionReassignmentsRequest:
+			mu.Lock()
+			polls++
+			poll := polls
+			mu.Unlock()
+
+			// Partition 0 finishes after the first poll, partition 1
+			// after the second.
+			resp := req.ResponseKind().(*kmsg.ListPartitionReassignmentsResponse)
+			rt := kmsg.NewListPartitionReassignmentsResponseTopic()
+			rt.Topic = "foo"
+			for p := int32(0); p < 2; p++ {
+				if int(p) < poll-1 {
+					continue
+				}
+				rp := kmsg.NewListPartitionReassignmentsResponseTopicPartition()
+				rp.Partition = p
+				rp.Replicas = []int32{0, 1}
+				rp.AddingReplicas = []int32{1}
+				rt.Partitions = append(rt.Partitions, rp)
+			}
+			if len(rt.Partitions) > 0 {
+				resp.Topics = append(resp.Topics, rt)
+			}
+			return resp
+
+		case *kmsg.DescribeLogDirsRequest:
+			resp := req.ResponseKind().(*kmsg.DescribeLogDirsResponse)
+			dir := kmsg.NewDescribeLogDirsResponseDir()
+			dir.Dir = "/data"
+			for _, t := range req.Topics {
+				dt := kmsg.NewDescribeLogDirsResponseDirTopic()
+				dt.Topic = t.Topic
+				for _, p := range t.Partitions {
+					dp := kmsg.NewDescribeLogDirsResponseDirTopicPartition()
+					dp.Partition = p
+					dp.Size = sizes[p]
+					dt.Partitions = append(dt.Partitions, dp)
+				}
+				dir.Topics = append(dir.Topics, dt)
+			}
+			resp.Dirs = append(resp.Dirs, dir)
+			return resp
+		}
+		return nil
+	}
+	f, adm := newFakeBroker(t, handle)
+	f.addTopic("foo", 2)
+
+	var progress []ReassignmentProgress
+	b := MonitorReassignments(TopicsSet{"foo": {0: {}, 1: {}}}).
+		PollInterval(time.Millisecond).
+		StallAfter(time.Nanosecond)
+	if err := adm.MonitorReassignments(context.Background(), b, func(p ReassignmentProgress) {
+		progress = append(progress, p)
+	}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	if len(progress) != 3 {
+		t.Fatalf("got %d progress updates, exp 3", len(progress))
+	}
+	for i, exp := range []struct {
+		remaining int
+		copied    int64
+		percent   float64
+		stalled   int
+		done      bool
+	}{
+		{2, 0, 0, 0, false},
+		{1, 100, 25, 1, false}, // partition 0 completed, partition 1 copied nothing since the first poll
+		{0, 400, 100, 0, true},
+	} {
+		p := progress[i]
+		if p.Total != 2 || p.Bytes != 400 {
+			t.Errorf("poll %d: got total %d bytes %d, exp 2 and 400", i, p.Total, p.Bytes)
+		}
+		if len(p.Remaining) != exp.remaining || p.CopiedBytes != exp.copied || p.Percent() != exp.percent {
+			t.Errorf("poll %d: got remaining %d copied %d percent %v, exp %d %d %v", i, len(p.Remaining), p.CopiedBytes, p.Percent(), exp.remaining, exp.copied, exp.percent)
+		}
+		if len(p.Stalled()) != exp.stalled || p.Done() != exp.done {
+			t.Errorf("poll %d: got %d stalled done %v, exp %d stalled done %v", i, len(p.Stalled()), p.Done(), exp.stalled, exp.done)
+		}
+	}
+	if rem := progress[1].Remaining; len(rem) == 1 && rem[0].Partition != 1 {
+		t.Errorf("got remaining partition %d, exp 1", rem[0].Partition)
+	}
+}
+
+func TestCalculateHealth(t *testing.T) {
+	brokers := BrokerDetails{{NodeID: 0}, {NodeID: 1}, {NodeID: 2}}
+	metadata := func(controller int32, ps ...PartitionDetail) Metadata {
+		td := TopicDetail{Topic: "foo", Partitions: make(PartitionDetails)}
+		for i, p := range ps {
+			p.Topic, p.Partition = "foo", int32(i)
+			td.Partitions[p.Partition] = p
+		}
+		return Metadata{Controller: controller, Brokers: brokers, Topics: TopicDetails{"foo": td}}
+	}
+	parts := func(ps []PartitionDetail) []int32 {
+		var is []int32
+		for _, p := range ps {
+			is = append(is, p.Partition)
+		}
+		return is
+	}
+
+	for _, test := range []struct {
+		name string
+		m    Metadata
+
+		controller      int32
+		underReplicated []int32
+		leaderless      []int32
+		offline         []int32
+		outOfISR        map[int32][]Partition
+		healthy         bool
+	}{
+		{
+			name: "healthy",
+			m: metadata(0,
+				PartitionDetail{Leader: 0, Replicas: []int32{0, 1}, ISR: []int32{0, 1}},
+				PartitionDetail{Leader: 1, Replicas: []int32{1, 2}, ISR: []int32{1, 2}},
+			),
+			controller: 0,
+			outOfISR:   map[int32][]Partition{},
+			healthy:    true,
+		},
+		{
+			name: "under replicated",
+			m: metadata(0,
+				PartitionDetail{Leader: 0, Replicas: []int32{0, 1}, ISR: []int32{0, 1}},
+				PartitionDetail{Leader: 1, Replicas: []int32{1, 2}, ISR: []int32{1}},
+			),
+			controller:      0,
+			underReplicated: []int32{1},
+			outOfISR:        map[int32][]Partition{2: {{"foo", 1}}},
+		},
+		{
+			name: "offline replicas and replicas on brokers not in the cluster",
+			m: metadata(0,
+				PartitionDetail{Leader: -1, Replicas: []int32{1, 2}, OfflineReplicas: []int32{1, 2}},
+				PartitionDetail{Leader: -1, Replicas: []int32{3, 4}},
+				PartitionDetail{Leader: 0, Replicas: []int32{0, 1}, ISR: []int32{0}, OfflineReplicas: []int32{1}},
+			),
+			controller:      0,
+			underReplicated: []int32{0, 1, 2},
+			leaderless:      []int32{0, 1},
+			offline:         []int32{0, 1},
+			outOfISR: map[int32][]Partition{
+				1: {{"foo", 0}, {"foo", 2}},
+				2: {{"foo", 0}},
+				3: {{"foo", 1}},
+				4: {{"foo", 1}},
+			},
+		},
+		{
+			name: "controller absent",
+			m: metadata(-1,
+				PartitionDetail{Leader: 0, Replicas: []int32{0}, ISR: []int32{0}},
+			),
+			controller: -1,
+			outOfISR:   map[int32][]Partition{},
+		},
+		{
+			name: "controller not in the cluster",
+			m: metadata(5,
+				PartitionDetail{Leader: 0, Replicas: []int32{0}, ISR: []int32{0}},
+			),
+			controller: -1,
+			outOfISR:   map[int32][]Partition{},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			h := CalculateHealth(test.m, nil)
+			if h.Controller != test.controller {
+				t.Errorf("got controller %d, exp %d", h.Controller, test.controller)
+			}
+			if got := parts(h.UnderReplicated); !reflect.DeepEqual(got, test.underReplicated) {
+				t.Errorf("got under replicated %v, exp %v", got, test.underReplicated)
+			}
+			if got := parts(h.Leaderless); !reflect.DeepEqual(got, test.leaderless) {
+				t.Errorf("got leaderless %v, exp %v", got, test.leaderless)
+			}
+			if got := parts(h.Offline); !reflect.DeepEqual(got, test.offline) {
+				t.Errorf("got offline %v, exp %v", got, test.offline)
+			}
+			if !reflect.DeepEqual(h.OutOfISR, test.outOfISR) {
+				t.Errorf("got out of ISR %v, exp %v", h.OutOfISR, test.outOfISR)
+			}
+			if h.Healthy() != test.healthy {
+				t.Errorf("got healthy %v, exp %v", h.Healthy(), test.healthy)
+			}
+		})
+	}
+
+	// Log directories that failed to describe are offline.
+	dirs := DescribedAllLogDirs{
+		0: {"/a": {Broker: 0, Dir: "/a"}},
+		1: {"/b": {Broker: 1, Dir: "/b", Err: kerr.KafkaStorageError}},
+	}
+	h := CalculateHealth(metadata(0), dirs)
+	if len(h.OfflineLogDirs) != 1 || len(h.OfflineLogDirs[1]) != 1 || h.OfflineLogDirs[1][0].Dir != "/b" {
+		t.Errorf("got offline log dirs %v, exp only /b on broker 1", h.OfflineLogDirs)
+	}
+	if h.Healthy() {
+		t.Error("got healthy with an offline log dir")
+	}
+}
+
+func TestMirrorTopics(t *testing.T) {
+	type config struct {
+		value     string
+		source    kmsg.ConfigSource
+		sensitive bool
+	}
+	describeConfigs := func(configs map[string]map[string]config) func(kmsg.Request) kmsg.Response {
+		return func(req kmsg.Request) kmsg.Response {
+			kreq, ok := req.(*kmsg.DescribeConfigsRequest)
+			if !ok {
+				return nil
+			}
+			resp := kreq.ResponseKind().(*kmsg.DescribeConfigsResponse)
+			for _, r := range kreq.Resources {
+				rr := kmsg.NewDescribeConfigsResponseResource()
+				rr.ResourceType = r.ResourceType
+				rr.ResourceName = r.ResourceName
+				for k, c := range configs[r.ResourceName] {
+					rc := kmsg.NewDescribeConfigsResponseResourceConfig()
+					rc.Name = k
+					rc.Value = kmsg.StringPtr(c.value)
+					rc.Source = c.source
+					rc.IsSensitive = c.sensitive
+					rr.Configs = append(rr.Configs, rc)
+				}
+				resp.Resources = append(resp.Resources, rr)
+			}
+			return resp
+		}
+	}
+
+	srcf, src := newFakeBroker(t, describeConfigs(map[string]map[string]config{
+		"created": {
+			"retention.ms":                          {"1000", kmsg.ConfigSourceDynamicTopicConfig, false},
+			"segment.bytes":                         {"1024", kmsg.ConfigSourceDefaultConfig, false},
+			"sasl.jaas.config":                      {"secret", kmsg.ConfigSourceDynamicTopicConfig, true},
+			"leader.replication.throttled.replicas": {"0:0", kmsg.ConfigSourceDynamicTopicConfig, false},
+		},
+		"existing": {
+			"retention.ms": {"2000", kmsg.ConfigSourceDynamicTopicConfig, false},
+		},
+	}))
+	srcf.addTopic("created", 3)
+	srcf.addTopic("existing", 4)
+
+	for _, validate := range []bool{false, true} {
+		var (
+			mu       sync.Mutex
+			received []kmsg.Request
+		)
+		dstConfigs := describeConfigs(map[string]map[string]config{
+			"existing": {
+				"retention.ms":   {"1", kmsg.ConfigSourceDynamicTopicConfig, false},
+				"cleanup.policy": {"compact", kmsg.ConfigSourceDynamicTopicConfig, false},
+			},
+		})
+		dstf, dst := newFakeBroker(t, func(req kmsg.Request) kmsg.Response {
+			switch req := req.(type) {
+			case *kmsg.CreateTopicsRequest:
+				mu.Lock()
+				received = append(received, req)
+				mu.Unlock()
+				resp := req.ResponseKind().(*kmsg.CreateTopicsResponse)
+				for _, rt := range req.Topics {
+					t := kmsg.NewCreateTopicsResponseTopic()
+					t.Topic = rt.Topic
+					resp.Topics = append(resp.Topics, t)
+				}
+				return resp
+			case *kmsg.CreatePartitionsRequest:
+				mu.Lock()
+				received = append(received, req)
+				mu.Unlock()
+				resp := req.ResponseKind().(*kmsg.CreatePartitionsResponse)
+				for _, rt := range req.Topics {
+					t := kmsg.NewCreatePartitionsResponseTopic()
+					t.Topic = rt.Topic
+					resp.Topics = append(resp.Topics, t)
+				}
+				return resp
+			case *kmsg.IncrementalAlterConfigsRequest:
+				mu.Lock()
+				received = append(received, req)
+				mu.Unlock()
+				resp := req.ResponseKind().(*kmsg.IncrementalAlterConfigsResponse)
+				for _, rr := range req.Resources {
+					r := kmsg.NewIncrementalAlterConfigsResponseResource()
+					r.ResourceType = rr.ResourceType
+					r.ResourceName = rr.ResourceName
+					resp.Resources = append(resp.Resources, r)
+				}
+				return resp
+			}
+			return dstConfigs(req)
+		})
+		dstf.addTopic("existing", 2)
+		if validate {
+			dst = dst.WithOpts(ValidateOnly())
+		}
+
+		ms, err := MirrorTopics(context.Background(), src, dst)
+		if err != nil {
+			t.Fatalf("validate %v: unexpected err: %v", validate, err)
+		}
+		if err := ms.Error(); err != nil {
+			t.Fatalf("validate %v: unexpected topic err: %v", validate, err)
+		}
+
+		if m := ms["created"]; !m.Created || m.SourcePartitions != 3 || m.DestPartitions != 0 || m.ValidateOnly != validate ||
+			!reflect.DeepEqual(m.ConfigsSet, map[string]string{"retention.ms": "1000"}) || len(m.ConfigsDeleted) != 0 {
+			t.Errorf("validate %v: got created result %+v", validate, m)
+		}
+		if m := ms["existing"]; m.Created || m.SourcePartitions != 4 || m.DestPartitions != 2 || m.ValidateOnly != validate ||
+			!reflect.DeepEqual(m.ConfigsSet, map[string]string{"retention.ms": "2000"}) || !reflect.DeepEqual(m.ConfigsDeleted, []string{"cleanup.policy"}) {
+			t.Errorf("validate %v: got existing result %+v", validate, m)
+		}
+
+		mu.Lock()
+		var creates, partitions, alters int
+		for _, req := range received {
+			switch req := req.(type) {
+			case *kmsg.CreateTopicsRequest:
+				creates++
+				rt := req.Topics[0]
+				if req.ValidateOnly != validate || rt.Topic != "created" || rt.NumPartitions != 3 || rt.ReplicationFactor != 1 ||
+					len(rt.Configs) != 1 || rt.Configs[0].Name != "retention.ms" || unptrStr(rt.Configs[0].Value) != "1000" {
+					t.Errorf("validate %v: got create topics %+v", validate, req)
+				}
+			case *kmsg.CreatePartitionsRequest:
+				partitions++
+				rt := req.Topics[0]
+				if req.ValidateOnly != validate || rt.Topic != "existing" || rt.Count != 4 {
+					t.Errorf("validate %v: got create partitions %+v", validate, req)
+				}
+			case *kmsg.IncrementalAlterConfigsRequest:
+				alters++
+				got := make(map[string]kmsg.IncrementalAlterConfigOp)
+				for _, c := range req.Resources[0].Configs {
+					got[c.Name] = c.Op
+				}
+				exp := map[string]kmsg.IncrementalAlterConfigOp{
+					"retention.ms":   kmsg.IncrementalAlterConfigOpSet,
+					"cleanup.policy": kmsg.IncrementalAlterConfigOpDelete,
+				}
+				if req.ValidateOnly != validate || req.Resources[0].ResourceName != "existing" || !reflect.DeepEqual(got, exp) {
+					t.Errorf("validate %v: got alter configs %+v", validate, req)
+				}
+			}
+		}
+		if creates != 1 || partitions != 1 || alters != 1 {
+			t.Errorf("validate %v: got %d creates, %d create partitions, %d alters, exp 1 each", validate, creates, partitions, alters)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestPartial(t *testing.T) {
+	se := &ShardErrors{
+		Name: "ListOffsets",
+		Errs: []ShardError{{
+			Req: &kmsg.ListOffsetsRequest{Topics: []kmsg.ListOffsetsRequestTopic{{
+				Topic:      "foo",
+				Partitions: []kmsg.ListOffsetsRequestTopicPartition{{Partition: 1}},
+			}}},
+			Err:    errors.New("failed"),
+			Broker: BrokerDetail{NodeID: 2},
+		}},
+		Succeeded: []BrokerDetail{{NodeID: 1}},
+	}
+
+	res, err := Partial(3, se)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if res.Complete() || res.Result != 3 {
+		t.Errorf("got complete? %v, result %d; exp incomplete, 3", res.Complete(), res.Result)
+	}
+	if got, exp := res.Failed.FailedTopics(), (TopicsSet{"foo": {1: {}}}); !reflect.DeepEqual(got, exp) {
+		t.Errorf("got failed topics %v != exp %v", got, exp)
+	}
+	if got := res.Failed.FailedBrokers(); len(got) != 1 || got[0].NodeID != 2 {
+		t.Errorf("got failed brokers %v, exp only broker 2", got)
+	}
+
+	se.AllFailed = true
+	if _, err := Partial(3, se); err == nil {
+		t.Error("got nil err when all shards failed")
+	}
+	if res, err := Partial(3, nil); err != nil || !res.Complete() {
+		t.Errorf("got err %v, complete? %v; exp nil, complete", err, res.Complete())
+	}
+}
+
+func TestACLBuilderCreations(t *testing.T) {
+	b := NewACLs().
+		Topics("foo-", "bar-").
+		Groups("g").
+		Prefixed().
+		Allow("User:a", "User:b").
+		Operations(OpRead, OpDescribe)
+
+	cs, err := b.Creations()
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if exp := 3 * 2 * 2; len(cs) != exp { // resources * principals * operations
+		t.Fatalf("got %d creations, exp %d", len(cs), exp)
+	}
+	for _, c := range cs {
+		if c.ResourcePatternType != ACLPatternPrefixed {
+			t.Errorf("got pattern %v, exp prefixed", c.ResourcePatternType)
+		}
+		if c.Host != "*" {
+			t.Errorf("got host %q, exp *", c.Host)
+		}
+		if c.PermissionType != kmsg.ACLPermissionTypeAllow {
+			t.Errorf("got permission %v, exp allow", c.PermissionType)
+		}
+	}
+
+	if _, err := NewACLs().Topics("t").Match().Allow("User:a").Operations(OpRead).Creations(); err == nil {
+		t.Error("expected error creating with match pattern")
+	}
+}
+
+func TestDecodeAuthorizedOperations(t *testing.T) {
+	if ops := DecodeAuthorizedOperations(math.MinInt32); ops != nil {
+		t.Errorf("got %v for unrequested operations, exp nil", ops)
+	}
+	got := DecodeAuthorizedOperations(1<<int(OpRead) | 1<<int(OpDescribe))
+	exp := []ACLOperation{OpRead, OpDescribe}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v != exp %v", got, exp)
+	}
+}
+
+func TestDiffMetadata(t *testing.T) {
+	old := Metadata{
+		Controller: 1,
+		Brokers:    BrokerDetails{{NodeID: 1, Host: "a"}, {NodeID: 2, Host: "b"}},
+		Topics: TopicDetails{
+			"foo": {Topic: "foo", Partitions: PartitionDetails{
+				0: {Topic: "foo", Partition: 0, Leader: 1, Replicas: []int32{1, 2}, ISR: []int32{1, 2}},
+			}},
+			"gone": {Topic: "gone", Partitions: PartitionDetails{}},
+		},
+	}
+	new := Metadata{
+		Controller: 2,
+		Brokers:    BrokerDetails{{NodeID: 2, Host: "c"}, {NodeID: 3, Host: "d"}},
+		Topics: TopicDetails{
+			"foo": {Topic: "foo", Partitions: PartitionDetails{
+				0: {Topic: "foo", Partition: 0, Leader: 2, Replicas: []int32{1, 2}, ISR: []int32{2}},
+				1: {Topic: "foo", Partition: 1, Leader: 3, Replicas: []int32{3}, ISR: []int32{3}},
+			}},
+			"bar": {Topic: "bar", Partitions: PartitionDetails{}},
+		},
+	}
+
+	d := DiffMetadata(old, new)
+	if !d.ControllerChanged {
+		t.Error("expected controller change")
+	}
+	if len(d.BrokersAdded) != 1 || d.BrokersAdded[0].NodeID != 3 {
+		t.Errorf("got brokers added %v, exp [3]", d.BrokersAdded)
+	}
+	if len(d.BrokersRemoved) != 1 || d.BrokersRemoved[0].NodeID != 1 {
+		t.Errorf("got brokers removed %v, exp [1]", d.BrokersRemoved)
+	}
+	if len(d.BrokersChanged) != 1 || d.BrokersChanged[0].New.Host != "c" {
+		t.Errorf("got brokers changed %v, exp [2]", d.BrokersChanged)
+	}
+	if len(d.TopicsAdded) != 1 || d.TopicsAdded[0].Topic != "bar" {
+		t.Errorf("got topics added %v, exp [bar]", d.TopicsAdded)
+	}
+	if len(d.TopicsRemoved) != 1 || d.TopicsRemoved[0].Topic != "gone" {
+		t.Errorf("got topics removed %v, exp [gone]", d.TopicsRemoved)
+	}
+	if len(d.PartitionsAdded) != 1 || d.PartitionsAdded[0].Partition != 1 {
+		t.Errorf("got partitions added %v, exp [foo 1]", d.PartitionsAdded)
+	}
+	if len(d.LeaderChanges) != 1 || d.LeaderChanges[0].New.Leader != 2 {
+		t.Errorf("got leader changes %v, exp [foo 0]", d.LeaderChanges)
+	}
+	if len(d.ISRShrinks) != 1 || len(d.ISRExpands) != 0 || len(d.ReplicaChanges) != 0 {
+		t.Errorf("got isr shrinks %v, expands %v, replica changes %v, exp only 1 shrink", d.ISRShrinks, d.ISRExpands, d.ReplicaChanges)
+	}
+
+	if !DiffMetadata(new, new).Empty() {
+		t.Error("expected no changes diffing metadata against itself")
+	}
+}
+
+func TestCalculateTopicSizes(t *testing.T) {
+	m := Metadata{Topics: TopicDetails{
+		"foo": {Topic: "foo", Partitions: PartitionDetails{
+			0: {Topic: "foo", Partition: 0, Leader: 1, Replicas: []int32{1, 2}},
+			1: {Topic: "foo", Partition: 1, Leader: 3, Replicas: []int32{2, 3}},
+		}},
+	}}
+	dir := func(b int32, ps ...DescribedLogDirPartition) DescribedLogDirs {
+		d := DescribedLogDir{Broker: b, Dir: "/d", Topics: make(DescribedLogDirTopics)}
+		for _, p := range ps {
+			p.Broker, p.Dir, p.Topic = b, "/d", "foo"
+			if d.Topics["foo"] == nil {
+				d.Topics["foo"] = make(map[int32]DescribedLogDirPartition)
+			}
+			d.Topics["foo"][p.Partition] = p
+		}
+		return DescribedLogDirs{"/d": d}
+	}
+	dirs := DescribedAllLogDirs{
+		1: dir(1, DescribedLogDirPartition{Partition: 0, Size: 100}),
+		2: dir(2, DescribedLogDirPartition{Partition: 0, Size: 90}, DescribedLogDirPartition{Partition: 1, Size: 50}),
+		// broker 3, the leader of partition 1, was not described
+	}
+
+	ss := CalculateTopicSizes(m, dirs)
+	foo, ok := ss.Lookup("foo")
+	if !ok {
+		t.Fatal("missing topic foo")
+	}
+	if foo.ReplicatedBytes != 240 || foo.LeaderBytes != 150 {
+		t.Errorf("got replicated %d, leader %d, exp 240, 150", foo.ReplicatedBytes, foo.LeaderBytes)
+	}
+	if p := foo.Partitions[0]; p.Replicas != 2 || p.LeaderBytes != 100 {
+		t.Errorf("p0: got %d replicas, %d leader bytes, exp 2, 100", p.Replicas, p.LeaderBytes)
+	}
+	if p := foo.Partitions[1]; p.Replicas != 1 || p.LeaderBytes != 50 {
+		t.Errorf("p1: got %d replicas, %d leader bytes, exp 1, 50", p.Replicas, p.LeaderBytes)
+	}
+}
+
+func TestAlterUserSCRAMsValidation(t *testing.T) {
+	rs, err := new(Client).AlterUserSCRAMs(context.Background(),
+		[]DeleteSCRAM{{User: "dup", Mechanism: ScramSha256}},
+		[]UpsertSCRAM{
+			{User: "dup", Mechanism: ScramSha256, Password: "p", Iterations: 4096},
+			{User: "nopass", Mechanism: ScramSha512},
+			{User: "badmech", Mechanism: 3, Password: "p"},
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	for _, user := range []string{"dup", "nopass", "badmech"} {
+		r, ok := rs[user]
+		if !ok || r.Err == nil {
+			t.Errorf("%s: expected per-user error, got %v", user, r)
+			continue
+		}
+		for _, m := range r.Mechanisms {
+			if m.Err != r.Err {
+				t.Errorf("%s: mechanism %s err %v != user err %v", user, m.Mechanism, m.Err, r.Err)
+			}
+		}
+	}
+	if ms := rs["dup"].Mechanisms; len(ms) != 2 || !ms[0].Deleted || ms[1].Deleted {
+		t.Errorf("dup: got mechanisms %v, exp delete then upsert", ms)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	m := Metadata{Topics: TopicDetails{
+		"foo": {Topic: "foo", ID: TopicID{1}, Partitions: PartitionDetails{
+			0: {Topic: "foo", Partition: 0, Err: kerr.LeaderNotAvailable},
+		}},
+	}}
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	var got struct {
+		Topics map[string]struct {
+			ID         string
+			Partitions map[string]struct{ Err string }
+			Err        *string
+		}
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unable to unmarshal %s: %v", b, err)
+	}
+	foo := got.Topics["foo"]
+	if foo.ID != (TopicID{1}).String() {
+		t.Errorf("got ID %q, exp %q", foo.ID, TopicID{1})
+	}
+	if foo.Err != nil {
+		t.Errorf("got Err %q, exp omitted", *foo.Err)
+	}
+	if perr := foo.Partitions["0"].Err; perr != kerr.LeaderNotAvailable.Error() {
+		t.Errorf("got partition Err %q, exp %q", perr, kerr.LeaderNotAvailable)
+	}
+
+	if _, err := json.Marshal((TopicDetails{"foo": m.Topics["foo"]}).IDs()); err != nil {
+		t.Errorf("unable to marshal topic ID keys: %v", err)
+	}
+}
+
+type requestorFn func(context.Context, kmsg.Request) (kmsg.Response, error)
+
+func (fn requestorFn) Request(ctx context.Context, req kmsg.Request) (kmsg.Response, error) {
+	return fn(ctx, req)
+}
+
+func TestRetryResponseErrorCodes(t *testing.T) {
+	listOffsets := func(code int16) (kmsg.Request, func(kmsg.Request) kmsg.Response) {
+		return kmsg.NewPtrListOffsetsRequest(), func(req kmsg.Request) kmsg.Response {
+			resp := req.ResponseKind().(*kmsg.ListOffsetsResponse)
+			rt := kmsg.NewListOffsetsResponseTopic()
+			rp := kmsg.NewListOffsetsResponseTopicPartition()
+			rp.ErrorCode = code
+			rt.Partitions = append(rt.Partitions, rp)
+			resp.Topics = append(resp.Topics, rt)
+			return resp
+		}
+	}
+	createTopics := func(code int16) (kmsg.Request, func(kmsg.Request) kmsg.Response) {
+		return kmsg.NewPtrCreateTopicsRequest(), func(req kmsg.Request) kmsg.Response {
+			resp := req.ResponseKind().(*kmsg.CreateTopicsResponse)
+			rt := kmsg.NewCreateTopicsResponseTopic()
+			rt.ErrorCode = code
+			resp.Topics = append(resp.Topics, rt)
+			return resp
+		}
+	}
+	alterAssignments := func(code int16) (kmsg.Request, func(kmsg.Request) kmsg.Response) {
+		return kmsg.NewPtrAlterPartitionAssignmentsRequest(), func(req kmsg.Request) kmsg.Response {
+			resp := req.ResponseKind().(*kmsg.AlterPartitionAssignmentsResponse)
+			resp.ErrorCode = code
+			return resp
+		}
+	}
+
+	for _, test := range []struct {
+		name  string
+		req   func(int16) (kmsg.Request, func(kmsg.Request) kmsg.Response)
+		code  int16
+		tries int
+	}{
+		{"retriable partition error is retried", listOffsets, kerr.NotLeaderForPartition.Code, 3},
+		{"non-retriable partition error is not retried", listOffsets, kerr.TopicAuthorizationFailed.Code, 1},
+		{"no error", listOffsets, 0, 1},
+		{"retriable nested error in a write is not retried", createTopics, kerr.NotController.Code, 1},
+		{"retriable top level error in a write is retried", alterAssignments, kerr.NotController.Code, 3},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cl := NewClient(nil).WithOpts(Retry(RetryPolicy{
+				MaxRetries: 2,
+				Backoff:    func(int) time.Duration { return time.Millisecond },
+			}))
+
+			req, respFn := test.req(test.code)
+			var tries int
+			r := retrier{cl, requestorFn(func(_ context.Context, req kmsg.Request) (kmsg.Response, error) {
+				tries++
+				return respFn(req), nil
+			})}
+
+			if _, err := r.Request(context.Background(), req); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if tries != test.tries {
+				t.Errorf("got %d tries, exp %d", tries, test.tries)
+			}
+		})
+	}
+}
+
+func TestValidateOnlyUnsupported(t *testing.T) {
+	cl := NewClient(nil).WithOpts(ValidateOnly())
+
+	validating := kmsg.NewPtrCreateTopicsRequest()
+	validating.ValidateOnly = true
+
+	for _, test := range []struct {
+		name   string
+		req    kmsg.Request
+		issued bool
+	}{
+		{"validating write is issued", validating, true},
+		{"read is issued", kmsg.NewPtrMetadataRequest(), true},
+		{"write without validation support fails", kmsg.NewPtrDeleteTopicsRequest(), false},
+		{"write with validation unset fails", kmsg.NewPtrCreateTopicsRequest(), false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var issued bool
+			r := retrier{cl, requestorFn(func(_ context.Context, req kmsg.Request) (kmsg.Response, error) {
+				issued = true
+				return req.ResponseKind(), nil
+			})}
+			_, err := r.Request(context.Background(), test.req)
+			if issued != test.issued {
+				t.Errorf("got issued %v, exp %v", issued, test.issued)
+			}
+			if gotErr := errors.Is(err, ErrValidateOnlyUnsupported); gotErr == test.issued {
+				t.Errorf("got err %v, exp unsupported err? %v", err, !test.issued)
+			}
+		})
+	}
+
+	// Sharded requests and the methods using them fail before anything is
+	// sent; the client has no underlying *kgo.Client to send with.
+	var se *ShardErrors
+	if _, err := cl.DeleteGroups(context.Background(), "g"); !errors.As(err, &se) || !errors.Is(se.Errs[0].Err, ErrValidateOnlyUnsupported) {
+		t.Errorf("DeleteGroups: got err %v, exp unsupported err", err)
+	}
+	if _, err := cl.DeleteACLs(context.Background(), NewACLs().Topics("t").Allow("u").AllowHosts().Operations(OpRead)); !errors.Is(err, ErrValidateOnlyUnsupported) {
+		t.Errorf("DeleteACLs: got err %v, exp unsupported err", err)
+	}
+}
+
+func TestListGroupsFilter(t *testing.T) {
+	f := ListGroupsFilter{
+		States:        []string{"Stable"},
+		Types:         []string{"classic"},
+		ProtocolTypes: []string{"consumer"},
+	}
+	for _, test := range []struct {
+		g   ListedGroup
+		exp bool
+	}{
+		{ListedGroup{ProtocolType: "consumer"}, true},                                     // old broker: no state, no type
+		{ListedGroup{ProtocolType: "connect"}, false},                                     // protocol type is always filtered client side
+		{ListedGroup{ProtocolType: "consumer", State: "Empty"}, false},                    // 2.6+ broker that ignored our state filter
+		{ListedGroup{ProtocolType: "consumer", State: "Stable", Type: "classic"}, true},   // 3.8+ broker
+		{ListedGroup{ProtocolType: "consumer", State: "Stable", Type: "consumer"}, false}, // 3.8+ broker, KIP-848 group
+	} {
+		if got := f.keep(test.g); got != test.exp {
+			t.Errorf("keep(%+v) = %v, exp %v", test.g, got, test.exp)
+		}
+	}
+}
+
+func TestShardErrEachFnErr(t *testing.T) {
+	req := kmsg.NewPtrListGroupsRequest()
+	shards := []kgo.ResponseShard{
+		{Meta: kgo.BrokerMetadata{NodeID: 1}, Req: req, Resp: kmsg.NewPtrListGroupsResponse()},
+		{Meta: kgo.BrokerMetadata{NodeID: 2}, Req: req, Resp: kmsg.NewPtrListGroupsResponse()},
+		{Meta: kgo.BrokerMetadata{NodeID: 3}, Req: req, Err: errors.New("connection reset")},
+	}
+	fn := func(b BrokerDetail, _ kmsg.Response) error {
+		if b.NodeID == 2 {
+			return kerr.CoordinatorLoadInProgress
+		}
+		return nil
+	}
+
+	// Existing callers only see shard request errors; a broker whose
+	// response fn fails is neither failed nor succeeded.
+	var se *ShardErrors
+	err := shardErrEachBroker(req, shards, fn)
+	if !errors.As(err, &se) {
+		t.Fatalf("got err %v, exp *ShardErrors", err)
+	}
+	if len(se.Succeeded) != 1 || se.Succeeded[0].NodeID != 1 {
+		t.Errorf("got succeeded %v, exp only broker 1", se.Succeeded)
+	}
+	if len(se.Errs) != 1 || se.Errs[0].Broker.NodeID != 3 {
+		t.Errorf("got errs %v, exp only broker 3 failing", se.Errs)
+	}
+	if err := shardErrEachBroker(req, shards[:2], fn); err != nil {
+		t.Errorf("got err %v, exp nil when only fn fails", err)
+	}
+
+	se = nil
+	err = shardErrEachBrokerRecordFn(req, shards, fn)
+	if !errors.As(err, &se) {
+		t.Fatalf("got err %v, exp *ShardErrors", err)
+	}
+	if len(se.Succeeded) != 1 || se.Succeeded[0].NodeID != 1 {
+		t.Errorf("got succeeded %v, exp only broker 1", se.Succeeded)
+	}
+	if len(se.Errs) != 2 || se.Errs[0].Broker.NodeID != 2 || !errors.Is(se.Errs[0].Err, kerr.CoordinatorLoadInProgress) {
+		t.Errorf("got errs %v, exp broker 2 failing with COORDINATOR_LOAD_IN_PROGRESS and broker 3", se.Errs)
+	}
+	if se.AllFailed {
+		t.Error("got all failed, exp partial failure")
+	}
+
+	for _, each := range []func(kmsg.Request, []kgo.ResponseShard, func(BrokerDetail, kmsg.Response) error) error{
+		shardErrEachBroker,
+		shardErrEachBrokerRecordFn,
+	} {
+		err = each(req, shards, func(BrokerDetail, kmsg.Response) error {
+			return &AuthError{Err: kerr.GroupAuthorizationFailed}
+		})
+		var ae *AuthError
+		if !errors.As(err, &ae) {
+			t.Errorf("got err %v, exp *AuthError", err)
+		}
+	}
+}
+
+func TestMergeShardErrs(t *testing.T) {
+	failed := errors.New("failed")
+	newSE := func(allFailed bool) *ShardErrors {
+		return &ShardErrors{
+			Name:      "ListOffsets",
+			AllFailed: allFailed,
+			Errs:      []ShardError{{Err: kerr.NotLeaderForPartition, Broker: BrokerDetail{NodeID: 1}}},
+		}
+	}
+
+	if err := mergeShardErrs(nil, nil); err != nil {
+		t.Errorf("got %v merging nil errs, exp nil", err)
+	}
+	if err := mergeShardErrs(nil, failed); err != failed {
+		t.Errorf("got %v, exp %v", err, failed)
+	}
+
+	// A non-shard error is kept as a shard error on an unknown broker.
+	var se *ShardErrors
+	err := mergeShardErrs(newSE(false), failed)
+	if !errors.As(err, &se) {
+		t.Fatalf("got %v, exp *ShardErrors", err)
+	}
+	if len(se.Errs) != 2 || se.Errs[1].Err != failed || se.Errs[1].Broker.NodeID != -1 {
+		t.Errorf("got errs %v, exp the non-shard error kept on broker -1", se.Errs)
+	}
+	if se.AllFailed {
+		t.Error("got all failed, exp partial failure")
+	}
+
+	// Two non-shard errors are both kept.
+	other := errors.New("other")
+	err = mergeShardErrs(failed, other)
+	if !errors.Is(err, failed) || !errors.Is(err, other) {
+		t.Errorf("got %v, exp both errors kept", err)
+	}
+	if _, perr := Partial(0, err); perr == nil {
+		t.Error("got nil err from Partial for an entirely failed request")
+	}
+
+	// Joined errors match without relying on errors.Is following
+	// Unwrap() []error, which it does not before Go 1.20.
+	var kerrErr *kerr.Error
+	j := mergeShardErrs(failed, kerr.NotController).(joinedErrs)
+	if !j.Is(failed) || !j.Is(kerr.NotController) || j.Is(other) {
+		t.Errorf("joined errors %v matched incorrectly", j)
+	}
+	if !j.As(&kerrErr) || kerrErr != kerr.NotController {
+		t.Errorf("got %v from As, exp %v", kerrErr, kerr.NotController)
+	}
+
+	// Auth errors take precedence.
+	ae := &AuthError{Err: kerr.TopicAuthorizationFailed}
+	if err := mergeShardErrs(newSE(true), ae); err != ae {
+		t.Errorf("got %v, exp auth error", err)
+	}
+}
+
+func TestResolveClientQuotas(t *testing.T) {
+	str := func(s string) *string { return &s }
+	qs := DescribedClientQuotas{
+		{Entity: ClientQuotaEntity{{"user", nil}}, Values: ClientQuotaValues{{"producer_byte_rate", 1}}},
+		{Entity: ClientQuotaEntity{{"user", str("ANONYMOUS")}}, Values: ClientQuotaValues{{"consumer_byte_rate", 2}}},
+		{Entity: ClientQuotaEntity{{"user", str("alice")}, {"client-id", str("app")}}, Values: ClientQuotaValues{{"producer_byte_rate", 3}}},
+		{Entity: ClientQuotaEntity{{"client-id", nil}}, Values: ClientQuotaValues{{"request_percentage", 4}}},
+	}
+	for _, test := range []struct {
+		user, clientID string
+		exp            ClientQuotaValues
+	}{
+		{"", "app", ClientQuotaValues{{"consumer_byte_rate", 2}, {"producer_byte_rate", 1}, {"request_percentage", 4}}},
+		{"ANONYMOUS", "app", ClientQuotaValues{{"consumer_byte_rate", 2}, {"producer_byte_rate", 1}, {"request_percentage", 4}}},
+		{"alice", "app", ClientQuotaValues{{"producer_byte_rate", 3}, {"request_percentage", 4}}},
+		{"bob", "app", ClientQuotaValues{{"producer_byte_rate", 1}, {"request_percentage", 4}}},
+	} {
+		if got := ResolveClientQuotas(qs, test.user, test.clientID); !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("user %q client %q: got %v != exp %v", test.user, test.clientID, got, test.exp)
+		}
+	}
+
+	if tr := NewThrottleTracker("", "app", 0); tr.user != "ANONYMOUS" {
+		t.Errorf("got tracked user %q, exp ANONYMOUS", tr.user)
+	}
+}
+
+func TestDescribeConsumerGroupsVersions(t *testing.T) {
+	handle := func(req kmsg.Request) kmsg.Response {
+		switch req := req.(type) {
+		case *kmsg.ConsumerGroupDescribeRequest:
+			resp := req.ResponseKind().(*kmsg.ConsumerGroupDescribeResponse)
+			for _, g := range req.Groups {
+				rg := kmsg.NewConsumerGroupDescribeResponseGroup()
+				rg.Group = g
+				rg.State = "Stable"
+				rm := kmsg.NewConsumerGroupDescribeResponseGroupMember()
+				rm.MemberID = "m848"
+				rg.Members = append(rg.Members, rm)
+				resp.Groups = append(resp.Groups, rg)
+			}
+			return resp
+		case *kmsg.DescribeGroupsRequest:
+			resp := req.ResponseKind().(*kmsg.DescribeGroupsResponse)
+			for _, g := range req.Groups {
+				rg := kmsg.NewDescribeGroupsResponseGroup()
+				rg.Group = g
+				rg.State = "Stable"
+				rm := kmsg.NewDescribeGroupsResponseGroupMember()
+				rm.MemberID = "mclassic"
+				rg.Members = append(rg.Members, rm)
+				resp.Groups = append(resp.Groups, rg)
+			}
+			return resp
+		}
+		return nil
+	}
+
+	for _, test := range []struct {
+		name        string
+		unsupported []int16
+		expType     string
+		expMember   string
+	}{
+		{"supported", nil, "consumer", "m848"},
+		{"unsupported", []int16{kmsg.ConsumerGroupDescribe.Int16()}, "classic", "mclassic"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			f, adm := newFakeBroker(t, handle, test.unsupported...)
+			ctx := context.Background()
+
+			// Load the broker's versions on the client before counting.
+			if _, err := adm.DescribeGroups(ctx, "g"); err != nil {
+				t.Fatalf("unable to warm up: %v", err)
+			}
+			apiVersions := f.requests(kmsg.ApiVersions)
+
+			described, err := adm.DescribeConsumerGroups(ctx, "g")
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			g, err := described.On("g", nil)
+			if err != nil {
+				t.Fatalf("group missing: %v", err)
+			}
+			if g.Type != test.expType || len(g.Members) != 1 || g.Members[0].MemberID != test.expMember {
+				t.Errorf("got type %q members %v, exp type %q with member %q", g.Type, g.Members, test.expType, test.expMember)
+			}
+			if got := f.requests(kmsg.ApiVersions); got != apiVersions {
+				t.Errorf("got %d ApiVersions requests, exp the cached %d", got, apiVersions)
+			}
+			if test.unsupported != nil && f.requests(kmsg.ConsumerGroupDescribe) != 0 {
+				t.Error("ConsumerGroupDescribe was sent to a broker that does not support it")
+			}
+		})
+	}
+}
+
+func TestLeaveGroupAllMembers(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		classic     bool
+		unsupported []int16
+	}{
+		{"consumer group", false, nil},
+		{"classic group", true, nil},
+		{"broker without ConsumerGroupDescribe", true, []int16{kmsg.ConsumerGroupDescribe.Int16()}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				mu   sync.Mutex
+				left []kmsg.LeaveGroupRequestMember
+			)
+			handle := func(req kmsg.Request) kmsg.Response {
+				switch req := req.(type) {
+				case *kmsg.ConsumerGroupDescribeRequest:
+					resp := req.ResponseKind().(*kmsg.ConsumerGroupDescribeResponse)
+					rg := kmsg.NewConsumerGroupDescribeResponseGroup()
+					rg.Group = req.Groups[0]
+					if test.classic {
+						rg.ErrorCode = kerr.GroupIDNotFound.Code
+					} else {
+						static := kmsg.NewConsumerGroupDescribeResponseGroupMember()
+						static.MemberID = "s"
+						static.InstanceID = StringPtr("i")
+						dynamic := kmsg.NewConsumerGroupDescribeResponseGroupMember()
+						dynamic.MemberID = "d"
+						rg.Members = append(rg.Members, static, dynamic)
+					}
+					resp.Groups = append(resp.Groups, rg)
+					return resp
+				case *kmsg.DescribeGroupsRequest:
+					resp := req.ResponseKind().(*kmsg.DescribeGroupsResponse)
+					rg := kmsg.NewDescribeGroupsResponseGroup()
+					rg.Group = req.Groups[0]
+					if test.classic {
+						static := kmsg.NewDescribeGroupsResponseGroupMember()
+						static.MemberID = "s"
+						static.InstanceID = StringPtr("i")
+						dynamic := kmsg.NewDescribeGroupsResponseGroupMember()
+						dynamic.MemberID = "d"
+						rg.Members = append(rg.Members, static, dynamic)
+					} else {
+						// Classic describes of consumer groups
+						// return no members.
+						rg.ErrorCode = kerr.GroupIDNotFound.Code
+					}
+					resp.Groups = append(resp.Groups, rg)
+					return resp
+				case *kmsg.LeaveGroupRequest:
+					mu.Lock()
+					left = append(left, req.Members...)
+					mu.Unlock()
+					resp := req.ResponseKind().(*kmsg.LeaveGroupResponse)
+					for _, m := range req.Members {
+						rm := kmsg.NewLeaveGroupResponseMember()
+						rm.MemberID = m.MemberID
+						rm.InstanceID = m.InstanceID
+						resp.Members = append(resp.Members, rm)
+					}
+					return resp
+				}
+				return nil
+			}
+			_, adm := newFakeBroker(t, handle, test.unsupported...)
+
+			resps, err := adm.LeaveGroup(context.Background(), LeaveGroup("g").AllMembers())
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(left) != 2 || unptrStr(left[0].InstanceID) != "i" || left[1].MemberID != "d" || left[1].InstanceID != nil {
+				t.Errorf("got left members %v, exp instance i and member d", left)
+			}
+			if len(resps) != 2 || !resps.Ok() {
+				t.Errorf("got responses %v, exp two successful", resps)
+			}
+		})
+	}
+}
+
+// fakeBroker is a single broker cluster for tests. Requests are answered by
+// handle; if handle returns nil, ApiVersions, Metadata, and FindCoordinator
+// are answered with the fake broker as the only broker, controller, and
+// coordinator, and as the only replica of every topic added with addTopic.
+// Keys in unsupported are not advertised in ApiVersions.
+type fakeBroker struct {
+	ln          net.Listener
+	port        int32
+	handle      func(kmsg.Request) kmsg.Response
+	unsupported map[int16]bool
+
+	mu     sync.Mutex
+	reqs   map[int16]int
+	topics map[string]int32
+}
+
+func newFakeBroker(t *testing.T, handle func(kmsg.Request) kmsg.Response, unsupported ...int16) (*fakeBroker, *Client) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	f := &fakeBroker{
+		ln:          ln,
+		port:        int32(p),
+		handle:      handle,
+		unsupported: make(map[int16]bool),
+		reqs:        make(map[int16]int),
+		topics:      make(map[string]int32),
+	}
+	for _, k := range unsupported {
+		f.unsupported[k] = true
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(ln.Addr().String()),
+		kgo.MaxVersions(nil),
+		kgo.RetryBackoffFn(func(int) time.Duration { return time.Millisecond }),
+	)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	t.Cleanup(func() {
+		cl.Close()
+		ln.Close()
+	})
+	return f, NewClient(cl)
+}
+
+// addTopic adds a topic with the given number of partitions to metadata.
+func (f *fakeBroker) addTopic(topic string, partitions int32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.topics[topic] = partitions
+}
+
+// requests returns how many requests of the given key have been received.
+func (f *fakeBroker) requests(key kmsg.Key) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reqs[key.Int16()]
+}
+
+func (f *fakeBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+		b := kbin.Reader{Src: body}
+		key, version, corr := b.Int16(), b.Int16(), b.Int32()
+		b.NullableString() // client ID
+		req := kmsg.RequestForKey(key)
+		if req == nil {
+			return
+		}
+		req.SetVersion(version)
+		if req.IsFlexible() {
+			kmsg.SkipTags(&b)
+		}
+		if err := req.ReadFrom(b.Src); err != nil {
+			return
+		}
+
+		f.mu.Lock()
+		f.reqs[key]++
+		f.mu.Unlock()
+
+		resp := f.handle(req)
+		if resp == nil {
+			resp = f.respond(req)
+		}
+		resp.SetVersion(version)
+
+		buf := append(make([]byte, 4, 64), 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(buf[4:], uint32(corr))
+		if resp.IsFlexible() && key != kmsg.ApiVersions.Int16() {
+			buf = append(buf, 0) // empty response header tags
+		}
+		buf = resp.AppendTo(buf)
+		binary.BigEndian.PutUint32(buf, uint32(len(buf)-4))
+		if _, err := conn.Write(buf); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeBroker) respond(req kmsg.Request) kmsg.Response {
+	switch req := req.(type) {
+	case *kmsg.ApiVersionsRequest:
+		resp := req.ResponseKind().(*kmsg.ApiVersionsResponse)
+		for k := int16(0); k <= kmsg.MaxKey; k++ {
+			r := kmsg.RequestForKey(k)
+			if r == nil || f.unsupported[k] {
+				continue
+			}
+			ak := kmsg.NewApiVersionsResponseApiKey()
+			ak.ApiKey = k
+			ak.MaxVersion = r.MaxVersion()
+			resp.ApiKeys = append(resp.ApiKeys, ak)
+		}
+		return resp
+
+	case *kmsg.MetadataRequest:
+		resp := req.ResponseKind().(*kmsg.MetadataResponse)
+		b := kmsg.NewMetadataResponseBroker()
+		b.Host = "127.0.0.1"
+		b.Port = f.port
+		resp.Brokers = append(resp.Brokers, b)
+		resp.ControllerID = 0
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		topics := make(map[string]int32)
+		if req.Topics == nil {
+			topics = f.topics
+		}
+		for _, t := range req.Topics {
+			if t.Topic != nil {
+				topics[*t.Topic] = f.topics[*t.Topic]
+			}
+		}
+		for topic, partitions := range topics {
+			rt := kmsg.NewMetadataResponseTopic()
+			rt.Topic = kmsg.StringPtr(topic)
+			if _, ok := f.topics[topic]; !ok {
+				rt.ErrorCode = kerr.UnknownTopicOrPartition.Code
+			}
+			for p := int32(0); p < partitions; p++ {
+				rp := kmsg.NewMetadataResponseTopicPartition()
+				rp.Partition = p
+				rp.Replicas = []int32{0}
+				rp.ISR = []int32{0}
+				rt.Partitions = append(rt.Partitions, rp)
+			}
+			resp.Topics = append(resp.Topics, rt)
+		}
+		return resp
+
+	case *kmsg.FindCoordinatorRequest:
+		resp := req.ResponseKind().(*kmsg.FindCoordinatorResponse)
+		resp.Host = "127.0.0.1"
+		resp.Port = f.port
+		keys := req.CoordinatorKeys
+		if req.Version < 4 {
+			keys = []string{req.CoordinatorKey}
+		}
+		for _, k := range keys {
+			c := kmsg.NewFindCoordinatorResponseCoordinator()
+			c.Key = k
+			c.Host = "127.0.0.1"
+			c.Port = f.port
+			resp.Coordinators = append(resp.Coordinators, c)
+		}
+		return resp
+
+	default:
+		return req.ResponseKind()
+	}
+}
@@ -2,6 +2,7 @@ package kadm
 
 import (
 	"context"
+	"errors"
 	"sort"
 
 	"github.com/twmb/franz-go/pkg/kerr"
@@ -167,7 +168,7 @@ func (cl *Client) AlterAllReplicaLogDirs(ctx context.Context, alter AlterReplica
 		return make(AlterAllReplicaLogDirsResponses), nil
 	}
 	req := alter.req()
-	shards := cl.cl.RequestSharded(ctx, req)
+	shards := cl.requestSharded(ctx, req)
 	resps := make(AlterAllReplicaLogDirsResponses)
 	return resps, shardErrEachBroker(req, shards, func(b BrokerDetail, kr kmsg.Response) error {
 		resp := kr.(*kmsg.AlterReplicaLogDirsResponse)
@@ -182,8 +183,7 @@ func (cl *Client) AlterBrokerReplicaLogDirs(ctx context.Context, broker int32, a
 	if len(alter) == 0 {
 		return make(AlterReplicaLogDirsResponses), nil
 	}
-	b := cl.cl.Broker(int(broker))
-	kresp, err := b.RetriableRequest(ctx, alter.req())
+	kresp, err := cl.brokerRequestor(broker).Request(ctx, alter.req())
 	if err != nil {
 		return nil, err
 	}
@@ -556,7 +556,7 @@ func newDescribeLogDirsResp(node int32, resp *kmsg.DescribeLogDirsResponse) Desc
 // This may return *ShardErrors.
 func (cl *Client) DescribeAllLogDirs(ctx context.Context, s TopicsSet) (DescribedAllLogDirs, error) {
 	req := describeLogDirsReq(s)
-	shards := cl.cl.RequestSharded(ctx, req)
+	shards := cl.requestSharded(ctx, req)
 	resps := make(DescribedAllLogDirs)
 	return resps, shardErrEachBroker(req, shards, func(b BrokerDetail, kr kmsg.Response) error {
 		resp := kr.(*kmsg.DescribeLogDirsResponse)
@@ -576,8 +576,7 @@ func (cl *Client) DescribeAllLogDirs(ctx context.Context, s TopicsSet) (Describe
 // log directories.
 func (cl *Client) DescribeBrokerLogDirs(ctx context.Context, broker int32, s TopicsSet) (DescribedLogDirs, error) {
 	req := describeLogDirsReq(s)
-	b := cl.cl.Broker(int(broker))
-	kresp, err := b.RetriableRequest(ctx, req)
+	kresp, err := cl.brokerRequestor(broker).Request(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -590,3 +589,185 @@ func (cl *Client) DescribeBrokerLogDirs(ctx context.Context, broker int32, s Top
 	}
 	return newDescribeLogDirsResp(broker, resp), nil
 }
+
+// PartitionSize is the estimated disk usage of a partition, as calculated in
+// CalculateTopicSizes.
+type PartitionSize struct {
+	Topic     string // Topic is the topic for this partition.
+	Partition int32  // Partition is this partition.
+	Leader    int32  // Leader is the leader of this partition, or -1 if the partition has no leader.
+	Replicas  int    // Replicas is the number of replicas that were described on disk, including future replicas.
+
+	// ReplicatedBytes is the size of this partition summed across all
+	// replicas, including future replicas that are being moved between
+	// log directories. This is the total disk used by the partition.
+	ReplicatedBytes int64
+
+	// LeaderBytes is the size of the leader's replica of this partition,
+	// i.e. the unique bytes in this partition. If the leader's replica
+	// was not described (no leader, or the leader failed to be
+	// described), this is the size of the largest replica.
+	LeaderBytes int64
+}
+
+// TopicSize is the estimated disk usage of a topic, as calculated in
+// CalculateTopicSizes.
+type TopicSize struct {
+	Topic           string                  // Topic is the topic.
+	ReplicatedBytes int64                   // ReplicatedBytes is the sum of ReplicatedBytes across all partitions.
+	LeaderBytes     int64                   // LeaderBytes is the sum of LeaderBytes across all partitions.
+	Partitions      map[int32]PartitionSize // Partitions are the per-partition sizes.
+}
+
+// SortedPartitions returns the partition sizes sorted by partition.
+func (s TopicSize) SortedPartitions() []PartitionSize {
+	ps := make([]PartitionSize, 0, len(s.Partitions))
+	for _, p := range s.Partitions {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].Partition < ps[j].Partition })
+	return ps
+}
+
+// TopicSizes contains estimated disk usage per topic.
+type TopicSizes map[string]TopicSize
+
+// Lookup returns the size of the given topic and whether it exists.
+func (ss TopicSizes) Lookup(t string) (TopicSize, bool) {
+	s, exists := ss[t]
+	return s, exists
+}
+
+// Sorted returns the topic sizes sorted by topic.
+func (ss TopicSizes) Sorted() []TopicSize {
+	s := make([]TopicSize, 0, len(ss))
+	for _, t := range ss {
+		s = append(s, t)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Topic < s[j].Topic })
+	return s
+}
+
+// SortedBySize returns the topic sizes sorted by replicated bytes, largest
+// first, and then by topic.
+func (ss TopicSizes) SortedBySize() []TopicSize {
+	s := ss.Sorted()
+	sort.SliceStable(s, func(i, j int) bool { return s[i].ReplicatedBytes > s[j].ReplicatedBytes })
+	return s
+}
+
+// ReplicatedBytes returns the sum of replicated bytes across all topics.
+func (ss TopicSizes) ReplicatedBytes() int64 {
+	var n int64
+	for _, s := range ss {
+		n += s.ReplicatedBytes
+	}
+	return n
+}
+
+// LeaderBytes returns the sum of leader-only bytes across all topics.
+func (ss TopicSizes) LeaderBytes() int64 {
+	var n int64
+	for _, s := range ss {
+		n += s.LeaderBytes
+	}
+	return n
+}
+
+// CalculateTopicSizes combines log directories described across brokers with
+// metadata to estimate the replicated and unique (leader-only) bytes per topic
+// and per partition. Only topics in the metadata are included; partitions
+// that are in the metadata but were not described in any log directory have
+// a size of zero.
+func CalculateTopicSizes(m Metadata, dirs DescribedAllLogDirs) TopicSizes {
+	ss := make(TopicSizes)
+	for _, t := range m.Topics {
+		if t.Err != nil {
+			continue
+		}
+		s := TopicSize{
+			Topic:      t.Topic,
+			Partitions: make(map[int32]PartitionSize, len(t.Partitions)),
+		}
+		for _, p := range t.Partitions {
+			s.Partitions[p.Partition] = PartitionSize{
+				Topic:     t.Topic,
+				Partition: p.Partition,
+				Leader:    p.Leader,
+			}
+		}
+		ss[t.Topic] = s
+	}
+
+	largest := make(map[string]map[int32]int64)
+	dirs.Each(func(d DescribedLogDir) {
+		d.Topics.Each(func(dp DescribedLogDirPartition) {
+			s, exists := ss[dp.Topic]
+			if !exists {
+				return
+			}
+			p, exists := s.Partitions[dp.Partition]
+			if !exists {
+				return
+			}
+			p.Replicas++
+			p.ReplicatedBytes += dp.Size
+			if !dp.IsFuture {
+				if dp.Broker == p.Leader {
+					p.LeaderBytes = dp.Size
+				}
+				lt := largest[dp.Topic]
+				if lt == nil {
+					lt = make(map[int32]int64)
+					largest[dp.Topic] = lt
+				}
+				if dp.Size > lt[dp.Partition] {
+					lt[dp.Partition] = dp.Size
+				}
+			}
+			s.Partitions[dp.Partition] = p
+		})
+	})
+
+	for t, s := range ss {
+		for i, p := range s.Partitions {
+			if _, leaderDescribed := dirs[p.Leader]; !leaderDescribed || p.Leader < 0 {
+				p.LeaderBytes = largest[t][i]
+				s.Partitions[i] = p
+			}
+			s.ReplicatedBytes += p.ReplicatedBytes
+			s.LeaderBytes += p.LeaderBytes
+		}
+		ss[t] = s
+	}
+	return ss
+}
+
+// TopicSizes estimates the disk usage of the given topics, or all topics if
+// none are specified, by requesting metadata and then describing log
+// directories on every broker. See CalculateTopicSizes for more details.
+//
+// If some brokers fail to be described, this returns the sizes that could be
+// calculated along with *ShardErrors. This returns an error if the metadata
+// request fails, or an *AuthError.
+func (cl *Client) TopicSizes(ctx context.Context, topics ...string) (TopicSizes, error) {
+	m, err := cl.Metadata(ctx, topics...)
+	if err != nil {
+		return nil, err
+	}
+	var s TopicsSet
+	if len(topics) > 0 {
+		s = m.Topics.TopicsSet()
+		if len(s) == 0 {
+			return make(TopicSizes), nil
+		}
+	}
+	dirs, err := cl.DescribeAllLogDirs(ctx, s)
+	if err != nil {
+		var se *ShardErrors
+		if !errors.As(err, &se) || se.AllFailed {
+			return nil, err
+		}
+	}
+	return CalculateTopicSizes(m, dirs), err
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"sort"
+	"sync"
 
 	"github.com/twmb/franz-go/pkg/kerr"
 	"github.com/twmb/franz-go/pkg/kgo"
@@ -21,6 +22,10 @@ func (t TopicID) String() string { return base64.StdEncoding.EncodeToString(t[:]
 // MarshalJSON returns the topic ID encoded as quoted base64.
 func (t TopicID) MarshalJSON() ([]byte, error) { return []byte(`"` + t.String() + `"`), nil }
 
+// MarshalText returns the topic ID encoded as base64, allowing topic IDs to be
+// used as JSON object keys.
+func (t TopicID) MarshalText() ([]byte, error) { return []byte(t.String()), nil }
+
 // Less returns if this ID is less than the other, byte by byte.
 func (t TopicID) Less(other TopicID) bool {
 	return bytes.Compare(t[:], other[:]) == -1
@@ -85,6 +90,11 @@ type TopicDetail struct {
 	IsInternal bool             // IsInternal is whether the topic is an internal topic.
 	Partitions PartitionDetails // Partitions contains details about the topic's partitions.
 
+	// AuthorizedOperations contains the operations the client is
+	// authorized to perform on this topic, if the client used the
+	// IncludeAuthorizedOperations option.
+	AuthorizedOperations []ACLOperation
+
 	Err error // Err is non-nil if the topic could not be loaded.
 }
 
@@ -129,6 +139,31 @@ func (ds TopicDetails) Has(topic string) bool {
 	return ok && d.Err != kerr.UnknownTopicOrPartition
 }
 
+// LookupID returns the topic with the given ID and whether it exists.
+func (ds TopicDetails) LookupID(id TopicID) (TopicDetail, bool) {
+	if d, ok := ds[id.String()]; ok && d.ID == id {
+		return d, true
+	}
+	for _, d := range ds {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return TopicDetail{}, false
+}
+
+// IDs returns a map of topic ID to topic name for all topics that have an ID
+// and a name.
+func (ds TopicDetails) IDs() map[TopicID]string {
+	ids := make(map[TopicID]string, len(ds))
+	for _, d := range ds {
+		if d.ID != (TopicID{}) && d.Topic != "" {
+			ids[d.ID] = d.Topic
+		}
+	}
+	return ids
+}
+
 // FilterInternal deletes any internal topics from this set of topic details.
 func (ds TopicDetails) FilterInternal() {
 	for t, d := range ds {
@@ -138,6 +173,15 @@ func (ds TopicDetails) FilterInternal() {
 	}
 }
 
+// KeepFunc calls fn for every topic, keeping the topic if fn returns true.
+func (ds TopicDetails) KeepFunc(fn func(TopicDetail) bool) {
+	for t, d := range ds {
+		if !fn(d) {
+			delete(ds, t)
+		}
+	}
+}
+
 // EachPartition calls fn for every partition in all topics.
 func (ds TopicDetails) EachPartition(fn func(PartitionDetail)) {
 	for _, td := range ds {
@@ -198,7 +242,7 @@ func (cl *Client) ListBrokers(ctx context.Context) (BrokerDetails, error) {
 //
 // This returns an error if the request fails to be issued, or an *AuthErr.
 func (cl *Client) BrokerMetadata(ctx context.Context) (Metadata, error) {
-	return cl.metadata(ctx, true, nil)
+	return cl.metadata(ctx, true, nil, nil)
 }
 
 // Metadata issues a metadata request and returns it. Specific topics to
@@ -210,20 +254,62 @@ func (cl *Client) Metadata(
 	ctx context.Context,
 	topics ...string,
 ) (Metadata, error) {
-	return cl.metadata(ctx, false, topics)
+	return cl.metadata(ctx, false, topics, nil)
 }
 
-func (cl *Client) metadata(ctx context.Context, noTopics bool, topics []string) (Metadata, error) {
+// MetadataForIDs issues a metadata request for the given topic IDs and
+// returns it. If no IDs are specified, all topics are requested. This
+// requires Kafka 3.1+ (metadata request v12), and not all brokers support
+// describing topics by ID.
+//
+// Topics that cannot be described by ID, such as when the broker returns
+// UNKNOWN_TOPIC_ID, do not have a name in the response. These topics are
+// keyed in the returned TopicDetails by the ID's String (base64) form, have
+// an empty Topic field, and have Err set. All other topics are keyed by name
+// as usual. You can use TopicDetails.LookupID to look up a topic by ID
+// regardless of whether the topic has a name.
+//
+// This returns an error if the request fails to be issued, or an *AuthErr.
+func (cl *Client) MetadataForIDs(
+	ctx context.Context,
+	ids ...TopicID,
+) (Metadata, error) {
+	return cl.metadata(ctx, false, nil, ids)
+}
+
+// ListTopicsForIDs issues a metadata request for the given topic IDs and
+// returns TopicDetails. See MetadataForIDs for how topics that could not be
+// described by ID are returned.
+//
+// This returns an error if the request fails to be issued, or an *AuthError.
+func (cl *Client) ListTopicsForIDs(
+	ctx context.Context,
+	ids ...TopicID,
+) (TopicDetails, error) {
+	m, err := cl.MetadataForIDs(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+	return m.Topics, nil
+}
+
+func (cl *Client) metadata(ctx context.Context, noTopics bool, topics []string, ids []TopicID) (Metadata, error) {
 	req := kmsg.NewPtrMetadataRequest()
 	for _, t := range topics {
 		rt := kmsg.NewMetadataRequestTopic()
 		rt.Topic = kmsg.StringPtr(t)
 		req.Topics = append(req.Topics, rt)
 	}
+	for _, id := range ids {
+		rt := kmsg.NewMetadataRequestTopic()
+		rt.TopicID = id
+		req.Topics = append(req.Topics, rt)
+	}
 	if noTopics {
 		req.Topics = []kmsg.MetadataRequestTopic{}
 	}
-	resp, err := req.RequestWith(ctx, cl.cl)
+	req.IncludeTopicAuthorizedOperations = cl.authOps
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return Metadata{}, err
 	}
@@ -234,12 +320,15 @@ func (cl *Client) metadata(ctx context.Context, noTopics bool, topics []string)
 			return Metadata{}, err
 		}
 		td := TopicDetail{
-			Topic:      *t.Topic,
+			Topic:      unptrStr(t.Topic),
 			ID:         t.TopicID,
 			Partitions: make(map[int32]PartitionDetail),
 			IsInternal: t.IsInternal,
 			Err:        kerr.ErrorForCode(t.ErrorCode),
 		}
+		if cl.authOps {
+			td.AuthorizedOperations = DecodeAuthorizedOperations(t.AuthorizedOperations)
+		}
 		for _, p := range t.Partitions {
 			td.Partitions[p.Partition] = PartitionDetail{
 				Topic:     td.Topic,
@@ -254,7 +343,11 @@ func (cl *Client) metadata(ctx context.Context, noTopics bool, topics []string)
 				Err: kerr.ErrorForCode(p.ErrorCode),
 			}
 		}
-		tds[*t.Topic] = td
+		key := td.Topic
+		if t.Topic == nil {
+			key = td.ID.String() // describing by ID failed, the broker did not return a name
+		}
+		tds[key] = td
 	}
 
 	m := Metadata{
@@ -278,16 +371,193 @@ func (cl *Client) metadata(ctx context.Context, noTopics bool, topics []string)
 	if len(topics) > 0 && len(m.Topics) != len(topics) {
 		return Metadata{}, fmt.Errorf("metadata returned only %d topics of %d requested", len(m.Topics), len(topics))
 	}
+	if len(ids) > 0 && len(m.Topics) != len(ids) {
+		return Metadata{}, fmt.Errorf("metadata returned only %d topics of %d requested", len(m.Topics), len(ids))
+	}
 
 	return m, nil
 }
 
+// DescribedCluster contains data from a describe cluster response.
+type DescribedCluster struct {
+	Cluster    string        // Cluster is the cluster ID.
+	Controller int32         // Controller is the node ID of the controller broker, or a random broker if the controller is not exposed.
+	Brokers    BrokerDetails // Brokers contains broker details, sorted by node ID.
+
+	// AuthorizedOperations contains the operations the client is
+	// authorized to perform on the cluster, if the client used the
+	// IncludeAuthorizedOperations option.
+	AuthorizedOperations []ACLOperation
+}
+
+// DescribeCluster describes the cluster with a DescribeCluster request, which
+// requires Kafka 2.8+. Unlike Metadata, this does not describe any topics,
+// and this is the only way to see the operations the client is authorized to
+// perform on the cluster in Kafka 2.8+ (see IncludeAuthorizedOperations).
+//
+// This returns an error if the request fails to be issued, or an *AuthError.
+func (cl *Client) DescribeCluster(ctx context.Context) (DescribedCluster, error) {
+	req := kmsg.NewPtrDescribeClusterRequest()
+	req.IncludeClusterAuthorizedOperations = cl.authOps
+	resp, err := req.RequestWith(ctx, cl.requestor())
+	if err != nil {
+		return DescribedCluster{}, err
+	}
+	if err := maybeAuthErr(resp.ErrorCode); err != nil {
+		return DescribedCluster{}, err
+	}
+	if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		return DescribedCluster{}, err
+	}
+
+	c := DescribedCluster{
+		Cluster:    resp.ClusterID,
+		Controller: resp.ControllerID,
+	}
+	for _, b := range resp.Brokers {
+		c.Brokers = append(c.Brokers, kgo.BrokerMetadata{
+			NodeID: b.NodeID,
+			Host:   b.Host,
+			Port:   b.Port,
+			Rack:   b.Rack,
+		})
+	}
+	sort.Slice(c.Brokers, func(i, j int) bool { return c.Brokers[i].NodeID < c.Brokers[j].NodeID })
+	if cl.authOps {
+		c.AuthorizedOperations = DecodeAuthorizedOperations(resp.ClusterAuthorizedOperations)
+	}
+	return c, nil
+}
+
+// DescribeTopicPartitionsPages describes topics with the DescribeTopicPartitions
+// request (Kafka 3.7+, KIP-966), calling fn with each page of topics. If no
+// topics are requested, all topics are described. Each response contains at
+// most partitionLimit partitions; a limit of zero or less uses the Kafka
+// default of 2000. If fn returns an error, describing stops and the error is
+// returned.
+//
+// Unlike a metadata request, this does not require the broker to build (and
+// the client to hold) one response containing every partition in the cluster,
+// which makes this suitable for clusters with hundreds of thousands of
+// partitions. Kafka may split a topic across responses; this function holds
+// back a partially described topic until all of its partitions are
+// described, so every page passed to fn contains only complete topics.
+//
+// This returns an error if any request fails, or an *AuthError.
+func (cl *Client) DescribeTopicPartitionsPages(
+	ctx context.Context,
+	partitionLimit int32,
+	fn func(TopicDetails) error,
+	topics ...string,
+) error {
+	req := kmsg.NewPtrDescribeTopicPartitionsRequest()
+	if partitionLimit > 0 {
+		req.ResponsePartitionLimit = partitionLimit
+	}
+	for _, t := range topics {
+		rt := kmsg.NewDescribeTopicPartitionsRequestTopic()
+		rt.Topic = t
+		req.Topics = append(req.Topics, rt)
+	}
+
+	var partial *TopicDetail
+	for {
+		resp, err := req.RequestWith(ctx, cl.requestor())
+		if err != nil {
+			return err
+		}
+
+		page := make(TopicDetails, len(resp.Topics))
+		if partial != nil {
+			page[partial.Topic] = *partial
+			partial = nil
+		}
+		for _, t := range resp.Topics {
+			if err := maybeAuthErr(t.ErrorCode); err != nil {
+				return err
+			}
+			key := unptrStr(t.Topic)
+			if t.Topic == nil {
+				key = TopicID(t.TopicID).String()
+			}
+			td, exists := page[key]
+			if !exists {
+				td = TopicDetail{
+					Topic:      unptrStr(t.Topic),
+					ID:         t.TopicID,
+					Partitions: make(map[int32]PartitionDetail),
+					IsInternal: t.IsInternal,
+					Err:        kerr.ErrorForCode(t.ErrorCode),
+				}
+				if cl.authOps {
+					td.AuthorizedOperations = DecodeAuthorizedOperations(t.AuthorizedOperations)
+				}
+			}
+			for _, p := range t.Partitions {
+				td.Partitions[p.Partition] = PartitionDetail{
+					Topic:     td.Topic,
+					Partition: p.Partition,
+
+					Leader:          p.Leader,
+					LeaderEpoch:     p.LeaderEpoch,
+					Replicas:        p.Replicas,
+					ISR:             p.ISR,
+					OfflineReplicas: p.OfflineReplicas,
+
+					Err: kerr.ErrorForCode(p.ErrorCode),
+				}
+			}
+			page[key] = td
+		}
+
+		next := resp.NextCursor
+		if next != nil {
+			if td, ok := page[next.Topic]; ok {
+				partial = &td
+				delete(page, next.Topic)
+			}
+			cursor := kmsg.NewDescribeTopicPartitionsRequestCursor()
+			cursor.Topic = next.Topic
+			cursor.Partition = next.Partition
+			req.Cursor = &cursor
+		}
+		if len(page) > 0 {
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+		if next == nil {
+			return nil
+		}
+	}
+}
+
+// DescribeTopicPartitions describes all requested topics, or all topics if no
+// topics are requested, by paging through DescribeTopicPartitions responses.
+// See DescribeTopicPartitionsPages for more details; this function collects
+// every page into one TopicDetails.
+//
+// This returns an error if any request fails, or an *AuthError.
+func (cl *Client) DescribeTopicPartitions(ctx context.Context, topics ...string) (TopicDetails, error) {
+	all := make(TopicDetails)
+	err := cl.DescribeTopicPartitionsPages(ctx, 0, func(page TopicDetails) error {
+		for t, td := range page {
+			all[t] = td
+		}
+		return nil
+	}, topics...)
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
 // ListedOffset contains record offset information.
 type ListedOffset struct {
 	Topic     string // Topic is the topic this offset is for.
 	Partition int32  // Partition is the partition this offset is for.
 
-	Timestamp   int64 // Timestamp is the millisecond of the offset if listing after a time, otherwise -1.
+	Timestamp   int64 // Timestamp is the millisecond of the offset if listing after a time or listing max timestamps, otherwise -1.
 	Offset      int64 // Offset is the record offset, or -1 if one could not be found.
 	LeaderEpoch int32 // LeaderEpoch is the leader epoch at this offset, if any, otherwise -1.
 
@@ -398,19 +668,246 @@ func (cl *Client) ListOffsetsAfterMilli(ctx context.Context, millisecond int64,
 	return cl.listOffsets(ctx, 0, millisecond, topics)
 }
 
+// ListMaxTimestampOffsets returns the offset and timestamp of the record with
+// the largest timestamp for each partition in each requested topic. If no
+// topics are specified, all topics are listed. If multiple records share the
+// largest timestamp, the earliest of them is returned. Partitions with no
+// records have an offset and timestamp of -1.
+//
+// This is useful for detecting producers that are writing timestamps in the
+// future, which can break time based retention and offset lookups.
+//
+// Listing max timestamps requires Kafka 3.0+. If a broker does not support
+// it, partitions led by that broker have Err set to
+// kerr.UnsupportedVersion.
+//
+// This may return *ShardErrors.
+func (cl *Client) ListMaxTimestampOffsets(ctx context.Context, topics ...string) (ListedOffsets, error) {
+	return cl.listOffsets(ctx, 0, -3, topics)
+}
+
+// ListStartOffsetsForPartitions is the same as ListStartOffsets, but lists
+// offsets for only the requested partitions rather than all partitions of
+// whole topics. This avoids a metadata request and avoids listing every
+// partition when only a few are of interest. To list partitions from an
+// existing Offsets, use Offsets.TopicsSet.
+//
+// This may return *ShardErrors.
+func (cl *Client) ListStartOffsetsForPartitions(ctx context.Context, s TopicsSet) (ListedOffsets, error) {
+	return cl.listOffsetsFor(ctx, 0, -2, s)
+}
+
+// ListEndOffsetsForPartitions is the same as ListEndOffsets, but lists offsets
+// for only the requested partitions rather than all partitions of whole
+// topics.
+//
+// This may return *ShardErrors.
+func (cl *Client) ListEndOffsetsForPartitions(ctx context.Context, s TopicsSet) (ListedOffsets, error) {
+	return cl.listOffsetsFor(ctx, 0, -1, s)
+}
+
+// ListCommittedOffsetsForPartitions is the same as ListCommittedOffsets, but
+// lists offsets for only the requested partitions rather than all partitions
+// of whole topics.
+//
+// This may return *ShardErrors.
+func (cl *Client) ListCommittedOffsetsForPartitions(ctx context.Context, s TopicsSet) (ListedOffsets, error) {
+	return cl.listOffsetsFor(ctx, 1, -1, s)
+}
+
+// ListMaxTimestampOffsetsForPartitions is the same as ListMaxTimestampOffsets,
+// but lists offsets for only the requested partitions rather than all
+// partitions of whole topics.
+//
+// This may return *ShardErrors.
+func (cl *Client) ListMaxTimestampOffsetsForPartitions(ctx context.Context, s TopicsSet) (ListedOffsets, error) {
+	return cl.listOffsetsFor(ctx, 0, -3, s)
+}
+
+// ListOffsetsAfterMilliForPartitions is the same as ListOffsetsAfterMilli, but
+// lists offsets for only the requested partitions rather than all partitions
+// of whole topics.
+//
+// This may return *ShardErrors.
+func (cl *Client) ListOffsetsAfterMilliForPartitions(ctx context.Context, millisecond int64, s TopicsSet) (ListedOffsets, error) {
+	return cl.listOffsetsFor(ctx, 0, millisecond, s)
+}
+
+// ListOffsetsSpec specifies which offsets to list with ListOffsetsFunc.
+type ListOffsetsSpec struct {
+	isolation int8
+	timestamp int64
+}
+
+// StartOffsetsSpec lists start offsets, as in ListStartOffsets.
+func StartOffsetsSpec() ListOffsetsSpec { return ListOffsetsSpec{0, -2} }
+
+// EndOffsetsSpec lists end offsets, as in ListEndOffsets.
+func EndOffsetsSpec() ListOffsetsSpec { return ListOffsetsSpec{0, -1} }
+
+// CommittedOffsetsSpec lists committed offsets, as in ListCommittedOffsets.
+func CommittedOffsetsSpec() ListOffsetsSpec { return ListOffsetsSpec{1, -1} }
+
+// MaxTimestampOffsetsSpec lists max timestamp offsets, as in
+// ListMaxTimestampOffsets.
+func MaxTimestampOffsetsSpec() ListOffsetsSpec { return ListOffsetsSpec{0, -3} }
+
+// AfterMilliOffsetsSpec lists offsets after the given millisecond, as in
+// ListOffsetsAfterMilli.
+func AfterMilliOffsetsSpec(millisecond int64) ListOffsetsSpec {
+	return ListOffsetsSpec{0, millisecond}
+}
+
+// ListOffsetsFunc lists offsets for the requested partitions according to
+// spec, calling fn with the offsets as each chunk of partitions is listed. If
+// s is nil, all partitions of all topics are listed.
+//
+// Partitions are split into chunks of bounded size (see ListOffsetsChunking)
+// that are listed concurrently, which avoids one massive request and response
+// on clusters with many partitions. fn is called serially, and each partition
+// is passed to fn exactly once. If fn returns an error, listing stops and
+// this returns that error.
+//
+// This may return *ShardErrors.
+func (cl *Client) ListOffsetsFunc(ctx context.Context, spec ListOffsetsSpec, s TopicsSet, fn func(ListedOffsets) error) error {
+	if s == nil {
+		tds, err := cl.ListTopics(ctx)
+		if err != nil {
+			return err
+		}
+		s = tds.TopicsSet()
+	}
+	return cl.listOffsetsChunked(ctx, spec.isolation, spec.timestamp, s, fn)
+}
+
 func (cl *Client) listOffsets(ctx context.Context, isolation int8, timestamp int64, topics []string) (ListedOffsets, error) {
 	tds, err := cl.ListTopics(ctx, topics...)
 	if err != nil {
 		return nil, err
 	}
+	return cl.listOffsetsFor(ctx, isolation, timestamp, tds.TopicsSet())
+}
+
+func (cl *Client) listOffsetsFor(ctx context.Context, isolation int8, timestamp int64, s TopicsSet) (ListedOffsets, error) {
+	list := make(ListedOffsets)
+	err := cl.listOffsetsChunked(ctx, isolation, timestamp, s, func(l ListedOffsets) error {
+		for t, ps := range l {
+			lt, ok := list[t]
+			if !ok {
+				list[t] = ps
+				continue
+			}
+			for p, o := range ps {
+				lt[p] = o
+			}
+		}
+		return nil
+	})
+	return list, err
+}
+
+// listOffsetsChunked splits s into chunks of at most the client's chunk size,
+// lists each chunk with bounded concurrency, and calls fn serially with the
+// results of each chunk.
+func (cl *Client) listOffsetsChunked(ctx context.Context, isolation int8, timestamp int64, s TopicsSet, fn func(ListedOffsets) error) error {
+	size, concurrency := cl.offsetsChunkSize, cl.offsetsConcurrency
+	if size <= 0 {
+		size = defaultListOffsetsChunk
+	}
+	if concurrency <= 0 {
+		concurrency = defaultListOffsetsConcurrency
+	}
+
+	var chunks []TopicsSet
+	var chunk TopicsSet
+	var n int
+	for _, t := range s.Sorted() {
+		for _, p := range t.Partitions {
+			if chunk == nil || n == size {
+				chunk = make(TopicsSet)
+				chunks = append(chunks, chunk)
+				n = 0
+			}
+			chunk.Add(t.Topic, p)
+			n++
+		}
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		sem       = make(chan struct{}, concurrency)
+		err       error
+		fnErr     error
+		succeeded bool
+	)
+	for _, chunk := range chunks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(chunk TopicsSet) {
+			defer func() { <-sem; wg.Done() }()
+			l, cerr := cl.listOffsetsChunk(ctx, isolation, timestamp, chunk)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if fnErr != nil {
+				return
+			}
+			if cerr == nil {
+				succeeded = true
+			}
+			err = mergeShardErrs(err, cerr)
+			if len(l) > 0 {
+				if fnErr = fn(l); fnErr != nil {
+					cancel()
+				}
+			}
+		}(chunk)
+	}
+	wg.Wait()
+
+	if fnErr != nil {
+		return fnErr
+	}
+	if se, ok := err.(*ShardErrors); ok && succeeded {
+		se.AllFailed = false
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
+
+// listOffsetsChunk lists offsets for a single chunk of partitions.
+func (cl *Client) listOffsetsChunk(ctx context.Context, isolation int8, timestamp int64, s TopicsSet) (ListedOffsets, error) {
+	if len(s) == 0 {
+		return make(ListedOffsets), nil
+	}
 
 	// If we request with timestamps, we may request twice: once for after
 	// timestamps, and once for any -1 (and no error) offsets where the
 	// timestamp is in the future.
+	//
+	// Listing the max timestamp (-3) requires v7; older brokers would
+	// interpret -3 as a literal timestamp and return the start offset, so
+	// we fail partitions from older brokers ourselves.
 	list := make(ListedOffsets)
 	rerequest := make(map[string][]int32)
 	shardfn := func(kr kmsg.Response) error {
 		resp := kr.(*kmsg.ListOffsetsResponse)
+		unsupported := timestamp == -3 && resp.GetVersion() < 7
 		for _, t := range resp.Topics {
 			lt, ok := list[t.Topic]
 			if !ok {
@@ -429,7 +926,18 @@ func (cl *Client) listOffsets(ctx context.Context, isolation int8, timestamp int
 					LeaderEpoch: p.LeaderEpoch,
 					Err:         kerr.ErrorForCode(p.ErrorCode),
 				}
-				if timestamp != -1 && p.Offset == -1 && p.ErrorCode == 0 {
+				if unsupported {
+					lt[p.Partition] = ListedOffset{
+						Topic:       t.Topic,
+						Partition:   p.Partition,
+						Timestamp:   -1,
+						Offset:      -1,
+						LeaderEpoch: -1,
+						Err:         kerr.UnsupportedVersion,
+					}
+					continue
+				}
+				if timestamp >= 0 && p.Offset == -1 && p.ErrorCode == 0 {
 					rerequest[t.Topic] = append(rerequest[t.Topic], p.Partition)
 				}
 			}
@@ -439,10 +947,10 @@ func (cl *Client) listOffsets(ctx context.Context, isolation int8, timestamp int
 
 	req := kmsg.NewPtrListOffsetsRequest()
 	req.IsolationLevel = isolation
-	for t, td := range tds {
+	for t, ps := range s {
 		rt := kmsg.NewListOffsetsRequestTopic()
 		rt.Topic = t
-		for p := range td.Partitions {
+		for p := range ps {
 			rp := kmsg.NewListOffsetsRequestTopicPartition()
 			rp.Partition = p
 			rp.Timestamp = timestamp
@@ -450,8 +958,8 @@ func (cl *Client) listOffsets(ctx context.Context, isolation int8, timestamp int
 		}
 		req.Topics = append(req.Topics, rt)
 	}
-	shards := cl.cl.RequestSharded(ctx, req)
-	err = shardErrEach(req, shards, shardfn)
+	shards := cl.requestSharded(ctx, req)
+	err := shardErrEach(req, shards, shardfn)
 	if len(rerequest) > 0 {
 		req.Topics = req.Topics[:0]
 		for t, ps := range rerequest {
@@ -465,7 +973,7 @@ func (cl *Client) listOffsets(ctx context.Context, isolation int8, timestamp int
 			}
 			req.Topics = append(req.Topics, rt)
 		}
-		shards = cl.cl.RequestSharded(ctx, req)
+		shards = cl.requestSharded(ctx, req)
 		err = mergeShardErrs(err, shardErrEach(req, shards, shardfn))
 	}
 	return list, err
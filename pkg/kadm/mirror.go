@@ -0,0 +1,269 @@
+package kadm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// MirroredTopic is the result of mirroring a single topic from a source
+// cluster to a destination cluster.
+type MirroredTopic struct {
+	Topic string // Topic is the topic that was mirrored.
+
+	Created bool // Created is true if the topic did not exist in the destination and was created.
+
+	SourcePartitions int // SourcePartitions is the number of partitions in the source topic.
+	DestPartitions   int // DestPartitions is the number of partitions in the destination topic before mirroring, or 0 if the topic was created.
+
+	// ConfigsSet contains dynamic configs that were set in the
+	// destination, either because they did not exist or had a different
+	// value. If the topic was created, this contains all configs the topic
+	// was created with.
+	ConfigsSet map[string]string
+
+	// ConfigsDeleted contains dynamic configs that existed in the
+	// destination but not the source, and were deleted from the
+	// destination.
+	ConfigsDeleted []string
+
+	// ValidateOnly is true if the destination client was using the
+	// ValidateOnly option; changes were validated but not applied.
+	ValidateOnly bool
+
+	Err        error  // Err is non-nil if the topic could not be fully mirrored.
+	ErrMessage string // ErrMessage a potential extra message describing any error.
+}
+
+// Changed returns whether mirroring this topic changed (or, if validating,
+// would change) the destination.
+func (m MirroredTopic) Changed() bool {
+	return m.Created || m.SourcePartitions != m.DestPartitions || len(m.ConfigsSet) > 0 || len(m.ConfigsDeleted) > 0
+}
+
+// MirroredTopics contains per-topic results of mirroring topics.
+type MirroredTopics map[string]MirroredTopic
+
+// Sorted returns all results sorted by topic.
+func (ms MirroredTopics) Sorted() []MirroredTopic {
+	s := make([]MirroredTopic, 0, len(ms))
+	for _, m := range ms {
+		s = append(s, m)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Topic < s[j].Topic })
+	return s
+}
+
+// Each calls fn for every result.
+func (ms MirroredTopics) Each(fn func(MirroredTopic)) {
+	for _, m := range ms {
+		fn(m)
+	}
+}
+
+// Error iterates over all results and returns the first error encountered, if
+// any.
+func (ms MirroredTopics) Error() error {
+	for _, m := range ms {
+		if m.Err != nil {
+			return m.Err
+		}
+	}
+	return nil
+}
+
+// Ok returns true if there are no errors. This is a shortcut for ms.Error() ==
+// nil.
+func (ms MirroredTopics) Ok() bool {
+	return ms.Error() == nil
+}
+
+// ErrMirrorPartitionsShrink is returned in a MirroredTopic if the destination
+// topic has more partitions than the source topic. Kafka does not support
+// removing partitions.
+var ErrMirrorPartitionsShrink = errors.New("destination topic has more partitions than the source topic")
+
+// Replication throttles are specific to the replicas of a cluster and are
+// never mirrored.
+var unmirroredConfigs = map[string]bool{
+	leaderThrottledReplicas:  true,
+	followerThrottledReplica: true,
+}
+
+// MirrorTopics reads the partition counts and dynamic (explicitly set) topic
+// configs of the given topics in src and applies them to dst. If no topics are
+// given, all non-internal topics in src are mirrored.
+//
+// Topics that do not exist in dst are created with the source topic's
+// partition count, replication factor, and dynamic configs. Topics that do
+// exist in dst have partitions added to match the source partition count, and
+// have their dynamic configs reconciled: configs that differ or are missing are
+// set, and dynamic configs that exist only in the destination are deleted.
+// Sensitive configs (which are not returned from describing) and replication
+// throttles are never mirrored.
+//
+// To see what would change without changing anything, pass a destination
+// client using the ValidateOnly option:
+//
+//	report, err := kadm.MirrorTopics(ctx, src, dst.WithOpts(kadm.ValidateOnly()))
+//
+// This returns an error if the source topics or configs cannot be described,
+// or if the destination topics cannot be listed. Per-topic failures are
+// returned in each MirroredTopic.
+func MirrorTopics(ctx context.Context, src, dst *Client, topics ...string) (MirroredTopics, error) {
+	stds, err := src.ListTopics(ctx, topics...)
+	if err != nil {
+		return nil, err
+	}
+	names := stds.Names()
+	if len(names) == 0 {
+		return make(MirroredTopics), nil
+	}
+	sconfigs, err := src.DescribeTopicConfigs(ctx, names...)
+	if err != nil {
+		return nil, err
+	}
+	dtds, err := dst.ListTopics(ctx, names...)
+	if err != nil {
+		return nil, err
+	}
+	dconfigs, err := dst.DescribeTopicConfigs(ctx, dtds.existing()...)
+	if err != nil {
+		return nil, err
+	}
+
+	ms := make(MirroredTopics, len(names))
+	for _, t := range names {
+		std := stds[t]
+		m := MirroredTopic{
+			Topic:            t,
+			SourcePartitions: len(std.Partitions),
+			ConfigsSet:       make(map[string]string),
+			ValidateOnly:     dst.validateOnly,
+		}
+		if std.Err != nil {
+			m.Err = std.Err
+			ms[t] = m
+			continue
+		}
+		want, err := dynamicTopicConfigs(sconfigs, t)
+		if err != nil {
+			m.Err = err
+			ms[t] = m
+			continue
+		}
+
+		if !dtds.Has(t) {
+			m.Created = true
+			create := make(map[string]*string, len(want))
+			for k, v := range want {
+				m.ConfigsSet[k] = v
+				create[k] = StringPtr(v)
+			}
+			rs, err := dst.CreateTopics(ctx, int32(len(std.Partitions)), int16(std.Partitions.NumReplicas()), create, t)
+			if err == nil {
+				r := rs[t]
+				err = r.Err
+				m.ErrMessage = r.ErrMessage
+			}
+			m.Err = err
+			ms[t] = m
+			continue
+		}
+
+		dtd := dtds[t]
+		m.DestPartitions = len(dtd.Partitions)
+		if dtd.Err != nil {
+			m.Err = dtd.Err
+			ms[t] = m
+			continue
+		}
+		have, err := dynamicTopicConfigs(dconfigs, t)
+		if err != nil {
+			m.Err = err
+			ms[t] = m
+			continue
+		}
+
+		switch {
+		case m.DestPartitions > m.SourcePartitions:
+			m.Err = ErrMirrorPartitionsShrink
+		case m.DestPartitions < m.SourcePartitions:
+			rs, err := dst.UpdatePartitions(ctx, m.SourcePartitions, t)
+			if err == nil {
+				r := rs[t]
+				err = r.Err
+				m.ErrMessage = r.ErrMessage
+			}
+			m.Err = err
+		}
+		if m.Err != nil {
+			ms[t] = m
+			continue
+		}
+
+		var alter []AlterConfig
+		for k, v := range want {
+			if hv, ok := have[k]; !ok || hv != v {
+				m.ConfigsSet[k] = v
+				alter = append(alter, AlterConfig{Name: k, Value: StringPtr(v)})
+			}
+		}
+		for k := range have {
+			if _, ok := want[k]; !ok {
+				m.ConfigsDeleted = append(m.ConfigsDeleted, k)
+				alter = append(alter, AlterConfig{Op: DeleteConfig, Name: k})
+			}
+		}
+		sort.Strings(m.ConfigsDeleted)
+		if len(alter) > 0 {
+			rs, err := dst.AlterTopicConfigs(ctx, alter, t)
+			if err == nil {
+				var r AlterConfigsResponse
+				r, err = rs.On(t, nil)
+				if err == nil {
+					err = r.Err
+					m.ErrMessage = r.ErrMessage
+				}
+			}
+			m.Err = err
+		}
+		ms[t] = m
+	}
+	return ms, nil
+}
+
+// existing returns the names of all topics that exist, i.e. are not
+// UNKNOWN_TOPIC_OR_PARTITION.
+func (ds TopicDetails) existing() []string {
+	var names []string
+	for _, t := range ds.Names() {
+		if ds.Has(t) {
+			names = append(names, t)
+		}
+	}
+	return names
+}
+
+// dynamicTopicConfigs returns the non-sensitive, explicitly set configs for
+// a topic.
+func dynamicTopicConfigs(rs ResourceConfigs, topic string) (map[string]string, error) {
+	rc, err := rs.On(topic, nil)
+	if err != nil {
+		return nil, fmt.Errorf("topic %s configs missing from describe response", topic)
+	}
+	if rc.Err != nil {
+		return nil, rc.Err
+	}
+	m := make(map[string]string)
+	for _, c := range rc.Configs {
+		if c.Source != kmsg.ConfigSourceDynamicTopicConfig || c.Sensitive || c.Value == nil || unmirroredConfigs[c.Key] {
+			continue
+		}
+		m[c.Key] = *c.Value
+	}
+	return m, nil
+}
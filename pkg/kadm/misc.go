@@ -119,7 +119,7 @@ func (cl *Client) findCoordinators(ctx context.Context, kind int8, names ...stri
 		}
 	}
 
-	shards := cl.cl.RequestSharded(ctx, req)
+	shards := cl.requestSharded(ctx, req)
 	for _, shard := range shards {
 		req := shard.Req.(*kmsg.FindCoordinatorRequest)
 		if shard.Err != nil {
@@ -257,7 +257,7 @@ func (cl *Client) ApiVersions(ctx context.Context) (BrokersApiVersions, error) {
 		go func() {
 			defer wg.Done()
 			v := BrokerApiVersions{NodeID: n, keyVersions: make(map[int16]minmax)}
-			v.raw, v.Err = req.RequestWith(ctx, cl.cl.Broker(int(n)))
+			v.raw, v.Err = req.RequestWith(ctx, retrier{cl, cl.cl.Broker(int(n))})
 
 			mu.Lock()
 			defer mu.Unlock()
@@ -365,7 +365,7 @@ func (cl *Client) DescribeClientQuotas(ctx context.Context, strict bool, entityC
 		rc.MatchType = entity.MatchType
 		req.Components = append(req.Components, rc)
 	}
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
@@ -410,9 +410,10 @@ type AlterClientQuotaEntry struct {
 
 // AlteredClientQuota is the result for a single entity that was altered.
 type AlteredClientQuota struct {
-	Entity     ClientQuotaEntity // Entity is the entity this result is for.
-	Err        error             // Err is non-nil if the alter operation on this entity failed.
-	ErrMessage string            // ErrMessage is an optional additional message on error.
+	Entity       ClientQuotaEntity // Entity is the entity this result is for.
+	Err          error             // Err is non-nil if the alter operation on this entity failed.
+	ErrMessage   string            // ErrMessage is an optional additional message on error.
+	ValidateOnly bool              // ValidateOnly is true if this result is from validating the alteration; no quotas were altered.
 }
 
 // AlteredClientQuotas contains results for all altered entities.
@@ -433,7 +434,7 @@ func (cl *Client) ValidateAlterClientQuotas(ctx context.Context, entries []Alter
 
 func (cl *Client) alterClientQuotas(ctx context.Context, validate bool, entries []AlterClientQuotaEntry) (AlteredClientQuotas, error) {
 	req := kmsg.NewPtrAlterClientQuotasRequest()
-	req.ValidateOnly = validate
+	req.ValidateOnly = validate || cl.validateOnly
 	for _, entry := range entries {
 		re := kmsg.NewAlterClientQuotasRequestEntry()
 		for _, c := range entry.Entity {
@@ -451,7 +452,7 @@ func (cl *Client) alterClientQuotas(ctx context.Context, validate bool, entries
 		}
 		req.Entries = append(req.Entries, re)
 	}
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
@@ -465,9 +466,10 @@ func (cl *Client) alterClientQuotas(ctx context.Context, validate bool, entries
 			})
 		}
 		a := AlteredClientQuota{
-			Entity:     e,
-			Err:        kerr.ErrorForCode(entry.ErrorCode),
-			ErrMessage: unptrStr(entry.ErrorMessage),
+			Entity:       e,
+			Err:          kerr.ErrorForCode(entry.ErrorCode),
+			ErrMessage:   unptrStr(entry.ErrorMessage),
+			ValidateOnly: req.ValidateOnly,
 		}
 		as = append(as, a)
 	}
@@ -582,7 +584,7 @@ func (cl *Client) DescribeUserSCRAMs(ctx context.Context, users ...string) (Desc
 		ru.Name = u
 		req.Users = append(req.Users, ru)
 	}
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
@@ -630,11 +632,24 @@ type UpsertSCRAM struct {
 	SaltedPassword []byte         // SaltedPassword must be paired with Salt and requires Password to be empty.
 }
 
+// AlteredSCRAMMechanism is the result of altering one mechanism for a user.
+type AlteredSCRAMMechanism struct {
+	Mechanism ScramMechanism // Mechanism is the mechanism that was altered.
+	Deleted   bool           // Deleted is true if the password for this mechanism was requested to be deleted, false if it was upserted.
+	Err       error          // Err is any error encountered when altering this mechanism.
+}
+
 // AlteredUserSCRAM is the result of an alter operation.
 type AlteredUserSCRAM struct {
 	User       string // User is the username that was altered.
 	Err        error  // Err is any error encountered when altering the user.
 	ErrMessage string // ErrMessage a potential extra message describing any error.
+
+	// Mechanisms contains the result of every deletion and upsertion
+	// requested for this user, in the order they were requested (deletions
+	// first). Kafka returns one result per user, so if the user failed,
+	// every mechanism has the user's error.
+	Mechanisms []AlteredSCRAMMechanism
 }
 
 // AlteredUserSCRAMs contains altered user SCRAM credentials keyed by user.
@@ -691,43 +706,99 @@ func (as AlteredUserSCRAMs) Ok() bool {
 }
 
 // AlterUserSCRAMs deletes, updates, or creates (inserts) user SCRAM
-// credentials. Note that a username can only appear once across both upserts
-// and deletes. This modifies elements of the upsert slice that need to have a
+// credentials in a single request. Deletions and upsertions can be mixed
+// across many users, and a user can have multiple mechanisms altered at once,
+// but a user and mechanism can only appear once across both upserts and
+// deletes. This modifies elements of the upsert slice that need to have a
 // salted password generated.
+//
+// Every user in the input has a result. Users with an invalid upsertion (for
+// example, a missing password or an unknown mechanism when generating a
+// salted password), or with a duplicate mechanism, are not sent to Kafka and
+// have Err set in their result; all other users are still altered. Per-user
+// errors from Kafka are likewise only returned in the results, allowing
+// provisioning tools to reconcile in bulk.
+//
+// This returns an error if the request fails to be issued, or an *AuthError.
 func (cl *Client) AlterUserSCRAMs(ctx context.Context, del []DeleteSCRAM, upsert []UpsertSCRAM) (AlteredUserSCRAMs, error) {
+	rs := make(AlteredUserSCRAMs)
+	type userMech struct {
+		user string
+		mech ScramMechanism
+	}
+	seen := make(map[userMech]bool)
+	addMech := func(user string, m AlteredSCRAMMechanism) {
+		r := rs[user]
+		r.User = user
+		r.Mechanisms = append(r.Mechanisms, m)
+		if seen[userMech{user, m.Mechanism}] && r.Err == nil {
+			r.Err = fmt.Errorf("user %s: mechanism %s cannot be altered more than once in the same request", user, m.Mechanism)
+		}
+		seen[userMech{user, m.Mechanism}] = true
+		if m.Err != nil && r.Err == nil {
+			r.Err = m.Err
+		}
+		rs[user] = r
+	}
+
+	for _, d := range del {
+		addMech(d.User, AlteredSCRAMMechanism{Mechanism: d.Mechanism, Deleted: true})
+	}
 	for i, u := range upsert {
+		var err error
 		if u.Password != "" {
 			if len(u.Salt) > 0 || len(u.SaltedPassword) > 0 {
-				return nil, fmt.Errorf("user %s: cannot specify both a password and a salt / salted password", u.User)
+				err = fmt.Errorf("user %s: cannot specify both a password and a salt / salted password", u.User)
+			} else {
+				u.Salt = make([]byte, 24)
+				if _, rerr := rand.Read(u.Salt); rerr != nil {
+					err = fmt.Errorf("user %s: unable to generate salt: %v", u.User, rerr)
+				}
 			}
-			u.Salt = make([]byte, 24)
-			if _, err := rand.Read(u.Salt); err != nil {
-				return nil, fmt.Errorf("user %s: unable to generate salt: %v", u.User, err)
+			if err == nil {
+				switch u.Mechanism {
+				case ScramSha256:
+					u.SaltedPassword = pbkdf2.Key([]byte(u.Password), u.Salt, int(u.Iterations), sha256.Size, sha256.New)
+				case ScramSha512:
+					u.SaltedPassword = pbkdf2.Key([]byte(u.Password), u.Salt, int(u.Iterations), sha512.Size, sha512.New)
+				default:
+					err = fmt.Errorf("user %s: unknown mechanism, unable to generate password", u.User)
+				}
 			}
-			switch u.Mechanism {
-			case ScramSha256:
-				u.SaltedPassword = pbkdf2.Key([]byte(u.Password), u.Salt, int(u.Iterations), sha256.Size, sha256.New)
-			case ScramSha512:
-				u.SaltedPassword = pbkdf2.Key([]byte(u.Password), u.Salt, int(u.Iterations), sha512.Size, sha512.New)
-			default:
-				return nil, fmt.Errorf("user %s: unknown mechanism, unable to generate password", u.User)
+			if err == nil {
+				upsert[i] = u
 			}
-			upsert[i] = u
-		} else {
-			if len(u.Salt) == 0 || len(u.SaltedPassword) == 0 {
-				return nil, fmt.Errorf("user %s: must specify either a password or a salt and salted password", u.User)
+		} else if len(u.Salt) == 0 || len(u.SaltedPassword) == 0 {
+			err = fmt.Errorf("user %s: must specify either a password or a salt and salted password", u.User)
+		}
+		addMech(u.User, AlteredSCRAMMechanism{Mechanism: u.Mechanism, Err: err})
+	}
+
+	// Any user that failed validation is not sent; every mechanism for
+	// that user gets the user's error.
+	for user, r := range rs {
+		if r.Err != nil {
+			for i := range r.Mechanisms {
+				r.Mechanisms[i].Err = r.Err
 			}
+			rs[user] = r
 		}
 	}
 
 	req := kmsg.NewPtrAlterUserSCRAMCredentialsRequest()
 	for _, d := range del {
+		if rs[d.User].Err != nil {
+			continue
+		}
 		rd := kmsg.NewAlterUserSCRAMCredentialsRequestDeletion()
 		rd.Name = d.User
 		rd.Mechanism = int8(d.Mechanism)
 		req.Deletions = append(req.Deletions, rd)
 	}
 	for _, u := range upsert {
+		if rs[u.User].Err != nil {
+			continue
+		}
 		ru := kmsg.NewAlterUserSCRAMCredentialsRequestUpsertion()
 		ru.Name = u.User
 		ru.Mechanism = int8(u.Mechanism)
@@ -736,19 +807,23 @@ func (cl *Client) AlterUserSCRAMs(ctx context.Context, del []DeleteSCRAM, upsert
 		ru.SaltedPassword = u.SaltedPassword
 		req.Upsertions = append(req.Upsertions, ru)
 	}
-	resp, err := req.RequestWith(ctx, cl.cl)
+	if len(req.Deletions) == 0 && len(req.Upsertions) == 0 {
+		return rs, nil
+	}
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
-	rs := make(AlteredUserSCRAMs)
 	for _, res := range resp.Results {
 		if err := maybeAuthErr(res.ErrorCode); err != nil {
 			return nil, err
 		}
-		r := AlteredUserSCRAM{
-			User:       res.User,
-			Err:        kerr.ErrorForCode(res.ErrorCode),
-			ErrMessage: unptrStr(res.ErrorMessage),
+		r := rs[res.User]
+		r.User = res.User
+		r.Err = kerr.ErrorForCode(res.ErrorCode)
+		r.ErrMessage = unptrStr(res.ErrorMessage)
+		for i := range r.Mechanisms {
+			r.Mechanisms[i].Err = r.Err
 		}
 		rs[r.User] = r
 	}
@@ -802,7 +877,7 @@ func (cl *Client) ElectLeaders(ctx context.Context, how ElectLeadersHow, s Topic
 		rt.Partitions = t.Partitions
 		req.Topics = append(req.Topics, rt)
 	}
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
@@ -888,17 +963,135 @@ type OffsetForLeaderEpoch struct {
 	Err error
 }
 
+// Truncated returns whether the given consumed offset, which was consumed in
+// the requested leader epoch, is past the end offset of that epoch. If so, the
+// partition was truncated (i.e. an unclean leader election occurred) and
+// every record from EndOffset up to the consumed offset has been lost. A
+// consumer in this state should reset to EndOffset.
+//
+// This always returns false if this response has an error or if the
+// requested epoch was unknown.
+func (o OffsetForLeaderEpoch) Truncated(consumed int64) bool {
+	return o.Err == nil && o.LeaderEpoch >= 0 && o.EndOffset >= 0 && consumed > o.EndOffset
+}
+
 // OffsetsForLeaderEpochs contains responses for partitions in a
 // OffsetForLeaderEpochRequest.
 type OffsetsForLeaderEpochs map[string]map[int32]OffsetForLeaderEpoch
 
+// Lookup returns the response at t and p and whether it exists.
+func (ls OffsetsForLeaderEpochs) Lookup(t string, p int32) (OffsetForLeaderEpoch, bool) {
+	if len(ls) == 0 {
+		return OffsetForLeaderEpoch{}, false
+	}
+	ps := ls[t]
+	if len(ps) == 0 {
+		return OffsetForLeaderEpoch{}, false
+	}
+	l, exists := ps[p]
+	return l, exists
+}
+
+// Each calls fn for every response.
+func (ls OffsetsForLeaderEpochs) Each(fn func(OffsetForLeaderEpoch)) {
+	for _, ps := range ls {
+		for _, l := range ps {
+			fn(l)
+		}
+	}
+}
+
+// Sorted returns the responses sorted by topic and partition.
+func (ls OffsetsForLeaderEpochs) Sorted() []OffsetForLeaderEpoch {
+	var all []OffsetForLeaderEpoch
+	ls.Each(func(l OffsetForLeaderEpoch) { all = append(all, l) })
+	sort.Slice(all, func(i, j int) bool {
+		l, r := all[i], all[j]
+		return l.Topic < r.Topic || l.Topic == r.Topic && l.Partition < r.Partition
+	})
+	return all
+}
+
+// Error iterates over all responses and returns the first error encountered,
+// if any.
+func (ls OffsetsForLeaderEpochs) Error() error {
+	for _, ps := range ls {
+		for _, l := range ps {
+			if l.Err != nil {
+				return l.Err
+			}
+		}
+	}
+	return nil
+}
+
+// Ok returns true if there are no errors. This is a shortcut for ls.Error() ==
+// nil.
+func (ls OffsetsForLeaderEpochs) Ok() bool {
+	return ls.Error() == nil
+}
+
+// Offsets returns the end offsets of each epoch as Offsets, skipping
+// responses that have an error. This can be used to reset a consumer or group
+// to the end of the epoch after detecting truncation.
+func (ls OffsetsForLeaderEpochs) Offsets() Offsets {
+	os := make(Offsets)
+	ls.Each(func(l OffsetForLeaderEpoch) {
+		if l.Err == nil {
+			os.AddOffset(l.Topic, l.Partition, l.EndOffset, l.LeaderEpoch)
+		}
+	})
+	return os
+}
+
+// Truncated returns the offsets from os that are past the end offset of their
+// leader epoch, per OffsetForLeaderEpoch.Truncated. The input offsets are
+// expected to be the offsets that were used to build the request, with each
+// offset's LeaderEpoch being the epoch that was requested. The returned
+// offsets are the end offsets that each truncated partition should be reset
+// to.
+func (ls OffsetsForLeaderEpochs) Truncated(os Offsets) Offsets {
+	truncated := make(Offsets)
+	os.Each(func(o Offset) {
+		if l, ok := ls.Lookup(o.Topic, o.Partition); ok && l.Truncated(o.At) {
+			truncated.AddOffset(l.Topic, l.Partition, l.EndOffset, l.LeaderEpoch)
+		}
+	})
+	return truncated
+}
+
+// OffsetForLeaderEpochRequestFromOffsets returns a request for the end offset
+// of each offset's leader epoch. Offsets with a negative leader epoch are
+// skipped.
+func OffsetForLeaderEpochRequestFromOffsets(os Offsets) OffsetForLeaderEpochRequest {
+	var r OffsetForLeaderEpochRequest
+	os.Each(func(o Offset) {
+		if o.LeaderEpoch >= 0 {
+			r.Add(o.Topic, o.Partition, o.LeaderEpoch)
+		}
+	})
+	return r
+}
+
+// OffetForLeaderEpoch is a misspelling of OffsetForLeaderEpoch.
+//
+// Deprecated: use OffsetForLeaderEpoch.
+func (cl *Client) OffetForLeaderEpoch(ctx context.Context, r OffsetForLeaderEpochRequest) (OffsetsForLeaderEpochs, error) {
+	return cl.OffsetForLeaderEpoch(ctx, r)
+}
+
 // OffsetForLeaderEpoch requests end offsets for the requested leader epoch in
 // partitions in the request. This is a relatively advanced and client internal
 // request, for more details, see the doc comments on the OffsetForLeaderEpoch
 // type.
 //
+// This can be used to detect log truncation after an unclean leader election:
+// build a request from offsets that were consumed (or committed) with
+// OffsetForLeaderEpochRequestFromOffsets, and then pass those same offsets
+// to the response's Truncated method.
+//
 // This may return *ShardErrors or *AuthError.
-func (cl *Client) OffetForLeaderEpoch(ctx context.Context, r OffsetForLeaderEpochRequest) (OffsetsForLeaderEpochs, error) {
+func (cl *Client) OffsetForLeaderEpoch(ctx context.Context, r OffsetForLeaderEpochRequest) (OffsetsForLeaderEpochs, error) {
 	req := kmsg.NewPtrOffsetForLeaderEpochRequest()
 	for t, ps := range r {
 		rt := kmsg.NewOffsetForLeaderEpochRequestTopic()
@@ -911,7 +1104,7 @@ func (cl *Client) OffetForLeaderEpoch(ctx context.Context, r OffsetForLeaderEpoc
 		}
 		req.Topics = append(req.Topics, rt)
 	}
-	shards := cl.cl.RequestSharded(ctx, req)
+	shards := cl.requestSharded(ctx, req)
 	ls := make(OffsetsForLeaderEpochs)
 	return ls, shardErrEachBroker(req, shards, func(b BrokerDetail, kr kmsg.Response) error {
 		resp := kr.(*kmsg.OffsetForLeaderEpochResponse)
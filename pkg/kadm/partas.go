@@ -91,7 +91,7 @@ func (cl *Client) AlterPartitionAssignments(ctx context.Context, req AlterPartit
 		kreq.Topics = append(kreq.Topics, rt)
 	}
 
-	kresp, err := kreq.RequestWith(ctx, cl.cl)
+	kresp, err := kreq.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
@@ -158,7 +158,17 @@ func (cl *Client) ListPartitionReassignments(ctx context.Context, s TopicsSet) (
 	if len(s) == 0 {
 		return make(ListPartitionReassignmentsResponses), nil
 	}
+	return cl.listPartitionReassignments(ctx, s)
+}
+
+// ListAllPartitionReassignments lists all active reassignments in the cluster,
+// returning an error if the response could not be issued or if you do not
+// have permissions.
+func (cl *Client) ListAllPartitionReassignments(ctx context.Context) (ListPartitionReassignmentsResponses, error) {
+	return cl.listPartitionReassignments(ctx, nil)
+}
 
+func (cl *Client) listPartitionReassignments(ctx context.Context, s TopicsSet) (ListPartitionReassignmentsResponses, error) {
 	kreq := kmsg.NewPtrListPartitionReassignmentsRequest()
 	kreq.TimeoutMillis = cl.timeoutMillis
 	for t, ps := range s {
@@ -170,7 +180,7 @@ func (cl *Client) ListPartitionReassignments(ctx context.Context, s TopicsSet) (
 		kreq.Topics = append(kreq.Topics, rt)
 	}
 
-	kresp, err := kreq.RequestWith(ctx, cl.cl)
+	kresp, err := kreq.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,876 @@
+package kadm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PartitionReassignment is a proposed replica placement for a single
+// partition, as well as the partition's current replicas.
+type PartitionReassignment struct {
+	Topic     string  // Topic is the topic this reassignment is for.
+	Partition int32   // Partition is the partition this reassignment is for.
+	Current   []int32 // Current are the partition's current replicas.
+	Proposed  []int32 // Proposed are the replicas the partition should be moved to.
+}
+
+// IsMove returns whether the proposed replicas differ from the current
+// replicas. Only order is considered: a change in the preferred leader is a
+// move.
+func (r PartitionReassignment) IsMove() bool {
+	if len(r.Current) != len(r.Proposed) {
+		return true
+	}
+	for i := range r.Current {
+		if r.Current[i] != r.Proposed[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// ReassignmentPlan contains per-topic, per-partition proposed reassignments.
+type ReassignmentPlan map[string]map[int32]PartitionReassignment
+
+// Lookup returns the reassignment at t and p and whether it exists.
+func (p ReassignmentPlan) Lookup(t string, pt int32) (PartitionReassignment, bool) {
+	if len(p) == 0 {
+		return PartitionReassignment{}, false
+	}
+	ps := p[t]
+	if len(ps) == 0 {
+		return PartitionReassignment{}, false
+	}
+	r, exists := ps[pt]
+	return r, exists
+}
+
+// Each calls fn for every partition in the plan.
+func (p ReassignmentPlan) Each(fn func(PartitionReassignment)) {
+	for _, ps := range p {
+		for _, r := range ps {
+			fn(r)
+		}
+	}
+}
+
+// Sorted returns the plan sorted by topic and partition.
+func (p ReassignmentPlan) Sorted() []PartitionReassignment {
+	var all []PartitionReassignment
+	p.Each(func(r PartitionReassignment) { all = append(all, r) })
+	sort.Slice(all, func(i, j int) bool {
+		l, r := all[i], all[j]
+		return l.Topic < r.Topic || l.Topic == r.Topic && l.Partition < r.Partition
+	})
+	return all
+}
+
+// Moves returns only the partitions whose proposed replicas differ from their
+// current replicas.
+func (p ReassignmentPlan) Moves() ReassignmentPlan {
+	m := make(ReassignmentPlan)
+	p.Each(func(r PartitionReassignment) {
+		if !r.IsMove() {
+			return
+		}
+		ps := m[r.Topic]
+		if ps == nil {
+			ps = make(map[int32]PartitionReassignment)
+			m[r.Topic] = ps
+		}
+		ps[r.Partition] = r
+	})
+	return m
+}
+
+// AlterPartitionAssignmentsReq returns the partitions that need to move as an
+// AlterPartitionAssignmentsReq, suitable for AlterPartitionAssignments.
+func (p ReassignmentPlan) AlterPartitionAssignmentsReq() AlterPartitionAssignmentsReq {
+	var req AlterPartitionAssignmentsReq
+	p.Moves().Each(func(r PartitionReassignment) {
+		req.Assign(r.Topic, r.Partition, r.Proposed)
+	})
+	return req
+}
+
+// ErrMixedRacks is returned from CalculateReassignmentPlan if some, but not
+// all, of the brokers being assigned to have a rack.
+var ErrMixedRacks = errors.New("not all brokers have a rack; rack aware assignment requires all or no brokers to have a rack")
+
+// CalculateReassignmentPlan returns a balanced replica placement for every
+// partition in every topic in m, placing replicas only on the given brokers.
+// If no brokers are given, all brokers in m are used. This is the equivalent
+// of kafka-reassign-partitions --generate.
+//
+// Each partition keeps its current replication factor. If every broker has a
+// rack, replicas are spread across racks such that each partition has at most
+// one replica per rack (if the replication factor is at most the number of
+// racks), and leaders and followers are spread evenly across racks and
+// brokers. If no broker has a rack, replicas are spread evenly across
+// brokers. Brokers with some, but not all, racks set returns ErrMixedRacks.
+//
+// Topics with load errors are skipped. Placement is deterministic for a given
+// topic name and set of brokers, which allows a plan to be regenerated and
+// compared against a prior plan.
+func CalculateReassignmentPlan(m Metadata, brokers ...int32) (ReassignmentPlan, error) {
+	racks := make(map[int32]string)
+	if len(brokers) == 0 {
+		brokers = m.Brokers.NodeIDs()
+	}
+	known := make(map[int32]BrokerDetail, len(m.Brokers))
+	for _, b := range m.Brokers {
+		known[b.NodeID] = b
+	}
+	var nracked int
+	for _, id := range brokers {
+		b, ok := known[id]
+		if !ok {
+			return nil, fmt.Errorf("broker %d does not exist in the metadata", id)
+		}
+		if b.Rack != nil {
+			racks[id] = *b.Rack
+			nracked++
+		}
+	}
+	if nracked != 0 && nracked != len(brokers) {
+		return nil, ErrMixedRacks
+	}
+	if len(brokers) == 0 {
+		return nil, errors.New("no brokers to assign replicas to")
+	}
+
+	arranged := int32s(append([]int32(nil), brokers...))
+	if nracked > 0 {
+		arranged = rackAlternatedBrokers(racks)
+	}
+
+	plan := make(ReassignmentPlan)
+	for _, td := range m.Topics.Sorted() {
+		if td.Err != nil || len(td.Partitions) == 0 {
+			continue
+		}
+		rf := td.Partitions.NumReplicas()
+		if rf > len(arranged) {
+			return nil, fmt.Errorf("topic %s has replication factor %d, which is larger than the %d brokers available", td.Topic, rf, len(arranged))
+		}
+		var assigned [][]int32
+		if nracked > 0 {
+			assigned = assignRackAware(td.Topic, len(td.Partitions), rf, arranged, racks)
+		} else {
+			assigned = assignRackUnaware(td.Topic, len(td.Partitions), rf, arranged)
+		}
+
+		ps := make(map[int32]PartitionReassignment, len(td.Partitions))
+		for i, p := range td.Partitions.Numbers() {
+			ps[p] = PartitionReassignment{
+				Topic:     td.Topic,
+				Partition: p,
+				Current:   td.Partitions[p].Replicas,
+				Proposed:  assigned[i],
+			}
+		}
+		plan[td.Topic] = ps
+	}
+	return plan, nil
+}
+
+// PlanReassignment issues a metadata request for the given topics and returns
+// a balanced plan placing all replicas only on the given brokers. If no
+// brokers are given, all brokers are used. If no topics are given, all
+// non-internal topics are planned. See CalculateReassignmentPlan for more
+// details.
+//
+// The returned plan is not applied. To apply the plan, use
+// AlterPartitionAssignments with the plan's AlterPartitionAssignmentsReq.
+//
+// This returns an error if the metadata request fails, or an *AuthError.
+func (cl *Client) PlanReassignment(ctx context.Context, brokers []int32, topics ...string) (ReassignmentPlan, error) {
+	m, err := cl.Metadata(ctx, topics...)
+	if err != nil {
+		return nil, err
+	}
+	if len(topics) == 0 {
+		m.Topics.FilterInternal()
+	}
+	return CalculateReassignmentPlan(m, brokers...)
+}
+
+// DrainBrokerBuilder helps build a broker drain, rather than having a
+// function signature with many optional arguments.
+//
+// All functions on this type accept and return the same pointer, allowing
+// for easy build-and-use usage.
+type DrainBrokerBuilder struct {
+	node       int32
+	throttle   int64
+	poll       time.Duration
+	noWait     bool
+	onProgress func(DrainProgress)
+}
+
+// DrainBroker returns a DrainBrokerBuilder for the input broker.
+func DrainBroker(node int32) *DrainBrokerBuilder {
+	return &DrainBrokerBuilder{
+		node: node,
+		poll: time.Second,
+	}
+}
+
+// Throttle sets the leader and follower replication throttle, in bytes per
+// second, to use on every broker involved in the drain. Throttles are only set
+// for the replicas that are moving, and are removed once the drain completes.
+// If the drain does not complete (the context is canceled, or NoWait is used),
+// the throttles are left in place and must be removed manually.
+func (b *DrainBrokerBuilder) Throttle(bytesPerSec int64) *DrainBrokerBuilder {
+	b.throttle = bytesPerSec
+	return b
+}
+
+// PollInterval sets how often to check reassignment progress, overriding the
+// default of 1s.
+func (b *DrainBrokerBuilder) PollInterval(interval time.Duration) *DrainBrokerBuilder {
+	b.poll = interval
+	return b
+}
+
+// NoWait returns as soon as the reassignments are submitted, rather than
+// waiting for all reassignments to complete.
+func (b *DrainBrokerBuilder) NoWait() *DrainBrokerBuilder {
+	b.noWait = true
+	return b
+}
+
+// OnProgress sets a function to call after every poll of reassignment
+// progress.
+func (b *DrainBrokerBuilder) OnProgress(fn func(DrainProgress)) *DrainBrokerBuilder {
+	b.onProgress = fn
+	return b
+}
+
+// DrainProgress is the progress of a broker drain.
+type DrainProgress struct {
+	Node      int32     // Node is the broker being drained.
+	Total     int       // Total is the number of partitions being moved off the broker.
+	Remaining TopicsSet // Remaining contains the partitions that are still being reassigned.
+
+	// Reassignment contains detailed progress of the drain, such as bytes
+	// copied, an ETA, and stalled partitions.
+	Reassignment ReassignmentProgress
+}
+
+// Done returns whether all partitions have been moved off the broker.
+func (p DrainProgress) Done() bool {
+	return len(p.Remaining) == 0
+}
+
+// DrainBroker moves every replica off of a broker onto the remaining brokers
+// in the cluster, so that the broker can be removed. Each replica on the
+// drained broker is replaced with a broker that does not already host the
+// partition, preferring a broker in the same rack as the drained broker and
+// then the broker with the fewest replicas. The position of the replica in
+// the partition's replica list is kept, meaning the replacement for a
+// preferred leader becomes the new preferred leader.
+//
+// By default, this waits until all reassignments complete, polling every
+// second. This returns the plan that was submitted, which may be empty if the
+// broker has no replicas.
+//
+// This returns an error if any request fails, if any partition fails to be
+// reassigned, or if there are not enough remaining brokers to keep every
+// partition's replication factor.
+func (cl *Client) DrainBroker(ctx context.Context, b *DrainBrokerBuilder) (ReassignmentPlan, error) {
+	m, err := cl.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := drainPlan(m, b.node)
+	if err != nil {
+		return nil, err
+	}
+	if len(plan) == 0 {
+		return plan, nil
+	}
+
+	if b.throttle > 0 {
+		if err := cl.setDrainThrottles(ctx, plan, b.throttle); err != nil {
+			return nil, err
+		}
+	}
+
+	resps, err := cl.AlterPartitionAssignments(ctx, plan.AlterPartitionAssignmentsReq())
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resps.Sorted() {
+		if r.Err != nil {
+			return nil, fmt.Errorf("unable to reassign %s[%d]: %w", r.Topic, r.Partition, r.Err)
+		}
+	}
+	if b.noWait {
+		return plan, nil
+	}
+
+	s := make(TopicsSet)
+	plan.Each(func(r PartitionReassignment) { s.Add(r.Topic, r.Partition) })
+	err = cl.MonitorReassignments(ctx, MonitorReassignments(s).PollInterval(b.poll), func(p ReassignmentProgress) {
+		if b.onProgress == nil {
+			return
+		}
+		remaining := make(TopicsSet)
+		for _, r := range p.Remaining {
+			remaining.Add(r.Topic, r.Partition)
+		}
+		b.onProgress(DrainProgress{
+			Node:         b.node,
+			Total:        p.Total,
+			Remaining:    remaining,
+			Reassignment: p,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if b.throttle > 0 {
+		if err := cl.removeDrainThrottles(ctx, plan); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// MonitorReassignmentsBuilder configures how Client.MonitorReassignments
+// watches reassignments.
+//
+// All functions on this type accept and return the same pointer, allowing
+// for easy build-and-use usage.
+type MonitorReassignmentsBuilder struct {
+	s          TopicsSet
+	poll       time.Duration
+	stallAfter time.Duration
+}
+
+// MonitorReassignments returns a MonitorReassignmentsBuilder for the given
+// partitions. If the set is empty, every reassignment that is active in the
+// cluster while monitoring is watched.
+func MonitorReassignments(s TopicsSet) *MonitorReassignmentsBuilder {
+	return &MonitorReassignmentsBuilder{
+		s:          s,
+		poll:       time.Second,
+		stallAfter: 5 * time.Minute,
+	}
+}
+
+// PollInterval sets how often to check reassignment progress, overriding the
+// default of 1s.
+func (b *MonitorReassignmentsBuilder) PollInterval(interval time.Duration) *MonitorReassignmentsBuilder {
+	b.poll = interval
+	return b
+}
+
+// StallAfter sets how long a partition can go without copying any data before
+// it is considered stalled, overriding the default of 5m.
+func (b *MonitorReassignmentsBuilder) StallAfter(d time.Duration) *MonitorReassignmentsBuilder {
+	b.stallAfter = d
+	return b
+}
+
+// PartitionReassignmentProgress is the progress of an individual partition
+// that is being reassigned.
+type PartitionReassignmentProgress struct {
+	Topic            string  // Topic is the topic being reassigned.
+	Partition        int32   // Partition is the partition being reassigned.
+	Replicas         []int32 // Replicas are the partition's current replicas.
+	AddingReplicas   []int32 // AddingReplicas are replicas currently being added to the partition.
+	RemovingReplicas []int32 // RemovingReplicas are replicas currently being removed from the partition.
+
+	// Bytes is the size of the partition on its largest existing (not
+	// adding) replica, or 0 if the size could not be described.
+	Bytes int64
+
+	// CopiedBytes is the size of the partition on the adding replica that
+	// is furthest behind, or 0 if the size could not be described.
+	CopiedBytes int64
+
+	// Stalled is whether no data has been copied to any adding replica
+	// within the monitor's StallAfter duration.
+	Stalled bool
+}
+
+// ReassignmentProgress is the progress of reassignments, as returned after
+// every poll from Client.MonitorReassignments.
+type ReassignmentProgress struct {
+	Elapsed time.Duration // Elapsed is how long reassignments have been monitored.
+
+	Total     int                             // Total is the number of partitions seen reassigning, including completed partitions.
+	Remaining []PartitionReassignmentProgress // Remaining contains partitions still being reassigned, sorted by topic and partition.
+
+	Bytes       int64 // Bytes is the total size of all partitions seen reassigning.
+	CopiedBytes int64 // CopiedBytes is how much of Bytes has been copied, counting completed partitions as fully copied.
+
+	// ETA is the estimated time remaining, based on the rate that bytes
+	// have been copied while monitoring. This is zero if the rate is not
+	// yet known.
+	ETA time.Duration
+}
+
+// Done returns whether all reassignments have completed.
+func (p ReassignmentProgress) Done() bool {
+	return len(p.Remaining) == 0
+}
+
+// Percent returns the percent complete, from 0 to 100. This is based on
+// bytes copied if sizes are known, and otherwise on the number of completed
+// partitions.
+func (p ReassignmentProgress) Percent() float64 {
+	if p.Done() {
+		return 100
+	}
+	if p.Bytes > 0 {
+		return 100 * float64(p.CopiedBytes) / float64(p.Bytes)
+	}
+	if p.Total == 0 {
+		return 0
+	}
+	return 100 * float64(p.Total-len(p.Remaining)) / float64(p.Total)
+}
+
+// Stalled returns the remaining partitions that are stalled.
+func (p ReassignmentProgress) Stalled() []PartitionReassignmentProgress {
+	var stalled []PartitionReassignmentProgress
+	for _, r := range p.Remaining {
+		if r.Stalled {
+			stalled = append(stalled, r)
+		}
+	}
+	return stalled
+}
+
+// MonitorReassignments polls ListPartitionReassignments and the log
+// directories of the replicas being added, calling fn with the progress after
+// every poll, until all monitored reassignments complete. Sizes are described
+// only for partitions still being reassigned; if describing log directories
+// fails on some brokers, progress is still reported with the sizes that could
+// be described.
+//
+// This returns nil once every reassignment completes, or an error if listing
+// reassignments fails or the context is canceled.
+func (cl *Client) MonitorReassignments(ctx context.Context, b *MonitorReassignmentsBuilder, fn func(ReassignmentProgress)) error {
+	type tracked struct {
+		bytes      int64
+		copied     int64
+		lastChange time.Time
+	}
+	var (
+		start      = time.Now()
+		seen       = make(map[string]map[int32]*tracked)
+		doneBytes  int64
+		completed  int
+		startBytes = int64(-1)
+	)
+	for {
+		var (
+			listed ListPartitionReassignmentsResponses
+			err    error
+		)
+		if len(b.s) > 0 {
+			listed, err = cl.ListPartitionReassignments(ctx, b.s)
+		} else {
+			listed, err = cl.ListAllPartitionReassignments(ctx)
+		}
+		if err != nil {
+			return err
+		}
+
+		remaining := make(TopicsSet)
+		listed.Each(func(r ListPartitionReassignmentsResponse) { remaining.Add(r.Topic, r.Partition) })
+		var dirs DescribedAllLogDirs
+		if len(remaining) > 0 {
+			dirs, _ = cl.DescribeAllLogDirs(ctx, remaining) // best effort; sizes are optional
+		}
+		size := func(broker int32, t string, p int32) (int64, bool) {
+			if d, ok := dirs[broker].LookupPartition(t, p); ok {
+				return d.Size, true
+			}
+			return 0, false
+		}
+
+		now := time.Now()
+		progress := ReassignmentProgress{Elapsed: now.Sub(start)}
+		for _, r := range listed.Sorted() {
+			pr := PartitionReassignmentProgress{
+				Topic:            r.Topic,
+				Partition:        r.Partition,
+				Replicas:         r.Replicas,
+				AddingReplicas:   r.AddingReplicas,
+				RemovingReplicas: r.RemovingReplicas,
+			}
+			adding := make(map[int32]bool, len(r.AddingReplicas))
+			for _, a := range r.AddingReplicas {
+				adding[a] = true
+			}
+			for _, replica := range r.Replicas {
+				if sz, ok := size(replica, r.Topic, r.Partition); ok && !adding[replica] && sz > pr.Bytes {
+					pr.Bytes = sz
+				}
+			}
+			pr.CopiedBytes = -1
+			for _, a := range r.AddingReplicas {
+				sz, _ := size(a, r.Topic, r.Partition)
+				if pr.CopiedBytes < 0 || sz < pr.CopiedBytes {
+					pr.CopiedBytes = sz
+				}
+			}
+			if pr.CopiedBytes < 0 || pr.CopiedBytes > pr.Bytes {
+				pr.CopiedBytes = pr.Bytes
+			}
+
+			ps := seen[r.Topic]
+			if ps == nil {
+				ps = make(map[int32]*tracked)
+				seen[r.Topic] = ps
+			}
+			t := ps[r.Partition]
+			if t == nil {
+				t = &tracked{lastChange: now}
+				ps[r.Partition] = t
+			}
+			if pr.Bytes > t.bytes {
+				t.bytes = pr.Bytes
+			}
+			if pr.CopiedBytes != t.copied {
+				t.copied = pr.CopiedBytes
+				t.lastChange = now
+			}
+			pr.Stalled = now.Sub(t.lastChange) >= b.stallAfter
+
+			progress.Bytes += t.bytes
+			progress.CopiedBytes += t.copied
+			progress.Remaining = append(progress.Remaining, pr)
+		}
+
+		// Any partition we saw that is no longer reassigning is done.
+		for topic, ps := range seen {
+			for p, t := range ps {
+				if _, ok := remaining[topic][p]; !ok {
+					doneBytes += t.bytes
+					completed++
+					delete(ps, p)
+				}
+			}
+		}
+		if len(b.s) > 0 {
+			b.s.Each(func(string, int32) { progress.Total++ })
+		} else {
+			progress.Total = completed + len(progress.Remaining)
+		}
+		progress.Bytes += doneBytes
+		progress.CopiedBytes += doneBytes
+
+		if startBytes < 0 {
+			startBytes = progress.CopiedBytes
+		}
+		if copied := progress.CopiedBytes - startBytes; copied > 0 && !progress.Done() {
+			rate := float64(copied) / float64(progress.Elapsed)
+			progress.ETA = time.Duration(float64(progress.Bytes-progress.CopiedBytes) / rate)
+		}
+
+		if fn != nil {
+			fn(progress)
+		}
+		if progress.Done() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(b.poll):
+		}
+	}
+}
+
+// drainPlan returns the minimal plan that moves every replica off of node.
+func drainPlan(m Metadata, node int32) (ReassignmentPlan, error) {
+	var rack *string
+	loads := make(map[int32]int)
+	for _, b := range m.Brokers {
+		if b.NodeID == node {
+			rack = b.Rack
+			continue
+		}
+		loads[b.NodeID] = 0
+	}
+	m.Topics.EachPartition(func(p PartitionDetail) {
+		for _, r := range p.Replicas {
+			if _, ok := loads[r]; ok {
+				loads[r]++
+			}
+		}
+	})
+	racks := make(map[int32]*string)
+	for _, b := range m.Brokers {
+		racks[b.NodeID] = b.Rack
+	}
+	sameRack := func(b int32) bool {
+		return rack != nil && racks[b] != nil && *racks[b] == *rack
+	}
+
+	plan := make(ReassignmentPlan)
+	for _, td := range m.Topics.Sorted() {
+		for _, p := range td.Partitions.Sorted() {
+			idx := -1
+			for i, r := range p.Replicas {
+				if r == node {
+					idx = i
+				}
+			}
+			if idx < 0 {
+				continue
+			}
+
+			var (
+				pick    int32
+				found   bool
+				current = make(map[int32]bool, len(p.Replicas))
+			)
+			for _, r := range p.Replicas {
+				current[r] = true
+			}
+			for _, b := range m.Brokers.NodeIDs() {
+				if _, ok := loads[b]; !ok || current[b] {
+					continue
+				}
+				if !found ||
+					sameRack(b) && !sameRack(pick) ||
+					sameRack(b) == sameRack(pick) && loads[b] < loads[pick] {
+					pick, found = b, true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("no broker available to replace broker %d in %s[%d]", node, p.Topic, p.Partition)
+			}
+			loads[pick]++
+
+			proposed := append([]int32(nil), p.Replicas...)
+			proposed[idx] = pick
+			ps := plan[p.Topic]
+			if ps == nil {
+				ps = make(map[int32]PartitionReassignment)
+				plan[p.Topic] = ps
+			}
+			ps[p.Partition] = PartitionReassignment{
+				Topic:     p.Topic,
+				Partition: p.Partition,
+				Current:   p.Replicas,
+				Proposed:  proposed,
+			}
+		}
+	}
+	return plan, nil
+}
+
+const (
+	leaderThrottleRate       = "leader.replication.throttled.rate"
+	followerThrottleRate     = "follower.replication.throttled.rate"
+	leaderThrottledReplicas  = "leader.replication.throttled.replicas"
+	followerThrottledReplica = "follower.replication.throttled.replicas"
+)
+
+// setDrainThrottles sets the throttle rate on every broker involved in the
+// plan, throttles the current replicas as leaders, and throttles the new
+// replicas as followers. This is the same as kafka-reassign-partitions
+// --throttle.
+func (cl *Client) setDrainThrottles(ctx context.Context, plan ReassignmentPlan, bytesPerSec int64) error {
+	brokers := make(map[int32]struct{})
+	for t, ps := range plan {
+		var leaders, followers []string
+		for _, r := range ps {
+			current := make(map[int32]bool)
+			for _, b := range r.Current {
+				brokers[b] = struct{}{}
+				current[b] = true
+				leaders = append(leaders, fmt.Sprintf("%d:%d", r.Partition, b))
+			}
+			for _, b := range r.Proposed {
+				brokers[b] = struct{}{}
+				if !current[b] {
+					followers = append(followers, fmt.Sprintf("%d:%d", r.Partition, b))
+				}
+			}
+		}
+		sort.Strings(leaders)
+		sort.Strings(followers)
+		resps, err := cl.AlterTopicConfigs(ctx, []AlterConfig{
+			{Name: leaderThrottledReplicas, Value: StringPtr(strings.Join(leaders, ","))},
+			{Name: followerThrottledReplica, Value: StringPtr(strings.Join(followers, ","))},
+		}, t)
+		if err = alterConfigsErr(resps, err); err != nil {
+			return err
+		}
+	}
+
+	var ids []int32
+	for b := range brokers {
+		ids = append(ids, b)
+	}
+	rate := StringPtr(strconv.FormatInt(bytesPerSec, 10))
+	resps, err := cl.AlterBrokerConfigs(ctx, []AlterConfig{
+		{Name: leaderThrottleRate, Value: rate},
+		{Name: followerThrottleRate, Value: rate},
+	}, int32s(ids)...)
+	return alterConfigsErr(resps, err)
+}
+
+// removeDrainThrottles removes all throttles that setDrainThrottles set.
+func (cl *Client) removeDrainThrottles(ctx context.Context, plan ReassignmentPlan) error {
+	var topics []string
+	brokers := make(map[int32]struct{})
+	for t, ps := range plan {
+		topics = append(topics, t)
+		for _, r := range ps {
+			for _, b := range r.Current {
+				brokers[b] = struct{}{}
+			}
+			for _, b := range r.Proposed {
+				brokers[b] = struct{}{}
+			}
+		}
+	}
+	resps, err := cl.AlterTopicConfigs(ctx, []AlterConfig{
+		{Op: DeleteConfig, Name: leaderThrottledReplicas},
+		{Op: DeleteConfig, Name: followerThrottledReplica},
+	}, topics...)
+	if err = alterConfigsErr(resps, err); err != nil {
+		return err
+	}
+
+	var ids []int32
+	for b := range brokers {
+		ids = append(ids, b)
+	}
+	resps, err = cl.AlterBrokerConfigs(ctx, []AlterConfig{
+		{Op: DeleteConfig, Name: leaderThrottleRate},
+		{Op: DeleteConfig, Name: followerThrottleRate},
+	}, int32s(ids)...)
+	return alterConfigsErr(resps, err)
+}
+
+func alterConfigsErr(resps AlterConfigsResponses, err error) error {
+	if err != nil {
+		return err
+	}
+	for _, r := range resps {
+		if r.Err != nil {
+			return fmt.Errorf("unable to alter configs for %q: %w", r.Name, r.Err)
+		}
+	}
+	return nil
+}
+
+// The functions below mirror Kafka's AdminUtils placement. The only
+// difference is that the start index is chosen from a hash of the topic name
+// rather than randomly, so that plans are stable.
+
+func assignStartIndex(topic string, nbrokers int) int {
+	h := fnv.New32a()
+	h.Write([]byte(topic))
+	return int(h.Sum32() % uint32(nbrokers))
+}
+
+func replicaIndex(first, shift, replica, nbrokers int) int {
+	s := 1 + (shift+replica)%(nbrokers-1)
+	return (first + s) % nbrokers
+}
+
+func assignRackUnaware(topic string, npartitions, rf int, brokers []int32) [][]int32 {
+	var (
+		n     = len(brokers)
+		start = assignStartIndex(topic, n)
+		shift = start
+		r     = make([][]int32, 0, npartitions)
+	)
+	for p := 0; p < npartitions; p++ {
+		if p > 0 && p%n == 0 {
+			shift++
+		}
+		first := (p + start) % n
+		replicas := []int32{brokers[first]}
+		for j := 0; j < rf-1; j++ {
+			replicas = append(replicas, brokers[replicaIndex(first, shift, j, n)])
+		}
+		r = append(r, replicas)
+	}
+	return r
+}
+
+func assignRackAware(topic string, npartitions, rf int, arranged []int32, racks map[int32]string) [][]int32 {
+	nracks := make(map[string]struct{})
+	for _, rack := range racks {
+		nracks[rack] = struct{}{}
+	}
+	var (
+		n     = len(arranged)
+		start = assignStartIndex(topic, n)
+		shift = start
+		r     = make([][]int32, 0, npartitions)
+	)
+	for p := 0; p < npartitions; p++ {
+		if p > 0 && p%n == 0 {
+			shift++
+		}
+		first := (p + start) % n
+		leader := arranged[first]
+		replicas := []int32{leader}
+		withRacks := map[string]struct{}{racks[leader]: {}}
+		withBrokers := map[int32]struct{}{leader: {}}
+		var k int
+		for j := 0; j < rf-1; j++ {
+			for {
+				b := arranged[replicaIndex(first, shift*len(nracks), k, n)]
+				k++
+				_, hasRack := withRacks[racks[b]]
+				_, hasBroker := withBrokers[b]
+				if (!hasRack || len(withRacks) == len(nracks)) && (!hasBroker || len(withBrokers) == n) {
+					replicas = append(replicas, b)
+					withRacks[racks[b]] = struct{}{}
+					withBrokers[b] = struct{}{}
+					break
+				}
+			}
+		}
+		r = append(r, replicas)
+	}
+	return r
+}
+
+// rackAlternatedBrokers returns brokers ordered such that each successive
+// broker is on a different rack, i.e. rack1 broker, rack2 broker, rack3
+// broker, rack1 broker, etc.
+func rackAlternatedBrokers(racks map[int32]string) []int32 {
+	byRack := make(map[string][]int32)
+	for b, rack := range racks {
+		byRack[rack] = append(byRack[rack], b)
+	}
+	names := make([]string, 0, len(byRack))
+	for rack, bs := range byRack {
+		int32s(bs)
+		names = append(names, rack)
+	}
+	sort.Strings(names)
+
+	arranged := make([]int32, 0, len(racks))
+	for i := 0; len(arranged) < len(racks); i++ {
+		rack := names[i%len(names)]
+		if bs := byRack[rack]; len(bs) > 0 {
+			arranged = append(arranged, bs[0])
+			byRack[rack] = bs[1:]
+		}
+	}
+	return arranged
+}
@@ -0,0 +1,333 @@
+package kadm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// RetryPolicy controls how the admin client retries requests that fail.
+//
+// The underlying *kgo.Client already retries requests internally according to
+// its own RequestRetries and RetryBackoffFn options. A RetryPolicy is applied
+// on top of that: if a request (or a shard of a sharded request) still fails
+// after the kgo client gives up, the admin client can retry the failed
+// request again. This is useful for admin tooling that wants to ride out
+// longer disruptions, such as a rolling broker restart, without changing the
+// options of a *kgo.Client that is shared with producing or consuming.
+//
+// The policy applies to request-level errors (errors returned from issuing a
+// request, or errors for a shard of a request) and to a retriable top level
+// error code in a response. For requests that only read, such as Metadata,
+// ListOffsets, or DescribeConfigs, the policy also applies to retriable error
+// codes nested inside a response, such as a per-topic or per-partition error
+// code (see kerr.IsRetriable). Requests that modify the cluster are not
+// retried for nested error codes: the request may have been partially
+// applied, and reissuing it would apply the successful pieces twice. If
+// ShouldRetry agrees to retry, the entire request (or shard) is reissued.
+// Once retries are exhausted, the last response is returned as is.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of times to retry a failing
+	// request. Zero disables retries at the admin client level.
+	MaxRetries int
+
+	// Backoff returns how long to wait before the given retry, which
+	// starts at 1. If nil, DefaultRetryBackoff is used.
+	Backoff func(retry int) time.Duration
+
+	// ShouldRetry returns whether a request that failed with err should be
+	// retried. If nil, DefaultShouldRetry is used. To retry only specific
+	// error codes, see RetryErrors.
+	ShouldRetry func(req kmsg.Request, err error) bool
+}
+
+// DefaultRetryBackoff is the default backoff for a RetryPolicy: exponential
+// backoff starting at 250ms and capped at 5s.
+func DefaultRetryBackoff(retry int) time.Duration {
+	backoff := 250 * time.Millisecond
+	for i := 1; i < retry && backoff < 5*time.Second; i++ {
+		backoff *= 2
+	}
+	if backoff > 5*time.Second {
+		backoff = 5 * time.Second
+	}
+	return backoff
+}
+
+// DefaultShouldRetry is the default retry decider for a RetryPolicy. This
+// retries retriable Kafka errors (see kerr.IsRetriable), coordinator errors,
+// and network errors. Context cancellation and client closing are never
+// retried.
+func DefaultShouldRetry(_ kmsg.Request, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, kgo.ErrClientClosed) {
+		return false
+	}
+	var ne net.Error
+	return kerr.IsRetriable(err) ||
+		errors.As(err, &ne) ||
+		errors.Is(err, kerr.NotCoordinator) ||
+		errors.Is(err, kerr.CoordinatorNotAvailable) ||
+		errors.Is(err, kerr.CoordinatorLoadInProgress)
+}
+
+// RetryErrors returns a ShouldRetry function for a RetryPolicy that retries
+// only the given errors, which are usually *kerr.Error values:
+//
+//	kadm.RetryErrors(kerr.CoordinatorLoadInProgress, kerr.NotController)
+//
+// Context cancellation and client closing are never retried.
+func RetryErrors(errs ...error) func(kmsg.Request, error) bool {
+	return func(_ kmsg.Request, err error) bool {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, kgo.ErrClientClosed) {
+			return false
+		}
+		for _, retry := range errs {
+			if errors.Is(err, retry) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Retry sets the retry policy to use for all requests issued by the client
+// returned from WithOpts. By default, the admin client does not retry beyond
+// what the underlying *kgo.Client retries.
+//
+//	adm := kadm.NewClient(cl).WithOpts(kadm.Retry(kadm.RetryPolicy{MaxRetries: 5}))
+func Retry(p RetryPolicy) RequestOpt {
+	if p.Backoff == nil {
+		p.Backoff = DefaultRetryBackoff
+	}
+	if p.ShouldRetry == nil {
+		p.ShouldRetry = DefaultShouldRetry
+	}
+	return requestOpt{func(cl *Client) { cl.retry = p }}
+}
+
+// wait waits for the backoff of the given retry, returning false if the
+// context is canceled first.
+func (p *RetryPolicy) wait(ctx context.Context, retry int) bool {
+	after := time.NewTimer(p.Backoff(retry))
+	defer after.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-after.C:
+		return true
+	}
+}
+
+// retrier issues requests to a kmsg.Requestor (the *kgo.Client or a
+// *kgo.Broker), retrying per the admin client's retry policy.
+type retrier struct {
+	cl *Client
+	r  kmsg.Requestor
+}
+
+func (r retrier) Request(ctx context.Context, req kmsg.Request) (kmsg.Response, error) {
+	if err := r.cl.checkValidateOnly(req); err != nil {
+		return nil, err
+	}
+	ctx, cancel := r.cl.reqCtx(ctx)
+	defer cancel()
+	p := &r.cl.retry
+	for retry := 0; ; retry++ {
+		resp, err := r.r.Request(ctx, req)
+		if retry >= p.MaxRetries || !p.shouldRetry(req, resp, err) || !p.wait(ctx, retry+1) {
+			return resp, err
+		}
+	}
+}
+
+// shouldRetry returns whether a request should be retried, given the
+// request's error or, if the request succeeded, the first retriable error
+// code inside the response.
+func (p *RetryPolicy) shouldRetry(req kmsg.Request, resp kmsg.Response, err error) bool {
+	if err == nil {
+		if err = retriableRespErr(req, resp); err == nil {
+			return false
+		}
+	}
+	return p.ShouldRetry(req, err)
+}
+
+// idempotentKeys are the requests that only read from the cluster, and thus
+// can be reissued if any piece of the response has a retriable error.
+var idempotentKeys = map[kmsg.Key]bool{
+	kmsg.Fetch:                        true,
+	kmsg.ListOffsets:                  true,
+	kmsg.Metadata:                     true,
+	kmsg.OffsetFetch:                  true,
+	kmsg.FindCoordinator:              true,
+	kmsg.DescribeGroups:               true,
+	kmsg.ListGroups:                   true,
+	kmsg.ApiVersions:                  true,
+	kmsg.OffsetForLeaderEpoch:         true,
+	kmsg.DescribeACLs:                 true,
+	kmsg.DescribeConfigs:              true,
+	kmsg.DescribeLogDirs:              true,
+	kmsg.DescribeDelegationToken:      true,
+	kmsg.ListPartitionReassignments:   true,
+	kmsg.DescribeClientQuotas:         true,
+	kmsg.DescribeUserSCRAMCredentials: true,
+	kmsg.DescribeQuorum:               true,
+	kmsg.DescribeCluster:              true,
+	kmsg.DescribeProducers:            true,
+	kmsg.DescribeTransactions:         true,
+	kmsg.ListTransactions:             true,
+	kmsg.ConsumerGroupDescribe:        true,
+	kmsg.DescribeTopicPartitions:      true,
+}
+
+// checkValidateOnly returns ErrValidateOnlyUnsupported if the client has the
+// ValidateOnly option and the request would modify the cluster without
+// support for only validating. Requests that only read are always allowed.
+func (cl *Client) checkValidateOnly(req kmsg.Request) error {
+	if !cl.validateOnly || idempotentKeys[kmsg.Key(req.Key())] {
+		return nil
+	}
+	if f := reflect.Indirect(reflect.ValueOf(req)).FieldByName("ValidateOnly"); f.IsValid() && f.Kind() == reflect.Bool && f.Bool() {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrValidateOnlyUnsupported, kmsg.NameForKey(req.Key()))
+}
+
+// retriableRespErr returns the first retriable error for an ErrorCode field
+// in the response. For requests that only read, this checks every depth (top
+// level, per topic, per partition, ...); otherwise, this only checks the top
+// level error code.
+func retriableRespErr(req kmsg.Request, resp kmsg.Response) error {
+	if resp == nil {
+		return nil
+	}
+	v := reflect.ValueOf(resp)
+	if idempotentKeys[kmsg.Key(req.Key())] {
+		return retriableErrIn(v)
+	}
+	if f := reflect.Indirect(v).FieldByName("ErrorCode"); f.IsValid() && f.Kind() == reflect.Int16 {
+		if err := kerr.ErrorForCode(int16(f.Int())); kerr.IsRetriable(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func retriableErrIn(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return retriableErrIn(v.Elem())
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := retriableErrIn(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Field(i)
+			if t.Field(i).Name == "ErrorCode" && f.Kind() == reflect.Int16 {
+				if err := kerr.ErrorForCode(int16(f.Int())); kerr.IsRetriable(err) {
+					return err
+				}
+				continue
+			}
+			if err := retriableErrIn(f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reqCtx returns the context to use for a request, applying any Timeout
+// option.
+func (cl *Client) reqCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	if cl.reqTimeout > 0 {
+		return context.WithTimeout(ctx, cl.reqTimeout)
+	}
+	return ctx, func() {}
+}
+
+// requestor returns a kmsg.Requestor that issues requests through the
+// underlying client (or to the Broker option's broker) according to the
+// retry policy.
+func (cl *Client) requestor() kmsg.Requestor {
+	if cl.toBroker {
+		return cl.brokerRequestor(cl.broker)
+	}
+	return retrier{cl, cl.cl}
+}
+
+// brokerRequestor returns a kmsg.Requestor that issues requests directly to
+// the given broker according to the retry policy.
+func (cl *Client) brokerRequestor(broker int32) kmsg.Requestor {
+	return retrier{cl, brokerRetriable{cl.cl.Broker(int(broker))}}
+}
+
+// brokerRetriable issues RetriableRequest on a broker, allowing the kgo
+// client to retry on connection failures before we apply our own policy.
+type brokerRetriable struct{ b *kgo.Broker }
+
+func (b brokerRetriable) Request(ctx context.Context, req kmsg.Request) (kmsg.Response, error) {
+	return b.b.RetriableRequest(ctx, req)
+}
+
+// requestSharded issues a sharded request, retrying any failed shards
+// according to the retry policy. Successful shards are kept from every
+// attempt, and only the pieces of the request that failed are reissued.
+//
+// If the client has the Broker option, the entire request is issued to that
+// broker as a single shard.
+func (cl *Client) requestSharded(ctx context.Context, req kmsg.Request) []kgo.ResponseShard {
+	if err := cl.checkValidateOnly(req); err != nil {
+		return []kgo.ResponseShard{{
+			Meta: kgo.BrokerMetadata{NodeID: -1},
+			Req:  req,
+			Err:  err,
+		}}
+	}
+	if cl.toBroker {
+		resp, err := cl.brokerRequestor(cl.broker).Request(ctx, req)
+		return []kgo.ResponseShard{{
+			Meta: kgo.BrokerMetadata{NodeID: cl.broker},
+			Req:  req,
+			Resp: resp,
+			Err:  err,
+		}}
+	}
+
+	ctx, cancel := cl.reqCtx(ctx)
+	defer cancel()
+	shards := cl.cl.RequestSharded(ctx, req)
+	p := &cl.retry
+	for retry := 1; retry <= p.MaxRetries; retry++ {
+		var ok, failed []kgo.ResponseShard
+		for _, shard := range shards {
+			if p.shouldRetry(shard.Req, shard.Resp, shard.Err) {
+				failed = append(failed, shard)
+			} else {
+				ok = append(ok, shard)
+			}
+		}
+		if len(failed) == 0 || !p.wait(ctx, retry) {
+			return shards
+		}
+		for _, shard := range failed {
+			ok = append(ok, cl.cl.RequestSharded(ctx, shard.Req)...)
+		}
+		shards = ok
+	}
+	return shards
+}
@@ -0,0 +1,266 @@
+package kadm
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// anonymousUser is the user name of Kafka's principal for unauthenticated
+// connections, User:ANONYMOUS.
+const anonymousUser = "ANONYMOUS"
+
+// BrokerThrottle contains throttles a broker recently applied to a client.
+type BrokerThrottle struct {
+	Broker int32 // Broker is the broker that throttled the client.
+
+	Count int           // Count is the number of throttled responses within the tracker's window.
+	Last  time.Duration // Last is the most recent throttle.
+	Max   time.Duration // Max is the longest throttle within the tracker's window.
+	Total time.Duration // Total is the sum of all throttles within the tracker's window.
+
+	LastAt time.Time // LastAt is when the most recent throttle was observed.
+}
+
+// ThrottleTracker is a kgo hook that records throttles that brokers apply to
+// a client. Kafka reports throttles in the ThrottleMillis field of responses
+// when a client exceeds its quota; the tracker keeps the throttles observed
+// within a trailing window so that they can be reported with
+// Client.ThrottleReport.
+//
+// A tracker should be added to the client it is tracking with kgo.WithHooks,
+// and should be created with the user and client ID that client connects as.
+type ThrottleTracker struct {
+	user     string
+	clientID string
+	window   time.Duration
+
+	mu        sync.Mutex
+	throttles map[int32][]observedThrottle
+}
+
+type observedThrottle struct {
+	at time.Time
+	d  time.Duration
+}
+
+var _ kgo.HookBrokerThrottle = new(ThrottleTracker)
+
+// NewThrottleTracker returns a tracker for a client connecting as the given
+// user (the SASL username, or empty if not using SASL) and client ID,
+// recording throttles within the given trailing window. A non-positive window
+// defaults to one minute. An empty user is tracked as ANONYMOUS, the user
+// Kafka assigns to unauthenticated connections.
+func NewThrottleTracker(user, clientID string, window time.Duration) *ThrottleTracker {
+	if window <= 0 {
+		window = time.Minute
+	}
+	if user == "" {
+		user = anonymousUser
+	}
+	return &ThrottleTracker{
+		user:      user,
+		clientID:  clientID,
+		window:    window,
+		throttles: make(map[int32][]observedThrottle),
+	}
+}
+
+// OnBrokerThrottle implements kgo.HookBrokerThrottle.
+func (t *ThrottleTracker) OnBrokerThrottle(meta kgo.BrokerMetadata, throttleInterval time.Duration, _ bool) {
+	if throttleInterval <= 0 {
+		return
+	}
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.throttles[meta.NodeID] = append(t.prune(t.throttles[meta.NodeID], now), observedThrottle{now, throttleInterval})
+}
+
+// prune drops throttles that are older than the window.
+func (t *ThrottleTracker) prune(os []observedThrottle, now time.Time) []observedThrottle {
+	var i int
+	for i < len(os) && now.Sub(os[i].at) > t.window {
+		i++
+	}
+	return os[i:]
+}
+
+// Throttles returns per-broker throttles observed within the tracker's
+// window, sorted by broker.
+func (t *ThrottleTracker) Throttles() []BrokerThrottle {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var bs []BrokerThrottle
+	for broker, os := range t.throttles {
+		os = t.prune(os, now)
+		if len(os) == 0 {
+			delete(t.throttles, broker)
+			continue
+		}
+		t.throttles[broker] = os
+		b := BrokerThrottle{
+			Broker: broker,
+			Count:  len(os),
+			Last:   os[len(os)-1].d,
+			LastAt: os[len(os)-1].at,
+		}
+		for _, o := range os {
+			b.Total += o.d
+			if o.d > b.Max {
+				b.Max = o.d
+			}
+		}
+		bs = append(bs, b)
+	}
+	sort.Slice(bs, func(i, j int) bool { return bs[i].Broker < bs[j].Broker })
+	return bs
+}
+
+// ThrottledClient is a client that was recently throttled, as reported from
+// Client.ThrottleReport.
+type ThrottledClient struct {
+	User     string // User is the user the client connects as.
+	ClientID string // ClientID is the client ID the client connects with.
+
+	// Quotas are the quotas that apply to this client, resolved with the
+	// same precedence that Kafka uses. Each value is from the most
+	// specific entity that defines that quota key.
+	Quotas ClientQuotaValues
+
+	// Brokers contains the per-broker throttles recently observed.
+	Brokers []BrokerThrottle
+}
+
+// Max returns the longest throttle observed from any broker.
+func (c ThrottledClient) Max() time.Duration {
+	var max time.Duration
+	for _, b := range c.Brokers {
+		if b.Max > max {
+			max = b.Max
+		}
+	}
+	return max
+}
+
+// ThrottleReport contains all clients that were recently throttled, sorted
+// by user and then by client ID.
+type ThrottleReport []ThrottledClient
+
+// ResolveClientQuotas returns the quotas that apply to a client connecting
+// with the given user and client ID, following Kafka's quota precedence, from
+// most to least specific:
+//
+//	user=<user>, client-id=<client-id>
+//	user=<user>, client-id=<default>
+//	user=<user>
+//	user=<default>, client-id=<client-id>
+//	user=<default>, client-id=<default>
+//	user=<default>
+//	client-id=<client-id>
+//	client-id=<default>
+//
+// For each quota key, the value from the most specific entity defining the
+// key is used. An empty user is resolved as ANONYMOUS, which is the user
+// Kafka assigns to unauthenticated connections and to which user quotas
+// (including the default user quota) apply like any other user. The input
+// quotas are usually from DescribeClientQuotas with no components, which
+// describes all quotas. The returned values are sorted by key.
+func ResolveClientQuotas(qs DescribedClientQuotas, user, clientID string) ClientQuotaValues {
+	if user == "" {
+		user = anonymousUser
+	}
+	u, c := &user, &clientID
+	levels := []ClientQuotaEntity{ // nil names are defaults
+		{{"user", u}, {"client-id", c}},
+		{{"user", u}, {"client-id", nil}},
+		{{"user", u}},
+		{{"user", nil}, {"client-id", c}},
+		{{"user", nil}, {"client-id", nil}},
+		{{"user", nil}},
+		{{"client-id", c}},
+		{{"client-id", nil}},
+	}
+	matches := func(have, want ClientQuotaEntity) bool {
+		if len(have) != len(want) {
+			return false
+		}
+	outer:
+		for _, w := range want {
+			for _, h := range have {
+				if h.Type == w.Type && (h.Name == nil) == (w.Name == nil) && (h.Name == nil || *h.Name == *w.Name) {
+					continue outer
+				}
+			}
+			return false
+		}
+		return true
+	}
+
+	resolved := make(map[string]float64)
+	for _, level := range levels {
+		for _, q := range qs {
+			if !matches(q.Entity, level) {
+				continue
+			}
+			for _, v := range q.Values {
+				if _, exists := resolved[v.Key]; !exists {
+					resolved[v.Key] = v.Value
+				}
+			}
+		}
+	}
+
+	vs := make(ClientQuotaValues, 0, len(resolved))
+	for k, v := range resolved {
+		vs = append(vs, ClientQuotaValue{Key: k, Value: v})
+	}
+	sort.Slice(vs, func(i, j int) bool { return vs[i].Key < vs[j].Key })
+	return vs
+}
+
+// ThrottleReport returns which of the tracked clients are currently being
+// throttled, how long brokers are throttling them for, and which quotas apply
+// to them. Clients that have not been throttled within their tracker's window
+// are not included.
+//
+// Throttles are only visible to the client that is throttled, so each client
+// to report on must have a ThrottleTracker installed as a hook. Quotas are
+// described with a single DescribeClientQuotas request for all quotas.
+//
+// This returns an error if describing quotas fails, or an *AuthError.
+func (cl *Client) ThrottleReport(ctx context.Context, trackers ...*ThrottleTracker) (ThrottleReport, error) {
+	var r ThrottleReport
+	for _, t := range trackers {
+		if bs := t.Throttles(); len(bs) > 0 {
+			r = append(r, ThrottledClient{
+				User:     t.user,
+				ClientID: t.clientID,
+				Brokers:  bs,
+			})
+		}
+	}
+	if len(r) == 0 {
+		return r, nil
+	}
+
+	qs, err := cl.DescribeClientQuotas(ctx, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	for i := range r {
+		r[i].Quotas = ResolveClientQuotas(qs, r[i].User, r[i].ClientID)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].User != r[j].User {
+			return r[i].User < r[j].User
+		}
+		return r[i].ClientID < r[j].ClientID
+	})
+	return r, nil
+}
@@ -3,7 +3,9 @@ package kadm
 import (
 	"context"
 	"errors"
+	"regexp"
 	"sort"
+	"strings"
 
 	"github.com/twmb/franz-go/pkg/kerr"
 	"github.com/twmb/franz-go/pkg/kmsg"
@@ -41,14 +43,57 @@ func (cl *Client) ListTopicsWithInternal(
 	return m.Topics, nil
 }
 
+// ListTopicsFunc issues a metadata request for all topics and returns
+// TopicDetails for only the topics that fn returns true for. Internal topics
+// are not passed to fn; to filter all topics including internal topics, use
+// ListTopicsWithInternal and TopicDetails.KeepFunc.
+//
+// This returns an error if the request fails to be issued, or an *AuthError.
+func (cl *Client) ListTopicsFunc(
+	ctx context.Context,
+	fn func(TopicDetail) bool,
+) (TopicDetails, error) {
+	t, err := cl.ListTopics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.KeepFunc(fn)
+	return t, nil
+}
+
+// ListTopicsRe issues a metadata request for all topics and returns
+// TopicDetails for only the non-internal topics whose name matches re.
+//
+// This returns an error if the request fails to be issued, or an *AuthError.
+func (cl *Client) ListTopicsRe(
+	ctx context.Context,
+	re *regexp.Regexp,
+) (TopicDetails, error) {
+	return cl.ListTopicsFunc(ctx, func(d TopicDetail) bool { return re.MatchString(d.Topic) })
+}
+
+// ListTopicsPrefix issues a metadata request for all topics and returns
+// TopicDetails for only the non-internal topics whose name begins with
+// prefix.
+//
+// This returns an error if the request fails to be issued, or an *AuthError.
+func (cl *Client) ListTopicsPrefix(
+	ctx context.Context,
+	prefix string,
+) (TopicDetails, error) {
+	return cl.ListTopicsFunc(ctx, func(d TopicDetail) bool { return strings.HasPrefix(d.Topic, prefix) })
+}
+
 // CreateTopicResponse contains the response for an individual created topic.
 type CreateTopicResponse struct {
 	Topic             string            // Topic is the topic that was created.
 	ID                TopicID           // ID is the topic ID for this topic, if talking to Kafka v2.8+.
 	Err               error             // Err is any error preventing this topic from being created.
+	ErrMessage        string            // ErrMessage a potential extra message describing any error.
 	NumPartitions     int32             // NumPartitions is the number of partitions in the response, if talking to Kafka v2.4+.
 	ReplicationFactor int16             // ReplicationFactor is how many replicas every partition has for this topic, if talking to Kafka 2.4+.
 	Configs           map[string]Config // Configs contains the topic configuration (minus config synonyms), if talking to Kafka 2.4+.
+	ValidateOnly      bool              // ValidateOnly is true if this response is from validating the creation; the topic was not created.
 }
 
 // CreateTopicRepsonses contains per-topic responses for created topics.
@@ -179,19 +224,103 @@ func (cl *Client) ValidateCreateTopics(
 	return cl.createTopics(ctx, true, partitions, replicationFactor, configs, topics)
 }
 
+// ReplicaAssignment is a manual replica assignment for a topic, mapping each
+// partition to the brokers that should host its replicas. The first broker
+// for a partition is the preferred leader.
+type ReplicaAssignment map[int32][]int32
+
+// CreateTopicWithAssignment issues a create topics request for the given
+// topic, placing replicas exactly as specified in the assignment rather than
+// letting Kafka choose. The assignment must contain every partition from 0 to
+// the desired number of partitions, and every partition should have the same
+// number of replicas. See CreateTopic for more details on the returned error.
+func (cl *Client) CreateTopicWithAssignment(
+	ctx context.Context,
+	assignment ReplicaAssignment,
+	configs map[string]*string,
+	topic string,
+) (CreateTopicResponse, error) {
+	rs, err := cl.CreateTopicsWithAssignments(ctx, map[string]ReplicaAssignment{topic: assignment}, configs)
+	if err != nil {
+		return CreateTopicResponse{}, err
+	}
+	r, exists := rs[topic]
+	if !exists {
+		return CreateTopicResponse{}, errors.New("requested topic was not part of create topic response")
+	}
+	return r, r.Err
+}
+
+// CreateTopicsWithAssignments issues a create topics request for every topic
+// in the assignments map, placing each topic's replicas exactly as specified
+// in its assignment, with the (optional) configs applied to every topic. This
+// allows placement-aware tooling to control exactly where replicas land.
+//
+// See CreateTopics for more details on errors.
+func (cl *Client) CreateTopicsWithAssignments(
+	ctx context.Context,
+	assignments map[string]ReplicaAssignment,
+	configs map[string]*string,
+) (CreateTopicResponses, error) {
+	return cl.createTopicsAssigned(ctx, false, configs, assignments)
+}
+
+// ValidateCreateTopicsWithAssignments validates a create topics request for
+// every topic in the assignments map. This uses the same logic as
+// CreateTopicsWithAssignments, but with the request's ValidateOnly field set
+// to true; no topics are actually created.
+func (cl *Client) ValidateCreateTopicsWithAssignments(
+	ctx context.Context,
+	assignments map[string]ReplicaAssignment,
+	configs map[string]*string,
+) (CreateTopicResponses, error) {
+	return cl.createTopicsAssigned(ctx, true, configs, assignments)
+}
+
+func (cl *Client) createTopicsAssigned(ctx context.Context, dry bool, configs map[string]*string, assignments map[string]ReplicaAssignment) (CreateTopicResponses, error) {
+	topics := make([]string, 0, len(assignments))
+	for t := range assignments {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+	return cl.createTopicsWith(ctx, dry, configs, topics, func(rt *kmsg.CreateTopicsRequestTopic) {
+		// Kafka requires -1 partitions and replication factor when
+		// using a manual assignment.
+		rt.NumPartitions = -1
+		rt.ReplicationFactor = -1
+		a := assignments[rt.Topic]
+		ps := make([]int32, 0, len(a))
+		for p := range a {
+			ps = append(ps, p)
+		}
+		for _, p := range int32s(ps) {
+			ra := kmsg.NewCreateTopicsRequestTopicReplicaAssignment()
+			ra.Partition = p
+			ra.Replicas = a[p]
+			rt.ReplicaAssignment = append(rt.ReplicaAssignment, ra)
+		}
+	})
+}
+
 func (cl *Client) createTopics(ctx context.Context, dry bool, p int32, rf int16, configs map[string]*string, topics []string) (CreateTopicResponses, error) {
+	return cl.createTopicsWith(ctx, dry, configs, topics, func(rt *kmsg.CreateTopicsRequestTopic) {
+		rt.NumPartitions = p
+		rt.ReplicationFactor = rf
+	})
+}
+
+func (cl *Client) createTopicsWith(ctx context.Context, dry bool, configs map[string]*string, topics []string, fill func(*kmsg.CreateTopicsRequestTopic)) (CreateTopicResponses, error) {
 	if len(topics) == 0 {
 		return make(CreateTopicResponses), nil
 	}
 
 	req := kmsg.NewCreateTopicsRequest()
 	req.TimeoutMillis = cl.timeoutMillis
-	req.ValidateOnly = dry
+	req.ValidateOnly = dry || cl.validateOnly
 	for _, t := range topics {
 		rt := kmsg.NewCreateTopicsRequestTopic()
 		rt.Topic = t
-		rt.NumPartitions = p
-		rt.ReplicationFactor = rf
+		fill(&rt)
 		for k, v := range configs {
 			rc := kmsg.NewCreateTopicsRequestTopicConfig()
 			rc.Name = k
@@ -201,7 +330,7 @@ func (cl *Client) createTopics(ctx context.Context, dry bool, p int32, rf int16,
 		req.Topics = append(req.Topics, rt)
 	}
 
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
@@ -212,9 +341,11 @@ func (cl *Client) createTopics(ctx context.Context, dry bool, p int32, rf int16,
 			Topic:             t.Topic,
 			ID:                t.TopicID,
 			Err:               kerr.ErrorForCode(t.ErrorCode),
+			ErrMessage:        unptrStr(t.ErrorMessage),
 			NumPartitions:     t.NumPartitions,
 			ReplicationFactor: t.ReplicationFactor,
 			Configs:           make(map[string]Config),
+			ValidateOnly:      req.ValidateOnly,
 		}
 		for _, c := range t.Configs {
 			rt.Configs[c.Name] = Config{
@@ -296,7 +427,7 @@ func (cl *Client) DeleteTopics(ctx context.Context, topics ...string) (DeleteTop
 		req.Topics = append(req.Topics, rt)
 	}
 
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
@@ -431,7 +562,7 @@ func (cl *Client) DeleteRecords(ctx context.Context, os Offsets) (DeleteRecordsR
 		req.Topics = append(req.Topics, rt)
 	}
 
-	shards := cl.cl.RequestSharded(ctx, req)
+	shards := cl.requestSharded(ctx, req)
 	rs := make(DeleteRecordsResponses)
 	return rs, shardErrEach(req, shards, func(kr kmsg.Response) error {
 		resp := kr.(*kmsg.DeleteRecordsResponse)
@@ -457,8 +588,10 @@ func (cl *Client) DeleteRecords(ctx context.Context, os Offsets) (DeleteRecordsR
 // CreatePartitionsResponse contains the response for an individual topic from
 // a create partitions request.
 type CreatePartitionsResponse struct {
-	Topic string // Topic is the topic this response is for.
-	Err   error  // Err is non-nil if partitions were unable to be added to this topic.
+	Topic        string // Topic is the topic this response is for.
+	Err          error  // Err is non-nil if partitions were unable to be added to this topic.
+	ErrMessage   string // ErrMessage a potential extra message describing any error.
+	ValidateOnly bool   // ValidateOnly is true if this response is from validating the request; no partitions were added.
 }
 
 // CreatePartitionsResponses contains per-topic responses for a create
@@ -564,7 +697,7 @@ func (cl *Client) createPartitions(ctx context.Context, dry bool, add, set int,
 
 	req := kmsg.NewCreatePartitionsRequest()
 	req.TimeoutMillis = cl.timeoutMillis
-	req.ValidateOnly = dry
+	req.ValidateOnly = dry || cl.validateOnly
 	for _, t := range topics {
 		rt := kmsg.NewCreatePartitionsRequestTopic()
 		rt.Topic = t
@@ -576,7 +709,7 @@ func (cl *Client) createPartitions(ctx context.Context, dry bool, add, set int,
 		req.Topics = append(req.Topics, rt)
 	}
 
-	resp, err := req.RequestWith(ctx, cl.cl)
+	resp, err := req.RequestWith(ctx, cl.requestor())
 	if err != nil {
 		return nil, err
 	}
@@ -584,8 +717,10 @@ func (cl *Client) createPartitions(ctx context.Context, dry bool, add, set int,
 	rs := make(CreatePartitionsResponses)
 	for _, t := range resp.Topics {
 		rs[t.Topic] = CreatePartitionsResponse{
-			Topic: t.Topic,
-			Err:   kerr.ErrorForCode(t.ErrorCode),
+			Topic:        t.Topic,
+			Err:          kerr.ErrorForCode(t.ErrorCode),
+			ErrMessage:   unptrStr(t.ErrorMessage),
+			ValidateOnly: req.ValidateOnly,
 		}
 	}
 	return rs, nil
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"sort"
+	"time"
 
 	"github.com/twmb/franz-go/pkg/kerr"
 	"github.com/twmb/franz-go/pkg/kmsg"
@@ -249,7 +250,7 @@ func (cl *Client) DescribeProducers(ctx context.Context, s TopicsSet) (Described
 		rt.Partitions = t.Partitions
 		req.Topics = append(req.Topics, rt)
 	}
-	shards := cl.cl.RequestSharded(ctx, req)
+	shards := cl.requestSharded(ctx, req)
 	dts := make(DescribedProducersTopics)
 	return dts, shardErrEachBroker(req, shards, func(b BrokerDetail, kr kmsg.Response) error {
 		resp := kr.(*kmsg.DescribeProducersResponse)
@@ -402,7 +403,7 @@ func (cl *Client) DescribeTransactions(ctx context.Context, txnIDs ...string) (D
 	req := kmsg.NewPtrDescribeTransactionsRequest()
 	req.TransactionalIDs = txnIDs
 
-	shards := cl.cl.RequestSharded(ctx, req)
+	shards := cl.requestSharded(ctx, req)
 	described := make(DescribedTransactions)
 	err := shardErrEachBroker(req, shards, func(b BrokerDetail, kr kmsg.Response) error {
 		resp := kr.(*kmsg.DescribeTransactionsResponse)
@@ -492,7 +493,7 @@ func (cl *Client) ListTransactions(ctx context.Context, producerIDs []int64, fil
 	req := kmsg.NewPtrListTransactionsRequest()
 	req.ProducerIDFilters = producerIDs
 	req.StateFilters = filterStates
-	shards := cl.cl.RequestSharded(ctx, req)
+	shards := cl.requestSharded(ctx, req)
 	list := make(ListedTransactions)
 	return list, shardErrEachBroker(req, shards, func(b BrokerDetail, kr kmsg.Response) error {
 		resp := kr.(*kmsg.ListTransactionsResponse)
@@ -514,6 +515,65 @@ func (cl *Client) ListTransactions(ctx context.Context, producerIDs []int64, fil
 	})
 }
 
+// ListTransactionsFilter filters which transactional IDs are returned from
+// ListTransactionalIDs.
+type ListTransactionsFilter struct {
+	// States, if non-empty, returns only transactions in the given states
+	// (Empty, Ongoing, PrepareCommit, PrepareAbort, CompleteCommit,
+	// CompleteAbort, Dead, PrepareEpochFence). This filter is applied by
+	// the broker.
+	States []string
+
+	// ProducerIDs, if non-empty, returns only transactions from the given
+	// producer IDs. This filter is applied by the broker.
+	ProducerIDs []int64
+
+	// OlderThan, if positive, returns only transactions that started at
+	// least this long ago. Only transactions that have started (i.e.,
+	// Ongoing or preparing to complete) have a start time, so this
+	// excludes all other transactions. This filter requires describing
+	// all listed transactions.
+	OlderThan time.Duration
+}
+
+// ListTransactionalIDs returns the sorted transactional IDs across all
+// coordinators that match the filter. This is a convenience function built on
+// ListTransactions and, if filtering by age, DescribeTransactions. For
+// example, to find transactions that may be hanging:
+//
+//	ids, err := adm.ListTransactionalIDs(ctx, kadm.ListTransactionsFilter{
+//		States:    []string{"Ongoing", "PrepareAbort", "PrepareCommit"},
+//		OlderThan: 15 * time.Minute,
+//	})
+//
+// This may return *ShardErrors or *AuthError. If listing or describing fails
+// on some brokers, this returns the IDs that could be listed and described
+// alongside the *ShardErrors.
+func (cl *Client) ListTransactionalIDs(ctx context.Context, filter ListTransactionsFilter) ([]string, error) {
+	listed, listErr := cl.ListTransactions(ctx, filter.ProducerIDs, filter.States)
+	var ae *AuthError
+	if errors.As(listErr, &ae) {
+		return nil, listErr
+	}
+	ids := listed.TransactionalIDs()
+	if filter.OlderThan <= 0 || len(ids) == 0 {
+		return ids, listErr
+	}
+
+	described, descErr := cl.DescribeTransactions(ctx, ids...)
+	if errors.As(descErr, &ae) {
+		return nil, descErr
+	}
+	cutoff := time.Now().Add(-filter.OlderThan).UnixMilli()
+	ids = ids[:0]
+	for _, d := range described.Sorted() {
+		if d.Err == nil && d.StartTimestamp >= 0 && d.StartTimestamp <= cutoff {
+			ids = append(ids, d.TxnID)
+		}
+	}
+	return ids, mergeShardErrs(listErr, descErr)
+}
+
 // TxnMarkers marks the end of a partition: the producer ID / epoch doing the
 // writing, whether this is a commit, the coordinator epoch of the broker we
 // are writing to (for fencing), and the topics and partitions that we are
@@ -717,7 +777,7 @@ func (cl *Client) WriteTxnMarkers(ctx context.Context, markers ...TxnMarkers) (T
 		}
 		req.Markers = append(req.Markers, rm)
 	}
-	shards := cl.cl.RequestSharded(ctx, req)
+	shards := cl.requestSharded(ctx, req)
 	rs := make(TxnMarkersResponses)
 	return rs, shardErrEachBroker(req, shards, func(b BrokerDetail, kr kmsg.Response) error {
 		resp := kr.(*kmsg.WriteTxnMarkersResponse)
@@ -17,6 +17,23 @@ func (c *Cluster) handleSASLAuthenticate(creq clientReq) (kmsg.Response, error)
 		return nil, err
 	}
 
+	// Like Kafka, we reply with an error on authentication failure rather
+	// than closing the connection. The connection is left in its current
+	// SASL stage, meaning any further request other than ApiVersions
+	// closes it.
+	authFailed := func(msg string) (kmsg.Response, error) {
+		resp.ErrorCode = kerr.SaslAuthenticationFailed.Code
+		resp.ErrorMessage = &msg
+		return resp, nil
+	}
+
+	if c.cfg.saslRejectAll {
+		switch creq.cc.saslStage {
+		case saslStageAuthPlain, saslStageAuthScram0_256, saslStageAuthScram0_512, saslStageAuthScram1:
+			return authFailed("Authentication failed: all authentication is rejected")
+		}
+	}
+
 	switch creq.cc.saslStage {
 	default:
 		resp.ErrorCode = kerr.IllegalSaslState.Code
@@ -27,11 +44,8 @@ func (c *Cluster) handleSASLAuthenticate(creq clientReq) (kmsg.Response, error)
 		if err != nil {
 			return nil, err
 		}
-		if c.sasls.plain == nil {
-			return nil, errors.New("invalid sasl")
-		}
-		if p != c.sasls.plain[u] {
-			return nil, errors.New("invalid sasl")
+		if exp, ok := c.sasls.plain[u]; !ok || p != exp {
+			return authFailed("Authentication failed: Invalid username or password")
 		}
 		creq.cc.saslStage = saslStageComplete
 
@@ -40,12 +54,9 @@ func (c *Cluster) handleSASLAuthenticate(creq clientReq) (kmsg.Response, error)
 		if err != nil {
 			return nil, err
 		}
-		if c.sasls.scram256 == nil {
-			return nil, errors.New("invalid sasl")
-		}
 		a, ok := c.sasls.scram256[c0.user]
 		if !ok {
-			return nil, errors.New("invalid sasl")
+			return authFailed("Authentication failed during authentication due to invalid credentials with SASL mechanism SCRAM-SHA-256")
 		}
 		s0, serverFirst := scramServerFirst(c0, a)
 		resp.SASLAuthBytes = serverFirst
@@ -57,12 +68,9 @@ func (c *Cluster) handleSASLAuthenticate(creq clientReq) (kmsg.Response, error)
 		if err != nil {
 			return nil, err
 		}
-		if c.sasls.scram512 == nil {
-			return nil, errors.New("invalid sasl")
-		}
 		a, ok := c.sasls.scram512[c0.user]
 		if !ok {
-			return nil, errors.New("invalid sasl")
+			return authFailed("Authentication failed during authentication due to invalid credentials with SASL mechanism SCRAM-SHA-512")
 		}
 		s0, serverFirst := scramServerFirst(c0, a)
 		resp.SASLAuthBytes = serverFirst
@@ -71,6 +79,9 @@ func (c *Cluster) handleSASLAuthenticate(creq clientReq) (kmsg.Response, error)
 
 	case saslStageAuthScram1:
 		serverFinal, err := creq.cc.s0.serverFinal(req.SASLAuthBytes)
+		if errors.Is(err, errScramInvalidPassword) {
+			return authFailed("Authentication failed during authentication due to invalid credentials with SASL mechanism " + creq.cc.s0.a.mechanism)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -1,6 +1,7 @@
 package kfake
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -41,6 +42,9 @@ type (
 		groups groups
 		sasls  sasls
 
+		tls *tls.Config   // non-nil if brokers listen with TLS
+		ca  *selfSignedCA // non-nil if started with SelfSignedTLS
+
 		die  chan struct{}
 		dead atomic.Bool
 	}
@@ -135,12 +139,20 @@ func NewCluster(opts ...Opt) (c *Cluster, err error) {
 		}
 	}
 
+	c.tls = cfg.tls
+	if cfg.selfSigned {
+		if c.ca, err = newSelfSignedCA(); err != nil {
+			return nil, err
+		}
+		c.tls = c.ca.tlsConfig()
+	}
+
 	for i := 0; i < cfg.nbrokers; i++ {
 		var port int
 		if len(cfg.ports) > 0 {
 			port = cfg.ports[i]
 		}
-		ln, err := newListener(port)
+		ln, err := newListener(port, c.tls)
 		if err != nil {
 			c.Close()
 			return nil, err
@@ -181,8 +193,15 @@ func (c *Cluster) Close() {
 	}
 }
 
-func newListener(port int) (net.Listener, error) {
-	return net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+func newListener(port int, tc *tls.Config) (net.Listener, error) {
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, err
+	}
+	if tc != nil {
+		ln = tls.NewListener(ln, tc)
+	}
+	return ln, nil
 }
 
 func (b *broker) listen() {
@@ -461,7 +480,7 @@ func (c *Cluster) AddNode(nodeID int32, port int) (int32, int, error) {
 			port = 0
 		}
 		var ln net.Listener
-		if ln, err = newListener(port); err != nil {
+		if ln, err = newListener(port, c.tls); err != nil {
 			return
 		}
 		_, strPort, _ := net.SplitHostPort(ln.Addr().String())
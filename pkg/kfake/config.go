@@ -1,6 +1,9 @@
 package kfake
 
-import "time"
+import (
+	"crypto/tls"
+	"time"
+)
 
 // Opt is an option to configure a client.
 type Opt interface {
@@ -22,8 +25,12 @@ type cfg struct {
 	minSessionTimeout time.Duration
 	maxSessionTimeout time.Duration
 
-	enableSASL bool
-	sasls      map[struct{ m, u string }]string // cleared after client initialization
+	enableSASL    bool
+	saslRejectAll bool
+	sasls         map[struct{ m, u string }]string // cleared after client initialization
+
+	tls        *tls.Config
+	selfSigned bool
 }
 
 // NumBrokers sets the number of brokers to start in the fake cluster.
@@ -78,11 +85,42 @@ func EnableSASL() Opt {
 	return opt{func(cfg *cfg) { cfg.enableSASL = true }}
 }
 
+// SASLRejectAll enables SASL authentication for the cluster and fails every
+// authentication attempt with SASL_AUTHENTICATION_FAILED, regardless of the
+// mechanism or credentials used. This can be used to test how clients handle
+// authentication errors.
+func SASLRejectAll() Opt {
+	return opt{func(cfg *cfg) { cfg.enableSASL, cfg.saslRejectAll = true, true }}
+}
+
+// TLS enables TLS on every broker's listener using the given config, which
+// must contain a certificate or GetCertificate function. To test certificate
+// rotation, use GetCertificate to return the current certificate.
+func TLS(c *tls.Config) Opt {
+	return opt{func(cfg *cfg) { cfg.tls, cfg.selfSigned = c, false }}
+}
+
+// SelfSignedTLS enables TLS on every broker's listener using a certificate
+// issued by a CA that is generated when the cluster starts. The certificate
+// is valid for localhost, 127.0.0.1, and ::1. The CA can be retrieved with
+// the cluster's TLSRootCAs or TLSCACertPEM methods, and the broker
+// certificate can be reissued with RotateTLSCertificate.
+func SelfSignedTLS() Opt {
+	return opt{func(cfg *cfg) { cfg.tls, cfg.selfSigned = nil, true }}
+}
+
 // Superuser seeds the cluster with a superuser. The method must be either
-// PLAIN, SCRAM-SHA-256, or SCRAM-SHA-512.
+// PLAIN, SCRAM-SHA-256, or SCRAM-SHA-512. This option can be used multiple
+// times to seed multiple users. Superusers are only used if SASL is enabled
+// with EnableSASL.
 // Note that PLAIN superusers cannot be deleted.
 // SCRAM superusers can be modified with AlterUserScramCredentials.
 // If you delete all SASL users, the kfake cluster will be unusable.
 func Superuser(method, user, pass string) Opt {
-	return opt{func(cfg *cfg) { cfg.sasls[struct{ m, u string }{method, user}] = pass }}
+	return opt{func(cfg *cfg) {
+		if cfg.sasls == nil {
+			cfg.sasls = make(map[struct{ m, u string }]string)
+		}
+		cfg.sasls[struct{ m, u string }{method, user}] = pass
+	}}
 }
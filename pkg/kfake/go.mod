@@ -7,3 +7,8 @@ require (
 	github.com/twmb/franz-go/pkg/kmsg v1.4.0
 	golang.org/x/crypto v0.7.0
 )
+
+require (
+	github.com/klauspost/compress v1.16.3 // indirect
+	github.com/pierrec/lz4/v4 v4.1.17 // indirect
+)
@@ -127,6 +127,10 @@ type scramClient0 struct {
 	nonce []byte // nonce in client0
 }
 
+// errScramInvalidPassword is returned from serverFinal if the client proof
+// does not match, and is converted to SASL_AUTHENTICATION_FAILED.
+var errScramInvalidPassword = errors.New("invalid password")
+
 var scramUnescaper = strings.NewReplacer("=3D", "=", "=2C", ",")
 
 func scramParseClient0(client0 []byte) (scramClient0, error) {
@@ -240,7 +244,7 @@ func (s *scramServer0) serverFinal(clientFinal []byte) ([]byte, error) {
 		usedKey = h.Sum(nil)
 	}
 	if !bytes.Equal(usedKey, storedKey) {
-		return nil, errors.New("invalid password")
+		return nil, errScramInvalidPassword
 	}
 
 	var serverKey []byte // := HMAC(SaltedPass, "Server Key")
@@ -0,0 +1,87 @@
+package kfake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+func TestSASLAuthentication(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		opts   []Opt
+		mech   sasl.Mechanism
+		expErr error
+	}{
+		{
+			name: "plain",
+			opts: []Opt{EnableSASL(), Superuser("PLAIN", "user", "pass")},
+			mech: plain.Auth{User: "user", Pass: "pass"}.AsMechanism(),
+		},
+		{
+			name:   "plain bad password",
+			opts:   []Opt{EnableSASL(), Superuser("PLAIN", "user", "pass")},
+			mech:   plain.Auth{User: "user", Pass: "wrong"}.AsMechanism(),
+			expErr: kerr.SaslAuthenticationFailed,
+		},
+		{
+			name: "scram",
+			opts: []Opt{EnableSASL()},
+			mech: scram.Auth{User: "admin", Pass: "admin"}.AsSha256Mechanism(),
+		},
+		{
+			name:   "scram bad password",
+			opts:   []Opt{EnableSASL()},
+			mech:   scram.Auth{User: "admin", Pass: "wrong"}.AsSha256Mechanism(),
+			expErr: kerr.SaslAuthenticationFailed,
+		},
+		{
+			name:   "scram unknown user",
+			opts:   []Opt{EnableSASL()},
+			mech:   scram.Auth{User: "unknown", Pass: "admin"}.AsSha256Mechanism(),
+			expErr: kerr.SaslAuthenticationFailed,
+		},
+		{
+			name:   "reject all",
+			opts:   []Opt{SASLRejectAll(), Superuser("PLAIN", "user", "pass")},
+			mech:   plain.Auth{User: "user", Pass: "pass"}.AsMechanism(),
+			expErr: kerr.SaslAuthenticationFailed,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := NewCluster(append([]Opt{NumBrokers(1)}, test.opts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			cl, err := kgo.NewClient(
+				kgo.SeedBrokers(c.ListenAddrs()...),
+				kgo.SASL(test.mech),
+				kgo.RequestRetries(0),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cl.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err = cl.Ping(ctx)
+			if test.expErr == nil {
+				if err != nil {
+					t.Errorf("unexpected err: %v", err)
+				}
+			} else if !errors.Is(err, test.expErr) {
+				t.Errorf("got err %v, exp %v", err, test.expErr)
+			}
+		})
+	}
+}
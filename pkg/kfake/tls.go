@@ -0,0 +1,149 @@
+package kfake
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// selfSignedCA is a CA that is generated when the cluster is started with
+// SelfSignedTLS. The CA issues the brokers' certificate, which can be
+// reissued with RotateTLSCertificate.
+type selfSignedCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+	pool *x509.CertPool
+
+	leaf atomic.Pointer[tls.Certificate]
+}
+
+func newSelfSignedCA() (*selfSignedCA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "kfake CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	ca := &selfSignedCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pool: x509.NewCertPool(),
+	}
+	ca.pool.AddCert(cert)
+	if err := ca.issue(); err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// issue creates a new broker certificate signed by the CA, valid for
+// localhost and the loopback addresses, and swaps it in for all future
+// handshakes.
+func (ca *selfSignedCA) issue() error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "kfake"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return err
+	}
+	ca.leaf.Store(&tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	})
+	return nil
+}
+
+func (ca *selfSignedCA) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return ca.leaf.Load(), nil
+		},
+	}
+}
+
+func newSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// TLSRootCAs returns a cert pool containing the CA that signed the brokers'
+// certificate if the cluster was started with SelfSignedTLS, or nil
+// otherwise. The pool can be used as the RootCAs in a client's tls.Config.
+func (c *Cluster) TLSRootCAs() *x509.CertPool {
+	if c.ca == nil {
+		return nil
+	}
+	return c.ca.pool
+}
+
+// TLSCACertPEM returns the PEM encoded CA certificate that signed the brokers'
+// certificate if the cluster was started with SelfSignedTLS, or nil
+// otherwise.
+func (c *Cluster) TLSCACertPEM() []byte {
+	if c.ca == nil {
+		return nil
+	}
+	return append([]byte(nil), c.ca.pem...)
+}
+
+// RotateTLSCertificate issues a new broker certificate from the self signed
+// CA. Connections that are already established are unaffected; all future
+// TLS handshakes use the new certificate. This returns an error if the
+// cluster was not started with SelfSignedTLS.
+//
+// If you provided your own tls.Config with TLS, you can rotate certificates
+// by using the config's GetCertificate function.
+func (c *Cluster) RotateTLSCertificate() error {
+	if c.ca == nil {
+		return errors.New("cluster is not using a self signed certificate")
+	}
+	return c.ca.issue()
+}
@@ -0,0 +1,73 @@
+package kfake
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestSelfSignedTLS(t *testing.T) {
+	c, err := NewCluster(NumBrokers(1), SelfSignedTLS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ping := func(tc *tls.Config) error {
+		cl, err := kgo.NewClient(
+			kgo.SeedBrokers(c.ListenAddrs()...),
+			kgo.DialTLSConfig(tc),
+			kgo.RequestRetries(0),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cl.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return cl.Ping(ctx)
+	}
+
+	if err := ping(&tls.Config{RootCAs: c.TLSRootCAs()}); err != nil {
+		t.Fatalf("unable to connect with the cluster's root CAs: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(c.TLSCACertPEM()) {
+		t.Fatal("unable to parse the cluster's CA PEM")
+	}
+	if err := ping(&tls.Config{RootCAs: pool}); err != nil {
+		t.Errorf("unable to connect with the cluster's CA PEM: %v", err)
+	}
+
+	// A rotated certificate is issued by the same CA.
+	if err := c.RotateTLSCertificate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ping(&tls.Config{RootCAs: c.TLSRootCAs()}); err != nil {
+		t.Errorf("unable to connect after rotating the certificate: %v", err)
+	}
+
+	// The certificate is not trusted without the cluster's CA.
+	if err := ping(&tls.Config{RootCAs: x509.NewCertPool()}); err == nil {
+		t.Error("unexpectedly connected without trusting the cluster's CA")
+	}
+}
+
+func TestTLSAccessors(t *testing.T) {
+	c, err := NewCluster(NumBrokers(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.TLSRootCAs() != nil || c.TLSCACertPEM() != nil {
+		t.Error("got a CA for a cluster without SelfSignedTLS")
+	}
+	if err := c.RotateTLSCertificate(); err == nil {
+		t.Error("expected an error rotating the certificate of a cluster without SelfSignedTLS")
+	}
+}
@@ -0,0 +1,153 @@
+package kgo
+
+import (
+	"errors"
+	"sync"
+)
+
+// AckCommitter tracks polled records that are processed out of order, such as
+// by a pool of worker goroutines, and only marks a record's offset for
+// committing once every record polled before it in its partition has been
+// acknowledged. This ensures that committed offsets never skip over records
+// that are still being processed, even if later records finish first.
+//
+// AckCommitter marks records with MarkCommitRecords, so the client must be
+// consuming in a group with AutoCommitMarks. The highest safe offsets are then
+// committed automatically on the autocommit interval and when partitions are
+// revoked, or can be committed manually with CommitMarkedOffsets.
+//
+// Every poll must be passed to Track before its records are handed off to be
+// processed. Each record must then be passed to AckRecord once it is
+// processed. If partitions are revoked or lost, they should be passed to
+// Forget in the OnPartitionsRevoked and OnPartitionsLost callbacks, so that
+// acknowledgements for records from those partitions are ignored.
+type AckCommitter struct {
+	mark func(...*Record)
+
+	mu    sync.Mutex
+	parts map[string]map[int32]*ackPartition
+}
+
+// ackPartition tracks records for a single partition.
+type ackPartition struct {
+	pending []*Record          // polled records not yet contiguously acked, in offset order
+	acked   map[int64]struct{} // offsets in pending that were acked out of order
+}
+
+// NewAckCommitter returns an AckCommitter that marks records on the given
+// client, or an error if the client is not consuming in a group with
+// AutoCommitMarks.
+func NewAckCommitter(cl *Client) (*AckCommitter, error) {
+	if cl.cfg.group == "" || !cl.cfg.autocommitMarks {
+		return nil, errors.New("AckCommitter requires a group consumer with AutoCommitMarks")
+	}
+	return &AckCommitter{
+		mark:  cl.MarkCommitRecords,
+		parts: make(map[string]map[int32]*ackPartition),
+	}, nil
+}
+
+// Track begins tracking all records in the given fetches. This must be called
+// for every poll, in order, before any record from the poll is acknowledged.
+//
+// If a partition's records begin at or before an offset that is already being
+// tracked (for example, because the client rewound after a rebalance), all
+// prior tracking for the partition is discarded.
+func (a *AckCommitter) Track(fs Fetches) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	fs.EachPartition(func(p FetchTopicPartition) {
+		if len(p.Records) == 0 {
+			return
+		}
+		ps := a.parts[p.Topic]
+		if ps == nil {
+			ps = make(map[int32]*ackPartition)
+			a.parts[p.Topic] = ps
+		}
+		ap := ps[p.Partition]
+		if ap == nil {
+			ap = new(ackPartition)
+			ps[p.Partition] = ap
+		}
+		if n := len(ap.pending); n > 0 && p.Records[0].Offset <= ap.pending[n-1].Offset {
+			*ap = ackPartition{}
+		}
+		ap.pending = append(ap.pending, p.Records...)
+	})
+}
+
+// AckRecord acknowledges that a record is done being processed. If this
+// record and every record tracked before it in its partition have been
+// acknowledged, the last record in that contiguous range is marked for
+// committing.
+//
+// Acknowledging a record that is not tracked, such as one from a forgotten
+// partition, does nothing. This function is safe to call concurrently.
+func (a *AckCommitter) AckRecord(r *Record) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ap := a.parts[r.Topic][r.Partition]
+	if ap == nil || len(ap.pending) == 0 || r.Offset < ap.pending[0].Offset {
+		return
+	}
+	if ap.acked == nil {
+		ap.acked = make(map[int64]struct{})
+	}
+	ap.acked[r.Offset] = struct{}{}
+
+	var last *Record
+	for len(ap.pending) > 0 {
+		next := ap.pending[0]
+		if _, ok := ap.acked[next.Offset]; !ok {
+			break
+		}
+		delete(ap.acked, next.Offset)
+		last = next
+		ap.pending[0] = nil
+		ap.pending = ap.pending[1:]
+	}
+
+	// We mark while holding our lock so that a concurrent Forget cannot
+	// finish (and allow a revoke to commit) before we mark a partition
+	// we are about to lose.
+	if last != nil {
+		a.mark(last)
+	}
+}
+
+// Forget stops tracking the given partitions, discarding any records that
+// have not yet been marked. This should be called with the partitions passed
+// to OnPartitionsRevoked and OnPartitionsLost.
+func (a *AckCommitter) Forget(partitions map[string][]int32) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for topic, ps := range partitions {
+		tps := a.parts[topic]
+		for _, p := range ps {
+			delete(tps, p)
+		}
+		if len(tps) == 0 {
+			delete(a.parts, topic)
+		}
+	}
+}
+
+// Pending returns the number of tracked records that have not yet been
+// marked for committing, either because they have not been acknowledged or
+// because an earlier record in their partition has not been acknowledged.
+func (a *AckCommitter) Pending() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var n int
+	for _, ps := range a.parts {
+		for _, ap := range ps {
+			n += len(ap.pending)
+		}
+	}
+	return n
+}
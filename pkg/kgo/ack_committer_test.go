@@ -0,0 +1,77 @@
+package kgo
+
+import (
+	"testing"
+)
+
+func TestAckCommitter(t *testing.T) {
+	var marked []int64
+	a := &AckCommitter{
+		mark:  func(rs ...*Record) { marked = append(marked, rs[len(rs)-1].Offset) },
+		parts: make(map[string]map[int32]*ackPartition),
+	}
+
+	recs := func(offsets ...int64) []*Record {
+		var rs []*Record
+		for _, o := range offsets {
+			rs = append(rs, &Record{Topic: "t", Partition: 0, Offset: o})
+		}
+		return rs
+	}
+	track := func(rs []*Record) {
+		a.Track(Fetches{{Topics: []FetchTopic{{
+			Topic:      "t",
+			Partitions: []FetchPartition{{Partition: 0, Records: rs}},
+		}}}})
+	}
+	expMarked := func(exp ...int64) {
+		t.Helper()
+		if len(marked) != len(exp) {
+			t.Fatalf("got marked %v != exp %v", marked, exp)
+		}
+		for i := range exp {
+			if marked[i] != exp[i] {
+				t.Fatalf("got marked %v != exp %v", marked, exp)
+			}
+		}
+	}
+
+	rs := recs(0, 1, 2, 3, 5)
+	track(rs)
+
+	a.AckRecord(rs[2]) // gap at 0, 1
+	a.AckRecord(rs[1])
+	expMarked()
+	a.AckRecord(rs[0]) // 0 through 2 are contiguous
+	expMarked(2)
+	a.AckRecord(rs[4]) // gap at 3
+	expMarked(2)
+	a.AckRecord(rs[3])
+	expMarked(2, 5)
+	if n := a.Pending(); n != 0 {
+		t.Fatalf("got %d pending != exp 0", n)
+	}
+
+	// Acking a record twice or before what we track does nothing.
+	a.AckRecord(rs[3])
+	expMarked(2, 5)
+
+	// A rewind discards what we were tracking.
+	more := recs(6, 7)
+	track(more)
+	rewound := recs(6, 7, 8)
+	track(rewound)
+	if n := a.Pending(); n != 3 {
+		t.Fatalf("got %d pending != exp 3", n)
+	}
+	a.AckRecord(rewound[0])
+	expMarked(2, 5, 6)
+
+	// Forgotten partitions ignore acks.
+	a.Forget(map[string][]int32{"t": {0}})
+	a.AckRecord(rewound[1])
+	expMarked(2, 5, 6)
+	if n := a.Pending(); n != 0 {
+		t.Fatalf("got %d pending != exp 0", n)
+	}
+}
@@ -74,3 +74,22 @@ type lazyI32 int32
 
 func (v *lazyI32) store(s int32) { atomic.StoreInt32((*int32)(v), s) }
 func (v *lazyI32) load() int32   { return atomic.LoadInt32((*int32)(v)) }
+
+// lazyI64 is the int64 counterpart of lazyI32.
+type lazyI64 int64
+
+func (v *lazyI64) store(s int64) { atomic.StoreInt64((*int64)(v), s) }
+func (v *lazyI64) load() int64   { return atomic.LoadInt64((*int64)(v)) }
+
+// lazyBool is the bool counterpart of lazyI32, used in Record, which users
+// copy by value.
+type lazyBool uint32
+
+func (b *lazyBool) store(v bool) {
+	var s uint32
+	if v {
+		s = 1
+	}
+	atomic.StoreUint32((*uint32)(b), s)
+}
+func (b *lazyBool) load() bool { return atomic.LoadUint32((*uint32)(b)) == 1 }
@@ -77,9 +77,14 @@ type promisedResp struct {
 
 	// The following block is used for the read / e2e hooks.
 	bytesWritten int
+	queueWait    time.Duration
 	writeWait    time.Duration
 	timeToWrite  time.Duration
 	readEnqueue  time.Time
+
+	// traced is the decoded request for HookBrokerRequestTrace, if
+	// TraceRequests is enabled.
+	traced kmsg.Request
 }
 
 func logID(id int32) string {
@@ -156,6 +161,59 @@ type broker struct {
 	reqs ringReq
 	// dead is an atomic so a backed up reqs cannot block broker stoppage.
 	dead atomicBool
+
+	// The following fields are for ClientStats and persist across
+	// connections.
+	connects  atomicI64
+	errMu     sync.Mutex
+	lastErr   error
+	lastErrAt time.Time
+}
+
+// noteErr saves err as the broker's last error for ClientStats, unless the
+// error is due to the client closing or a request context being canceled.
+func (b *broker) noteErr(err error) {
+	if err == nil || errors.Is(err, ErrClientClosed) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	b.errMu.Lock()
+	defer b.errMu.Unlock()
+	b.lastErr = err
+	b.lastErrAt = time.Now()
+}
+
+func (b *broker) stats() BrokerStats {
+	s := BrokerStats{
+		Meta:     b.meta,
+		Connects: b.connects.Load(),
+	}
+
+	b.errMu.Lock()
+	s.LastErr, s.LastErrAt = b.lastErr, b.lastErrAt
+	b.errMu.Unlock()
+
+	b.reapMu.Lock()
+	defer b.reapMu.Unlock()
+	for _, cxn := range []*brokerCxn{
+		b.cxnFetch,
+		b.cxnGroup,
+		b.cxnNormal,
+		b.cxnProduce,
+		b.cxnSlow,
+	} {
+		if cxn == nil || cxn.dead.Load() {
+			continue
+		}
+		s.Connections = append(s.Connections, ConnectionStats{
+			Purpose:        cxn.purpose,
+			ConnectedAt:    cxn.connectedAt,
+			ConnectLatency: cxn.connectDur,
+			InFlight:       int(cxn.inflight.Load()),
+			BytesWritten:   cxn.bytesWritten.Load(),
+			BytesRead:      cxn.bytesRead.Load(),
+		})
+	}
+	return s
 }
 
 // brokerVersions is loaded once (and potentially a few times concurrently if
@@ -281,6 +339,7 @@ start:
 
 func (b *broker) handleReq(pr promisedReq) {
 	req := pr.req
+	queueWait := time.Since(pr.enqueue) // before loading a connection, which may dial
 	var cxn *brokerCxn
 	var retriedOnNewConnection bool
 start:
@@ -299,6 +358,11 @@ start:
 		}
 	}
 
+	if _, isWarmup := req.(*warmupRequest); isWarmup {
+		pr.promise(nil, nil)
+		return
+	}
+
 	v := b.loadVersions()
 
 	if int(req.Key()) > v.len() || b.cl.cfg.maxVersions != nil && !b.cl.cfg.maxVersions.HasKey(req.Key()) {
@@ -428,15 +492,18 @@ start:
 	corrID, bytesWritten, writeWait, timeToWrite, readEnqueue, writeErr := cxn.writeRequest(pr.ctx, pr.enqueue, req)
 
 	if writeErr != nil {
+		cxn.hookTrace(req, nil, writeErr)
 		pr.promise(nil, writeErr)
 		cxn.die()
-		cxn.hookWriteE2E(req.Key(), bytesWritten, writeWait, timeToWrite, writeErr)
+		cxn.hookWriteE2E(req.Key(), req.GetVersion(), corrID, bytesWritten, queueWait, writeWait, timeToWrite, writeErr)
 		return
 	}
+	traced := cxn.takeTraced()
 
 	if isNoResp {
+		cxn.hookTrace(traced, nil, nil)
 		pr.promise(noResp, nil)
-		cxn.hookWriteE2E(req.Key(), bytesWritten, writeWait, timeToWrite, writeErr)
+		cxn.hookWriteE2E(req.Key(), req.GetVersion(), corrID, bytesWritten, queueWait, writeWait, timeToWrite, writeErr)
 		return
 	}
 
@@ -450,20 +517,98 @@ start:
 		pr.promise,
 		rt,
 		bytesWritten,
+		queueWait,
 		writeWait,
 		timeToWrite,
 		readEnqueue,
+		traced,
 	})
 }
 
-func (cxn *brokerCxn) hookWriteE2E(key int16, bytesWritten int, writeWait, timeToWrite time.Duration, writeErr error) {
+// takeTraced returns and clears the request decoded in the last
+// writeRequest.
+func (cxn *brokerCxn) takeTraced() kmsg.Request {
+	traced := cxn.traced
+	cxn.traced = nil
+	return traced
+}
+
+// hookTrace calls HookBrokerRequestTrace if TraceRequests is enabled,
+// redacting SASL payloads.
+func (cxn *brokerCxn) hookTrace(req kmsg.Request, resp kmsg.Response, err error) {
+	if !cxn.cl.cfg.traceRequests || req == nil {
+		return
+	}
+	switch r := req.(type) {
+	case *kmsg.SASLAuthenticateRequest:
+		redacted := *r
+		redacted.SASLAuthBytes = nil
+		req = &redacted
+	}
+	switch r := resp.(type) {
+	case *kmsg.SASLAuthenticateResponse:
+		redacted := *r
+		redacted.SASLAuthBytes = nil
+		resp = &redacted
+	}
+	cxn.cl.cfg.hooks.each(func(h Hook) {
+		if h, ok := h.(HookBrokerRequestTrace); ok {
+			h.OnBrokerRequestTrace(cxn.b.meta, req, resp, err)
+		}
+	})
+}
+
+// decodeWrittenRequest decodes the request body in buf, which was formatted
+// with AppendRequest for req. If the body cannot be decoded, req itself is
+// returned.
+//
+// Decoded byte slices (e.g., produce records) point into the buffer they are
+// decoded from. buf is returned to the buffer pool once written, so we decode
+// from a copy.
+func decodeWrittenRequest(buf []byte, req kmsg.Request) kmsg.Request {
+	key, version := req.Key(), req.GetVersion()
+	decoded := kmsg.RequestForKey(key)
+	if decoded == nil || len(buf) < 12 {
+		return req
+	}
+	body := buf[12:] // size, key, version, correlation ID
+	if key != 7 || version != 0 {
+		if len(body) < 2 {
+			return req
+		}
+		idLen := int(int16(binary.BigEndian.Uint16(body)))
+		body = body[2:]
+		if idLen > 0 {
+			if len(body) < idLen {
+				return req
+			}
+			body = body[idLen:]
+		}
+		if req.IsFlexible() {
+			if len(body) < 1 {
+				return req
+			}
+			body = body[1:] // we never write header tags
+		}
+	}
+	decoded.SetVersion(version)
+	if err := decoded.ReadFrom(append([]byte(nil), body...)); err != nil {
+		return req
+	}
+	return decoded
+}
+
+func (cxn *brokerCxn) hookWriteE2E(key, version int16, corrID int32, bytesWritten int, queueWait, writeWait, timeToWrite time.Duration, writeErr error) {
 	cxn.cl.cfg.hooks.each(func(h Hook) {
 		if h, ok := h.(HookBrokerE2E); ok {
 			h.OnBrokerE2E(cxn.b.meta, key, BrokerE2E{
-				BytesWritten: bytesWritten,
-				WriteWait:    writeWait,
-				TimeToWrite:  timeToWrite,
-				WriteErr:     writeErr,
+				Version:       version,
+				CorrelationID: corrID,
+				BytesWritten:  bytesWritten,
+				QueueWait:     queueWait,
+				WriteWait:     writeWait,
+				TimeToWrite:   timeToWrite,
+				WriteErr:      writeErr,
 			})
 		}
 	})
@@ -481,33 +626,63 @@ func newBufPool() bufPool {
 func (p bufPool) get() []byte  { return (*p.p.Get().(*[]byte))[:0] }
 func (p bufPool) put(b []byte) { p.p.Put(&b) }
 
+// warmupRequest is an internal request that only loads the connection a
+// request with the same key would use; it is never written. See
+// Client.Warmup.
+type warmupRequest struct{ key int16 }
+
+func (r *warmupRequest) Key() int16      { return r.key }
+func (*warmupRequest) MaxVersion() int16 { return 0 }
+func (*warmupRequest) SetVersion(int16)  {}
+func (*warmupRequest) GetVersion() int16 { return 0 }
+func (*warmupRequest) IsFlexible() bool  { return false }
+func (*warmupRequest) AppendTo([]byte) []byte {
+	panic("unreachable -- the client never writes its internal warmupRequest")
+}
+
+func (*warmupRequest) ReadFrom([]byte) error {
+	panic("unreachable -- the client never uses ReadFrom on its internal warmupRequest")
+}
+
+func (*warmupRequest) ResponseKind() kmsg.Response {
+	panic("unreachable -- the client never reads a response for its internal warmupRequest")
+}
+
 // loadConection returns the broker's connection, creating it if necessary
 // and returning an error of if that fails.
 func (b *broker) loadConnection(ctx context.Context, req kmsg.Request) (*brokerCxn, error) {
 	var (
 		pcxn         = &b.cxnNormal
+		purpose      = "normal"
 		isProduceCxn bool // see docs on brokerCxn.discard for why we do this
 		reqKey       = req.Key()
 		_, isTimeout = req.(kmsg.TimeoutRequest)
 	)
 	switch {
 	case reqKey == 0:
-		pcxn = &b.cxnProduce
+		pcxn, purpose = &b.cxnProduce, "produce"
 		isProduceCxn = true
 	case reqKey == 1:
-		pcxn = &b.cxnFetch
+		pcxn, purpose = &b.cxnFetch, "fetch"
 	case reqKey == 11 || reqKey == 14: // join || sync
-		pcxn = &b.cxnGroup
+		pcxn, purpose = &b.cxnGroup, "group"
 	case isTimeout:
-		pcxn = &b.cxnSlow
+		pcxn, purpose = &b.cxnSlow, "slow"
 	}
 
-	if *pcxn != nil && !(*pcxn).dead.Load() {
-		return *pcxn, nil
+	if cxn := *pcxn; cxn != nil && !cxn.dead.Load() {
+		why := cxn.recycleWhy(time.Now())
+		if why == "" {
+			return cxn, nil
+		}
+		b.cl.cfg.logger.Log(LogLevelDebug, "recycling connection", "addr", b.addr, "broker", logID(b.meta.NodeID), "purpose", purpose, "why", why)
+		cxn.die()
 	}
 
+	start := time.Now()
 	conn, err := b.connect(ctx)
 	if err != nil {
+		b.noteErr(err)
 		return nil, err
 	}
 
@@ -515,15 +690,20 @@ func (b *broker) loadConnection(ctx context.Context, req kmsg.Request) (*brokerC
 		cl: b.cl,
 		b:  b,
 
-		addr:   b.addr,
-		conn:   conn,
-		deadCh: make(chan struct{}),
+		addr:    b.addr,
+		conn:    conn,
+		deadCh:  make(chan struct{}),
+		purpose: purpose,
 	}
 	if err = cxn.init(isProduceCxn); err != nil {
 		b.cl.cfg.logger.Log(LogLevelDebug, "connection initialization failed", "addr", b.addr, "broker", logID(b.meta.NodeID), "err", err)
+		b.noteErr(err)
 		cxn.closeConn()
 		return nil, err
 	}
+	cxn.connectedAt = time.Now()
+	cxn.connectDur = cxn.connectedAt.Sub(start)
+	b.connects.Add(1)
 	b.cl.cfg.logger.Log(LogLevelDebug, "connection initialized successfully", "addr", b.addr, "broker", logID(b.meta.NodeID))
 
 	b.reapMu.Lock()
@@ -599,7 +779,7 @@ func (b *broker) reapConnections(idleTimeout time.Duration) (total int) {
 		writeIdle := time.Since(lastWrite) > idleTimeout && !cxn.writing.Load()
 		readIdle := time.Since(lastRead) > idleTimeout && !cxn.reading.Load()
 
-		if writeIdle && readIdle {
+		if writeIdle && readIdle || cxn.outlived(time.Now()) && !cxn.busy() {
 			cxn.die()
 			total++
 		}
@@ -607,11 +787,36 @@ func (b *broker) reapConnections(idleTimeout time.Duration) (total int) {
 	return total
 }
 
+// dialBrokerKey is a context key for the metadata of the broker being dialed,
+// used for DialTLSServerName and BrokerAddressMapper.
+type dialBrokerKey struct{}
+
+// dialAddr returns the address to dial for the broker, which is the broker's
+// advertised address unless remapped with BrokerAddressMapper.
+func (b *broker) dialAddr() (string, error) {
+	if b.cl.cfg.brokerAddrFn == nil {
+		return b.addr, nil
+	}
+	addr, err := b.cl.cfg.brokerAddrFn(b.meta)
+	if err != nil {
+		return "", fmt.Errorf("unable to map broker address %s: %w", b.addr, err)
+	}
+	if addr == "" {
+		return b.addr, nil
+	}
+	return addr, nil
+}
+
 // connect connects to the broker's addr, returning the new connection.
 func (b *broker) connect(ctx context.Context) (net.Conn, error) {
-	b.cl.cfg.logger.Log(LogLevelDebug, "opening connection to broker", "addr", b.addr, "broker", logID(b.meta.NodeID))
+	addr, err := b.dialAddr()
+	if err != nil {
+		b.cl.cfg.logger.Log(LogLevelWarn, "unable to open connection to broker", "addr", b.addr, "broker", logID(b.meta.NodeID), "err", err)
+		return nil, err
+	}
+	b.cl.cfg.logger.Log(LogLevelDebug, "opening connection to broker", "addr", b.addr, "dial_addr", addr, "broker", logID(b.meta.NodeID))
 	start := time.Now()
-	conn, err := b.cl.cfg.dialFn(ctx, "tcp", b.addr)
+	conn, err := b.cl.cfg.dialFn(context.WithValue(ctx, dialBrokerKey{}, b.meta), "tcp", addr)
 	since := time.Since(start)
 	b.cl.cfg.hooks.each(func(h Hook) {
 		if h, ok := h.(HookBrokerConnect); ok {
@@ -649,6 +854,11 @@ type brokerCxn struct {
 
 	corrID int32
 
+	// traced is the last written request decoded from its wire bytes, if
+	// TraceRequests is enabled. Writes are serialized on a connection and
+	// the writer takes this immediately after writing.
+	traced kmsg.Request
+
 	// The following four fields are used for connection reaping.
 	// Write is only updated in one location; read is updated in three
 	// due to readConn, readConnAsync, and discard.
@@ -659,6 +869,14 @@ type brokerCxn struct {
 
 	successes uint64
 
+	// The following fields are for ClientStats.
+	purpose      string
+	connectedAt  time.Time
+	connectDur   time.Duration // time to dial and initialize (api versions, sasl)
+	bytesWritten atomicI64
+	bytesRead    atomicI64
+	inflight     atomicI32
+
 	// resps manages reading kafka responses.
 	resps ringResp
 	// dead is an atomic so that a backed up resps cannot block cxn death.
@@ -667,6 +885,43 @@ type brokerCxn struct {
 	deadCh chan struct{}
 }
 
+// outlived returns whether the connection is older than ConnMaxLifetime.
+func (cxn *brokerCxn) outlived(now time.Time) bool {
+	lifetime := cxn.cl.cfg.connMaxLifetime
+	return lifetime > 0 && now.Sub(cxn.connectedAt) > lifetime
+}
+
+// busy returns whether the connection is writing a request, reading a
+// response, or has requests in flight. A request is only counted as in flight
+// once it is written, so we must check writing as well.
+func (cxn *brokerCxn) busy() bool {
+	return cxn.inflight.Load() != 0 || cxn.writing.Load() || cxn.reading.Load()
+}
+
+// recycleWhy returns why the connection should be replaced before it is
+// next used, if it is not busy and is past ConnMaxLifetime or
+// ConnMaxIdleTime, or an empty string if it can be used.
+func (cxn *brokerCxn) recycleWhy(now time.Time) string {
+	if cxn.busy() {
+		return ""
+	}
+	if cxn.outlived(now) {
+		return "max lifetime"
+	}
+	if idle := cxn.cl.cfg.connMaxIdleTime; idle > 0 {
+		lastActive := cxn.connectedAt
+		for _, at := range []int64{cxn.lastWrite.Load(), cxn.lastRead.Load()} {
+			if t := time.Unix(0, at); t.After(lastActive) {
+				lastActive = t
+			}
+		}
+		if now.Sub(lastActive) > idle {
+			return "max idle time"
+		}
+	}
+	return ""
+}
+
 func (cxn *brokerCxn) init(isProduceCxn bool) error {
 	hasVersions := cxn.b.loadVersions() != nil
 	if !hasVersions {
@@ -719,14 +974,16 @@ start:
 	cxn.cl.cfg.logger.Log(LogLevelDebug, "issuing api versions request", "broker", logID(cxn.b.meta.NodeID), "version", maxVersion)
 	corrID, bytesWritten, writeWait, timeToWrite, readEnqueue, writeErr := cxn.writeRequest(nil, time.Now(), req)
 	if writeErr != nil {
-		cxn.hookWriteE2E(req.Key(), bytesWritten, writeWait, timeToWrite, writeErr)
+		cxn.hookWriteE2E(req.Key(), req.GetVersion(), corrID, bytesWritten, 0, writeWait, timeToWrite, writeErr)
 		return writeErr
 	}
 
 	rt, _ := cxn.cl.connTimeouter.timeouts(req)
 	// api versions does *not* use flexible response headers; see comment in promisedResp
-	rawResp, err := cxn.readResponse(nil, req.Key(), req.GetVersion(), corrID, false, rt, bytesWritten, writeWait, timeToWrite, readEnqueue)
+	traced := cxn.takeTraced()
+	rawResp, err := cxn.readResponse(nil, req.Key(), req.GetVersion(), corrID, false, rt, bytesWritten, 0, writeWait, timeToWrite, readEnqueue)
 	if err != nil {
+		cxn.hookTrace(traced, nil, err)
 		return err
 	}
 	if len(rawResp) < 2 {
@@ -756,7 +1013,9 @@ start:
 		resp.Version = 0
 	}
 
-	if err = resp.ReadFrom(rawResp); err != nil {
+	err = resp.ReadFrom(rawResp)
+	cxn.hookTrace(traced, resp, err)
+	if err != nil {
 		return fmt.Errorf("unable to read ApiVersions response: %w", err)
 	}
 	if len(resp.ApiKeys) == 0 {
@@ -792,17 +1051,21 @@ start:
 		cxn.cl.cfg.logger.Log(LogLevelDebug, "issuing SASLHandshakeRequest", "broker", logID(cxn.b.meta.NodeID))
 		corrID, bytesWritten, writeWait, timeToWrite, readEnqueue, writeErr := cxn.writeRequest(nil, time.Now(), req)
 		if writeErr != nil {
-			cxn.hookWriteE2E(req.Key(), bytesWritten, writeWait, timeToWrite, writeErr)
+			cxn.hookWriteE2E(req.Key(), req.GetVersion(), corrID, bytesWritten, 0, writeWait, timeToWrite, writeErr)
 			return writeErr
 		}
 
 		rt, _ := cxn.cl.connTimeouter.timeouts(req)
-		rawResp, err := cxn.readResponse(nil, req.Key(), req.GetVersion(), corrID, req.IsFlexible(), rt, bytesWritten, writeWait, timeToWrite, readEnqueue)
+		traced := cxn.takeTraced()
+		rawResp, err := cxn.readResponse(nil, req.Key(), req.GetVersion(), corrID, req.IsFlexible(), rt, bytesWritten, 0, writeWait, timeToWrite, readEnqueue)
 		if err != nil {
+			cxn.hookTrace(traced, nil, err)
 			return err
 		}
 		resp := req.ResponseKind().(*kmsg.SASLHandshakeResponse)
-		if err = resp.ReadFrom(rawResp); err != nil {
+		err = resp.ReadFrom(rawResp)
+		cxn.hookTrace(traced, resp, err)
+		if err != nil {
 			return err
 		}
 
@@ -887,18 +1150,24 @@ func (cxn *brokerCxn) doSasl(authenticate bool) error {
 			// without reading a response back (kerberos). If this
 			// is the case, we need to e2e.
 			if writeErr != nil || done {
-				cxn.hookWriteE2E(req.Key(), bytesWritten, writeWait, timeToWrite, writeErr)
+				cxn.hookWriteE2E(req.Key(), req.GetVersion(), corrID, bytesWritten, 0, writeWait, timeToWrite, writeErr)
 				if writeErr != nil {
 					return writeErr
 				}
 			}
-			if !done {
-				rawResp, err := cxn.readResponse(nil, req.Key(), req.GetVersion(), corrID, req.IsFlexible(), rt, bytesWritten, writeWait, timeToWrite, readEnqueue)
+			traced := cxn.takeTraced()
+			if done {
+				cxn.hookTrace(traced, nil, nil)
+			} else {
+				rawResp, err := cxn.readResponse(nil, req.Key(), req.GetVersion(), corrID, req.IsFlexible(), rt, bytesWritten, 0, writeWait, timeToWrite, readEnqueue)
 				if err != nil {
+					cxn.hookTrace(traced, nil, err)
 					return err
 				}
 				resp := req.ResponseKind().(*kmsg.SASLAuthenticateResponse)
-				if err = resp.ReadFrom(rawResp); err != nil {
+				err = resp.ReadFrom(rawResp)
+				cxn.hookTrace(traced, resp, err)
+				if err != nil {
 					return err
 				}
 
@@ -1004,17 +1273,25 @@ func (cxn *brokerCxn) writeRequest(ctx context.Context, enqueuedForWritingAt tim
 		}
 	}
 
+	corrID = cxn.corrID
 	buf := cxn.cl.reqFormatter.AppendRequest(
 		cxn.cl.bufPool.get()[:0],
 		req,
-		cxn.corrID,
+		corrID,
 	)
 
 	_, wt := cxn.cl.connTimeouter.timeouts(req)
 	bytesWritten, writeWait, timeToWrite, readEnqueue, writeErr = cxn.writeConn(ctx, buf, wt, enqueuedForWritingAt)
 
+	if cxn.cl.cfg.traceRequests && writeErr == nil {
+		cxn.traced = decodeWrittenRequest(buf, req)
+	}
+
 	cxn.cl.bufPool.put(buf)
 
+	cxn.bytesWritten.Add(int64(bytesWritten))
+	cxn.b.noteErr(writeErr)
+
 	cxn.cl.cfg.hooks.each(func(h Hook) {
 		if h, ok := h.(HookBrokerWrite); ok {
 			h.OnBrokerWrite(cxn.b.meta, req.Key(), bytesWritten, writeWait, timeToWrite, writeErr)
@@ -1027,7 +1304,6 @@ func (cxn *brokerCxn) writeRequest(ctx context.Context, enqueuedForWritingAt tim
 	if writeErr != nil {
 		return
 	}
-	corrID = cxn.corrID
 	cxn.corrID++
 	if cxn.corrID < 0 {
 		cxn.corrID = 0
@@ -1198,11 +1474,14 @@ func (cxn *brokerCxn) readResponse(
 	flexibleHeader bool,
 	timeout time.Duration,
 	bytesWritten int,
+	queueWait time.Duration,
 	writeWait time.Duration,
 	timeToWrite time.Duration,
 	readEnqueue time.Time,
 ) ([]byte, error) {
 	bytesRead, buf, readWait, timeToRead, readErr := cxn.readConn(ctx, timeout, readEnqueue)
+	cxn.bytesRead.Add(int64(bytesRead))
+	cxn.b.noteErr(readErr)
 
 	cxn.cl.cfg.hooks.each(func(h Hook) {
 		switch h := h.(type) {
@@ -1210,13 +1489,16 @@ func (cxn *brokerCxn) readResponse(
 			h.OnBrokerRead(cxn.b.meta, key, bytesRead, readWait, timeToRead, readErr)
 		case HookBrokerE2E:
 			h.OnBrokerE2E(cxn.b.meta, key, BrokerE2E{
-				BytesWritten: bytesWritten,
-				BytesRead:    bytesRead,
-				WriteWait:    writeWait,
-				TimeToWrite:  timeToWrite,
-				ReadWait:     readWait,
-				TimeToRead:   timeToRead,
-				ReadErr:      readErr,
+				Version:       version,
+				CorrelationID: corrID,
+				BytesWritten:  bytesWritten,
+				BytesRead:     bytesRead,
+				QueueWait:     queueWait,
+				WriteWait:     writeWait,
+				TimeToWrite:   timeToWrite,
+				ReadWait:      readWait,
+				TimeToRead:    timeToRead,
+				ReadErr:       readErr,
 			})
 		}
 	})
@@ -1270,12 +1552,14 @@ func (cxn *brokerCxn) die() {
 // waitResp, called serially by a broker's handleReqs, manages handling a
 // message requests's response.
 func (cxn *brokerCxn) waitResp(pr promisedResp) {
+	cxn.inflight.Add(1)
 	first, dead := cxn.resps.push(pr)
 	if first {
 		go cxn.handleResps(pr)
 	} else if dead {
+		cxn.inflight.Add(-1)
 		pr.promise(nil, errChosenBrokerDead)
-		cxn.hookWriteE2E(pr.resp.Key(), pr.bytesWritten, pr.writeWait, pr.timeToWrite, errChosenBrokerDead)
+		cxn.hookWriteE2E(pr.resp.Key(), pr.resp.GetVersion(), pr.corrID, pr.bytesWritten, pr.queueWait, pr.writeWait, pr.timeToWrite, errChosenBrokerDead)
 	}
 }
 
@@ -1404,6 +1688,7 @@ func (cxn *brokerCxn) discard() {
 			return
 		}
 
+		cxn.bytesRead.Add(int64(nread))
 		cxn.cl.cfg.hooks.each(func(h Hook) {
 			if h, ok := h.(HookBrokerRead); ok {
 				h.OnBrokerRead(cxn.b.meta, 0, nread, 0, timeToRead, err)
@@ -1421,10 +1706,11 @@ func (cxn *brokerCxn) handleResps(pr promisedResp) {
 start:
 	if dead {
 		pr.promise(nil, errChosenBrokerDead)
-		cxn.hookWriteE2E(pr.resp.Key(), pr.bytesWritten, pr.writeWait, pr.timeToWrite, errChosenBrokerDead)
+		cxn.hookWriteE2E(pr.resp.Key(), pr.resp.GetVersion(), pr.corrID, pr.bytesWritten, pr.queueWait, pr.writeWait, pr.timeToWrite, errChosenBrokerDead)
 	} else {
 		cxn.handleResp(pr)
 	}
+	cxn.inflight.Add(-1)
 
 	pr, more, dead = cxn.resps.dropPeek()
 	if more {
@@ -1441,6 +1727,7 @@ func (cxn *brokerCxn) handleResp(pr promisedResp) {
 		pr.flexibleHeader,
 		pr.readTimeout,
 		pr.bytesWritten,
+		pr.queueWait,
 		pr.writeWait,
 		pr.timeToWrite,
 		pr.readEnqueue,
@@ -1456,6 +1743,7 @@ func (cxn *brokerCxn) handleResp(pr promisedResp) {
 				}
 			}
 		}
+		cxn.hookTrace(pr.traced, nil, err)
 		pr.promise(nil, err)
 		cxn.die()
 		return
@@ -1463,6 +1751,7 @@ func (cxn *brokerCxn) handleResp(pr promisedResp) {
 
 	cxn.successes++
 	readErr := pr.resp.ReadFrom(rawResp)
+	cxn.hookTrace(pr.traced, pr.resp, readErr)
 
 	// If we had no error, we read the response successfully.
 	//
@@ -1479,9 +1768,15 @@ func (cxn *brokerCxn) handleResp(pr promisedResp) {
 						cxn.throttleUntil.Store(throttleUntil)
 					}
 				}
+				throttle := time.Duration(millis) * time.Millisecond
+				key := pr.resp.Key()
+				cxn.cl.addThrottle(cxn.b.meta.NodeID, key, throttle)
 				cxn.cl.cfg.hooks.each(func(h Hook) {
 					if h, ok := h.(HookBrokerThrottle); ok {
-						h.OnBrokerThrottle(cxn.b.meta, time.Duration(millis)*time.Millisecond, throttlesAfterResp)
+						h.OnBrokerThrottle(cxn.b.meta, throttle, throttlesAfterResp)
+					}
+					if h, ok := h.(HookBrokerThrottleKey); ok {
+						h.OnBrokerThrottleKey(cxn.b.meta, key, throttle, throttlesAfterResp)
 					}
 				})
 			}
@@ -33,7 +33,8 @@ var crc32c = crc32.MakeTable(crc32.Castagnoli) // record crc's use Castagnoli ta
 
 // Client issues requests and handles responses to a Kafka cluster.
 type Client struct {
-	cfg cfg
+	cfg           cfg
+	reconfigureMu sync.Mutex // serializes writes to live options in cfg
 
 	ctx       context.Context
 	ctxCancel func()
@@ -77,8 +78,9 @@ type Client struct {
 	producer producer
 	consumer consumer
 
-	compressor   *compressor
-	decompressor *decompressor
+	compressor       *compressor
+	topicCompressors map[string]*compressor // per-topic compressor overrides
+	decompressor     *decompressor
 
 	coordinatorsMu sync.Mutex
 	coordinators   map[coordinatorKey]*coordinatorLoad
@@ -91,6 +93,11 @@ type Client struct {
 
 	mappedMetaMu sync.Mutex
 	mappedMeta   map[string]mappedMetadataTopic
+
+	throttlesMu sync.Mutex
+	throttles   map[int32]map[int16]ThrottleStats // broker => request key => stats
+
+	telemetry telemetry // KIP-714 client metrics
 }
 
 func (cl *Client) idempotent() bool { return !cl.cfg.disableIdempotency }
@@ -116,7 +123,7 @@ type hostport struct {
 
 // ValidateOpts returns an error if the options are invalid.
 func ValidateOpts(opts ...Opt) error {
-	_, _, _, err := validateCfg(opts...)
+	_, _, _, _, err := validateCfg(opts...)
 	return err
 }
 
@@ -135,23 +142,38 @@ func parseSeeds(addrs []string) ([]hostport, error) {
 // This function validates the configuration and returns a few things that we
 // initialize while validating. The difference between this and NewClient
 // initialization is all NewClient initialization is infallible.
-func validateCfg(opts ...Opt) (cfg, []hostport, *compressor, error) {
+func validateCfg(opts ...Opt) (cfg, []hostport, *compressor, map[string]*compressor, error) {
 	cfg := defaultCfg()
 	for _, opt := range opts {
 		opt.apply(&cfg)
 	}
 	if err := cfg.validate(); err != nil {
-		return cfg, nil, nil, err
+		return cfg, nil, nil, nil, err
 	}
 	seeds, err := parseSeeds(cfg.seedBrokers)
 	if err != nil {
-		return cfg, nil, nil, err
+		return cfg, nil, nil, nil, err
 	}
-	compressor, err := newCompressor(cfg.compression...)
+	cfg.applyNamespace()
+	defCompressor, err := newCompressor(cfg.compression...)
 	if err != nil {
-		return cfg, nil, nil, err
+		return cfg, nil, nil, nil, err
+	}
+	var topicCompressors map[string]*compressor
+	for topic, codecs := range cfg.topicCompression {
+		c, err := newCompressor(codecs...)
+		if err != nil {
+			return cfg, nil, nil, nil, fmt.Errorf("invalid compression for topic %q: %w", topic, err)
+		}
+		if topicCompressors == nil {
+			topicCompressors = make(map[string]*compressor)
+		}
+		if cfg.topicToKafka != nil {
+			topic = cfg.topicToKafka(topic)
+		}
+		topicCompressors[topic] = c
 	}
-	return cfg, seeds, compressor, nil
+	return cfg, seeds, defCompressor, topicCompressors, nil
 }
 
 func namefn(fn any) string {
@@ -236,18 +258,40 @@ func (cl *Client) OptValues(opt any) []any {
 	case namefn(SoftwareNameAndVersion):
 		return []any{cfg.softwareName, cfg.softwareVersion}
 	case namefn(WithLogger):
-		if cfg.logger != nil {
-			return []any{cfg.logger.(*wrappedLogger).inner}
-		}
-		return []any{nil}
+		return []any{cfg.logger.load()}
 	case namefn(RequestTimeoutOverhead):
 		return []any{cfg.requestTimeoutOverhead}
 	case namefn(ConnIdleTimeout):
 		return []any{cfg.connIdleTimeout}
+	case namefn(ConnMaxLifetime):
+		return []any{cfg.connMaxLifetime}
+	case namefn(ConnMaxIdleTime):
+		return []any{cfg.connMaxIdleTime}
+	case namefn(DisableClientMetrics):
+		return []any{cfg.disableClientMetrics}
+	case namefn(TraceRequests):
+		return []any{cfg.traceRequests}
+	case namefn(LazyConnect):
+		return []any{cfg.lazyConnect}
 	case namefn(Dialer):
 		return []any{cfg.dialFn}
 	case namefn(DialTLSConfig):
 		return []any{cfg.dialTLS}
+	case namefn(DialTLSConfigFn):
+		return []any{cfg.dialTLSFn}
+	case namefn(DialTLSServerName):
+		return []any{cfg.dialTLSServerName}
+	case namefn(BrokerAddressMapper):
+		return []any{cfg.brokerAddrFn}
+	case namefn(TopicNamespace):
+		return []any{cfg.topicToKafka, cfg.topicFromKafka}
+	case namefn(DialProxy):
+		if cfg.proxy != nil {
+			return []any{cfg.proxy.url()}
+		}
+		return []any{cfg.dialProxy}
+	case namefn(Resolver):
+		return []any{cfg.resolver}
 	case namefn(SeedBrokers):
 		return []any{cfg.seedBrokers}
 	case namefn(MaxVersions):
@@ -262,6 +306,8 @@ func (cl *Client) OptValues(opt any) []any {
 		return []any{cfg.retryTimeout(0)}
 	case namefn(RetryTimeoutFn):
 		return []any{cfg.retryTimeout}
+	case namefn(RequestRetryPolicy):
+		return []any{cfg.retryPolicy}
 	case namefn(AllowAutoTopicCreation):
 		return []any{cfg.allowAutoTopicCreation}
 	case namefn(BrokerMaxWriteBytes):
@@ -272,8 +318,14 @@ func (cl *Client) OptValues(opt any) []any {
 		return []any{cfg.metadataMaxAge}
 	case namefn(MetadataMinAge):
 		return []any{cfg.metadataMinAge}
+	case namefn(MetadataTopicsMaxAge):
+		return []any{cfg.metadataTopicsMaxAge}
+	case namefn(MetadataTopicsMinAge):
+		return []any{cfg.metadataTopicsMinAge}
 	case namefn(SASL):
 		return []any{cfg.sasls}
+	case namefn(WithValueTransformer):
+		return []any{cfg.valueTransformer, cfg.valueTransformerTopics}
 	case namefn(WithHooks):
 		return []any{cfg.hooks}
 	case namefn(ConcurrentTransactionsBackoff):
@@ -287,18 +339,30 @@ func (cl *Client) OptValues(opt any) []any {
 		return []any{cfg.disableIdempotency}
 	case namefn(MaxProduceRequestsInflightPerBroker):
 		return []any{cfg.maxProduceInflight}
+	case namefn(ProducerBatchAffinity):
+		return []any{cfg.batchAffinity}
 	case namefn(ProducerBatchCompression):
 		return []any{cfg.compression}
 	case namefn(ProducerBatchMaxBytes):
 		return []any{cfg.maxRecordBatchBytes}
+	case namefn(ProducerValidateMaxMessageBytes):
+		return []any{cfg.validateMaxMsgBytes}
+	case namefn(MaxBufferedBytesPerPriority):
+		return []any{cfg.maxPrioBufferedBytes}
 	case namefn(MaxBufferedRecords):
-		return []any{cfg.maxBufferedRecords}
+		return []any{cfg.maxBufferedRecords.load()}
+	case namefn(ProduceRateLimit):
+		return []any{cfg.rateBytes, cfg.rateRecords}
+	case namefn(ProduceRateLimitPerTopic):
+		return []any{cfg.topicRateBytes, cfg.topicRateRecords}
+	case namefn(ProduceRateLimitFail):
+		return []any{cfg.rateLimitFail}
 	case namefn(RecordPartitioner):
 		return []any{cfg.partitioner}
 	case namefn(ProduceRequestTimeout):
 		return []any{cfg.produceTimeout}
 	case namefn(RecordRetries):
-		return []any{cfg.recordRetries}
+		return []any{cfg.recordRetries.load()}
 	case namefn(UnknownTopicRetries):
 		return []any{cfg.maxUnknownFailures}
 	case namefn(StopProducerOnDataLossDetected):
@@ -306,11 +370,13 @@ func (cl *Client) OptValues(opt any) []any {
 	case namefn(ProducerOnDataLossDetected):
 		return []any{cfg.onDataLoss}
 	case namefn(ProducerLinger):
-		return []any{cfg.linger}
+		return []any{time.Duration(cfg.linger.load())}
 	case namefn(ManualFlushing):
 		return []any{cfg.manualFlushing}
 	case namefn(RecordDeliveryTimeout):
 		return []any{cfg.recordTimeout}
+	case namefn(ProduceAckSampling):
+		return []any{cfg.ackSampling}
 	case namefn(TransactionalID):
 		if cfg.txnID != nil {
 			return []any{cfg.txnID, true}
@@ -325,26 +391,56 @@ func (cl *Client) OptValues(opt any) []any {
 		return []any{cfg.preferLagFn}
 	case namefn(ConsumeRegex):
 		return []any{cfg.regex}
+	case namefn(ConsumeRegexRefreshInterval):
+		return []any{cfg.regexRefresh}
+	case namefn(OnRegexTopicsAdded):
+		return []any{cfg.onRegexAdded}
+	case namefn(OnRegexTopicsRemoved):
+		return []any{cfg.onRegexRemoved}
 	case namefn(ConsumeResetOffset):
 		return []any{cfg.resetOffset}
+	case namefn(OnOffsetOutOfRange):
+		return []any{cfg.onOutOfRange}
+	case namefn(OnLogTruncation):
+		return []any{cfg.onLogTruncation}
 	case namefn(ConsumeTopics):
 		return []any{cfg.topics}
 	case namefn(DisableFetchSessions):
 		return []any{cfg.disableFetchSessions}
 	case namefn(FetchIsolationLevel):
 		return []any{cfg.isolationLevel}
+	case namefn(FetchHedgeAfter):
+		return []any{cfg.fetchHedgeAfter}
 	case namefn(FetchMaxBytes):
-		return []any{int32(cfg.maxBytes)}
+		return []any{cfg.maxBytes.load()}
 	case namefn(FetchMaxPartitionBytes):
-		return []any{int32(cfg.maxPartBytes)}
+		return []any{cfg.maxPartBytes.load()}
+	case namefn(AdaptiveFetchMaxPartitionBytes):
+		return []any{cfg.adaptiveMaxPartBytes}
 	case namefn(FetchMaxWait):
 		return []any{time.Duration(cfg.maxWait) * time.Millisecond}
 	case namefn(FetchMinBytes):
 		return []any{cfg.minBytes}
+	case namefn(FetchReplicaSelector):
+		return []any{cfg.replicaSelector}
+	case namefn(PreferredReplicaMaxAge):
+		return []any{cfg.replicaMaxAge}
+	case namefn(PreferredReplicaMaxErrors):
+		return []any{cfg.replicaMaxErrs}
+	case namefn(OnReplicaSwitch):
+		return []any{cfg.onReplicaSwitch}
 	case namefn(KeepControlRecords):
 		return []any{cfg.keepControl}
+	case namefn(ConsumeRawBatches):
+		return []any{cfg.rawBatches}
+	case namefn(PoolFetchBuffers):
+		return []any{cfg.poolBuffers}
 	case namefn(MaxConcurrentFetches):
 		return []any{cfg.maxConcurrentFetches}
+	case namefn(FetchDecodeConcurrency):
+		return []any{cfg.fetchDecodeConcurrency}
+	case namefn(MaxPollRecordsPerPartition):
+		return []any{cfg.maxPollPartitionRecords}
 	case namefn(Rack):
 		return []any{cfg.rack}
 
@@ -360,6 +456,12 @@ func (cl *Client) OptValues(opt any) []any {
 		return []any{cfg.balancers}
 	case namefn(BlockRebalanceOnPoll):
 		return []any{cfg.blockRebalanceOnPoll}
+	case namefn(CommitCoalesceInterval):
+		return []any{cfg.commitCoalesceInterval}
+	case namefn(CommitCoalesceNoRebalanceFlush):
+		return []any{cfg.commitNoRebalanceFlush}
+	case namefn(CommitMaxPartitions):
+		return []any{cfg.commitMaxPartitions}
 	case namefn(ConsumerGroup):
 		return []any{cfg.group}
 	case namefn(DisableAutoCommit):
@@ -394,6 +496,99 @@ func (cl *Client) OptValues(opt any) []any {
 	}
 }
 
+// Reconfigure applies options to a live client, allowing some tuning without
+// restarting the client (and rejoining a group). Only the following options
+// can be reconfigured:
+//
+//   - WithLogger, which can be used to change the log level
+//   - DialProxy, if the client was created with DialProxy
+//   - FetchMaxBytes and FetchMaxPartitionBytes, used on the next fetch
+//   - MaxBufferedRecords
+//   - ProducerLinger
+//   - RecordRetries
+//
+// If any option cannot be reconfigured or any value is invalid, this returns
+// an error and no options are applied. Options that are not passed keep their
+// current values.
+func (cl *Client) Reconfigure(opts ...Opt) error {
+	for i, opt := range opts {
+		if _, ok := opt.(interface{ live() }); !ok {
+			return fmt.Errorf("option %d (%s) cannot be reconfigured on a live client", i, optName(opt))
+		}
+	}
+
+	cl.reconfigureMu.Lock()
+	defer cl.reconfigureMu.Unlock()
+
+	// Live options only modify the fields below, so we apply them to a
+	// partial config to validate before storing anything.
+	live := &cl.cfg
+	c := cfg{
+		logger:             new(wrappedLogger),
+		maxBytes:           lazyI32(live.maxBytes.load()),
+		maxPartBytes:       lazyI32(live.maxPartBytes.load()),
+		maxBufferedRecords: lazyI64(live.maxBufferedRecords.load()),
+		recordRetries:      lazyI64(live.recordRetries.load()),
+		linger:             lazyI64(live.linger.load()),
+	}
+	for _, opt := range opts {
+		opt.apply(&c)
+	}
+
+	switch {
+	case c.maxBufferedRecords < 1:
+		return fmt.Errorf("max buffered records %d is less than allowed 1", c.maxBufferedRecords)
+	case c.linger < 0 || time.Duration(c.linger) > time.Minute:
+		return fmt.Errorf("linger %v is outside of the allowed range [0, 1m]", time.Duration(c.linger))
+	case c.maxBytes < 1 || c.maxPartBytes < 1:
+		return fmt.Errorf("max fetch bytes %d and max fetch partition bytes %d must be positive", c.maxBytes, c.maxPartBytes)
+	case int32(c.maxBytes) > live.maxBrokerReadBytes:
+		return fmt.Errorf("max fetch bytes %d is erroneously larger than max broker read bytes %d", c.maxBytes, live.maxBrokerReadBytes)
+	}
+	if c.dialProxy != nil {
+		if live.proxy == nil {
+			return errors.New("DialProxy can only be reconfigured on a client created with DialProxy")
+		}
+		if err := validateProxyURL(c.dialProxy); err != nil {
+			return err
+		}
+	}
+	if c.maxPartBytes > c.maxBytes {
+		c.maxPartBytes = c.maxBytes
+	}
+
+	if _, set := c.logger.inner.Load().(innerLogger); set {
+		live.logger.store(c.logger.load())
+	}
+	live.maxBytes.store(int32(c.maxBytes))
+	live.maxPartBytes.store(int32(c.maxPartBytes))
+	live.recordRetries.store(int64(c.recordRetries))
+	live.linger.store(int64(c.linger))
+	if c.dialProxy != nil {
+		live.proxy.setURL(c.dialProxy)
+	}
+	cl.producer.setMaxBufferedRecords(int64(c.maxBufferedRecords))
+	return nil
+}
+
+// optName returns the name of the function that created an option, for
+// error messages.
+func optName(opt Opt) string {
+	v := reflect.ValueOf(opt)
+	if v.Kind() == reflect.Struct && v.NumField() > 0 {
+		v = v.Field(0)
+	}
+	if v.Kind() != reflect.Func {
+		return fmt.Sprintf("%T", opt)
+	}
+	name := runtime.FuncForPC(v.Pointer()).Name() // e.g. github.com/twmb/franz-go/pkg/kgo.Rack.func1
+	name = strings.TrimSuffix(name, ".func1")
+	if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+		name = name[dot+1:]
+	}
+	return name
+}
+
 // NewClient returns a new Kafka client with the given options or an error if
 // the options are invalid. Connections to brokers are lazily created only when
 // requests are written to them.
@@ -407,7 +602,7 @@ func (cl *Client) OptValues(opt any) []any {
 // NewClient also launches a goroutine which periodically updates the cached
 // topic metadata.
 func NewClient(opts ...Opt) (*Client, error) {
-	cfg, seeds, compressor, err := validateCfg(opts...)
+	cfg, seeds, compressor, topicCompressors, err := validateCfg(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -427,9 +622,47 @@ func NewClient(opts ...Opt) (*Client, error) {
 	if cfg.dialFn == nil {
 		dialer := &net.Dialer{Timeout: cfg.dialTimeout}
 		cfg.dialFn = dialer.DialContext
-		if cfg.dialTLS != nil {
+		if cfg.resolver != nil {
 			cfg.dialFn = func(ctx context.Context, network, host string) (net.Conn, error) {
-				c := cfg.dialTLS.Clone()
+				return dialResolved(ctx, cfg.resolver, dialer, network, host)
+			}
+		}
+		netDial := cfg.dialFn
+		var proxy *proxyDialer
+		if cfg.dialProxy != nil {
+			proxy = newProxyDialer(cfg.dialProxy, dialer, cfg.resolver)
+			cfg.proxy = proxy
+			cfg.dialFn = proxy.DialContext
+			netDial = cfg.dialFn
+		}
+		if cfg.dialTLS != nil || cfg.dialTLSFn != nil {
+			loadTLS := func(context.Context) (*tls.Config, error) { return cfg.dialTLS, nil }
+			if cfg.dialTLSFn != nil {
+				loadTLS = cfg.dialTLSFn
+			}
+			cfg.dialFn = func(ctx context.Context, network, host string) (net.Conn, error) {
+				c, err := loadTLS(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("unable to load tls config for dialing: %w", err)
+				}
+				if c == nil {
+					return nil, errors.New("unable to load tls config for dialing: config is nil")
+				}
+				c = c.Clone()
+				if meta, ok := ctx.Value(dialBrokerKey{}).(BrokerMetadata); ok {
+					if cfg.dialTLSServerName != nil {
+						name, skip := cfg.dialTLSServerName(meta)
+						if name != "" {
+							c.ServerName = name
+						}
+						if skip {
+							c.InsecureSkipVerify = true
+						}
+					}
+					if c.ServerName == "" && cfg.brokerAddrFn != nil {
+						c.ServerName = meta.Host
+					}
+				}
 				if c.ServerName == "" {
 					server, _, err := net.SplitHostPort(host)
 					if err != nil {
@@ -437,10 +670,27 @@ func NewClient(opts ...Opt) (*Client, error) {
 					}
 					c.ServerName = server
 				}
-				return (&tls.Dialer{
-					NetDialer: dialer,
-					Config:    c,
-				}).DialContext(ctx, network, host)
+				if proxy == nil && cfg.resolver == nil {
+					return (&tls.Dialer{
+						NetDialer: dialer,
+						Config:    c,
+					}).DialContext(ctx, network, host)
+				}
+				if dialer.Timeout > 0 {
+					var cancel func()
+					ctx, cancel = context.WithTimeout(ctx, dialer.Timeout)
+					defer cancel()
+				}
+				conn, err := netDial(ctx, network, host)
+				if err != nil {
+					return nil, err
+				}
+				tlsConn := tls.Client(conn, c)
+				if err := tlsConn.HandshakeContext(ctx); err != nil {
+					conn.Close()
+					return nil, err
+				}
+				return tlsConn, nil
 			}
 		}
 	}
@@ -472,8 +722,9 @@ func NewClient(opts ...Opt) (*Client, error) {
 		bufPool: newBufPool(),
 		prsPool: newPrsPool(),
 
-		compressor:   compressor,
-		decompressor: newDecompressor(),
+		compressor:       compressor,
+		topicCompressors: topicCompressors,
+		decompressor:     newDecompressor(cfg.decompressors),
 
 		coordinators: make(map[coordinatorKey]*coordinatorLoad),
 
@@ -543,6 +794,81 @@ func (cl *Client) Ping(ctx context.Context) error {
 	return lastErr
 }
 
+// Warmup loads the cluster's brokers and opens connections to them up front,
+// including completing any TLS and SASL handshakes, so that the first
+// requests the client issues do not pay the cost of connecting. This can be
+// used by latency sensitive services at startup, before producing or
+// consuming.
+//
+// By default, this connects to every broker in the cluster. If broker IDs are
+// specified, this only connects to those brokers. The client uses separate
+// connections for producing, fetching, and everything else; this opens the
+// producing and general connections, as well as the fetching connection if the
+// client is consuming.
+//
+// This returns the first error encountered, but attempts to connect to every
+// broker regardless. Connections that are not used may still be closed once
+// ConnIdleTimeout passes.
+func (cl *Client) Warmup(ctx context.Context, brokerIDs ...int32) error {
+	if err := cl.fetchBrokerMetadata(ctx); err != nil {
+		return err
+	}
+
+	cl.brokersMu.RLock()
+	brokers := append([]*broker(nil), cl.brokers...)
+	cl.brokersMu.RUnlock()
+
+	if len(brokerIDs) > 0 {
+		var keep []*broker
+		for _, id := range brokerIDs {
+			var found bool
+			for _, br := range brokers {
+				if br.meta.NodeID == id {
+					keep, found = append(keep, br), true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("unable to warm up unknown broker %d", id)
+			}
+		}
+		brokers = keep
+	}
+
+	keys := []int16{
+		0,  // produce
+		18, // api versions, loading the general connection
+	}
+	if cl.consumer.consuming() {
+		keys = append(keys, 1) // fetch
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for _, br := range brokers {
+		for _, key := range keys {
+			wg.Add(1)
+			go func(br *broker, key int16) {
+				defer wg.Done()
+				_, err := br.waitResp(ctx, &warmupRequest{key})
+				if err != nil {
+					cl.cfg.logger.Log(LogLevelWarn, "unable to warm up broker connection", "broker", logID(br.meta.NodeID), "err", err)
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}(br, key)
+		}
+	}
+	wg.Wait()
+	return firstErr
+}
+
 // PurgeTopicsFromClient internally removes all internal information about the
 // input topics.
 //
@@ -570,6 +896,10 @@ func (cl *Client) Ping(ctx context.Context) error {
 // MetadataMinAge anyway, but the map is not cleaned up one the metadata
 // expires. This function ensures the map is purged.
 func (cl *Client) PurgeTopicsFromClient(topics ...string) {
+	cl.purgeTopicsFromClient(cl.nsTopics(topics)...)
+}
+
+func (cl *Client) purgeTopicsFromClient(topics ...string) {
 	if len(topics) == 0 {
 		return
 	}
@@ -961,6 +1291,9 @@ func (cl *Client) Close() {
 	wg.Wait()
 	sessCloseCancel()
 
+	cl.stopTelemetry()
+	c.stopAllPauseTimers()
+
 	// Now we kill the client context and all brokers, ensuring all
 	// requests fail. This will finish all producer callbacks and
 	// stop the metadata loop.
@@ -1000,6 +1333,54 @@ func (cl *Client) Close() {
 	}
 }
 
+// CloseFlushing flushes buffered records until the context is done and then
+// closes the client, returning every record that failed to be produced while
+// flushing or closing. This allows shutdown paths to persist unproduced
+// records (for example, to a spill file) rather than losing them to failed
+// promises. If everything is flushed before the context is done, this
+// returns nil.
+//
+// All promises are still called as usual, with records that are failed by
+// closing receiving ErrClientClosed, and all promises are finished before
+// this returns. The returned records are the same records that were passed
+// to the promises, and promises must not modify them: calling Reuse on a
+// failed record within its promise is a no-op while CloseFlushing is
+// running, so that a returned record is never pooled and overwritten. Once
+// this returns, you own the returned records and can reuse them as usual.
+// Records that were in flight when the context was done may
+// have been written by Kafka even though they are returned; you should expect
+// duplicates if you reproduce them.
+//
+// If you are transactionally producing, you must end the transaction before
+// calling this. The same group caveats as Close apply.
+func (cl *Client) CloseFlushing(ctx context.Context) []*Record {
+	p := &cl.producer
+	var unflushed []*Record
+	p.promisesMu.Lock()
+	p.unflushed = &unflushed
+	p.promisesMu.Unlock()
+
+	if err := cl.Flush(ctx); err != nil {
+		cl.cfg.logger.Log(LogLevelInfo, "unable to flush before closing, failing remaining buffered records", "err", err)
+	}
+	cl.Close()
+
+	// Close fails all buffered records, but promises are finished in the
+	// background. We wait for every prior promise to be finished before
+	// we stop collecting.
+	flushed := make(chan struct{})
+	p.promiseBatch(batchPromise{flushed: flushed})
+	<-flushed
+
+	p.promisesMu.Lock()
+	p.unflushed = nil
+	p.promisesMu.Unlock()
+	for _, r := range unflushed {
+		r.producing.store(false)
+	}
+	return unflushed
+}
+
 // Request issues a request to Kafka, waiting for and returning the response.
 // If a retryable network error occurs, or if a retryable group / transaction
 // coordinator error occurs, the request is retried. All other errors are
@@ -1077,12 +1458,53 @@ func (cl *Client) retryableBrokerFn(fn func() (*broker, error)) *retryable {
 	return &retryable{cl: cl, br: fn}
 }
 
-func (cl *Client) shouldRetry(tries int, err error) bool {
-	return (kerr.IsRetriable(err) || isRetryableBrokerErr(err)) && int64(tries) < cl.cfg.retries
+// RetryPolicy decides whether requests that failed with a retryable error are
+// retried, and how long to backoff before retrying. See RequestRetryPolicy.
+type RetryPolicy interface {
+	// Retry is called with the key of the request that failed, the number
+	// of times the request has been tried so far (starting at 1), and the
+	// error the request failed with. This returns how long to backoff
+	// before retrying and whether to retry at all.
+	//
+	// This function may be called concurrently.
+	Retry(key int16, tries int, err error) (backoff time.Duration, retry bool)
+}
+
+// shouldRetry returns how long to backoff and whether to retry a request for
+// key that failed with err on its tries'th try, if err is retryable.
+func (cl *Client) shouldRetry(key int16, tries int, err error) (time.Duration, bool) {
+	if !kerr.IsRetriable(err) && !isRetryableBrokerErr(err) {
+		return 0, false
+	}
+	return cl.retryDecision(key, tries, err)
 }
 
-func (cl *Client) shouldRetryNext(tries int, err error) bool {
-	return isSkippableBrokerErr(err) && int64(tries) < cl.cfg.retries
+// shouldRetryNext is like shouldRetry, but for errors that can be retried on
+// a different broker only.
+func (cl *Client) shouldRetryNext(key int16, tries int, err error) (time.Duration, bool) {
+	if !isSkippableBrokerErr(err) {
+		return 0, false
+	}
+	return cl.retryDecision(key, tries, err)
+}
+
+func (cl *Client) retryDecision(key int16, tries int, err error) (time.Duration, bool) {
+	if p := cl.cfg.retryPolicy; p != nil {
+		return p.Retry(key, tries, err)
+	}
+	return cl.cfg.retryBackoff(tries), int64(tries) < cl.cfg.retries
+}
+
+// policyRetriesProduce returns whether the retry policy, if any, allows a
+// batch that failed with the retryable err to be retried. RecordRetries is
+// checked separately.
+func (cl *Client) policyRetriesProduce(tries int64, err error) bool {
+	p := cl.cfg.retryPolicy
+	if p == nil {
+		return true
+	}
+	_, retry := p.Retry(int16(kmsg.Produce), int(tries), err)
+	return retry
 }
 
 type retryable struct {
@@ -1139,13 +1561,21 @@ start:
 
 	if err != nil || retryErr != nil {
 		if r.limitRetries == 0 || tries < r.limitRetries {
-			backoff := r.cl.cfg.retryBackoff(tries)
-			if retryTimeout == 0 || time.Now().Add(backoff).Sub(tryStart) <= retryTimeout {
-				// If this broker / request had a retryable error, we can
-				// just retry now. If the error is *not* retryable but
-				// is a broker-specific network error, and the next
-				// broker is different than the current, we also retry.
-				if r.cl.shouldRetry(tries, err) || r.cl.shouldRetry(tries, retryErr) {
+			withinTimeout := func(backoff time.Duration) bool {
+				return retryTimeout == 0 || time.Now().Add(backoff).Sub(tryStart) <= retryTimeout
+			}
+
+			// If this broker / request had a retryable error, we can
+			// just retry now. If the error is *not* retryable but
+			// is a broker-specific network error, and the next
+			// broker is different than the current, we also retry.
+			key := req.Key()
+			backoff, retry := r.cl.shouldRetry(key, tries, err)
+			if !retry {
+				backoff, retry = r.cl.shouldRetry(key, tries, retryErr)
+			}
+			if retry {
+				if withinTimeout(backoff) {
 					r.cl.cfg.logger.Log(LogLevelDebug, "retrying request",
 						"tries", tries,
 						"backoff", backoff,
@@ -1156,11 +1586,11 @@ start:
 						next, nextErr = r.br()
 						goto start
 					}
-				} else if r.cl.shouldRetryNext(tries, err) {
-					next, nextErr = r.br()
-					if next != br && r.cl.waitTries(ctx, backoff) {
-						goto start
-					}
+				}
+			} else if backoff, retry = r.cl.shouldRetryNext(key, tries, err); retry && withinTimeout(backoff) {
+				next, nextErr = r.br()
+				if next != br && r.cl.waitTries(ctx, backoff) {
+					goto start
 				}
 			}
 		}
@@ -1860,6 +2290,126 @@ func (cl *Client) SeedBrokers() []*Broker {
 	return bs
 }
 
+// ThrottleStats contains cumulative throttling that a broker has applied to
+// a type of request.
+type ThrottleStats struct {
+	// Throttles is how many responses had a non-zero throttle.
+	Throttles int64
+	// ThrottleTime is the sum of all throttle intervals.
+	ThrottleTime time.Duration
+	// LastThrottle is the most recent non-zero throttle interval.
+	LastThrottle time.Duration
+}
+
+// ThrottleStats returns the cumulative throttling applied by brokers over the
+// lifetime of the client, keyed by broker node ID and then by request key
+// (see kmsg.Key). Only requests that were throttled at least once are
+// included. This can be used to detect quota pressure; see HookBrokerThrottle
+// and HookBrokerThrottleKey to be notified of every throttle as it happens.
+func (cl *Client) ThrottleStats() map[int32]map[int16]ThrottleStats {
+	cl.throttlesMu.Lock()
+	defer cl.throttlesMu.Unlock()
+
+	if len(cl.throttles) == 0 {
+		return nil
+	}
+	dup := make(map[int32]map[int16]ThrottleStats, len(cl.throttles))
+	for node, keys := range cl.throttles {
+		dupKeys := make(map[int16]ThrottleStats, len(keys))
+		for key, stats := range keys {
+			dupKeys[key] = stats
+		}
+		dup[node] = dupKeys
+	}
+	return dup
+}
+
+func (cl *Client) addThrottle(node int32, key int16, throttle time.Duration) {
+	cl.throttlesMu.Lock()
+	defer cl.throttlesMu.Unlock()
+
+	if cl.throttles == nil {
+		cl.throttles = make(map[int32]map[int16]ThrottleStats)
+	}
+	keys := cl.throttles[node]
+	if keys == nil {
+		keys = make(map[int16]ThrottleStats)
+		cl.throttles[node] = keys
+	}
+	stats := keys[key]
+	stats.Throttles++
+	stats.ThrottleTime += throttle
+	stats.LastThrottle = throttle
+	keys[key] = stats
+}
+
+// ConnectionStats contains stats for a single open connection to a broker.
+type ConnectionStats struct {
+	// Purpose is what the connection is used for: "produce" for produce
+	// requests, "fetch" for fetch requests, "group" for join and sync
+	// group requests, "slow" for requests that have a timeout, and
+	// "normal" for everything else.
+	Purpose string
+	// ConnectedAt is when the connection finished initializing.
+	ConnectedAt time.Time
+	// ConnectLatency is how long it took to dial and initialize the
+	// connection, including requesting api versions and authenticating.
+	ConnectLatency time.Duration
+	// InFlight is the number of requests written on the connection that
+	// are awaiting a response.
+	InFlight int
+	// BytesWritten is the number of bytes written on the connection.
+	BytesWritten int64
+	// BytesRead is the number of bytes read on the connection.
+	BytesRead int64
+}
+
+// BrokerStats contains connection stats for a single broker.
+type BrokerStats struct {
+	// Meta is the broker's metadata. Seed brokers have special negative
+	// node IDs.
+	Meta BrokerMetadata
+	// Connections contains all currently open connections to the broker,
+	// sorted by purpose.
+	Connections []ConnectionStats
+	// Connects is how many connections have been successfully opened to
+	// the broker over the lifetime of the client.
+	Connects int64
+	// LastErr is the last connection, read, or write error that occurred
+	// for the broker, if any, and LastErrAt is when it occurred. Errors
+	// from the client closing or request contexts being canceled are
+	// not tracked.
+	LastErr   error
+	LastErrAt time.Time
+}
+
+// ClientStats is a snapshot of the client's broker connections, as returned
+// from Client.ClientStats.
+type ClientStats struct {
+	// Brokers contains stats for every discovered and seed broker, sorted
+	// by node ID.
+	Brokers []BrokerStats
+}
+
+// ClientStats returns a snapshot of the client's connections to brokers. This
+// is meant for debugging and dashboards; the snapshot is not atomic across
+// brokers or connections.
+func (cl *Client) ClientStats() ClientStats {
+	cl.brokersMu.RLock()
+	seeds := cl.loadSeeds()
+	brokers := make([]*broker, 0, len(cl.brokers)+len(seeds))
+	brokers = append(brokers, cl.brokers...)
+	brokers = append(brokers, seeds...)
+	cl.brokersMu.RUnlock()
+
+	var s ClientStats
+	for _, b := range brokers {
+		s.Brokers = append(s.Brokers, b.stats())
+	}
+	sort.Slice(s.Brokers, func(i, j int) bool { return s.Brokers[i].Meta.NodeID < s.Brokers[j].Meta.NodeID })
+	return s
+}
+
 // UpdateSeedBrokers updates the client's list of seed brokers. Over the course
 // of a long period of time, your might replace all brokers that you originally
 // specified as seeds. This command allows you to replace the client's list of
@@ -2156,10 +2706,10 @@ func (cl *Client) handleShardedReq(ctx context.Context, req kmsg.Request) ([]Res
 				// immediately. The request was not even issued. However, as a
 				// safety, we only do this 3 times to avoid some super weird
 				// pathological spin loop.
-				backoff := cl.cfg.retryBackoff(tries)
+				backoff, retry := cl.shouldRetry(myIssue.req.Key(), tries, err)
 				if err != nil &&
 					(reshardable && isPinned && errors.Is(err, errBrokerTooOld) && tries <= 3) ||
-					(retryTimeout == 0 || time.Now().Add(backoff).Sub(start) < retryTimeout) && cl.shouldRetry(tries, err) && cl.waitTries(ctx, backoff) {
+					retry && (retryTimeout == 0 || time.Now().Add(backoff).Sub(start) < retryTimeout) && cl.waitTries(ctx, backoff) {
 					// Non-reshardable re-requests just jump back to the
 					// top where the broker is loaded. This is the case on
 					// requests where the original request is split to
@@ -2,11 +2,21 @@ package kgo
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/twmb/franz-go/pkg/kerr"
 	"github.com/twmb/franz-go/pkg/kmsg"
 )
 
@@ -216,3 +226,783 @@ type intSliceHook []int
 func (*intSliceHook) OnNewClient(*Client) {
 	// ignore
 }
+
+func TestThrottleStats(t *testing.T) {
+	cl := new(Client)
+	if stats := cl.ThrottleStats(); stats != nil {
+		t.Fatalf("got initial stats %v, exp nil", stats)
+	}
+
+	produce := int16(kmsg.Produce)
+	fetch := int16(kmsg.Fetch)
+	cl.addThrottle(1, produce, 10*time.Millisecond)
+	cl.addThrottle(1, produce, 30*time.Millisecond)
+	cl.addThrottle(2, fetch, 5*time.Millisecond)
+
+	exp := map[int32]map[int16]ThrottleStats{
+		1: {produce: {Throttles: 2, ThrottleTime: 40 * time.Millisecond, LastThrottle: 30 * time.Millisecond}},
+		2: {fetch: {Throttles: 1, ThrottleTime: 5 * time.Millisecond, LastThrottle: 5 * time.Millisecond}},
+	}
+	got := cl.ThrottleStats()
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got stats %v != exp %v", got, exp)
+	}
+
+	// The returned map is a copy.
+	got[1][produce] = ThrottleStats{}
+	if again := cl.ThrottleStats(); !reflect.DeepEqual(again, exp) {
+		t.Errorf("stats modified through returned map: %v", again)
+	}
+}
+
+func TestBrokerStats(t *testing.T) {
+	cl := new(Client)
+	b := cl.newBroker(3, "localhost", 9092, nil)
+
+	produce := &brokerCxn{purpose: "produce", connectDur: time.Second}
+	produce.bytesWritten.Store(100)
+	produce.bytesRead.Store(10)
+	produce.inflight.Store(2)
+	b.cxnProduce = produce
+
+	dead := &brokerCxn{purpose: "fetch"}
+	dead.dead.Store(true)
+	b.cxnFetch = dead
+
+	b.cxnNormal = &brokerCxn{purpose: "normal"}
+	b.connects.Store(3)
+
+	b.noteErr(context.Canceled) // ignored
+	errRead := errors.New("read failed")
+	b.noteErr(errRead)
+	b.noteErr(ErrClientClosed) // ignored
+
+	s := b.stats()
+	if s.Meta.NodeID != 3 || s.Connects != 3 {
+		t.Errorf("got node %d connects %d, exp 3 and 3", s.Meta.NodeID, s.Connects)
+	}
+	if s.LastErr != errRead || s.LastErrAt.IsZero() {
+		t.Errorf("got last err %v at %v, exp %v", s.LastErr, s.LastErrAt, errRead)
+	}
+	exp := []ConnectionStats{
+		{Purpose: "normal"},
+		{Purpose: "produce", ConnectLatency: time.Second, InFlight: 2, BytesWritten: 100, BytesRead: 10},
+	}
+	if !reflect.DeepEqual(s.Connections, exp) {
+		t.Errorf("got connections %+v != exp %+v", s.Connections, exp)
+	}
+}
+
+func TestDialTLSServerName(t *testing.T) {
+	dir := t.TempDir()
+	writeTestCert(t, dir, "broker.example.com", time.Now())
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snis := make(chan string, 10)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			snis <- hello.ServerName
+			return nil, nil
+		},
+		Certificates: []tls.Certificate{cert},
+	})
+	if err != nil {
+		t.Skipf("unable to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.(*tls.Conn).Handshake()
+				io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	addr := ln.Addr().String()
+	cl, err := NewClient(
+		SeedBrokers("127.0.0.1:1"), // background dials should not reach our listener
+		DialTLSConfig(new(tls.Config)),
+		DialTLSServerName(func(b BrokerMetadata) (string, bool) {
+			if b.NodeID == 1 {
+				return "broker.example.com", true
+			}
+			return "", true
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	for _, test := range []struct {
+		node   int32
+		expSNI string
+	}{
+		{1, "broker.example.com"},
+		{2, ""}, // IP addresses are not sent as SNI
+	} {
+		ctx := context.WithValue(context.Background(), dialBrokerKey{}, BrokerMetadata{NodeID: test.node})
+		conn, err := cl.cfg.dialFn(ctx, "tcp", addr)
+		if err != nil {
+			t.Fatalf("node %d: unable to dial: %v", test.node, err)
+		}
+		conn.Close()
+		if sni := <-snis; sni != test.expSNI {
+			t.Errorf("node %d: got sni %q != exp %q", test.node, sni, test.expSNI)
+		}
+	}
+}
+
+func TestBrokerAddressMapper(t *testing.T) {
+	errMap := errors.New("unmappable")
+	cl := new(Client)
+	cl.cfg.brokerAddrFn = func(b BrokerMetadata) (string, error) {
+		switch b.NodeID {
+		case 1:
+			return "localhost:" + strconv.Itoa(int(b.Port)+10000), nil
+		case 2:
+			return "", nil
+		}
+		return "", errMap
+	}
+
+	for _, test := range []struct {
+		node   int32
+		exp    string
+		expErr bool
+	}{
+		{1, "localhost:19092", false},
+		{2, "10.0.0.1:9092", false},
+		{3, "", true},
+	} {
+		b := cl.newBroker(test.node, "10.0.0.1", 9092, nil)
+		addr, err := b.dialAddr()
+		if gotErr := err != nil; gotErr != test.expErr || gotErr && !errors.Is(err, errMap) {
+			t.Errorf("node %d: got err %v, exp err? %v", test.node, err, test.expErr)
+		}
+		if addr != test.exp {
+			t.Errorf("node %d: got addr %q != exp %q", test.node, addr, test.exp)
+		}
+	}
+}
+
+func TestWarmupRequest(t *testing.T) {
+	accepted := make(chan struct{}, 10)
+	addr := listenTest(t, func(conn net.Conn) {
+		accepted <- struct{}{}
+		io.Copy(io.Discard, conn)
+	})
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNum, _ := strconv.Atoi(port)
+
+	cl, err := NewClient(SeedBrokers(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	b := cl.newBroker(1, host, int32(portNum), nil)
+	defer b.stopForever()
+	b.storeVersions(newBrokerVersions()) // skip ApiVersions on connect
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, key := range []int16{0, 18, 0} {
+		if _, err := b.waitResp(ctx, &warmupRequest{key}); err != nil {
+			t.Fatalf("unable to warm up key %d: %v", key, err)
+		}
+	}
+
+	// Warming up the produce connection twice only connects once.
+	for i := 0; i < 2; i++ {
+		<-accepted
+	}
+	var purposes []string
+	for _, cxn := range b.stats().Connections {
+		purposes = append(purposes, cxn.Purpose)
+	}
+	if exp := []string{"normal", "produce"}; !reflect.DeepEqual(purposes, exp) {
+		t.Errorf("got warm connections %v, exp %v", purposes, exp)
+	}
+}
+
+func TestLazyConnect(t *testing.T) {
+	accepted := make(chan struct{}, 100)
+	addr := listenTest(t, func(conn net.Conn) {
+		accepted <- struct{}{}
+		io.Copy(io.Discard, conn)
+	})
+
+	cl, err := NewClient(
+		SeedBrokers(addr),
+		ConsumeTopics("foo"),
+		MetadataMinAge(10*time.Millisecond),
+		MetadataMaxAge(20*time.Millisecond),
+		LazyConnect(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	select {
+	case <-accepted:
+		t.Fatal("lazy client connected before being used")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	cl.PollFetches(ctx)
+
+	select {
+	case <-accepted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lazy client did not connect after polling")
+	}
+}
+
+func TestConnRecycling(t *testing.T) {
+	cl := new(Client)
+	cl.cfg.connMaxLifetime = time.Minute
+	cl.cfg.connMaxIdleTime = 10 * time.Second
+
+	now := time.Now()
+	for _, test := range []struct {
+		connectedAt time.Time
+		lastWrite   time.Time
+		lastRead    time.Time
+		inflight    int32
+		writing     bool
+		reading     bool
+		exp         string
+	}{
+		{connectedAt: now.Add(-time.Second), exp: ""},
+		{connectedAt: now.Add(-2 * time.Minute), lastWrite: now, lastRead: now, exp: "max lifetime"},
+		{connectedAt: now.Add(-2 * time.Minute), inflight: 1, exp: ""},
+		{connectedAt: now.Add(-2 * time.Minute), writing: true, exp: ""},
+		{connectedAt: now.Add(-2 * time.Minute), reading: true, exp: ""},
+		{connectedAt: now.Add(-30 * time.Second), exp: "max idle time"},
+		{connectedAt: now.Add(-30 * time.Second), lastWrite: now.Add(-20 * time.Second), lastRead: now.Add(-time.Second), exp: ""},
+		{connectedAt: now.Add(-30 * time.Second), lastWrite: now.Add(-20 * time.Second), lastRead: now.Add(-20 * time.Second), exp: "max idle time"},
+	} {
+		cxn := &brokerCxn{cl: cl, connectedAt: test.connectedAt}
+		if !test.lastWrite.IsZero() {
+			cxn.lastWrite.Store(test.lastWrite.UnixNano())
+		}
+		if !test.lastRead.IsZero() {
+			cxn.lastRead.Store(test.lastRead.UnixNano())
+		}
+		cxn.inflight.Store(test.inflight)
+		cxn.writing.Store(test.writing)
+		cxn.reading.Store(test.reading)
+		if got := cxn.recycleWhy(now); got != test.exp {
+			t.Errorf("connected %v ago: got recycle why %q != exp %q", now.Sub(test.connectedAt), got, test.exp)
+		}
+	}
+
+	// The reaper does not kill a connection past its max lifetime while a
+	// request is being written (and is not yet in flight).
+	cxn := &brokerCxn{cl: cl, connectedAt: now.Add(-2 * time.Minute)}
+	cxn.lastWrite.Store(now.UnixNano())
+	cxn.lastRead.Store(now.UnixNano())
+	cxn.writing.Store(true)
+	b := &broker{cl: cl, cxnNormal: cxn}
+	if reaped := b.reapConnections(time.Hour); reaped != 0 || cxn.dead.Load() {
+		t.Errorf("reaped %d connections, exp the writing connection to be kept", reaped)
+	}
+}
+
+type traceHook struct {
+	req  kmsg.Request
+	resp kmsg.Response
+}
+
+func (h *traceHook) OnBrokerRequestTrace(_ BrokerMetadata, req kmsg.Request, resp kmsg.Response, _ error) {
+	h.req, h.resp = req, resp
+}
+
+func TestRequestTrace(t *testing.T) {
+	formatter := kmsg.NewRequestFormatter(kmsg.FormatterClientID("trace"))
+	for _, version := range []int16{4, 12} { // non-flexible and flexible
+		req := kmsg.NewPtrMetadataRequest()
+		req.Version = version
+		req.Topics = append(req.Topics, kmsg.MetadataRequestTopic{Topic: kmsg.StringPtr("foo")})
+
+		buf := formatter.AppendRequest(nil, req, 3)
+		decoded := decodeWrittenRequest(buf, req)
+		if decoded == kmsg.Request(req) {
+			t.Fatalf("v%d: request was not decoded", version)
+		}
+		if !reflect.DeepEqual(decoded, kmsg.Request(req)) {
+			t.Errorf("v%d: decoded %v != exp %v", version, decoded, req)
+		}
+	}
+
+	// The written buffer is reused once written; the decoded request must
+	// not point into it.
+	produce := kmsg.NewPtrProduceRequest()
+	produce.Version = 7
+	produce.Topics = append(produce.Topics, kmsg.ProduceRequestTopic{
+		Topic:      "foo",
+		Partitions: []kmsg.ProduceRequestTopicPartition{{Records: []byte("records")}},
+	})
+	buf := formatter.AppendRequest(nil, produce, 3)
+	decoded := decodeWrittenRequest(buf, produce)
+	for i := range buf {
+		buf[i] = 0
+	}
+	if got := decoded.(*kmsg.ProduceRequest).Topics[0].Partitions[0].Records; string(got) != "records" {
+		t.Errorf("decoded produce records %q were overwritten when the written buffer was reused", got)
+	}
+
+	h := new(traceHook)
+	cl := new(Client)
+	cl.cfg.hooks = hooks{h}
+	cxn := &brokerCxn{cl: cl, b: &broker{}}
+
+	req := kmsg.NewPtrSASLAuthenticateRequest()
+	req.SASLAuthBytes = []byte("secret")
+	resp := kmsg.NewPtrSASLAuthenticateResponse()
+	resp.SASLAuthBytes = []byte("secret")
+
+	cxn.hookTrace(req, resp, nil)
+	if h.req != nil {
+		t.Fatal("hook was called without TraceRequests")
+	}
+
+	cl.cfg.traceRequests = true
+	cxn.hookTrace(req, resp, nil)
+	if h.req.(*kmsg.SASLAuthenticateRequest).SASLAuthBytes != nil || h.resp.(*kmsg.SASLAuthenticateResponse).SASLAuthBytes != nil {
+		t.Error("SASL payloads were not redacted")
+	}
+	if string(req.SASLAuthBytes) != "secret" || string(resp.SASLAuthBytes) != "secret" {
+		t.Error("redaction modified the original request or response")
+	}
+}
+
+// serveMetadataTest serves ApiVersions and Metadata requests on conn, replying
+// that every requested topic (or only "foo", if all topics are requested) has
+// one partition led by broker 0 at the address conn was accepted on. The
+// topics of each Metadata request are sent to topics, with nil meaning all
+// topics. Other requests are not replied to.
+func serveMetadataTest(conn net.Conn, topics chan<- []string) {
+	host, portStr, _ := net.SplitHostPort(conn.LocalAddr().String())
+	port, _ := strconv.Atoi(portStr)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		buf := make([]byte, 4+binary.BigEndian.Uint32(size[:]))
+		copy(buf, size[:])
+		if _, err := io.ReadFull(conn, buf[4:]); err != nil {
+			return
+		}
+		key := int16(binary.BigEndian.Uint16(buf[4:]))
+		version := int16(binary.BigEndian.Uint16(buf[6:]))
+		corrID := binary.BigEndian.Uint32(buf[8:])
+
+		var resp kmsg.Response
+		switch key {
+		case 18:
+			r := kmsg.NewPtrApiVersionsResponse()
+			r.Version = version
+			k := kmsg.NewApiVersionsResponseApiKey()
+			k.ApiKey, k.MaxVersion = 3, 7 // metadata, non-flexible
+			r.ApiKeys = append(r.ApiKeys, k)
+			resp = r
+		case 3:
+			req := kmsg.NewPtrMetadataRequest()
+			req.Version = version
+			decoded := decodeWrittenRequest(buf, req).(*kmsg.MetadataRequest)
+			var requested []string
+			r := kmsg.NewPtrMetadataResponse()
+			r.Version = version
+			b := kmsg.NewMetadataResponseBroker()
+			b.Host, b.Port = host, int32(port)
+			r.Brokers = append(r.Brokers, b)
+			names := []string{"foo"} // all topics requests
+			if decoded.Topics != nil {
+				names = names[:0]
+				for _, rt := range decoded.Topics {
+					requested = append(requested, *rt.Topic)
+					names = append(names, *rt.Topic)
+				}
+			}
+			for _, name := range names {
+				t := kmsg.NewMetadataResponseTopic()
+				t.Topic = kmsg.StringPtr(name)
+				p := kmsg.NewMetadataResponseTopicPartition()
+				p.Replicas, p.ISR = []int32{0}, []int32{0}
+				t.Partitions = append(t.Partitions, p)
+				r.Topics = append(r.Topics, t)
+			}
+			sort.Strings(requested)
+			topics <- requested
+			resp = r
+		default:
+			continue
+		}
+		out := make([]byte, 8)
+		binary.BigEndian.PutUint32(out[4:], corrID)
+		out = resp.AppendTo(out)
+		binary.BigEndian.PutUint32(out, uint32(len(out)-4))
+		if _, err := conn.Write(out); err != nil {
+			return
+		}
+	}
+}
+
+func TestRefreshMetadata(t *testing.T) {
+	topics := make(chan []string, 100)
+	addr := listenTest(t, func(conn net.Conn) { serveMetadataTest(conn, topics) })
+
+	cl, err := NewClient(
+		SeedBrokers(addr),
+		ConsumeTopics("foo", "bar"),
+		MetadataMinAge(time.Hour),
+		MetadataMaxAge(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	next := func() []string {
+		select {
+		case got := <-topics:
+			return got
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a metadata request")
+			return nil
+		}
+	}
+	if got := next(); !reflect.DeepEqual(got, []string{"bar", "foo"}) {
+		t.Fatalf("initial metadata request got topics %v, exp [bar foo]", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, test := range []struct {
+		topics []string
+		exp    []string
+	}{
+		{[]string{"foo", "unused"}, []string{"foo"}},
+		{nil, []string{"bar", "foo"}},
+	} {
+		if err := cl.RefreshMetadata(ctx, test.topics...); err != nil {
+			t.Fatalf("refresh %v: unexpected err: %v", test.topics, err)
+		}
+		if got := next(); !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("refresh %v: got requested topics %v, exp %v", test.topics, got, test.exp)
+		}
+	}
+}
+
+func TestMetadataTopicsMaxAge(t *testing.T) {
+	topics := make(chan []string, 100)
+	addr := listenTest(t, func(conn net.Conn) { serveMetadataTest(conn, topics) })
+
+	cl, err := NewClient(
+		SeedBrokers(addr),
+		ConsumeRegex(),
+		ConsumeTopics("f.*"),
+		MetadataMinAge(time.Hour),
+		MetadataMaxAge(time.Hour),
+		MetadataTopicsMaxAge(50*time.Millisecond),
+		MetadataTopicsMinAge(10*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	for i, exp := range [][]string{nil, {"foo"}, {"foo"}} {
+		select {
+		case got := <-topics:
+			if !reflect.DeepEqual(got, exp) {
+				t.Errorf("metadata request %d: got topics %v, exp %v", i, got, exp)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for metadata request %d", i)
+		}
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	t.Parallel()
+
+	cl, err := NewClient(MaxBufferedRecords(1), SeedBrokers("127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if err := cl.Reconfigure(RecordRetries(3), Rack("foo")); err == nil || !strings.Contains(err.Error(), "Rack") {
+		t.Errorf("got err %v, exp an error naming Rack", err)
+	}
+	if err := cl.Reconfigure(RecordRetries(3), MaxBufferedRecords(0)); err == nil {
+		t.Error("expected an error for invalid max buffered records")
+	}
+	if v := cl.OptValues(RecordRetries)[0]; v == int64(3) {
+		t.Error("record retries was applied from a failed reconfigure")
+	}
+
+	var buf strings.Builder
+	var mu sync.Mutex
+	logger := BasicLogger(writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return buf.Write(p)
+	}), LogLevelDebug, nil)
+	if err := cl.Reconfigure(
+		WithLogger(logger),
+		FetchMaxBytes(100),
+		FetchMaxPartitionBytes(200),
+		ProducerLinger(time.Second),
+		RecordRetries(3),
+	); err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []struct {
+		opt any
+		v   any
+	}{
+		{WithLogger, logger},
+		{FetchMaxBytes, int32(100)},
+		{FetchMaxPartitionBytes, int32(100)}, // clamped to the max bytes
+		{ProducerLinger, time.Second},
+		{RecordRetries, int64(3)},
+	} {
+		if got := cl.OptValues(exp.opt)[0]; got != exp.v {
+			t.Errorf("%s: got %v, exp %v", namefn(exp.opt), got, exp.v)
+		}
+	}
+	cl.cfg.logger.Log(LogLevelDebug, "reconfigured")
+	mu.Lock()
+	logged := buf.String()
+	mu.Unlock()
+	if !strings.Contains(logged, "reconfigured") {
+		t.Errorf("reconfigured logger did not log, got %q", logged)
+	}
+
+	// Raising the max buffered records wakes a blocked producer.
+	cl.Produce(context.Background(), &Record{Topic: "foo"}, nil)
+	produced := make(chan struct{})
+	go func() {
+		defer close(produced)
+		cl.Produce(context.Background(), &Record{Topic: "foo"}, nil)
+	}()
+	select {
+	case <-produced:
+		t.Fatal("produce did not block at the max buffered records")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := cl.Reconfigure(MaxBufferedRecords(2)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-produced:
+	case <-time.After(5 * time.Second):
+		t.Fatal("produce was not woken by raising the max buffered records")
+	}
+}
+
+func TestReconfigureLowerMaxBuffered(t *testing.T) {
+	t.Parallel()
+
+	cl, err := NewClient(MaxBufferedRecords(3), SeedBrokers("127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	// We simulate three buffered records and finish them directly, so
+	// that the test does not depend on when buffered records fail.
+	cl.producer.bufferedRecords.Add(3)
+	finish := func() {
+		cl.finishRecordPromise(promisedRec{promise: func(*Record, error) {}, Record: &Record{Topic: "foo"}}, ErrAborting, false, false)
+	}
+
+	if err := cl.Reconfigure(MaxBufferedRecords(1)); err != nil {
+		t.Fatal(err)
+	}
+	produced := make(chan struct{})
+	go func() {
+		defer close(produced)
+		cl.Produce(context.Background(), &Record{Topic: "foo"}, nil)
+	}()
+	blocked := func(why string) {
+		select {
+		case <-produced:
+			t.Fatalf("produce was not blocked %s", why)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	blocked("with three records buffered")
+
+	// The two records over the lowered limit finish without waking the
+	// producer; the buffer is still at the limit.
+	finish()
+	finish()
+	blocked("with the buffer at the lowered limit")
+
+	finish()
+	select {
+	case <-produced:
+	case <-time.After(5 * time.Second):
+		t.Fatal("produce was not woken once the buffer was under the lowered limit")
+	}
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (fn writerFunc) Write(p []byte) (int, error) { return fn(p) }
+
+type e2eHook struct {
+	mu   sync.Mutex
+	keys []int16
+	e2es []BrokerE2E
+}
+
+func (h *e2eHook) OnBrokerE2E(_ BrokerMetadata, key int16, e2e BrokerE2E) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.keys = append(h.keys, key)
+	h.e2es = append(h.e2es, e2e)
+}
+
+func TestBrokerE2E(t *testing.T) {
+	topics := make(chan []string, 100)
+	addr := listenTest(t, func(conn net.Conn) { serveMetadataTest(conn, topics) })
+
+	h := new(e2eHook)
+	cl, err := NewClient(
+		SeedBrokers(addr),
+		WithHooks(h),
+		MetadataMinAge(time.Hour),
+		MetadataMaxAge(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cl.RefreshMetadata(ctx); err != nil {
+		t.Fatal(err)
+	}
+	h.mu.Lock()
+	start := len(h.e2es)
+	h.mu.Unlock()
+	for i := 0; i < 2; i++ {
+		req := kmsg.NewPtrMetadataRequest()
+		if _, err := req.RequestWith(ctx, cl.Broker(0)); err != nil {
+			t.Fatalf("metadata request %d: unexpected err: %v", i, err)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var metas []BrokerE2E
+	for i, e2e := range h.e2es {
+		if err := e2e.Err(); err != nil {
+			t.Errorf("e2e %d: unexpected err: %v", i, err)
+		}
+		if e2e.BytesWritten <= 4 || e2e.BytesRead <= 4 {
+			t.Errorf("e2e %d: got bytes written %d, read %d, exp both > 4", i, e2e.BytesWritten, e2e.BytesRead)
+		}
+		if e2e.QueueWait > e2e.WriteWait {
+			t.Errorf("e2e %d: queue wait %v > write wait %v", i, e2e.QueueWait, e2e.WriteWait)
+		}
+		switch h.keys[i] {
+		case int16(kmsg.ApiVersions):
+			if e2e.QueueWait != 0 {
+				t.Errorf("e2e %d: got ApiVersions queue wait %v, exp 0", i, e2e.QueueWait)
+			}
+		case int16(kmsg.Metadata):
+			if e2e.Version != 7 {
+				t.Errorf("e2e %d: got metadata version %d, exp 7", i, e2e.Version)
+			}
+			if i >= start {
+				metas = append(metas, e2e)
+			}
+		}
+	}
+	if len(metas) != 2 {
+		t.Fatalf("got %d metadata e2es after refreshing, exp 2", len(metas))
+	}
+	if metas[0].CorrelationID+1 != metas[1].CorrelationID {
+		t.Errorf("got metadata correlation IDs %d and %d, exp consecutive", metas[0].CorrelationID, metas[1].CorrelationID)
+	}
+}
+
+type retryPolicyFn func(int16, int, error) (time.Duration, bool)
+
+func (fn retryPolicyFn) Retry(key int16, tries int, err error) (time.Duration, bool) {
+	return fn(key, tries, err)
+}
+
+func TestRequestRetryPolicy(t *testing.T) {
+	var calls []int
+	cl, err := NewClient(
+		RequestRetryPolicy(retryPolicyFn(func(key int16, tries int, err error) (time.Duration, bool) {
+			calls = append(calls, tries)
+			return 0, key == int16(kmsg.Metadata) && tries < 3
+		})),
+		RetryTimeout(0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	var issued int
+	r := cl.retryableBrokerFn(func() (*broker, error) {
+		issued++
+		return nil, kerr.LeaderNotAvailable
+	})
+
+	for _, test := range []struct {
+		req       kmsg.Request
+		expIssued int
+	}{
+		{kmsg.NewPtrMetadataRequest(), 3},
+		{kmsg.NewPtrFindCoordinatorRequest(), 1},
+	} {
+		calls, issued = nil, 0
+		if _, err := r.Request(context.Background(), test.req); !errors.Is(err, kerr.LeaderNotAvailable) {
+			t.Errorf("%s: got err %v, exp %v", kmsg.NameForKey(test.req.Key()), err, kerr.LeaderNotAvailable)
+		}
+		if issued != test.expIssued {
+			t.Errorf("%s: issued %d times, exp %d", kmsg.NameForKey(test.req.Key()), issued, test.expIssued)
+		}
+		if len(calls) != test.expIssued || calls[len(calls)-1] != test.expIssued {
+			t.Errorf("%s: got policy calls with tries %v, exp 1 through %d", kmsg.NameForKey(test.req.Key()), calls, test.expIssued)
+		}
+	}
+
+	// Non-retryable errors never reach the policy.
+	calls = nil
+	if _, retry := cl.shouldRetry(int16(kmsg.Metadata), 1, kerr.InvalidRequest); retry || len(calls) != 0 {
+		t.Errorf("non-retryable error: got retry %v with %d policy calls, exp false with 0", retry, len(calls))
+	}
+	if cl.policyRetriesProduce(1, kerr.NotEnoughReplicas) {
+		t.Error("produce batch retry allowed, exp denied by policy")
+	}
+}
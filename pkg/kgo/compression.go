@@ -5,6 +5,7 @@ import (
 	"compress/gzip"
 	"encoding/binary"
 	"errors"
+	"io"
 	"io/ioutil"
 	"runtime"
 	"sync"
@@ -40,25 +41,50 @@ const (
 // RecordBatch. All records in a RecordBatch are compressed into one record
 // for that batch.
 type CompressionCodec struct {
-	codec codecType
-	level int8
+	codec  codecType
+	level  int8
+	custom Compressor
+}
+
+// Compressor is a custom implementation of a compression codec, allowing
+// alternative implementations of the codecs Kafka supports (for example, zstd
+// with a trained dictionary, or a hardware accelerated gzip).
+//
+// Kafka only supports the gzip, snappy, lz4, and zstd codecs; a custom
+// compressor replaces the built-in implementation of one of those codecs and
+// must produce data in that codec's format. Compressors must be safe for
+// concurrent use.
+type Compressor interface {
+	// Compress appends the compressed src to dst and returns the
+	// resulting slice. If this returns an error, the batch is sent
+	// uncompressed.
+	Compress(dst, src []byte) ([]byte, error)
+}
+
+// Decompressor is a custom implementation of a decompression codec, the
+// counterpart of Compressor. Decompressors must be safe for concurrent use.
+type Decompressor interface {
+	// Decompress returns the decompressed src.
+	Decompress(src []byte) ([]byte, error)
 }
 
 // NoCompression is a compression option that avoids compression. This can
 // always be used as a fallback compression.
-func NoCompression() CompressionCodec { return CompressionCodec{codecNone, 0} }
+func NoCompression() CompressionCodec { return CompressionCodec{codec: codecNone, level: 0} }
 
 // GzipCompression enables gzip compression with the default compression level.
-func GzipCompression() CompressionCodec { return CompressionCodec{codecGzip, gzip.DefaultCompression} }
+func GzipCompression() CompressionCodec {
+	return CompressionCodec{codec: codecGzip, level: gzip.DefaultCompression}
+}
 
 // SnappyCompression enables snappy compression.
-func SnappyCompression() CompressionCodec { return CompressionCodec{codecSnappy, 0} }
+func SnappyCompression() CompressionCodec { return CompressionCodec{codec: codecSnappy, level: 0} }
 
 // Lz4Compression enables lz4 compression with the fastest compression level.
-func Lz4Compression() CompressionCodec { return CompressionCodec{codecLZ4, 0} }
+func Lz4Compression() CompressionCodec { return CompressionCodec{codec: codecLZ4, level: 0} }
 
 // ZstdCompression enables zstd compression with the default compression level.
-func ZstdCompression() CompressionCodec { return CompressionCodec{codecZstd, 0} }
+func ZstdCompression() CompressionCodec { return CompressionCodec{codec: codecZstd, level: 0} }
 
 // WithLevel changes the compression codec's "level", effectively allowing for
 // higher or lower compression ratios at the expense of CPU speed.
@@ -75,8 +101,29 @@ func (c CompressionCodec) WithLevel(level int) CompressionCodec {
 	return c
 }
 
+// WithCompressor uses the given compressor rather than the built-in
+// implementation for this codec, allowing for custom implementations such as
+// zstd with a trained dictionary:
+//
+//	enc, _ := zstd.NewWriter(nil, zstd.WithEncoderDict(dict))
+//	codec := kgo.ZstdCompression().WithCompressor(zstdDict{enc})
+//
+// Any level set with WithLevel is ignored; the compressor is expected to be
+// configured with the level to use. This has no effect on NoCompression.
+//
+// Data compressed with a custom compressor must be decompressable by every
+// consumer. If the format requires special handling to decompress (as zstd
+// with a dictionary does), consumers must use WithDecompressor, and the
+// broker must not need to decompress batches (e.g., the topic's
+// compression.type must be "producer").
+func (c CompressionCodec) WithCompressor(comp Compressor) CompressionCodec {
+	c.custom = comp
+	return c
+}
+
 type compressor struct {
 	options  []codecType
+	custom   [5]Compressor // indexed by codecType
 	gzPool   sync.Pool
 	lz4Pool  sync.Pool
 	zstdPool sync.Pool
@@ -110,6 +157,10 @@ func newCompressor(codecs ...CompressionCodec) (*compressor, error) {
 out:
 	for _, codec := range codecs {
 		c.options = append(c.options, codec.codec)
+		if codec.custom != nil && codec.codec != codecNone {
+			c.custom[codec.codec] = codec.custom
+			continue
+		}
 		switch codec.codec {
 		case codecNone:
 			break out
@@ -187,6 +238,15 @@ func (c *compressor) compress(dst *sliceWriter, src []byte, produceRequestVersio
 		break
 	}
 
+	if custom := c.custom[use]; custom != nil {
+		compressed, err := custom.Compress(dst.inner, src)
+		if err != nil {
+			return nil, -1
+		}
+		dst.inner = compressed
+		return dst.inner, use
+	}
+
 	switch use {
 	case codecNone:
 		return src, 0
@@ -224,13 +284,16 @@ func (c *compressor) compress(dst *sliceWriter, src []byte, produceRequestVersio
 }
 
 type decompressor struct {
+	custom [5]Decompressor // indexed by codecType
+
 	ungzPool   sync.Pool
 	unlz4Pool  sync.Pool
 	unzstdPool sync.Pool
 }
 
-func newDecompressor() *decompressor {
+func newDecompressor(custom [5]Decompressor) *decompressor {
 	d := &decompressor{
+		custom: custom,
 		ungzPool: sync.Pool{
 			New: func() any { return new(gzip.Reader) },
 		},
@@ -259,6 +322,20 @@ type zstdDecoder struct {
 }
 
 func (d *decompressor) decompress(src []byte, codec byte) ([]byte, error) {
+	return d.decompressTo(nil, src, codec)
+}
+
+// isCustom returns whether codec is decompressed with a user provided
+// Decompressor, which does not decompress into a given buffer.
+func (d *decompressor) isCustom(codec byte) bool {
+	return int(codec) < len(d.custom) && d.custom[codec] != nil
+}
+
+// decompressTo decompresses src into dst, reusing dst's capacity if possible.
+func (d *decompressor) decompressTo(dst, src []byte, codec byte) ([]byte, error) {
+	if d.isCustom(codec) {
+		return d.custom[codec].Decompress(src)
+	}
 	switch codec {
 	case 0:
 		return src, nil
@@ -268,26 +345,35 @@ func (d *decompressor) decompress(src []byte, codec byte) ([]byte, error) {
 		if err := ungz.Reset(bytes.NewReader(src)); err != nil {
 			return nil, err
 		}
-		return ioutil.ReadAll(ungz)
+		return readAllTo(dst, ungz)
 	case 2:
 		if len(src) > 16 && bytes.HasPrefix(src, xerialPfx) {
 			return xerialDecode(src)
 		}
-		return s2.Decode(nil, src)
+		return s2.Decode(dst[:cap(dst)], src)
 	case 3:
 		unlz4 := d.unlz4Pool.Get().(*lz4.Reader)
 		defer d.unlz4Pool.Put(unlz4)
 		unlz4.Reset(bytes.NewReader(src))
-		return ioutil.ReadAll(unlz4)
+		return readAllTo(dst, unlz4)
 	case 4:
 		unzstd := d.unzstdPool.Get().(*zstdDecoder)
 		defer d.unzstdPool.Put(unzstd)
-		return unzstd.inner.DecodeAll(src, nil)
+		return unzstd.inner.DecodeAll(src, dst[:0])
 	default:
 		return nil, errors.New("unknown compression codec")
 	}
 }
 
+func readAllTo(dst []byte, r io.Reader) ([]byte, error) {
+	if dst == nil {
+		return ioutil.ReadAll(r)
+	}
+	buf := bytes.NewBuffer(dst[:0])
+	_, err := buf.ReadFrom(r)
+	return buf.Bytes(), err
+}
+
 var xerialPfx = []byte{130, 83, 78, 65, 80, 80, 89, 0}
 
 var errMalformedXerial = errors.New("malformed xerial framing")
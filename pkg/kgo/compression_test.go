@@ -47,7 +47,7 @@ func TestNewCompressor(t *testing.T) {
 
 func TestCompressDecompress(t *testing.T) {
 	t.Parallel()
-	d := newDecompressor()
+	d := newDecompressor([5]Decompressor{})
 	in := []byte("foo")
 	var wg sync.WaitGroup
 	for _, produceVersion := range []int16{
@@ -162,3 +162,74 @@ func Test_xerialDecode(t *testing.T) {
 		})
 	}
 }
+
+// xorCodec is a toy codec that is not actually compression, but shrinks the
+// input so that it is used over the uncompressed input.
+type xorCodec struct{}
+
+func (xorCodec) Compress(dst, src []byte) ([]byte, error) {
+	for _, b := range src[:len(src)-1] {
+		dst = append(dst, b^0xff)
+	}
+	return dst, nil
+}
+
+func (xorCodec) Decompress(src []byte) ([]byte, error) {
+	out := make([]byte, 0, len(src)+1)
+	for _, b := range src {
+		out = append(out, b^0xff)
+	}
+	return append(out, '!'), nil
+}
+
+func TestCustomCodec(t *testing.T) {
+	c, err := newCompressor(ZstdCompression().WithCompressor(xorCodec{}), NoCompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := newDecompressor([5]Decompressor{codecZstd: xorCodec{}})
+
+	in := []byte("hello!")
+	w := sliceWriters.Get().(*sliceWriter)
+	defer sliceWriters.Put(w)
+
+	got, used := c.compress(w, in, 7)
+	if used != codecZstd {
+		t.Fatalf("got codec %d, exp zstd", used)
+	}
+	out, err := d.decompress(got, byte(used))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, in) {
+		t.Errorf("got %q, exp %q", out, in)
+	}
+
+	if _, used := c.compress(w, in, 6); used != codecNone {
+		t.Errorf("got codec %d for produce v6, exp none", used)
+	}
+}
+
+func TestDecompressTo(t *testing.T) {
+	d := newDecompressor([5]Decompressor{})
+	in := bytes.Repeat([]byte("foo"), 100)
+	for _, codec := range []CompressionCodec{GzipCompression(), SnappyCompression(), Lz4Compression(), ZstdCompression()} {
+		c, _ := newCompressor(codec)
+		w := sliceWriters.Get().(*sliceWriter)
+		compressed, _ := c.compress(w, in, 7)
+
+		dst := make([]byte, 0, 1<<10)
+		got, err := d.decompressTo(dst, compressed, byte(codec.codec))
+		sliceWriters.Put(w)
+		if err != nil {
+			t.Errorf("codec %d: unexpected err: %v", codec.codec, err)
+			continue
+		}
+		if !bytes.Equal(got, in) {
+			t.Errorf("codec %d: got %q != exp %q", codec.codec, got, in)
+		}
+		if &got[0] != &dst[:1][0] {
+			t.Errorf("codec %d: did not decompress into dst", codec.codec)
+		}
+	}
+}
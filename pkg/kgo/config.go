@@ -8,6 +8,7 @@ import (
 	"math"
 	"math/rand"
 	"net"
+	"net/url"
 	"regexp"
 	"runtime/debug"
 	"sync"
@@ -59,6 +60,22 @@ func (producerOpt) producerOpt()       {}
 func (consumerOpt) consumerOpt()       {}
 func (groupOpt) groupOpt()             {}
 
+// Options that can also be applied to a live client with Reconfigure.
+type (
+	liveClientOpt   struct{ fn func(*cfg) }
+	liveProducerOpt struct{ fn func(*cfg) }
+	liveConsumerOpt struct{ fn func(*cfg) }
+)
+
+func (opt liveClientOpt) apply(cfg *cfg)   { opt.fn(cfg) }
+func (opt liveProducerOpt) apply(cfg *cfg) { opt.fn(cfg) }
+func (opt liveConsumerOpt) apply(cfg *cfg) { opt.fn(cfg) }
+func (liveProducerOpt) producerOpt()       {}
+func (liveConsumerOpt) consumerOpt()       {}
+func (liveClientOpt) live()                {}
+func (liveProducerOpt) live()              {}
+func (liveConsumerOpt) live()              {}
+
 // A cfg can be written to while initializing a client, and after that it is
 // (mostly) only ever read from. Some areas can continue to be modified --
 // particularly reconfiguring what to consume from -- but most areas are
@@ -72,13 +89,26 @@ type cfg struct {
 	dialFn                 func(context.Context, string, string) (net.Conn, error)
 	dialTimeout            time.Duration
 	dialTLS                *tls.Config
+	dialTLSFn              func(context.Context) (*tls.Config, error)
+	dialTLSServerName      func(BrokerMetadata) (string, bool)
+	topicToKafka           func(string) string
+	topicFromKafka         func(string) string
+	brokerAddrFn           func(BrokerMetadata) (string, error)
+	dialProxy              *url.URL
+	proxy                  *proxyDialer // created in NewClient if dialProxy is set
+	resolver               HostResolver
 	requestTimeoutOverhead time.Duration
 	connIdleTimeout        time.Duration
+	connMaxLifetime        time.Duration
+	connMaxIdleTime        time.Duration
+	lazyConnect            bool
+	disableClientMetrics   bool
+	traceRequests          bool
 
 	softwareName    string // KIP-511
 	softwareVersion string // KIP-511
 
-	logger Logger
+	logger *wrappedLogger
 
 	seedBrokers []string
 	maxVersions *kversion.Versions
@@ -87,6 +117,7 @@ type cfg struct {
 	retryBackoff func(int) time.Duration
 	retries      int64
 	retryTimeout func(int16) time.Duration
+	retryPolicy  RetryPolicy
 
 	maxBrokerWriteBytes int32
 	maxBrokerReadBytes  int32
@@ -96,10 +127,16 @@ type cfg struct {
 	metadataMaxAge time.Duration
 	metadataMinAge time.Duration
 
+	metadataTopicsMaxAge time.Duration
+	metadataTopicsMinAge time.Duration
+
 	sasls []sasl.Mechanism
 
 	hooks hooks
 
+	valueTransformer       ValueTransformer
+	valueTransformerTopics func(string) bool
+
 	//////////////////////
 	// PRODUCER SECTION //
 	//////////////////////
@@ -110,45 +147,79 @@ type cfg struct {
 	disableIdempotency bool
 	maxProduceInflight int                // if idempotency is disabled, we allow a configurable max inflight
 	compression        []CompressionCodec // order of preference
-
-	defaultProduceTopic string
-	maxRecordBatchBytes int32
-	maxBufferedRecords  int64
-	produceTimeout      time.Duration
-	recordRetries       int64
-	maxUnknownFailures  int64
-	linger              time.Duration
-	recordTimeout       time.Duration
-	manualFlushing      bool
-	txnBackoff          time.Duration
-
-	partitioner Partitioner
+	topicCompression   map[string][]CompressionCodec
+
+	defaultProduceTopic   string
+	maxRecordBatchBytes   int32
+	validateMaxMsgBytes   bool
+	maxBufferedRecords    lazyI64
+	maxPartBufferedBytes  int64
+	maxTopicBufferedBytes int64
+	maxPrioBufferedBytes  map[int8]int64
+	rateBytes             int64 // global produce rate limits, per second
+	rateRecords           int64
+	topicRateBytes        int64 // per topic produce rate limits, per second
+	topicRateRecords      int64
+	rateLimitFail         bool
+	produceTimeout        time.Duration
+	recordRetries         lazyI64
+	maxUnknownFailures    int64
+	linger                lazyI64 // time.Duration
+	recordTimeout         time.Duration
+	ackSampling           int64
+	manualFlushing        bool
+	txnBackoff            time.Duration
+
+	partitioner   Partitioner
+	batchAffinity func(*Record) []byte
 
 	stopOnDataLoss bool
 	onDataLoss     func(string, int32)
 
+	deadLetterTopic    string
+	deadLetterTopics   map[string]string // source topic => dead letter topic
+	deadLetterClassify func(*Record, error) bool
+
 	//////////////////////
 	// CONSUMER SECTION //
 	//////////////////////
 
-	maxWait        int32
-	minBytes       int32
-	maxBytes       lazyI32
-	maxPartBytes   lazyI32
-	resetOffset    Offset
-	isolationLevel int8
-	keepControl    bool
-	rack           string
-	preferLagFn    PreferLagFn
+	maxWait         int32
+	minBytes        int32
+	fetchHedgeAfter time.Duration
+	maxBytes        lazyI32
+	maxPartBytes    lazyI32
+	resetOffset     Offset
+	onOutOfRange    func(OffsetOutOfRange) OffsetOutOfRangeAction
+	onLogTruncation func(LogTruncation) bool
+	isolationLevel  int8
+	keepControl     bool
+	rawBatches      bool
+	poolBuffers     bool
+	rack            string
+	preferLagFn     PreferLagFn
+	replicaSelector ReplicaSelector
+	replicaMaxAge   time.Duration
+	replicaMaxErrs  int
+	onReplicaSwitch func(ReplicaSwitch)
+	decompressors   [5]Decompressor // indexed by codecType
 
 	maxConcurrentFetches     int
+	fetchDecodeConcurrency   int
+	adaptiveMaxPartBytes     int32
 	disableFetchSessions     bool
 	keepFetchRetryableErrors bool
 
+	maxPollPartitionRecords int
+
 	topics     map[string]*regexp.Regexp   // topics to consume; if regex is true, values are compiled regular expressions
 	partitions map[string]map[int32]Offset // partitions to directly consume from
 	regex      bool
 
+	regexRefresh   time.Duration
+	onRegexAdded   func(*Client, []string)
+	onRegexRemoved func(*Client, []string)
+
 	////////////////////////////
 	// CONSUMER GROUP SECTION //
 	////////////////////////////
@@ -182,6 +253,10 @@ type cfg struct {
 	autocommitMarks    bool
 	autocommitInterval time.Duration
 	commitCallback     func(*Client, *kmsg.OffsetCommitRequest, *kmsg.OffsetCommitResponse, error)
+
+	commitCoalesceInterval time.Duration
+	commitMaxPartitions    int
+	commitNoRebalanceFlush bool
 }
 
 func (cfg *cfg) validate() error {
@@ -195,6 +270,12 @@ func (cfg *cfg) validate() error {
 		cfg.maxPartBytes = cfg.maxBytes
 	}
 
+	for source, deadLetter := range cfg.deadLetterTopics {
+		if source == deadLetter {
+			return fmt.Errorf("topic %q cannot be its own dead letter topic", source)
+		}
+	}
+
 	if cfg.disableIdempotency {
 		if cfg.txnID != nil {
 			return errors.New("cannot both disable idempotent writes and use transactional IDs")
@@ -276,6 +357,7 @@ func (cfg *cfg) validate() error {
 
 		// 0 <= allowed concurrency
 		{name: "max concurrent fetches", v: int64(cfg.maxConcurrentFetches), allowed: 0, badcmp: i64lt},
+		{name: "fetch decode concurrency", v: int64(cfg.fetchDecodeConcurrency), allowed: 0, badcmp: i64lt},
 
 		// 1s <= request timeout overhead <= 15m
 		{name: "request timeout max overhead", v: int64(cfg.requestTimeoutOverhead), allowed: int64(15 * time.Minute), badcmp: i64gt, durs: true},
@@ -289,9 +371,15 @@ func (cfg *cfg) validate() error {
 		{name: "metadata max age", v: int64(cfg.metadataMaxAge), allowed: int64(time.Hour), badcmp: i64gt, durs: true},
 		{name: "metadata min age", v: int64(cfg.metadataMinAge), allowed: int64(10 * time.Millisecond), badcmp: i64lt, durs: true},
 		{v: int64(cfg.metadataMaxAge), allowed: int64(cfg.metadataMinAge), badcmp: i64lt, fmt: "metadata max age %v is erroneously less than metadata min age %v", durs: true},
+		{name: "metadata topics min age", v: int64(cfg.metadataTopicsMinAge), allowed: int64(10 * time.Millisecond), badcmp: i64lt, durs: true},
 
 		// Some random producer settings.
-		{name: "max buffered records", v: cfg.maxBufferedRecords, allowed: 1, badcmp: i64lt},
+		{name: "max buffered records", v: int64(cfg.maxBufferedRecords), allowed: 1, badcmp: i64lt},
+		{name: "produce rate limit bytes", v: cfg.rateBytes, allowed: 0, badcmp: i64lt},
+		{name: "produce rate limit records", v: cfg.rateRecords, allowed: 0, badcmp: i64lt},
+		{name: "per topic produce rate limit bytes", v: cfg.topicRateBytes, allowed: 0, badcmp: i64lt},
+		{name: "per topic produce rate limit records", v: cfg.topicRateRecords, allowed: 0, badcmp: i64lt},
+		{name: "produce ack sampling", v: cfg.ackSampling, allowed: 1, badcmp: i64lt},
 		{name: "linger", v: int64(cfg.linger), allowed: int64(time.Minute), badcmp: i64gt, durs: true},
 		{name: "produce timeout", v: int64(cfg.produceTimeout), allowed: int64(100 * time.Millisecond), badcmp: i64lt, durs: true},
 		{name: "record timeout", v: int64(cfg.recordTimeout), allowed: int64(time.Second), badcmp: func(l, r int64) (bool, string) {
@@ -305,6 +393,9 @@ func (cfg *cfg) validate() error {
 		// but we want the error message to be in the nice
 		// time.Duration string format.
 		{name: "max fetch wait", v: int64(cfg.maxWait) * int64(time.Millisecond), allowed: int64(10 * time.Millisecond), badcmp: i64lt, durs: true},
+		{name: "preferred replica max age", v: int64(cfg.replicaMaxAge), allowed: 0, badcmp: i64lt, durs: true},
+		{name: "preferred replica max errors", v: int64(cfg.replicaMaxErrs), allowed: 0, badcmp: i64lt},
+		{name: "fetch hedge after", v: int64(cfg.fetchHedgeAfter), allowed: 0, badcmp: i64lt, durs: true},
 
 		// Group settings.
 		{name: "number of balancers", v: int64(len(cfg.balancers)), allowed: 1, badcmp: i64lt},
@@ -313,6 +404,8 @@ func (cfg *cfg) validate() error {
 		{name: "session timeout", v: int64(cfg.sessionTimeout), allowed: int64(100 * time.Millisecond), badcmp: i64lt, durs: true},
 		{name: "rebalance timeout", v: int64(cfg.rebalanceTimeout), allowed: int64(100 * time.Millisecond), badcmp: i64lt, durs: true},
 		{name: "autocommit interval", v: int64(cfg.autocommitInterval), allowed: int64(100 * time.Millisecond), badcmp: i64lt, durs: true},
+		{name: "commit coalesce interval", v: int64(cfg.commitCoalesceInterval), allowed: 0, badcmp: i64lt, durs: true},
+		{name: "commit max partitions", v: int64(cfg.commitMaxPartitions), allowed: 0, badcmp: i64lt},
 
 		{v: int64(cfg.heartbeatInterval), allowed: int64(cfg.rebalanceTimeout) * int64(time.Millisecond), badcmp: i64gt, durs: true, fmt: "heartbeat interval %v is erroneously larger than the session timeout %v"},
 	} {
@@ -335,6 +428,30 @@ func (cfg *cfg) validate() error {
 		if cfg.dialTLS != nil {
 			return errors.New("cannot set both Dialer and DialTLSConfig")
 		}
+		if cfg.dialProxy != nil {
+			return errors.New("cannot set both Dialer and DialProxy")
+		}
+		if cfg.resolver != nil {
+			return errors.New("cannot set both Dialer and Resolver")
+		}
+		if cfg.dialTLSFn != nil {
+			return errors.New("cannot set both Dialer and DialTLSConfigFn")
+		}
+	}
+	if (cfg.topicToKafka == nil) != (cfg.topicFromKafka == nil) {
+		return errors.New("TopicNamespace requires both a to and from function")
+	}
+
+	if cfg.dialTLS != nil && cfg.dialTLSFn != nil {
+		return errors.New("cannot set both DialTLSConfig and DialTLSConfigFn")
+	}
+	if cfg.dialTLSServerName != nil && cfg.dialTLS == nil && cfg.dialTLSFn == nil {
+		return errors.New("DialTLSServerName requires DialTLSConfig or DialTLSConfigFn")
+	}
+	if cfg.dialProxy != nil {
+		if err := validateProxyURL(cfg.dialProxy); err != nil {
+			return err
+		}
 	}
 
 	if len(cfg.group) > 0 {
@@ -441,7 +558,7 @@ func defaultCfg() cfg {
 		softwareName:    "kgo",
 		softwareVersion: softwareVersion(),
 
-		logger: new(nopLogger),
+		logger: new(wrappedLogger),
 
 		seedBrokers: []string{"127.0.0.1"},
 		maxVersions: kversion.Stable(),
@@ -483,6 +600,8 @@ func defaultCfg() cfg {
 		metadataMaxAge: 5 * time.Minute,
 		metadataMinAge: 5 * time.Second / 2,
 
+		metadataTopicsMinAge: 5 * time.Second / 2,
+
 		//////////////
 		// producer //
 		//////////////
@@ -496,6 +615,7 @@ func defaultCfg() cfg {
 		produceTimeout:      10 * time.Second,
 		recordRetries:       math.MaxInt64, // effectively unbounded
 		maxUnknownFailures:  4,
+		ackSampling:         1,
 		partitioner:         UniformBytesPartitioner(64<<10, true, true, nil),
 		txnBackoff:          20 * time.Millisecond,
 
@@ -560,8 +680,11 @@ func SoftwareNameAndVersion(name, version string) Opt {
 // to not use a logger.
 //
 // It is invalid to use a nil logger; doing so will cause panics.
+//
+// This option can be changed on a live client with Reconfigure, which can be
+// used to change the log level.
 func WithLogger(l Logger) Opt {
-	return clientOpt{func(cfg *cfg) { cfg.logger = &wrappedLogger{l} }}
+	return liveClientOpt{func(cfg *cfg) { cfg.logger.store(l) }}
 }
 
 // RequestTimeoutOverhead uses the given time as overhead while deadlining
@@ -602,6 +725,75 @@ func ConnIdleTimeout(timeout time.Duration) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.connIdleTimeout = timeout }}
 }
 
+// ConnMaxLifetime sets the maximum amount of time a connection to a broker
+// can be used before it is recycled, overriding the default of no limit.
+//
+// Connections are recycled when they are next used and have no requests in
+// flight: the old connection is closed and a new one is opened (and
+// initialized with any TLS and SASL handshakes). The idle connection reaper
+// (see ConnIdleTimeout) also closes connections that have outlived this
+// lifetime and have nothing in flight. This is useful behind L4 load
+// balancers and NAT gateways that periodically kill long lived connections,
+// and to rebalance connections across load balanced brokers.
+func ConnMaxLifetime(lifetime time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.connMaxLifetime = lifetime }}
+}
+
+// ConnMaxIdleTime sets the maximum amount of time a connection to a broker
+// can go without reading or writing before it is recycled rather than used,
+// overriding the default of no limit.
+//
+// Unlike ConnIdleTimeout, which periodically reaps idle connections in the
+// background and can allow a connection to idle for up to 2x its timeout,
+// this is checked every time a connection is about to be used: a connection
+// that has been idle for longer than this is closed and replaced before a
+// request is written. This is useful behind L4 load balancers and NAT gateways
+// that silently drop idle connections, where writing to such a connection
+// would otherwise hang until the request times out.
+func ConnMaxIdleTime(idle time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.connMaxIdleTime = idle }}
+}
+
+// DisableClientMetrics disables pushing client metrics to brokers, overriding
+// the default of pushing whatever metrics brokers request with KIP-714
+// (Kafka 3.7+).
+//
+// By default, the client asks a broker which metrics to push and how often
+// with GetTelemetrySubscriptions, and pushes the requested metrics with
+// PushTelemetry. This begins once the client first loads metadata, and the
+// client pushes one final time when closing. Brokers that do not support
+// KIP-714, or that do not have client metrics subscriptions configured,
+// request no metrics. You can observe what is pushed with
+// HookClientMetricsPush.
+func DisableClientMetrics() Opt {
+	return clientOpt{func(cfg *cfg) { cfg.disableClientMetrics = true }}
+}
+
+// TraceRequests enables HookBrokerRequestTrace, which is passed every
+// request and response decoded in full, with SASL payloads redacted. This is
+// meant to help diagnose protocol level issues without packet captures.
+//
+// Tracing decodes every request the client writes, which is expensive: this
+// option is meant for debugging, not for production.
+func TraceRequests() Opt {
+	return clientOpt{func(cfg *cfg) { cfg.traceRequests = true }}
+}
+
+// LazyConnect defers all broker connections until the client is first used,
+// overriding the default of loading metadata as soon as a consumer client is
+// created and periodically thereafter. This makes creating a client free: no
+// connections are opened, and no connection errors are logged, until the
+// client needs to talk to a broker.
+//
+// With this option, a consumer client begins loading metadata on the first
+// poll, and the client does not periodically refresh metadata (see
+// MetadataMaxAge) until something, such as producing or polling, first
+// triggers a metadata update. Client.Warmup can be used to connect eagerly at
+// a time of your choosing.
+func LazyConnect() Opt {
+	return clientOpt{func(cfg *cfg) { cfg.lazyConnect = true }}
+}
+
 // Dialer uses fn to dial addresses, overriding the default dialer that uses a
 // 10s dial timeout and no TLS.
 //
@@ -642,6 +834,121 @@ func DialTLSConfig(c *tls.Config) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.dialTLS = c }}
 }
 
+// DialTLSConfigFn is like DialTLSConfig, but calls fn for the TLS config to
+// use every time a broker is dialed. This allows certificates and CA bundles
+// to be rotated without recreating the client: connections opened after a
+// rotation use the new config. If fn returns an error, the dial fails.
+//
+// The returned config is cloned and has its ServerName set the same as
+// DialTLSConfig. See ReloadTLSFiles for a function that reloads certificates
+// from files when they change.
+//
+// Note that if you only need to rotate a client certificate, you can instead
+// use DialTLSConfig with a config that sets GetClientCertificate.
+func DialTLSConfigFn(fn func(context.Context) (*tls.Config, error)) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.dialTLSFn = fn }}
+}
+
+// DialTLSServerName sets a function that is called every time a broker is
+// dialed with TLS to override the TLS ServerName for that broker, and to
+// optionally skip verifying that broker's certificate. This is useful if
+// brokers advertise IP addresses but their certificates contain hostnames.
+//
+// The function is passed the metadata of the broker being dialed. Seed
+// brokers have special negative node IDs; all brokers have the host and port
+// being dialed. If the returned server name is empty, the default ServerName
+// handling of DialTLSConfig is used. If insecureSkipVerify is true, the
+// broker's certificate is not verified, which should only be used for
+// testing.
+//
+// This option requires DialTLSConfig or DialTLSConfigFn.
+func DialTLSServerName(fn func(broker BrokerMetadata) (serverName string, insecureSkipVerify bool)) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.dialTLSServerName = fn }}
+}
+
+// BrokerAddressMapper sets a function that maps a broker's advertised address
+// to the address to dial, which is useful when advertised listeners are not
+// reachable as-is: kubectl port-forward, SSH tunnels, NAT, and so on.
+//
+// The function is called every time a connection is opened and is passed the
+// metadata of the broker being dialed, including the advertised host and port.
+// Seed brokers have special negative node IDs and the host and port that the
+// seed was configured with. The returned address must be a host:port; if it is
+// empty, the advertised address is dialed. If the function returns an error,
+// the dial fails with that error.
+//
+// SASL continues to use the advertised address (which matters for Kerberos),
+// and if dialing with TLS and the TLS config has no ServerName, the
+// advertised host is used as the ServerName rather than the mapped host.
+func BrokerAddressMapper(fn func(broker BrokerMetadata) (string, error)) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.brokerAddrFn = fn }}
+}
+
+// DialProxy opts into dialing all brokers through the proxy at proxyURL. The
+// supported schemes are socks5 and socks5h (SOCKS5, RFC 1928) and http (HTTP
+// CONNECT). A username and password in the url are used to authenticate to
+// the proxy. If the url has no port, the default is 1080 for SOCKS5 and 80 for
+// HTTP.
+//
+// Every broker, including brokers learned from metadata, is dialed through
+// the proxy with the host and port that the broker advertises. With socks5,
+// broker hostnames are resolved locally; with socks5h and http, the proxy
+// resolves hostnames, which is necessary if brokers advertise names that only
+// resolve from behind the proxy.
+//
+// This option composes with DialTimeout, which bounds dialing the proxy and
+// the proxy handshake, and with DialTLSConfig, which layers TLS to the broker
+// over the proxied connection. This option cannot be used with Dialer.
+//
+// This option can be changed on a live client with Reconfigure if the client
+// was created with DialProxy. New connections are dialed through the new
+// proxy; existing connections are unaffected.
+func DialProxy(proxyURL *url.URL) Opt {
+	return liveClientOpt{func(cfg *cfg) { cfg.dialProxy = proxyURL }}
+}
+
+// Resolver uses r to resolve the hostnames of seed brokers and brokers
+// learned from metadata, overriding the default of the system resolver. This
+// can be used for service discovery (such as consul), split-horizon DNS, or
+// caching. Hostnames are resolved every time a connection is opened, meaning
+// a broker whose address changes is found again on reconnect.
+//
+// If a hostname resolves to multiple addresses, each address is dialed in
+// order until one succeeds. When dialing with TLS, the certificate is verified
+// against the original hostname, not the resolved address.
+//
+// If DialProxy is used, r resolves the proxy's hostname, and broker hostnames
+// for the socks5 scheme. This option cannot be used with Dialer.
+func Resolver(r HostResolver) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.resolver = r }}
+}
+
+// TopicNamespace transparently maps topic names between the names your
+// application uses and the names in Kafka, which is useful for multi-tenant
+// platforms that give every tenant a topic prefix. toKafka maps an
+// application topic to its Kafka topic, and fromKafka must reverse the
+// mapping. TopicPrefix can be used to create a pair of functions that add and
+// strip a prefix:
+//
+//	kgo.TopicNamespace(kgo.TopicPrefix("tenant-a."))
+//
+// Topics are mapped to Kafka names when producing records, when configuring
+// topics or partitions to consume (except regular expressions, which match
+// Kafka names), and in all client methods that accept topics or topic keyed
+// maps: pausing and resuming, adding consume topics, setting offsets,
+// flushing, purging, and committing (including committing records). Topics
+// are mapped back when records are returned from polling or finish producing,
+// in the partitions passed to OnPartitionsAssigned, OnPartitionsRevoked, and
+// OnPartitionsLost, and in all client methods that return topics or topic
+// keyed maps.
+//
+// Hooks and logs see Kafka names, as do raw requests issued through Request
+// and the requests and responses passed to commit callbacks. Per-topic
+// producer options, such as ProducerTopicCompression, use application names.
+func TopicNamespace(toKafka, fromKafka func(string) string) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.topicToKafka, cfg.topicFromKafka = toKafka, fromKafka }}
+}
+
 // SeedBrokers sets the seed brokers for the client to use, overriding the
 // default 127.0.0.1:9092.
 //
@@ -693,7 +1000,8 @@ func RetryBackoffFn(backoff func(int) time.Duration) Opt {
 // overriding the default of 20.
 //
 // This option does not apply to produce requests; to limit produce request
-// retries / record retries, see RecordRetries.
+// retries / record retries, see RecordRetries. This option is ignored if a
+// RequestRetryPolicy is used.
 func RequestRetries(n int) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.retries = int64(n) }}
 }
@@ -742,6 +1050,25 @@ func RetryTimeoutFn(t func(int16) time.Duration) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.retryTimeout = t }}
 }
 
+// RequestRetryPolicy sets a policy that decides, per request key and error,
+// whether a failed request is retried and how long to backoff before retrying.
+// This replaces RequestRetries and RetryBackoffFn for any request issued
+// through a client's Request function (as well as requests the client issues
+// internally through the same path, such as group and transaction requests).
+// RetryTimeoutFn still bounds how long a request can be retried.
+//
+// The policy is only consulted for errors the client considers retryable; it
+// can decline to retry, but it cannot force a retry of a non-retryable error.
+//
+// The policy is also consulted when a batch within a produce request fails
+// with a retryable error, with the ProduceRequest key and the number of times
+// the batch has been tried. In this case, the policy can only stop retrying
+// earlier than RecordRetries; the backoff it returns is ignored and the
+// producer continues to backoff with RetryBackoffFn.
+func RequestRetryPolicy(policy RetryPolicy) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.retryPolicy = policy }}
+}
+
 // AllowAutoTopicCreation enables topics to be auto created if they do
 // not exist when fetching their metadata.
 func AllowAutoTopicCreation() Opt {
@@ -788,6 +1115,28 @@ func MetadataMinAge(age time.Duration) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.metadataMinAge = age }}
 }
 
+// MetadataTopicsMaxAge enables a second, topics only metadata refresh that
+// runs every age, separate from the full refresh every MetadataMaxAge. This
+// can be used to detect leader changes quickly on large clusters.
+//
+// A full refresh loads metadata for every topic in the cluster if you are
+// consuming with regex, so that new topics can be discovered. A topics only
+// refresh loads metadata only for the topics the client is already producing
+// to or consuming. If you are not consuming with regex, the two refreshes
+// load the same topics.
+//
+// By default, this is disabled and all refreshes are full refreshes.
+func MetadataTopicsMaxAge(age time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.metadataTopicsMaxAge = age }}
+}
+
+// MetadataTopicsMinAge sets the minimum time between topics only metadata
+// refreshes (see MetadataTopicsMaxAge), overriding the default 2.5s. This is
+// separate from MetadataMinAge, which bounds full refreshes.
+func MetadataTopicsMinAge(age time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.metadataTopicsMinAge = age }}
+}
+
 // SASL appends sasl authentication options to use for all connections.
 //
 // SASL is tried in order; if the broker supports the first mechanism, all
@@ -809,6 +1158,26 @@ func WithHooks(hooks ...Hook) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.hooks = append(cfg.hooks, hooks...) }}
 }
 
+// WithValueTransformer sets a transformer for record values, such as for
+// envelope encryption or payload signing. The transformer is used for all
+// topics that topics returns true for, or all topics if topics is nil. If
+// using TopicNamespace, topics is called with application topic names.
+//
+// When producing, values are transformed after the record is passed through
+// all HookProduceRecordIntercept and HookProduceRecordBuffered hooks, right
+// before the record is partitioned and added to a batch. If transforming
+// fails, the record is failed with the error. The transformed value remains
+// in the record when its promise is called.
+//
+// When consuming, values are transformed as soon as records are decoded from
+// a fetch response, before any HookFetchRecordBuffered or
+// HookFetchRecordIntercept hooks. If transforming fails, the record is kept
+// with its original value and the error can be retrieved with
+// ValueTransformErr.
+func WithValueTransformer(t ValueTransformer, topics func(topic string) bool) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.valueTransformer, cfg.valueTransformerTopics = t, topics }}
+}
+
 // ConcurrentTransactionsBackoff sets the backoff interval to use during
 // transactional requests in case we encounter CONCURRENT_TRANSACTIONS error,
 // overriding the default 20ms.
@@ -909,6 +1278,99 @@ func ProducerBatchCompression(preference ...CompressionCodec) ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.compression = preference }}
 }
 
+// ProducerBatchAffinity reorders records within each batch so that records
+// with the same affinity, as returned by fn, are adjacent. Compression works
+// better when similar data is close together, so grouping records that have
+// similar values can considerably improve the compression ratio of batches.
+// KeyAffinity can be used to group records by key, which works well if
+// records with the same key have similar values.
+//
+// Records are stably sorted by affinity (compared as bytes) when a batch is
+// first written, meaning records that have the same affinity keep their
+// relative order. However, records with different affinities in the same batch
+// may be written to Kafka, and have their promises called, in a different
+// order than they were produced. Only use this option if your application does
+// not rely on the order of records that have different affinities. Ordering
+// across batches is unchanged: a record is never reordered with records in a
+// different batch.
+//
+// This option has no effect when producing to brokers older than Kafka 0.11.
+func ProducerBatchAffinity(fn func(*Record) []byte) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.batchAffinity = fn }}
+}
+
+// KeyAffinity returns the record's key, for use with ProducerBatchAffinity to
+// group records with the same key within batches.
+func KeyAffinity(r *Record) []byte { return r.Key }
+
+// DeadLetterTopic sets the dead letter topic that records are produced to
+// when they permanently fail to be produced, or when they are passed to
+// Client.DeadLetter by a consumer.
+//
+// Dead lettered records keep their key, value, headers, and timestamp, and
+// have provenance headers added describing where the record came from and
+// why it was dead lettered; see DeadLetterHeaderTopic and its sibling
+// constants. Records that fail to produce to the dead letter topic are not
+// dead lettered again; failures are only logged.
+//
+// Failed records are dead lettered asynchronously with TryProduce: a copy of
+// the failed record is taken and produced in a new goroutine right before the
+// record's promise is called, so the dead letter record may not yet be
+// buffered when the promise runs. If the client is at MaxBufferedRecords, the
+// dead letter record fails with ErrMaxBuffered (and is logged), and a Flush
+// that is concurrent with a record failing may return before the dead letter
+// record is buffered.
+//
+// By default, produce failures are dead lettered unless the client is closing
+// or aborting, or the record's produce context was canceled. This can be
+// changed with DeadLetterClassifier. Produce failures are never dead lettered
+// for transactional clients, since a failed record dooms the transaction; use
+// Client.DeadLetter in a later transaction instead.
+func DeadLetterTopic(topic string) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.deadLetterTopic = topic }}
+}
+
+// DeadLetterTopicFor sets the dead letter topic to use for records from the
+// given source topic, overriding DeadLetterTopic. This option can be used
+// multiple times to map multiple source topics, and the latest use for a
+// given source topic wins. See DeadLetterTopic for more details.
+func DeadLetterTopicFor(source, deadLetter string) ProducerOpt {
+	return producerOpt{func(cfg *cfg) {
+		if cfg.deadLetterTopics == nil {
+			cfg.deadLetterTopics = make(map[string]string)
+		}
+		cfg.deadLetterTopics[source] = deadLetter
+	}}
+}
+
+// DeadLetterClassifier sets the function that decides whether a record that
+// failed to be produced with the given error should be dead lettered,
+// overriding the default classification described in DeadLetterTopic.
+//
+// This function is called in the client's promise goroutine before the
+// record's promise, and must not block.
+func DeadLetterClassifier(fn func(r *Record, err error) bool) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.deadLetterClassify = fn }}
+}
+
+// ProducerTopicCompression overrides the compression codec preference for
+// producing records to the given topic, which otherwise defaults to the
+// preference from ProducerBatchCompression. This option can be used multiple
+// times to override compression for multiple topics, and the latest use for a
+// given topic wins.
+//
+// This is useful when one client produces to topics with very different
+// payloads; for example, producing with lz4 to a logs topic but with no
+// compression to a topic whose values are already compressed.
+func ProducerTopicCompression(topic string, preference ...CompressionCodec) ProducerOpt {
+	return producerOpt{func(cfg *cfg) {
+		if cfg.topicCompression == nil {
+			cfg.topicCompression = make(map[string][]CompressionCodec)
+		}
+		cfg.topicCompression[topic] = preference
+	}}
+}
+
 // ProducerBatchMaxBytes upper bounds the size of a record batch, overriding
 // the default 1,000,012 bytes. This mirrors Kafka's max.message.bytes.
 //
@@ -926,11 +1388,118 @@ func ProducerBatchMaxBytes(v int32) ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.maxRecordBatchBytes = v }}
 }
 
+// ProducerValidateMaxMessageBytes validates each produced record against its
+// topic's max.message.bytes before the record is buffered. If a record is too
+// large, its promise is called with an *ErrRecordTooLarge immediately, rather
+// than the client discovering the problem from the broker after the batch the
+// record is in is produced.
+//
+// A topic's max.message.bytes is loaded with a DescribeConfigs request in the
+// background the first time a record is produced to the topic, and records
+// produced before the config is loaded are not validated. If the config cannot
+// be loaded (for example, if the client is not authorized to describe topic
+// configs), records to the topic are not validated. The config is reloaded
+// every MetadataMaxAge, and sooner if a broker rejects a batch to the topic
+// with MESSAGE_TOO_LARGE, so changes to the topic's config are picked up.
+//
+// Like ProducerBatchMaxBytes, a record is validated by its size before
+// compression.
+func ProducerValidateMaxMessageBytes() ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.validateMaxMsgBytes = true }}
+}
+
+// MaxBufferedBytesPerPriority sets the max amount of record bytes the client
+// will buffer for records with the given Record.Priority, overriding the
+// default of no per-priority limit. This option can be used multiple times to
+// limit multiple priorities, and the latest use for a given priority wins.
+//
+// Like MaxBufferedBytesPerTopic, this limit is checked before a record is
+// buffered: if the record's priority is at or over the limit, Produce blocks
+// until records with that priority drain below the limit. TryProduce and
+// manually flushing clients fail the record immediately with ErrMaxBuffered.
+//
+// This can be used to keep bulk, low priority traffic from using the entire
+// client buffer (see MaxBufferedRecords) that high priority records need.
+func MaxBufferedBytesPerPriority(priority int8, n int64) ProducerOpt {
+	return producerOpt{func(cfg *cfg) {
+		if cfg.maxPrioBufferedBytes == nil {
+			cfg.maxPrioBufferedBytes = make(map[int8]int64)
+		}
+		cfg.maxPrioBufferedBytes[priority] = n
+	}}
+}
+
 // MaxBufferedRecords sets the max amount of records the client will buffer,
 // blocking produces until records are finished if this limit is reached.
 // This overrides the default of 10,000.
+//
+// This option can be changed on a live client with Reconfigure. Lowering the
+// limit while more records than the new limit are buffered does not fail any
+// records; producers block until enough records finish that the buffer is
+// under the new limit.
 func MaxBufferedRecords(n int) ProducerOpt {
-	return producerOpt{func(cfg *cfg) { cfg.maxBufferedRecords = int64(n) }}
+	return liveProducerOpt{func(cfg *cfg) { cfg.maxBufferedRecords = lazyI64(n) }}
+}
+
+// MaxBufferedBytesPerPartition sets the max amount of record bytes (keys,
+// values, and headers) the client will buffer for any single partition,
+// overriding the default of no limit. This prevents one hot or unavailable
+// partition from using the entire buffer and starving all other partitions.
+//
+// This limit is checked after a record is partitioned: if buffering a record
+// puts its partition at or over the limit, Produce blocks until the partition
+// drains below the limit, the produce context is canceled, or the client is
+// closed. The record itself is already buffered and is not failed if waiting
+// is canceled. TryProduce and manually flushing clients never wait.
+func MaxBufferedBytesPerPartition(n int64) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.maxPartBufferedBytes = n }}
+}
+
+// MaxBufferedBytesPerTopic sets the max amount of record bytes (keys, values,
+// and headers) the client will buffer for any single topic, overriding the
+// default of no limit.
+//
+// Unlike MaxBufferedBytesPerPartition, this limit is checked before a record
+// is buffered: if the record's topic is at or over the limit, Produce blocks
+// until the topic drains below the limit. If the produce context is canceled
+// or the client is closed while waiting, the record is failed with the
+// context error or ErrClientClosed. TryProduce and manually flushing clients
+// fail the record immediately with ErrMaxBuffered.
+func MaxBufferedBytesPerTopic(n int64) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.maxTopicBufferedBytes = n }}
+}
+
+// ProduceRateLimit limits how fast the client buffers records across all
+// topics, overriding the default of no limit. Either limit can be zero to not
+// limit it: bytesPerSec limits record bytes (keys, values, and headers), and
+// recordsPerSec limits the number of records.
+//
+// Limits are enforced with a token bucket that allows bursting up to one
+// second's worth of bytes or records. A record larger than the per second
+// byte limit is allowed once the bucket is full. By default, Produce blocks
+// until the record is within the limit, the produce context is canceled, or
+// the client is closed; see ProduceRateLimitFail to fail records instead.
+// TryProduce and manually flushing clients never wait and fail records over
+// the limit with ErrRateLimited.
+//
+// This limits the rate records are buffered at, not the rate they are sent
+// to brokers at, and can be used to protect brokers from runaway producers.
+func ProduceRateLimit(bytesPerSec, recordsPerSec int64) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.rateBytes, cfg.rateRecords = bytesPerSec, recordsPerSec }}
+}
+
+// ProduceRateLimitPerTopic is like ProduceRateLimit, but limits every topic
+// independently. This can be used alongside ProduceRateLimit, in which case a
+// record must be within both its topic's limit and the global limit.
+func ProduceRateLimitPerTopic(bytesPerSec, recordsPerSec int64) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.topicRateBytes, cfg.topicRateRecords = bytesPerSec, recordsPerSec }}
+}
+
+// ProduceRateLimitFail opts into failing records with ErrRateLimited if
+// producing them would exceed ProduceRateLimit or ProduceRateLimitPerTopic,
+// rather than blocking until the record is within the limit.
+func ProduceRateLimitFail() ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.rateLimitFail = true }}
 }
 
 // RecordPartitioner uses the given partitioner to partition records, overriding
@@ -970,8 +1539,10 @@ func ProduceRequestTimeout(limit time.Duration) ProducerOpt {
 //
 // This option is different from RequestRetries to allow finer grained control
 // of when to fail when producing records.
+//
+// This option can be changed on a live client with Reconfigure.
 func RecordRetries(n int) ProducerOpt {
-	return producerOpt{func(cfg *cfg) { cfg.recordRetries = int64(n) }}
+	return liveProducerOpt{func(cfg *cfg) { cfg.recordRetries = lazyI64(n) }}
 }
 
 // UnknownTopicRetries sets the number of times a record can fail with
@@ -1025,8 +1596,11 @@ func ProducerOnDataLossDetected(fn func(string, int32)) ProducerOpt {
 // producer will likely be producing to many partitions; it is both unnecessary
 // to linger in this case and inefficient because the client will have many
 // timers running (and stopping and restarting) unnecessarily.
+//
+// This option can be changed on a live client with Reconfigure; partitions
+// that are already lingering use the new linger the next time they linger.
 func ProducerLinger(linger time.Duration) ProducerOpt {
-	return producerOpt{func(cfg *cfg) { cfg.linger = linger }}
+	return liveProducerOpt{func(cfg *cfg) { cfg.linger = lazyI64(linger) }}
 }
 
 // ManualFlushing disables auto-flushing when producing. While you can still
@@ -1065,6 +1639,14 @@ func RecordDeliveryTimeout(timeout time.Duration) ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.recordTimeout = timeout }}
 }
 
+// ProduceAckSampling samples the records passed to HookProduceRecordAcked,
+// calling the hook for only one of every n successfully produced records,
+// overriding the default of 1 (every record). This can be used to reduce the
+// cost of the hook when producing at high volume.
+func ProduceAckSampling(n int) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.ackSampling = int64(n) }}
+}
+
 // TransactionalID sets a transactional ID for the client, ensuring that
 // records are produced transactionally under this ID (exactly once semantics).
 //
@@ -1120,6 +1702,26 @@ func FetchMaxWait(wait time.Duration) ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.maxWait = int32(wait.Milliseconds()) }}
 }
 
+// FetchHedgeAfter opts into hedging slow fetches: if a broker has not
+// responded to a fetch within the given duration, the client issues the same
+// fetch to another replica of every partition in the request and uses
+// whichever response arrives first. This can reduce tail latency when a
+// single broker is slow (e.g. a GC pause or a bad disk). By default, fetches
+// are not hedged.
+//
+// Brokers wait up to FetchMaxWait for data before responding to a fetch, so
+// a duration below the max wait also hedges idle fetches. You likely want to
+// lower FetchMaxWait or use a duration larger than it.
+//
+// The hedged fetch is sent to the partitions' leader if they share one, and
+// otherwise to a follower replicating every partition; hedging to followers
+// requires brokers that support follower fetching (KIP-392). A hedged fetch
+// does not use a fetch session, and its response is only used if it has no
+// errors; otherwise, the client continues waiting for the original fetch.
+func FetchHedgeAfter(d time.Duration) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.fetchHedgeAfter = d }}
+}
+
 // FetchMaxBytes sets the maximum amount of bytes a broker will try to send
 // during a fetch, overriding the default 50MiB. Note that brokers may not obey
 // this limit if it has records larger than this limit. Also note that this
@@ -1133,8 +1735,30 @@ func FetchMaxWait(wait time.Duration) ConsumerOpt {
 // If what you are consuming is compressed, and compressed well, it is strongly
 // recommended to set this option so that decompression does not eat all of
 // your RAM.
+//
+// This option can be changed on a live client with Reconfigure, and takes
+// effect on the next fetch request.
 func FetchMaxBytes(b int32) ConsumerOpt {
-	return consumerOpt{func(cfg *cfg) { cfg.maxBytes = lazyI32(b) }}
+	return liveConsumerOpt{func(cfg *cfg) { cfg.maxBytes = lazyI32(b) }}
+}
+
+// WithDecompressor uses the given decompressor rather than the built-in
+// implementation when decompressing batches compressed with the given codec.
+// This is the consumer counterpart to CompressionCodec.WithCompressor, and
+// allows consuming data that requires special handling to decompress, such as
+// zstd with a trained dictionary:
+//
+//	dec, _ := zstd.NewReader(nil, zstd.WithDecoderDicts(dict))
+//	kgo.WithDecompressor(kgo.ZstdCompression(), zstdDict{dec})
+//
+// This option can be used multiple times for different codecs. This has no
+// effect for NoCompression.
+func WithDecompressor(codec CompressionCodec, d Decompressor) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) {
+		if codec.codec > codecNone && int(codec.codec) < len(cfg.decompressors) {
+			cfg.decompressors[codec.codec] = d
+		}
+	}}
 }
 
 // FetchMinBytes sets the minimum amount of bytes a broker will try to send
@@ -1155,8 +1779,43 @@ func FetchMinBytes(b int32) ConsumerOpt {
 // will still be returned so the client can make progress.
 //
 // This corresponds to the Java max.partition.fetch.bytes setting.
+//
+// This option can be changed on a live client with Reconfigure, and takes
+// effect on the next fetch request.
 func FetchMaxPartitionBytes(b int32) ConsumerOpt {
-	return consumerOpt{func(cfg *cfg) { cfg.maxPartBytes = lazyI32(b) }}
+	return liveConsumerOpt{func(cfg *cfg) { cfg.maxPartBytes = lazyI32(b) }}
+}
+
+// AdaptiveFetchMaxPartitionBytes enables adaptive per-partition fetch sizes,
+// allowing partitions that are lagging to be fetched with sizes up to max
+// bytes.
+//
+// Partitions begin fetching with FetchMaxPartitionBytes. If a partition is
+// still lagging after a fetch that was limited by the partition's fetch size,
+// the size for the partition is doubled for the next fetch, up to max. Once a
+// partition is caught up to its high watermark, its size is halved on every
+// fetch until it is back to FetchMaxPartitionBytes. This improves catch up
+// throughput for lagging partitions without permanently raising the memory
+// used for partitions that are caught up.
+//
+// Per-partition sizes are always limited by FetchMaxBytes. By default, fetch
+// sizes are not adaptive.
+func AdaptiveFetchMaxPartitionBytes(max int32) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.adaptiveMaxPartBytes = max }}
+}
+
+// MaxPollRecordsPerPartition sets the maximum number of records a single poll
+// returns for any one partition, overriding the default of no limit. Any
+// remaining records are kept buffered and returned in later polls. This
+// prevents one high throughput partition from dominating polls and starving
+// processing of quieter partitions.
+//
+// This limit applies to PollFetches and PollRecords, in addition to the
+// overall maxPollRecords limit of PollRecords. Like PollRecords, a source
+// (broker) is not fetched from again until all of its buffered records have
+// been polled.
+func MaxPollRecordsPerPartition(n int) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.maxPollPartitionRecords = n }}
 }
 
 // MaxConcurrentFetches sets the maximum number of fetch requests to allow in
@@ -1187,6 +1846,25 @@ func MaxConcurrentFetches(n int) ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.maxConcurrentFetches = n }}
 }
 
+// FetchDecodeConcurrency sets the maximum number of goroutines across the
+// client that decompress and decode fetch responses, overriding the default of
+// decoding each fetch response serially in the goroutine that issued it (i.e.,
+// one goroutine per broker).
+//
+// With this option, the partitions within a fetch response are decoded
+// concurrently, and at most n partitions are decoded at once across all
+// brokers. A large value lets consumers with many CPUs parallelize decoding
+// large compressed batches, while a small value bounds the CPU that decoding
+// can use; a value of 1 decodes one partition at a time across the entire
+// client.
+//
+// Note that HookFetchBatchRead may be called concurrently with this option.
+//
+// A value of 0 implies the default.
+func FetchDecodeConcurrency(n int) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.fetchDecodeConcurrency = n }}
+}
+
 // ConsumeResetOffset sets the offset to start consuming from, or if
 // OffsetOutOfRange is seen while fetching, to restart consuming from. The
 // default is NewOffset().AtStart(), i.e., the earliest offset.
@@ -1216,6 +1894,47 @@ func ConsumeResetOffset(offset Offset) ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.resetOffset = offset }}
 }
 
+// OnOffsetOutOfRange sets a function to call when fetching a partition fails
+// with OffsetOutOfRange, overriding the static ConsumeResetOffset. The
+// function receives the partition, the offset that was requested, and the
+// partition's current log bounds, and returns what the client should do:
+// reset to an offset, pause the partition, or fail the partition. See
+// OutOfRangeReset, OutOfRangePause, and OutOfRangeFail.
+//
+// This is useful for consumers that are sensitive to data loss: rather than
+// silently resetting, the consumer can inspect how far it fell behind the log
+// start offset and choose what to do.
+//
+// If consuming from a follower and the requested offset is past the
+// follower's high watermark, the client first validates the offset against
+// the leader (see KIP-392); the function is only called if the client would
+// otherwise reset the partition.
+//
+// The function is called in the goroutine processing the fetch response and
+// must not block.
+func OnOffsetOutOfRange(fn func(OffsetOutOfRange) OffsetOutOfRangeAction) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.onOutOfRange = fn }}
+}
+
+// OnLogTruncation sets a function to call when the client detects that a
+// partition's log was truncated past what the client had consumed, which
+// means records the client consumed were lost (e.g. after an unclean leader
+// election). Truncation is detected by validating the leader epoch of the last
+// consumed record against the current leader (KIP-320, Kafka 2.1+).
+//
+// When truncation is detected, the client resets the partition to the
+// divergent offset and injects an *ErrDataLoss into the next poll. This
+// function is called before that, and can be used to record potential data
+// loss or to halt consuming: if the function returns true, the client pauses
+// fetching the partition as if by PauseFetchPartitions. Fetching can be
+// resumed with ResumeFetchPartitions.
+//
+// The function is called in the goroutine validating offsets and must not
+// block.
+func OnLogTruncation(fn func(LogTruncation) bool) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.onLogTruncation = fn }}
+}
+
 // Rack specifies where the client is physically located and changes fetch
 // requests to consume from the closest replica as opposed to the leader
 // replica.
@@ -1239,6 +1958,49 @@ func ReadUncommitted() IsolationLevel { return IsolationLevel{0} }
 // ReadCommitted is an isolation level to only fetch committed records.
 func ReadCommitted() IsolationLevel { return IsolationLevel{1} }
 
+// FetchReplicaSelector sets a ReplicaSelector to choose which replica each
+// partition is consumed from, overriding the default of consuming from the
+// leader or from the broker's preferred read replica (see Rack).
+//
+// Consuming from followers requires brokers that support KIP-392 (Kafka 2.4+).
+func FetchReplicaSelector(selector ReplicaSelector) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.replicaSelector = selector }}
+}
+
+// PreferredReplicaMaxAge sets how long a partition is consumed from a
+// preferred read replica before the client returns to the leader, overriding
+// the default of 0 (staying on the replica until the partition's leader
+// changes). Returning to the leader gives the leader a chance to choose a
+// different preferred replica, and is similar to the Java client returning to
+// the leader every metadata.max.age.ms. Any data fetched from the replica
+// once it has aged out is discarded and fetched again from the leader.
+func PreferredReplicaMaxAge(age time.Duration) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.replicaMaxAge = age }}
+}
+
+// PreferredReplicaMaxErrors sets how many consecutive fetch errors a partition
+// can have while being consumed from a preferred read replica before the
+// client falls back to consuming from the leader, overriding the default of 0
+// (never falling back on errors; the client retries the replica until the
+// partition's leader changes). Non-retryable errors are not returned to
+// polling while falling back.
+func PreferredReplicaMaxErrors(n int) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.replicaMaxErrs = n }}
+}
+
+// OnReplicaSwitch sets a function to call whenever a partition switches the
+// broker it is consumed from: moving to a preferred read replica or a replica
+// chosen by a FetchReplicaSelector, returning to the leader because of
+// PreferredReplicaMaxAge or PreferredReplicaMaxErrors, or following a leader
+// change. Lag is relative to the high watermark of the broker being consumed
+// from, so this can be used to annotate lag metrics when the broker changes.
+//
+// This function is called concurrently for partitions on different brokers,
+// and it must not block.
+func OnReplicaSwitch(fn func(ReplicaSwitch)) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.onReplicaSwitch = fn }}
+}
+
 // FetchIsolationLevel sets the "isolation level" used for fetching
 // records, overriding the default ReadUncommitted.
 func FetchIsolationLevel(level IsolationLevel) ConsumerOpt {
@@ -1253,6 +2015,46 @@ func KeepControlRecords() ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.keepControl = true }}
 }
 
+// ConsumeRawBatches opts into returning fetched record batches as they were
+// received from the broker, without decompressing or decoding their records.
+// This is useful for proxies and replicators that re-produce whole batches and
+// otherwise waste CPU decoding and re-encoding every record.
+//
+// With this option, fetched partitions have no Records; batches are instead
+// returned in FetchPartition.RawBatches (and from Batches and PollBatches)
+// with the FetchBatch.Raw field set. Aborted transactional batches and control
+// batches are still dropped (unless KeepControlRecords is used), but record
+// level features such as value transforms and record hooks do not apply. The
+// first batch returned for a partition may begin before the offset being
+// consumed, and PollRecords does not split batches: a partition's batches
+// are returned together and do not count towards maxPollRecords.
+//
+// Old message set formats (pre Kafka 0.11) have no batch to pass through and
+// are still decoded into Records.
+func ConsumeRawBatches() ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.rawBatches = true }}
+}
+
+// PoolFetchBuffers opts into pooling the buffers that compressed record
+// batches are decompressed into, overriding the default of allocating a new
+// buffer per batch. This is meant for high throughput consumers whose garbage
+// collection is dominated by fetch allocations.
+//
+// The keys, values, and header values of consumed records point into these
+// buffers. A buffer is returned to the pool once every record that points
+// into it is recycled with Record.Recycle or Fetches.Recycle. Records that
+// are never recycled are garbage collected as usual. Only decompression
+// buffers are pooled: keys and values are not copied into pooled slices of
+// their own, and records from uncompressed batches point into the fetch
+// response, which is not pooled. Recycling records from uncompressed batches,
+// or from batches decompressed with a custom Decompressor, does nothing.
+//
+// A record's Key, Value, and Headers must not be used after the record is
+// recycled.
+func PoolFetchBuffers() ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.poolBuffers = true }}
+}
+
 // ConsumeTopics adds topics to use for consuming.
 //
 // By default, consuming will start at the beginning of partitions. To change
@@ -1293,6 +2095,40 @@ func ConsumeRegex() ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.regex = true }}
 }
 
+// ConsumeRegexRefreshInterval sets how often a regex consumer refreshes
+// metadata to discover new topics matching its regular expressions or to
+// detect deleted topics, overriding the default of MetadataMaxAge. The refresh
+// is still bounded by MetadataMinAge. This option is only used if consuming
+// via regex.
+func ConsumeRegexRefreshInterval(interval time.Duration) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.regexRefresh = interval }}
+}
+
+// OnRegexTopicsAdded sets a function to call when a regex consumer first sees
+// topics that match its regular expressions, before the topics are consumed.
+// This can be used to initialize any per-topic state. Topics are passed in
+// sorted order. A topic that is deleted and recreated is passed again.
+//
+// The function is called in a background goroutine and blocks further
+// discovery of new topics until it returns. This option is only used if
+// consuming via regex.
+func OnRegexTopicsAdded(fn func(*Client, []string)) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.onRegexAdded = fn }}
+}
+
+// OnRegexTopicsRemoved sets a function to call when a regex consumer stops
+// consuming topics that matched its regular expressions, which happens if the
+// topics are no longer returned in metadata (i.e., they were deleted) or if
+// the topics are purged with PurgeTopicsFromClient.
+// Topics are passed in sorted order.
+//
+// The function is called after the topics are removed from the consumer, and
+// blocks metadata updates until it returns. This option is only used if
+// consuming via regex.
+func OnRegexTopicsRemoved(fn func(*Client, []string)) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.onRegexRemoved = fn }}
+}
+
 // DisableFetchSessions sets the client to not use fetch sessions (Kafka 1.0+).
 //
 // A "fetch session" is is a way to reduce bandwidth for fetch requests &
@@ -1642,6 +2478,49 @@ func AutoCommitInterval(interval time.Duration) GroupOpt {
 	return groupOpt{func(cfg *cfg) { cfg.autocommitInterval = interval }}
 }
 
+// CommitCoalesceInterval sets the minimum time between issued asynchronous
+// commits, overriding the default 0 (no minimum).
+//
+// If an asynchronous commit (an autocommit or CommitOffsets, and by extension
+// CommitRecords and CommitUncommittedOffsets) is issued less than interval
+// after the prior commit, the commit is held until the interval elapses. Any
+// further asynchronous commits in that window are merged into the held commit,
+// with later offsets for a partition replacing earlier ones, and the single
+// resulting OffsetCommit request is issued with the context of the latest
+// commit. Every merged commit's onDone is called with that one request and
+// response. Synchronous commits (CommitOffsetsSync, and the default revoke)
+// are never held: they absorb anything being held and are issued immediately.
+//
+// This option is useful if you commit at a very high rate, where each commit
+// would otherwise cancel the prior in flight commit and issue a new request.
+// By default, a held commit is flushed immediately when a rebalance begins;
+// see CommitCoalesceNoRebalanceFlush.
+func CommitCoalesceInterval(interval time.Duration) GroupOpt {
+	return groupOpt{func(cfg *cfg) { cfg.commitCoalesceInterval = interval }}
+}
+
+// CommitMaxPartitions sets the maximum number of partitions to include in a
+// single OffsetCommit request, overriding the default 0 (unlimited).
+//
+// Commits for more partitions are split into multiple requests that are issued
+// one after another. The onDone for the commit is called once with the full
+// request and a response merging every split response; if any split request
+// fails, onDone receives the error and no further split requests are issued.
+func CommitMaxPartitions(n int) GroupOpt {
+	return groupOpt{func(cfg *cfg) { cfg.commitMaxPartitions = n }}
+}
+
+// CommitCoalesceNoRebalanceFlush opts out of flushing a commit that is being
+// held by CommitCoalesceInterval when a rebalance begins.
+//
+// Commits block joining the group, so by default a held commit is issued
+// immediately when a rebalance starts to avoid delaying the rebalance. With
+// this option, the held commit instead waits out the remainder of its
+// interval, which may delay rebalancing by up to the coalesce interval.
+func CommitCoalesceNoRebalanceFlush() GroupOpt {
+	return groupOpt{func(cfg *cfg) { cfg.commitNoRebalanceFlush = true }}
+}
+
 // AutoCommitMarks switches the autocommitting behavior to only commit "marked"
 // records, which can be done with the MarkCommitRecords method.
 //
@@ -73,6 +73,124 @@ func NoResetOffset() Offset {
 	}
 }
 
+// OffsetOutOfRange describes a partition that failed fetching with
+// OffsetOutOfRange; see OnOffsetOutOfRange.
+type OffsetOutOfRange struct {
+	Topic     string // Topic is the topic that failed fetching.
+	Partition int32  // Partition is the partition that failed fetching.
+
+	// Offset is the offset that the client requested.
+	Offset int64
+	// LogStartOffset is the partition's log start offset, as returned in
+	// the fetch response.
+	LogStartOffset int64
+	// HighWatermark is the partition's high watermark, as returned in the
+	// fetch response.
+	HighWatermark int64
+}
+
+// OffsetOutOfRangeAction is what the client should do when a partition fails
+// fetching with OffsetOutOfRange; see OnOffsetOutOfRange.
+type OffsetOutOfRangeAction struct {
+	reset Offset
+	pause bool
+}
+
+// OutOfRangeReset returns an action that resets the partition to the given
+// offset, exactly as if the offset were used in ConsumeResetOffset. For
+// example, NewOffset().AtStart() resets to the earliest offset,
+// NewOffset().AtEnd() resets to the latest offset, and NewOffset().At(n)
+// resets to offset n (or the nearest of the log bounds, if n is out of range).
+func OutOfRangeReset(o Offset) OffsetOutOfRangeAction {
+	return OffsetOutOfRangeAction{reset: o}
+}
+
+// OutOfRangePause returns an action that pauses fetching the partition as if
+// by PauseFetchPartitions and returns the OffsetOutOfRange error in the next
+// poll. Fetching can be resumed with ResumeFetchPartitions, usually after
+// using SetOffsets.
+func OutOfRangePause() OffsetOutOfRangeAction {
+	return OffsetOutOfRangeAction{reset: NoResetOffset(), pause: true}
+}
+
+// OutOfRangeFail returns an action that fails the partition, as if
+// ConsumeResetOffset was NoResetOffset: the OffsetOutOfRange error is returned
+// in polls until the partition's offset is changed with SetOffsets.
+func OutOfRangeFail() OffsetOutOfRangeAction {
+	return OffsetOutOfRangeAction{reset: NoResetOffset()}
+}
+
+// outOfRangeReset returns the offset to reset an out of range partition to,
+// pausing the partition if the OnOffsetOutOfRange function says to.
+func (cl *Client) outOfRangeReset(topic string, partition int32, offset int64, fp *FetchPartition) Offset {
+	fn := cl.cfg.onOutOfRange
+	if fn == nil {
+		return cl.cfg.resetOffset
+	}
+	action := fn(OffsetOutOfRange{
+		Topic:          cl.unnsTopic(topic),
+		Partition:      partition,
+		Offset:         offset,
+		LogStartOffset: fp.LogStartOffset,
+		HighWatermark:  fp.HighWatermark,
+	})
+	if action.pause {
+		cl.consumer.pausePartition(topic, partition)
+	}
+	return action.reset
+}
+
+// LogTruncation describes a partition whose log was truncated past what the
+// client had consumed, as detected by validating the leader epoch of the last
+// consumed record (KIP-320); see OnLogTruncation.
+type LogTruncation struct {
+	Topic     string // Topic is the truncated topic.
+	Partition int32  // Partition is the truncated partition.
+
+	// ConsumedTo is the offset the client had consumed to before
+	// truncation was detected.
+	ConsumedTo int64
+	// ResetTo is the divergent offset: the end offset of the epoch of the
+	// last consumed record, according to the current leader. Records from
+	// ResetTo to ConsumedTo were lost, and the client resets the partition
+	// to ResetTo.
+	ResetTo int64
+}
+
+// onLogTruncation logs truncation and calls the OnLogTruncation function,
+// pausing the partition if the function says to.
+func (cl *Client) onLogTruncation(edl *ErrDataLoss) {
+	cl.cfg.logger.Log(LogLevelWarn, "detected log truncation, resetting to the divergent offset",
+		"topic", edl.Topic,
+		"partition", edl.Partition,
+		"consumed_to", edl.ConsumedTo,
+		"reset_to", edl.ResetTo,
+	)
+	fn := cl.cfg.onLogTruncation
+	if fn == nil {
+		return
+	}
+	if pause := fn(LogTruncation{
+		Topic:      cl.unnsTopic(edl.Topic),
+		Partition:  edl.Partition,
+		ConsumedTo: edl.ConsumedTo,
+		ResetTo:    edl.ResetTo,
+	}); pause {
+		cl.consumer.pausePartition(edl.Topic, edl.Partition)
+	}
+}
+
+// pausePartition pauses fetching a partition as if by PauseFetchPartitions.
+func (c *consumer) pausePartition(topic string, partition int32) {
+	c.pausedMu.Lock()
+	tps := map[string][]int32{topic: {partition}}
+	c.stopPauseTimers(tps)
+	paused := c.clonePaused()
+	paused.addPartitions(tps)
+	c.storePaused(paused)
+	c.pausedMu.Unlock()
+}
+
 // AfterMilli returns an offset that consumes from the first offset after a
 // given timestamp. This option is not compatible with At/Relative/WithEpoch;
 // using any of those will clear the special millisecond state.
@@ -153,12 +271,17 @@ func (o Offset) At(at int64) Offset {
 
 type consumer struct {
 	bufferedRecords atomicI64
+	bufferedBytes   atomicI64 // key and value bytes; for PollMinBytes
 
 	cl *Client
 
 	pausedMu sync.Mutex   // grabbed when updating paused
 	paused   atomic.Value // loaded when issuing fetches
 
+	// pauseTimers tracks partitions paused with PauseFetchPartitionsFor,
+	// resuming each partition when its timer fires. Guarded by pausedMu.
+	pauseTimers map[string]map[int32]*time.Timer
+
 	// mu is grabbed when
 	//  - polling fetches, for quickly draining sources / updating group uncommitted
 	//  - calling assignPartitions (group / direct updates)
@@ -166,6 +289,14 @@ type consumer struct {
 	d  *directConsumer // if non-nil, we are consuming partitions directly
 	g  *groupConsumer  // if non-nil, we are consuming as a group member
 
+	// With LazyConnect, we trigger the initial metadata update on the
+	// first poll rather than in init.
+	lazyInit sync.Once
+
+	// decodeSem bounds concurrent fetch partition decoding across all
+	// sources if FetchDecodeConcurrency is set.
+	decodeSem chan struct{}
+
 	// On metadata update, if the consumer is set (direct or group), the
 	// client begins a goroutine that updates the consumer kind's
 	// assignments.
@@ -195,6 +326,12 @@ type consumer struct {
 	sourcesReadyForDraining []*source
 	fakeReadyForDraining    []Fetch
 
+	intercepts   []HookFetchRecordIntercept
+	latencyHooks []HookFetchPartitionLatency
+
+	lagMu sync.Mutex
+	lag   map[string]map[int32]PartitionLag // updated when polling
+
 	pollWaitMu    sync.Mutex
 	pollWaitC     *sync.Cond
 	pollWaitState uint64 // 0 == nothing, low 32 bits: # pollers, high 32: # waiting rebalances
@@ -289,8 +426,20 @@ func (c *consumer) init(cl *Client) {
 	c.paused.Store(make(pausedTopics))
 	c.sourcesReadyCond = sync.NewCond(&c.sourcesReadyMu)
 	c.pollWaitC = sync.NewCond(&c.pollWaitMu)
+	if n := cl.cfg.fetchDecodeConcurrency; n > 0 {
+		c.decodeSem = make(chan struct{}, n)
+	}
+
+	cl.cfg.hooks.each(func(h Hook) {
+		if h, ok := h.(HookFetchRecordIntercept); ok {
+			c.intercepts = append(c.intercepts, h)
+		}
+		if h, ok := h.(HookFetchPartitionLatency); ok {
+			c.latencyHooks = append(c.latencyHooks, h)
+		}
+	})
 
-	if len(cl.cfg.topics) > 0 || len(cl.cfg.partitions) > 0 {
+	if (len(cl.cfg.topics) > 0 || len(cl.cfg.partitions) > 0) && !cl.cfg.lazyConnect {
 		defer cl.triggerUpdateMetadataNow("querying metadata for consumer initialization") // we definitely want to trigger a metadata update
 	}
 
@@ -364,6 +513,38 @@ func (cl *Client) PollFetches(ctx context.Context) Fetches {
 	return cl.PollRecords(ctx, 0)
 }
 
+// PollBatches is like PollFetches, but returns records grouped into the record
+// batches they were fetched in, along with each batch's metadata (producer
+// ID, compression, base timestamp, etc.). This is useful when forwarding
+// batches wholesale, or when downstream processing needs batch boundaries.
+//
+// Every fetched partition is returned as one FetchBatches, including
+// partitions with errors and the injected errors described in PollFetches.
+// Partitions with neither records nor an error are skipped. All other
+// PollFetches documentation applies.
+func (cl *Client) PollBatches(ctx context.Context) []FetchBatches {
+	var bs []FetchBatches
+	cl.PollFetches(ctx).EachTopic(func(t FetchTopic) {
+		for i := range t.Partitions {
+			p := &t.Partitions[i]
+			batches := p.Batches()
+			if len(batches) == 0 && p.Err == nil {
+				continue
+			}
+			bs = append(bs, FetchBatches{
+				Topic:            t.Topic,
+				Partition:        p.Partition,
+				Err:              p.Err,
+				HighWatermark:    p.HighWatermark,
+				LastStableOffset: p.LastStableOffset,
+				LogStartOffset:   p.LogStartOffset,
+				Batches:          batches,
+			})
+		}
+	})
+	return bs
+}
+
 // PollRecords waits for records to be available, returning as soon as any
 // broker returns records in a fetch. If the context is nil, this function
 // will return immediately with any currently buffered records.
@@ -386,11 +567,109 @@ func (cl *Client) PollFetches(ctx context.Context) Fetches {
 // this by using BlockRebalanceOnPoll, but this comes with different tradeoffs.
 // See the documentation on BlockRebalanceOnPoll for more information.
 func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
+	return cl.unnsFetches(cl.pollRecords(ctx, pollCfg{maxRecords: maxPollRecords}))
+}
+
+// PollOpt is an option to tune a single call to PollFetchesWith, overriding
+// the client's defaults for that call only.
+type PollOpt interface {
+	apply(*pollCfg)
+}
+
+type pollOpt struct{ fn func(*pollCfg) }
+
+func (opt pollOpt) apply(cfg *pollCfg) { opt.fn(cfg) }
+
+type pollCfg struct {
+	maxRecords int
+	maxWait    time.Duration
+	minBytes   int64
+	partitions map[string]map[int32]struct{} // namespaced; nil means all
+}
+
+// PollMaxRecords returns at most n records from the poll, as if by
+// PollRecords. A non-positive n returns all buffered records.
+func PollMaxRecords(n int) PollOpt {
+	return pollOpt{func(cfg *pollCfg) { cfg.maxRecords = n }}
+}
+
+// PollMaxWait bounds how long the poll waits for records. Once d elapses,
+// the poll returns whatever is available, which may be nothing. Unlike context
+// cancelation, no error is injected if the wait expires. By default, a poll
+// waits until the context is canceled.
+func PollMaxWait(d time.Duration) PollOpt {
+	return pollOpt{func(cfg *pollCfg) { cfg.maxWait = d }}
+}
+
+// PollMinBytes has the poll wait until at least n bytes of record keys and
+// values are buffered in the client before returning, or until PollMaxWait
+// elapses or the context is canceled. This allows trading latency for larger
+// batches on a per-poll basis.
+//
+// Buffered bytes are counted across all partitions, even if the poll is
+// restricted with PollPartitions. Each broker buffers at most one fetch at a
+// time, so a minimum larger than what brokers can buffer is only satisfied
+// by PollMaxWait or the context; you likely want to pair this with
+// PollMaxWait.
+func PollMinBytes(n int64) PollOpt {
+	return pollOpt{func(cfg *pollCfg) { cfg.minBytes = n }}
+}
+
+// PollPartitions restricts the poll to only return records from the given
+// partitions. Buffered records for other partitions remain buffered until a
+// later poll takes them; note that a broker does not fetch again until its
+// entire buffered fetch is polled. Injected errors (client closed, context
+// canceled, or fatal partition errors) are still returned.
+func PollPartitions(topicPartitions map[string][]int32) PollOpt {
+	return pollOpt{func(cfg *pollCfg) {
+		cfg.partitions = make(map[string]map[int32]struct{}, len(topicPartitions))
+		for topic, partitions := range topicPartitions {
+			ps := cfg.partitions[topic]
+			if ps == nil {
+				ps = make(map[int32]struct{}, len(partitions))
+				cfg.partitions[topic] = ps
+			}
+			for _, partition := range partitions {
+				ps[partition] = struct{}{}
+			}
+		}
+	}}
+}
+
+// PollFetchesWith is PollFetches with per-call options overriding the client
+// defaults, allowing one consumer to alternate between latency sensitive and
+// batch polls. See the PollOpt functions for what can be tuned. All
+// PollFetches documentation applies.
+func (cl *Client) PollFetchesWith(ctx context.Context, opts ...PollOpt) Fetches {
+	var cfg pollCfg
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	if cfg.partitions != nil && cl.cfg.topicToKafka != nil {
+		ns := make(map[string]map[int32]struct{}, len(cfg.partitions))
+		for topic, ps := range cfg.partitions {
+			ns[cl.nsTopic(topic)] = ps
+		}
+		cfg.partitions = ns
+	}
+	return cl.unnsFetches(cl.pollRecords(ctx, cfg))
+}
+
+func (cl *Client) pollRecords(ctx context.Context, po pollCfg) Fetches {
+	maxPollRecords := po.maxRecords
 	if maxPollRecords == 0 {
 		maxPollRecords = -1
 	}
 	c := &cl.consumer
 
+	if cl.cfg.lazyConnect {
+		c.lazyInit.Do(func() {
+			if len(cl.cfg.topics) > 0 || len(cl.cfg.partitions) > 0 {
+				cl.triggerUpdateMetadataNow("querying metadata for lazy consumer initialization")
+			}
+		})
+	}
+
 	c.g.undirtyUncommitted()
 
 	// If the user gave us a canceled context, we bail immediately after
@@ -430,20 +709,33 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 		defer c.mu.Unlock()
 
 		c.sourcesReadyMu.Lock()
-		if maxPollRecords < 0 {
+		perPartition := c.cl.cfg.maxPollPartitionRecords
+		if maxPollRecords < 0 && perPartition <= 0 && po.partitions == nil {
 			for _, ready := range c.sourcesReadyForDraining {
 				fetches = append(fetches, ready.takeBuffered())
 			}
 			c.sourcesReadyForDraining = nil
 		} else {
-			for len(c.sourcesReadyForDraining) > 0 && maxPollRecords > 0 {
-				source := c.sourcesReadyForDraining[0]
-				fetch, taken, drained := source.takeNBuffered(maxPollRecords)
+			if maxPollRecords < 0 {
+				maxPollRecords = math.MaxInt
+			}
+			// If a source is not drained, either we have taken
+			// maxPollRecords or we took the per-partition limit
+			// from each of its partitions. We only take from each
+			// source once so that no partition returns more than
+			// the per-partition limit.
+			for i := 0; i < len(c.sourcesReadyForDraining) && maxPollRecords > 0; {
+				source := c.sourcesReadyForDraining[i]
+				fetch, taken, drained := source.takeNBuffered(maxPollRecords, perPartition, po.partitions)
 				if drained {
-					c.sourcesReadyForDraining = c.sourcesReadyForDraining[1:]
+					c.sourcesReadyForDraining = append(c.sourcesReadyForDraining[:i], c.sourcesReadyForDraining[i+1:]...)
+				} else {
+					i++
 				}
 				maxPollRecords -= taken
-				fetches = append(fetches, fetch)
+				if len(fetch.Topics) > 0 {
+					fetches = append(fetches, fetch)
+				}
 			}
 		}
 
@@ -470,13 +762,29 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 		if c.g != nil {
 			c.g.updateUncommitted(realFetches)
 		}
+
+		// We intercept after updating uncommitted so that filtered
+		// records are still considered consumed.
+		c.intercept(realFetches)
 	}
 
 	// We try filling fetches once before waiting. If we have no context,
 	// we guarantee that we just drain anything available and return.
-	fill()
-	if len(fetches) > 0 || ctx == nil {
-		return fetches
+	// If we need a minimum of bytes buffered, we only fill early if the
+	// minimum is already met.
+	enoughBytes := func() bool { return po.minBytes <= 0 || c.bufferedBytes.Load() >= po.minBytes }
+	if ctx == nil || enoughBytes() {
+		fill()
+		if len(fetches) > 0 || ctx == nil {
+			return fetches
+		}
+	}
+
+	var maxWait <-chan time.Time
+	if po.maxWait > 0 {
+		timer := time.NewTimer(po.maxWait)
+		defer timer.Stop()
+		maxWait = timer.C
 	}
 
 	done := make(chan struct{})
@@ -486,7 +794,7 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 		defer c.sourcesReadyMu.Unlock()
 		defer close(done)
 
-		for !quit && len(c.sourcesReadyForDraining) == 0 {
+		for !quit && (!c.readyForDrainingLocked(po.partitions) || !enoughBytes()) {
 			c.sourcesReadyCond.Wait()
 		}
 	}()
@@ -505,6 +813,8 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 	case <-ctx.Done():
 		exit()
 		return errFetch(ctx.Err())
+	case <-maxWait:
+		exit()
 	case <-done:
 	}
 
@@ -512,6 +822,60 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 	return fetches
 }
 
+// readyForDrainingLocked returns whether any source ready for draining has
+// buffered data for the given partitions, or for anything if partitions is
+// nil. This must be called with sourcesReadyMu held.
+func (c *consumer) readyForDrainingLocked(partitions map[string]map[int32]struct{}) bool {
+	if partitions == nil {
+		return len(c.sourcesReadyForDraining) > 0
+	}
+	for _, source := range c.sourcesReadyForDraining {
+		for _, t := range source.buffered.fetch.Topics {
+			ps, exists := partitions[t.Topic]
+			if !exists {
+				continue
+			}
+			for _, p := range t.Partitions {
+				if _, exists := ps[p.Partition]; exists {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// intercept runs all fetch record intercept hooks against every record in
+// the fetches, modifying the fetches in place to drop filtered records.
+func (c *consumer) intercept(fetches Fetches) {
+	if len(c.intercepts) == 0 {
+		return
+	}
+	for i := range fetches {
+		f := &fetches[i]
+		for j := range f.Topics {
+			t := &f.Topics[j]
+			for k := range t.Partitions {
+				p := &t.Partitions[k]
+				keep := p.Records[:0]
+			records:
+				for _, r := range p.Records {
+					for _, h := range c.intercepts {
+						if !h.OnFetchRecordIntercept(r) {
+							continue records
+						}
+					}
+					keep = append(keep, r)
+				}
+				for l := len(keep); l < len(p.Records); l++ {
+					p.Records[l] = nil // allow filtered records to be garbage collected
+				}
+				p.Records = keep
+			}
+		}
+	}
+}
+
 // AllowRebalance allows a consumer group to rebalance if it was blocked by you
 // polling records in tandem with the BlockRebalanceOnPoll option.
 //
@@ -525,10 +889,176 @@ func (cl *Client) AllowRebalance() {
 	cl.consumer.allowRebalance()
 }
 
+// PartitionLag is the lag of a consumed partition as of the last time records
+// were polled from the partition.
+type PartitionLag struct {
+	// Offset is the next offset that will be polled.
+	Offset int64
+	// HighWatermark is the high watermark of the partition in the fetch
+	// response that records were last polled from.
+	HighWatermark int64
+	// LogStartOffset is the log start offset of the partition in the fetch
+	// response that records were last polled from.
+	LogStartOffset int64
+	// Lag is the number of records between Offset and HighWatermark. If
+	// Offset is before LogStartOffset, Lag is the number of records
+	// between LogStartOffset and HighWatermark.
+	Lag int64
+}
+
+// Lag returns the current lag for every partition that is being consumed and
+// that records have been polled from, computed from the high watermark and
+// log start offset in fetch responses. This allows tracking lag without
+// issuing any requests. Lag is updated every time records are polled, so the
+// returned lag is only as fresh as the last poll for a partition.
+//
+// Partitions that are no longer consumed, such as partitions revoked in a
+// group rebalance, are not returned.
+func (cl *Client) Lag() map[string]map[int32]PartitionLag {
+	c := &cl.consumer
+	c.lagMu.Lock()
+	defer c.lagMu.Unlock()
+
+	if len(c.lag) == 0 {
+		return nil
+	}
+	lag := make(map[string]map[int32]PartitionLag, len(c.lag))
+	for t, ps := range c.lag {
+		lps := make(map[int32]PartitionLag, len(ps))
+		for p, l := range ps {
+			lps[p] = l
+		}
+		lag[cl.unnsTopic(t)] = lps
+	}
+	return lag
+}
+
+// PartitionPosition is the consume position of an assigned partition.
+type PartitionPosition struct {
+	// Fetch is the offset the next fetch for the partition starts at, or
+	// -1 if the client is still loading the offset to start fetching from
+	// (for example, listing offsets after the partition is assigned or
+	// after an offset out of range reset).
+	Fetch int64
+	// Polled is the offset of the last record polled from the partition,
+	// or -1 if nothing has been polled since the partition was assigned.
+	Polled int64
+	// Committed is the last committed offset for the partition if
+	// consuming as a group member, or -1 if nothing is committed or if
+	// not consuming in a group. Like all commits, this is the offset of
+	// the next record to consume.
+	Committed int64
+}
+
+// Positions returns the consume position of every partition currently
+// assigned to the client, similar to the position and committed functions in
+// the Java consumer. This does not issue any requests and is cheap enough to
+// use in health checks.
+//
+// If the client is not consuming, this returns nil.
+func (cl *Client) Positions() map[string]map[int32]PartitionPosition {
+	c := &cl.consumer
+	var (
+		assigned map[string][]int32
+		tps      *topicsPartitions
+	)
+	switch {
+	case c.g != nil:
+		assigned, tps = c.g.nowAssigned.read(), c.g.tps
+	case c.d != nil:
+		c.mu.Lock()
+		assigned = make(map[string][]int32, len(c.d.using))
+		for t, ps := range c.d.using {
+			for p := range ps {
+				assigned[t] = append(assigned[t], p)
+			}
+		}
+		c.mu.Unlock()
+		tps = c.d.tps
+	default:
+		return nil
+	}
+	if len(assigned) == 0 {
+		return nil
+	}
+
+	committed := cl.committedOffsets()
+	topics := tps.load()
+
+	c.lagMu.Lock()
+	defer c.lagMu.Unlock()
+
+	positions := make(map[string]map[int32]PartitionPosition, len(assigned))
+	for t, ps := range assigned {
+		var parts []*topicPartition
+		if td, ok := topics[t]; ok {
+			parts = td.load().partitions
+		}
+		tpositions := make(map[int32]PartitionPosition, len(ps))
+		for _, p := range ps {
+			pos := PartitionPosition{Fetch: -1, Polled: -1, Committed: -1}
+			if p >= 0 && int(p) < len(parts) && parts[p].cursor != nil {
+				pos.Fetch = parts[p].cursor.position.Load()
+			}
+			if l, ok := c.lag[t][p]; ok {
+				pos.Polled = l.Offset - 1
+			}
+			if eo, ok := committed[t][p]; ok {
+				pos.Committed = eo.Offset
+			}
+			tpositions[p] = pos
+		}
+		positions[cl.unnsTopic(t)] = tpositions
+	}
+	return positions
+}
+
+// setLag saves the lag for a partition after records are polled.
+func (c *consumer) setLag(topic string, partition int32, offset, hwm, logStart int64) {
+	from := offset
+	if from < logStart {
+		from = logStart
+	}
+	lag := hwm - from
+	if lag < 0 {
+		lag = 0
+	}
+
+	c.lagMu.Lock()
+	defer c.lagMu.Unlock()
+	if c.lag == nil {
+		c.lag = make(map[string]map[int32]PartitionLag)
+	}
+	ps := c.lag[topic]
+	if ps == nil {
+		ps = make(map[int32]PartitionLag)
+		c.lag[topic] = ps
+	}
+	ps[partition] = PartitionLag{
+		Offset:         offset,
+		HighWatermark:  hwm,
+		LogStartOffset: logStart,
+		Lag:            lag,
+	}
+}
+
+// delLag deletes the lag for a partition that is no longer being consumed.
+func (c *consumer) delLag(topic string, partition int32) {
+	c.lagMu.Lock()
+	defer c.lagMu.Unlock()
+	ps := c.lag[topic]
+	delete(ps, partition)
+	if len(ps) == 0 {
+		delete(c.lag, topic)
+	}
+}
+
 // UpdateFetchMaxBytes updates the max bytes that a fetch request will ask for
 // and the max partition bytes that a fetch request will ask for each
 // partition.
 func (cl *Client) UpdateFetchMaxBytes(maxBytes, maxPartBytes int32) {
+	cl.reconfigureMu.Lock()
+	defer cl.reconfigureMu.Unlock()
 	cl.cfg.maxBytes.store(maxBytes)
 	cl.cfg.maxPartBytes.store(maxPartBytes)
 }
@@ -550,16 +1080,16 @@ func (cl *Client) UpdateFetchMaxBytes(maxBytes, maxPartBytes int32) {
 func (cl *Client) PauseFetchTopics(topics ...string) []string {
 	c := &cl.consumer
 	if len(topics) == 0 {
-		return c.loadPaused().pausedTopics()
+		return cl.unnsTopics(c.loadPaused().pausedTopics())
 	}
 
 	c.pausedMu.Lock()
 	defer c.pausedMu.Unlock()
 
 	paused := c.clonePaused()
-	paused.addTopics(topics...)
+	paused.addTopics(cl.nsTopics(topics)...)
 	c.storePaused(paused)
-	return paused.pausedTopics()
+	return cl.unnsTopics(paused.pausedTopics())
 }
 
 // PauseFetchPartitions sets the client to no longer fetch the given partitions
@@ -579,16 +1109,117 @@ func (cl *Client) PauseFetchTopics(topics ...string) []string {
 func (cl *Client) PauseFetchPartitions(topicPartitions map[string][]int32) map[string][]int32 {
 	c := &cl.consumer
 	if len(topicPartitions) == 0 {
-		return c.loadPaused().pausedPartitions()
+		return cl.unnsPartitions(c.loadPaused().pausedPartitions())
 	}
 
 	c.pausedMu.Lock()
 	defer c.pausedMu.Unlock()
 
+	ns := cl.nsPartitions(topicPartitions)
+	c.stopPauseTimers(ns)
 	paused := c.clonePaused()
-	paused.addPartitions(topicPartitions)
+	paused.addPartitions(ns)
 	c.storePaused(paused)
-	return paused.pausedPartitions()
+	return cl.unnsPartitions(paused.pausedPartitions())
+}
+
+// PauseFetchPartitionsFor is PauseFetchPartitions, but the given partitions
+// are automatically resumed after d. This is useful for backing off a
+// partition (i.e., if processing is rate limited) without managing your own
+// timers. The partitions remain paused across rebalances until d elapses.
+//
+// Pausing a partition again restarts its timer; pausing a partition with
+// PauseFetchPartitions or resuming it with ResumeFetchPartitions stops its
+// timer. If d is not positive, this is equivalent to PauseFetchPartitions.
+// As with PauseFetchPartitions, calling this with no partitions returns the
+// list of currently paused partitions.
+func (cl *Client) PauseFetchPartitionsFor(d time.Duration, topicPartitions map[string][]int32) map[string][]int32 {
+	c := &cl.consumer
+	if d <= 0 || len(topicPartitions) == 0 {
+		return cl.PauseFetchPartitions(topicPartitions)
+	}
+
+	c.pausedMu.Lock()
+	defer c.pausedMu.Unlock()
+
+	ns := cl.nsPartitions(topicPartitions)
+	c.stopPauseTimers(ns)
+	if c.pauseTimers == nil {
+		c.pauseTimers = make(map[string]map[int32]*time.Timer)
+	}
+	for topic, partitions := range ns {
+		timers := c.pauseTimers[topic]
+		if timers == nil {
+			timers = make(map[int32]*time.Timer)
+			c.pauseTimers[topic] = timers
+		}
+		for _, partition := range partitions {
+			topic, partition := topic, partition
+			var t *time.Timer // read by autoResume only once pausedMu is held
+			t = time.AfterFunc(d, func() { c.autoResume(topic, partition, &t) })
+			timers[partition] = t
+		}
+	}
+
+	paused := c.clonePaused()
+	paused.addPartitions(ns)
+	c.storePaused(paused)
+	return cl.unnsPartitions(paused.pausedPartitions())
+}
+
+// autoResume resumes a partition paused with PauseFetchPartitionsFor, if the
+// timer that fired is still the partition's current timer.
+func (c *consumer) autoResume(topic string, partition int32, t **time.Timer) {
+	c.pausedMu.Lock()
+	timers := c.pauseTimers[topic]
+	if timers[partition] != *t { // the timer was stopped or replaced
+		c.pausedMu.Unlock()
+		return
+	}
+	delete(timers, partition)
+	if len(timers) == 0 {
+		delete(c.pauseTimers, topic)
+	}
+	paused := c.clonePaused()
+	paused.delPartitions(map[string][]int32{topic: {partition}})
+	c.storePaused(paused)
+	c.pausedMu.Unlock()
+
+	c.cl.allSinksAndSources(func(sns sinkAndSource) {
+		sns.source.maybeConsume()
+	})
+}
+
+// stopPauseTimers stops any auto resume timers for the given (namespaced)
+// partitions. This must be called with pausedMu held.
+func (c *consumer) stopPauseTimers(topicPartitions map[string][]int32) {
+	for topic, partitions := range topicPartitions {
+		timers, exists := c.pauseTimers[topic]
+		if !exists {
+			continue
+		}
+		for _, partition := range partitions {
+			if t, exists := timers[partition]; exists {
+				t.Stop()
+				delete(timers, partition)
+			}
+		}
+		if len(timers) == 0 {
+			delete(c.pauseTimers, topic)
+		}
+	}
+}
+
+// stopAllPauseTimers stops every auto resume timer; used when closing.
+func (c *consumer) stopAllPauseTimers() {
+	c.pausedMu.Lock()
+	defer c.pausedMu.Unlock()
+	for _, timers := range c.pauseTimers {
+		for _, t := range timers {
+			t.Stop()
+		}
+	}
+	c.pauseTimers = nil
 }
 
 // ResumeFetchTopics resumes fetching the input topics if they were previously
@@ -604,7 +1235,7 @@ func (cl *Client) ResumeFetchTopics(topics ...string) {
 	defer c.pausedMu.Unlock()
 
 	paused := c.clonePaused()
-	paused.delTopics(topics...)
+	paused.delTopics(cl.nsTopics(topics)...)
 	c.storePaused(paused)
 }
 
@@ -621,8 +1252,10 @@ func (cl *Client) ResumeFetchPartitions(topicPartitions map[string][]int32) {
 	c.pausedMu.Lock()
 	defer c.pausedMu.Unlock()
 
+	ns := cl.nsPartitions(topicPartitions)
+	c.stopPauseTimers(ns)
 	paused := c.clonePaused()
-	paused.delPartitions(topicPartitions)
+	paused.delPartitions(ns)
 	c.storePaused(paused)
 }
 
@@ -644,7 +1277,7 @@ func (cl *Client) ResumeFetchPartitions(topicPartitions map[string][]int32) {
 // call) and to not use this concurrent with committing. Any other usage is
 // prone to odd interactions.
 func (cl *Client) SetOffsets(setOffsets map[string]map[int32]EpochOffset) {
-	cl.setOffsets(setOffsets, true)
+	cl.setOffsets(cl.nsOffsets(setOffsets), true)
 }
 
 func (cl *Client) setOffsets(setOffsets map[string]map[int32]EpochOffset, log bool) {
@@ -679,6 +1312,114 @@ func (cl *Client) setOffsets(setOffsets map[string]map[int32]EpochOffset, log bo
 	}
 }
 
+// SetOffsetsForTimes sets any matching partitions in setTimes to the offset of
+// the first record with a timestamp at or after the given time, or to the end
+// of the partition if no record is that new. Timestamps are resolved to
+// offsets with a ListOffsets request, and then offsets are set exactly as in
+// SetOffsets; all of the caveats of SetOffsets apply. Any buffered but not yet
+// polled fetches for the set partitions are dropped, and in flight fetches
+// are discarded.
+//
+// If resolving any timestamp fails, this returns the error and no offsets
+// are set.
+func (cl *Client) SetOffsetsForTimes(ctx context.Context, setTimes map[string]map[int32]time.Time) error {
+	if len(setTimes) == 0 {
+		return nil
+	}
+	millis := make(map[string]map[int32]int64, len(setTimes))
+	for topic, partitions := range setTimes {
+		ps := make(map[int32]int64, len(partitions))
+		for partition, at := range partitions {
+			ps[partition] = at.UnixNano() / 1e6
+		}
+		millis[cl.nsTopic(topic)] = ps
+	}
+
+	setOffsets, err := cl.listOffsetsForTimes(ctx, millis)
+	if err != nil {
+		return err
+	}
+
+	// Any partition that has no record at or after the requested time is
+	// set to the end of the partition.
+	var ends map[string]map[int32]int64
+	for topic, partitions := range setOffsets {
+		for partition, eo := range partitions {
+			if eo.Offset >= 0 {
+				continue
+			}
+			if ends == nil {
+				ends = make(map[string]map[int32]int64)
+			}
+			if ends[topic] == nil {
+				ends[topic] = make(map[int32]int64)
+			}
+			ends[topic][partition] = -1 // latest
+		}
+	}
+	if len(ends) > 0 {
+		endOffsets, err := cl.listOffsetsForTimes(ctx, ends)
+		if err != nil {
+			return err
+		}
+		for topic, partitions := range endOffsets {
+			for partition, eo := range partitions {
+				setOffsets[topic][partition] = eo
+			}
+		}
+	}
+
+	cl.setOffsets(setOffsets, true)
+	return nil
+}
+
+// listOffsetsForTimes issues a ListOffsets request for the given millisecond
+// timestamps (or the special -1 and -2 timestamps), returning the first error
+// encountered.
+func (cl *Client) listOffsetsForTimes(ctx context.Context, millis map[string]map[int32]int64) (map[string]map[int32]EpochOffset, error) {
+	req := kmsg.NewPtrListOffsetsRequest()
+	req.IsolationLevel = cl.cfg.isolationLevel
+	for topic, partitions := range millis {
+		rt := kmsg.NewListOffsetsRequestTopic()
+		rt.Topic = topic
+		for partition, milli := range partitions {
+			rp := kmsg.NewListOffsetsRequestTopicPartition()
+			rp.Partition = partition
+			rp.Timestamp = milli
+			rt.Partitions = append(rt.Partitions, rp)
+		}
+		req.Topics = append(req.Topics, rt)
+	}
+
+	resp, err := req.RequestWith(ctx, cl)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make(map[string]map[int32]EpochOffset, len(resp.Topics))
+	for _, rt := range resp.Topics {
+		partitions := offsets[rt.Topic]
+		if partitions == nil {
+			partitions = make(map[int32]EpochOffset, len(rt.Partitions))
+			offsets[rt.Topic] = partitions
+		}
+		for _, rp := range rt.Partitions {
+			if err := kerr.ErrorForCode(rp.ErrorCode); err != nil {
+				return nil, fmt.Errorf("unable to list offsets for topic %s partition %d: %w", cl.unnsTopic(rt.Topic), rp.Partition, err)
+			}
+			partitions[rp.Partition] = EpochOffset{rp.LeaderEpoch, rp.Offset}
+		}
+	}
+	for topic, partitions := range millis {
+		for partition := range partitions {
+			if _, ok := offsets[topic][partition]; !ok {
+				return nil, fmt.Errorf("broker did not reply to list offsets for topic %s partition %d", cl.unnsTopic(topic), partition)
+			}
+		}
+	}
+	return offsets, nil
+}
+
 // This is guaranteed to be called in a blocking metadata fn, which ensures
 // that metadata does not load the tps we are changing. Basically, we ensure
 // everything w.r.t. consuming is at a stand still.
@@ -692,6 +1433,16 @@ func (c *consumer) purgeTopics(topics []string) {
 		purgeAssignments[topic] = nil
 	}
 
+	// If consuming via regex, we notify of purged topics that matched
+	// after all of our locks are released.
+	var removed []string
+	defer func() {
+		if fn := c.cl.cfg.onRegexRemoved; fn != nil && len(removed) > 0 {
+			sort.Strings(removed)
+			fn(c.cl, c.cl.unnsTopics(removed))
+		}
+	}()
+
 	c.waitAndAddRebalance()
 	defer c.unaddRebalance()
 
@@ -705,6 +1456,9 @@ func (c *consumer) purgeTopics(topics []string) {
 		defer c.g.mu.Unlock()
 		c.assignPartitions(purgeAssignments, assignPurgeMatching, c.g.tps, fmt.Sprintf("purge of %v requested", topics))
 		for _, topic := range topics {
+			if c.g.reSeen[topic] {
+				removed = append(removed, topic)
+			}
 			delete(c.g.using, topic)
 			delete(c.g.reSeen, topic)
 		}
@@ -712,6 +1466,9 @@ func (c *consumer) purgeTopics(topics []string) {
 	} else {
 		c.assignPartitions(purgeAssignments, assignPurgeMatching, c.d.tps, fmt.Sprintf("purge of %v requested", topics))
 		for _, topic := range topics {
+			if c.d.reSeen[topic] {
+				removed = append(removed, topic)
+			}
 			delete(c.d.using, topic)
 			delete(c.d.reSeen, topic)
 			delete(c.d.m, topic)
@@ -732,6 +1489,7 @@ func (cl *Client) AddConsumeTopics(topics ...string) {
 	if len(topics) == 0 || c.g == nil && c.d == nil || cl.cfg.regex {
 		return
 	}
+	topics = cl.nsTopics(topics)
 
 	// We can do this outside of the metadata loop because we are strictly
 	// adding new topics and forbid regex consuming.
@@ -890,6 +1648,8 @@ func (c *consumer) assignPartitions(assignments map[string]map[int32]Offset, how
 			}
 			if shouldKeep {
 				keep.use(usedCursor)
+			} else {
+				c.delLag(usedCursor.topic, usedCursor.partition)
 			}
 		}
 		c.usingCursors = keep
@@ -1042,23 +1802,36 @@ func (c *consumer) doOnMetadataUpdate() {
 	// block below.
 	if c.outstandingMetadataUpdates.maybeBegin() {
 		doUpdate := func() {
-			// We forbid reassignments while we do a quick check for
-			// new assignments--for the direct consumer particularly,
-			// this prevents TOCTOU, and guards against a concurrent
-			// assignment from SetOffsets.
-			c.mu.Lock()
-			defer c.mu.Unlock()
-
-			switch {
-			case c.d != nil:
-				if new := c.d.findNewAssignments(); len(new) > 0 {
-					c.assignPartitions(new, assignWithoutInvalidating, c.d.tps, "new assignments from direct consumer")
+			var rns reNews
+			func() {
+				// We forbid reassignments while we do a quick
+				// check for new assignments--for the direct
+				// consumer particularly, this prevents TOCTOU,
+				// and guards against a concurrent assignment
+				// from SetOffsets.
+				c.mu.Lock()
+				defer c.mu.Unlock()
+
+				switch {
+				case c.d != nil:
+					if new := c.d.findNewAssignments(&rns); len(new) > 0 {
+						c.assignPartitions(new, assignWithoutInvalidating, c.d.tps, "new assignments from direct consumer")
+					}
+				case c.g != nil:
+					c.g.findNewAssignments(&rns)
 				}
-			case c.g != nil:
-				c.g.findNewAssignments()
-			}
 
-			go c.loadSession().doOnMetadataUpdate()
+				go c.loadSession().doOnMetadataUpdate()
+			}()
+
+			// We notify of new regex matches outside of the lock
+			// so that the user's function can use the client.
+			rns.log(&c.cl.cfg)
+			if fn := c.cl.cfg.onRegexAdded; fn != nil {
+				if matched := rns.matched(); len(matched) > 0 {
+					fn(c.cl, c.cl.unnsTopics(matched))
+				}
+			}
 		}
 
 		go func() {
@@ -1687,6 +2460,7 @@ func (s *consumerSession) handleListOrEpochResults(loaded loadedOffsets) (reload
 		var edl *ErrDataLoss
 		switch {
 		case errors.As(load.err, &edl):
+			s.c.cl.onLogTruncation(edl)
 			s.c.addFakeReadyForDraining(load.topic, load.partition, load.err, "notification of data loss") // signal we lost data, but set the cursor to what we can
 			use()
 
@@ -54,15 +54,11 @@ func (*directConsumer) getSetAssigns(setOffsets map[string]map[int32]EpochOffset
 }
 
 // findNewAssignments returns new partitions to consume at given offsets
-// based off the current topics.
-func (d *directConsumer) findNewAssignments() map[string]map[int32]Offset {
+// based off the current topics. If consuming via regex, newly evaluated topics
+// are added to rns.
+func (d *directConsumer) findNewAssignments(rns *reNews) map[string]map[int32]Offset {
 	topics := d.tps.load()
 
-	var rns reNews
-	if d.cfg.regex {
-		defer rns.log(d.cfg)
-	}
-
 	toUse := make(map[string]map[int32]Offset, 10)
 	for topic, topicPartitions := range topics {
 		var useTopic bool
@@ -147,6 +147,12 @@ type groupConsumer struct {
 	// autocommit does not cancel the user's manual commit.
 	blockAuto bool
 
+	// pendingCommit is an async commit held to coalesce with later async
+	// commits if CommitCoalesceInterval is set, and lastCommit is when we
+	// last issued a commit request.
+	pendingCommit *pendingCommit
+	lastCommit    time.Time
+
 	// We set this once to manage the group lifecycle once.
 	managing bool
 
@@ -996,6 +1002,7 @@ func (g *groupConsumer) rejoin(why string) {
 // Joins and then syncs, issuing the two slow requests in goroutines to allow
 // for group cancelation to return early.
 func (g *groupConsumer) joinAndSync(joinWhy string) error {
+	g.flushPendingCommit()
 	g.noCommitDuringJoinAndSync.Lock()
 	g.cfg.logger.Log(LogLevelDebug, "blocking commits from join&sync")
 	defer g.noCommitDuringJoinAndSync.Unlock()
@@ -1509,6 +1516,7 @@ start:
 	kip320 := g.cl.supportsOffsetForLeaderEpoch()
 
 	offsets := make(map[string]map[int32]Offset)
+	metas := make(map[string]map[int32]string)
 	for _, rTopic := range resp.Topics {
 		topicOffsets := make(map[int32]Offset)
 		offsets[rTopic.Topic] = topicOffsets
@@ -1548,6 +1556,14 @@ start:
 				offset = g.cfg.resetOffset
 			}
 			topicOffsets[rPartition.Partition] = offset
+			if rPartition.Metadata != nil {
+				topicMetas := metas[rTopic.Topic]
+				if topicMetas == nil {
+					topicMetas = make(map[int32]string)
+					metas[rTopic.Topic] = topicMetas
+				}
+				topicMetas[rPartition.Partition] = *rPartition.Metadata
+			}
 		}
 	}
 
@@ -1606,9 +1622,10 @@ start:
 				Offset: offset.at,
 			}
 			topicUncommitted[partition] = uncommit{
-				dirty:     committed,
-				head:      committed,
-				committed: committed,
+				dirty:         committed,
+				head:          committed,
+				committed:     committed,
+				committedMeta: metas[topic][partition],
 			}
 		}
 	}
@@ -1627,7 +1644,7 @@ start:
 //
 // This does not rejoin if the leader notices a partition is lost, which is
 // finicky.
-func (g *groupConsumer) findNewAssignments() {
+func (g *groupConsumer) findNewAssignments(rns *reNews) {
 	topics := g.tps.load()
 
 	type change struct {
@@ -1635,11 +1652,6 @@ func (g *groupConsumer) findNewAssignments() {
 		delta int
 	}
 
-	var rns reNews
-	if g.cfg.regex {
-		defer rns.log(&g.cl.cfg)
-	}
-
 	var numNewTopics int
 	toChange := make(map[string]change, len(topics))
 	for topic, topicPartitions := range topics {
@@ -1732,6 +1744,8 @@ type uncommit struct {
 	dirty     EpochOffset // if autocommitting, what will move to head on next Poll
 	head      EpochOffset // ready to commit
 	committed EpochOffset // what is committed
+
+	committedMeta string // the metadata stored alongside the committed offset
 }
 
 // EpochOffset combines a record offset with the leader epoch the broker
@@ -1779,10 +1793,25 @@ func (g *groupConsumer) updateUncommitted(fetches Fetches) {
 			}
 			var topicOffsets map[int32]uncommit
 			for _, partition := range topic.Partitions {
-				if len(partition.Records) == 0 {
+				// Our new head points just past the final consumed offset,
+				// that is, if we rejoin, this is the offset to begin at.
+				var set EpochOffset
+				switch {
+				case len(partition.Records) > 0:
+					final := partition.Records[len(partition.Records)-1]
+					set = EpochOffset{
+						final.LeaderEpoch, // -1 if old message / unknown
+						final.Offset + 1,
+					}
+				case len(partition.RawBatches) > 0:
+					final := partition.RawBatches[len(partition.RawBatches)-1]
+					set = EpochOffset{
+						final.PartitionLeaderEpoch,
+						final.LastOffset + 1,
+					}
+				default:
 					continue
 				}
-				final := partition.Records[len(partition.Records)-1]
 
 				if topicOffsets == nil {
 					if g.uncommitted == nil {
@@ -1795,12 +1824,6 @@ func (g *groupConsumer) updateUncommitted(fetches Fetches) {
 					}
 				}
 
-				// Our new head points just past the final consumed offset,
-				// that is, if we rejoin, this is the offset to begin at.
-				set := EpochOffset{
-					final.LeaderEpoch, // -1 if old message / unknown
-					final.Offset + 1,
-				}
 				prior := topicOffsets[partition.Partition]
 
 				if debug {
@@ -1956,6 +1979,11 @@ func (g *groupConsumer) updateCommitted(
 				reqPart.Offset,
 			}
 			uncommit.committed = set
+			if reqPart.Metadata != nil {
+				uncommit.committedMeta = *reqPart.Metadata
+			} else {
+				uncommit.committedMeta = ""
+			}
 
 			// head is set in four places:
 			//  (1) if manually committing or greedily autocommitting,
@@ -2059,7 +2087,7 @@ func (g *groupConsumer) loopCommit() {
 		g.mu.Lock()
 		if !g.blockAuto {
 			g.cfg.logger.Log(LogLevelDebug, "autocommitting", "group", g.cfg.group)
-			g.commit(g.ctx, g.getUncommittedLocked(true, false), func(cl *Client, req *kmsg.OffsetCommitRequest, resp *kmsg.OffsetCommitResponse, err error) {
+			g.commitAsync(g.ctx, g.getUncommittedLocked(true, false), func(cl *Client, req *kmsg.OffsetCommitRequest, resp *kmsg.OffsetCommitResponse, err error) {
 				g.noCommitDuringJoinAndSync.RUnlock()
 				g.cfg.commitCallback(cl, req, resp, err)
 			})
@@ -2137,6 +2165,10 @@ func (g *groupConsumer) getSetAssigns(setOffsets map[string]map[int32]EpochOffse
 // If using a cooperative balancer, commits while consuming during rebalancing
 // may fail with REBALANCE_IN_PROGRESS.
 func (cl *Client) UncommittedOffsets() map[string]map[int32]EpochOffset {
+	return cl.unnsOffsets(cl.uncommittedOffsets())
+}
+
+func (cl *Client) uncommittedOffsets() map[string]map[int32]EpochOffset {
 	if g := cl.consumer.g; g != nil {
 		return g.getUncommitted(true)
 	}
@@ -2163,7 +2195,7 @@ func (cl *Client) UncommittedOffsets() map[string]map[int32]EpochOffset {
 // may fail with REBALANCE_IN_PROGRESS.
 func (cl *Client) MarkedOffsets() map[string]map[int32]EpochOffset {
 	if g := cl.consumer.g; g != nil {
-		return g.getUncommitted(false)
+		return cl.unnsOffsets(g.getUncommitted(false))
 	}
 	return nil
 }
@@ -2173,6 +2205,10 @@ func (cl *Client) MarkedOffsets() map[string]map[int32]EpochOffset {
 //
 // If there are no committed offsets, this returns nil.
 func (cl *Client) CommittedOffsets() map[string]map[int32]EpochOffset {
+	return cl.unnsOffsets(cl.committedOffsets())
+}
+
+func (cl *Client) committedOffsets() map[string]map[int32]EpochOffset {
 	g := cl.consumer.g
 	if g == nil {
 		return nil
@@ -2183,6 +2219,39 @@ func (cl *Client) CommittedOffsets() map[string]map[int32]EpochOffset {
 	return g.getUncommittedLocked(false, false)
 }
 
+// CommittedOffsetsMetadata returns the metadata stored alongside the latest
+// committed offsets. Like CommittedOffsets, this is updated from commits or
+// from joining a group and fetching offsets. By default, the client commits
+// its member ID as the metadata; see CommitRecordsWithMeta to commit custom
+// metadata.
+//
+// If there are no committed offsets, this returns nil.
+func (cl *Client) CommittedOffsetsMetadata() map[string]map[int32]string {
+	g := cl.consumer.g
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var metas map[string]map[int32]string
+	for topic, partitions := range g.uncommitted {
+		for partition, uncommit := range partitions {
+			if metas == nil {
+				metas = make(map[string]map[int32]string, len(g.uncommitted))
+			}
+			topic := cl.unnsTopic(topic)
+			topicMetas := metas[topic]
+			if topicMetas == nil {
+				topicMetas = make(map[int32]string, len(partitions))
+				metas[topic] = topicMetas
+			}
+			topicMetas[partition] = uncommit.committedMeta
+		}
+	}
+	return metas
+}
+
 func (g *groupConsumer) getUncommitted(dirty bool) map[string]map[int32]EpochOffset {
 	g.mu.Lock()
 	defer g.mu.Unlock()
@@ -2238,6 +2307,33 @@ func PreCommitFnContext(ctx context.Context, fn func(*kmsg.OffsetCommitRequest)
 	return context.WithValue(ctx, commitContextFn, fn)
 }
 
+// CommitMetadataContext returns a context that, when used while committing,
+// sets the metadata of every committed partition to meta. The metadata is
+// stored alongside the committed offsets and can be used to save small bits of
+// state, such as a processing watermark or the host that committed. Any
+// PreCommitFnContext function already in ctx is called before the metadata is
+// set.
+//
+// The metadata can be read back with CommittedOffsetsMetadata, or through the
+// admin OffsetFetch request.
+func CommitMetadataContext(ctx context.Context, meta string) context.Context {
+	prior, _ := ctx.Value(commitContextFn).(func(*kmsg.OffsetCommitRequest) error)
+	return PreCommitFnContext(ctx, func(req *kmsg.OffsetCommitRequest) error {
+		if prior != nil {
+			if err := prior(req); err != nil {
+				return err
+			}
+		}
+		for i := range req.Topics {
+			ps := req.Topics[i].Partitions
+			for j := range ps {
+				ps[j].Metadata = &meta
+			}
+		}
+		return nil
+	})
+}
+
 // CommitRecords issues a synchronous offset commit for the offsets contained
 // within rs. Retryable errors are retried up to the configured retry limit,
 // and any unretryable error is returned.
@@ -2268,6 +2364,17 @@ func PreCommitFnContext(ctx context.Context, fn func(*kmsg.OffsetCommitRequest)
 // If you do not want to wait for this function to complete before continuing
 // processing records, you can call this function in a goroutine.
 func (cl *Client) CommitRecords(ctx context.Context, rs ...*Record) error {
+	return cl.commitRecords(ctx, rs)
+}
+
+// CommitRecordsWithMeta is CommitRecords, but also stores meta alongside every
+// committed offset. This is shorthand for using CommitMetadataContext with
+// CommitRecords.
+func (cl *Client) CommitRecordsWithMeta(ctx context.Context, meta string, rs ...*Record) error {
+	return cl.commitRecords(CommitMetadataContext(ctx, meta), rs)
+}
+
+func (cl *Client) commitRecords(ctx context.Context, rs []*Record) error {
 	// First build the offset commit map. We favor the latest epoch, then
 	// offset, if any records map to the same topic / partition.
 	offsets := make(map[string]map[int32]EpochOffset)
@@ -2339,10 +2446,11 @@ func (cl *Client) MarkCommitRecords(rs ...*Record) {
 	var curPartitions map[int32]uncommit
 	for _, r := range rs {
 		if curPartitions == nil || r.Topic != curTopic {
-			curPartitions = g.uncommitted[r.Topic]
+			topic := cl.nsTopic(r.Topic)
+			curPartitions = g.uncommitted[topic]
 			if curPartitions == nil {
 				curPartitions = make(map[int32]uncommit)
-				g.uncommitted[r.Topic] = curPartitions
+				g.uncommitted[topic] = curPartitions
 			}
 			curTopic = r.Topic
 		}
@@ -2353,9 +2461,10 @@ func (cl *Client) MarkCommitRecords(rs ...*Record) {
 			r.Offset + 1,
 		}); current.head.Less(newHead) {
 			curPartitions[r.Partition] = uncommit{
-				dirty:     current.dirty,
-				committed: current.committed,
-				head:      newHead,
+				dirty:         current.dirty,
+				committed:     current.committed,
+				head:          newHead,
+				committedMeta: current.committedMeta,
 			}
 		}
 	}
@@ -2447,7 +2556,7 @@ func (cl *Client) CommitOffsetsSync(
 		onDone(cl, kmsg.NewPtrOffsetCommitRequest(), kmsg.NewPtrOffsetCommitResponse(), nil)
 		return
 	}
-	g.commitOffsetsSync(ctx, uncommitted, onDone)
+	g.commitOffsetsSync(ctx, cl.nsOffsets(uncommitted), onDone)
 }
 
 // waitJoinSyncMu is a rather insane way to try to grab a lock, but also return
@@ -2612,7 +2721,7 @@ func (cl *Client) CommitOffsets(
 		g.blockAuto = false
 	}
 
-	g.commit(ctx, uncommitted, unblockAuto)
+	g.commitAsync(ctx, cl.nsOffsets(uncommitted), unblockAuto)
 }
 
 // defaultRevoke commits the last fetched offsets and waits for the commit to
@@ -2653,6 +2762,18 @@ func (g *groupConsumer) commit(
 	uncommitted map[string]map[int32]EpochOffset,
 	onDone func(*Client, *kmsg.OffsetCommitRequest, *kmsg.OffsetCommitResponse, error),
 ) {
+	// If an async commit is being held for coalescing, we absorb it:
+	// our offsets take precedence, and we issue everything now.
+	if p := g.takePendingCommit(); p != nil {
+		p.merge(uncommitted)
+		uncommitted = p.uncommitted
+		ourDone := onDone
+		onDone = func(cl *Client, req *kmsg.OffsetCommitRequest, resp *kmsg.OffsetCommitResponse, err error) {
+			p.onDone(cl, req, resp, err)
+			ourDone(cl, req, resp, err)
+		}
+	}
+
 	// The user could theoretically give us topics that have no partitions
 	// to commit. We strip those: Kafka does not reply to them, and we
 	// expect all partitions in our request to be replied to in
@@ -2687,6 +2808,7 @@ func (g *groupConsumer) commit(
 		return
 	}
 
+	g.lastCommit = time.Now()
 	priorCancel := g.commitCancel
 	priorDone := g.commitDone
 
@@ -2747,16 +2869,206 @@ func (g *groupConsumer) commit(
 			}
 		}
 
-		resp, err := req.RequestWith(commitCtx, g.cl)
+		resp, err := g.issueCommit(commitCtx, req)
 		if err != nil {
 			onDone(g.cl, req, nil, err)
 			return
 		}
-		g.updateCommitted(req, resp)
 		onDone(g.cl, req, resp, nil)
 	}()
 }
 
+// issueCommit issues req, splitting it into multiple sequential requests if
+// CommitMaxPartitions is set and req has more partitions than allowed. Each
+// request's offsets are marked committed as its response is received.
+func (g *groupConsumer) issueCommit(ctx context.Context, req *kmsg.OffsetCommitRequest) (*kmsg.OffsetCommitResponse, error) {
+	var n int
+	for _, t := range req.Topics {
+		n += len(t.Partitions)
+	}
+	maxParts := g.cfg.commitMaxPartitions
+	if maxParts <= 0 || n <= maxParts {
+		resp, err := req.RequestWith(ctx, g.cl)
+		if err != nil {
+			return nil, err
+		}
+		g.updateCommitted(req, resp)
+		return resp, nil
+	}
+
+	splits := splitCommitRequest(req, maxParts)
+	g.cfg.logger.Log(LogLevelDebug, "splitting commit", "group", g.cfg.group, "partitions", n, "requests", len(splits))
+
+	merged := kmsg.NewPtrOffsetCommitResponse()
+	topicIdx := make(map[string]int)
+	for _, split := range splits {
+		resp, err := split.RequestWith(ctx, g.cl)
+		if err != nil {
+			return nil, err
+		}
+		g.updateCommitted(split, resp)
+
+		merged.Version = resp.Version
+		if resp.ThrottleMillis > merged.ThrottleMillis {
+			merged.ThrottleMillis = resp.ThrottleMillis
+		}
+		for _, t := range resp.Topics {
+			if i, exists := topicIdx[t.Topic]; exists {
+				merged.Topics[i].Partitions = append(merged.Topics[i].Partitions, t.Partitions...)
+				continue
+			}
+			topicIdx[t.Topic] = len(merged.Topics)
+			merged.Topics = append(merged.Topics, t)
+		}
+	}
+	return merged, nil
+}
+
+// splitCommitRequest splits req into requests of at most maxParts partitions.
+// Topics that do not fit in one request are split across requests.
+func splitCommitRequest(req *kmsg.OffsetCommitRequest, maxParts int) []*kmsg.OffsetCommitRequest {
+	var (
+		splits []*kmsg.OffsetCommitRequest
+		split  *kmsg.OffsetCommitRequest
+		n      int
+	)
+	for _, t := range req.Topics {
+		ps := t.Partitions
+		for len(ps) > 0 {
+			if split == nil || n == maxParts {
+				dup := *req
+				dup.Topics = nil
+				split = &dup
+				splits = append(splits, split)
+				n = 0
+			}
+			take := maxParts - n
+			if take > len(ps) {
+				take = len(ps)
+			}
+			st := t
+			st.Partitions = ps[:take:take]
+			split.Topics = append(split.Topics, st)
+			ps = ps[take:]
+			n += take
+		}
+	}
+	return splits
+}
+
+// pendingCommit is an async commit held by CommitCoalesceInterval.
+type pendingCommit struct {
+	ctx         context.Context // the context of the latest merged commit
+	uncommitted map[string]map[int32]EpochOffset
+	onDones     []func(*Client, *kmsg.OffsetCommitRequest, *kmsg.OffsetCommitResponse, error)
+	taken       chan struct{} // closed once the commit is issued or absorbed
+}
+
+// merge merges uncommitted into the pending commit, with offsets in
+// uncommitted replacing any pending offsets for the same partition.
+func (p *pendingCommit) merge(uncommitted map[string]map[int32]EpochOffset) {
+	for t, ps := range uncommitted {
+		pps := p.uncommitted[t]
+		if pps == nil {
+			pps = make(map[int32]EpochOffset, len(ps))
+			p.uncommitted[t] = pps
+		}
+		for partition, eo := range ps {
+			pps[partition] = eo
+		}
+	}
+}
+
+func (p *pendingCommit) onDone(cl *Client, req *kmsg.OffsetCommitRequest, resp *kmsg.OffsetCommitResponse, err error) {
+	for _, onDone := range p.onDones {
+		onDone(cl, req, resp, err)
+	}
+}
+
+// commitAsync is commit for asynchronous commits: if CommitCoalesceInterval
+// is set and we committed too recently, the commit is held and merged with any
+// further async commits until the interval elapses. This is called under the
+// same locks as commit, and each merged commit's noCommitDuringJoinAndSync
+// read lock is held until its onDone.
+func (g *groupConsumer) commitAsync(
+	ctx context.Context,
+	uncommitted map[string]map[int32]EpochOffset,
+	onDone func(*Client, *kmsg.OffsetCommitRequest, *kmsg.OffsetCommitResponse, error),
+) {
+	interval := g.cfg.commitCoalesceInterval
+	if interval <= 0 {
+		g.commit(ctx, uncommitted, onDone)
+		return
+	}
+	if p := g.pendingCommit; p != nil {
+		p.ctx = ctx
+		p.merge(uncommitted)
+		p.onDones = append(p.onDones, onDone)
+		return
+	}
+	wait := time.Until(g.lastCommit.Add(interval))
+	if wait <= 0 {
+		g.commit(ctx, uncommitted, onDone)
+		return
+	}
+
+	p := &pendingCommit{
+		ctx:         ctx,
+		uncommitted: make(map[string]map[int32]EpochOffset, len(uncommitted)),
+		onDones:     []func(*Client, *kmsg.OffsetCommitRequest, *kmsg.OffsetCommitResponse, error){onDone},
+		taken:       make(chan struct{}),
+	}
+	p.merge(uncommitted)
+	g.pendingCommit = p
+	g.cfg.logger.Log(LogLevelDebug, "holding commit to coalesce", "group", g.cfg.group, "wait", wait)
+
+	go func() {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-p.taken:
+			return
+		}
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.pendingCommit == p {
+			g.issuePendingCommitLocked()
+		}
+	}()
+}
+
+// takePendingCommit removes and returns any held commit. This must be called
+// with mu held.
+func (g *groupConsumer) takePendingCommit() *pendingCommit {
+	p := g.pendingCommit
+	if p != nil {
+		g.pendingCommit = nil
+		close(p.taken)
+	}
+	return p
+}
+
+// issuePendingCommitLocked issues any held commit immediately. This must be
+// called with mu held.
+func (g *groupConsumer) issuePendingCommitLocked() {
+	if p := g.takePendingCommit(); p != nil {
+		g.cfg.logger.Log(LogLevelDebug, "issuing coalesced commit", "group", g.cfg.group, "coalesced", len(p.onDones))
+		g.commit(p.ctx, p.uncommitted, p.onDone)
+	}
+}
+
+// flushPendingCommit issues any held commit immediately; this is called when
+// a rebalance begins so that a held commit does not delay joining.
+func (g *groupConsumer) flushPendingCommit() {
+	if g.cfg.commitNoRebalanceFlush {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.issuePendingCommitLocked()
+}
+
 type reNews struct {
 	added   map[string][]string
 	skipped []string
@@ -2773,6 +3085,16 @@ func (r *reNews) skip(topic string) {
 	r.skipped = append(r.skipped, topic)
 }
 
+// matched returns all topics that newly matched a regular expression.
+func (r *reNews) matched() []string {
+	var matched []string
+	for _, matches := range r.added {
+		matched = append(matched, matches...)
+	}
+	sort.Strings(matched)
+	return matched
+}
+
 func (r *reNews) log(cfg *cfg) {
 	if len(r.added) == 0 && len(r.skipped) == 0 {
 		return
@@ -0,0 +1,1146 @@
+package kgo
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"math"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+type fetchInterceptHook func(*Record) bool
+
+func (h fetchInterceptHook) OnFetchRecordIntercept(r *Record) bool { return h(r) }
+
+func TestFetchRecordIntercept(t *testing.T) {
+	cl, err := NewClient(WithHooks(
+		fetchInterceptHook(func(r *Record) bool {
+			r.Value = append(r.Value, '!')
+			return r.Offset%2 == 0
+		}),
+		fetchInterceptHook(func(r *Record) bool {
+			if r.Value[len(r.Value)-1] != '!' {
+				t.Errorf("second hook did not see first hook's modification")
+			}
+			return r.Offset != 2
+		}),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	var rs []*Record
+	for i := int64(0); i < 5; i++ {
+		rs = append(rs, &Record{Topic: "foo", Offset: i, Value: []byte("v")})
+	}
+	fs := Fetches{{Topics: []FetchTopic{{
+		Topic:      "foo",
+		Partitions: []FetchPartition{{Records: rs}},
+	}}}}
+	cl.consumer.intercept(fs)
+
+	got := fs.Records()
+	if len(got) != 2 || got[0].Offset != 0 || got[1].Offset != 4 {
+		t.Fatalf("got %d records, exp offsets 0 and 4", len(got))
+	}
+	if string(got[0].Value) != "v!" {
+		t.Errorf("got value %q, exp %q", got[0].Value, "v!")
+	}
+}
+
+// encodeTestBatch encodes a record batch with n records starting at first,
+// with a valid length and CRC.
+func encodeTestBatch(first int64, n int, pid int64) []byte {
+	return encodeTestBatchCompressed(first, n, pid, nil)
+}
+
+// encodeTestBatchCompressed is encodeTestBatch, compressing the records with
+// c if c is non-nil.
+func encodeTestBatchCompressed(first int64, n int, pid int64, c *compressor) []byte {
+	rb := kmsg.RecordBatch{
+		FirstOffset:     first,
+		Magic:           2,
+		LastOffsetDelta: int32(n - 1),
+		FirstTimestamp:  1000,
+		MaxTimestamp:    1000 + int64(n-1),
+		ProducerID:      pid,
+		ProducerEpoch:   1,
+		FirstSequence:   int32(first),
+		NumRecords:      int32(n),
+	}
+	for i := 0; i < n; i++ {
+		r := kmsg.Record{
+			OffsetDelta:      int32(i),
+			TimestampDelta64: int64(i),
+			Value:            []byte("v"),
+		}
+		r.Length = int32(len(r.AppendTo(nil)) - 1) // less the 1 byte zero length varint
+		rb.Records = r.AppendTo(rb.Records)
+	}
+	if c != nil {
+		w := sliceWriters.Get().(*sliceWriter)
+		compressed, codec := c.compress(w, rb.Records, 7)
+		rb.Records = append([]byte(nil), compressed...)
+		rb.Attributes = int16(codec)
+		sliceWriters.Put(w)
+	}
+	raw := rb.AppendTo(nil)
+	binary.BigEndian.PutUint32(raw[8:], uint32(len(raw)-12))
+	binary.BigEndian.PutUint32(raw[17:], crc32.Checksum(raw[21:], crc32c))
+	return raw
+}
+
+func TestFetchPartitionBatches(t *testing.T) {
+	var in []byte
+	in = append(in, encodeTestBatch(0, 3, 7)...)
+	in = append(in, encodeTestBatch(3, 2, 8)...)
+
+	o := cursorOffsetNext{
+		cursorOffset: cursorOffset{offset: 1}, // start mid batch
+		from:         &cursor{topic: "t"},
+	}
+	fp := o.processRespPartition(nil, &kmsg.FetchResponseTopicPartition{RecordBatches: in}, newDecompressor([5]Decompressor{}), nil)
+	if fp.Err != nil {
+		t.Fatalf("unexpected err: %v", fp.Err)
+	}
+	if len(fp.Records) != 4 {
+		t.Fatalf("got %d records, exp 4", len(fp.Records))
+	}
+
+	batches := fp.Batches()
+	if len(batches) != 2 {
+		t.Fatalf("got %d batches, exp 2", len(batches))
+	}
+	for i, exp := range []struct {
+		first, last, pid int64
+		offsets          []int64
+	}{
+		{0, 2, 7, []int64{1, 2}},
+		{3, 4, 8, []int64{3, 4}},
+	} {
+		b := batches[i]
+		if b.FirstOffset != exp.first || b.LastOffset != exp.last || b.ProducerID != exp.pid {
+			t.Errorf("batch %d: got first %d last %d pid %d, exp %d %d %d", i, b.FirstOffset, b.LastOffset, b.ProducerID, exp.first, exp.last, exp.pid)
+		}
+		if len(b.Records) != len(exp.offsets) {
+			t.Errorf("batch %d: got %d records, exp %d", i, len(b.Records), len(exp.offsets))
+			continue
+		}
+		for j, r := range b.Records {
+			if r.Offset != exp.offsets[j] {
+				t.Errorf("batch %d record %d: got offset %d, exp %d", i, j, r.Offset, exp.offsets[j])
+			}
+		}
+	}
+
+	// Filtering a whole batch's records drops the batch.
+	fp.Records = fp.Records[2:]
+	if batches := fp.Batches(); len(batches) != 1 || batches[0].ProducerID != 8 {
+		t.Errorf("got %d batches after filtering, exp only the second batch", len(batches))
+	}
+}
+
+func TestPoolFetchBuffers(t *testing.T) {
+	c, err := newCompressor(CompressionCodec{codec: codecZstd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := encodeTestBatchCompressed(0, 3, 7, c)
+	in = append(in, encodeTestBatch(3, 1, 7)...)
+
+	o := cursorOffsetNext{from: &cursor{topic: "t", poolBuffers: true}}
+	fp := o.processRespPartition(nil, &kmsg.FetchResponseTopicPartition{RecordBatches: in}, newDecompressor([5]Decompressor{}), nil)
+	if fp.Err != nil {
+		t.Fatalf("unexpected err: %v", fp.Err)
+	}
+	if len(fp.Records) != 4 {
+		t.Fatalf("got %d records, exp 4", len(fp.Records))
+	}
+
+	if fp.Records[0].slab == nil {
+		t.Fatal("compressed batch record does not have a pooled buffer")
+	}
+	slab := fp.Records[0].slab.slab
+	if fp.Records[1].slab == nil || fp.Records[1].slab.slab != slab || fp.Records[2].slab == nil || fp.Records[2].slab.slab != slab {
+		t.Fatal("compressed batch records do not share a pooled buffer")
+	}
+	if fp.Records[3].slab != nil {
+		t.Error("uncompressed batch record unexpectedly has a pooled buffer")
+	}
+	for _, r := range fp.Records {
+		if string(r.Value) != "v" {
+			t.Errorf("offset %d: got value %q, exp %q", r.Offset, r.Value, "v")
+		}
+	}
+
+	fs := Fetches{{Topics: []FetchTopic{{Topic: "t", Partitions: []FetchPartition{fp}}}}}
+	fp.Records[0].Recycle()
+	fp.Records[0].Recycle() // no-op
+	if refs := slab.refs.Load(); refs != 2 {
+		t.Errorf("got %d refs after recycling one record, exp 2", refs)
+	}
+
+	// Recycling a copy of a record releases the record's hold once, no
+	// matter how many copies are recycled.
+	cp, cp2 := *fp.Records[2], *fp.Records[2]
+	cp.Recycle()
+	cp2.Recycle()
+	if refs := slab.refs.Load(); refs != 1 {
+		t.Errorf("got %d refs after recycling copies of one record, exp 1", refs)
+	}
+	fs.Recycle()
+	if refs := slab.refs.Load(); refs != 0 {
+		t.Errorf("got %d refs after recycling all records, exp 0", refs)
+	}
+	if fp.Records[1].Value != nil || fp.Records[1].slab != nil {
+		t.Error("recycled record was not cleared")
+	}
+	if string(fp.Records[3].Value) != "v" {
+		t.Error("recycling a record from an uncompressed batch cleared it")
+	}
+}
+
+func TestConsumeRawBatches(t *testing.T) {
+	c, err := newCompressor(CompressionCodec{codec: codecZstd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := encodeTestBatchCompressed(0, 3, 7, c)
+	second := encodeTestBatch(3, 2, 8)
+	in := append(append([]byte(nil), first...), second...)
+
+	o := cursorOffsetNext{
+		cursorOffset: cursorOffset{offset: 1}, // start mid batch
+		from:         &cursor{topic: "t", rawBatches: true},
+	}
+	fp := o.processRespPartition(nil, &kmsg.FetchResponseTopicPartition{RecordBatches: in}, newDecompressor([5]Decompressor{}), nil)
+	if fp.Err != nil {
+		t.Fatalf("unexpected err: %v", fp.Err)
+	}
+	if len(fp.Records) != 0 {
+		t.Errorf("got %d decoded records, exp 0", len(fp.Records))
+	}
+	if o.offset != 5 {
+		t.Errorf("got next offset %d, exp 5", o.offset)
+	}
+
+	batches := fp.Batches()
+	if len(batches) != 2 {
+		t.Fatalf("got %d batches, exp 2", len(batches))
+	}
+	for i, exp := range []struct {
+		first, last, pid int64
+		raw              []byte
+	}{
+		{0, 2, 7, first},
+		{3, 4, 8, second},
+	} {
+		b := batches[i]
+		if b.FirstOffset != exp.first || b.LastOffset != exp.last || b.ProducerID != exp.pid {
+			t.Errorf("batch %d: got first %d last %d pid %d, exp %d %d %d", i, b.FirstOffset, b.LastOffset, b.ProducerID, exp.first, exp.last, exp.pid)
+		}
+		if !bytes.Equal(b.Raw, exp.raw) {
+			t.Errorf("batch %d: raw batch does not match the fetched batch", i)
+		}
+	}
+
+	f := Fetch{Topics: []FetchTopic{{Topic: "t", Partitions: []FetchPartition{fp}}}}
+	if !f.hasErrorsOrRecords() || (Fetches{f}).Empty() {
+		t.Error("fetch with only raw batches is considered empty")
+	}
+
+	// A batch entirely before our offset is skipped.
+	o = cursorOffsetNext{
+		cursorOffset: cursorOffset{offset: 3},
+		from:         &cursor{topic: "t", rawBatches: true},
+	}
+	fp = o.processRespPartition(nil, &kmsg.FetchResponseTopicPartition{RecordBatches: in}, newDecompressor([5]Decompressor{}), nil)
+	if len(fp.RawBatches) != 1 || fp.RawBatches[0].FirstOffset != 3 {
+		t.Errorf("got %d raw batches, exp only the second batch", len(fp.RawBatches))
+	}
+}
+
+func TestFetchDecodeConcurrency(t *testing.T) {
+	cl := &Client{decompressor: newDecompressor([5]Decompressor{})}
+	cl.consumer.decodeSem = make(chan struct{}, 2)
+	s := &source{cl: cl}
+
+	var kept []keptRespPartition
+	for p := int32(0); p < 6; p++ {
+		rp := kmsg.NewFetchResponseTopicPartition()
+		rp.Partition = p
+		rp.RecordBatches = encodeTestBatch(0, int(p)+1, 7)
+		kept = append(kept, keptRespPartition{
+			topic: "t",
+			rp:    &rp,
+			o:     &cursorOffsetNext{from: &cursor{topic: "t", partition: p}},
+		})
+	}
+
+	s.decodeRespPartitions(nil, kept)
+	for _, k := range kept {
+		if k.fp.Err != nil || len(k.fp.Records) != int(k.rp.Partition)+1 {
+			t.Errorf("partition %d: got %d records (err %v), exp %d", k.rp.Partition, len(k.fp.Records), k.fp.Err, k.rp.Partition+1)
+		}
+	}
+	if len(cl.consumer.decodeSem) != 0 {
+		t.Error("decode semaphore was not released")
+	}
+}
+
+type fetchBatchReadHook func(int32)
+
+func (h fetchBatchReadHook) OnFetchBatchRead(_ BrokerMetadata, _ string, p int32, _ FetchBatchMetrics) {
+	h(p)
+}
+
+// TestFetchMovedPartitionsNotDecoded checks that partitions returning to the
+// leader are not decoded: their records are discarded and fetched again from
+// the leader, so decoding them is wasted work and fires hooks for batches the
+// user never sees.
+func TestFetchMovedPartitionsNotDecoded(t *testing.T) {
+	t.Parallel()
+
+	for _, concurrency := range []int{0, 2} {
+		var (
+			mu   sync.Mutex
+			read []int32
+		)
+		opts := []Opt{
+			PreferredReplicaMaxAge(time.Minute),
+			WithHooks(fetchBatchReadHook(func(p int32) {
+				mu.Lock()
+				defer mu.Unlock()
+				read = append(read, p)
+			})),
+		}
+		if concurrency > 0 {
+			opts = append(opts, FetchDecodeConcurrency(concurrency))
+		}
+		cl, err := NewClient(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cl.Close()
+
+		s := cl.newSource(2)
+		req := &fetchRequest{usedOffsets: usedOffsets{"t": make(map[int32]*cursorOffsetNext)}}
+		resp := &kmsg.FetchResponse{Version: 11}
+		rt := kmsg.NewFetchResponseTopic()
+		rt.Topic = "t"
+		for p := int32(0); p < 2; p++ {
+			c := &cursor{topic: "t", partition: p, source: s}
+			c.leader = 1
+			c.replicas.Store([]int32{1, 2})
+			if p == 0 {
+				c.replicaSince = time.Now().Add(-time.Hour) // past the max age: returns to the leader
+			}
+			req.usedOffsets["t"][p] = &cursorOffsetNext{from: c}
+
+			rp := kmsg.NewFetchResponseTopicPartition()
+			rp.Partition = p
+			rp.PreferredReadReplica = -1
+			rp.RecordBatches = encodeTestBatch(0, 2, 7)
+			rt.Partitions = append(rt.Partitions, rp)
+		}
+		resp.Topics = append(resp.Topics, rt)
+
+		f, _, preferreds, _, _, _ := s.handleReqResp(&broker{meta: BrokerMetadata{NodeID: 2}}, req, resp)
+		if len(preferreds) != 1 || preferreds[0].from.partition != 0 || preferreds[0].preferredReplica != 1 {
+			t.Errorf("concurrency %d: got moves %v, exp partition 0 moving to the leader", concurrency, preferreds)
+		}
+		if !reflect.DeepEqual(read, []int32{1}) {
+			t.Errorf("concurrency %d: got batches read for partitions %v, exp only partition 1", concurrency, read)
+		}
+		if len(f.Topics) != 1 || len(f.Topics[0].Partitions) != 1 || f.Topics[0].Partitions[0].Partition != 1 || len(f.Topics[0].Partitions[0].Records) != 2 {
+			t.Errorf("concurrency %d: got fetch %+v, exp only partition 1 with 2 records", concurrency, f)
+		}
+	}
+}
+
+func TestTakeNBufferedPerPartition(t *testing.T) {
+	cl, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	s := cl.newSource(1)
+	s.sem = make(chan struct{})
+	recs := func(p int32, n int) []*Record {
+		var rs []*Record
+		for i := 0; i < n; i++ {
+			rs = append(rs, &Record{Topic: "t", Partition: p, Offset: int64(i)})
+		}
+		return rs
+	}
+	cursors := map[int32]*cursor{
+		0: {topic: "t", partition: 0, source: s},
+		1: {topic: "t", partition: 1, source: s},
+	}
+	s.buffered = bufferedFetch{
+		fetch: Fetch{Topics: []FetchTopic{{
+			Topic: "t",
+			Partitions: []FetchPartition{
+				{Partition: 0, Records: recs(0, 5)},
+				{Partition: 1, Records: recs(1, 2)},
+			},
+		}}},
+		doneFetch: make(chan struct{}, 1),
+		usedOffsets: usedOffsets{"t": {
+			0: {cursorOffset: cursorOffset{offset: 5}, from: cursors[0]},
+			1: {cursorOffset: cursorOffset{offset: 2}, from: cursors[1]},
+		}},
+	}
+
+	for i, exp := range []struct {
+		perPartition map[int32]int
+		drained      bool
+	}{
+		{map[int32]int{0: 2, 1: 2}, false},
+		{map[int32]int{0: 2}, false},
+		{map[int32]int{0: 1}, true},
+	} {
+		f, taken, drained := s.takeNBuffered(math.MaxInt, 2, nil)
+		if drained != exp.drained {
+			t.Errorf("#%d: got drained %v, exp %v", i, drained, exp.drained)
+		}
+		got := make(map[int32]int)
+		var total int
+		Fetches{f}.EachPartition(func(p FetchTopicPartition) {
+			got[p.Partition] += len(p.Records)
+			total += len(p.Records)
+		})
+		if !reflect.DeepEqual(got, exp.perPartition) || total != taken {
+			t.Errorf("#%d: got per partition %v (taken %d), exp %v", i, got, taken, exp.perPartition)
+		}
+	}
+	if o := cursors[0].offset; o != 5 {
+		t.Errorf("got partition 0 cursor offset %d, exp 5", o)
+	}
+}
+
+func TestPollFetchesWith(t *testing.T) {
+	cl, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	s := cl.newSource(1)
+	s.sem = make(chan struct{})
+	recs := func(p int32, n int) []*Record {
+		var rs []*Record
+		for i := 0; i < n; i++ {
+			rs = append(rs, &Record{Topic: "t", Partition: p, Offset: int64(i), Value: []byte("v")})
+		}
+		return rs
+	}
+	cursors := map[int32]*cursor{
+		0: {topic: "t", partition: 0, source: s},
+		1: {topic: "t", partition: 1, source: s},
+	}
+	s.buffered = bufferedFetch{
+		fetch: Fetch{Topics: []FetchTopic{{
+			Topic: "t",
+			Partitions: []FetchPartition{
+				{Partition: 0, Records: recs(0, 5)},
+				{Partition: 1, Records: recs(1, 2)},
+			},
+		}}},
+		doneFetch: make(chan struct{}, 1),
+		usedOffsets: usedOffsets{"t": {
+			0: {cursorOffset: cursorOffset{offset: 5}, from: cursors[0]},
+			1: {cursorOffset: cursorOffset{offset: 2}, from: cursors[1]},
+		}},
+	}
+	s.hook(&s.buffered.fetch, true, false)
+	cl.consumer.addSourceReadyForDraining(s)
+
+	ctx := context.Background()
+	count := func(fs Fetches) map[int32]int {
+		if errs := fs.Errors(); len(errs) > 0 {
+			t.Fatalf("unexpected fetch errors: %v", errs)
+		}
+		got := make(map[int32]int)
+		fs.EachRecord(func(r *Record) { got[r.Partition]++ })
+		return got
+	}
+
+	if got, exp := count(cl.PollFetchesWith(ctx, PollPartitions(map[string][]int32{"t": {1}}))), map[int32]int{1: 2}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("restricted poll: got %v, exp %v", got, exp)
+	}
+	if got, exp := count(cl.PollFetchesWith(ctx, PollMaxRecords(2))), map[int32]int{0: 2}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("max records poll: got %v, exp %v", got, exp)
+	}
+	if b := cl.consumer.bufferedBytes.Load(); b != 3 {
+		t.Errorf("got %d buffered bytes, exp 3", b)
+	}
+
+	start := time.Now()
+	if got, exp := count(cl.PollFetchesWith(ctx, PollMinBytes(1<<20), PollMaxWait(50*time.Millisecond))), map[int32]int{0: 3}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("min bytes poll: got %v, exp %v", got, exp)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("min bytes poll returned after %v, before the max wait", elapsed)
+	}
+
+	if fs := cl.PollFetchesWith(ctx, PollMaxWait(10*time.Millisecond)); len(fs) != 0 {
+		t.Errorf("got %v from an empty poll, exp nothing", fs)
+	}
+}
+
+func TestHedgeFetchRequest(t *testing.T) {
+	t.Parallel()
+
+	cursor := func(p, leader int32, replicas ...int32) *cursorOffsetNext {
+		c := &cursor{topic: "t", partition: p}
+		c.leader = leader
+		c.replicas.Store(replicas)
+		return &cursorOffsetNext{from: c}
+	}
+	req := func(cs ...*cursorOffsetNext) *fetchRequest {
+		f := &fetchRequest{
+			usedOffsets: usedOffsets{"t": make(map[int32]*cursorOffsetNext)},
+			torder:      []string{"t"},
+			porder:      make(map[string][]int32),
+		}
+		for _, c := range cs {
+			f.usedOffsets["t"][c.from.partition] = c
+			f.porder["t"] = append(f.porder["t"], c.from.partition)
+			f.numOffsets++
+		}
+		return f
+	}
+
+	for i, test := range []struct {
+		req   *fetchRequest
+		skip  int32
+		exp   int32
+		expOK bool
+	}{
+		// Fetching from a follower: hedge to the shared leader.
+		{req(cursor(0, 1, 1, 2, 3), cursor(1, 1, 1, 2, 3)), 2, 1, true},
+		// Fetching from the leader: lowest replica of everything.
+		{req(cursor(0, 1, 1, 2, 3), cursor(1, 1, 1, 3, 4)), 1, 3, true},
+		// Different leaders, no common replica other than skip.
+		{req(cursor(0, 1, 1, 2), cursor(1, 3, 1, 3)), 1, -1, false},
+		// Single replica.
+		{req(cursor(0, 1, 1)), 1, -1, false},
+	} {
+		got, ok := test.req.hedgeReplica(test.skip)
+		if got != test.exp || ok != test.expOK {
+			t.Errorf("#%d: got (%d, %v), exp (%d, %v)", i, got, ok, test.exp, test.expOK)
+		}
+	}
+
+	orig := req(cursor(0, 1, 1), cursor(1, 1, 1))
+	orig.session.id = 3
+	orig.session.epoch = 4
+	dup := orig.hedgeCopy()
+	dup.porder["t"][0] = 9
+	dup.torder[0] = "u"
+	if orig.porder["t"][0] != 0 || orig.torder[0] != "t" {
+		t.Error("hedge copy shares write ordering with the original request")
+	}
+	if !dup.session.killed || dup.session.epoch != -1 || orig.session.epoch != 4 {
+		t.Error("hedge copy is not sessionless or modified the original session")
+	}
+
+	resp := &kmsg.FetchResponse{Topics: []kmsg.FetchResponseTopic{{
+		Partitions: []kmsg.FetchResponseTopicPartition{{}, {}},
+	}}}
+	if fetchRespHasErrs(resp) {
+		t.Error("unexpected errors in error free response")
+	}
+	resp.Topics[0].Partitions[1].ErrorCode = 6
+	if !fetchRespHasErrs(resp) {
+		t.Error("missed partition error")
+	}
+}
+
+// TestHedgeFetchPrimaryWins hedges a fetch to a broker that reads the request
+// and never replies, so the primary wins. The primary's response is processed
+// after the hedge was written; run with -race to check that the hedge does
+// not share offsets with the primary.
+func TestHedgeFetchPrimaryWins(t *testing.T) {
+	seed := listenTest(t, func(conn net.Conn) { io.Copy(io.Discard, conn) })
+	read := make(chan struct{}, 1)
+	addr := listenTest(t, func(conn net.Conn) {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		if _, err := io.CopyN(io.Discard, conn, int64(binary.BigEndian.Uint32(size[:]))); err != nil {
+			return
+		}
+		read <- struct{}{}
+		io.Copy(io.Discard, conn)
+	})
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNum, _ := strconv.Atoi(port)
+
+	cl, err := NewClient(SeedBrokers(seed), FetchHedgeAfter(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	b := cl.newBroker(1, host, int32(portNum), nil)
+	defer b.stopForever()
+	b.storeVersions(newBrokerVersions()) // skip ApiVersions on connect
+	cl.brokersMu.Lock()
+	cl.brokers = []*broker{b}
+	cl.brokersMu.Unlock()
+
+	c := &cursor{topic: "t"}
+	c.leader = 1
+	c.replicas.Store([]int32{0, 1})
+	req := &fetchRequest{
+		maxBytes:     1 << 20,
+		maxPartBytes: 1 << 20,
+		numOffsets:   1,
+		usedOffsets:  usedOffsets{"t": {0: c.use()}},
+		torder:       []string{"t"},
+		porder:       map[string][]int32{"t": {0}},
+	}
+	hreq := req.hedgeCopy()
+
+	requested := make(chan struct{})
+	go func() {
+		<-read
+		close(requested)
+	}()
+	s := &source{cl: cl, nodeID: 0}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if h := s.hedgeFetch(ctx, hreq, requested); h != nil {
+		t.Fatal("hedged fetch won against a broker that never replies")
+	}
+	if ctx.Err() != nil {
+		t.Fatal("timed out waiting for the hedged fetch to be written")
+	}
+
+	o := req.usedOffsets["t"][0]
+	fp := o.processRespPartition(nil, &kmsg.FetchResponseTopicPartition{
+		HighWatermark: 3,
+		RecordBatches: encodeTestBatch(0, 3, 7),
+	}, newDecompressor([5]Decompressor{}), nil)
+	if fp.Err != nil || len(fp.Records) != 3 || o.offset != 3 {
+		t.Fatalf("got err %v, %d records, offset %d; exp 3 records and offset 3", fp.Err, len(fp.Records), o.offset)
+	}
+	if ho := hreq.usedOffsets["t"][0]; ho.offset != 0 || ho.hwm != 0 {
+		t.Errorf("processing the primary modified the hedge's offset %d and hwm %d", ho.offset, ho.hwm)
+	}
+}
+
+type replicaSelectorFn func(ReplicaSelection) int32
+
+func (fn replicaSelectorFn) SelectReplica(s ReplicaSelection) int32 { return fn(s) }
+
+func TestReplicaSelector(t *testing.T) {
+	t.Parallel()
+
+	var selections []ReplicaSelection
+	cl, err := NewClient(FetchReplicaSelector(replicaSelectorFn(func(s ReplicaSelection) int32 {
+		selections = append(selections, s)
+		switch s.Partition {
+		case 0:
+			return 2
+		case 3:
+			return 9 // not a replica
+		}
+		return -1
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	s := cl.newSource(1)
+	req := &fetchRequest{usedOffsets: usedOffsets{"t": make(map[int32]*cursorOffsetNext)}}
+	resp := &kmsg.FetchResponse{Version: 11}
+	rt := kmsg.NewFetchResponseTopic()
+	rt.Topic = "t"
+	for p := int32(0); p < 4; p++ {
+		c := &cursor{topic: "t", partition: p, source: s}
+		c.leader = 1
+		c.replicas.Store([]int32{1, 2, 3})
+		req.usedOffsets["t"][p] = &cursorOffsetNext{from: c}
+
+		rp := kmsg.NewFetchResponseTopicPartition()
+		rp.Partition = p
+		rp.PreferredReadReplica = -1
+		switch p {
+		case 1:
+			rp.RecordBatches = encodeTestBatch(0, 3, 7) // has records: not selected
+		case 2:
+			rp.PreferredReadReplica = 3 // selector defers to the broker
+		}
+		rt.Partitions = append(rt.Partitions, rp)
+	}
+	resp.Topics = append(resp.Topics, rt)
+
+	_, _, preferreds, _, _, _ := s.handleReqResp(nil, req, resp)
+	moved := make(map[int32]int32)
+	preferreds.eachPreferred(func(c cursorOffsetPreferred) {
+		moved[c.from.partition] = c.preferredReplica
+	})
+	if exp := map[int32]int32{0: 2, 2: 3}; !reflect.DeepEqual(moved, exp) {
+		t.Errorf("got moves %v, exp %v", moved, exp)
+	}
+
+	if len(selections) != 3 {
+		t.Fatalf("got %d selections, exp 3", len(selections))
+	}
+	for _, sel := range selections {
+		if sel.Partition == 1 {
+			t.Error("selector was called for a partition with records")
+		}
+		exp := ReplicaSelection{Topic: "t", Partition: sel.Partition, Leader: 1, Replicas: []int32{1, 2, 3}, Current: 1, Preferred: -1}
+		if sel.Partition == 2 {
+			exp.Preferred = 3
+		}
+		if !reflect.DeepEqual(sel, exp) {
+			t.Errorf("got selection %+v, exp %+v", sel, exp)
+		}
+	}
+}
+
+func TestPreferredReplicaFallback(t *testing.T) {
+	t.Parallel()
+
+	var switches []ReplicaSwitch
+	cl, err := NewClient(
+		PreferredReplicaMaxAge(time.Minute),
+		PreferredReplicaMaxErrors(2),
+		OnReplicaSwitch(func(s ReplicaSwitch) { switches = append(switches, s) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	leader, follower := cl.newSource(1), cl.newSource(2)
+	prior := cl.sinksAndSources
+	cl.sinksAndSources = map[int32]sinkAndSource{1: {source: leader}, 2: {source: follower}}
+	defer func() { cl.sinksAndSources = prior }() // our sources have no sinks to close
+
+	req := &fetchRequest{usedOffsets: usedOffsets{"t": make(map[int32]*cursorOffsetNext)}}
+	resp := &kmsg.FetchResponse{Version: 11}
+	rt := kmsg.NewFetchResponseTopic()
+	rt.Topic = "t"
+	cursors := make(map[int32]*cursor)
+	for p := int32(0); p < 4; p++ {
+		c := &cursor{topic: "t", partition: p, source: follower, cursorsIdx: -1}
+		c.leader = 1
+		c.replicaSince = time.Now()
+		follower.addCursor(c)
+		cursors[p] = c
+		req.usedOffsets["t"][p] = &cursorOffsetNext{from: c}
+
+		rp := kmsg.NewFetchResponseTopicPartition()
+		rp.Partition = p
+		rp.PreferredReadReplica = -1
+		switch p {
+		case 0:
+			c.replicaSince = time.Now().Add(-2 * time.Minute) // aged out
+		case 1:
+			c.replicaErrs = 1
+			rp.ErrorCode = kerr.OffsetNotAvailable.Code // second error in a row
+		case 3:
+			rp.ErrorCode = kerr.OffsetNotAvailable.Code // first error
+		}
+		rt.Partitions = append(rt.Partitions, rp)
+	}
+	resp.Topics = append(resp.Topics, rt)
+
+	_, _, preferreds, _, _, _ := follower.handleReqResp(nil, req, resp)
+	moved := make(map[int32]ReplicaSwitchReason)
+	preferreds.eachPreferred(func(c cursorOffsetPreferred) {
+		if c.preferredReplica != 1 {
+			t.Errorf("partition %d: moving to %d, exp the leader 1", c.from.partition, c.preferredReplica)
+		}
+		moved[c.from.partition] = c.why
+		c.move()
+	})
+	if exp := map[int32]ReplicaSwitchReason{0: ReplicaSwitchMaxAge, 1: ReplicaSwitchMaxErrors}; !reflect.DeepEqual(moved, exp) {
+		t.Errorf("got moves %v, exp %v", moved, exp)
+	}
+	if cursors[3].replicaErrs != 1 {
+		t.Errorf("got %d consecutive errors, exp 1", cursors[3].replicaErrs)
+	}
+	if cursors[0].source != leader || !cursors[0].replicaSince.IsZero() {
+		t.Error("cursor did not move back to the leader")
+	}
+
+	exp := []ReplicaSwitch{
+		{Topic: "t", Partition: 0, From: 2, To: 1, Leader: 1, Reason: ReplicaSwitchMaxAge},
+		{Topic: "t", Partition: 1, From: 2, To: 1, Leader: 1, Reason: ReplicaSwitchMaxErrors},
+	}
+	sort.Slice(switches, func(i, j int) bool { return switches[i].Partition < switches[j].Partition })
+	if !reflect.DeepEqual(switches, exp) {
+		t.Errorf("got switches %+v, exp %+v", switches, exp)
+	}
+}
+
+func TestReplicaSelectorFinal(t *testing.T) {
+	t.Parallel()
+
+	cl, err := NewClient(
+		PreferredReplicaMaxAge(time.Millisecond),
+		FetchReplicaSelector(replicaSelectorFn(func(s ReplicaSelection) int32 {
+			if s.Partition == 1 {
+				return -1
+			}
+			return s.Current
+		})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	// We are consuming from follower 2, which has been a replica for
+	// longer than the max age.
+	s := cl.newSource(2)
+	req := &fetchRequest{usedOffsets: usedOffsets{"t": make(map[int32]*cursorOffsetNext)}}
+	resp := &kmsg.FetchResponse{Version: 11}
+	rt := kmsg.NewFetchResponseTopic()
+	rt.Topic = "t"
+	for p := int32(0); p < 3; p++ {
+		c := &cursor{topic: "t", partition: p, source: s}
+		c.leader = 1
+		c.replicas.Store([]int32{1, 2, 3})
+		c.replicaSince = time.Now().Add(-time.Hour)
+		req.usedOffsets["t"][p] = &cursorOffsetNext{from: c}
+
+		rp := kmsg.NewFetchResponseTopicPartition()
+		rp.Partition = p
+		rp.PreferredReadReplica = -1
+		if p == 0 {
+			rp.PreferredReadReplica = 3 // overridden by the selector
+		}
+		rt.Partitions = append(rt.Partitions, rp)
+	}
+	resp.Topics = append(resp.Topics, rt)
+
+	_, _, preferreds, _, _, _ := s.handleReqResp(nil, req, resp)
+	moved := make(map[int32]int32)
+	preferreds.eachPreferred(func(c cursorOffsetPreferred) {
+		moved[c.from.partition] = c.preferredReplica
+	})
+	// Only the partition that the selector deferred on returns to the
+	// leader; the selector keeping partitions 0 and 2 on the current broker
+	// is final.
+	if exp := map[int32]int32{1: 1}; !reflect.DeepEqual(moved, exp) {
+		t.Errorf("got moves %v, exp %v", moved, exp)
+	}
+}
+
+func TestAdaptFetchBytes(t *testing.T) {
+	c := &cursor{topic: "t"}
+	const base, max = 100, 350
+	for i, step := range []struct {
+		hwm, offset int64
+		fetched     int
+		exp         int32
+	}{
+		{1000, 100, 100, 200},  // lagging and size bound: double
+		{1000, 300, 200, 350},  // double, capped at max
+		{1000, 400, 100, 350},  // lagging but small fetch: keep
+		{1000, 1000, 350, 175}, // caught up: halve
+		{1000, 1000, 0, 0},     // halve to base: use base
+		{1000, 1000, 0, 0},     // stays at base
+	} {
+		o := cursorOffsetNext{cursorOffset: cursorOffset{offset: step.offset, hwm: step.hwm}, from: c, fetchBytes: c.fetchBytes}
+		o.adaptFetchBytes(base, max, step.fetched)
+		if c.fetchBytes != step.exp {
+			t.Errorf("#%d: got fetch bytes %d, exp %d", i, c.fetchBytes, step.exp)
+		}
+	}
+
+	s := make(fetchSessionTopic)
+	if s.hasPartitionAt(0, 5, 1, 100) || !s.hasPartitionAt(0, 5, 1, 100) || s.hasPartitionAt(0, 5, 1, 200) {
+		t.Error("session did not track partition max bytes changes")
+	}
+}
+
+func TestLag(t *testing.T) {
+	cl, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	s := cl.newSource(1)
+	s.sem = make(chan struct{})
+	var recs []*Record
+	for i := 0; i < 4; i++ {
+		recs = append(recs, &Record{Topic: "t", Offset: int64(10 + i)})
+	}
+	c0, c1 := &cursor{topic: "t", partition: 0, source: s}, &cursor{topic: "t", partition: 1, source: s}
+	s.buffered = bufferedFetch{
+		fetch: Fetch{Topics: []FetchTopic{{
+			Topic: "t",
+			Partitions: []FetchPartition{
+				{Partition: 0, Records: recs, HighWatermark: 20, LogStartOffset: 5},
+				{Partition: 1, HighWatermark: 30, LogStartOffset: 25},
+			},
+		}}},
+		doneFetch: make(chan struct{}, 1),
+		usedOffsets: usedOffsets{"t": {
+			0: {cursorOffset: cursorOffset{offset: 14}, from: c0},
+			1: {cursorOffset: cursorOffset{offset: 3}, from: c1},
+		}},
+	}
+
+	s.takeNBuffered(2, 0, nil)
+	exp := map[string]map[int32]PartitionLag{"t": {0: {Offset: 12, HighWatermark: 20, LogStartOffset: 5, Lag: 8}}}
+	if got := cl.Lag(); !reflect.DeepEqual(got, exp) {
+		t.Errorf("got lag %v, exp %v", got, exp)
+	}
+
+	s.takeNBuffered(10, 0, nil)
+	exp["t"][0] = PartitionLag{Offset: 14, HighWatermark: 20, LogStartOffset: 5, Lag: 6}
+	exp["t"][1] = PartitionLag{Offset: 3, HighWatermark: 30, LogStartOffset: 25, Lag: 5} // offset before log start
+	if got := cl.Lag(); !reflect.DeepEqual(got, exp) {
+		t.Errorf("got lag %v, exp %v", got, exp)
+	}
+
+	cl.consumer.delLag("t", 0)
+	cl.consumer.delLag("t", 1)
+	if got := cl.Lag(); got != nil {
+		t.Errorf("got lag %v after deleting, exp nil", got)
+	}
+}
+
+type fetchLatencyHook map[int32]FetchLatencyMetrics
+
+func (h fetchLatencyHook) OnFetchPartitionLatency(_ string, p int32, m FetchLatencyMetrics) {
+	h[p] = m
+}
+
+func TestHookFetchLatency(t *testing.T) {
+	now := time.Now()
+	f := Fetch{Topics: []FetchTopic{{
+		Topic: "t",
+		Partitions: []FetchPartition{
+			{Partition: 0, Records: []*Record{
+				{Timestamp: now.Add(-3 * time.Second)},
+				{Timestamp: now.Add(-time.Second)},
+				{Timestamp: now.Add(-2 * time.Second)},
+			}},
+			{Partition: 1}, // no records: no hook
+		},
+	}}}
+
+	h := make(fetchLatencyHook)
+	hookFetchLatency(&f, []HookFetchPartitionLatency{h}, now)
+	exp := fetchLatencyHook{0: {
+		NumRecords:   3,
+		MinLatency:   time.Second,
+		MaxLatency:   3 * time.Second,
+		TotalLatency: 6 * time.Second,
+	}}
+	if !reflect.DeepEqual(h, exp) {
+		t.Errorf("got %v, exp %v", h, exp)
+	}
+}
+
+func TestSetOffsetsForTimes(t *testing.T) {
+	cl, err := NewClient(ConsumePartitions(map[string]map[int32]Offset{"foo": {0: NewOffset()}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if err := cl.SetOffsetsForTimes(context.Background(), nil); err != nil {
+		t.Errorf("unexpected err setting no times: %v", err)
+	}
+
+	// We cannot resolve times without a broker; nothing should be set.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := cl.SetOffsetsForTimes(ctx, map[string]map[int32]time.Time{"foo": {0: time.Now()}}); err == nil {
+		t.Error("unexpected success resolving times with a canceled context")
+	}
+}
+
+func TestOnOffsetOutOfRange(t *testing.T) {
+	cl, err := NewClient(ConsumeResetOffset(NewOffset().AtEnd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := &FetchPartition{LogStartOffset: 10, HighWatermark: 20}
+	if reset := cl.outOfRangeReset("foo", 0, 5, fp); reset != cl.cfg.resetOffset {
+		t.Errorf("got reset %v without a callback, exp ConsumeResetOffset %v", reset, cl.cfg.resetOffset)
+	}
+	cl.Close()
+
+	var seen []OffsetOutOfRange
+	cl, err = NewClient(
+		TopicNamespace(TopicPrefix("ns.")),
+		OnOffsetOutOfRange(func(o OffsetOutOfRange) OffsetOutOfRangeAction {
+			seen = append(seen, o)
+			switch o.Partition {
+			case 0:
+				return OutOfRangeReset(NewOffset().At(o.LogStartOffset))
+			case 1:
+				return OutOfRangePause()
+			default:
+				return OutOfRangeFail()
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if reset := cl.outOfRangeReset("ns.foo", 0, 5, fp); reset.noReset || reset.at != 10 {
+		t.Errorf("got reset %v, exp reset at 10", reset)
+	}
+	if reset := cl.outOfRangeReset("ns.foo", 1, 5, fp); !reset.noReset || !cl.consumer.loadPaused().has("ns.foo", 1) {
+		t.Errorf("got reset %v, exp no reset and partition 1 paused", reset)
+	}
+	if reset := cl.outOfRangeReset("ns.foo", 2, 5, fp); !reset.noReset || cl.consumer.loadPaused().has("ns.foo", 2) {
+		t.Errorf("got reset %v, exp no reset and partition 2 not paused", reset)
+	}
+	if exp := (OffsetOutOfRange{"foo", 0, 5, 10, 20}); len(seen) != 3 || seen[0] != exp {
+		t.Errorf("got out of range calls %v, exp first %v", seen, exp)
+	}
+}
+
+func TestOnLogTruncation(t *testing.T) {
+	var seen []LogTruncation
+	cl, err := NewClient(
+		TopicNamespace(TopicPrefix("ns.")),
+		OnLogTruncation(func(lt LogTruncation) bool {
+			seen = append(seen, lt)
+			return lt.Partition == 1
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	cl.onLogTruncation(&ErrDataLoss{"ns.foo", 0, 20, 15})
+	cl.onLogTruncation(&ErrDataLoss{"ns.foo", 1, 20, 15})
+	if cl.consumer.loadPaused().has("ns.foo", 0) || !cl.consumer.loadPaused().has("ns.foo", 1) {
+		t.Error("exp only partition 1 paused")
+	}
+	if exp := (LogTruncation{"foo", 0, 20, 15}); len(seen) != 2 || seen[0] != exp {
+		t.Errorf("got truncation calls %v, exp first %v", seen, exp)
+	}
+}
+
+func TestRegexTopicCallbacks(t *testing.T) {
+	added := make(chan []string, 1)
+	removed := make(chan []string, 1)
+	cl, err := NewClient(
+		ConsumeTopics("^foo"),
+		ConsumeRegex(),
+		ConsumeRegexRefreshInterval(time.Hour),
+		OnRegexTopicsAdded(func(_ *Client, topics []string) { added <- topics }),
+		OnRegexTopicsRemoved(func(_ *Client, topics []string) { removed <- topics }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	c := &cl.consumer
+	c.d.tps.storeTopics([]string{"foo2", "bar", "foo1"})
+	c.doOnMetadataUpdate()
+	if got := <-added; !reflect.DeepEqual(got, []string{"foo1", "foo2"}) {
+		t.Errorf("got added %v, exp [foo1 foo2]", got)
+	}
+
+	c.purgeTopics([]string{"bar", "foo1"})
+	if got := <-removed; !reflect.DeepEqual(got, []string{"foo1"}) {
+		t.Errorf("got removed %v, exp [foo1]", got)
+	}
+}
+
+func TestPauseFetchPartitionsFor(t *testing.T) {
+	cl, err := NewClient(TopicNamespace(TopicPrefix("ns.")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if paused := cl.PauseFetchPartitionsFor(50*time.Millisecond, map[string][]int32{"foo": {0, 1, 2}}); len(paused["foo"]) != 3 {
+		t.Fatalf("got paused %v, exp foo 0, 1, 2", paused)
+	}
+	cl.PauseFetchPartitions(map[string][]int32{"foo": {1}})               // pausing indefinitely stops the timer
+	cl.PauseFetchPartitionsFor(time.Hour, map[string][]int32{"foo": {2}}) // re-pausing replaces the timer
+
+	time.Sleep(200 * time.Millisecond)
+
+	paused := cl.consumer.loadPaused()
+	if paused.has("ns.foo", 0) || !paused.has("ns.foo", 1) || !paused.has("ns.foo", 2) {
+		t.Errorf("got paused %v after auto resume, exp only ns.foo 1 and 2", paused.pausedPartitions())
+	}
+
+	cl.ResumeFetchPartitions(map[string][]int32{"foo": {2}})
+	cl.consumer.pausedMu.Lock()
+	remaining := len(cl.consumer.pauseTimers)
+	cl.consumer.pausedMu.Unlock()
+	if remaining != 0 {
+		t.Errorf("got %d topics with pause timers after resuming, exp 0", remaining)
+	}
+}
+
+func TestPositions(t *testing.T) {
+	t.Parallel()
+
+	topics := make(chan []string, 100)
+	addr := listenTest(t, func(conn net.Conn) { serveMetadataTest(conn, topics) })
+
+	cl, err := NewClient(
+		SeedBrokers(addr),
+		ConsumePartitions(map[string]map[int32]Offset{"foo": {0: NewOffset().At(5)}}),
+		MetadataMinAge(time.Hour),
+		MetadataMaxAge(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	exp := map[string]map[int32]PartitionPosition{"foo": {0: {Fetch: 5, Polled: -1, Committed: -1}}}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := cl.Positions()
+		if reflect.DeepEqual(got, exp) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got positions %v, exp %v", got, exp)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cl.consumer.setLag("foo", 0, 7, 10, 0)
+	exp["foo"][0] = PartitionPosition{Fetch: 5, Polled: 6, Committed: -1}
+	if got := cl.Positions(); !reflect.DeepEqual(got, exp) {
+		t.Errorf("got positions %v after polling, exp %v", got, exp)
+	}
+}
@@ -0,0 +1,158 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+// Provenance headers that are added to every dead lettered record. See
+// DeadLetterTopic for more details.
+const (
+	// DeadLetterHeaderTopic is the header key containing the topic the
+	// record was originally produced to or consumed from.
+	DeadLetterHeaderTopic = "dlq.source.topic"
+	// DeadLetterHeaderPartition is the header key containing the
+	// record's original partition, formatted as a base 10 number. For
+	// records that failed to be produced before being partitioned, this
+	// is not meaningful.
+	DeadLetterHeaderPartition = "dlq.source.partition"
+	// DeadLetterHeaderOffset is the header key containing the record's
+	// original offset, formatted as a base 10 number. For records that
+	// failed to be produced, this is always -1.
+	DeadLetterHeaderOffset = "dlq.source.offset"
+	// DeadLetterHeaderError is the header key containing the error the
+	// record was dead lettered for, if any.
+	DeadLetterHeaderError = "dlq.error"
+)
+
+// deadLetterKey marks a record's context so that a record that fails to be
+// dead lettered is not dead lettered again.
+type deadLetterKey struct{}
+
+// DeadLetter produces a copy of r to the dead letter topic for r's topic,
+// adding provenance headers including err, and waits for the produce to
+// finish. This is meant for consumers to dead letter "poison" records that
+// cannot be processed, and can be used whether or not the client is otherwise
+// used for producing.
+//
+// This returns ErrNoDeadLetterTopic if no dead letter topic is configured for
+// r's topic, otherwise this returns the produce error, if any. If the client
+// is transactional, this must be called within a transaction. See
+// DeadLetterTopic for more details.
+func (cl *Client) DeadLetter(ctx context.Context, r *Record, err error) error {
+	dlq := cl.deadLetterRecord(r, r.Offset, err)
+	if dlq == nil {
+		return ErrNoDeadLetterTopic
+	}
+	dlq.Context = context.WithValue(ctx, deadLetterKey{}, true)
+	return cl.ProduceSync(ctx, dlq).FirstErr()
+}
+
+// maybeDeadLetterFailed is called before the promise of every record that
+// failed to be produced, and produces the record to the dead letter topic if
+// one is configured and the failure is classified as dead-letterable.
+func (cl *Client) maybeDeadLetterFailed(r *Record, err error) {
+	if cl.cfg.deadLetterTopic == "" && len(cl.cfg.deadLetterTopics) == 0 || cl.cfg.txnID != nil {
+		return
+	}
+	if r.Context != nil && r.Context.Value(deadLetterKey{}) != nil {
+		return // this record was itself being dead lettered
+	}
+	classify := cl.cfg.deadLetterClassify
+	if classify == nil {
+		classify = defaultDeadLetterClassify
+	}
+	if !classify(r, err) {
+		return
+	}
+	dlq := cl.deadLetterRecord(r, -1, err)
+	if dlq == nil {
+		return
+	}
+	// We are in the promise goroutine with promisesMu held. Producing
+	// here can block: if the buffer is full, even TryProduce waits to
+	// drain a slot from waitBuffer, which is only sent to by this
+	// goroutine as records finish. We produce in a new goroutine so that
+	// promises keep finishing; we do not block waiting for buffer space,
+	// since the dead letter record is best effort.
+	ctx := context.WithValue(context.Background(), deadLetterKey{}, true)
+	dlq.Context = ctx
+	srcTopic := r.Topic // r may be reused once its promise is called
+	go cl.TryProduce(ctx, dlq, func(dlq *Record, dlqErr error) {
+		if dlqErr != nil {
+			cl.cfg.logger.Log(LogLevelWarn, "unable to produce record to dead letter topic",
+				"source_topic", srcTopic,
+				"dead_letter_topic", dlq.Topic,
+				"source_err", err,
+				"err", dlqErr,
+			)
+		}
+	})
+}
+
+// defaultDeadLetterClassify dead letters every produce failure that is not
+// caused by the user: closing or aborting the client or canceling the
+// record's context.
+func defaultDeadLetterClassify(_ *Record, err error) bool {
+	switch {
+	case errors.Is(err, ErrClientClosed),
+		errors.Is(err, ErrAborting),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	return true
+}
+
+// deadLetterTopicFor returns the dead letter topic for a source topic, or ""
+// if there is none.
+func (cl *Client) deadLetterTopicFor(topic string) string {
+	if dlq, ok := cl.cfg.deadLetterTopics[topic]; ok {
+		return dlq
+	}
+	return cl.cfg.deadLetterTopic
+}
+
+// deadLetterRecord returns a copy of r destined to the dead letter topic for
+// r's topic, with provenance headers added, or nil if there is no dead letter
+// topic. The key, value, and headers are copied so that the source record can
+// be reused once its promise is called.
+func (cl *Client) deadLetterRecord(r *Record, offset int64, err error) *Record {
+	topic := r.Topic
+	if topic == "" {
+		topic = cl.cfg.defaultProduceTopic
+	}
+	dlqTopic := cl.deadLetterTopicFor(topic)
+	if dlqTopic == "" || dlqTopic == topic {
+		return nil
+	}
+
+	headers := make([]RecordHeader, 0, len(r.Headers)+4)
+	for _, h := range r.Headers {
+		headers = append(headers, RecordHeader{Key: h.Key, Value: dupBytes(h.Value)})
+	}
+	headers = append(headers,
+		RecordHeader{Key: DeadLetterHeaderTopic, Value: []byte(topic)},
+		RecordHeader{Key: DeadLetterHeaderPartition, Value: strconv.AppendInt(nil, int64(r.Partition), 10)},
+		RecordHeader{Key: DeadLetterHeaderOffset, Value: strconv.AppendInt(nil, offset, 10)},
+	)
+	if err != nil {
+		headers = append(headers, RecordHeader{Key: DeadLetterHeaderError, Value: []byte(err.Error())})
+	}
+
+	return &Record{
+		Key:       dupBytes(r.Key),
+		Value:     dupBytes(r.Value),
+		Headers:   headers,
+		Timestamp: r.Timestamp,
+		Topic:     dlqTopic,
+	}
+}
+
+func dupBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
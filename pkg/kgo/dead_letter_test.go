@@ -0,0 +1,124 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeadLetterRecord(t *testing.T) {
+	t.Parallel()
+	cl, err := NewClient(
+		DeadLetterTopic("dlq"),
+		DeadLetterTopicFor("orders", "orders-dlq"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer cl.Close()
+
+	r := &Record{
+		Key:       []byte("k"),
+		Value:     []byte("v"),
+		Headers:   []RecordHeader{{Key: "h", Value: []byte("hv")}},
+		Topic:     "orders",
+		Partition: 3,
+		Offset:    10,
+	}
+	dlq := cl.deadLetterRecord(r, r.Offset, errors.New("bad"))
+	if dlq == nil {
+		t.Fatal("unexpected nil dead letter record")
+	}
+	if dlq.Topic != "orders-dlq" {
+		t.Errorf("got topic %q != exp orders-dlq", dlq.Topic)
+	}
+	r.Value[0] = 'x'
+	if string(dlq.Value) != "v" {
+		t.Errorf("dead letter value was not copied, got %q", dlq.Value)
+	}
+	exp := map[string]string{
+		"h":                       "hv",
+		DeadLetterHeaderTopic:     "orders",
+		DeadLetterHeaderPartition: "3",
+		DeadLetterHeaderOffset:    "10",
+		DeadLetterHeaderError:     "bad",
+	}
+	if len(dlq.Headers) != len(exp) {
+		t.Fatalf("got %d headers != exp %d", len(dlq.Headers), len(exp))
+	}
+	for _, h := range dlq.Headers {
+		if exp[h.Key] != string(h.Value) {
+			t.Errorf("header %s: got %q != exp %q", h.Key, h.Value, exp[h.Key])
+		}
+	}
+
+	if dlq := cl.deadLetterRecord(&Record{Topic: "other"}, -1, nil); dlq == nil || dlq.Topic != "dlq" {
+		t.Errorf("expected default dead letter topic, got %v", dlq)
+	}
+	if dlq := cl.deadLetterRecord(&Record{Topic: "dlq"}, -1, nil); dlq != nil {
+		t.Errorf("expected no dead lettering of the dead letter topic, got %v", dlq)
+	}
+
+	if _, err := NewClient(DeadLetterTopicFor("foo", "foo")); err == nil {
+		t.Error("expected error for topic dead lettering to itself")
+	}
+}
+
+func TestDefaultDeadLetterClassify(t *testing.T) {
+	t.Parallel()
+	for _, test := range []struct {
+		err error
+		exp bool
+	}{
+		{ErrRecordTimeout, true},
+		{ErrRecordRetries, true},
+		{ErrClientClosed, false},
+		{ErrAborting, false},
+		{context.Canceled, false},
+	} {
+		if got := defaultDeadLetterClassify(nil, test.err); got != test.exp {
+			t.Errorf("%v: got %v != exp %v", test.err, got, test.exp)
+		}
+	}
+}
+
+func TestDeadLetterFailedWhileBufferFull(t *testing.T) {
+	t.Parallel()
+	cl, err := NewClient(
+		SeedBrokers("127.0.0.1:1"), // nothing listens here
+		MaxBufferedRecords(1),
+		DeadLetterTopic("dlq"),
+		RecordDeliveryTimeout(time.Second),
+		RetryBackoffFn(func(int) time.Duration { return 10 * time.Millisecond }),
+	)
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer cl.Close()
+
+	// The record fails while it is still counted in the full buffer; dead
+	// lettering it must not wait for buffer space inside the promise.
+	done := make(chan error, 1)
+	cl.Produce(context.Background(), &Record{Topic: "foo", Value: []byte("v")}, func(_ *Record, err error) {
+		done <- err
+	})
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("unexpected nil produce error")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("record promise was never called")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for cl.BufferedProduceRecords() != 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("buffered records never drained, still have %d", cl.BufferedProduceRecords())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"io"
 	"net"
 	"os"
+
+	"github.com/twmb/franz-go/pkg/kerr"
 )
 
 func isRetryableBrokerErr(err error) bool {
@@ -180,15 +182,29 @@ var (
 	// unable to be produced after RecordRetries attempts.
 	ErrRecordRetries = errors.New("record failed after being retried too many times")
 
+	// ErrRecordExpired is passed to produce promises when records are
+	// still buffered past the deadline set with WithRecordExpiry.
+	ErrRecordExpired = errors.New("record expired before it was able to be sent")
+
 	// ErrMaxBuffered is returned when the maximum amount of records are
 	// buffered and either manual flushing is enabled or you are using
 	// TryProduce.
 	ErrMaxBuffered = errors.New("the maximum amount of records are buffered, cannot buffer more")
 
+	// ErrRateLimited is passed to produce promises when producing a record
+	// would exceed ProduceRateLimit or ProduceRateLimitPerTopic and either
+	// ProduceRateLimitFail is used, manual flushing is enabled, or you are
+	// using TryProduce.
+	ErrRateLimited = errors.New("producing the record would exceed the produce rate limit")
+
 	// ErrAborting is returned for all buffered records while
 	// AbortBufferedRecords is being called.
 	ErrAborting = errors.New("client is aborting buffered records")
 
+	// ErrNoDeadLetterTopic is returned from DeadLetter when no dead letter
+	// topic is configured for a record's topic.
+	ErrNoDeadLetterTopic = errors.New("no dead letter topic is configured for the record's topic")
+
 	// ErrClientClosed is returned in various places when the client's
 	// Close function has been called.
 	//
@@ -256,6 +272,26 @@ func (e *ErrDataLoss) Error() string {
 		e.Topic, e.Partition, e.ConsumedTo, e.ResetTo)
 }
 
+// ErrRecordTooLarge is returned in a record's promise if
+// ProducerValidateMaxMessageBytes is used and the record is larger than its
+// topic's max.message.bytes. This unwraps to kerr.MessageTooLarge.
+type ErrRecordTooLarge struct {
+	// Topic is the topic the record was produced to.
+	Topic string
+	// Size is the size of a record batch containing only the record.
+	Size int32
+	// MaxMessageBytes is the topic's max.message.bytes.
+	MaxMessageBytes int32
+}
+
+func (e *ErrRecordTooLarge) Error() string {
+	return fmt.Sprintf("record for topic %s is too large: a batch with only this record is %d bytes,"+
+		" which is larger than the topic max.message.bytes of %d", e.Topic, e.Size, e.MaxMessageBytes)
+}
+
+// Unwrap returns kerr.MessageTooLarge.
+func (*ErrRecordTooLarge) Unwrap() error { return kerr.MessageTooLarge }
+
 type errUnknownController struct {
 	id int32
 }
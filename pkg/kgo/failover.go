@@ -0,0 +1,347 @@
+package kgo
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FailoverOpt is an option to configure a FailoverClient.
+type FailoverOpt interface {
+	applyFailover(*failoverCfg)
+}
+
+type failoverOpt struct{ fn func(*failoverCfg) }
+
+func (opt failoverOpt) applyFailover(cfg *failoverCfg) { opt.fn(cfg) }
+
+type failoverCfg struct {
+	healthInterval time.Duration
+	healthFailures int
+	rewind         time.Duration
+	onFailover     func(*FailoverClient, bool)
+}
+
+// FailoverHealthCheck sets the FailoverClient to ping the active cluster every
+// interval, and to fail over to the other cluster once failures consecutive
+// pings fail. Each ping times out after interval. By default, the client does
+// not health check and only fails over when Failover is called.
+func FailoverHealthCheck(interval time.Duration, failures int) FailoverOpt {
+	return failoverOpt{func(cfg *failoverCfg) { cfg.healthInterval, cfg.healthFailures = interval, failures }}
+}
+
+// FailoverRewind sets how far before the last consumed record's timestamp a
+// FailoverClient resumes consuming on the cluster it fails over to, overriding
+// the default of 0. Rewinding more tolerates more clock skew and replication
+// reordering between clusters at the cost of more duplicate records.
+func FailoverRewind(rewind time.Duration) FailoverOpt {
+	return failoverOpt{func(cfg *failoverCfg) { cfg.rewind = rewind }}
+}
+
+// OnFailover sets a function to call after a FailoverClient switches
+// clusters, with whether the client is now using the standby cluster.
+func OnFailover(fn func(fc *FailoverClient, onStandby bool)) FailoverOpt {
+	return failoverOpt{func(cfg *failoverCfg) { cfg.onFailover = fn }}
+}
+
+// FailoverClient manages a client for a primary cluster and a standby cluster
+// (for example, a disaster recovery cluster that is mirrored from the
+// primary). Only one cluster is active at a time: producing and consuming
+// through the FailoverClient use the active cluster's client.
+//
+// When the client fails over, either because Failover is called or because
+// health checks fail (see FailoverHealthCheck), the client for the prior
+// cluster is closed in the background and a new client is created with the
+// other cluster's options. Any records still buffered in the prior client are
+// failed with ErrClientClosed; you may want to produce them again.
+//
+// Consuming resumes on the new cluster by timestamp: offsets are not
+// comparable across clusters, but mirrored records usually keep their
+// timestamps. The FailoverClient tracks the timestamp of the last record
+// polled per partition, and the new client starts consuming each partition
+// at the first record at or after that timestamp (less FailoverRewind). This
+// is done with ConsumeResetOffset for direct consumers, which uses the
+// earliest timestamp across all partitions, and with AdjustFetchOffsetsFn for
+// group consumers, which uses each partition's timestamp. These options are
+// appended to the cluster's options and override any you specified. If
+// nothing has been polled, the cluster's options are used as is.
+//
+// Consuming is at least once across a failover: records polled before
+// failing over may be polled again.
+type FailoverClient struct {
+	cfg  failoverCfg
+	opts [2][]Opt // primary, standby
+
+	mu        sync.Mutex
+	cl        *Client
+	onStandby bool
+	closed    bool
+
+	tsMu   sync.Mutex
+	lastTs map[string]map[int32]int64 // topic => partition => millis of last polled record
+
+	ctx    context.Context
+	cancel func()
+	done   chan struct{}
+}
+
+// NewFailoverClient returns a FailoverClient that initially uses a client
+// created with the primary options, and uses the standby options when
+// failing over.
+func NewFailoverClient(primary, standby []Opt, opts ...FailoverOpt) (*FailoverClient, error) {
+	fc := &FailoverClient{
+		opts:   [2][]Opt{primary, standby},
+		lastTs: make(map[string]map[int32]int64),
+		done:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt.applyFailover(&fc.cfg)
+	}
+
+	cl, err := NewClient(primary...)
+	if err != nil {
+		return nil, err
+	}
+	fc.cl = cl
+
+	fc.ctx, fc.cancel = context.WithCancel(context.Background())
+	if fc.cfg.healthInterval > 0 && fc.cfg.healthFailures > 0 {
+		go fc.healthCheck()
+	} else {
+		close(fc.done)
+	}
+	return fc, nil
+}
+
+// Client returns the client for the currently active cluster. The client is
+// closed if the FailoverClient fails over.
+func (fc *FailoverClient) Client() *Client {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.cl
+}
+
+// OnStandby returns whether the FailoverClient is using the standby cluster.
+func (fc *FailoverClient) OnStandby() bool {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.onStandby
+}
+
+// Failover switches the FailoverClient to the other cluster: from the primary
+// to the standby, or from the standby back to the primary. This returns an
+// error if the client for the other cluster cannot be created, in which case
+// the FailoverClient keeps using the current cluster.
+func (fc *FailoverClient) Failover() error {
+	return fc.failover(nil)
+}
+
+// failover switches clusters if the active client is from, or always if from
+// is nil.
+func (fc *FailoverClient) failover(from *Client) error {
+	fc.mu.Lock()
+	if fc.closed {
+		fc.mu.Unlock()
+		return ErrClientClosed
+	}
+	if from != nil && fc.cl != from {
+		fc.mu.Unlock()
+		return nil // we already failed over
+	}
+
+	toStandby := !fc.onStandby
+	opts := fc.opts[0]
+	if toStandby {
+		opts = fc.opts[1]
+	}
+	resumeOpts, setResume := fc.resumeOpts()
+	opts = append(append([]Opt(nil), opts...), resumeOpts...)
+
+	cl, err := NewClient(opts...)
+	if err != nil {
+		fc.mu.Unlock()
+		return err
+	}
+	setResume(cl)
+
+	prior := fc.cl
+	fc.cl, fc.onStandby = cl, toStandby
+	fc.mu.Unlock()
+
+	prior.cfg.logger.Log(LogLevelWarn, "failing over to other cluster", "to_standby", toStandby)
+	go prior.Close()
+	if fc.cfg.onFailover != nil {
+		fc.cfg.onFailover(fc, toStandby)
+	}
+	return nil
+}
+
+// resumeOpts returns options to resume consuming at the timestamps of the
+// last polled records, and a function to call with the new client once it is
+// created (group offset adjusting is in terms of Kafka topic names, which we
+// only know once the client exists).
+func (fc *FailoverClient) resumeOpts() ([]Opt, func(*Client)) {
+	fc.tsMu.Lock()
+	defer fc.tsMu.Unlock()
+
+	if len(fc.lastTs) == 0 {
+		return nil, func(*Client) {}
+	}
+
+	rewind := fc.cfg.rewind.Milliseconds()
+	at := func(millis int64) Offset { return NewOffset().AfterMilli(millis - rewind) }
+
+	var earliest int64 = -1
+	lastTs := make(map[string]map[int32]int64, len(fc.lastTs))
+	for topic, partitions := range fc.lastTs {
+		ps := make(map[int32]int64, len(partitions))
+		for partition, millis := range partitions {
+			ps[partition] = millis
+			if earliest < 0 || millis < earliest {
+				earliest = millis
+			}
+		}
+		lastTs[topic] = ps
+	}
+
+	var kafkaTs atomic.Value // map[string]map[int32]int64, keyed by Kafka topic name
+	adjust := func(_ context.Context, offsets map[string]map[int32]Offset) (map[string]map[int32]Offset, error) {
+		tss, _ := kafkaTs.Load().(map[string]map[int32]int64)
+		for topic, partitions := range offsets {
+			for partition := range partitions {
+				if millis, ok := tss[topic][partition]; ok {
+					partitions[partition] = at(millis)
+				}
+			}
+		}
+		return offsets, nil
+	}
+
+	setResume := func(cl *Client) {
+		tss := make(map[string]map[int32]int64, len(lastTs))
+		for topic, partitions := range lastTs {
+			tss[cl.nsTopic(topic)] = partitions
+		}
+		kafkaTs.Store(tss)
+	}
+
+	return []Opt{ConsumeResetOffset(at(earliest)), AdjustFetchOffsetsFn(adjust)}, setResume
+}
+
+func (fc *FailoverClient) healthCheck() {
+	defer close(fc.done)
+
+	ticker := time.NewTicker(fc.cfg.healthInterval)
+	defer ticker.Stop()
+
+	var failures int
+	for {
+		select {
+		case <-fc.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cl := fc.Client()
+		ctx, cancel := context.WithTimeout(fc.ctx, fc.cfg.healthInterval)
+		err := cl.Ping(ctx)
+		cancel()
+		if err == nil {
+			failures = 0
+			continue
+		}
+		if fc.ctx.Err() != nil {
+			return
+		}
+
+		failures++
+		cl.cfg.logger.Log(LogLevelWarn, "failover health check failed", "err", err, "consecutive_failures", failures)
+		if failures < fc.cfg.healthFailures {
+			continue
+		}
+		failures = 0
+		if err := fc.failover(cl); err != nil && err != ErrClientClosed {
+			cl.cfg.logger.Log(LogLevelError, "unable to fail over to other cluster", "err", err)
+		}
+	}
+}
+
+// Produce produces a record with the active cluster's client; see
+// Client.Produce.
+func (fc *FailoverClient) Produce(ctx context.Context, r *Record, promise func(*Record, error)) {
+	fc.Client().Produce(ctx, r, promise)
+}
+
+// TryProduce produces a record with the active cluster's client; see
+// Client.TryProduce.
+func (fc *FailoverClient) TryProduce(ctx context.Context, r *Record, promise func(*Record, error)) {
+	fc.Client().TryProduce(ctx, r, promise)
+}
+
+// ProduceSync produces records with the active cluster's client; see
+// Client.ProduceSync.
+func (fc *FailoverClient) ProduceSync(ctx context.Context, rs ...*Record) ProduceResults {
+	return fc.Client().ProduceSync(ctx, rs...)
+}
+
+// Flush flushes the active cluster's client; see Client.Flush.
+func (fc *FailoverClient) Flush(ctx context.Context) error {
+	return fc.Client().Flush(ctx)
+}
+
+// PollFetches polls the active cluster's client; see Client.PollFetches. If
+// the client fails over while polling, this polls the new client.
+func (fc *FailoverClient) PollFetches(ctx context.Context) Fetches {
+	return fc.poll(func(cl *Client) Fetches { return cl.PollFetches(ctx) })
+}
+
+// PollRecords polls the active cluster's client; see Client.PollRecords. If
+// the client fails over while polling, this polls the new client.
+func (fc *FailoverClient) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
+	return fc.poll(func(cl *Client) Fetches { return cl.PollRecords(ctx, maxPollRecords) })
+}
+
+func (fc *FailoverClient) poll(fn func(*Client) Fetches) Fetches {
+	for {
+		cl := fc.Client()
+		fs := fn(cl)
+		if fs.IsClientClosed() && fc.Client() != cl {
+			continue // we failed over while polling
+		}
+		fc.track(fs)
+		return fs
+	}
+}
+
+// track saves the timestamp of the last polled record per partition.
+func (fc *FailoverClient) track(fs Fetches) {
+	fc.tsMu.Lock()
+	defer fc.tsMu.Unlock()
+	fs.EachRecord(func(r *Record) {
+		partitions := fc.lastTs[r.Topic]
+		if partitions == nil {
+			partitions = make(map[int32]int64)
+			fc.lastTs[r.Topic] = partitions
+		}
+		if millis := r.Timestamp.UnixNano() / 1e6; millis > partitions[r.Partition] {
+			partitions[r.Partition] = millis
+		}
+	})
+}
+
+// Close stops health checking and closes the active cluster's client.
+func (fc *FailoverClient) Close() {
+	fc.mu.Lock()
+	if fc.closed {
+		fc.mu.Unlock()
+		return
+	}
+	fc.closed = true
+	cl := fc.cl
+	fc.mu.Unlock()
+
+	fc.cancel()
+	<-fc.done
+	cl.Close()
+}
@@ -0,0 +1,68 @@
+package kgo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFailoverClient(t *testing.T) {
+	switched := make(chan bool, 2)
+	fc, err := NewFailoverClient(
+		[]Opt{SeedBrokers("primary:9092")},
+		[]Opt{SeedBrokers("standby:9092"), TopicNamespace(TopicPrefix("dr."))},
+		FailoverRewind(time.Second),
+		OnFailover(func(_ *FailoverClient, onStandby bool) { switched <- onStandby }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fc.Close()
+
+	primary := fc.Client()
+	if fc.OnStandby() {
+		t.Fatal("unexpectedly started on the standby")
+	}
+
+	fc.track(Fetches{{Topics: []FetchTopic{{Topic: "foo", Partitions: []FetchPartition{{
+		Partition: 1,
+		Records: []*Record{
+			{Topic: "foo", Partition: 1, Timestamp: time.UnixMilli(5000)},
+			{Topic: "foo", Partition: 1, Timestamp: time.UnixMilli(7000)},
+		},
+	}}}}}})
+
+	if err := fc.Failover(); err != nil {
+		t.Fatal(err)
+	}
+	if !<-switched || !fc.OnStandby() || fc.Client() == primary {
+		t.Fatal("did not fail over to the standby")
+	}
+
+	// The standby consumes one second before the last polled record, and
+	// the group adjust function maps our topic to the standby's name.
+	standby := fc.Client()
+	if reset := standby.cfg.resetOffset; !reset.afterMilli || reset.at != 6000 {
+		t.Errorf("got standby reset offset %v, exp after milli 6000", reset)
+	}
+	offsets, _ := standby.cfg.adjustOffsetsBeforeAssign(context.Background(), map[string]map[int32]Offset{
+		"dr.foo": {0: NewOffset().At(3), 1: NewOffset().At(3)},
+	})
+	if o := offsets["dr.foo"][1]; !o.afterMilli || o.at != 6000 {
+		t.Errorf("got adjusted offset %v, exp after milli 6000", o)
+	}
+	if o := offsets["dr.foo"][0]; o.afterMilli || o.at != 3 {
+		t.Errorf("got adjusted unpolled partition offset %v, exp unchanged", o)
+	}
+
+	if err := fc.Failover(); err != nil {
+		t.Fatal(err)
+	}
+	if <-switched || fc.OnStandby() {
+		t.Error("did not fail back to the primary")
+	}
+	fc.Close()
+	if err := fc.Failover(); err != ErrClientClosed {
+		t.Errorf("got failover err %v after close, exp %v", err, ErrClientClosed)
+	}
+}
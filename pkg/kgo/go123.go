@@ -0,0 +1,94 @@
+//go:build go1.23
+// +build go1.23
+
+package kgo
+
+import (
+	"context"
+	"iter"
+)
+
+// All returns an iterator over all records in all fetches, in the same order
+// as EachRecord. Errors are not yielded; use Errors or EachError.
+func (fs Fetches) All() iter.Seq[*Record] {
+	return func(yield func(*Record) bool) {
+		for _, f := range fs {
+			for i := range f.Topics {
+				ft := &f.Topics[i]
+				for j := range ft.Partitions {
+					for _, r := range ft.Partitions[j].Records {
+						if !yield(r) {
+							return
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// PollRecordsSeq returns an iterator that, when iterated, polls once with
+// PollRecords and yields each polled record. Each fetch error is yielded as a
+// *FetchError with a nil record after all records are yielded. If the client
+// is closed or ctx is canceled before anything is polled, the iterator yields
+// that error alone.
+//
+//	for r, err := range cl.PollRecordsSeq(ctx, 1000) {
+//		if err != nil {
+//			// handle err
+//			continue
+//		}
+//		// process r
+//	}
+func (cl *Client) PollRecordsSeq(ctx context.Context, maxPollRecords int) iter.Seq2[*Record, error] {
+	return func(yield func(*Record, error) bool) {
+		yieldFetches(cl.PollRecords(ctx, maxPollRecords), yield)
+	}
+}
+
+// Records returns an iterator that continuously polls with PollFetches and
+// yields every consumed record. Fetch errors are yielded as a *FetchError with
+// a nil record. Iteration stops, without yielding an error, once the client
+// is closed or ctx is done; check ctx.Err() if you need to distinguish the
+// two.
+//
+// Breaking out of the loop stops polling. Records polled but not yet yielded
+// when the loop is broken are dropped, but they have been returned from
+// polling: if you are autocommitting, they may be committed.
+//
+//	for r, err := range cl.Records(ctx) {
+//		if err != nil {
+//			// handle err
+//			continue
+//		}
+//		// process r
+//	}
+func (cl *Client) Records(ctx context.Context) iter.Seq2[*Record, error] {
+	return func(yield func(*Record, error) bool) {
+		for {
+			fs := cl.PollFetches(ctx)
+			if fs.IsClientClosed() || ctx.Err() != nil {
+				return
+			}
+			if !yieldFetches(fs, yield) {
+				return
+			}
+		}
+	}
+}
+
+// yieldFetches yields all records and then all errors in fs, returning false
+// if yield returned false.
+func yieldFetches(fs Fetches, yield func(*Record, error) bool) bool {
+	for r := range fs.All() {
+		if !yield(r, nil) {
+			return false
+		}
+	}
+	for _, fe := range fs.Errors() {
+		if !yield(nil, &fe) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+//go:build go1.23
+// +build go1.23
+
+package kgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFetchesIterators(t *testing.T) {
+	r0, r1, r2 := new(Record), new(Record), new(Record)
+	errFetch := errors.New("fetch failed")
+	fs := Fetches{
+		{Topics: []FetchTopic{{Topic: "foo", Partitions: []FetchPartition{
+			{Partition: 0, Records: []*Record{r0, r1}},
+			{Partition: 1, Err: errFetch},
+		}}}},
+		{Topics: []FetchTopic{{Topic: "bar", Partitions: []FetchPartition{{Records: []*Record{r2}}}}}},
+	}
+
+	var got []*Record
+	for r := range fs.All() {
+		got = append(got, r)
+		if len(got) == 2 {
+			break
+		}
+	}
+	if len(got) != 2 || got[0] != r0 || got[1] != r1 {
+		t.Errorf("got records %v, exp the first two records", got)
+	}
+
+	var nrecs int
+	var errs []error
+	yieldFetches(fs, func(r *Record, err error) bool {
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			nrecs++
+		}
+		return true
+	})
+	var fe *FetchError
+	if nrecs != 3 || len(errs) != 1 || !errors.As(errs[0], &fe) || fe.Topic != "foo" || fe.Partition != 1 || !errors.Is(errs[0], errFetch) {
+		t.Errorf("got %d records and errs %v, exp 3 records and one foo p1 error", nrecs, errs)
+	}
+
+	cl, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var polled int
+	for _, err := range cl.PollRecordsSeq(ctx, 10) {
+		polled++
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got poll err %v, exp %v", err, context.Canceled)
+		}
+	}
+	if polled != 1 {
+		t.Errorf("got %d polled results, exp 1", polled)
+	}
+	for r, err := range cl.Records(ctx) {
+		t.Errorf("unexpected record %v (err %v) with a canceled context", r, err)
+	}
+	cl.Close()
+	for r, err := range cl.Records(context.Background()) {
+		t.Errorf("unexpected record %v (err %v) with a closed client", r, err)
+	}
+}
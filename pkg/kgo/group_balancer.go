@@ -189,6 +189,77 @@ func ParseConsumerSyncAssignment(assignment []byte) (map[string][]int32, error)
 	return m, nil
 }
 
+// ConsumerProtocolMetadata is the metadata that members of a "consumer"
+// protocol group send in JoinGroup, decoded from a kmsg.ConsumerMemberMetadata
+// into a friendlier form. This can be used to encode metadata in a custom
+// GroupBalancer's JoinGroupMetadata and to decode member metadata in its
+// MemberBalancer.
+type ConsumerProtocolMetadata struct {
+	// Topics are the topics the member is interested in.
+	Topics []string
+	// OwnedPartitions are the partitions the member currently owns, which
+	// cooperative balancers use to avoid unnecessary revocations.
+	OwnedPartitions map[string][]int32
+	// Generation is the group generation the member owned its partitions
+	// in, or -1 if unknown.
+	Generation int32
+	// Rack is the member's rack, if any, which balancers can use to assign
+	// partitions with replicas in the same rack.
+	Rack string
+	// UserData is balancer specific data.
+	UserData []byte
+}
+
+// AppendTo appends the metadata, encoded as the latest version of
+// kmsg.ConsumerMemberMetadata, to dst. Topics and owned partitions are sorted
+// in the encoding to avoid accidental rebalances (see JoinGroupMetadata in
+// GroupBalancer); the metadata itself is not modified.
+func (m *ConsumerProtocolMetadata) AppendTo(dst []byte) []byte {
+	meta := kmsg.NewConsumerMemberMetadata()
+	meta.Version = 3
+	meta.Topics = append([]string(nil), m.Topics...)
+	sort.Strings(meta.Topics)
+	meta.UserData = m.UserData
+	for topic, partitions := range m.OwnedPartitions {
+		owned := kmsg.NewConsumerMemberMetadataOwnedPartition()
+		owned.Topic = topic
+		owned.Partitions = append([]int32(nil), partitions...)
+		sort.Slice(owned.Partitions, func(i, j int) bool { return owned.Partitions[i] < owned.Partitions[j] })
+		meta.OwnedPartitions = append(meta.OwnedPartitions, owned)
+	}
+	sort.Slice(meta.OwnedPartitions, func(i, j int) bool { return meta.OwnedPartitions[i].Topic < meta.OwnedPartitions[j].Topic })
+	meta.Generation = m.Generation
+	if m.Rack != "" {
+		meta.Rack = kmsg.StringPtr(m.Rack)
+	}
+	return meta.AppendTo(dst)
+}
+
+// ParseConsumerProtocolMetadata decodes a kmsg.ConsumerMemberMetadata, such
+// as a member's ProtocolMetadata in a JoinGroupResponse. Fields that do not
+// exist in the encoded version are left empty, with Generation as -1.
+func ParseConsumerProtocolMetadata(src []byte) (ConsumerProtocolMetadata, error) {
+	meta := kmsg.NewConsumerMemberMetadata()
+	if err := meta.ReadFrom(src); err != nil {
+		return ConsumerProtocolMetadata{}, fmt.Errorf("unable to read member metadata: %v", err)
+	}
+	m := ConsumerProtocolMetadata{
+		Topics:     meta.Topics,
+		Generation: meta.Generation,
+		UserData:   meta.UserData,
+	}
+	if len(meta.OwnedPartitions) > 0 {
+		m.OwnedPartitions = make(map[string][]int32, len(meta.OwnedPartitions))
+		for _, owned := range meta.OwnedPartitions {
+			m.OwnedPartitions[owned.Topic] = append(m.OwnedPartitions[owned.Topic], owned.Partitions...)
+		}
+	}
+	if meta.Rack != nil {
+		m.Rack = *meta.Rack
+	}
+	return m, nil
+}
+
 // NewConsumerBalancer parses the each member's metadata as a
 // kmsg.ConsumerMemberMetadata and returns a ConsumerBalancer to use in balancing.
 //
@@ -0,0 +1,110 @@
+package kgo
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// SimulatedGroupMember is a member of a group in SimulateGroupBalance.
+type SimulatedGroupMember struct {
+	// MemberID is the member's ID, which must be unique in the group.
+	MemberID string
+	// InstanceID is the member's optional static instance ID.
+	InstanceID *string
+	// Topics are the topics the member is interested in.
+	Topics []string
+	// Owned is the member's current assignment, which is passed to the
+	// balancer's JoinGroupMetadata.
+	Owned map[string][]int32
+	// Balancer, if non-nil, is used to build this member's JoinGroup
+	// metadata rather than the balancer passed to SimulateGroupBalance.
+	// This can be used to simulate members that are configured
+	// differently, such as with different racks.
+	Balancer GroupBalancer
+}
+
+// SimulateGroupBalance simulates a group join and sync with the given
+// balancer and returns each member's resulting assignment, keyed by member ID.
+// This is meant for testing custom GroupBalancers without a broker.
+//
+// The simulation mirrors what the client does: each member's JoinGroup
+// metadata is built with JoinGroupMetadata, the group leader balances every
+// member with the balancer's MemberBalancer using the partition counts in
+// topics, and each member parses its own assignment with ParseSyncAssignment.
+// Topics that members are interested in but that are missing from topics are
+// not balanced, as if the topics did not exist.
+//
+// Repeated rebalances (for example, to check that a cooperative balancer
+// converges or that a balancer is sticky) can be simulated by passing each
+// member's returned assignment as Owned in the next call with the next
+// generation.
+func SimulateGroupBalance(
+	balancer GroupBalancer,
+	generation int32,
+	topics map[string]int32,
+	members []SimulatedGroupMember,
+) (map[string]map[string][]int32, error) {
+	joined := make([]kmsg.JoinGroupResponseMember, 0, len(members))
+	for _, m := range members {
+		interests := append([]string(nil), m.Topics...)
+		sort.Strings(interests)
+		owned := make(map[string][]int32, len(m.Owned))
+		for topic, partitions := range m.Owned {
+			partitions = append([]int32(nil), partitions...)
+			sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+			owned[topic] = partitions
+		}
+
+		memberBalancer := balancer
+		if m.Balancer != nil {
+			memberBalancer = m.Balancer
+		}
+
+		member := kmsg.NewJoinGroupResponseMember()
+		member.MemberID = m.MemberID
+		member.InstanceID = m.InstanceID
+		member.ProtocolMetadata = memberBalancer.JoinGroupMetadata(interests, owned, generation)
+		joined = append(joined, member)
+	}
+	sortJoinMembers(joined)
+
+	memberBalancer, interests, err := balancer.MemberBalancer(joined)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create group member balancer: %v", err)
+	}
+	topicPartitionCount := make(map[string]int32, len(interests))
+	for topic := range interests {
+		if partitions, exists := topics[topic]; exists {
+			topicPartitionCount[topic] = partitions
+		}
+	}
+
+	var into IntoSyncAssignment
+	if memberBalancerOrErr, ok := memberBalancer.(GroupMemberBalancerOrError); ok {
+		if into, err = memberBalancerOrErr.BalanceOrError(topicPartitionCount); err != nil {
+			return nil, err
+		}
+	} else {
+		into = memberBalancer.Balance(topicPartitionCount)
+	}
+
+	assignments := make(map[string]map[string][]int32, len(members))
+	for _, m := range members {
+		assignments[m.MemberID] = make(map[string][]int32)
+	}
+	for _, assignment := range into.IntoSyncAssignment() {
+		if _, exists := assignments[assignment.MemberID]; !exists {
+			return nil, fmt.Errorf("balancer assigned partitions to unknown member %q", assignment.MemberID)
+		}
+		assigned, err := balancer.ParseSyncAssignment(assignment.MemberAssignment)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse assignment for member %q: %v", assignment.MemberID, err)
+		}
+		if assigned != nil {
+			assignments[assignment.MemberID] = assigned
+		}
+	}
+	return assignments, nil
+}
@@ -1,6 +1,7 @@
 package kgo
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 
@@ -98,3 +99,114 @@ func Test_stickyAdjustCooperative(t *testing.T) {
 		t.Errorf("got plan != exp\ngot: %#v\nexp: %#v\n", inPlan, expPlan)
 	}
 }
+
+func TestConsumerProtocolMetadata(t *testing.T) {
+	in := ConsumerProtocolMetadata{
+		Topics:          []string{"b", "a"},
+		OwnedPartitions: map[string][]int32{"b": {2, 0}, "a": {1}},
+		Generation:      3,
+		Rack:            "rack1",
+		UserData:        []byte("data"),
+	}
+	encoded := in.AppendTo(nil)
+	if !reflect.DeepEqual(encoded, in.AppendTo(nil)) {
+		t.Error("encoding is not deterministic")
+	}
+	if in.Topics[0] != "b" || in.OwnedPartitions["b"][0] != 2 {
+		t.Error("encoding modified the input metadata")
+	}
+
+	got, err := ParseConsumerProtocolMetadata(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := ConsumerProtocolMetadata{
+		Topics:          []string{"a", "b"},
+		OwnedPartitions: map[string][]int32{"a": {1}, "b": {0, 2}},
+		Generation:      3,
+		Rack:            "rack1",
+		UserData:        []byte("data"),
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %+v != exp %+v", got, exp)
+	}
+
+	got, err = ParseConsumerProtocolMetadata(memberMetadataV0([]string{"a"}, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := (ConsumerProtocolMetadata{Topics: []string{"a"}, Generation: -1}); !reflect.DeepEqual(got, exp) {
+		t.Errorf("got v0 %+v != exp %+v", got, exp)
+	}
+}
+
+func TestSimulateGroupBalance(t *testing.T) {
+	topics := map[string]int32{"a": 6, "b": 3}
+	var members []SimulatedGroupMember
+	for i := 0; i < 3; i++ {
+		members = append(members, SimulatedGroupMember{
+			MemberID: fmt.Sprintf("m%d", i),
+			Topics:   []string{"a", "b", "missing"},
+		})
+	}
+
+	checkComplete := func(name string, assignments map[string]map[string][]int32) {
+		t.Helper()
+		seen := make(map[string]map[int32]string)
+		for member, assigned := range assignments {
+			for topic, partitions := range assigned {
+				if seen[topic] == nil {
+					seen[topic] = make(map[int32]string)
+				}
+				for _, p := range partitions {
+					if prior, ok := seen[topic][p]; ok {
+						t.Errorf("%s: %s/%d assigned to both %s and %s", name, topic, p, prior, member)
+					}
+					seen[topic][p] = member
+				}
+			}
+		}
+		for topic, n := range topics {
+			if int32(len(seen[topic])) != n {
+				t.Errorf("%s: got %d assigned partitions for %s, exp %d", name, len(seen[topic]), topic, n)
+			}
+		}
+	}
+
+	for _, balancer := range []GroupBalancer{
+		RangeBalancer(),
+		RoundRobinBalancer(),
+		StickyBalancer(),
+	} {
+		assignments, err := SimulateGroupBalance(balancer, 1, topics, members)
+		if err != nil {
+			t.Fatalf("%s: unexpected err: %v", balancer.ProtocolName(), err)
+		}
+		if len(assignments) != len(members) {
+			t.Errorf("%s: got %d assignments, exp %d", balancer.ProtocolName(), len(assignments), len(members))
+		}
+		checkComplete(balancer.ProtocolName(), assignments)
+	}
+
+	// A cooperative balancer converges over two rebalances when a member
+	// joins: the first revokes what moves, the second assigns it.
+	cooperative := CooperativeStickyBalancer()
+	assignments, err := SimulateGroupBalance(cooperative, 1, topics, members[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkComplete("initial", assignments)
+	for generation := int32(2); generation <= 3; generation++ {
+		next := append([]SimulatedGroupMember(nil), members...)
+		for i := range next {
+			next[i].Owned = assignments[next[i].MemberID]
+		}
+		if assignments, err = SimulateGroupBalance(cooperative, generation, topics, next); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkComplete("converged", assignments)
+	if len(assignments["m2"]) == 0 {
+		t.Error("new member was not assigned partitions after converging")
+	}
+}
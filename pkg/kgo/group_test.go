@@ -3,13 +3,68 @@ package kgo
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
 )
 
+func TestCommitMetadataContext(t *testing.T) {
+	newReq := func() *kmsg.OffsetCommitRequest {
+		req := kmsg.NewPtrOffsetCommitRequest()
+		for _, topic := range []string{"a", "b"} {
+			rt := kmsg.NewOffsetCommitRequestTopic()
+			rt.Topic = topic
+			for p := int32(0); p < 2; p++ {
+				rp := kmsg.NewOffsetCommitRequestTopicPartition()
+				rp.Partition = p
+				rt.Partitions = append(rt.Partitions, rp)
+			}
+			req.Topics = append(req.Topics, rt)
+		}
+		return req
+	}
+	run := func(ctx context.Context, req *kmsg.OffsetCommitRequest) error {
+		return ctx.Value(commitContextFn).(func(*kmsg.OffsetCommitRequest) error)(req)
+	}
+
+	var priorCalled bool
+	ctx := PreCommitFnContext(context.Background(), func(*kmsg.OffsetCommitRequest) error {
+		priorCalled = true
+		return nil
+	})
+	req := newReq()
+	if err := run(CommitMetadataContext(ctx, "meta"), req); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !priorCalled {
+		t.Error("prior pre-commit fn was not called")
+	}
+	for _, rt := range req.Topics {
+		for _, rp := range rt.Partitions {
+			if rp.Metadata == nil || *rp.Metadata != "meta" {
+				t.Errorf("t %s p %d: metadata not set", rt.Topic, rp.Partition)
+			}
+		}
+	}
+
+	errPrior := errors.New("prior")
+	ctx = PreCommitFnContext(context.Background(), func(*kmsg.OffsetCommitRequest) error { return errPrior })
+	req = newReq()
+	if err := run(CommitMetadataContext(ctx, "meta"), req); err != errPrior {
+		t.Errorf("got err %v != exp %v", err, errPrior)
+	}
+	if req.Topics[0].Partitions[0].Metadata != nil {
+		t.Error("metadata unexpectedly set after prior fn error")
+	}
+}
+
 // TestGroupETL tests:
 //
 // - producing a lot of messages to a single topic, ensuring that all messages
@@ -21,6 +76,125 @@ import (
 // order
 //
 // - all balancers
+func TestCommitCoalesce(t *testing.T) {
+	cl, err := NewClient(CommitCoalesceInterval(100 * time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+	g := &groupConsumer{cl: cl, cfg: &cl.cfg}
+
+	// Our pre-commit fn records every request that would be issued and
+	// fails it so that we never need a broker.
+	var (
+		mu      sync.Mutex
+		reqs    []map[int32]int64
+		errStop = errors.New("stop")
+	)
+	ctx := PreCommitFnContext(context.Background(), func(req *kmsg.OffsetCommitRequest) error {
+		offsets := make(map[int32]int64)
+		for _, rt := range req.Topics {
+			for _, rp := range rt.Partitions {
+				offsets[rp.Partition] = rp.Offset
+			}
+		}
+		mu.Lock()
+		reqs = append(reqs, offsets)
+		mu.Unlock()
+		return errStop
+	})
+	dones := make(chan error, 10)
+	onDone := func(_ *Client, _ *kmsg.OffsetCommitRequest, _ *kmsg.OffsetCommitResponse, err error) { dones <- err }
+	commit := func(async bool, offsets map[int32]int64) {
+		uncommitted := map[string]map[int32]EpochOffset{"foo": {}}
+		for p, o := range offsets {
+			uncommitted["foo"][p] = EpochOffset{-1, o}
+		}
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if async {
+			g.commitAsync(ctx, uncommitted, onDone)
+		} else {
+			g.commit(ctx, uncommitted, onDone)
+		}
+	}
+	wait := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			select {
+			case err := <-dones:
+				if err != errStop {
+					t.Fatalf("got commit err %v, exp %v", err, errStop)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for commit %d of %d", i+1, n)
+			}
+		}
+	}
+	check := func(exp ...map[int32]int64) {
+		t.Helper()
+		mu.Lock()
+		defer mu.Unlock()
+		if !reflect.DeepEqual(reqs, exp) {
+			t.Fatalf("got commits %v, exp %v", reqs, exp)
+		}
+	}
+
+	commit(true, map[int32]int64{0: 1}) // nothing recent: issued immediately
+	wait(1)
+	commit(true, map[int32]int64{0: 2, 1: 1}) // held...
+	commit(true, map[int32]int64{0: 3})       // ...and merged
+	check(map[int32]int64{0: 1})
+	wait(2)
+	check(map[int32]int64{0: 1}, map[int32]int64{0: 3, 1: 1})
+
+	commit(true, map[int32]int64{2: 1}) // held, then absorbed by a sync commit
+	commit(false, map[int32]int64{3: 1})
+	wait(2)
+	commit(true, map[int32]int64{4: 1}) // held, then flushed by a rebalance
+	g.flushPendingCommit()
+	wait(1)
+	check(map[int32]int64{0: 1}, map[int32]int64{0: 3, 1: 1}, map[int32]int64{2: 1, 3: 1}, map[int32]int64{4: 1})
+
+	time.Sleep(200 * time.Millisecond) // absorbed and flushed commits are not issued again
+	check(map[int32]int64{0: 1}, map[int32]int64{0: 3, 1: 1}, map[int32]int64{2: 1, 3: 1}, map[int32]int64{4: 1})
+}
+
+func TestSplitCommitRequest(t *testing.T) {
+	req := kmsg.NewPtrOffsetCommitRequest()
+	req.Group = "g"
+	for _, topic := range []struct {
+		name  string
+		parts int32
+	}{{"a", 3}, {"b", 1}, {"c", 4}} {
+		rt := kmsg.NewOffsetCommitRequestTopic()
+		rt.Topic = topic.name
+		for p := int32(0); p < topic.parts; p++ {
+			rp := kmsg.NewOffsetCommitRequestTopicPartition()
+			rp.Partition = p
+			rt.Partitions = append(rt.Partitions, rp)
+		}
+		req.Topics = append(req.Topics, rt)
+	}
+
+	var got []string
+	for _, split := range splitCommitRequest(req, 3) {
+		if split.Group != "g" {
+			t.Errorf("split lost group, got %q", split.Group)
+		}
+		var s string
+		for _, rt := range split.Topics {
+			for _, rp := range rt.Partitions {
+				s += fmt.Sprintf("%s%d", rt.Topic, rp.Partition)
+			}
+		}
+		got = append(got, s)
+	}
+	if exp := []string{"a0a1a2", "b0c0c1", "c2c3"}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("got splits %v, exp %v", got, exp)
+	}
+}
+
 func TestGroupETL(t *testing.T) {
 	t.Parallel()
 
@@ -3,6 +3,8 @@ package kgo
 import (
 	"net"
 	"time"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
 )
 
 ////////////////////////////////////////////////////////////////
@@ -102,12 +104,25 @@ type HookBrokerRead interface {
 // Note that if this is for a produce request with no acks, there will be no
 // read wait / time to read.
 type BrokerE2E struct {
-	// BytesWritten is the number of bytes written for this request.
+	// Version is the version of the request that was written, and thus
+	// the version of the response that was read.
+	Version int16
+	// CorrelationID is the correlation ID the request was written with.
+	// This can be used to correlate this hook with HookBrokerWrite and
+	// HookBrokerRead, or with broker side request logs.
+	//
+	// This is zero if the request failed before it could be serialized
+	// (i.e., if the client was closed while the request was throttled).
+	CorrelationID int32
+
+	// BytesWritten is the number of bytes written for this request, i.e.
+	// the size of the request including its four byte length prefix.
 	//
 	// This may not be the whole request if there was an error while writing.
 	BytesWritten int
 
-	// BytesRead is the number of bytes read for this requests's response.
+	// BytesRead is the number of bytes read for this requests's response,
+	// i.e. the size of the response including its four byte length prefix.
 	//
 	// This may not be the whole response if there was an error while
 	// reading, and this will be zero if there was a write error.
@@ -118,6 +133,14 @@ type BrokerE2E struct {
 	// written to the connection. This number is not included in the
 	// DurationE2E method.
 	WriteWait time.Duration
+	// QueueWait is the portion of WriteWait that this request spent queued
+	// in the client before the broker began handling it. The remainder of
+	// WriteWait is spent opening (and authenticating) a connection if
+	// necessary, waiting out any broker throttle, and waiting for the
+	// connection to be available for writing. Internal requests issued
+	// while initializing a connection (ApiVersions, SASL) are not queued
+	// and always have a zero QueueWait.
+	QueueWait time.Duration
 	// TimeToWrite is how long a request took to be written on the wire.
 	// This specifically tracks only how long conn.Write takes.
 	TimeToWrite time.Duration
@@ -185,6 +208,38 @@ type HookBrokerThrottle interface {
 	OnBrokerThrottle(meta BrokerMetadata, throttleInterval time.Duration, throttledAfterResponse bool)
 }
 
+// HookBrokerThrottleKey is called at the same time as HookBrokerThrottle, but
+// is additionally passed the key of the response that carried the throttle.
+// This can be used to track which requests are being throttled, e.g. whether
+// a produce or fetch quota is being hit.
+type HookBrokerThrottleKey interface {
+	// OnBrokerThrottleKey is passed the broker metadata, the key for the
+	// throttled response, the imposed throttling interval, and whether
+	// the throttle was applied before Kafka responded or after; see
+	// HookBrokerThrottle for more details.
+	OnBrokerThrottleKey(meta BrokerMetadata, key int16, throttleInterval time.Duration, throttledAfterResponse bool)
+}
+
+// HookBrokerRequestTrace is called with fully decoded requests and responses
+// if TraceRequests is enabled; without that option, this hook is never
+// called. This is meant for debugging protocol level issues.
+//
+// The request is decoded from the exact bytes the client wrote to the
+// broker, meaning requests the client builds internally (such as produce
+// requests) are visible as their kmsg type. SASLAuthBytes in
+// SASLAuthenticate requests and responses are cleared before calling this
+// hook.
+//
+// The request and response must not be modified, and the response must not
+// be retained past the hook call.
+type HookBrokerRequestTrace interface {
+	// OnBrokerRequestTrace is passed the broker metadata, the request
+	// that was written, the response that was read, and any error from
+	// writing or reading. The response is nil if the request errored or
+	// does not have a response (acks=0 produce requests).
+	OnBrokerRequestTrace(meta BrokerMetadata, req kmsg.Request, resp kmsg.Response, err error)
+}
+
 //////////
 // MISC //
 //////////
@@ -203,6 +258,29 @@ type HookGroupManageError interface {
 	OnGroupManageError(error)
 }
 
+// HookClientMetricsPush is called after the client pushes KIP-714 client
+// metrics to a broker, which the client does if a broker requests metrics
+// (see DisableClientMetrics).
+type HookClientMetricsPush interface {
+	// OnClientMetricsPush is passed the broker metadata, the metrics that
+	// were pushed, and any error from pushing them.
+	OnClientMetricsPush(meta BrokerMetadata, metrics []ClientMetric, err error)
+}
+
+// HookProducerIDUpdate is called whenever the client successfully loads a
+// new idempotent producer ID or bumps the epoch of its current ID (KIP-360).
+// Sequence numbers for every partition restart at zero after an update. This
+// can be used to correlate downstream deduplication with producer sessions;
+// see also the client's ProducerSession method.
+type HookProducerIDUpdate interface {
+	// OnProducerIDUpdate is passed the prior producer ID and epoch (both
+	// -1 on the initial load) and the new producer ID and epoch.
+	//
+	// This hook is called while the producer ID is being loaded, which
+	// blocks producing; it should return quickly.
+	OnProducerIDUpdate(priorID int64, priorEpoch int16, id int64, epoch int16)
+}
+
 ///////////////////////////////
 // PRODUCE & CONSUME BATCHES //
 ///////////////////////////////
@@ -302,10 +380,92 @@ type HookFetchBatchRead interface {
 	OnFetchBatchRead(meta BrokerMetadata, topic string, partition int32, metrics FetchBatchMetrics)
 }
 
+// ProduceLatencyMetrics tracks how long a successfully produced batch took to
+// produce.
+type ProduceLatencyMetrics struct {
+	// NumRecords is the number of records that were produced in this
+	// batch.
+	NumRecords int
+
+	// BufferLatency is how long the batch was buffered in the client: the
+	// time from the first record in the batch being produced (its
+	// Timestamp) until the produce request containing the batch was
+	// written. If the batch was retried, this includes all retries.
+	BufferLatency time.Duration
+
+	// BrokerLatency is the time from the produce request containing the
+	// batch being written until the client began handling the response.
+	BrokerLatency time.Duration
+}
+
+// HookProduceBatchLatency is called whenever a batch is known to be
+// successfully produced, with how long the batch took to produce. This hook
+// is not called if producing with acks=0, since the broker does not respond.
+type HookProduceBatchLatency interface {
+	// OnProduceBatchLatency is called per successful batch written to a
+	// topic partition.
+	OnProduceBatchLatency(meta BrokerMetadata, topic string, partition int32, metrics ProduceLatencyMetrics)
+}
+
+// FetchLatencyMetrics tracks the end-to-end latency of records polled from a
+// partition, from each record's timestamp until the record was polled.
+//
+// If records are produced with timestamps set by the producer (the default),
+// this is the latency from the record being produced to it being consumed.
+// If the topic uses LogAppendTime, this is the latency from the record being
+// written in Kafka to it being consumed.
+type FetchLatencyMetrics struct {
+	// NumRecords is the number of records polled from the partition.
+	NumRecords int
+
+	// MinLatency is the smallest latency of any polled record.
+	MinLatency time.Duration
+
+	// MaxLatency is the largest latency of any polled record.
+	MaxLatency time.Duration
+
+	// TotalLatency is the sum of all polled record latencies. The mean
+	// latency is TotalLatency / NumRecords.
+	TotalLatency time.Duration
+}
+
+// HookFetchPartitionLatency is called with the end-to-end latency of records
+// every time records are polled from a partition.
+//
+// Note that this hook may slow down high-volume consuming a bit.
+type HookFetchPartitionLatency interface {
+	// OnFetchPartitionLatency is called per partition in a poll that
+	// returned records.
+	OnFetchPartitionLatency(topic string, partition int32, metrics FetchLatencyMetrics)
+}
+
 ///////////////////////////////
 // PRODUCE & CONSUME RECORDS //
 ///////////////////////////////
 
+// HookProduceRecordIntercept is called for every record passed to Produce,
+// before the record is buffered.
+//
+// Intercept hooks form a chain: they are called in the order they were
+// provided to WithHooks, and each hook sees any modifications made by earlier
+// hooks. A hook can modify the record (for example, to add tenant headers or
+// to enforce a schema on the value), or it can reject the record by returning
+// an error. If a hook returns an error, later hooks are not called, the record
+// is not buffered, and the record's promise is called with the hook's error.
+//
+// Because a rejected record is never buffered, neither
+// HookProduceRecordBuffered nor HookProduceRecordUnbuffered is called for it.
+// To observe the final partition, offset, and error of records that are
+// produced, use HookProduceRecordUnbuffered.
+//
+// Note that this hook may slow down high-volume producing a bit.
+type HookProduceRecordIntercept interface {
+	// OnProduceRecordIntercept is passed a record that is about to be
+	// buffered, after the default topic (if any) has been set. Returning
+	// a non-nil error drops the record.
+	OnProduceRecordIntercept(*Record) error
+}
+
 // HookProduceRecordBuffered is called when a record is buffered internally in
 // the client from a call to Produce.
 //
@@ -358,6 +518,62 @@ type HookProduceRecordUnbuffered interface {
 	OnProduceRecordUnbuffered(*Record, error)
 }
 
+// ProduceAck describes a record that was successfully produced, for
+// HookProduceRecordAcked.
+type ProduceAck struct {
+	// Topic is the topic the record was produced to.
+	Topic string
+	// Partition is the partition the record was produced to.
+	Partition int32
+	// Offset is the offset the broker assigned to the record. If producing
+	// with NoAck, the broker does not reply and the offset is meaningless.
+	Offset int64
+	// Timestamp is the timestamp of the record.
+	Timestamp time.Time
+	// KeyHash is the murmur2 hash of the record key, which is the hash
+	// Kafka's default partitioner uses. This is zero if the key is nil.
+	KeyHash uint32
+}
+
+// HookProduceRecordAcked is called for every record that is successfully
+// produced, just before the record's promise is called. This can be used to
+// record what was written (for example, in an audit log) without wrapping
+// every produce promise. The ProduceAck does not retain the record, so it is
+// safe to keep after the promise is called.
+//
+// To only be called for a sample of records, see ProduceAckSampling.
+//
+// This hook may be called concurrently for records in different partitions.
+type HookProduceRecordAcked interface {
+	// OnProduceRecordAcked is passed the acknowledgement for a
+	// successfully produced record.
+	OnProduceRecordAcked(ProduceAck)
+}
+
+// HookFetchRecordIntercept is called for every record that is about to be
+// returned from polling, allowing records to be modified or filtered before
+// the application sees them.
+//
+// Intercept hooks form a chain: they are called in the order they were
+// provided to WithHooks, and each hook sees any modifications made by earlier
+// hooks. A hook can modify the record in place (for example, to decrypt or
+// decompress the value, or to add headers), or it can filter the record out by
+// returning false, in which case later hooks are not called and the record is
+// not returned from polling.
+//
+// Filtered records are still considered consumed: if using a group, offsets
+// are committed past them as though they were polled. If polling with a
+// maximum number of records, filtered records count toward that maximum.
+//
+// Intercept hooks are called while polling, serially with any other poll, so
+// hooks should be fast. Note that this hook will slow down high-volume
+// consuming a bit.
+type HookFetchRecordIntercept interface {
+	// OnFetchRecordIntercept is passed a record that is about to be
+	// returned from polling. Returning false drops the record.
+	OnFetchRecordIntercept(*Record) bool
+}
+
 // HookFetchRecordBuffered is called when a record is internally buffered after
 // fetching, ready to be polled.
 //
@@ -406,12 +622,21 @@ func implementsAnyHook(h Hook) bool {
 		HookBrokerRead,
 		HookBrokerE2E,
 		HookBrokerThrottle,
+		HookBrokerThrottleKey,
+		HookBrokerRequestTrace,
 		HookGroupManageError,
+		HookClientMetricsPush,
+		HookProducerIDUpdate,
 		HookProduceBatchWritten,
 		HookFetchBatchRead,
+		HookProduceBatchLatency,
+		HookFetchPartitionLatency,
+		HookProduceRecordIntercept,
 		HookProduceRecordBuffered,
 		HookProduceRecordPartitioned,
 		HookProduceRecordUnbuffered,
+		HookProduceRecordAcked,
+		HookFetchRecordIntercept,
 		HookFetchRecordBuffered,
 		HookFetchRecordUnbuffered:
 		return true
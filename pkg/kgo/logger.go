@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync/atomic"
 )
 
 // LogLevel designates which level the logger should log at.
@@ -97,28 +98,34 @@ func (b *basicLogger) Log(level LogLevel, msg string, keyvals ...any) {
 	b.dst.Write(buf.inner)
 }
 
-// nopLogger, the default logger, drops everything.
-type nopLogger struct{}
-
-func (*nopLogger) Level() LogLevel { return LogLevelNone }
-func (*nopLogger) Log(LogLevel, string, ...any) {
-}
-
 // wrappedLogger wraps the config logger for convenience at logging callsites.
+// The inner logger is stored atomically so that it can be swapped with
+// Reconfigure; by default, there is no inner logger and everything is dropped.
 type wrappedLogger struct {
-	inner Logger
+	inner atomic.Value // innerLogger
+}
+
+type innerLogger struct{ l Logger }
+
+func (w *wrappedLogger) store(l Logger) { w.inner.Store(innerLogger{l}) }
+
+func (w *wrappedLogger) load() Logger {
+	inner, _ := w.inner.Load().(innerLogger)
+	return inner.l
 }
 
 func (w *wrappedLogger) Level() LogLevel {
-	if w.inner == nil {
+	l := w.load()
+	if l == nil {
 		return LogLevelNone
 	}
-	return w.inner.Level()
+	return l.Level()
 }
 
 func (w *wrappedLogger) Log(level LogLevel, msg string, keyvals ...any) {
-	if w.Level() < level {
+	l := w.load()
+	if l == nil || l.Level() < level {
 		return
 	}
-	w.inner.Log(level, msg, keyvals...)
+	l.Log(level, msg, keyvals...)
 }
@@ -165,31 +165,73 @@ func (cl *Client) updateMetadataLoop() {
 	var consecutiveErrors int
 	var lastAt time.Time
 
+	// With LazyConnect, we do not update on our own until something
+	// first triggers an update.
+	idle := cl.cfg.lazyConnect
+
 	ticker := time.NewTicker(cl.cfg.metadataMaxAge)
 	defer ticker.Stop()
+
+	var regexRefresh <-chan time.Time
+	if cl.cfg.regex && cl.cfg.regexRefresh > 0 {
+		ticker := time.NewTicker(cl.cfg.regexRefresh)
+		defer ticker.Stop()
+		regexRefresh = ticker.C
+	}
+
+	var topicsRefresh <-chan time.Time
+	if cl.cfg.metadataTopicsMaxAge > 0 {
+		ticker := time.NewTicker(cl.cfg.metadataTopicsMaxAge)
+		defer ticker.Stop()
+		topicsRefresh = ticker.C
+	}
 loop:
 	for {
-		var now bool
+		var now, topicsOnly bool
 		select {
 		case <-cl.ctx.Done():
 			return
 		case <-ticker.C:
 			// We do not log on the standard update case.
+			if idle {
+				continue loop
+			}
+		case <-regexRefresh:
+			// Nor on the regex refresh case.
+			if idle {
+				continue loop
+			}
+		case <-topicsRefresh:
+			// Nor on the topics only refresh case.
+			if idle {
+				continue loop
+			}
+			topicsOnly = true
 		case why := <-cl.updateMetadataCh:
 			cl.cfg.logger.Log(LogLevelInfo, "metadata update triggered", "why", why)
+			idle = false
 		case why := <-cl.updateMetadataNowCh:
 			cl.cfg.logger.Log(LogLevelInfo, "immediate metadata update triggered", "why", why)
 			now = true
+			idle = false
 		case fn := <-cl.blockingMetadataFnCh:
 			fn()
 			continue loop
 		}
 
+		// We begin pushing client metrics once the client is in use,
+		// which avoids connecting on startup (no-op once started).
+		cl.maybeStartTelemetry()
+
 		var nowTries int
 	start:
 		nowTries++
 		if !now {
-			if wait := cl.cfg.metadataMinAge - time.Since(lastAt); wait > 0 {
+			minAge := cl.cfg.metadataMinAge
+			if topicsOnly {
+				minAge = cl.cfg.metadataTopicsMinAge
+			}
+			if wait := minAge - time.Since(lastAt); wait > 0 {
 				timer := time.NewTimer(wait)
 			prewait:
 				select {
@@ -211,12 +253,15 @@ loop:
 		// potential pile on now triggers.
 		time.Sleep(time.Until(lastAt.Add(10 * time.Millisecond)))
 
-		// Drain any refires that occurred during our waiting.
+		// Drain any refires that occurred during our waiting. A
+		// refire upgrades a topics only refresh to a full one.
 	out:
 		for {
 			select {
 			case <-cl.updateMetadataCh:
+				topicsOnly = false
 			case <-cl.updateMetadataNowCh:
+				topicsOnly = false
 			case fn := <-cl.blockingMetadataFnCh:
 				fn()
 			default:
@@ -224,7 +269,7 @@ loop:
 			}
 		}
 
-		retryWhy, err := cl.updateMetadata()
+		retryWhy, err := cl.updateMetadata(cl.cfg.regex && !topicsOnly, nil)
 		if retryWhy != nil || err != nil {
 			// If err is non-nil, the metadata request failed
 			// itself and already retried 3x; we do not loop more.
@@ -287,18 +332,64 @@ loop:
 	}
 }
 
+// RefreshMetadata immediately refreshes metadata for the given topics, or
+// for all topics the client is producing to or consuming if no topics are
+// given, returning once the refresh completes or the context is canceled.
+// Topics that the client is not producing to or consuming are ignored.
+//
+// This bypasses MetadataMinAge and MetadataTopicsMinAge, and never loads
+// metadata for every topic in the cluster, even if you are consuming with
+// regex. The returned error is non-nil only if the metadata request itself
+// failed; per topic and partition load errors are retried internally as
+// usual.
+func (cl *Client) RefreshMetadata(ctx context.Context, topics ...string) error {
+	var only map[string]struct{}
+	if len(topics) > 0 {
+		only = make(map[string]struct{}, len(topics))
+		for _, topic := range topics {
+			only[cl.nsTopic(topic)] = struct{}{}
+		}
+	}
+
+	done := make(chan error, 1)
+	fn := func() {
+		_, err := cl.updateMetadata(false, only)
+		if err == nil {
+			cl.metawait.signal()
+			cl.consumer.doOnMetadataUpdate()
+		}
+		done <- err
+	}
+	select {
+	case cl.blockingMetadataFnCh <- fn:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-cl.ctx.Done():
+		return ErrClientClosed
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-cl.ctx.Done():
+		return ErrClientClosed
+	}
+}
+
 // Updates all producer and consumer partition data, returning whether a new
-// update needs scheduling or if an error occurred.
+// update needs scheduling or if an error occurred. If all is true, this loads
+// every topic in the cluster; otherwise, this loads only the topics in use,
+// limited to only if only is non-nil.
 //
 // The producer and consumer use different topic maps and underlying
 // topicPartitionsData pointers, but we update those underlying pointers
 // equally.
-func (cl *Client) updateMetadata() (retryWhy multiUpdateWhy, err error) {
+func (cl *Client) updateMetadata(all bool, only map[string]struct{}) (retryWhy multiUpdateWhy, err error) {
 	var (
 		tpsProducerLoad = cl.producer.topics.load()
 		tpsConsumer     *topicsPartitions
 		groupExternal   *groupExternal
-		all             = cl.cfg.regex
 		reqTopics       []string
 	)
 	c := &cl.consumer
@@ -325,7 +416,9 @@ func (cl *Client) updateMetadata() (retryWhy multiUpdateWhy, err error) {
 		})
 		reqTopics = make([]string, 0, len(reqTopicsSet))
 		for topic := range reqTopicsSet {
-			reqTopics = append(reqTopics, topic)
+			if _, ok := only[topic]; only == nil || ok {
+				reqTopics = append(reqTopics, topic)
+			}
 		}
 	}
 
@@ -365,7 +458,7 @@ func (cl *Client) updateMetadata() (retryWhy multiUpdateWhy, err error) {
 			// metadata fn; this will wait for our current
 			// execution to finish then purge.
 			cl.cfg.logger.Log(LogLevelInfo, "regex consumer purging topics that were previously consumed because they are missing in a metadata response, we are assuming they are deleted", "topics", purgeTopics)
-			go cl.PurgeTopicsFromClient(purgeTopics...)
+			go cl.purgeTopicsFromClient(purgeTopics...)
 		}
 	}
 
@@ -406,7 +499,7 @@ func (cl *Client) updateMetadata() (retryWhy multiUpdateWhy, err error) {
 		for topic, priorParts := range m.priors {
 			newParts, exists := latest[topic]
 			if !exists {
-				if m.isProduce {
+				if _, ok := only[topic]; m.isProduce && (only == nil || ok) {
 					missingProduceTopics = append(missingProduceTopics, topic)
 				}
 				continue
@@ -472,6 +565,7 @@ type metadataPartition struct {
 	loadErr     int16
 	leader      int32
 	leaderEpoch int32
+	replicas    []int32
 	sns         sinkAndSource
 }
 
@@ -483,6 +577,7 @@ func (mp metadataPartition) newPartition(cl *Client, isProduce bool) *topicParti
 	p := &topicPartition{
 		loadErr:            kerr.ErrorForCode(mp.loadErr),
 		topicPartitionData: td,
+		replicas:           mp.replicas,
 	}
 	if isProduce {
 		p.records = &recBuf{
@@ -494,6 +589,8 @@ func (mp metadataPartition) newPartition(cl *Client, isProduce bool) *topicParti
 			failing:             mp.loadErr != 0,
 			sink:                mp.sns.sink,
 			topicPartitionData:  td,
+			partBytes:           newBufferedBytes(cl.cfg.maxPartBufferedBytes),
+			topicBytes:          cl.producer.topicBufferedBytes(mp.topic),
 		}
 	} else {
 		p.cursor = &cursor{
@@ -501,6 +598,9 @@ func (mp metadataPartition) newPartition(cl *Client, isProduce bool) *topicParti
 			topicID:            mp.topicID,
 			partition:          mp.partition,
 			keepControl:        cl.cfg.keepControl,
+			rawBatches:         cl.cfg.rawBatches,
+			poolBuffers:        cl.cfg.poolBuffers,
+			valueTransformer:   cl.cfg.valueTransformerFor(mp.topic),
 			cursorsIdx:         -1,
 			source:             mp.sns.source,
 			topicPartitionData: td,
@@ -509,6 +609,8 @@ func (mp metadataPartition) newPartition(cl *Client, isProduce bool) *topicParti
 				lastConsumedEpoch: -1, // required sentinel
 			},
 		}
+		p.cursor.position.Store(-1)
+		p.cursor.replicas.Store(mp.replicas)
 	}
 	return p
 }
@@ -601,6 +703,7 @@ func (cl *Client) fetchTopicMetadata(all bool, reqTopics []string) (map[string]*
 				if replica < 0 {
 					continue
 				}
+				mp.replicas = append(mp.replicas, replica)
 				if _, exists = cl.sinksAndSources[replica]; !exists {
 					cl.sinksAndSources[replica] = sinkAndSource{
 						sink:   cl.newSink(replica),
@@ -805,6 +908,9 @@ func (cl *Client) mergeTopicPartitions(
 				)
 			}
 		}
+		if !isProduce {
+			newTP.cursor.replicas.Store(newTP.replicas)
+		}
 	}
 
 	// For any partitions **not currently in use**, we need to add them to
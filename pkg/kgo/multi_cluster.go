@@ -0,0 +1,222 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// MultiClusterConsumer consumes from multiple clusters at once, fanning in
+// records from every cluster into one stream of fetches. This is useful when
+// migrating topics between clusters: while producers move from the old
+// cluster to the new one, consumers can read from both.
+//
+// Each cluster has its own client, created from that cluster's options, and
+// offsets are tracked and committed per cluster: commit functions on the
+// MultiClusterConsumer route each record to the client it was consumed from.
+// Use ClusterIndex to see which cluster a polled record came from.
+type MultiClusterConsumer struct {
+	cls []*Client
+}
+
+type clusterIndexKey struct{}
+
+// NewMultiClusterConsumer returns a MultiClusterConsumer that consumes with a
+// client for each input set of options. Each set of options must configure
+// the client to consume (i.e., with ConsumeTopics or ConsumePartitions).
+func NewMultiClusterConsumer(clusters ...[]Opt) (*MultiClusterConsumer, error) {
+	if len(clusters) == 0 {
+		return nil, errors.New("unable to create a multi cluster consumer with no clusters")
+	}
+	m := new(MultiClusterConsumer)
+	for _, opts := range clusters {
+		cl, err := NewClient(opts...)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.cls = append(m.cls, cl)
+	}
+	return m, nil
+}
+
+// Clients returns the client for each cluster, in the order the clusters were
+// passed to NewMultiClusterConsumer.
+func (m *MultiClusterConsumer) Clients() []*Client {
+	return append([]*Client(nil), m.cls...)
+}
+
+// ClusterIndex returns the index of the cluster a polled record was consumed
+// from, in the order the clusters were passed to NewMultiClusterConsumer, or
+// -1 if the record was not polled from a MultiClusterConsumer.
+func ClusterIndex(r *Record) int {
+	if r.Context == nil {
+		return -1
+	}
+	idx, ok := r.Context.Value(clusterIndexKey{}).(int)
+	if !ok {
+		return -1
+	}
+	return idx
+}
+
+// PollFetches polls all clusters concurrently and returns once any cluster
+// has data, returning everything that every cluster had ready. Every
+// returned record has its Context annotated such that ClusterIndex returns
+// the cluster it was consumed from. All Client.PollFetches documentation
+// applies.
+func (m *MultiClusterConsumer) PollFetches(ctx context.Context) Fetches {
+	return m.poll(ctx, func(ctx context.Context, cl *Client) Fetches { return cl.PollFetches(ctx) })
+}
+
+// PollRecords is like PollFetches, but polls up to maxPollRecords from each
+// cluster; see Client.PollRecords.
+func (m *MultiClusterConsumer) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
+	return m.poll(ctx, func(ctx context.Context, cl *Client) Fetches { return cl.PollRecords(ctx, maxPollRecords) })
+}
+
+func (m *MultiClusterConsumer) poll(ctx context.Context, fn func(context.Context, *Client) Fetches) Fetches {
+	pollCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Polling a client with a canceled context does not drop anything
+	// buffered, so once one cluster has data, we stop polling the others
+	// and keep whatever they had ready.
+	var (
+		wg      sync.WaitGroup
+		results = make([]Fetches, len(m.cls))
+	)
+	for i, cl := range m.cls {
+		wg.Add(1)
+		go func(i int, cl *Client) {
+			defer wg.Done()
+			fs := fn(pollCtx, cl)
+			results[i] = fs
+			if len(fs) > 0 {
+				cancel()
+			}
+		}(i, cl)
+	}
+	wg.Wait()
+
+	var fs Fetches
+	var closed int
+	for i, r := range results {
+		if r.IsClientClosed() || m.cls[i].ctx.Err() != nil {
+			closed++
+			continue
+		}
+		// Clients we canceled return a single fake fetch with our
+		// cancelation error, which we strip.
+		if err := r.Err0(); errors.Is(err, context.Canceled) && len(r) == 1 && len(r[0].Topics) == 1 && len(r[0].Topics[0].Partitions) == 1 {
+			continue
+		}
+		r.EachRecord(func(rec *Record) {
+			recCtx := rec.Context
+			if recCtx == nil {
+				recCtx = context.Background()
+			}
+			rec.Context = context.WithValue(recCtx, clusterIndexKey{}, i)
+		})
+		fs = append(fs, r...)
+	}
+	if closed == len(m.cls) {
+		return errFetch(ErrClientClosed)
+	}
+	// If the user's context was canceled, clients may still have returned
+	// data: polled data is consumed (and may be autocommitted), so we
+	// return it and only return the context error if nothing was polled.
+	if len(fs) == 0 {
+		if err := ctx.Err(); err != nil {
+			return errFetch(err)
+		}
+	}
+	return fs
+}
+
+// splitByCluster splits records by the cluster they were consumed from,
+// returning an error if any record was not polled from this consumer.
+func (m *MultiClusterConsumer) splitByCluster(rs []*Record) ([][]*Record, error) {
+	split := make([][]*Record, len(m.cls))
+	for _, r := range rs {
+		idx := ClusterIndex(r)
+		if idx < 0 || idx >= len(m.cls) {
+			return nil, errors.New("unable to commit a record that was not polled from the multi cluster consumer")
+		}
+		split[idx] = append(split[idx], r)
+	}
+	return split, nil
+}
+
+// CommitRecords commits each record with the client of the cluster the
+// record was consumed from; see Client.CommitRecords. Every cluster is
+// committed to even if committing to an earlier cluster fails, and the first
+// error is returned.
+func (m *MultiClusterConsumer) CommitRecords(ctx context.Context, rs ...*Record) error {
+	split, err := m.splitByCluster(rs)
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for i, rs := range split {
+		if len(rs) == 0 {
+			continue
+		}
+		if err := m.cls[i].CommitRecords(ctx, rs...); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// MarkCommitRecords marks each record to be committed with the client of the
+// cluster the record was consumed from; see Client.MarkCommitRecords. Records
+// that were not polled from this consumer are ignored.
+func (m *MultiClusterConsumer) MarkCommitRecords(rs ...*Record) {
+	for _, r := range rs {
+		if idx := ClusterIndex(r); idx >= 0 && idx < len(m.cls) {
+			m.cls[idx].MarkCommitRecords(r)
+		}
+	}
+}
+
+// CommitUncommittedOffsets commits uncommitted offsets on every cluster; see
+// Client.CommitUncommittedOffsets. The first error is returned.
+func (m *MultiClusterConsumer) CommitUncommittedOffsets(ctx context.Context) error {
+	return m.each(func(cl *Client) error { return cl.CommitUncommittedOffsets(ctx) })
+}
+
+// CommitMarkedOffsets commits marked offsets on every cluster; see
+// Client.CommitMarkedOffsets. The first error is returned.
+func (m *MultiClusterConsumer) CommitMarkedOffsets(ctx context.Context) error {
+	return m.each(func(cl *Client) error { return cl.CommitMarkedOffsets(ctx) })
+}
+
+// each calls fn concurrently for every client, returning the first error in
+// cluster order.
+func (m *MultiClusterConsumer) each(fn func(*Client) error) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(m.cls))
+	for i, cl := range m.cls {
+		wg.Add(1)
+		go func(i int, cl *Client) {
+			defer wg.Done()
+			errs[i] = fn(cl)
+		}(i, cl)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes every cluster's client.
+func (m *MultiClusterConsumer) Close() {
+	m.each(func(cl *Client) error {
+		cl.Close()
+		return nil
+	})
+}
@@ -0,0 +1,70 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMultiClusterConsumer(t *testing.T) {
+	m, err := NewMultiClusterConsumer(
+		[]Opt{SeedBrokers("old:9092"), ConsumeTopics("foo")},
+		[]Opt{SeedBrokers("new:9092"), ConsumeTopics("foo")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// Only the second cluster has something ready; polling returns it
+	// without waiting on the first cluster.
+	errInjected := errors.New("injected")
+	m.Clients()[1].consumer.addFakeReadyForDraining("foo", 0, errInjected, "test")
+	fs := m.PollFetches(context.Background())
+	if len(fs) != 1 || !errors.Is(fs.Err0(), errInjected) {
+		t.Errorf("got fetches %v, exp only the injected error", fs)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if fs := m.PollFetches(ctx); !errors.Is(fs.Err0(), context.Canceled) {
+		t.Errorf("got poll err %v, exp %v", fs.Err0(), context.Canceled)
+	}
+
+	// If the context is canceled while polling, data that one cluster
+	// already returned is kept rather than replaced with the context
+	// error: it was consumed from the client.
+	ctx, cancel = context.WithCancel(context.Background())
+	fs = m.poll(ctx, func(pollCtx context.Context, cl *Client) Fetches {
+		if cl == m.Clients()[0] {
+			cancel()
+			return Fetches{{Topics: []FetchTopic{{
+				Topic:      "foo",
+				Partitions: []FetchPartition{{Records: []*Record{{Topic: "foo"}}}},
+			}}}}
+		}
+		<-pollCtx.Done()
+		return errFetch(pollCtx.Err())
+	})
+	if rs := fs.Records(); len(rs) != 1 || ClusterIndex(rs[0]) != 0 || fs.Err0() != nil {
+		t.Errorf("got fetches %v after canceling mid-poll, exp only the polled record", fs)
+	}
+
+	r0 := &Record{Context: context.WithValue(context.Background(), clusterIndexKey{}, 0)}
+	r1 := &Record{Context: context.WithValue(context.Background(), clusterIndexKey{}, 1)}
+	if ClusterIndex(r0) != 0 || ClusterIndex(r1) != 1 || ClusterIndex(new(Record)) != -1 {
+		t.Error("cluster index did not match the record's cluster")
+	}
+	split, err := m.splitByCluster([]*Record{r1, r0, r1})
+	if err != nil || len(split[0]) != 1 || len(split[1]) != 2 {
+		t.Errorf("got split %v (err %v), exp one record for cluster 0 and two for cluster 1", split, err)
+	}
+	if err := m.CommitRecords(context.Background(), new(Record)); err == nil {
+		t.Error("unexpected success committing a record that was not polled")
+	}
+
+	m.Close()
+	if fs := m.PollFetches(context.Background()); !fs.IsClientClosed() {
+		t.Errorf("got poll err %v after close, exp %v", fs.Err0(), ErrClientClosed)
+	}
+}
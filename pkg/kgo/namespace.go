@@ -0,0 +1,152 @@
+package kgo
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// This file contains the client side topic namespacing configured with
+// TopicNamespace or TopicPrefix. Topics are mapped to their Kafka names
+// where the user passes topics into the client, and back where the client
+// hands topics to the user. Everything internal uses Kafka names.
+
+func (cl *Client) nsTopic(topic string) string {
+	if cl.cfg.topicToKafka == nil || topic == "" {
+		return topic
+	}
+	return cl.cfg.topicToKafka(topic)
+}
+
+func (cl *Client) unnsTopic(topic string) string {
+	if cl.cfg.topicFromKafka == nil || topic == "" {
+		return topic
+	}
+	return cl.cfg.topicFromKafka(topic)
+}
+
+func (cl *Client) nsTopics(topics []string) []string {
+	return remapTopics(topics, cl.cfg.topicToKafka)
+}
+
+func (cl *Client) unnsTopics(topics []string) []string {
+	return remapTopics(topics, cl.cfg.topicFromKafka)
+}
+
+func remapTopics(topics []string, fn func(string) string) []string {
+	if fn == nil || len(topics) == 0 {
+		return topics
+	}
+	remapped := make([]string, len(topics))
+	for i, topic := range topics {
+		remapped[i] = fn(topic)
+	}
+	return remapped
+}
+
+func (cl *Client) nsPartitions(tps map[string][]int32) map[string][]int32 {
+	return remapPartitions(tps, cl.cfg.topicToKafka)
+}
+
+func (cl *Client) unnsPartitions(tps map[string][]int32) map[string][]int32 {
+	return remapPartitions(tps, cl.cfg.topicFromKafka)
+}
+
+func remapPartitions(tps map[string][]int32, fn func(string) string) map[string][]int32 {
+	if fn == nil || tps == nil {
+		return tps
+	}
+	remapped := make(map[string][]int32, len(tps))
+	for topic, ps := range tps {
+		remapped[fn(topic)] = ps
+	}
+	return remapped
+}
+
+func (cl *Client) nsOffsets(offsets map[string]map[int32]EpochOffset) map[string]map[int32]EpochOffset {
+	return remapOffsets(offsets, cl.cfg.topicToKafka)
+}
+
+func (cl *Client) unnsOffsets(offsets map[string]map[int32]EpochOffset) map[string]map[int32]EpochOffset {
+	return remapOffsets(offsets, cl.cfg.topicFromKafka)
+}
+
+func remapOffsets(offsets map[string]map[int32]EpochOffset, fn func(string) string) map[string]map[int32]EpochOffset {
+	if fn == nil || offsets == nil {
+		return offsets
+	}
+	remapped := make(map[string]map[int32]EpochOffset, len(offsets))
+	for topic, ps := range offsets {
+		remapped[fn(topic)] = ps
+	}
+	return remapped
+}
+
+// unnsFetches maps all topics in polled fetches back to the user's names,
+// modifying the fetches and records in place.
+func (cl *Client) unnsFetches(fs Fetches) Fetches {
+	if cl.cfg.topicFromKafka == nil {
+		return fs
+	}
+	for i := range fs {
+		for j := range fs[i].Topics {
+			t := &fs[i].Topics[j]
+			if t.Topic == "" {
+				continue // injected errors have no topic
+			}
+			t.Topic = cl.cfg.topicFromKafka(t.Topic)
+			for k := range t.Partitions {
+				for _, r := range t.Partitions[k].Records {
+					r.Topic = t.Topic
+				}
+			}
+		}
+	}
+	return fs
+}
+
+// applyNamespace maps topics in the configuration to their Kafka names and
+// wraps the group partition callbacks to receive the user's names. This is
+// called once when creating a client.
+func (cfg *cfg) applyNamespace() {
+	to, from := cfg.topicToKafka, cfg.topicFromKafka
+	if to == nil {
+		return
+	}
+
+	if !cfg.regex && cfg.topics != nil {
+		topics := cfg.topics
+		cfg.topics = make(map[string]*regexp.Regexp, len(topics))
+		for topic, re := range topics {
+			cfg.topics[to(topic)] = re
+		}
+	}
+	if cfg.partitions != nil {
+		partitions := cfg.partitions
+		cfg.partitions = make(map[string]map[int32]Offset, len(partitions))
+		for topic, ps := range partitions {
+			cfg.partitions[to(topic)] = ps
+		}
+	}
+
+	wrap := func(fn func(context.Context, *Client, map[string][]int32)) func(context.Context, *Client, map[string][]int32) {
+		if fn == nil {
+			return nil
+		}
+		return func(ctx context.Context, cl *Client, tps map[string][]int32) {
+			fn(ctx, cl, remapPartitions(tps, from))
+		}
+	}
+	cfg.onAssigned = wrap(cfg.onAssigned)
+	cfg.onRevoked = wrap(cfg.onRevoked)
+	cfg.onLost = wrap(cfg.onLost)
+}
+
+// TopicPrefix returns the pair of functions for TopicNamespace that add
+// prefix to topics on their way to Kafka, and strip prefix from topics on
+// their way back. Topics from Kafka that do not have the prefix are returned
+// unchanged.
+func TopicPrefix(prefix string) (toKafka, fromKafka func(string) string) {
+	return func(topic string) string { return prefix + topic },
+		func(topic string) string { return strings.TrimPrefix(topic, prefix) }
+}
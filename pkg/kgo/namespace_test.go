@@ -0,0 +1,78 @@
+package kgo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestTopicNamespace(t *testing.T) {
+	to, from := TopicPrefix("tenant.")
+	if got := to("foo"); got != "tenant.foo" {
+		t.Errorf("got to %q, exp tenant.foo", got)
+	}
+	if got := from("tenant.foo"); got != "foo" {
+		t.Errorf("got from %q, exp foo", got)
+	}
+
+	var assigned map[string][]int32
+	cl, err := NewClient(
+		TopicNamespace(to, from),
+		ConsumeTopics("foo"),
+		ConsumerGroup("group"),
+		OnPartitionsAssigned(func(_ context.Context, _ *Client, tps map[string][]int32) { assigned = tps }),
+		MaxBufferedBytesPerTopic(10),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if _, ok := cl.cfg.topics["tenant.foo"]; !ok || len(cl.cfg.topics) != 1 {
+		t.Errorf("got consume topics %v, exp only tenant.foo", cl.cfg.topics)
+	}
+	cl.cfg.onAssigned(context.Background(), cl, map[string][]int32{"tenant.foo": {0}})
+	if exp := map[string][]int32{"foo": {0}}; !reflect.DeepEqual(assigned, exp) {
+		t.Errorf("got assigned %v, exp %v", assigned, exp)
+	}
+
+	if paused := cl.PauseFetchTopics("bar"); !reflect.DeepEqual(paused, []string{"bar"}) {
+		t.Errorf("got paused %v, exp [bar]", paused)
+	}
+	if !cl.consumer.loadPaused().has("tenant.bar", 0) {
+		t.Error("tenant.bar is not paused internally")
+	}
+	cl.ResumeFetchTopics("bar")
+	if paused := cl.PauseFetchTopics(); len(paused) != 0 {
+		t.Errorf("got paused %v after resuming, exp none", paused)
+	}
+
+	// The topic is full, so produced records fail immediately; the
+	// record is buffered under the Kafka name and returned with ours.
+	cl.producer.topicBufferedBytes("tenant.bar").add(10)
+	r := &Record{Topic: "bar"}
+	done := make(chan error, 1)
+	cl.TryProduce(context.Background(), r, func(_ *Record, err error) { done <- err })
+	if err := <-done; err != ErrMaxBuffered {
+		t.Errorf("got produce err %v, exp %v", err, ErrMaxBuffered)
+	}
+	if gotTopic := r.Topic; gotTopic != "bar" {
+		t.Errorf("got promised topic %q, exp bar", gotTopic)
+	}
+
+	rec := &Record{Topic: "tenant.foo"}
+	fs := cl.unnsFetches(Fetches{{Topics: []FetchTopic{
+		{Topic: "tenant.foo", Partitions: []FetchPartition{{Records: []*Record{rec}}}},
+		{Partitions: []FetchPartition{{Err: ErrClientClosed}}},
+	}}})
+	if fs[0].Topics[0].Topic != "foo" || rec.Topic != "foo" || fs[0].Topics[1].Topic != "" {
+		t.Errorf("fetches were not mapped back to application topics: %v", fs[0].Topics)
+	}
+}
+
+func TestTopicNamespaceValidate(t *testing.T) {
+	to, _ := TopicPrefix("tenant.")
+	if _, err := NewClient(TopicNamespace(to, nil)); err == nil {
+		t.Error("unexpected success with only a to function")
+	}
+}
@@ -72,6 +72,56 @@ type TopicBackupIter interface {
 	Rem() int
 }
 
+// TopicClusterPartitioner is an optional extension interface to
+// TopicPartitioner that can partition with information about the cluster,
+// such as where partition leaders are, which rack each leader is in, and how
+// many records are buffered for each partition. This allows for locality
+// aware partitioning (preferring leaders in the client's rack) and load aware
+// partitioning.
+//
+// If a partitioner implements this interface, neither the Partition function
+// nor the PartitionByBackup function will be called.
+type TopicClusterPartitioner interface {
+	TopicPartitioner
+
+	// PartitionByCluster is similar to Partition, but has an additional
+	// cluster argument that can be used to inspect each of the n
+	// partitions by index. The cluster argument is only valid for the
+	// duration of this call.
+	PartitionByCluster(r *Record, n int, cluster ClusterPartitions) int
+}
+
+// ClusterPartitions provides information about the partitions that a record
+// can be partitioned to.
+type ClusterPartitions interface {
+	// Rack returns the client's rack, as configured with the Rack option.
+	Rack() string
+	// Partition returns information about the partition at index i,
+	// which must be in [0, n).
+	Partition(i int) PartitionInfo
+}
+
+// PartitionInfo is information about a partition, for use in a
+// TopicClusterPartitioner.
+type PartitionInfo struct {
+	// Partition is the partition number.
+	Partition int32
+	// Leader is the broker that leads the partition. If the client does
+	// not know the leader's metadata, only the Leader.NodeID field is set.
+	Leader BrokerMetadata
+	// LeaderEpoch is the partition's leader epoch.
+	LeaderEpoch int32
+	// BufferedRecords is the number of records currently buffered for the
+	// partition, including records that are being produced.
+	BufferedRecords int64
+	// ProduceLatency is a moving average of how long recent produce
+	// requests containing this partition took, from being written until a
+	// response was received. Failed requests count as taking at least the
+	// ProduceRequestTimeout. This is zero if nothing has been produced to
+	// the partition yet.
+	ProduceLatency time.Duration
+}
+
 ////////////
 // SIMPLE // - BasicConsistent, Manual, RoundRobin
 ////////////
@@ -186,6 +236,32 @@ func LeastBackupPartitioner() Partitioner {
 	return new(leastBackupPartitioner)
 }
 
+// clusterPartitionsInput is the ClusterPartitions implementation that is
+// passed to a TopicClusterPartitioner.
+type clusterPartitionsInput struct {
+	cl      *Client
+	mapping []*topicPartition
+}
+
+func (i *clusterPartitionsInput) Rack() string { return i.cl.cfg.rack }
+
+func (i *clusterPartitionsInput) Partition(idx int) PartitionInfo {
+	p := i.mapping[idx]
+	info := PartitionInfo{
+		Partition:       p.records.partition,
+		Leader:          BrokerMetadata{NodeID: p.leader},
+		LeaderEpoch:     p.leaderEpoch,
+		BufferedRecords: p.records.buffered.Load(),
+		ProduceLatency:  time.Duration(p.records.latency.Load()),
+	}
+	i.cl.brokersMu.RLock()
+	if b := findBroker(i.cl.brokers, p.leader); b != nil {
+		info.Leader = b.meta
+	}
+	i.cl.brokersMu.RUnlock()
+	return info
+}
+
 type (
 	leastBackupInput struct{ mapping []*topicPartition }
 
@@ -324,22 +400,7 @@ func (p *uniformBytesTopicPartitioner) PartitionByBackup(r *Record, n int, backu
 		return p.u.hasher(r.Key, n)
 	}
 
-	l := 1 + // attributes, int8 unused
-		1 + // ts delta, 1 minimum (likely 2 or 3)
-		1 + // offset delta, likely 1
-		kbin.VarintLen(int32(len(r.Key))) +
-		len(r.Key) +
-		kbin.VarintLen(int32(len(r.Value))) +
-		len(r.Value) +
-		kbin.VarintLen(int32(len(r.Headers))) // varint array len headers
-
-	for _, h := range r.Headers {
-		l += kbin.VarintLen(int32(len(h.Key))) +
-			len(h.Key) +
-			kbin.VarintLen(int32(len(h.Value))) +
-			len(h.Value)
-	}
-
+	l := uniformBytesRecordLen(r)
 	p.bytes += l
 	if p.bytes >= p.u.bytes {
 		p.bytes = l
@@ -396,6 +457,127 @@ func (p *uniformBytesTopicPartitioner) PartitionByBackup(r *Record, n int, backu
 	return p.onPart
 }
 
+// uniformBytesRecordLen approximates the number of bytes r adds to a batch.
+func uniformBytesRecordLen(r *Record) int {
+	l := 1 + // attributes, int8 unused
+		1 + // ts delta, 1 minimum (likely 2 or 3)
+		1 + // offset delta, likely 1
+		kbin.VarintLen(int32(len(r.Key))) +
+		len(r.Key) +
+		kbin.VarintLen(int32(len(r.Value))) +
+		len(r.Value) +
+		kbin.VarintLen(int32(len(r.Headers))) // varint array len headers
+
+	for _, h := range r.Headers {
+		l += kbin.VarintLen(int32(len(h.Key))) +
+			len(h.Key) +
+			kbin.VarintLen(int32(len(h.Value))) +
+			len(h.Value)
+	}
+	return l
+}
+
+/////////////////////
+// ADAPTIVE STICKY //
+/////////////////////
+
+// AdaptiveStickyPartitioner is a UniformBytesPartitioner that also steers
+// records away from slow partitions. Like UniformBytesPartitioner with
+// adaptive enabled, this returns the same partition until 'bytes' is hit, at
+// which point it chooses a new partition weighted by the inverse of the
+// records buffered for each partition. This additionally weighs each
+// partition by the inverse of its recent produce latency (see
+// PartitionInfo.ProduceLatency), so that a partition whose produce requests
+// take ten times longer is chosen roughly ten times less often.
+//
+// This lets keyless traffic automatically move away from degraded brokers:
+// a broker that slows down or starts timing out both backs up its partitions
+// and raises their latency, and it regains traffic as it recovers.
+//
+// If keys is true, this uses standard hashing based on record key for records
+// with non-nil keys. hasher is optional; if nil, the default hasher murmur2
+// (Kafka's default).
+func AdaptiveStickyPartitioner(bytes int, keys bool, hasher PartitionerHasher) Partitioner {
+	if hasher == nil {
+		hasher = KafkaHasher(murmur2)
+	}
+	return &adaptiveStickyPartitioner{
+		bytes,
+		keys,
+		hasher,
+	}
+}
+
+type (
+	adaptiveStickyPartitioner struct {
+		bytes  int
+		keys   bool
+		hasher PartitionerHasher
+	}
+
+	adaptiveStickyTopicPartitioner struct {
+		a      adaptiveStickyPartitioner
+		bytes  int
+		onPart int
+		rng    *rand.Rand
+
+		weights []float64
+	}
+)
+
+func (a *adaptiveStickyPartitioner) ForTopic(string) TopicPartitioner {
+	return &adaptiveStickyTopicPartitioner{
+		a:      *a,
+		onPart: -1,
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (p *adaptiveStickyTopicPartitioner) RequiresConsistency(r *Record) bool {
+	return p.a.keys && r.Key != nil
+}
+func (*adaptiveStickyTopicPartitioner) Partition(*Record, int) int { panic("unreachable") }
+
+func (p *adaptiveStickyTopicPartitioner) PartitionByCluster(r *Record, n int, cluster ClusterPartitions) int {
+	if p.a.keys && r.Key != nil {
+		return p.a.hasher(r.Key, n)
+	}
+
+	l := uniformBytesRecordLen(r)
+	p.bytes += l
+	if p.bytes >= p.a.bytes {
+		p.bytes = l
+		p.onPart = -1
+	}
+
+	if p.onPart >= 0 && p.onPart < n {
+		return p.onPart
+	}
+
+	// Each partition's weight is 1 / (buffered+1) / (latency_ms+1); we
+	// pick the partition our random scaled pick lands on, exactly as in
+	// the adaptive UniformBytesPartitioner.
+	p.weights = p.weights[:0]
+	var t float64
+	for i := 0; i < n; i++ {
+		info := cluster.Partition(i)
+		ms := float64(info.ProduceLatency) / float64(time.Millisecond)
+		w := 1 / float64(info.BufferedRecords+1) / (ms + 1)
+		t += w
+		p.weights = append(p.weights, w)
+	}
+	pick := p.rng.Float64() * t
+	p.onPart = n - 1 // guard floating rounding problems
+	for i, w := range p.weights {
+		pick -= w
+		if pick <= 0 {
+			p.onPart = i
+			break
+		}
+	}
+	return p.onPart
+}
+
 /////////////////////
 // STICKY & COMPAT // - Sticky, Kafka (custom hash), Sarama (custom hash)
 /////////////////////
@@ -3,7 +3,9 @@ package kgo
 import (
 	"bytes"
 	"hash/crc32"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/twmb/franz-go/pkg/kbin"
 	"github.com/twmb/franz-go/pkg/kmsg"
@@ -424,3 +426,165 @@ func BenchmarkAppendBatch(b *testing.B) {
 		})
 	}
 }
+
+func TestTopicCompressionOverride(t *testing.T) {
+	t.Parallel()
+	cfg, _, def, topics, err := validateCfg(
+		ProducerBatchCompression(GzipCompression()),
+		ProducerTopicCompression("raw", NoCompression()),
+		ProducerTopicCompression("logs", Lz4Compression()),
+	)
+	if err != nil {
+		t.Fatalf("unexpected validate err: %v", err)
+	}
+	if len(cfg.topicCompression) != 2 || len(topics) != 2 {
+		t.Fatalf("got %d configured and %d built topic compressors, exp 2", len(cfg.topicCompression), len(topics))
+	}
+
+	ourReq := produceRequest{
+		version:          7,
+		acks:             -1,
+		timeout:          1000,
+		hasHook:          true,
+		compressor:       def,
+		topicCompressors: topics,
+	}
+	for _, topic := range []string{"default", "raw", "logs"} {
+		ourReq.batches.addSeqBatch(topic, 0, seqRecBatch{
+			recBatch: &recBatch{
+				records: []promisedRec{{
+					Record: &Record{Value: bytes.Repeat([]byte("v"), 1000)},
+				}},
+			},
+		})
+	}
+	ourReq.AppendTo(nil)
+
+	for topic, exp := range map[string]uint8{
+		"default": 1,
+		"raw":     0,
+		"logs":    3,
+	} {
+		if got := ourReq.metrics[topic][0].CompressionType; got != exp {
+			t.Errorf("topic %s: got compression type %d != exp %d", topic, got, exp)
+		}
+	}
+
+	if _, _, _, _, err := validateCfg(ProducerTopicCompression("bad", CompressionCodec{codec: 5})); err == nil {
+		t.Error("expected error for invalid topic compression")
+	}
+}
+
+func TestRecBatchPackByAffinity(t *testing.T) {
+	cl, _ := NewClient()
+	defer cl.Close()
+
+	// We use enough records that offset deltas change encoded length, and
+	// we interleave keys so that most records move.
+	b := (&recBuf{cl: cl}).newRecordBatch()
+	start := time.Now()
+	for i := 0; i < 200; i++ {
+		r := &Record{
+			Key:       []byte{"ab"[i%2]},
+			Value:     []byte(strconv.Itoa(i)),
+			Timestamp: start.Add(time.Duration(i) * time.Millisecond),
+		}
+		nums := b.calculateRecordNumbers(r)
+		b.appendRecord(promisedRec{Record: r}, nums)
+		r.setLengthAndTimestampDelta(nums.lengthField, nums.tsDelta)
+	}
+	b.packByAffinity(KeyAffinity)
+
+	raw, _ := seqRecBatch{recBatch: b}.appendTo(nil, 8, -1, -1, false, nil)
+	if l := int32(len(raw)); l != b.wireLength {
+		t.Fatalf("got batch wire length %d != tracked %d", l, b.wireLength)
+	}
+	var kbatch kmsg.RecordBatch
+	if err := kbatch.ReadFrom(raw[4:]); err != nil {
+		t.Fatalf("unable to read batch: %v", err)
+	}
+
+	var prior kmsg.Record
+	in := kbatch.Records
+	for i := 0; i < int(kbatch.NumRecords); i++ {
+		var r kmsg.Record
+		length, n := kbin.Varint(in)
+		if err := r.ReadFrom(in[:n+int(length)]); err != nil {
+			t.Fatalf("record %d: unable to read: %v", i, err)
+		}
+		in = in[n+int(length):]
+
+		if r.OffsetDelta != int32(i) {
+			t.Errorf("record %d: got offset delta %d", i, r.OffsetDelta)
+		}
+		exp := "a"
+		if i >= 100 {
+			exp = "b"
+		}
+		if string(r.Key) != exp {
+			t.Errorf("record %d: got key %s != exp %s", i, r.Key, exp)
+		}
+		if i%100 > 0 && r.TimestampDelta <= prior.TimestampDelta {
+			t.Errorf("record %d: timestamp delta %d is not after prior %d, records with the same key were reordered", i, r.TimestampDelta, prior.TimestampDelta)
+		}
+		prior = r
+	}
+	if len(in) != 0 {
+		t.Errorf("got %d leftover bytes after reading records", len(in))
+	}
+}
+
+func TestRecBatchPackByAffinityVarintBoundary(t *testing.T) {
+	cl, _ := NewClient()
+	defer cl.Close()
+
+	// The first record's length field is 63 at offset delta 0; moved to
+	// offset delta 99, the offset delta takes one more byte, the length
+	// field becomes 64, and the varint of the length field takes two
+	// bytes rather than one.
+	b := (&recBuf{cl: cl}).newRecordBatch()
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		r := &Record{Key: []byte("a"), Timestamp: start}
+		if i == 0 {
+			r.Key = []byte("b")
+			r.Value = bytes.Repeat([]byte("v"), 56)
+		}
+		nums := b.calculateRecordNumbers(r)
+		if i == 0 && nums.lengthField != 63 {
+			t.Fatalf("got first record length %d != exp 63", nums.lengthField)
+		}
+		b.appendRecord(promisedRec{Record: r}, nums)
+		r.setLengthAndTimestampDelta(nums.lengthField, nums.tsDelta)
+	}
+	before := b.wireLength
+	b.packByAffinity(KeyAffinity)
+	if b.wireLength != before+1 {
+		t.Errorf("got wire length %d after packing, exp %d", b.wireLength, before+1)
+	}
+
+	raw, _ := seqRecBatch{recBatch: b}.appendTo(nil, 8, -1, -1, false, nil)
+	if l := int32(len(raw)); l != b.wireLength {
+		t.Fatalf("got batch wire length %d != tracked %d", l, b.wireLength)
+	}
+	var kbatch kmsg.RecordBatch
+	if err := kbatch.ReadFrom(raw[4:]); err != nil {
+		t.Fatalf("unable to read batch: %v", err)
+	}
+	if int(kbatch.Length) != len(raw)-4-12 {
+		t.Errorf("got batch length %d != exp %d", kbatch.Length, len(raw)-4-12)
+	}
+	var err error
+	recs := kbatch.Records
+	for i := 0; i < int(kbatch.NumRecords) && err == nil; i++ {
+		var r kmsg.Record
+		length, n := kbin.Varint(recs)
+		if err = r.ReadFrom(recs[:n+int(length)]); err == nil && r.OffsetDelta != int32(i) {
+			t.Errorf("record %d: got offset delta %d", i, r.OffsetDelta)
+		}
+		recs = recs[n+int(length):]
+	}
+	if err != nil || len(recs) != 0 {
+		t.Errorf("got err %v, %d leftover bytes after reading records", err, len(recs))
+	}
+}
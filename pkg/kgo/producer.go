@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -25,12 +26,16 @@ type producer struct {
 	// Hooks exist behind a pointer because likely they are not used.
 	// We only take up one byte vs. 6.
 	hooks *struct {
+		intercept   []HookProduceRecordIntercept
 		buffered    []HookProduceRecordBuffered
 		partitioned []HookProduceRecordPartitioned
 		unbuffered  []HookProduceRecordUnbuffered
+		acked       []HookProduceRecordAcked
 	}
+	ackSeq atomicI64 // counts successful records for ProduceAckSampling
 
 	hasHookBatchWritten bool
+	hasHookBatchLatency bool
 
 	// unknownTopics buffers all records for topics that are not loaded.
 	// The map is to a pointer to a slice for reasons documented in
@@ -46,12 +51,56 @@ type producer struct {
 	// recBuf could be created and records sent to while we are flushing.
 	flushing atomicI32 // >0 if flushing, can Flush many times concurrently
 
+	// topicFlushes is >0 if FlushTopics or FlushPartitions is running;
+	// flushingTopics counts how many flushes are running per topic.
+	topicFlushes     atomicI32
+	flushingTopicsMu sync.Mutex
+	flushingTopics   map[string]int
+
 	aborting atomicI32 // >0 if aborting, can abort many times concurrently
 
 	idMu       sync.Mutex
 	idVersion  int16
 	waitBuffer chan struct{}
 
+	// overLimit is the number of records that were admitted into the
+	// buffer before MaxBufferedRecords was lowered and that are over the
+	// new limit. Finishing one of these records does not wake a producer
+	// waiting for buffer space. It is only modified with overLimitMu.
+	overLimitMu sync.Mutex
+	overLimit   atomicI64
+
+	// topicBytes tracks buffered bytes per topic if MaxBufferedBytesPerTopic
+	// is set; recBufs share the tracker for their topic.
+	topicBytesMu sync.Mutex
+	topicBytes   map[string]*bufferedBytes
+
+	// prioBytes tracks buffered bytes per record priority if
+	// MaxBufferedBytesPerPriority is set. hasPriorities is set once any
+	// record with a non-zero priority is produced, after which sinks
+	// order partitions by priority when draining.
+	prioBytesMu   sync.Mutex
+	prioBytes     map[int8]*bufferedBytes
+	hasPriorities atomicBool
+
+	// rateBytes and rateRecords limit the global produce rate if
+	// ProduceRateLimit is set, and topicRates limit the per topic rate
+	// if ProduceRateLimitPerTopic is set.
+	rateBytes    *tokenBucket
+	rateRecords  *tokenBucket
+	topicRatesMu sync.Mutex
+	topicRates   map[string][2]*tokenBucket // bytes, records
+
+	// hasExpiry is set once any record with a produce expiry (see
+	// WithRecordExpiry) is produced, after which sinks check for
+	// expired records when draining.
+	hasExpiry atomicBool
+
+	// maxMsgBytes caches each topic's max.message.bytes if
+	// ProducerValidateMaxMessageBytes is used.
+	maxMsgBytesMu sync.Mutex
+	maxMsgBytes   map[string]*topicMaxMsgBytes
+
 	// mu and c are used for flush and drain notifications; mu is used for
 	// a few other tight locks.
 	mu sync.Mutex
@@ -60,6 +109,11 @@ type producer struct {
 	batchPromises ringBatchPromise
 	promisesMu    sync.Mutex
 
+	// unflushed collects records that fail while CloseFlushing is
+	// running; it is non-nil only while collecting and is guarded by
+	// promisesMu.
+	unflushed *[]*Record
+
 	txnMu sync.Mutex
 	inTxn bool
 
@@ -105,18 +159,26 @@ func (p *producer) init(cl *Client) {
 		err:   errReloadProducerID,
 	})
 	p.c = sync.NewCond(&p.mu)
+	p.rateBytes = newTokenBucket(cl.cfg.rateBytes)
+	p.rateRecords = newTokenBucket(cl.cfg.rateRecords)
 
 	inithooks := func() {
 		if p.hooks == nil {
 			p.hooks = &struct {
+				intercept   []HookProduceRecordIntercept
 				buffered    []HookProduceRecordBuffered
 				partitioned []HookProduceRecordPartitioned
 				unbuffered  []HookProduceRecordUnbuffered
+				acked       []HookProduceRecordAcked
 			}{}
 		}
 	}
 
 	cl.cfg.hooks.each(func(h Hook) {
+		if h, ok := h.(HookProduceRecordIntercept); ok {
+			inithooks()
+			p.hooks.intercept = append(p.hooks.intercept, h)
+		}
 		if h, ok := h.(HookProduceRecordBuffered); ok {
 			inithooks()
 			p.hooks.buffered = append(p.hooks.buffered, h)
@@ -129,9 +191,16 @@ func (p *producer) init(cl *Client) {
 			inithooks()
 			p.hooks.unbuffered = append(p.hooks.unbuffered, h)
 		}
+		if h, ok := h.(HookProduceRecordAcked); ok {
+			inithooks()
+			p.hooks.acked = append(p.hooks.acked, h)
+		}
 		if _, ok := h.(HookProduceBatchWritten); ok {
 			p.hasHookBatchWritten = true
 		}
+		if _, ok := h.(HookProduceBatchLatency); ok {
+			p.hasHookBatchLatency = true
+		}
 	})
 }
 
@@ -379,8 +448,27 @@ func (cl *Client) produce(
 	if r.Topic == "" {
 		r.Topic = cl.cfg.defaultProduceTopic
 	}
+	r.producing.store(true)
 
 	p := &cl.producer
+	if p.hooks != nil && len(p.hooks.intercept) > 0 {
+		for _, h := range p.hooks.intercept {
+			if err := h.OnProduceRecordIntercept(r); err != nil {
+				p.promiseRecordBeforeBuf(promisedRec{ctx, promise, r}, err)
+				return
+			}
+		}
+	}
+
+	// Rate limiting happens before the record is buffered: a record that
+	// fails here was never counted in bufferedRecords and never had the
+	// buffered hook called.
+	if err := p.waitRateLimit(ctx, r, block); err != nil {
+		p.promiseRecordBeforeBuf(promisedRec{ctx, promise, r}, err)
+		return
+	}
+
+	r.Topic = cl.nsTopic(r.Topic)
 	if p.hooks != nil && len(p.hooks.buffered) > 0 {
 		for _, h := range p.hooks.buffered {
 			h.OnProduceRecordBuffered(r)
@@ -396,13 +484,13 @@ func (cl *Client) produce(
 		return
 	}
 
-	if p.bufferedRecords.Add(1) > cl.cfg.maxBufferedRecords {
+	if p.bufferedRecords.Add(1) > cl.cfg.maxBufferedRecords.load() {
 		// If the client ctx cancels or the produce ctx cancels, we
 		// need to un-count our buffering of this record. We also need
 		// to drain a slot from the waitBuffer chan, which could be
 		// sent to right when we are erroring.
 		drainBuffered := func(err error) {
-			p.promiseRecord(promisedRec{ctx, promise, r}, err)
+			p.promiseBatch(batchPromise{recs: []promisedRec{{ctx, promise, r}}, err: err, waited: true})
 			<-p.waitBuffer
 		}
 		if !block || cl.cfg.manualFlushing {
@@ -420,7 +508,389 @@ func (cl *Client) produce(
 		}
 	}
 
-	cl.partitionRecord(promisedRec{ctx, promise, r})
+	if tb := p.topicBufferedBytes(r.Topic); tb != nil && tb.over() {
+		if !block || cl.cfg.manualFlushing {
+			p.promiseRecord(promisedRec{ctx, promise, r}, ErrMaxBuffered)
+			return
+		}
+		if err := tb.wait(ctx, cl.ctx); err != nil {
+			p.promiseRecord(promisedRec{ctx, promise, r}, err)
+			return
+		}
+	}
+
+	if r.Priority != 0 {
+		p.hasPriorities.Store(true)
+	}
+	if _, ok := recordExpiry(r.Context); ok {
+		p.hasExpiry.Store(true)
+	}
+	if pb := p.prioBufferedBytes(r.Priority); pb != nil && pb.over() {
+		if !block || cl.cfg.manualFlushing {
+			p.promiseRecord(promisedRec{ctx, promise, r}, ErrMaxBuffered)
+			return
+		}
+		if err := pb.wait(ctx, cl.ctx); err != nil {
+			p.promiseRecord(promisedRec{ctx, promise, r}, err)
+			return
+		}
+	}
+
+	recBuf := cl.partitionRecord(promisedRec{ctx, promise, r})
+
+	// The record may already be finished, so we cannot look at it. We
+	// wait on the partition it was buffered to, if any. Canceling waiting
+	// does not fail the record: it is already buffered.
+	if recBuf != nil && recBuf.partBytes != nil && block && !cl.cfg.manualFlushing {
+		recBuf.partBytes.wait(ctx, cl.ctx)
+	}
+}
+
+// bufferedBytes tracks the record bytes buffered in a partition or topic
+// against a limit, allowing producers to wait until the bytes drop below the
+// limit. All methods are safe to call on a nil tracker.
+type bufferedBytes struct {
+	limit int64
+
+	mu      sync.Mutex
+	n       int64
+	drained chan struct{} // closed and cleared once n drops below limit
+}
+
+func newBufferedBytes(limit int64) *bufferedBytes {
+	if limit <= 0 {
+		return nil
+	}
+	return &bufferedBytes{limit: limit}
+}
+
+func (b *bufferedBytes) add(n int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.n += n
+}
+
+func (b *bufferedBytes) sub(n int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.n -= n
+	if b.n < b.limit && b.drained != nil {
+		close(b.drained)
+		b.drained = nil
+	}
+}
+
+func (b *bufferedBytes) over() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.n >= b.limit
+}
+
+// wait blocks until the buffered bytes are below the limit, returning an
+// error if either context is done first.
+func (b *bufferedBytes) wait(ctx, clientCtx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	for {
+		b.mu.Lock()
+		if b.n < b.limit {
+			b.mu.Unlock()
+			return nil
+		}
+		if b.drained == nil {
+			b.drained = make(chan struct{})
+		}
+		drained := b.drained
+		b.mu.Unlock()
+
+		select {
+		case <-drained:
+		case <-clientCtx.Done():
+			return ErrClientClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// setMaxBufferedRecords changes the max buffered records on a live client.
+//
+// If the limit is lowered below the number of records already admitted into
+// the buffer, the records over the new limit are tracked in overLimit and
+// waiting producers are not woken until enough records finish that the
+// buffer is under the new limit. If the limit is raised, we wake producers
+// that are waiting for buffer space and are now within the new limit.
+func (p *producer) setMaxBufferedRecords(n int64) {
+	p.overLimitMu.Lock()
+	defer p.overLimitMu.Unlock()
+
+	prior := p.cl.cfg.maxBufferedRecords.load()
+	buffered := p.bufferedRecords.Load()
+	admitted := prior + p.overLimit.Load()
+	if buffered < admitted {
+		admitted = buffered
+	}
+	waiting := buffered - admitted
+
+	p.cl.cfg.maxBufferedRecords.store(n)
+	if admitted > n {
+		p.overLimit.Store(admitted - n)
+		return
+	}
+	p.overLimit.Store(0)
+	if wake := n - admitted; waiting > wake {
+		waiting = wake
+	}
+	for ; waiting > 0; waiting-- {
+		p.waitBuffer <- struct{}{}
+	}
+}
+
+// finishOverLimit returns whether a finished record was one of the records
+// over a lowered MaxBufferedRecords, in which case no waiting producer should
+// be woken.
+func (p *producer) finishOverLimit() bool {
+	if p.overLimit.Load() <= 0 {
+		return false
+	}
+	p.overLimitMu.Lock()
+	defer p.overLimitMu.Unlock()
+	if p.overLimit.Load() <= 0 {
+		return false
+	}
+	p.overLimit.Add(-1)
+	return true
+}
+
+// topicBufferedBytes returns the buffered bytes tracker for a topic, or nil
+// if MaxBufferedBytesPerTopic is not set.
+func (p *producer) topicBufferedBytes(topic string) *bufferedBytes {
+	limit := p.cl.cfg.maxTopicBufferedBytes
+	if limit <= 0 {
+		return nil
+	}
+	p.topicBytesMu.Lock()
+	defer p.topicBytesMu.Unlock()
+	b := p.topicBytes[topic]
+	if b == nil {
+		if p.topicBytes == nil {
+			p.topicBytes = make(map[string]*bufferedBytes)
+		}
+		b = newBufferedBytes(limit)
+		p.topicBytes[topic] = b
+	}
+	return b
+}
+
+// prioBufferedBytes returns the buffered bytes tracker for a record priority,
+// or nil if MaxBufferedBytesPerPriority is not set for the priority.
+func (p *producer) prioBufferedBytes(priority int8) *bufferedBytes {
+	limit := p.cl.cfg.maxPrioBufferedBytes[priority]
+	if limit <= 0 {
+		return nil
+	}
+	p.prioBytesMu.Lock()
+	defer p.prioBytesMu.Unlock()
+	b := p.prioBytes[priority]
+	if b == nil {
+		if p.prioBytes == nil {
+			p.prioBytes = make(map[int8]*bufferedBytes)
+		}
+		b = newBufferedBytes(limit)
+		p.prioBytes[priority] = b
+	}
+	return b
+}
+
+// tokenBucket is a token bucket that refills at rate tokens per second and
+// holds up to one second's worth of tokens. Taking tokens can put the bucket
+// into debt, which is how we allow taking more than a full bucket at once.
+// All methods are safe to call on a nil bucket.
+type tokenBucket struct {
+	rate float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate int64) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		rate:   float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+func (b *tokenBucket) refillLocked(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.rate {
+			b.tokens = b.rate
+		}
+		b.last = now
+	}
+}
+
+// take takes n tokens, returning how long to wait until the bucket is no
+// longer in debt. If noDebt is true, this never waits: tokens are only taken
+// if the bucket has enough or is full, and this returns -1 if tokens were not
+// taken.
+func (b *tokenBucket) take(n int64, now time.Time, noDebt bool) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refillLocked(now)
+	if noDebt && b.tokens < float64(n) && b.tokens < b.rate {
+		return -1
+	}
+	b.tokens -= float64(n)
+	if b.tokens >= 0 || noDebt {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// give returns tokens that were taken but not used.
+func (b *tokenBucket) give(n int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens += float64(n)
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+}
+
+// topicRateLimits returns the bytes and records token buckets for a topic,
+// which are nil if ProduceRateLimitPerTopic is not set.
+func (p *producer) topicRateLimits(topic string) (bytes, records *tokenBucket) {
+	cfg := &p.cl.cfg
+	if cfg.topicRateBytes <= 0 && cfg.topicRateRecords <= 0 {
+		return nil, nil
+	}
+	p.topicRatesMu.Lock()
+	defer p.topicRatesMu.Unlock()
+	buckets, ok := p.topicRates[topic]
+	if !ok {
+		if p.topicRates == nil {
+			p.topicRates = make(map[string][2]*tokenBucket)
+		}
+		buckets = [2]*tokenBucket{newTokenBucket(cfg.topicRateBytes), newTokenBucket(cfg.topicRateRecords)}
+		p.topicRates[topic] = buckets
+	}
+	return buckets[0], buckets[1]
+}
+
+// waitRateLimit takes a record's bytes and count from every produce rate
+// limit, waiting until the record is within all limits if block is true.
+// If we cannot or do not wait, or if waiting is canceled, the tokens are
+// returned and this returns an error to fail the record with.
+func (p *producer) waitRateLimit(ctx context.Context, r *Record, block bool) error {
+	cfg := &p.cl.cfg
+	if cfg.rateBytes <= 0 && cfg.rateRecords <= 0 && cfg.topicRateBytes <= 0 && cfg.topicRateRecords <= 0 {
+		return nil
+	}
+
+	topicBytes, topicRecords := p.topicRateLimits(r.Topic)
+	var (
+		n       = recordBufferedBytes(r)
+		now     = time.Now()
+		noDebt  = !block || cfg.manualFlushing || cfg.rateLimitFail
+		buckets = [4]*tokenBucket{p.rateBytes, p.rateRecords, topicBytes, topicRecords}
+		takes   = [4]int64{n, 1, n, 1}
+		wait    time.Duration
+	)
+	giveBack := func(upTo int) {
+		for i := 0; i < upTo; i++ {
+			buckets[i].give(takes[i])
+		}
+	}
+	for i, b := range buckets {
+		d := b.take(takes[i], now, noDebt)
+		if d < 0 {
+			giveBack(i)
+			return ErrRateLimited
+		}
+		if d > wait {
+			wait = d
+		}
+	}
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-p.cl.ctx.Done():
+		giveBack(len(buckets))
+		return ErrClientClosed
+	case <-ctx.Done():
+		giveBack(len(buckets))
+		return ctx.Err()
+	}
+}
+
+// recordBufferedBytes is the size of a record for buffered byte limits:
+// the length of the key, value, and all header keys and values.
+func recordBufferedBytes(r *Record) int64 {
+	n := int64(len(r.Key) + len(r.Value))
+	for _, h := range r.Headers {
+		n += int64(len(h.Key) + len(h.Value))
+	}
+	return n
+}
+
+type recordExpiryKey struct{}
+
+// WithRecordExpiry returns a copy of ctx that, when used as a produced
+// record's Context, sets a deadline for the record to be sent by. If the
+// record is still buffered and has not been sent by the deadline, it is
+// removed from its batch and failed with ErrRecordExpired. Records that have
+// already been sent are not expired, even if they need to be retried.
+//
+// Unlike a context deadline, which fails the entire batch a record is in once
+// the batch's first record's context is done, this expires only the record
+// itself. Expiry is checked when the client is about to send a batch, so
+// records can be failed slightly after their deadline. This is useful for
+// request-scoped events that become useless after a latency budget, while
+// RecordDeliveryTimeout can remain as a global upper bound.
+//
+// If ctx is nil, context.Background is used.
+func WithRecordExpiry(ctx context.Context, deadline time.Time) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, recordExpiryKey{}, deadline)
+}
+
+// recordExpiry returns the deadline set with WithRecordExpiry, if any.
+func recordExpiry(ctx context.Context) (time.Time, bool) {
+	if ctx == nil {
+		return time.Time{}, false
+	}
+	deadline, ok := ctx.Value(recordExpiryKey{}).(time.Time)
+	return deadline, ok
 }
 
 type batchPromise struct {
@@ -431,6 +901,11 @@ type batchPromise struct {
 	partition  int32
 	recs       []promisedRec
 	err        error
+
+	beforeBuf bool // if true, the records were never buffered (nor counted as buffered)
+	waited    bool // if true, the record was counted as buffered but failed while waiting for buffer space
+
+	flushed chan struct{} // if non-nil, closed once all prior promises are finished
 }
 
 func (p *producer) promiseBatch(b batchPromise) {
@@ -443,6 +918,13 @@ func (p *producer) promiseRecord(pr promisedRec, err error) {
 	p.promiseBatch(batchPromise{recs: []promisedRec{pr}, err: err})
 }
 
+// promiseRecordBeforeBuf fails a record that was rejected before it was
+// buffered; the promise is called in order with all other promises, but no
+// unbuffered hooks are called and the buffered count is not decremented.
+func (p *producer) promiseRecordBeforeBuf(pr promisedRec, err error) {
+	p.promiseBatch(batchPromise{recs: []promisedRec{pr}, err: err, beforeBuf: true})
+}
+
 func (p *producer) finishPromises(b batchPromise) {
 	cl := p.cl
 	var more bool
@@ -455,13 +937,21 @@ start:
 		pr.ProducerID = b.pid
 		pr.ProducerEpoch = b.epoch
 		pr.Attrs = b.attrs
-		cl.finishRecordPromise(pr, b.err)
+		cl.finishRecordPromise(pr, b.err, b.beforeBuf, b.waited)
 		b.recs[i] = promisedRec{}
 	}
 	p.promisesMu.Unlock()
 	if cap(b.recs) > 4 {
 		cl.prsPool.put(b.recs)
 	}
+	if b.flushed != nil {
+		close(b.flushed)
+	}
+	if p.topicFlushes.Load() > 0 {
+		p.mu.Lock()
+		p.mu.Unlock() //nolint:gocritic,staticcheck // We use the lock as a barrier, unlocking immediately is safe.
+		p.c.Broadcast()
+	}
 
 	b, more = p.batchPromises.dropPeek()
 	if more {
@@ -469,22 +959,46 @@ start:
 	}
 }
 
-func (cl *Client) finishRecordPromise(pr promisedRec, err error) {
+func (cl *Client) finishRecordPromise(pr promisedRec, err error, beforeBuf, waited bool) {
 	p := &cl.producer
 
+	// Records collected for CloseFlushing are returned to the user, so
+	// we keep them marked as producing: Reuse in the promise is a no-op
+	// and does not pool a record that we are returning. CloseFlushing
+	// clears the mark once it stops collecting.
+	if err != nil && p.unflushed != nil {
+		*p.unflushed = append(*p.unflushed, pr.Record)
+	} else {
+		pr.Record.producing.store(false)
+	}
+
+	if beforeBuf {
+		pr.promise(pr.Record, err)
+		return
+	}
+
 	if p.hooks != nil && len(p.hooks.unbuffered) > 0 {
 		for _, h := range p.hooks.unbuffered {
 			h.OnProduceRecordUnbuffered(pr.Record, err)
 		}
 	}
+	pr.Record.Topic = cl.unnsTopic(pr.Record.Topic)
+
+	if err != nil {
+		cl.maybeDeadLetterFailed(pr.Record, err)
+	} else if p.hooks != nil && len(p.hooks.acked) > 0 {
+		cl.hookProduceAcked(pr.Record)
+	}
 
 	// We call the promise before finishing the record; this allows users
 	// of Flush to know that all buffered records are completely done
 	// before Flush returns.
 	pr.promise(pr.Record, err)
 
+	// A record that failed while waiting for buffer space was never
+	// admitted, so it cannot be one of the records over a lowered limit.
 	buffered := p.bufferedRecords.Add(-1)
-	if buffered >= cl.cfg.maxBufferedRecords {
+	if (waited || !p.finishOverLimit()) && buffered >= cl.cfg.maxBufferedRecords.load() {
 		p.waitBuffer <- struct{}{}
 	} else if buffered == 0 && p.flushing.Load() > 0 {
 		p.mu.Lock()
@@ -493,23 +1007,62 @@ func (cl *Client) finishRecordPromise(pr promisedRec, err error) {
 	}
 }
 
+// hookProduceAcked calls HookProduceRecordAcked for a successfully produced
+// record, if the record is sampled.
+func (cl *Client) hookProduceAcked(r *Record) {
+	p := &cl.producer
+	if n := cl.cfg.ackSampling; n > 1 && (p.ackSeq.Add(1)-1)%n != 0 {
+		return
+	}
+	ack := ProduceAck{
+		Topic:     r.Topic,
+		Partition: r.Partition,
+		Offset:    r.Offset,
+		Timestamp: r.Timestamp,
+	}
+	if r.Key != nil {
+		ack.KeyHash = murmur2(r.Key)
+	}
+	for _, h := range p.hooks.acked {
+		h.OnProduceRecordAcked(ack)
+	}
+}
+
 // partitionRecord loads the partitions for a topic and produce to them. If
 // the topic does not currently exist, the record is buffered in unknownTopics
 // for a metadata update to deal with.
-func (cl *Client) partitionRecord(pr promisedRec) {
+//
+// This returns the record buffer the record was partitioned to, if any.
+func (cl *Client) partitionRecord(pr promisedRec) *recBuf {
 	parts, partsData := cl.partitionsForTopicProduce(pr)
 	if parts == nil { // saved in unknownTopics
-		return
+		return nil
 	}
-	cl.doPartitionRecord(parts, partsData, pr)
+	return cl.doPartitionRecord(parts, partsData, pr)
 }
 
 // doPartitionRecord is separate so that metadata updates that load unknown
 // partitions can call this directly.
-func (cl *Client) doPartitionRecord(parts *topicPartitions, partsData *topicPartitionsData, pr promisedRec) {
+func (cl *Client) doPartitionRecord(parts *topicPartitions, partsData *topicPartitionsData, pr promisedRec) *recBuf {
 	if partsData.loadErr != nil && !kerr.IsRetriable(partsData.loadErr) {
 		cl.producer.promiseRecord(pr, partsData.loadErr)
-		return
+		return nil
+	}
+	if t := cl.cfg.valueTransformerFor(pr.Topic); t != nil {
+		value, err := t.ProduceValue(pr.Record)
+		if err != nil {
+			cl.producer.promiseRecord(pr, fmt.Errorf("unable to transform record value: %w", err))
+			return nil
+		}
+		pr.Value = value
+	}
+	if cl.cfg.validateMaxMsgBytes {
+		if max := cl.producer.maxMessageBytes(pr.Topic); max > 0 {
+			if size := singleRecordBatchLength(pr.Record); size > max {
+				cl.producer.promiseRecord(pr, &ErrRecordTooLarge{pr.Topic, size, max})
+				return nil
+			}
+		}
 	}
 
 	parts.partsMu.Lock()
@@ -524,23 +1077,34 @@ func (cl *Client) doPartitionRecord(parts *topicPartitions, partsData *topicPart
 	}
 	if len(mapping) == 0 {
 		cl.producer.promiseRecord(pr, errors.New("unable to partition record due to no usable partitions"))
-		return
+		return nil
 	}
 
-	var pick int
+	tcp, _ := parts.partitioner.(TopicClusterPartitioner)
 	tlp, _ := parts.partitioner.(TopicBackupPartitioner)
-	if tlp != nil {
-		if parts.lb == nil {
-			parts.lb = new(leastBackupInput)
+	partitionPick := func() int {
+		switch {
+		case tcp != nil:
+			if parts.cp == nil {
+				parts.cp = &clusterPartitionsInput{cl: cl}
+			}
+			parts.cp.mapping = mapping
+			return tcp.PartitionByCluster(pr.Record, len(mapping), parts.cp)
+		case tlp != nil:
+			if parts.lb == nil {
+				parts.lb = new(leastBackupInput)
+			}
+			parts.lb.mapping = mapping
+			return tlp.PartitionByBackup(pr.Record, len(mapping), parts.lb)
+		default:
+			return parts.partitioner.Partition(pr.Record, len(mapping))
 		}
-		parts.lb.mapping = mapping
-		pick = tlp.PartitionByBackup(pr.Record, len(mapping), parts.lb)
-	} else {
-		pick = parts.partitioner.Partition(pr.Record, len(mapping))
 	}
+
+	pick := partitionPick()
 	if pick < 0 || pick >= len(mapping) {
 		cl.producer.promiseRecord(pr, fmt.Errorf("invalid record partitioning choice of %d from %d available", pick, len(mapping)))
-		return
+		return nil
 	}
 
 	partition := mapping[pick]
@@ -551,20 +1115,121 @@ func (cl *Client) doPartitionRecord(parts *topicPartitions, partsData *topicPart
 	if !processed {
 		onNewBatch.OnNewBatch()
 
-		if tlp != nil {
-			parts.lb.mapping = mapping
-			pick = tlp.PartitionByBackup(pr.Record, len(mapping), parts.lb)
-		} else {
-			pick = parts.partitioner.Partition(pr.Record, len(mapping))
-		}
+		pick = partitionPick()
 
 		if pick < 0 || pick >= len(mapping) {
 			cl.producer.promiseRecord(pr, fmt.Errorf("invalid record partitioning choice of %d from %d available", pick, len(mapping)))
-			return
+			return nil
 		}
 		partition = mapping[pick]
 		partition.records.bufferRecord(pr, false) // KIP-480
 	}
+	return partition.records
+}
+
+// maxMessageBytes returns the topic's max.message.bytes, or a non-positive
+// number if it is not yet known. The first call for a topic begins loading the
+// config in the background, as does the first call once the config is stale.
+func (p *producer) maxMessageBytes(topic string) int32 {
+	p.maxMsgBytesMu.Lock()
+	defer p.maxMsgBytesMu.Unlock()
+	if p.maxMsgBytes == nil {
+		p.maxMsgBytes = make(map[string]*topicMaxMsgBytes)
+	}
+	m, ok := p.maxMsgBytes[topic]
+	if !ok {
+		m = &topicMaxMsgBytes{max: -1}
+		p.maxMsgBytes[topic] = m
+	}
+	if !m.loading && (m.max < 0 || time.Since(m.loadedAt) > p.cl.cfg.metadataMaxAge) {
+		m.loading = true
+		go p.loadMaxMessageBytes(topic)
+	}
+	return m.max
+}
+
+// topicMaxMsgBytes is a topic's cached max.message.bytes. The config is
+// reloaded as often as metadata is (see MetadataMaxAge), or sooner if a broker
+// rejects a batch with MESSAGE_TOO_LARGE, so that changes to the topic config
+// are picked up. We keep validating with the prior value while reloading.
+type topicMaxMsgBytes struct {
+	max      int32 // -1 if not yet loaded, 0 if it could not be loaded
+	loadedAt time.Time
+	loading  bool
+}
+
+// forgetMaxMessageBytes marks a topic's max.message.bytes as stale, so that it
+// is reloaded the next time a record is produced to the topic. This is called
+// if a broker rejects a batch as too large, which means the topic's config was
+// lowered since we loaded it.
+func (p *producer) forgetMaxMessageBytes(topic string) {
+	p.maxMsgBytesMu.Lock()
+	defer p.maxMsgBytesMu.Unlock()
+	if m, ok := p.maxMsgBytes[topic]; ok {
+		m.loadedAt = time.Time{}
+	}
+}
+
+func (p *producer) loadMaxMessageBytes(topic string) {
+	cl := p.cl
+	max, err := cl.describeMaxMessageBytes(topic)
+
+	p.maxMsgBytesMu.Lock()
+	defer p.maxMsgBytesMu.Unlock()
+	m := p.maxMsgBytes[topic]
+	m.loading = false
+	switch {
+	case err == nil:
+		m.max, m.loadedAt = max, time.Now()
+	case kerr.IsRetriable(err) && m.max < 0:
+		// We have never loaded the config; we retry the next time a
+		// record is produced to the topic.
+		cl.cfg.logger.Log(LogLevelInfo, "unable to load topic max.message.bytes, retrying on the next produce", "topic", topic, "err", err)
+	case kerr.IsRetriable(err):
+		// We keep our prior value and retry once it is stale again.
+		cl.cfg.logger.Log(LogLevelInfo, "unable to reload topic max.message.bytes, continuing to use the prior value", "topic", topic, "max_message_bytes", m.max, "err", err)
+		m.loadedAt = time.Now()
+	default:
+		cl.cfg.logger.Log(LogLevelWarn, "unable to load topic max.message.bytes, records to this topic will not be validated", "topic", topic, "err", err)
+		m.max, m.loadedAt = 0, time.Now()
+	}
+}
+
+func (cl *Client) describeMaxMessageBytes(topic string) (int32, error) {
+	req := kmsg.NewPtrDescribeConfigsRequest()
+	rr := kmsg.NewDescribeConfigsRequestResource()
+	rr.ResourceType = kmsg.ConfigResourceTypeTopic
+	rr.ResourceName = topic
+	rr.ConfigNames = []string{"max.message.bytes"}
+	req.Resources = append(req.Resources, rr)
+
+	resp, err := req.RequestWith(cl.ctx, cl)
+	if err != nil {
+		return 0, err
+	}
+	return maxMessageBytesFromResp(topic, resp)
+}
+
+func maxMessageBytesFromResp(topic string, resp *kmsg.DescribeConfigsResponse) (int32, error) {
+	for _, r := range resp.Resources {
+		if r.ResourceName != topic {
+			continue
+		}
+		if err := kerr.ErrorForCode(r.ErrorCode); err != nil {
+			return 0, err
+		}
+		for _, c := range r.Configs {
+			if c.Name != "max.message.bytes" || c.Value == nil {
+				continue
+			}
+			max, err := strconv.ParseInt(*c.Value, 10, 32)
+			if err != nil {
+				return 0, fmt.Errorf("unable to parse max.message.bytes %q: %w", *c.Value, err)
+			}
+			return int32(max), nil
+		}
+	}
+	return 0, errors.New("describe configs response is missing max.message.bytes")
 }
 
 // ProducerID returns, loading if necessary, the current producer ID and epoch.
@@ -592,6 +1257,64 @@ func (cl *Client) ProducerID(ctx context.Context) (int64, int16, error) {
 	}
 }
 
+// ProducerSession is a snapshot of the client's idempotent producer session,
+// which can be used to correlate produced batches with a producer ID and epoch
+// when deduplicating downstream.
+type ProducerSession struct {
+	// ID is the current producer ID, or -1 if no producer ID has been
+	// loaded yet or if idempotency is disabled.
+	ID int64
+	// Epoch is the current producer epoch, or -1 if no ID is loaded.
+	Epoch int16
+	// Err is non-nil if the producer ID is in an errored state. The client
+	// recovers from some errors by loading a new ID or bumping the epoch
+	// on the next produce.
+	Err error
+	// Sequences is the sequence number that the next batch produced to a
+	// partition will use, for every partition the client has produced to.
+	// Sequences restart at zero whenever the ID or epoch changes.
+	Sequences map[string]map[int32]int32
+}
+
+// ProducerSession returns a snapshot of the current idempotent producer ID,
+// epoch, and per partition sequence numbers. Unlike ProducerID, this does not
+// load the producer ID if it has not been loaded yet. To be notified when the
+// ID or epoch changes, see HookProducerIDUpdate.
+func (cl *Client) ProducerSession() ProducerSession {
+	id := cl.producer.id.Load().(*producerID)
+	s := ProducerSession{
+		ID:    id.id,
+		Epoch: id.epoch,
+		Err:   id.err,
+	}
+	if errors.Is(s.Err, errReloadProducerID) {
+		s.Err = nil
+	}
+	for topic, parts := range cl.producer.topics.load() {
+		for _, p := range parts.load().partitions {
+			recBuf := p.records
+			recBuf.mu.Lock()
+			seq := recBuf.seq
+			if recBuf.needSeqReset {
+				seq = 0
+			}
+			recBuf.mu.Unlock()
+
+			if s.Sequences == nil {
+				s.Sequences = make(map[string]map[int32]int32)
+			}
+			topic := cl.unnsTopic(topic)
+			seqs := s.Sequences[topic]
+			if seqs == nil {
+				seqs = make(map[int32]int32)
+				s.Sequences[topic] = seqs
+			}
+			seqs[recBuf.partition] = seq
+		}
+	}
+	return s
+}
+
 type producerID struct {
 	id    int64
 	epoch int16
@@ -612,6 +1335,7 @@ func (cl *Client) producerID() (int64, int16, error) {
 		defer p.idMu.Unlock()
 
 		if id = p.id.Load().(*producerID); errors.Is(id.err, errReloadProducerID) {
+			prior := id
 			if cl.cfg.disableIdempotency {
 				cl.cfg.logger.Log(LogLevelInfo, "skipping producer id initialization because the client was configured to disable idempotent writes")
 				id = &producerID{
@@ -631,6 +1355,7 @@ func (cl *Client) producerID() (int64, int16, error) {
 					err:   nil,
 				}
 				p.id.Store(id)
+				cl.hookProducerIDUpdate(prior, id)
 			} else {
 				newID, keep := cl.doInitProducerID(id.id, id.epoch)
 				if keep {
@@ -642,6 +1367,9 @@ func (cl *Client) producerID() (int64, int16, error) {
 					// then we definitely still need to reset here.
 					cl.resetAllProducerSequences()
 					p.id.Store(id)
+					if id.err == nil {
+						cl.hookProducerIDUpdate(prior, id)
+					}
 				} else {
 					// If we are not keeping the producer ID,
 					// we will return our old ID but with a
@@ -660,6 +1388,14 @@ func (cl *Client) producerID() (int64, int16, error) {
 	return id.id, id.epoch, id.err
 }
 
+func (cl *Client) hookProducerIDUpdate(prior, id *producerID) {
+	cl.cfg.hooks.each(func(h Hook) {
+		if h, ok := h.(HookProducerIDUpdate); ok {
+			h.OnProducerIDUpdate(prior.id, prior.epoch, id.id, id.epoch)
+		}
+	})
+}
+
 // As seen in KAFKA-12152, if we bump an epoch, we have to reset sequence nums
 // for every partition. Otherwise, we will use a new id/epoch for a partition
 // and trigger OOOSN errors.
@@ -890,7 +1626,7 @@ func (cl *Client) waitUnknownTopic(
 			}
 			cl.cfg.logger.Log(LogLevelInfo, "new topic metadata wait failed, retrying wait", "topic", topic, "err", retryableErr)
 			tries++
-			if int64(tries) >= cl.cfg.recordRetries {
+			if int64(tries) >= cl.cfg.recordRetries.load() {
 				err = fmt.Errorf("no partitions available after attempting to refresh metadata %d times, last err: %w", tries, retryableErr)
 			}
 			if cl.cfg.maxUnknownFailures >= 0 && errors.Is(retryableErr, kerr.UnknownTopicOrPartition) {
@@ -948,7 +1684,7 @@ func (cl *Client) Flush(ctx context.Context) error {
 	// linger because the producer's flushing atomic int32 is nonzero. We
 	// must wake anything that could be lingering up, after which all sinks
 	// will loop draining.
-	if cl.cfg.linger > 0 || cl.cfg.manualFlushing {
+	if cl.cfg.linger.load() > 0 || cl.cfg.manualFlushing {
 		for _, parts := range p.topics.load() {
 			for _, part := range parts.load().partitions {
 				part.records.unlingerAndManuallyDrain()
@@ -980,6 +1716,173 @@ func (cl *Client) Flush(ctx context.Context) error {
 	}
 }
 
+// FlushTopics hangs waiting for all buffered records for the given topics to
+// be flushed, stopping lingers for those topics if necessary. Unlike Flush,
+// this does not wait for records to other topics, which continue to linger
+// as usual. This is useful when some topics need records to be durable
+// before continuing while other topics can be batched lazily.
+//
+// If the client is configured with ManualFlushing, all partitions of the
+// given topics are flushed.
+//
+// If the context finishes (Done), this returns the context's error.
+//
+// This function is safe to call multiple times concurrently, and safe to call
+// concurrent with Flush.
+func (cl *Client) FlushTopics(ctx context.Context, topics ...string) error {
+	tps := make(map[string][]int32, len(topics))
+	for _, topic := range topics {
+		tps[cl.nsTopic(topic)] = nil
+	}
+	return cl.flushSome(ctx, tps)
+}
+
+// FlushPartitions is like FlushTopics, but only waits for buffered records
+// in the given partitions. Records for topics whose partitions are not yet
+// known are waited on until they are partitioned. Lingering is stopped for
+// all partitions of the given topics, so records in other partitions of
+// these topics may be flushed as well.
+func (cl *Client) FlushPartitions(ctx context.Context, partitions map[string][]int32) error {
+	tps := make(map[string][]int32, len(partitions))
+	for topic, ps := range partitions {
+		if len(ps) > 0 {
+			tps[cl.nsTopic(topic)] = ps
+		}
+	}
+	return cl.flushSome(ctx, tps)
+}
+
+// flushSome flushes the given topics, and if a topic has partitions, only
+// waits on those partitions.
+func (cl *Client) flushSome(ctx context.Context, tps map[string][]int32) error {
+	if len(tps) == 0 {
+		return nil
+	}
+	p := &cl.producer
+
+	// Similar to Flush, we first forbid the topics from lingering, and
+	// then wake up anything that is lingering. With manual flushing, our
+	// topics can now be drained.
+	p.flushingTopicsMu.Lock()
+	if p.flushingTopics == nil {
+		p.flushingTopics = make(map[string]int)
+	}
+	for topic := range tps {
+		p.flushingTopics[topic]++
+	}
+	p.topicFlushes.Add(1)
+	p.flushingTopicsMu.Unlock()
+	defer func() {
+		p.flushingTopicsMu.Lock()
+		defer p.flushingTopicsMu.Unlock()
+		for topic := range tps {
+			if p.flushingTopics[topic]--; p.flushingTopics[topic] == 0 {
+				delete(p.flushingTopics, topic)
+			}
+		}
+		p.topicFlushes.Add(-1)
+	}()
+
+	cl.cfg.logger.Log(LogLevelInfo, "flushing topics", "topics", tps)
+	defer cl.cfg.logger.Log(LogLevelDebug, "flushed topics", "topics", tps)
+
+	if cl.cfg.linger.load() > 0 || cl.cfg.manualFlushing {
+		topics := p.topics.load()
+		for topic := range tps {
+			if parts, ok := topics[topic]; ok {
+				for _, part := range parts.load().partitions {
+					part.records.unlingerAndManuallyDrain()
+				}
+			}
+		}
+	}
+
+	quit := false
+	done := make(chan struct{})
+	go func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		defer close(done)
+
+		for !quit && p.anyBuffered(tps) {
+			p.c.Wait()
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		p.mu.Lock()
+		quit = true
+		p.mu.Unlock()
+		p.c.Broadcast()
+		return ctx.Err()
+	}
+
+	// Records are removed from their partitions before their promises
+	// are called. All promises for our partitions have at least been
+	// queued, so once a marker queued after them is finished, all of
+	// our promises have been called.
+	flushed := make(chan struct{})
+	p.promiseBatch(batchPromise{flushed: flushed})
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// anyBuffered returns whether any records are buffered for the given topics
+// and partitions, including records for topics that are not yet loaded.
+func (p *producer) anyBuffered(tps map[string][]int32) bool {
+	// Records for unknown topics are moved into partitions while the
+	// unknown topics mutex is held; by holding the mutex while checking
+	// partitions, we never miss records that are moving.
+	p.unknownTopicsMu.Lock()
+	defer p.unknownTopicsMu.Unlock()
+
+	topics := p.topics.load()
+	for topic, partitions := range tps {
+		if unknown, ok := p.unknownTopics[topic]; ok && len(unknown.buffered) > 0 {
+			return true
+		}
+		parts, ok := topics[topic]
+		if !ok {
+			continue
+		}
+		all := parts.load().partitions
+		if len(partitions) == 0 {
+			for _, part := range all {
+				if part.records.lockedBuffered() {
+					return true
+				}
+			}
+			continue
+		}
+		for _, partition := range partitions {
+			if partition >= 0 && int(partition) < len(all) && all[partition].records.lockedBuffered() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isFlushing returns whether records for a topic should be flushed, rather
+// than lingering or (with manual flushing) waiting for a flush.
+func (p *producer) isFlushing(topic string) bool {
+	if p.flushing.Load() > 0 {
+		return true
+	}
+	if p.topicFlushes.Load() == 0 {
+		return false
+	}
+	p.flushingTopicsMu.Lock()
+	defer p.flushingTopicsMu.Unlock()
+	return p.flushingTopics[topic] > 0
+}
+
 func (p *producer) pause(ctx context.Context) error {
 	p.inflight.Add(1 << 48)
 
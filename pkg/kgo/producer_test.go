@@ -0,0 +1,729 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+type interceptHook struct {
+	fn       func(*Record) error
+	buffered int
+}
+
+func (h *interceptHook) OnProduceRecordIntercept(r *Record) error { return h.fn(r) }
+func (h *interceptHook) OnProduceRecordBuffered(*Record)          { h.buffered++ }
+
+func TestProduceRecordIntercept(t *testing.T) {
+	errReject := errors.New("rejected")
+	var order []string
+	first := &interceptHook{fn: func(r *Record) error {
+		order = append(order, "first")
+		r.Headers = append(r.Headers, RecordHeader{Key: "tenant", Value: []byte("a")})
+		return nil
+	}}
+	second := &interceptHook{fn: func(r *Record) error {
+		order = append(order, "second")
+		if len(r.Headers) != 1 {
+			t.Errorf("second hook did not see first hook's modification")
+		}
+		return errReject
+	}}
+	third := &interceptHook{fn: func(*Record) error {
+		order = append(order, "third")
+		return nil
+	}}
+
+	cl, err := NewClient(WithHooks(first, second, third), DefaultProduceTopic("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	done := make(chan error, 1)
+	cl.Produce(context.Background(), &Record{Value: []byte("v")}, func(_ *Record, err error) { done <- err })
+	if err := <-done; err != errReject {
+		t.Errorf("got promise err %v, exp %v", err, errReject)
+	}
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("got hook order %v, exp [first second]", order)
+	}
+	if first.buffered != 0 {
+		t.Errorf("rejected record was buffered")
+	}
+	if n := cl.BufferedProduceRecords(); n != 0 {
+		t.Errorf("got %d buffered records, exp 0", n)
+	}
+}
+
+func TestBufferedBytes(t *testing.T) {
+	if b := newBufferedBytes(0); b != nil {
+		t.Fatal("expected nil tracker with no limit")
+	}
+	var nilb *bufferedBytes
+	nilb.add(1)
+	nilb.sub(1)
+	if nilb.over() || nilb.wait(context.Background(), context.Background()) != nil {
+		t.Fatal("nil tracker should never be over or block")
+	}
+
+	b := newBufferedBytes(10)
+	b.add(10)
+	if !b.over() {
+		t.Fatal("expected tracker at limit to be over")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- b.wait(context.Background(), context.Background()) }()
+	b.sub(1)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected wait err: %v", err)
+	}
+
+	b.add(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.wait(ctx, context.Background()); err != context.Canceled {
+		t.Errorf("got wait err %v, exp %v", err, context.Canceled)
+	}
+}
+
+func TestMaxBufferedBytesPerTopic(t *testing.T) {
+	cl, err := NewClient(MaxBufferedBytesPerTopic(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	cl.producer.topicBufferedBytes("foo").add(10)
+
+	done := make(chan error, 1)
+	promise := func(_ *Record, err error) { done <- err }
+
+	cl.TryProduce(context.Background(), &Record{Topic: "foo"}, promise)
+	if err := <-done; err != ErrMaxBuffered {
+		t.Errorf("got try produce err %v, exp %v", err, ErrMaxBuffered)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cl.Produce(ctx, &Record{Topic: "foo"}, promise)
+	if err := <-done; err != context.Canceled {
+		t.Errorf("got produce err %v, exp %v", err, context.Canceled)
+	}
+
+	if n := cl.BufferedProduceRecords(); n != 0 {
+		t.Errorf("got %d buffered records, exp 0", n)
+	}
+	if size := recordBufferedBytes(&Record{Key: []byte("k"), Value: []byte("vv"), Headers: []RecordHeader{{"h", []byte("hv")}}}); size != 6 {
+		t.Errorf("got record size %d, exp 6", size)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	if b := newTokenBucket(0); b != nil {
+		t.Fatal("expected nil bucket with no rate")
+	}
+	var nilb *tokenBucket
+	nilb.give(1)
+	if d := nilb.take(1, time.Now(), true); d != 0 {
+		t.Fatalf("nil bucket should never limit, got %v", d)
+	}
+
+	now := time.Now()
+	b := newTokenBucket(10)
+	b.last = now
+	if d := b.take(20, now, true); d != 0 {
+		t.Errorf("a full bucket should allow a large take without waiting, got %v", d)
+	}
+	if d := b.take(1, now, true); d != -1 {
+		t.Errorf("got %v taking from an empty bucket without debt, exp -1", d)
+	}
+	if d := b.take(5, now.Add(time.Second), false); d != 500*time.Millisecond {
+		t.Errorf("got wait %v, exp 500ms", d)
+	}
+	b.give(5)
+	if d := b.take(10, now.Add(3*time.Second), true); d != 0 {
+		t.Errorf("expected refilled bucket, got %v", d)
+	}
+}
+
+func TestProduceRateLimit(t *testing.T) {
+	cl, err := NewClient(
+		ProduceRateLimit(0, 2),
+		ProduceRateLimitPerTopic(10, 0),
+		ProduceRateLimitFail(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	p := &cl.producer
+	ctx := context.Background()
+	if err := p.waitRateLimit(ctx, &Record{Topic: "foo", Value: make([]byte, 10)}, true); err != nil {
+		t.Fatalf("unexpected first record err: %v", err)
+	}
+	if err := p.waitRateLimit(ctx, &Record{Topic: "foo", Value: make([]byte, 1)}, true); err != ErrRateLimited {
+		t.Fatalf("got err %v over the topic limit, exp %v", err, ErrRateLimited)
+	}
+	// The failed record above gave back its global record token.
+	if err := p.waitRateLimit(ctx, &Record{Topic: "bar", Value: make([]byte, 1)}, true); err != nil {
+		t.Fatalf("unexpected other topic err: %v", err)
+	}
+	if err := p.waitRateLimit(ctx, &Record{Topic: "baz"}, true); err != ErrRateLimited {
+		t.Fatalf("got err %v over the global limit, exp %v", err, ErrRateLimited)
+	}
+
+	cl, err = NewClient(ProduceRateLimit(0, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	done := make(chan error, 1)
+	promise := func(_ *Record, err error) { done <- err }
+	cl.producer.rateRecords.take(100, time.Now(), false)
+	cl.TryProduce(ctx, &Record{Topic: "foo"}, promise)
+	if err := <-done; err != ErrRateLimited {
+		t.Errorf("got try produce err %v, exp %v", err, ErrRateLimited)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	cl.Produce(cctx, &Record{Topic: "foo"}, promise)
+	if err := <-done; err != context.Canceled {
+		t.Errorf("got produce err %v, exp %v", err, context.Canceled)
+	}
+
+	start := time.Now()
+	if err := cl.producer.waitRateLimit(ctx, &Record{Topic: "foo"}, true); err != nil {
+		t.Errorf("unexpected blocking err: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 5*time.Millisecond {
+		t.Errorf("expected to wait for a token, waited %v", elapsed)
+	}
+
+	// A record that fails while waiting for rate limiting was never
+	// buffered, and must not be uncounted from the buffer.
+	cl.producer.rateRecords.take(1000, time.Now(), false) // ~10s of debt
+	cctx, cancel = context.WithCancel(ctx)
+	time.AfterFunc(10*time.Millisecond, cancel)
+	cl.Produce(cctx, &Record{Topic: "foo"}, promise)
+	if err := <-done; err != context.Canceled {
+		t.Errorf("got rate limited produce err %v, exp %v", err, context.Canceled)
+	}
+	if n := cl.BufferedProduceRecords(); n != 0 {
+		t.Errorf("got %d buffered records after rate limiting failed a record, exp 0", n)
+	}
+}
+
+func TestFlushTopics(t *testing.T) {
+	cl, err := NewClient(ManualFlushing(), MaxBufferedRecords(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	// With no seed broker, the record waits in unknown topics.
+	cl.Produce(context.Background(), &Record{Topic: "foo"}, nil)
+
+	if err := cl.FlushTopics(context.Background(), "bar"); err != nil {
+		t.Errorf("unexpected err flushing a topic with nothing buffered: %v", err)
+	}
+	if err := cl.FlushPartitions(context.Background(), map[string][]int32{"bar": {0}}); err != nil {
+		t.Errorf("unexpected err flushing a partition with nothing buffered: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := cl.FlushTopics(ctx, "foo"); err != context.Canceled {
+		t.Errorf("got flush err %v, exp %v", err, context.Canceled)
+	}
+
+	p := &cl.producer
+	if p.isFlushing("foo") || p.topicFlushes.Load() != 0 || len(p.flushingTopics) != 0 {
+		t.Error("topic flush state was not cleared after flushing")
+	}
+}
+
+func TestClusterPartitionsInput(t *testing.T) {
+	cl, err := NewClient(Rack("us-east-1a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	rack := "us-east-1b"
+	cl.brokersMu.Lock()
+	cl.brokers = []*broker{{meta: BrokerMetadata{NodeID: 1, Host: "b1", Port: 9092, Rack: &rack}}}
+	cl.brokersMu.Unlock()
+
+	known := &recBuf{partition: 0}
+	known.buffered.Store(3)
+	in := &clusterPartitionsInput{cl: cl, mapping: []*topicPartition{
+		{topicPartitionData: topicPartitionData{leader: 1, leaderEpoch: 5}, records: known},
+		{topicPartitionData: topicPartitionData{leader: 2, leaderEpoch: 7}, records: &recBuf{partition: 4}},
+	}}
+
+	if got := in.Rack(); got != "us-east-1a" {
+		t.Errorf("got rack %q, exp us-east-1a", got)
+	}
+	for i, exp := range []PartitionInfo{
+		{Partition: 0, Leader: BrokerMetadata{NodeID: 1, Host: "b1", Port: 9092, Rack: &rack}, LeaderEpoch: 5, BufferedRecords: 3},
+		{Partition: 4, Leader: BrokerMetadata{NodeID: 2}, LeaderEpoch: 7},
+	} {
+		if got := in.Partition(i); !reflect.DeepEqual(got, exp) {
+			t.Errorf("partition index %d: got %+v, exp %+v", i, got, exp)
+		}
+	}
+}
+
+func TestValidateMaxMessageBytes(t *testing.T) {
+	resp := kmsg.NewPtrDescribeConfigsResponse()
+	rr := kmsg.NewDescribeConfigsResponseResource()
+	rr.ResourceName = "foo"
+	rc := kmsg.NewDescribeConfigsResponseResourceConfig()
+	rc.Name = "max.message.bytes"
+	rc.Value = kmsg.StringPtr("100")
+	rr.Configs = append(rr.Configs, rc)
+	resp.Resources = append(resp.Resources, rr)
+	if max, err := maxMessageBytesFromResp("foo", resp); err != nil || max != 100 {
+		t.Errorf("got max %d (err %v), exp 100", max, err)
+	}
+	if _, err := maxMessageBytesFromResp("bar", resp); err == nil {
+		t.Error("unexpected success parsing a response missing our topic")
+	}
+
+	if l := singleRecordBatchLength(new(Record)); l != 61+7 {
+		t.Errorf("got empty record batch length %d, exp 68", l)
+	}
+
+	cl, err := NewClient(ProducerValidateMaxMessageBytes(), SeedBrokers("127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+	cl.producer.maxMsgBytes = map[string]*topicMaxMsgBytes{"foo": {max: 100, loadedAt: time.Now()}}
+
+	produce := func(value []byte) error {
+		done := make(chan error, 1)
+		pr := promisedRec{context.Background(), func(_ *Record, err error) { done <- err }, &Record{Topic: "foo", Value: value}}
+		cl.doPartitionRecord(new(topicPartitions), new(topicPartitionsData), pr)
+		return <-done
+	}
+
+	var tooLarge *ErrRecordTooLarge
+	if err := produce(make([]byte, 100)); !errors.As(err, &tooLarge) || !errors.Is(err, kerr.MessageTooLarge) {
+		t.Errorf("got err %v, exp record too large", err)
+	} else if tooLarge.MaxMessageBytes != 100 || tooLarge.Size <= 100 {
+		t.Errorf("got too large err %+v, exp max 100 and size over 100", tooLarge)
+	}
+	// A small record passes validation and fails partitioning, since we
+	// have no partitions.
+	if err := produce([]byte("v")); errors.As(err, &tooLarge) {
+		t.Errorf("got err %v for small record, exp not too large", err)
+	}
+
+	// After a broker rejects a batch as too large, the config is reloaded
+	// on the next produce, and the prior value is used while reloading.
+	cl.producer.forgetMaxMessageBytes("foo")
+	if max := cl.producer.maxMessageBytes("foo"); max != 100 {
+		t.Errorf("got max %d while reloading, exp the prior 100", max)
+	}
+	cl.producer.maxMsgBytesMu.Lock()
+	loading := cl.producer.maxMsgBytes["foo"].loading
+	cl.producer.maxMsgBytesMu.Unlock()
+	if !loading {
+		t.Error("forgotten max.message.bytes is not reloading")
+	}
+}
+
+func TestProducePriority(t *testing.T) {
+	cl, err := NewClient(MaxBufferedBytesPerPriority(1, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if cl.producer.prioBufferedBytes(0) != nil {
+		t.Error("unexpected buffered bytes tracker for unlimited priority 0")
+	}
+	cl.producer.prioBufferedBytes(1).add(10)
+	done := make(chan error, 1)
+	cl.TryProduce(context.Background(), &Record{Topic: "foo", Priority: 1}, func(_ *Record, err error) { done <- err })
+	if err := <-done; err != ErrMaxBuffered {
+		t.Errorf("got try produce err %v, exp %v", err, ErrMaxBuffered)
+	}
+	if !cl.producer.hasPriorities.Load() {
+		t.Error("producing a priority record did not enable priority draining")
+	}
+
+	// Records with different priorities are not batched together.
+	b := new(recBatch)
+	if appended, _ := b.tryBuffer(promisedRec{Record: &Record{Priority: 1}}, -1, 1<<20, false); !appended {
+		t.Fatal("unable to buffer first record")
+	}
+	if appended, _ := b.tryBuffer(promisedRec{Record: new(Record)}, -1, 1<<20, false); appended {
+		t.Error("unexpectedly buffered a priority 0 record into a priority 1 batch")
+	}
+
+	// Sinks drain higher priority batches first, otherwise keeping their
+	// round robin order from recBufsStart.
+	var s sink
+	for _, prio := range []int8{0, 2, 0, 1, 2} {
+		s.recBufs = append(s.recBufs, &recBuf{partition: int32(len(s.recBufs)), batches: []*recBatch{{priority: prio}}})
+	}
+	s.recBufs = append(s.recBufs, &recBuf{partition: int32(len(s.recBufs))}) // nothing to drain
+	s.recBufsStart = 2
+
+	var order []int32
+	for _, recBuf := range s.recBufsByPriority() {
+		order = append(order, recBuf.partition)
+	}
+	if exp := []int32{4, 1, 3, 2, 0, 5}; !reflect.DeepEqual(order, exp) {
+		t.Errorf("got drain order %v, exp %v", order, exp)
+	}
+}
+
+func TestRecordExpiry(t *testing.T) {
+	cl, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	now := time.Now()
+	var (
+		expiredCtx = WithRecordExpiry(context.Background(), now.Add(-time.Second))
+		liveCtx    = WithRecordExpiry(context.Background(), now.Add(time.Hour))
+		errs       = make(chan error, 10)
+	)
+	if _, ok := recordExpiry(context.Background()); ok {
+		t.Error("unexpected expiry on a plain context")
+	}
+
+	recBuf := &recBuf{cl: cl}
+	buffer := func(ctxs ...context.Context) *recBatch {
+		b := recBuf.newRecordBatch()
+		for i, ctx := range ctxs {
+			r := &Record{Context: ctx, Value: []byte{byte(i)}, Timestamp: now.Add(time.Duration(i) * time.Millisecond)}
+			pr := promisedRec{ctx, func(_ *Record, err error) { errs <- err }, r}
+			if appended, _ := b.tryBuffer(pr, -1, 1<<20, false); !appended {
+				t.Fatal("unable to buffer record")
+			}
+		}
+		recBuf.batches = append(recBuf.batches, b)
+		recBuf.buffered.Add(int64(len(ctxs)))
+		return b
+	}
+
+	// Expiring the first record rebuilds the batch from the rest, exactly
+	// as if only the rest had been buffered.
+	b := buffer(expiredCtx, liveCtx, context.Background())
+	exp := recBuf.newRecordBatch()
+	for _, pr := range b.records[1:] {
+		exp.tryBuffer(pr, -1, 1<<20, false)
+	}
+	if !recBuf.expireRecords(b, now) {
+		t.Fatal("batch unexpectedly removed")
+	}
+	if err := <-errs; err != ErrRecordExpired {
+		t.Errorf("got err %v, exp %v", err, ErrRecordExpired)
+	}
+	if len(b.records) != 2 || b.wireLength != exp.wireLength || b.firstTimestamp != exp.firstTimestamp || b.maxTimestampDelta != exp.maxTimestampDelta {
+		t.Errorf("rebuilt batch has %d records, length %d, first ts %d, max ts delta %d; exp 2, %d, %d, %d",
+			len(b.records), b.wireLength, b.firstTimestamp, b.maxTimestampDelta, exp.wireLength, exp.firstTimestamp, exp.maxTimestampDelta)
+	}
+	if buffered := recBuf.buffered.Load(); buffered != 2 {
+		t.Errorf("got %d buffered, exp 2", buffered)
+	}
+
+	// Nothing is expired now, so nothing changes.
+	if !recBuf.expireRecords(b, now) || len(b.records) != 2 {
+		t.Error("unexpected change to batch with no expired records")
+	}
+
+	// If everything expires, the batch is removed.
+	b2 := buffer(expiredCtx)
+	recBuf.batchDrainIdx = 1
+	if recBuf.expireRecords(b2, now) {
+		t.Error("batch with only expired records was not removed")
+	}
+	if err := <-errs; err != ErrRecordExpired {
+		t.Errorf("got err %v, exp %v", err, ErrRecordExpired)
+	}
+	if len(recBuf.batches) != 1 || recBuf.batches[0] != b {
+		t.Error("expired batch was not removed from the buffer")
+	}
+}
+
+func TestRecordReuse(t *testing.T) {
+	errReject := errors.New("rejected")
+	var producing bool
+	reject := &interceptHook{fn: func(r *Record) error {
+		producing = r.producing.load()
+		return errReject
+	}}
+	cl, err := NewClient(WithHooks(reject), DefaultProduceTopic("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	r := GetRecord()
+	r.Value = append(r.Value, "value"...)
+	r.Headers = append(r.Headers, RecordHeader{Key: "k", Value: []byte("v")})
+
+	done := make(chan struct{})
+	cl.Produce(context.Background(), r, func(r *Record, err error) {
+		defer close(done)
+		if err != errReject {
+			t.Errorf("got promise err %v, exp %v", err, errReject)
+		}
+		r.Reuse()
+	})
+	<-done
+	if !producing {
+		t.Error("record was not marked as producing while being produced")
+	}
+	if r.Topic != "" || len(r.Value) != 0 || cap(r.Value) < 5 || len(r.Headers) != 0 || cap(r.Headers) < 1 {
+		t.Errorf("reused record was not cleared with capacity retained: %+v", r)
+	}
+	if r.Key != nil || r.Context != nil {
+		t.Error("reused record unexpectedly has a key or context")
+	}
+
+	r2 := &Record{Value: []byte("v")}
+	r2.producing.store(true)
+	r2.Reuse()
+	if string(r2.Value) != "v" {
+		t.Error("record still being produced was reused")
+	}
+}
+
+type producerIDUpdateHook func(priorID int64, priorEpoch int16, id int64, epoch int16)
+
+func (h producerIDUpdateHook) OnProducerIDUpdate(priorID int64, priorEpoch int16, id int64, epoch int16) {
+	h(priorID, priorEpoch, id, epoch)
+}
+
+func TestProducerSession(t *testing.T) {
+	var updates [][4]int64
+	cl, err := NewClient(
+		TopicNamespace(TopicPrefix("ns.")),
+		WithHooks(producerIDUpdateHook(func(priorID int64, priorEpoch int16, id int64, epoch int16) {
+			updates = append(updates, [4]int64{priorID, int64(priorEpoch), id, int64(epoch)})
+		})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if s := cl.ProducerSession(); s.ID != -1 || s.Epoch != -1 || s.Err != nil || s.Sequences != nil {
+		t.Errorf("got initial session %+v, exp unloaded", s)
+	}
+
+	p := &cl.producer
+	p.topics.storeTopics([]string{"ns.foo"})
+	p.topics.load()["ns.foo"].v.Store(&topicPartitionsData{partitions: []*topicPartition{
+		{records: &recBuf{partition: 0, seq: 7}},
+		{records: &recBuf{partition: 1, seq: 3}},
+	}})
+	p.id.Store(&producerID{5, 1, nil})
+
+	exp := ProducerSession{ID: 5, Epoch: 1, Sequences: map[string]map[int32]int32{"foo": {0: 7, 1: 3}}}
+	if s := cl.ProducerSession(); !reflect.DeepEqual(s, exp) {
+		t.Errorf("got session %+v, exp %+v", s, exp)
+	}
+
+	// Reloading an idempotent ID bumps the epoch locally (KIP-360) and
+	// resets every sequence number.
+	p.id.Store(&producerID{5, 1, errReloadProducerID})
+	if id, epoch, err := cl.producerID(); id != 5 || epoch != 2 || err != nil {
+		t.Fatalf("got id %d epoch %d err %v, exp 5 2 nil", id, epoch, err)
+	}
+	exp = ProducerSession{ID: 5, Epoch: 2, Sequences: map[string]map[int32]int32{"foo": {0: 0, 1: 0}}}
+	if s := cl.ProducerSession(); !reflect.DeepEqual(s, exp) {
+		t.Errorf("got session %+v after bump, exp %+v", s, exp)
+	}
+	if exp := [][4]int64{{5, 1, 5, 2}}; !reflect.DeepEqual(updates, exp) {
+		t.Errorf("got updates %v, exp %v", updates, exp)
+	}
+}
+
+func TestCloseFlushing(t *testing.T) {
+	t.Parallel()
+
+	cl, err := NewClient(SeedBrokers("127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var promised []error
+	promise := func(r *Record, err error) { // promises are serialized
+		promised = append(promised, err)
+		r.Reuse() // must not pool a record that CloseFlushing returns
+	}
+	for _, v := range []string{"a", "b", "c"} {
+		cl.Produce(context.Background(), &Record{Topic: "foo", Value: []byte(v)}, promise)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	unflushed := cl.CloseFlushing(ctx)
+
+	var values []string
+	for _, r := range unflushed {
+		values = append(values, string(r.Value))
+		if r.Topic != "foo" {
+			t.Errorf("got record topic %q, exp foo", r.Topic)
+		}
+	}
+	if exp := []string{"a", "b", "c"}; !reflect.DeepEqual(values, exp) {
+		t.Errorf("got unflushed %v, exp %v", values, exp)
+	}
+	for _, r := range unflushed {
+		if r.producing.load() {
+			t.Error("returned record is still marked as producing")
+		}
+	}
+	if len(promised) != 3 {
+		t.Fatalf("got %d promises, exp 3", len(promised))
+	}
+	for _, err := range promised {
+		if !errors.Is(err, ErrClientClosed) {
+			t.Errorf("got promise err %v, exp %v", err, ErrClientClosed)
+		}
+	}
+
+	cl, err = NewClient(SeedBrokers("127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unflushed := cl.CloseFlushing(context.Background()); unflushed != nil {
+		t.Errorf("got %d unflushed records with nothing buffered, exp none", len(unflushed))
+	}
+}
+
+type ackHook struct{ acks []ProduceAck }
+
+func (h *ackHook) OnProduceRecordAcked(ack ProduceAck) { h.acks = append(h.acks, ack) }
+
+func TestHookProduceRecordAcked(t *testing.T) {
+	t.Parallel()
+
+	h := new(ackHook)
+	cl, err := NewClient(
+		SeedBrokers("127.0.0.1:1"),
+		WithHooks(h),
+		ProduceAckSampling(2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ts := time.Unix(1, 0)
+	finish := func(r *Record, err error) {
+		cl.producer.bufferedRecords.Add(1)
+		cl.finishRecordPromise(promisedRec{promise: func(*Record, error) {}, Record: r}, err, false, false)
+	}
+	finish(&Record{Topic: "foo", Partition: 1, Offset: 10, Timestamp: ts, Key: []byte("k")}, nil)
+	finish(&Record{Topic: "foo", Partition: 1, Offset: 11, Timestamp: ts}, nil)        // not sampled
+	finish(&Record{Topic: "foo", Partition: 2, Offset: 5, Timestamp: ts}, ErrAborting) // failed, not acked
+	finish(&Record{Topic: "bar", Partition: 0, Offset: 3, Timestamp: ts}, nil)
+
+	exp := []ProduceAck{
+		{Topic: "foo", Partition: 1, Offset: 10, Timestamp: ts, KeyHash: murmur2([]byte("k"))},
+		{Topic: "bar", Partition: 0, Offset: 3, Timestamp: ts},
+	}
+	if !reflect.DeepEqual(h.acks, exp) {
+		t.Errorf("got acks %v, exp %v", h.acks, exp)
+	}
+}
+
+func TestProduceLatencyFailurePenalty(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		name string
+		err  error
+		min  time.Duration
+		max  time.Duration
+	}{
+		{"success records the real latency", nil, 0, time.Second},
+		{"fast failure records the penalty", errors.New("connection reset"), 10 * time.Second, 10 * time.Second},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rb := &recBuf{partition: 0}
+			req := &produceRequest{writtenAt: time.Now()}
+			req.batches.addBatch("foo", 0, 0, &recBatch{owner: rb})
+
+			req.noteLatency(test.err, 10*time.Second)
+			if got := time.Duration(rb.latency.Load()); got < test.min || got > test.max {
+				t.Errorf("got latency %v, exp between %v and %v", got, test.min, test.max)
+			}
+		})
+	}
+
+	// A request that was never written records nothing.
+	rb := &recBuf{partition: 0}
+	req := new(produceRequest)
+	req.batches.addBatch("foo", 0, 0, &recBatch{owner: rb})
+	req.noteLatency(errors.New("dial failed"), 10*time.Second)
+	if got := rb.latency.Load(); got != 0 {
+		t.Errorf("got latency %v for an unwritten request, exp 0", got)
+	}
+}
+
+type fakeClusterPartitions []PartitionInfo
+
+func (fakeClusterPartitions) Rack() string                    { return "" }
+func (f fakeClusterPartitions) Partition(i int) PartitionInfo { return f[i] }
+
+func TestAdaptiveStickyPartitioner(t *testing.T) {
+	t.Parallel()
+
+	cluster := fakeClusterPartitions{
+		{Partition: 0, ProduceLatency: 2 * time.Millisecond},
+		{Partition: 1, ProduceLatency: 2 * time.Millisecond},
+		{Partition: 2, ProduceLatency: time.Second}, // degraded
+	}
+
+	p := AdaptiveStickyPartitioner(1, true, nil).ForTopic("foo").(*adaptiveStickyTopicPartitioner)
+	p.rng = rand.New(rand.NewSource(1))
+
+	var counts [3]int
+	for i := 0; i < 3000; i++ {
+		counts[p.PartitionByCluster(&Record{Value: []byte("v")}, len(cluster), cluster)]++
+	}
+	if counts[2] > 30 {
+		t.Errorf("degraded partition picked %d of 3000 times, exp rarely: %v", counts[2], counts)
+	}
+	if counts[0] < 1000 || counts[1] < 1000 {
+		t.Errorf("healthy partitions not picked evenly: %v", counts)
+	}
+
+	// Backed up partitions are avoided the same way.
+	cluster[1].BufferedRecords = 1000
+	counts = [3]int{}
+	for i := 0; i < 3000; i++ {
+		counts[p.PartitionByCluster(&Record{Value: []byte("v")}, len(cluster), cluster)]++
+	}
+	if counts[0] < 2900 {
+		t.Errorf("expected the healthy, unbuffered partition to be picked nearly always: %v", counts)
+	}
+
+	key := []byte("key")
+	if got, exp := p.PartitionByCluster(&Record{Key: key}, len(cluster), cluster), KafkaHasher(murmur2)(key, len(cluster)); got != exp {
+		t.Errorf("keyed record partitioned to %d, exp hashed %d", got, exp)
+	}
+}
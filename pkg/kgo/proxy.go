@@ -0,0 +1,305 @@
+package kgo
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// proxyDialer dials brokers through a SOCKS5 or HTTP CONNECT proxy, as
+// configured with DialProxy.
+type proxyDialer struct {
+	u        atomic.Value // *url.URL, swapped with Reconfigure; each dial loads it once
+	dialer   *net.Dialer
+	resolver HostResolver // optional, used to resolve the proxy and with socks5
+}
+
+func newProxyDialer(u *url.URL, dialer *net.Dialer, resolver HostResolver) *proxyDialer {
+	p := &proxyDialer{dialer: dialer, resolver: resolver}
+	p.setURL(u)
+	return p
+}
+
+// setURL changes the proxy for all future dials. We store a copy so that the
+// user modifying their url does not race with dialing.
+func (p *proxyDialer) setURL(u *url.URL) {
+	cp := *u
+	if u.User != nil {
+		user := *u.User
+		cp.User = &user
+	}
+	p.u.Store(&cp)
+}
+
+func (p *proxyDialer) url() *url.URL { return p.u.Load().(*url.URL) }
+
+func validateProxyURL(u *url.URL) error {
+	switch u.Scheme {
+	case "socks5", "socks5h", "http":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q, only socks5, socks5h, and http are supported", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return errors.New("proxy url is missing a host")
+	}
+	return nil
+}
+
+// DialContext dials the proxy and then asks the proxy to connect to addr,
+// returning the proxied connection.
+func (p *proxyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if p.dialer.Timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, p.dialer.Timeout)
+		defer cancel()
+	}
+
+	u := p.url()
+	proxyAddr := u.Host
+	if u.Port() == "" {
+		port := "1080"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+		proxyAddr = net.JoinHostPort(u.Hostname(), port)
+	}
+	conn, err := dialResolved(ctx, p.resolver, p.dialer, network, proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to dial proxy %s: %w", proxyAddr, err)
+	}
+
+	// The handshake is bounded by the context: we set the context's
+	// deadline on the connection, and if the context is canceled while
+	// handshaking, we force the blocked read or write to return.
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	proxied := conn
+	if u.Scheme == "http" {
+		proxied, err = connectHTTP(conn, u, addr)
+	} else {
+		err = p.connectSOCKS5(ctx, conn, u, addr)
+	}
+	if err != nil {
+		conn.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		return nil, fmt.Errorf("unable to connect to %s through proxy %s: %w", addr, proxyAddr, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return proxied, nil
+}
+
+// connectHTTP issues an HTTP CONNECT request for addr.
+func connectHTTP(conn net.Conn, u *url.URL, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + pass))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy responded to CONNECT with %s", resp.Status)
+	}
+
+	// Kafka brokers never write first, so we do not expect anything to
+	// be buffered. Just in case, we do not drop anything that was.
+	if br.Buffered() > 0 {
+		return &bufferedConn{conn, br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection that first reads anything buffered while
+// handshaking with a proxy.
+type bufferedConn struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) { return c.br.Read(b) }
+
+// connectSOCKS5 performs a SOCKS5 handshake (RFC 1928), authenticating with
+// a username and password if the proxy url has them (RFC 1929), and then
+// issues a CONNECT for addr.
+//
+// With the socks5 scheme, addr's host is resolved locally. With the socks5h
+// scheme, the host is sent to the proxy to resolve, which is necessary if
+// brokers advertise hostnames that only the proxy can resolve.
+func (p *proxyDialer) connectSOCKS5(ctx context.Context, conn net.Conn, u *url.URL, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port in %s: %w", addr, err)
+	}
+
+	// Greeting: we support no auth, or username/password auth.
+	greeting := []byte{5, 1, 0}
+	if u.User != nil {
+		greeting = []byte{5, 2, 0, 2}
+	}
+	if _, err := conn.Write(greeting); err != nil {
+		return err
+	}
+	var chosen [2]byte
+	if _, err := io.ReadFull(conn, chosen[:]); err != nil {
+		return err
+	}
+	if chosen[0] != 5 {
+		return fmt.Errorf("unexpected SOCKS version %d in proxy response", chosen[0])
+	}
+	switch chosen[1] {
+	case 0:
+	case 2:
+		if u.User == nil {
+			return errors.New("SOCKS proxy requires username/password authentication")
+		}
+		user := u.User.Username()
+		pass, _ := u.User.Password()
+		if len(user) > 255 || len(pass) > 255 {
+			return errors.New("SOCKS username and password must each be at most 255 bytes")
+		}
+		auth := []byte{1, byte(len(user))}
+		auth = append(auth, user...)
+		auth = append(auth, byte(len(pass)))
+		auth = append(auth, pass...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		var status [2]byte
+		if _, err := io.ReadFull(conn, status[:]); err != nil {
+			return err
+		}
+		if status[1] != 0 {
+			return errors.New("SOCKS proxy rejected username/password authentication")
+		}
+	default:
+		return errors.New("SOCKS proxy has no acceptable authentication methods")
+	}
+
+	req := []byte{5, 1, 0} // version, CONNECT, reserved
+	ip := net.ParseIP(host)
+	if ip == nil && u.Scheme == "socks5" {
+		var r HostResolver = net.DefaultResolver
+		if p.resolver != nil {
+			r = p.resolver
+		}
+		addrs, err := r.LookupHost(ctx, host)
+		if err != nil {
+			return err
+		}
+		for _, addr := range addrs {
+			if ip = net.ParseIP(addr); ip != nil {
+				break
+			}
+		}
+		if ip == nil {
+			return fmt.Errorf("no IP addresses found for %s", host)
+		}
+	}
+	switch {
+	case ip == nil:
+		if len(host) > 255 {
+			return fmt.Errorf("host %s is too long for SOCKS", host)
+		}
+		req = append(req, 3, byte(len(host)))
+		req = append(req, host...)
+	case ip.To4() != nil:
+		req = append(req, 1)
+		req = append(req, ip.To4()...)
+	default:
+		req = append(req, 4)
+		req = append(req, ip.To16()...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	// Reply: version, status, reserved, address type, then the bound
+	// address and port, which we discard.
+	var reply [4]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[1] != 0 {
+		return fmt.Errorf("SOCKS proxy failed to connect: %s", socks5ReplyString(reply[1]))
+	}
+	var skip int
+	switch reply[3] {
+	case 1:
+		skip = net.IPv4len
+	case 4:
+		skip = net.IPv6len
+	case 3:
+		var l [1]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return err
+		}
+		skip = int(l[0])
+	default:
+		return fmt.Errorf("unknown SOCKS address type %d in proxy response", reply[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
+
+func socks5ReplyString(code byte) string {
+	switch code {
+	case 1:
+		return "general SOCKS server failure"
+	case 2:
+		return "connection not allowed by ruleset"
+	case 3:
+		return "network unreachable"
+	case 4:
+		return "host unreachable"
+	case 5:
+		return "connection refused"
+	case 6:
+		return "TTL expired"
+	case 7:
+		return "command not supported"
+	case 8:
+		return "address type not supported"
+	}
+	return "unknown code " + strconv.Itoa(int(code))
+}
@@ -3,7 +3,9 @@ package kgo
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"sync"
 	"time"
 	"unsafe"
 )
@@ -142,6 +144,21 @@ type Record struct {
 	// not mirror the offset actually stored within Kafka.
 	Offset int64
 
+	// Priority is an optional produce priority class for this record.
+	//
+	// When a broker connection is congested, partitions whose next batch
+	// to send has a higher priority are written to the broker before
+	// partitions with lower priority batches. Records with different
+	// priorities are never batched together, and records within a single
+	// partition are always written in order: a high priority record is
+	// not written before lower priority records that were buffered before
+	// it in the same partition. To keep high priority records from waiting
+	// behind bulk traffic, produce them to separate partitions or topics.
+	//
+	// Buffering can be limited per priority with
+	// MaxBufferedBytesPerPriority. This field is unused when consuming.
+	Priority int8
+
 	// Context is an optional field that is used for enriching records.
 	//
 	// If this field is nil when producing, it is set to the Produce ctx
@@ -149,6 +166,78 @@ type Record struct {
 	// producer hooks. It can also be set in a consumer hook to propagate
 	// enrichment to consumer clients.
 	Context context.Context
+
+	// slab references the pooled buffer this record's key and value
+	// point into, if consuming with PoolFetchBuffers.
+	slab *fetchSlabRef
+
+	// producing is true from when the record is produced until right
+	// before its promise is called; Reuse does nothing while true.
+	producing lazyBool
+}
+
+var recordPool = sync.Pool{New: func() any { return new(Record) }}
+
+// GetRecord returns a Record from a pool of records released with
+// Record.Reuse, allocating a new Record if the pool is empty. Producing
+// pooled records avoids allocating a Record and its value and headers slices
+// per message.
+//
+// The returned record is empty, but its Value and Headers may have capacity
+// left over from the record's previous use: appending to them does not
+// allocate. Value is non-nil if it has leftover capacity, and must be set to
+// nil to produce a tombstone. Key is always nil, because records with an
+// empty key are partitioned differently than records with no key.
+func GetRecord() *Record {
+	return recordPool.Get().(*Record)
+}
+
+// Reuse clears the record and releases it to the pool that GetRecord draws
+// from. Any record can be reused, not only records from GetRecord.
+//
+// When producing, the earliest a record can be reused is in its promise. If
+// the record is still being produced (its promise has not yet been called),
+// Reuse does nothing and the record is not pooled. After calling Reuse, the
+// record and the slices that were in it must no longer be used.
+//
+// Reusing a consumed record also recycles it; see Recycle.
+func (r *Record) Reuse() {
+	if r.producing.load() {
+		return
+	}
+	var value []byte
+	var headers []RecordHeader
+	if r.slab != nil {
+		r.Recycle() // the key, value, and headers point into a pooled fetch buffer
+	} else {
+		value = r.Value[:0]
+		for i := range r.Headers {
+			r.Headers[i] = RecordHeader{}
+		}
+		headers = r.Headers[:0]
+	}
+	*r = Record{Value: value, Headers: headers}
+	recordPool.Put(r)
+}
+
+// Recycle returns the buffer this record's key, value, and header values
+// point into to the client's fetch buffer pool once all other records
+// sharing the buffer are also recycled; see PoolFetchBuffers. This clears
+// the record's Key, Value, and Headers, which must not be used after
+// recycling. This does nothing if the record does not point into a pooled
+// buffer.
+//
+// Recycling a record more than once, or recycling copies of the same record,
+// releases the record's hold on the buffer only once. The key, value, and
+// headers of every copy must not be used after any copy is recycled.
+func (r *Record) Recycle() {
+	ref := r.slab
+	if ref == nil {
+		return
+	}
+	r.slab = nil
+	r.Key, r.Value, r.Headers = nil, nil, nil
+	ref.release()
 }
 
 // When buffering records, we calculate the length and tsDelta ahead of time
@@ -253,6 +342,14 @@ type FetchPartition struct {
 	LogStartOffset int64
 	// Records contains feched records for this partition.
 	Records []*Record
+	// RawBatches contains the fetched record batches for this partition,
+	// undecoded, if consuming with ConsumeRawBatches. Records is empty
+	// when consuming raw batches.
+	RawBatches []FetchBatch
+
+	// batches contains the metadata of every batch that records were
+	// kept from, in order, without records. See Batches.
+	batches []FetchBatch
 }
 
 // EachRecord calls fn for each record in the partition.
@@ -262,6 +359,108 @@ func (p *FetchPartition) EachRecord(fn func(*Record)) {
 	}
 }
 
+// FetchBatch is a record batch as it was written by a producer, as returned
+// from FetchPartition.Batches and PollBatches.
+//
+// For old message set formats (pre Kafka 0.11), each outer message is treated
+// as a batch, and fields that do not exist in message sets are -1.
+type FetchBatch struct {
+	// FirstOffset is the offset of the first record in the batch as
+	// written. If consuming started in the middle of this batch, or if
+	// records were compacted away, this is before the offset of the first
+	// record in Records.
+	FirstOffset int64
+	// LastOffset is the offset of the last record in the batch as written.
+	LastOffset int64
+
+	// ProducerID and ProducerEpoch are the producer ID and epoch the
+	// batch was written with, or -1 if the producer was not idempotent.
+	ProducerID    int64
+	ProducerEpoch int16
+	// BaseSequence is the sequence number of the first record in the
+	// batch, or -1 if the producer was not idempotent.
+	BaseSequence int32
+	// PartitionLeaderEpoch is the leader epoch of the broker that wrote
+	// the batch.
+	PartitionLeaderEpoch int32
+
+	// Attrs are the batch attributes, which contain the compression codec,
+	// timestamp type, and whether the batch is transactional or control.
+	Attrs RecordAttrs
+	// FirstTimestamp is the timestamp of the first record in the batch.
+	FirstTimestamp time.Time
+	// MaxTimestamp is the largest timestamp of any record in the batch.
+	MaxTimestamp time.Time
+
+	// Records contains the records from this batch that were returned
+	// from polling. This is empty if consuming with ConsumeRawBatches.
+	Records []*Record
+
+	// Raw is the entire batch as it was fetched, starting with the batch's
+	// first offset and length, with its records still compressed. This is
+	// only set if consuming with ConsumeRawBatches.
+	Raw []byte
+}
+
+// FetchBatches contains the record batches fetched for a single partition, as
+// returned from PollBatches.
+type FetchBatches struct {
+	// Topic is the topic this is for.
+	Topic string
+	// Partition is the partition this is for.
+	Partition int32
+	// Err is an error for this partition in the fetch; see
+	// FetchPartition.Err.
+	Err error
+	// HighWatermark is the current high watermark for this partition.
+	HighWatermark int64
+	// LastStableOffset is the last stable offset for this partition.
+	LastStableOffset int64
+	// LogStartOffset is the low watermark for this partition.
+	LogStartOffset int64
+	// Batches contains the fetched batches for this partition.
+	Batches []FetchBatch
+}
+
+// Batches returns the partition's records grouped into the batches they were
+// fetched in. Batches without any records (for example, because all records
+// were aborted, filtered, or already consumed) are not returned.
+//
+// If the partition was returned from PollRecords, the records of the first
+// and last batch may be a subset of the records in that batch, and the
+// remaining records are returned in later polls.
+//
+// If consuming with ConsumeRawBatches, this returns RawBatches.
+func (p *FetchPartition) Batches() []FetchBatch {
+	if len(p.RawBatches) > 0 {
+		return p.RawBatches
+	}
+	var (
+		batches []FetchBatch
+		records = p.Records
+	)
+	for _, b := range p.batches {
+		var n int
+		for n < len(records) && records[n].Offset <= b.LastOffset {
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		b.Records = records[:n:n]
+		records = records[n:]
+		batches = append(batches, b)
+	}
+	return batches
+}
+
+// EachBatch calls fn for each batch in the partition; see Batches.
+func (p *FetchPartition) EachBatch(fn func(FetchBatch)) {
+	for _, b := range p.Batches() {
+		fn(b)
+	}
+}
+
 // FetchTopic is a response for a fetched topic from a broker.
 type FetchTopic struct {
 	// Topic is the topic this is for.
@@ -321,6 +520,13 @@ type FetchError struct {
 	Err       error
 }
 
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("topic %s partition %d: %v", e.Topic, e.Partition, e.Err)
+}
+
+// Unwrap returns the underlying fetch error.
+func (e *FetchError) Unwrap() error { return e.Err }
+
 // Errors returns all errors in a fetch with the topic and partition that
 // errored.
 //
@@ -373,7 +579,7 @@ func (f Fetch) hasErrorsOrRecords() bool {
 		t := &f.Topics[i]
 		for j := range t.Partitions {
 			p := &t.Partitions[j]
-			if p.Err != nil || len(p.Records) > 0 {
+			if p.Err != nil || len(p.Records) > 0 || len(p.RawBatches) > 0 {
 				return true
 			}
 		}
@@ -567,6 +773,12 @@ func (fs Fetches) EachRecord(fn func(*Record)) {
 	}
 }
 
+// Recycle recycles every record in Fetches; see Record.Recycle and
+// PoolFetchBuffers.
+func (fs Fetches) Recycle() {
+	fs.EachRecord((*Record).Recycle)
+}
+
 // Records returns all records in all fetches.
 //
 // This is a convenience function that does a single slice allocation. If you
@@ -593,7 +805,7 @@ func (fs Fetches) Empty() bool {
 	for i := range fs {
 		for j := range fs[i].Topics {
 			for k := range fs[i].Topics[j].Partitions {
-				if len(fs[i].Topics[j].Partitions[k].Records) > 0 {
+				if p := &fs[i].Topics[j].Partitions[k]; len(p.Records) > 0 || len(p.RawBatches) > 0 {
 					return false
 				}
 			}
@@ -0,0 +1,149 @@
+package kgo
+
+import "strconv"
+
+// This file contains convenience functions for reading and writing record
+// headers. Typed values are encoded as strings (for example, "123" or "true"),
+// which is how most Kafka tooling displays header values, and matches how the
+// client writes its own headers (see DeadLetterHeaderPartition).
+
+// Header returns the value of the last header in the record with the given
+// key, and whether the header exists. Kafka allows a record to have multiple
+// headers with the same key; use EachHeader to see all of them.
+func (r *Record) Header(key string) ([]byte, bool) {
+	for i := len(r.Headers) - 1; i >= 0; i-- {
+		if r.Headers[i].Key == key {
+			return r.Headers[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// HeaderString returns the value of the last header in the record with the
+// given key as a string, and whether the header exists.
+func (r *Record) HeaderString(key string) (string, bool) {
+	v, ok := r.Header(key)
+	return string(v), ok
+}
+
+// HeaderInt64 returns the value of the last header in the record with the
+// given key parsed as a base 10 integer, and whether the header exists and
+// could be parsed.
+func (r *Record) HeaderInt64(key string) (int64, bool) {
+	v, ok := r.Header(key)
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(string(v), 10, 64)
+	return i, err == nil
+}
+
+// HeaderBool returns the value of the last header in the record with the given
+// key parsed with strconv.ParseBool, and whether the header exists and could
+// be parsed.
+func (r *Record) HeaderBool(key string) (bool, bool) {
+	v, ok := r.Header(key)
+	if !ok {
+		return false, false
+	}
+	b, err := strconv.ParseBool(string(v))
+	return b, err == nil
+}
+
+// EachHeader calls fn for each header in the record, in order.
+func (r *Record) EachHeader(fn func(key string, value []byte)) {
+	for _, h := range r.Headers {
+		fn(h.Key, h.Value)
+	}
+}
+
+// SetHeader sets the header with the given key to value, replacing all
+// existing headers with the same key. If the record already had a header with
+// the key, the new header replaces the first such header in place; otherwise,
+// the header is added to the end.
+func (r *Record) SetHeader(key string, value []byte) {
+	at := -1
+	keep := r.Headers[:0]
+	for _, h := range r.Headers {
+		if h.Key == key {
+			if at >= 0 {
+				continue
+			}
+			at = len(keep)
+			h.Value = value
+		}
+		keep = append(keep, h)
+	}
+	for i := len(keep); i < len(r.Headers); i++ {
+		r.Headers[i] = RecordHeader{} // allow dropped values to be garbage collected
+	}
+	r.Headers = keep
+	if at < 0 {
+		r.Headers = append(r.Headers, RecordHeader{Key: key, Value: value})
+	}
+}
+
+// SetHeaderString sets the header with the given key to the string value; see
+// SetHeader.
+func (r *Record) SetHeaderString(key, value string) {
+	r.SetHeader(key, []byte(value))
+}
+
+// SetHeaderInt64 sets the header with the given key to the base 10 string
+// encoding of value; see SetHeader.
+func (r *Record) SetHeaderInt64(key string, value int64) {
+	r.SetHeader(key, strconv.AppendInt(nil, value, 10))
+}
+
+// SetHeaderBool sets the header with the given key to "true" or "false"; see
+// SetHeader.
+func (r *Record) SetHeaderBool(key string, value bool) {
+	r.SetHeader(key, strconv.AppendBool(nil, value))
+}
+
+// DeleteHeader deletes all headers in the record with the given key.
+func (r *Record) DeleteHeader(key string) {
+	keep := r.Headers[:0]
+	for _, h := range r.Headers {
+		if h.Key != key {
+			keep = append(keep, h)
+		}
+	}
+	for i := len(keep); i < len(r.Headers); i++ {
+		r.Headers[i] = RecordHeader{}
+	}
+	r.Headers = keep
+}
+
+// RecordHeaders builds a slice of record headers. Each function appends a
+// header and returns the new slice, allowing chaining:
+//
+//	r.Headers = kgo.RecordHeaders{}.
+//		AddString("source", "billing").
+//		AddInt64("attempt", 3).
+//		AddBool("replay", false)
+//
+// Unlike the Set functions on Record, the Add functions do not replace
+// existing headers with the same key.
+type RecordHeaders []RecordHeader
+
+// Add appends a header with the given key and value.
+func (hs RecordHeaders) Add(key string, value []byte) RecordHeaders {
+	return append(hs, RecordHeader{Key: key, Value: value})
+}
+
+// AddString appends a header with the given key and string value.
+func (hs RecordHeaders) AddString(key, value string) RecordHeaders {
+	return hs.Add(key, []byte(value))
+}
+
+// AddInt64 appends a header with the given key and the base 10 string encoding
+// of value.
+func (hs RecordHeaders) AddInt64(key string, value int64) RecordHeaders {
+	return hs.Add(key, strconv.AppendInt(nil, value, 10))
+}
+
+// AddBool appends a header with the given key and "true" or "false".
+func (hs RecordHeaders) AddBool(key string, value bool) RecordHeaders {
+	return hs.Add(key, strconv.AppendBool(nil, value))
+}
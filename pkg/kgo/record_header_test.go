@@ -0,0 +1,48 @@
+package kgo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRecordHeaders(t *testing.T) {
+	r := &Record{Headers: RecordHeaders{}.
+		AddString("a", "1").
+		AddInt64("n", -42).
+		AddString("a", "2").
+		AddBool("b", true).
+		Add("raw", []byte{0xff}),
+	}
+
+	if v, ok := r.HeaderString("a"); !ok || v != "2" {
+		t.Errorf("got a=%q (ok? %v), exp last value 2", v, ok)
+	}
+	if v, ok := r.HeaderInt64("n"); !ok || v != -42 {
+		t.Errorf("got n=%d (ok? %v), exp -42", v, ok)
+	}
+	if v, ok := r.HeaderBool("b"); !ok || !v {
+		t.Errorf("got b=%v (ok? %v), exp true", v, ok)
+	}
+	if _, ok := r.HeaderInt64("raw"); ok {
+		t.Error("unexpected ok parsing non-integer header")
+	}
+	if _, ok := r.Header("missing"); ok {
+		t.Error("unexpected ok for missing header")
+	}
+
+	r.SetHeaderString("a", "3")
+	r.SetHeaderInt64("new", 7)
+	r.DeleteHeader("raw")
+
+	var keys []string
+	r.EachHeader(func(k string, _ []byte) { keys = append(keys, k) })
+	if exp := []string{"a", "n", "b", "new"}; !reflect.DeepEqual(keys, exp) {
+		t.Errorf("got header keys %v, exp %v", keys, exp)
+	}
+	if v, _ := r.HeaderString("a"); v != "3" {
+		t.Errorf("got a=%q after set, exp 3", v)
+	}
+	if v, _ := r.HeaderString("new"); v != "7" {
+		t.Errorf("got new=%q after set, exp 7", v)
+	}
+}
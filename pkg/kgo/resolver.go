@@ -0,0 +1,53 @@
+package kgo
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// HostResolver resolves hostnames to addresses, as configured with the
+// Resolver option. The returned addresses can be IPs or other hostnames; they
+// are dialed in order until one succeeds. *net.Resolver satisfies this
+// interface.
+type HostResolver interface {
+	LookupHost(ctx context.Context, host string) (addrs []string, err error)
+}
+
+// dialResolved dials addr with dialer, resolving the host in addr with r
+// first if r is non-nil and the host is not already an IP address. Each
+// resolved address is tried in order until one connects, and the first dial
+// error is returned if none do.
+func dialResolved(ctx context.Context, r HostResolver, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	if r == nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to split host:port for dialing: %w", err)
+	}
+	if net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	addrs, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve %s: %w", host, err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("unable to resolve %s: no addresses", host)
+	}
+	var firstErr error
+	for _, resolved := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(resolved, port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
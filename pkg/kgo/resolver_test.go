@@ -0,0 +1,60 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testResolver struct {
+	lookups int64
+	addrs   map[string][]string
+}
+
+func (r *testResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	atomic.AddInt64(&r.lookups, 1)
+	addrs, ok := r.addrs[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return addrs, nil
+}
+
+func TestDialResolved(t *testing.T) {
+	target := listenTest(t, echoServe)
+	_, port, _ := net.SplitHostPort(target)
+
+	r := &testResolver{addrs: map[string][]string{
+		// The first address is refused, the second succeeds.
+		"broker.internal": {"127.0.0.2", "127.0.0.1"},
+	}}
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+
+	for i := 0; i < 2; i++ {
+		conn, err := dialResolved(context.Background(), r, dialer, "tcp", net.JoinHostPort("broker.internal", port))
+		if err != nil {
+			t.Fatalf("unable to dial: %v", err)
+		}
+		expEcho(t, conn)
+	}
+	if lookups := atomic.LoadInt64(&r.lookups); lookups != 2 {
+		t.Errorf("got %d lookups != exp 2, hosts should be resolved on every dial", lookups)
+	}
+
+	// IPs are dialed directly.
+	conn, err := dialResolved(context.Background(), r, dialer, "tcp", target)
+	if err != nil {
+		t.Fatalf("unable to dial: %v", err)
+	}
+	expEcho(t, conn)
+	if lookups := atomic.LoadInt64(&r.lookups); lookups != 2 {
+		t.Errorf("got %d lookups != exp 2, IPs should not be resolved", lookups)
+	}
+
+	if _, err := dialResolved(context.Background(), r, dialer, "tcp", net.JoinHostPort("unknown.internal", port)); err == nil {
+		t.Error("unexpected success dialing an unresolvable host")
+	}
+}
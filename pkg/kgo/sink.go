@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"hash/crc32"
 	"math"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -84,8 +85,11 @@ func (s *sink) createReq(id int64, epoch int16) (*produceRequest, *kmsg.AddParti
 		producerID:    id,
 		producerEpoch: epoch,
 
-		hasHook:    s.cl.producer.hasHookBatchWritten,
-		compressor: s.cl.compressor,
+		hasHook:          s.cl.producer.hasHookBatchWritten,
+		hasLatencyHook:   s.cl.producer.hasHookBatchLatency && s.cl.cfg.acks.val != 0,
+		affinity:         s.cl.cfg.batchAffinity,
+		compressor:       s.cl.compressor,
+		topicCompressors: s.cl.topicCompressors,
 
 		wireLength:      s.cl.baseProduceRequestLength(), // start length with no topics
 		wireLengthLimit: s.cl.cfg.maxBrokerWriteBytes,
@@ -101,10 +105,13 @@ func (s *sink) createReq(id int64, epoch int16) (*produceRequest, *kmsg.AddParti
 	s.recBufsMu.Lock()
 	defer s.recBufsMu.Unlock()
 
-	recBufsIdx := s.recBufsStart
-	for i := 0; i < len(s.recBufs); i++ {
-		recBuf := s.recBufs[recBufsIdx]
-		recBufsIdx = (recBufsIdx + 1) % len(s.recBufs)
+	recBufs, recBufsIdx := s.recBufs, s.recBufsStart
+	if s.cl.producer.hasPriorities.Load() {
+		recBufs, recBufsIdx = s.recBufsByPriority(), 0
+	}
+	for i := 0; i < len(recBufs); i++ {
+		recBuf := recBufs[recBufsIdx]
+		recBufsIdx = (recBufsIdx + 1) % len(recBufs)
 
 		recBuf.mu.Lock()
 		if recBuf.failing || len(recBuf.batches) == recBuf.batchDrainIdx || recBuf.inflightOnSink != nil && recBuf.inflightOnSink != s || recBuf.inflight != 0 && !recBuf.okOnSink {
@@ -112,7 +119,21 @@ func (s *sink) createReq(id int64, epoch int16) (*produceRequest, *kmsg.AddParti
 			continue
 		}
 
+		// With manual flushing, if only some topics are being
+		// flushed, we skip draining any other topic.
+		if s.cl.cfg.manualFlushing && !s.cl.producer.isFlushing(recBuf.topic) {
+			recBuf.mu.Unlock()
+			continue
+		}
+
 		batch := recBuf.batches[recBuf.batchDrainIdx]
+		if batch.tries == 0 && s.cl.producer.hasExpiry.Load() {
+			if !recBuf.expireRecords(batch, time.Now()) {
+				moreToDrain = moreToDrain || recBuf.batchDrainIdx < len(recBuf.batches)
+				recBuf.mu.Unlock()
+				continue
+			}
+		}
 		if added := req.tryAddBatch(s.produceVersion.Load(), recBuf, batch); !added {
 			recBuf.mu.Unlock()
 			moreToDrain = true
@@ -138,6 +159,34 @@ func (s *sink) createReq(id int64, epoch int16) (*produceRequest, *kmsg.AddParti
 	return req, txnBuilder.req, moreToDrain
 }
 
+// recBufsByPriority returns the sink's recBufs in drain order: starting from
+// recBufsStart, stably sorted so that recBufs whose next batch to drain has a
+// higher priority come first. This must be called with recBufsMu held.
+func (s *sink) recBufsByPriority() []*recBuf {
+	type prioRecBuf struct {
+		recBuf   *recBuf
+		priority int8
+	}
+	ps := make([]prioRecBuf, 0, len(s.recBufs))
+	for i := range s.recBufs {
+		recBuf := s.recBufs[(s.recBufsStart+i)%len(s.recBufs)]
+		priority := int8(math.MinInt8) // nothing to drain; sort last
+		recBuf.mu.Lock()
+		if recBuf.batchDrainIdx < len(recBuf.batches) {
+			priority = recBuf.batches[recBuf.batchDrainIdx].priority
+		}
+		recBuf.mu.Unlock()
+		ps = append(ps, prioRecBuf{recBuf, priority})
+	}
+	sort.SliceStable(ps, func(i, j int) bool { return ps[i].priority > ps[j].priority })
+
+	recBufs := make([]*recBuf, 0, len(ps))
+	for _, p := range ps {
+		recBufs = append(recBufs, p.recBuf)
+	}
+	return recBufs
+}
+
 func incrementSequence(sequence, increment int32) int32 {
 	if sequence > math.MaxInt32-increment {
 		return increment - (math.MaxInt32 - sequence) - 1
@@ -181,7 +230,7 @@ func (t *txnReqBuilder) add(rb *recBuf) {
 }
 
 func (s *sink) maybeDrain() {
-	if s.cl.cfg.manualFlushing && s.cl.producer.flushing.Load() == 0 {
+	if s.cl.cfg.manualFlushing && s.cl.producer.flushing.Load() == 0 && s.cl.producer.topicFlushes.Load() == 0 {
 		return
 	}
 	if s.drainState.maybeBegin() {
@@ -576,7 +625,27 @@ func (s *sink) handleReqRespNoack(b *bytes.Buffer, debug bool, req *produceReque
 	}
 }
 
+// noteLatency updates the produce latency average of every partition in the
+// request. A failed request counts as taking at least penalty, so that
+// partitions on a broker that is timing out or resetting connections look
+// slow rather than fast.
+func (req *produceRequest) noteLatency(err error, penalty time.Duration) {
+	if req.writtenAt.IsZero() {
+		return // never written
+	}
+	latency := time.Since(req.writtenAt)
+	if err != nil && latency < penalty {
+		latency = penalty
+	}
+	for _, partitions := range req.batches {
+		for _, batch := range partitions {
+			batch.owner.noteLatency(int64(latency))
+		}
+	}
+}
+
 func (s *sink) handleReqResp(br *broker, req *produceRequest, resp kmsg.Response, err error) {
+	req.noteLatency(err, s.cl.cfg.produceTimeout)
 	if err != nil {
 		s.handleReqClientErr(req, err)
 		return
@@ -584,6 +653,8 @@ func (s *sink) handleReqResp(br *broker, req *produceRequest, resp kmsg.Response
 	s.firstRespCheck(req.idempotent(), req.version)
 	s.consecutiveFailures.Store(0)
 	defer req.metrics.hook(&s.cl.cfg, br) // defer to end so that non-written batches are removed
+	brokerLatency := time.Since(req.writtenAt)
+	defer req.latencies.hook(&s.cl.cfg, br, brokerLatency)
 
 	var b *bytes.Buffer
 	debug := s.cl.cfg.logger.Level() >= LogLevelDebug
@@ -610,6 +681,7 @@ func (s *sink) handleReqResp(br *broker, req *produceRequest, resp kmsg.Response
 		if !ok {
 			s.cl.cfg.logger.Log(LogLevelError, "broker erroneously replied with topic in produce request that we did not produce to", "broker", logID(s.nodeID), "topic", topic)
 			delete(req.metrics, topic)
+			delete(req.latencies, topic)
 			continue // should not hit this
 		}
 
@@ -618,12 +690,14 @@ func (s *sink) handleReqResp(br *broker, req *produceRequest, resp kmsg.Response
 		}
 
 		tmetrics := req.metrics[topic]
+		tlatencies := req.latencies[topic]
 		for _, rPartition := range rTopic.Partitions {
 			partition := rPartition.Partition
 			batch, ok := partitions[partition]
 			if !ok {
 				s.cl.cfg.logger.Log(LogLevelError, "broker erroneously replied with partition in produce request that we did not produce to", "broker", logID(s.nodeID), "topic", rTopic.Topic, "partition", partition)
 				delete(tmetrics, partition)
+				delete(tlatencies, partition)
 				continue // should not hit this
 			}
 			delete(partitions, partition)
@@ -643,6 +717,7 @@ func (s *sink) handleReqResp(br *broker, req *produceRequest, resp kmsg.Response
 			}
 			if !didProduce {
 				delete(tmetrics, partition)
+				delete(tlatencies, partition)
 			}
 		}
 
@@ -719,7 +794,8 @@ func (s *sink) handleReqRespBatch(
 	case kerr.IsRetriable(err) &&
 		!failUnknown &&
 		err != kerr.CorruptMessage &&
-		batch.tries < s.cl.cfg.recordRetries:
+		batch.tries < s.cl.cfg.recordRetries.load() &&
+		s.cl.policyRetriesProduce(batch.tries, err):
 
 		if debug {
 			fmt.Fprintf(b, "retrying@%d,%d(%s)}, ", baseOffset, nrec, err)
@@ -831,9 +907,12 @@ func (s *sink) handleReqRespBatch(
 				"partition", partition,
 				"err", err,
 				"err_is_retryable", kerr.IsRetriable(err),
-				"max_retries_reached", !failUnknown && batch.tries >= s.cl.cfg.recordRetries,
+				"max_retries_reached", !failUnknown && batch.tries >= s.cl.cfg.recordRetries.load(),
 			)
 			batch.owner.okOnSink = false
+			if err == kerr.MessageTooLarge && s.cl.cfg.validateMaxMsgBytes {
+				s.cl.producer.forgetMaxMessageBytes(topic)
+			}
 		} else {
 			batch.owner.okOnSink = true
 		}
@@ -871,6 +950,9 @@ func (cl *Client) finishBatch(batch *recBatch, producerID int64, producerEpoch i
 	finished := len(batch.records)
 	recBuf.batch0Seq = incrementSequence(recBuf.batch0Seq, int32(finished))
 	recBuf.buffered.Add(-int64(finished))
+	recBuf.partBytes.sub(batch.bufferedBytes)
+	recBuf.topicBytes.sub(batch.bufferedBytes)
+	recBuf.cl.producer.prioBufferedBytes(batch.priority).sub(batch.bufferedBytes)
 	recBuf.batches[0] = nil
 	recBuf.batches = recBuf.batches[1:]
 	recBuf.batchDrainIdx--
@@ -1032,6 +1114,17 @@ type recBuf struct {
 	// of records buffered in total on this recBuf.
 	buffered atomicI64
 
+	// latency is a moving average of the produce latency of requests
+	// containing this partition, in nanoseconds, for partitioners that
+	// steer records away from slow partitions.
+	latency atomicI64
+
+	// partBytes and topicBytes track buffered record bytes for
+	// MaxBufferedBytesPerPartition and MaxBufferedBytesPerTopic, and are
+	// nil if the corresponding limit is not set.
+	partBytes  *bufferedBytes
+	topicBytes *bufferedBytes
+
 	mu sync.Mutex // guards r/w access to all fields below
 
 	// sink is who is currently draining us. This can be modified
@@ -1184,7 +1277,7 @@ func (recBuf *recBuf) bufferRecord(pr promisedRec, abortOnNewBatch bool) bool {
 		recBuf.batches = append(recBuf.batches, newBatch)
 	}
 
-	if recBuf.cl.cfg.linger == 0 {
+	if recBuf.cl.cfg.linger.load() == 0 {
 		if onDrainBatch {
 			recBuf.sink.maybeDrain()
 		}
@@ -1202,6 +1295,13 @@ func (recBuf *recBuf) bufferRecord(pr promisedRec, abortOnNewBatch bool) bool {
 	}
 
 	recBuf.buffered.Add(1)
+	prioBytes := recBuf.cl.producer.prioBufferedBytes(pr.Priority)
+	if recBuf.partBytes != nil || recBuf.topicBytes != nil || prioBytes != nil {
+		n := recordBufferedBytes(pr.Record)
+		recBuf.partBytes.add(n)
+		recBuf.topicBytes.add(n)
+		prioBytes.add(n)
+	}
 
 	if recBuf.cl.producer.hooks != nil && len(recBuf.cl.producer.hooks.partitioned) > 0 {
 		for _, h := range recBuf.cl.producer.hooks.partitioned {
@@ -1220,7 +1320,7 @@ func (recBuf *recBuf) bufferRecord(pr promisedRec, abortOnNewBatch bool) bool {
 // lingering, then we are flushing and also indicate there is more to drain.
 func (recBuf *recBuf) tryStopLingerForDraining() bool {
 	recBuf.lockedStopLinger()
-	canLinger := recBuf.cl.cfg.linger == 0
+	canLinger := recBuf.cl.cfg.linger.load() == 0
 	moreToDrain := !canLinger && len(recBuf.batches) > recBuf.batchDrainIdx ||
 		canLinger && (len(recBuf.batches) > recBuf.batchDrainIdx+1 ||
 			len(recBuf.batches) == recBuf.batchDrainIdx+1 && !recBuf.lockedMaybeStartLinger())
@@ -1229,10 +1329,10 @@ func (recBuf *recBuf) tryStopLingerForDraining() bool {
 
 // Begins a linger timer unless the producer is being flushed.
 func (recBuf *recBuf) lockedMaybeStartLinger() bool {
-	if recBuf.cl.producer.flushing.Load() > 0 {
+	if recBuf.cl.producer.isFlushing(recBuf.topic) {
 		return false
 	}
-	recBuf.lingering = time.AfterFunc(recBuf.cl.cfg.linger, recBuf.sink.maybeDrain)
+	recBuf.lingering = time.AfterFunc(time.Duration(recBuf.cl.cfg.linger.load()), recBuf.sink.maybeDrain)
 	return true
 }
 
@@ -1243,6 +1343,14 @@ func (recBuf *recBuf) lockedStopLinger() {
 	}
 }
 
+// lockedBuffered returns whether the buffer has any records, locking the
+// buffer so that any records that were just finished have been promised.
+func (recBuf *recBuf) lockedBuffered() bool {
+	recBuf.mu.Lock()
+	defer recBuf.mu.Unlock()
+	return len(recBuf.batches) > 0
+}
+
 func (recBuf *recBuf) unlingerAndManuallyDrain() {
 	recBuf.mu.Lock()
 	defer recBuf.mu.Unlock()
@@ -1250,6 +1358,16 @@ func (recBuf *recBuf) unlingerAndManuallyDrain() {
 	recBuf.sink.maybeDrain()
 }
 
+// noteLatency folds a produce latency into the partition's moving average,
+// weighing the new latency at one fifth. Concurrent responses may race and
+// drop an update, which is fine for a heuristic.
+func (recBuf *recBuf) noteLatency(latency int64) {
+	if prior := recBuf.latency.Load(); prior > 0 {
+		latency = prior - prior/5 + latency/5
+	}
+	recBuf.latency.Store(latency)
+}
+
 // bumpRepeatedLoadErr is provided to bump a buffer's number of consecutive
 // load errors during metadata updates.
 //
@@ -1314,7 +1432,11 @@ func (recBuf *recBuf) checkUnknownFailLimit(err error) bool {
 //   - if batch fails fatally when producing
 func (recBuf *recBuf) failAllRecords(err error) {
 	recBuf.lockedStopLinger()
+	var failedBytes int64
 	for _, batch := range recBuf.batches {
+		failedBytes += batch.bufferedBytes
+		recBuf.cl.producer.prioBufferedBytes(batch.priority).sub(batch.bufferedBytes)
+
 		// We need to guard our clearing of records against a
 		// concurrent produceRequest's write, which can have this batch
 		// buffered wile we are failing.
@@ -1334,9 +1456,78 @@ func (recBuf *recBuf) failAllRecords(err error) {
 	}
 	recBuf.resetBatchDrainIdx()
 	recBuf.buffered.Store(0)
+	recBuf.partBytes.sub(failedBytes)
+	recBuf.topicBytes.sub(failedBytes)
 	recBuf.batches = nil
 }
 
+// expireRecords fails any records in an unsent batch that are past their
+// WithRecordExpiry deadline, rebuilding the batch from the records that
+// remain. If every record expired, the batch is removed and this returns
+// false. This must be called with recBuf.mu held.
+func (recBuf *recBuf) expireRecords(batch *recBatch, now time.Time) bool {
+	expired := func(pr promisedRec) bool {
+		deadline, ok := recordExpiry(pr.Record.Context)
+		return ok && !now.Before(deadline)
+	}
+	var anyExpired bool
+	for _, pr := range batch.records {
+		if anyExpired = expired(pr); anyExpired {
+			break
+		}
+	}
+	if !anyExpired {
+		return true
+	}
+
+	// The batch has never been sent, so no request can be reading it
+	// and its records do not yet have sequence numbers: we can rebuild
+	// it in place.
+	var (
+		rebuilt      = recBuf.newRecordBatch()
+		expiredRecs  []promisedRec
+		expiredBytes int64
+	)
+	for _, pr := range batch.records {
+		if expired(pr) {
+			expiredRecs = append(expiredRecs, pr)
+			expiredBytes += recordBufferedBytes(pr.Record)
+			continue
+		}
+		nums := rebuilt.calculateRecordNumbers(pr.Record)
+		rebuilt.appendRecord(pr, nums)
+		pr.setLengthAndTimestampDelta(
+			nums.lengthField,
+			nums.tsDelta,
+		)
+	}
+
+	recBuf.buffered.Add(-int64(len(expiredRecs)))
+	recBuf.partBytes.sub(expiredBytes)
+	recBuf.topicBytes.sub(expiredBytes)
+	recBuf.cl.producer.prioBufferedBytes(batch.priority).sub(expiredBytes)
+	recBuf.cl.producer.promiseBatch(batchPromise{
+		recs: expiredRecs,
+		err:  ErrRecordExpired,
+	})
+
+	if len(rebuilt.records) == 0 {
+		recBuf.cl.prsPool.put(rebuilt.records)
+		recBuf.batches = append(recBuf.batches[:recBuf.batchDrainIdx], recBuf.batches[recBuf.batchDrainIdx+1:]...)
+		return false
+	}
+
+	batch.mu.Lock()
+	batch.records = rebuilt.records
+	batch.mu.Unlock()
+	batch.wireLength = rebuilt.wireLength
+	batch.v1wireLength = rebuilt.v1wireLength
+	batch.bufferedBytes = rebuilt.bufferedBytes
+	batch.firstTimestamp = rebuilt.firstTimestamp
+	batch.maxTimestampDelta = rebuilt.maxTimestampDelta
+	return true
+}
+
 // clearFailing clears a buffer's failing state if it is failing.
 //
 // This is called when a buffer is added to a sink (to clear a failing state
@@ -1382,10 +1573,15 @@ type recBatch struct {
 	wireLength   int32 // tracks total size this batch would currently encode as, including length prefix
 	v1wireLength int32 // same as wireLength, but for message set v1
 
+	bufferedBytes int64 // sum of recordBufferedBytes for all records
+	priority      int8  // Record.Priority of all records in this batch
+
 	attrs             int16 // updated during apending; read and converted to RecordAttrs on success
 	firstTimestamp    int64 // since unix epoch, in millis
 	maxTimestampDelta int64
 
+	packed bool // if records have been sorted by affinity; only done once, on the first write
+
 	mu      sync.Mutex    // guards appendTo's reading of records against failAllRecords emptying it
 	records []promisedRec // record w/ length, ts calculated
 }
@@ -1403,7 +1599,7 @@ func (b *recBatch) maybeFailErr(cfg *cfg) error {
 	switch {
 	case b.isTimedOut(cfg.recordTimeout):
 		return ErrRecordTimeout
-	case b.tries >= cfg.recordRetries:
+	case b.tries >= cfg.recordRetries.load():
 		return ErrRecordRetries
 	case b.owner.cl.producer.isAborting():
 		return ErrAborting
@@ -1426,7 +1622,9 @@ func (b *recBatch) flexibleWireLength() int32 { // uvarint length prefix
 func (b *recBatch) appendRecord(pr promisedRec, nums recordNumbers) {
 	b.wireLength += nums.wireLength()
 	b.v1wireLength += messageSet1Length(pr.Record)
+	b.bufferedBytes += recordBufferedBytes(pr.Record)
 	if len(b.records) == 0 {
+		b.priority = pr.Priority
 		b.firstTimestamp = pr.Timestamp.UnixNano() / 1e6
 	} else if nums.tsDelta > b.maxTimestampDelta {
 		b.maxTimestampDelta = nums.tsDelta
@@ -1537,7 +1735,16 @@ type produceRequest struct {
 	metrics produceMetrics
 	hasHook bool
 
-	compressor *compressor
+	// Also initialized in AppendTo, latencies tracks how long each batch
+	// was buffered before being written at writtenAt. We use this in
+	// handleReqResp for the OnProduceBatchLatency hook.
+	latencies      produceLatencies
+	writtenAt      time.Time
+	hasLatencyHook bool
+
+	compressor       *compressor
+	topicCompressors map[string]*compressor
+	affinity         func(*Record) []byte
 
 	// wireLength is initially the size of sending a produce request,
 	// including the request header, with no topics. We start with the
@@ -1573,6 +1780,30 @@ func (p produceMetrics) hook(cfg *cfg, br *broker) {
 	}()
 }
 
+type produceLatencies map[string]map[int32]ProduceLatencyMetrics
+
+func (p produceLatencies) hook(cfg *cfg, br *broker, brokerLatency time.Duration) {
+	if len(p) == 0 {
+		return
+	}
+	var hooks []HookProduceBatchLatency
+	cfg.hooks.each(func(h Hook) {
+		if h, ok := h.(HookProduceBatchLatency); ok {
+			hooks = append(hooks, h)
+		}
+	})
+	go func() {
+		for _, h := range hooks {
+			for topic, partitions := range p {
+				for partition, metrics := range partitions {
+					metrics.BrokerLatency = brokerLatency
+					h.OnProduceBatchLatency(br.meta, topic, partition, metrics)
+				}
+			}
+		}
+	}()
+}
+
 func (p *produceRequest) idempotent() bool { return p.producerID >= 0 }
 
 func (p *produceRequest) tryAddBatch(produceVersion int32, recBuf *recBuf, batch *recBatch) bool {
@@ -1764,6 +1995,26 @@ func (cl *Client) maxRecordBatchBytesForTopic(topic string) int32 {
 	return recordBatchLimit
 }
 
+// singleRecordBatchLength returns the length of a record batch containing
+// only r, which is what a broker compares against max.message.bytes.
+func singleRecordBatchLength(r *Record) int32 {
+	const recordBatchOverhead = 8 + // firstOffset
+		4 + // batchLength
+		4 + // partitionLeaderEpoch
+		1 + // magic
+		4 + // crc
+		2 + // attributes
+		4 + // lastOffsetDelta
+		8 + // firstTimestamp
+		8 + // maxTimestamp
+		8 + // producerID
+		2 + // producerEpoch
+		4 + // seq
+		4 // record array length
+	nums := new(recBatch).calculateRecordNumbers(r)
+	return recordBatchOverhead + nums.wireLength()
+}
+
 func messageSet0Length(r *Record) int32 {
 	const length = 4 + // array len
 		8 + // offset
@@ -1866,7 +2117,7 @@ func (b *recBatch) tryBuffer(pr promisedRec, produceVersion, maxBatchBytes int32
 	batchWireLength, _ := b.wireLengthForProduceVersion(produceVersion)
 	newBatchLength := batchWireLength + nums.wireLength()
 
-	if b.tries != 0 || newBatchLength > maxBatchBytes {
+	if b.tries != 0 || newBatchLength > maxBatchBytes || len(b.records) > 0 && b.priority != pr.Priority {
 		return false, false
 	}
 	if abortOnNewBatch {
@@ -1895,6 +2146,10 @@ func (p *produceRequest) AppendTo(dst []byte) []byte {
 	if p.hasHook {
 		p.metrics = make(map[string]map[int32]ProduceBatchMetrics)
 	}
+	p.writtenAt = time.Now()
+	if p.hasLatencyHook {
+		p.latencies = make(produceLatencies)
+	}
 
 	if p.version >= 3 {
 		if flexible {
@@ -1926,6 +2181,16 @@ func (p *produceRequest) AppendTo(dst []byte) []byte {
 			tmetrics = make(map[int32]ProduceBatchMetrics)
 			p.metrics[topic] = tmetrics
 		}
+		var tlatencies map[int32]ProduceLatencyMetrics
+		if p.hasLatencyHook {
+			tlatencies = make(map[int32]ProduceLatencyMetrics)
+			p.latencies[topic] = tlatencies
+		}
+
+		compressor := p.compressor
+		if c, ok := p.topicCompressors[topic]; ok {
+			compressor = c
+		}
 
 		for partition, batch := range partitions {
 			dst = kbin.AppendInt32(dst, partition)
@@ -1940,11 +2205,20 @@ func (p *produceRequest) AppendTo(dst []byte) []byte {
 				continue
 			}
 			batch.canFailFromLoadErrs = false // we are going to write this batch: the response status is now unknown
+			if p.hasLatencyHook {
+				tlatencies[partition] = ProduceLatencyMetrics{
+					NumRecords:    len(batch.records),
+					BufferLatency: p.writtenAt.Sub(batch.records[0].Timestamp),
+				}
+			}
 			var pmetrics ProduceBatchMetrics
+			if p.affinity != nil && p.version >= 3 {
+				batch.packByAffinity(p.affinity)
+			}
 			if p.version < 3 {
-				dst, pmetrics = batch.appendToAsMessageSet(dst, uint8(p.version), p.compressor)
+				dst, pmetrics = batch.appendToAsMessageSet(dst, uint8(p.version), compressor)
 			} else {
-				dst, pmetrics = batch.appendTo(dst, p.version, p.producerID, p.producerEpoch, p.txnID != nil, p.compressor)
+				dst, pmetrics = batch.appendTo(dst, p.version, p.producerID, p.producerEpoch, p.txnID != nil, compressor)
 			}
 			batch.mu.Unlock()
 			if p.hasHook {
@@ -2096,6 +2370,65 @@ func (b seqRecBatch) appendTo(
 	return dst, m
 }
 
+// packByAffinity stably sorts the batch's records by affinity for
+// ProducerBatchAffinity. This is only done the first time a batch is written,
+// so that retries write the exact same batch.
+//
+// A record's length field includes its offset delta, which is its position in
+// the batch, so we fix each moved record's length. The varint encoding of the
+// length field itself can then change size (at 64 bytes, 8192 bytes, ...), so
+// we recompute the batch's wire length from the new record lengths. If the
+// new length would exceed the max batch size, we leave the batch as is.
+// Timestamp deltas are relative to the batch's first timestamp, which does not
+// change.
+func (b *recBatch) packByAffinity(fn func(*Record) []byte) {
+	if b.packed {
+		return
+	}
+	b.packed = true
+	if len(b.records) < 2 {
+		return
+	}
+
+	type affinity struct {
+		idx int
+		key []byte
+	}
+	affinities := make([]affinity, len(b.records))
+	for i, pr := range b.records {
+		affinities[i] = affinity{i, fn(pr.Record)}
+	}
+	sort.SliceStable(affinities, func(i, j int) bool {
+		return bytes.Compare(affinities[i].key, affinities[j].key) < 0
+	})
+
+	lengths := make([]int32, len(b.records))
+	wireLength := b.wireLength
+	for i, a := range affinities {
+		length, _ := b.records[a.idx].lengthAndTimestampDelta()
+		lengths[i] = length
+		if i != a.idx {
+			lengths[i] += int32(kbin.VarintLen(int32(i)) - kbin.VarintLen(int32(a.idx)))
+			wireLength += recordNumbers{lengthField: lengths[i]}.wireLength() - recordNumbers{lengthField: length}.wireLength()
+		}
+	}
+	if b.owner != nil && b.owner.maxRecordBatchBytes > 0 && wireLength > b.owner.maxRecordBatchBytes {
+		return
+	}
+
+	sorted := make([]promisedRec, len(b.records))
+	for i, a := range affinities {
+		pr := b.records[a.idx]
+		if i != a.idx {
+			_, tsDelta := pr.lengthAndTimestampDelta()
+			pr.setLengthAndTimestampDelta(lengths[i], tsDelta)
+		}
+		sorted[i] = pr
+	}
+	copy(b.records, sorted)
+	b.wireLength = wireLength
+}
+
 func (pr promisedRec) appendTo(dst []byte, offsetDelta int32) []byte {
 	length, tsDelta := pr.lengthAndTimestampDelta()
 	dst = kbin.AppendVarint(dst, length)
@@ -7,6 +7,7 @@ import (
 	"hash/crc32"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/twmb/franz-go/pkg/kbin"
@@ -99,6 +100,10 @@ type cursor struct {
 	unknownIDFails atomicI32
 
 	keepControl bool // whether to keep control records
+	rawBatches  bool // whether to pass record batches through undecoded
+	poolBuffers bool // whether to decompress into pooled buffers
+
+	valueTransformer ValueTransformer // non-nil if values are transformed for this topic
 
 	cursorsIdx int // updated under source mutex
 
@@ -128,6 +133,30 @@ type cursor struct {
 
 	topicPartitionData // updated in metadata when session is stopped
 
+	// replicas is every replica of this partition ([]int32), updated on
+	// every metadata update and read when choosing where to hedge a slow
+	// fetch (FetchHedgeAfter).
+	replicas atomic.Value
+
+	// position mirrors cursorOffset.offset for Positions, which reads it
+	// outside of any session.
+	position atomicI64
+
+	// replicaSince is when the cursor moved to a replica that is not the
+	// leader, and replicaErrs is the number of consecutive fetch errors
+	// from that replica, for PreferredReplicaMaxAge and
+	// PreferredReplicaMaxErrors. Like fetchBytes, these are only used
+	// while the cursor is in use or while its session is stopped.
+	replicaSince time.Time
+	replicaErrs  int
+
+	// fetchBytes is the adaptive per-partition fetch size if
+	// AdaptiveFetchMaxPartitionBytes is in use, or 0 to use the configured
+	// FetchMaxPartitionBytes. This is read when building a fetch request
+	// and updated when handling the response, both of which require the
+	// cursor to be in use, so no synchronization is needed.
+	fetchBytes int32
+
 	// cursorOffset is our epoch/offset that we are consuming. When a fetch
 	// request is issued, we "freeze" a view of the offset and of the
 	// leader epoch (see cursorOffsetNext for why the leader epoch). When a
@@ -167,6 +196,7 @@ func (c *cursor) use() *cursorOffsetNext {
 		cursorOffset:       c.cursorOffset,
 		from:               c,
 		currentLeaderEpoch: c.leaderEpoch,
+		fetchBytes:         c.fetchBytes,
 	}
 }
 
@@ -175,6 +205,7 @@ func (c *cursor) use() *cursorOffsetNext {
 // This also unsets the cursor offset, which is assumed to be unused now.
 func (c *cursor) unset() {
 	c.useState.Store(false)
+	c.fetchBytes = 0
 	c.setOffset(cursorOffset{
 		offset:            -1,
 		lastConsumedEpoch: -1,
@@ -201,6 +232,7 @@ func (c *cursor) allowUsable() {
 // after.
 func (c *cursor) setOffset(o cursorOffset) {
 	c.cursorOffset = o
+	c.position.Store(o.offset)
 }
 
 // cursorOffsetNext is updated while processing a fetch response.
@@ -222,11 +254,44 @@ type cursorOffsetNext struct {
 	// Basically, any field read in AppendTo needs to be copied into
 	// cursorOffsetNext.
 	currentLeaderEpoch int32
+	fetchBytes         int32
+}
+
+// adaptFetchBytes adjusts the cursor's per-partition fetch size after a fetch
+// for AdaptiveFetchMaxPartitionBytes. If the partition is still lagging and
+// the fetch returned at least half of what we asked for, the fetch was likely
+// limited by the size and we double it, up to max. Once the partition is
+// caught up, we halve the size back down to the configured base.
+func (o *cursorOffsetNext) adaptFetchBytes(base, max int32, fetched int) {
+	if max <= base {
+		o.from.fetchBytes = 0
+		return
+	}
+	size := o.fetchBytes
+	if size < base {
+		size = base
+	}
+	lag := o.hwm - o.offset
+	switch {
+	case lag > 0 && int64(fetched) >= int64(size)/2:
+		if size > max/2 {
+			size = max
+		} else {
+			size *= 2
+		}
+	case lag <= 0:
+		size /= 2
+	}
+	if size <= base {
+		size = 0
+	}
+	o.from.fetchBytes = size
 }
 
 type cursorOffsetPreferred struct {
 	cursorOffsetNext
 	preferredReplica int32
+	why              ReplicaSwitchReason
 }
 
 // Moves a cursor from one source to another. This is done while handling
@@ -250,9 +315,46 @@ func (p *cursorOffsetPreferred) move() {
 	// This remove clears the source's session and buffered fetch, although
 	// we will not have a buffered fetch since moving replicas is called
 	// before buffering a fetch.
+	from := c.source.nodeID
 	c.source.removeCursor(c)
 	c.source = sns.source
 	c.source.addCursor(c)
+
+	c.replicaErrs = 0
+	if c.source.nodeID != c.leader {
+		c.replicaSince = time.Now()
+	} else {
+		c.replicaSince = time.Time{}
+	}
+	c.hookReplicaSwitch(from, p.why)
+}
+
+// hookReplicaSwitch calls the OnReplicaSwitch function, if any, after the
+// cursor moved from the given broker to its current source.
+func (c *cursor) hookReplicaSwitch(from int32, why ReplicaSwitchReason) {
+	if fn := c.source.cl.cfg.onReplicaSwitch; fn != nil && from != c.source.nodeID {
+		fn(ReplicaSwitch{
+			Topic:     c.topic,
+			Partition: c.partition,
+			From:      from,
+			To:        c.source.nodeID,
+			Leader:    c.leader,
+			Reason:    why,
+		})
+	}
+}
+
+// maybeReturnToLeader returns whether a cursor being consumed from a replica
+// should return to the leader, and why, given the error code of the latest
+// fetch of the partition.
+func (s *source) maybeReturnToLeader(c *cursor, errCode int16) (ReplicaSwitchReason, bool) {
+	cfg := &s.cl.cfg
+	if errCode != 0 {
+		c.replicaErrs++
+		return ReplicaSwitchMaxErrors, cfg.replicaMaxErrs > 0 && c.replicaErrs >= cfg.replicaMaxErrs
+	}
+	c.replicaErrs = 0
+	return ReplicaSwitchMaxAge, cfg.replicaMaxAge > 0 && !c.replicaSince.IsZero() && time.Since(c.replicaSince) >= cfg.replicaMaxAge
 }
 
 type cursorPreferreds []cursorOffsetPreferred
@@ -322,17 +424,59 @@ func (s *source) hook(f *Fetch, buffered, polled bool) {
 		}
 	})
 
-	var nrecs int
+	if polled && len(s.cl.consumer.latencyHooks) > 0 {
+		hookFetchLatency(f, s.cl.consumer.latencyHooks, time.Now())
+	}
+
+	var nrecs, nbytes int
 	for i := range f.Topics {
 		t := &f.Topics[i]
 		for j := range t.Partitions {
-			nrecs += len(t.Partitions[j].Records)
+			p := &t.Partitions[j]
+			nrecs += len(p.Records)
+			for _, r := range p.Records {
+				nbytes += len(r.Key) + len(r.Value)
+			}
+			for _, b := range p.RawBatches {
+				nbytes += len(b.Raw)
+			}
 		}
 	}
 	if buffered {
 		s.cl.consumer.bufferedRecords.Add(int64(nrecs))
+		s.cl.consumer.bufferedBytes.Add(int64(nbytes))
 	} else {
 		s.cl.consumer.bufferedRecords.Add(-int64(nrecs))
+		s.cl.consumer.bufferedBytes.Add(-int64(nbytes))
+	}
+}
+
+// hookFetchLatency calls latency hooks for every partition in a polled fetch
+// that has records, measuring latency from each record's timestamp to now.
+func hookFetchLatency(f *Fetch, hooks []HookFetchPartitionLatency, now time.Time) {
+	for i := range f.Topics {
+		t := &f.Topics[i]
+		for j := range t.Partitions {
+			p := &t.Partitions[j]
+			if len(p.Records) == 0 {
+				continue
+			}
+			var m FetchLatencyMetrics
+			for _, r := range p.Records {
+				l := now.Sub(r.Timestamp)
+				if m.NumRecords == 0 || l < m.MinLatency {
+					m.MinLatency = l
+				}
+				if l > m.MaxLatency {
+					m.MaxLatency = l
+				}
+				m.TotalLatency += l
+				m.NumRecords++
+			}
+			for _, h := range hooks {
+				h.OnFetchPartitionLatency(t.Topic, p.Partition, m)
+			}
+		}
 	}
 }
 
@@ -345,21 +489,47 @@ func (s *source) discardBuffered() {
 	s.takeBufferedFn(false, usedOffsets.finishUsingAll)
 }
 
+// setLag saves the lag for all partitions in a fetch that is being polled,
+// using the offsets the fetch advances the partitions to.
+func (s *source) setLag(f *Fetch, offsets usedOffsets) {
+	for i := range f.Topics {
+		t := &f.Topics[i]
+		for j := range t.Partitions {
+			p := &t.Partitions[j]
+			o := offsets[t.Topic][p.Partition]
+			if o == nil || p.Err != nil {
+				continue
+			}
+			s.cl.consumer.setLag(t.Topic, p.Partition, o.offset, p.HighWatermark, p.LogStartOffset)
+		}
+	}
+}
+
 // takeNBuffered takes a limited amount of records from a buffered fetch,
-// updating offsets in each partition per records taken.
+// updating offsets in each partition per records taken. If perPartition is
+// positive, at most perPartition records are taken from each partition. If
+// only is non-nil, records are only taken from the partitions in only.
 //
 // This only allows a new fetch once every buffered record has been taken.
 //
 // This returns the number of records taken and whether the source has been
 // completely drained.
-func (s *source) takeNBuffered(n int) (Fetch, int, bool) {
+func (s *source) takeNBuffered(n, perPartition int, only map[string]map[int32]struct{}) (Fetch, int, bool) {
 	var r Fetch
 	var taken int
 
 	b := &s.buffered
 	bf := &b.fetch
-	for len(bf.Topics) > 0 && n > 0 {
-		t := &bf.Topics[0]
+	for ti := 0; ti < len(bf.Topics) && n > 0; {
+		t := &bf.Topics[ti]
+
+		var onlyPartitions map[int32]struct{}
+		if only != nil {
+			if onlyPartitions = only[t.Topic]; onlyPartitions == nil {
+				ti++
+				continue
+			}
+		}
 
 		r.Topics = append(r.Topics, *t)
 		rt := &r.Topics[len(r.Topics)-1]
@@ -367,13 +537,22 @@ func (s *source) takeNBuffered(n int) (Fetch, int, bool) {
 
 		tCursors := b.usedOffsets[t.Topic]
 
-		for len(t.Partitions) > 0 && n > 0 {
-			p := &t.Partitions[0]
+		for pi := 0; pi < len(t.Partitions) && n > 0; {
+			p := &t.Partitions[pi]
+			if onlyPartitions != nil {
+				if _, exists := onlyPartitions[p.Partition]; !exists {
+					pi++
+					continue
+				}
+			}
 
 			rt.Partitions = append(rt.Partitions, *p)
 			rp := &rt.Partitions[len(rt.Partitions)-1]
 
 			take := n
+			if perPartition > 0 && take > perPartition {
+				take = perPartition
+			}
 			if take > len(p.Records) {
 				take = len(p.Records)
 			}
@@ -387,15 +566,18 @@ func (s *source) takeNBuffered(n int) (Fetch, int, bool) {
 			pCursor := tCursors[p.Partition]
 
 			if len(p.Records) == 0 {
-				t.Partitions = t.Partitions[1:]
+				t.Partitions = append(t.Partitions[:pi], t.Partitions[pi+1:]...)
 
 				pCursor.from.setOffset(pCursor.cursorOffset)
 				pCursor.from.allowUsable()
-				delete(tCursors, p.Partition)
+				if rp.Err == nil {
+					s.cl.consumer.setLag(t.Topic, rp.Partition, pCursor.offset, rp.HighWatermark, rp.LogStartOffset)
+				}
+				delete(tCursors, rp.Partition) // p now points to the next partition
 				if len(tCursors) == 0 {
 					delete(b.usedOffsets, t.Topic)
 				}
-				break
+				continue
 			}
 
 			lastReturnedRecord := rp.Records[len(rp.Records)-1]
@@ -404,10 +586,19 @@ func (s *source) takeNBuffered(n int) (Fetch, int, bool) {
 				lastConsumedEpoch: lastReturnedRecord.LeaderEpoch,
 				hwm:               p.HighWatermark,
 			})
+			if rp.Err == nil {
+				s.cl.consumer.setLag(t.Topic, p.Partition, lastReturnedRecord.Offset+1, p.HighWatermark, p.LogStartOffset)
+			}
+			pi++
 		}
 
+		if len(rt.Partitions) == 0 { // only possible if filtering with only
+			r.Topics = r.Topics[:len(r.Topics)-1]
+		}
 		if len(t.Partitions) == 0 {
-			bf.Topics = bf.Topics[1:]
+			bf.Topics = append(bf.Topics[:ti], bf.Topics[ti+1:]...)
+		} else {
+			ti++
 		}
 	}
 
@@ -423,6 +614,9 @@ func (s *source) takeNBuffered(n int) (Fetch, int, bool) {
 func (s *source) takeBufferedFn(polled bool, offsetFn func(usedOffsets)) Fetch {
 	r := s.buffered
 	s.buffered = bufferedFetch{}
+	if polled {
+		s.setLag(&r.fetch, r.usedOffsets)
+	}
 	offsetFn(r.usedOffsets)
 	r.doneFetch <- struct{}{}
 	close(s.sem)
@@ -603,24 +797,51 @@ func (s *source) fetch(consumerSession *consumerSession, doneFetch chan<- struct
 		kresp       kmsg.Response
 		requested   = make(chan struct{})
 		ctx, cancel = context.WithCancel(consumerSession.ctx)
+
+		// The primary request is canceled early if a hedged fetch
+		// wins, so it writes to its own response and error.
+		primaryCtx, primaryCancel = context.WithCancel(ctx)
+		primaryResp               kmsg.Response
+		primaryErr                error
+
+		// We copy our request for hedging before issuing it, since
+		// issuing it can modify the request.
+		hreq  *fetchRequest
+		hedge *hedgedFetch
 	)
 	defer cancel()
+	defer primaryCancel()
+	if s.cl.cfg.fetchHedgeAfter > 0 {
+		hreq = req.hedgeCopy()
+	}
 
 	br, err := s.cl.brokerOrErr(ctx, s.nodeID, errUnknownBroker)
 	if err != nil {
 		close(requested)
 	} else {
-		br.do(ctx, req, func(k kmsg.Response, e error) {
-			kresp, err = k, e
+		br.do(primaryCtx, req, func(k kmsg.Response, e error) {
+			primaryResp, primaryErr = k, e
 			close(requested)
 		})
+		if hreq != nil {
+			hedge = s.hedgeFetch(ctx, hreq, requested)
+		}
 	}
 
-	select {
-	case <-requested:
+	if hedge != nil {
+		primaryCancel()
 		fetched = true
-	case <-ctx.Done():
-		return
+		br, req, kresp = hedge.br, hreq, hedge.resp
+	} else {
+		select {
+		case <-requested:
+			fetched = true
+			if err == nil {
+				kresp, err = primaryResp, primaryErr
+			}
+		case <-ctx.Done():
+			return
+		}
 	}
 
 	var didBackoff bool
@@ -707,8 +928,14 @@ func (s *source) fetch(consumerSession *consumerSession, doneFetch chan<- struct
 	})
 	reloadOffsets.each(deleteReqUsedOffset)
 
-	// The session on the request was updated; we keep those updates.
-	s.session = req.session
+	// The session on the request was updated; we keep those updates. If
+	// a hedged fetch won, we do not know what happened with our canceled
+	// request in our session, so we reset the session.
+	if hedge != nil {
+		s.session.reset()
+	} else {
+		s.session = req.session
+	}
 
 	// handleReqResp only parses the body of the response, not the top
 	// level error code.
@@ -747,11 +974,16 @@ func (s *source) fetch(consumerSession *consumerSession, doneFetch chan<- struct
 	// advance past them).
 	setOffsets = true
 
-	if resp.Version < 7 || resp.SessionID <= 0 {
+	switch {
+	case hedge != nil:
+		// A hedged fetch is sessionless and says nothing about
+		// whether our broker supports sessions; our session was
+		// reset above.
+	case resp.Version < 7 || resp.SessionID <= 0:
 		// If the version is less than 7, we cannot use fetch sessions,
 		// so we kill them on the first response.
 		s.session.kill()
-	} else {
+	default:
 		s.session.bumpEpoch(resp.SessionID)
 	}
 
@@ -779,13 +1011,167 @@ func (s *source) fetch(consumerSession *consumerSession, doneFetch chan<- struct
 	return
 }
 
+// keptRespPartition is a partition in a fetch response that stays on this
+// source. Partitions are decoded only after every partition's replica is
+// decided, so that partitions moving to another replica are not decoded.
+type keptRespPartition struct {
+	topic string
+	rp    *kmsg.FetchResponseTopicPartition
+	o     *cursorOffsetNext
+	fp    FetchPartition
+}
+
+// decodeRespPartitions processes the records of every kept partition. With
+// FetchDecodeConcurrency, partitions are processed concurrently, bounded
+// across all sources.
+func (s *source) decodeRespPartitions(br *broker, kept []keptRespPartition) {
+	sem := s.cl.consumer.decodeSem
+	if sem == nil {
+		for i := range kept {
+			k := &kept[i]
+			k.fp = k.o.processRespPartition(br, k.rp, s.cl.decompressor, s.cl.cfg.hooks)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	for i := range kept {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(k *keptRespPartition) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			k.fp = k.o.processRespPartition(br, k.rp, s.cl.decompressor, s.cl.cfg.hooks)
+		}(&kept[i])
+	}
+	wg.Wait()
+}
+
+// selectReplica returns the replica a partition should move to, or -1 if it
+// should stay on this source, and whether the replica was chosen by the
+// selector. A chosen replica is final: it is not overridden by the broker's
+// preferred read replica nor by returning to the leader.
+func (s *source) selectReplica(o *cursorOffsetNext, preferred int32) (int32, bool) {
+	replicas, _ := o.from.replicas.Load().([]int32)
+	sel := s.cl.cfg.replicaSelector.SelectReplica(ReplicaSelection{
+		Topic:     o.from.topic,
+		Partition: o.from.partition,
+		Leader:    o.from.leader,
+		Replicas:  append([]int32(nil), replicas...),
+		Current:   s.nodeID,
+		Preferred: preferred,
+	})
+	if sel < 0 {
+		return preferred, false
+	}
+	if sel == s.nodeID {
+		return -1, true
+	}
+	for _, replica := range replicas {
+		if replica == sel {
+			return sel, true
+		}
+	}
+	s.cl.cfg.logger.Log(LogLevelWarn, "replica selector chose a broker that is not a replica of the partition, ignoring",
+		"broker", logID(s.nodeID),
+		"topic", o.from.topic,
+		"partition", o.from.partition,
+		"selected", sel,
+	)
+	return preferred, false
+}
+
+type hedgedFetch struct {
+	br   *broker
+	resp *kmsg.FetchResponse
+}
+
+// hedgeFetch waits up to FetchHedgeAfter for our primary fetch to be
+// requested. If the primary is slower, this issues hreq to another replica of
+// every partition in the request and returns the hedged response if it is
+// error free and arrives before the primary. Otherwise, this returns nil and
+// the primary should be used.
+func (s *source) hedgeFetch(ctx context.Context, hreq *fetchRequest, requested <-chan struct{}) *hedgedFetch {
+	timer := time.NewTimer(s.cl.cfg.fetchHedgeAfter)
+	defer timer.Stop()
+	select {
+	case <-requested:
+		return nil
+	case <-ctx.Done():
+		return nil
+	case <-timer.C:
+	}
+
+	node, ok := hreq.hedgeReplica(s.nodeID)
+	if !ok {
+		return nil
+	}
+	br, err := s.cl.brokerOrErr(ctx, node, errUnknownBroker)
+	if err != nil {
+		return nil
+	}
+
+	s.cl.cfg.logger.Log(LogLevelDebug, "fetch is slow, hedging to another replica",
+		"broker", logID(s.nodeID),
+		"hedge_broker", logID(node),
+		"after", s.cl.cfg.fetchHedgeAfter,
+	)
+
+	hctx, hcancel := context.WithCancel(ctx)
+	defer hcancel()
+	hedged := make(chan *hedgedFetch, 1)
+	br.do(hctx, hreq, func(k kmsg.Response, err error) {
+		if err != nil {
+			hedged <- nil
+			return
+		}
+		resp := k.(*kmsg.FetchResponse)
+		if fetchRespHasErrs(resp) {
+			hedged <- nil
+			return
+		}
+		hedged <- &hedgedFetch{br, resp}
+	})
+
+	select {
+	case <-requested:
+		return nil
+	case <-ctx.Done():
+		return nil
+	case h := <-hedged:
+		if h == nil {
+			s.cl.cfg.logger.Log(LogLevelDebug, "hedged fetch failed, waiting for the original fetch", "broker", logID(s.nodeID), "hedge_broker", logID(node))
+		}
+		return h
+	}
+}
+
+// fetchRespHasErrs returns whether a fetch response has a top level or any
+// partition error. We only use hedged responses without errors, leaving
+// error handling to the primary fetch.
+func fetchRespHasErrs(resp *kmsg.FetchResponse) bool {
+	if resp.ErrorCode != 0 {
+		return true
+	}
+	for i := range resp.Topics {
+		for j := range resp.Topics[i].Partitions {
+			if resp.Topics[i].Partitions[j].ErrorCode != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Parses a fetch response into a Fetch, offsets to reload, and whether
 // metadata needs updating.
 //
 // This only uses a source's broker and client, and thus does not need
 // the source mutex.
 //
-// This function, and everything it calls, is side effect free.
+// This function, and everything it calls, is side effect free (other than
+// calling a user provided ReplicaSelector).
 func (s *source) handleReqResp(br *broker, req *fetchRequest, resp *kmsg.FetchResponse) (
 	f Fetch,
 	reloadOffsets listOrEpochLoads,
@@ -799,8 +1185,11 @@ func (s *source) handleReqResp(br *broker, req *fetchRequest, resp *kmsg.FetchRe
 		updateWhy       multiUpdateWhy
 		numErrsStripped int
 		kip320          = s.cl.supportsOffsetForLeaderEpoch()
+		kept            []keptRespPartition
 	)
 
+	// We first decide which partitions move to a different replica, and
+	// then only decode the partitions that stay.
 	for _, rt := range resp.Topics {
 		topic := rt.Topic
 		// v13 only uses topic IDs, so we have to map the response
@@ -821,11 +1210,6 @@ func (s *source) handleReqResp(br *broker, req *fetchRequest, resp *kmsg.FetchRe
 			continue
 		}
 
-		fetchTopic := FetchTopic{
-			Topic:      topic,
-			Partitions: make([]FetchPartition, 0, len(rt.Partitions)),
-		}
-
 		for i := range rt.Partitions {
 			rp := &rt.Partitions[i]
 			partition := rp.Partition
@@ -842,138 +1226,159 @@ func (s *source) handleReqResp(br *broker, req *fetchRequest, resp *kmsg.FetchRe
 			// If we are fetching from the replica already, Kafka replies with a -1
 			// preferred read replica. If Kafka replies with a preferred replica,
 			// it sends no records.
-			if preferred := rp.PreferredReadReplica; resp.Version >= 11 && preferred >= 0 {
+			//
+			// If the user has a replica selector, we allow it to move
+			// the cursor whenever the partition has nothing buffered.
+			preferred := int32(-1)
+			if resp.Version >= 11 {
+				preferred = rp.PreferredReadReplica
+			}
+			var selected bool
+			if s.cl.cfg.replicaSelector != nil && rp.ErrorCode == 0 && len(rp.RecordBatches) == 0 {
+				preferred, selected = s.selectReplica(partOffset, preferred)
+			}
+			why := ReplicaSwitchPreferred
+			if preferred < 0 && partOffset.from.leader >= 0 && s.nodeID != partOffset.from.leader {
+				if reason, back := s.maybeReturnToLeader(partOffset.from, rp.ErrorCode); back && !selected {
+					preferred, why = partOffset.from.leader, reason
+				}
+			}
+			if preferred >= 0 {
 				preferreds = append(preferreds, cursorOffsetPreferred{
 					*partOffset,
 					preferred,
+					why,
 				})
 				continue
 			}
+			kept = append(kept, keptRespPartition{topic: topic, rp: rp, o: partOffset})
+		}
+	}
 
-			fp := partOffset.processRespPartition(br, rp, s.cl.decompressor, s.cl.cfg.hooks)
-			if fp.Err != nil {
-				updateMeta = true
-				updateWhy.add(topic, partition, fp.Err)
-			}
+	s.decodeRespPartitions(br, kept)
 
-			// We only keep the partition if it has no error, or an
-			// error we do not internally retry.
-			var keep bool
-			switch fp.Err {
-			default:
-				if kerr.IsRetriable(fp.Err) && !s.cl.cfg.keepFetchRetryableErrors {
-					// UnknownLeaderEpoch: our meta is newer than the broker we fetched from
-					// OffsetNotAvailable: fetched from out of sync replica or a behind in-sync one (KIP-392 case 1 and case 2)
-					// UnknownTopicID: kafka has not synced the state on all brokers
-					// And other standard retryable errors.
-					numErrsStripped++
-				} else {
-					// - bad auth
-					// - unsupported compression
-					// - unsupported message version
-					// - unknown error
-					// - or, no error
-					keep = true
-				}
+	var fetchTopic FetchTopic
+	flushTopic := func() {
+		if len(fetchTopic.Partitions) > 0 {
+			f.Topics = append(f.Topics, fetchTopic)
+		}
+	}
+	for i := range kept {
+		var (
+			k          = &kept[i]
+			topic      = k.topic
+			rp         = k.rp
+			partition  = rp.Partition
+			partOffset = k.o
+			fp         = k.fp
+		)
+		if topic != fetchTopic.Topic {
+			flushTopic()
+			fetchTopic = FetchTopic{Topic: topic}
+		}
+
+		if fp.Err != nil {
+			updateMeta = true
+			updateWhy.add(topic, partition, fp.Err)
+		} else if s.cl.cfg.adaptiveMaxPartBytes > 0 {
+			partOffset.adaptFetchBytes(req.maxPartBytes, s.cl.cfg.adaptiveMaxPartBytes, len(rp.RecordBatches))
+		}
+
+		// We only keep the partition if it has no error, or an
+		// error we do not internally retry.
+		var keep bool
+		switch fp.Err {
+		default:
+			if kerr.IsRetriable(fp.Err) && !s.cl.cfg.keepFetchRetryableErrors {
+				// UnknownLeaderEpoch: our meta is newer than the broker we fetched from
+				// OffsetNotAvailable: fetched from out of sync replica or a behind in-sync one (KIP-392 case 1 and case 2)
+				// UnknownTopicID: kafka has not synced the state on all brokers
+				// And other standard retryable errors.
+				numErrsStripped++
+			} else {
+				// - bad auth
+				// - unsupported compression
+				// - unsupported message version
+				// - unknown error
+				// - or, no error
+				keep = true
+			}
 
-			case nil:
-				partOffset.from.unknownIDFails.Store(0)
+		case nil:
+			partOffset.from.unknownIDFails.Store(0)
+			keep = true
+
+		case kerr.UnknownTopicID:
+			// We need to keep UnknownTopicID even though it is
+			// retryable, because encountering this error means
+			// the topic has been recreated and we will never
+			// consume the topic again anymore. This is an error
+			// worth bubbling up.
+			//
+			// Kafka will actually return this error for a brief
+			// window immediately after creating a topic for the
+			// first time, meaning the controller has not yet
+			// propagated to the leader that it is now the leader
+			// of a new partition. We need to ignore this error
+			// for a little bit.
+			if fails := partOffset.from.unknownIDFails.Add(1); fails > 5 {
+				partOffset.from.unknownIDFails.Add(-1)
+				keep = true
+			} else if s.cl.cfg.keepFetchRetryableErrors {
 				keep = true
+			} else {
+				numErrsStripped++
+			}
 
-			case kerr.UnknownTopicID:
-				// We need to keep UnknownTopicID even though it is
-				// retryable, because encountering this error means
-				// the topic has been recreated and we will never
-				// consume the topic again anymore. This is an error
-				// worth bubbling up.
-				//
-				// Kafka will actually return this error for a brief
-				// window immediately after creating a topic for the
-				// first time, meaning the controller has not yet
-				// propagated to the leader that it is now the leader
-				// of a new partition. We need to ignore this error
-				// for a little bit.
-				if fails := partOffset.from.unknownIDFails.Add(1); fails > 5 {
-					partOffset.from.unknownIDFails.Add(-1)
-					keep = true
-				} else if s.cl.cfg.keepFetchRetryableErrors {
+		case kerr.OffsetOutOfRange:
+			// If we are out of range, we reset to what we can.
+			// With Kafka >= 2.1, we should only get offset out
+			// of range if we fetch before the start, but a user
+			// could start past the end and want to reset to
+			// the end. We respect that.
+			//
+			// KIP-392 (case 3) specifies that if we are consuming
+			// from a follower, then if our offset request is before
+			// the low watermark, we list offsets from the follower.
+			//
+			// KIP-392 (case 4) specifies that if we are consuming
+			// a follower and our request is larger than the high
+			// watermark, then we should first check for truncation
+			// from the leader and then if we still get out of
+			// range, reset with list offsets.
+			//
+			// It further goes on to say that "out of range errors
+			// due to ISR propagation delays should be extremely
+			// rare". Rather than falling back to listing offsets,
+			// we stay in a cycle of validating the leader epoch
+			// until the follower has caught up.
+			//
+			// In all cases except case 4, we also have to check if
+			// no reset offset was configured (or if the user's
+			// OnOffsetOutOfRange chose to not reset). If so, we
+			// ignore trying to reset and instead keep our failed
+			// partition.
+			addList := func(replica int32) {
+				reset := s.cl.outOfRangeReset(topic, partition, partOffset.offset, &fp)
+				if reset.noReset {
 					keep = true
 				} else {
-					numErrsStripped++
-				}
-
-			case kerr.OffsetOutOfRange:
-				// If we are out of range, we reset to what we can.
-				// With Kafka >= 2.1, we should only get offset out
-				// of range if we fetch before the start, but a user
-				// could start past the end and want to reset to
-				// the end. We respect that.
-				//
-				// KIP-392 (case 3) specifies that if we are consuming
-				// from a follower, then if our offset request is before
-				// the low watermark, we list offsets from the follower.
-				//
-				// KIP-392 (case 4) specifies that if we are consuming
-				// a follower and our request is larger than the high
-				// watermark, then we should first check for truncation
-				// from the leader and then if we still get out of
-				// range, reset with list offsets.
-				//
-				// It further goes on to say that "out of range errors
-				// due to ISR propagation delays should be extremely
-				// rare". Rather than falling back to listing offsets,
-				// we stay in a cycle of validating the leader epoch
-				// until the follower has caught up.
-				//
-				// In all cases except case 4, we also have to check if
-				// no reset offset was configured. If so, we ignore
-				// trying to reset and instead keep our failed partition.
-				addList := func(replica int32) {
-					if s.cl.cfg.resetOffset.noReset {
-						keep = true
-					} else {
-						reloadOffsets.addLoad(topic, partition, loadTypeList, offsetLoad{
-							replica: replica,
-							Offset:  s.cl.cfg.resetOffset,
-						})
-					}
+					reloadOffsets.addLoad(topic, partition, loadTypeList, offsetLoad{
+						replica: replica,
+						Offset:  reset,
+					})
 				}
+			}
 
-				switch {
-				case s.nodeID == partOffset.from.leader: // non KIP-392 case
-					addList(-1)
+			switch {
+			case s.nodeID == partOffset.from.leader: // non KIP-392 case
+				addList(-1)
 
-				case partOffset.offset < fp.LogStartOffset: // KIP-392 case 3
-					addList(s.nodeID)
-
-				default: // partOffset.offset > fp.HighWatermark, KIP-392 case 4
-					if kip320 {
-						reloadOffsets.addLoad(topic, partition, loadTypeEpoch, offsetLoad{
-							replica: -1,
-							Offset: Offset{
-								at:    partOffset.offset,
-								epoch: partOffset.lastConsumedEpoch,
-							},
-						})
-					} else {
-						// If the broker does not support offset for leader epoch but
-						// does support follower fetching for some reason, we have to
-						// fallback to listing.
-						addList(-1)
-					}
-				}
+			case partOffset.offset < fp.LogStartOffset: // KIP-392 case 3
+				addList(s.nodeID)
 
-			case kerr.FencedLeaderEpoch:
-				// With fenced leader epoch, we notify an error only
-				// if necessary after we find out if loss occurred.
-				// If we have consumed nothing, then we got unlucky
-				// by being fenced right after we grabbed metadata.
-				// We just refresh metadata and try again.
-				//
-				// It would be odd for a broker to reply we are fenced
-				// but not support offset for leader epoch, so we do
-				// not check KIP-320 support here.
-				if partOffset.lastConsumedEpoch >= 0 {
+			default: // partOffset.offset > fp.HighWatermark, KIP-392 case 4
+				if kip320 {
 					reloadOffsets.addLoad(topic, partition, loadTypeEpoch, offsetLoad{
 						replica: -1,
 						Offset: Offset{
@@ -981,18 +1386,40 @@ func (s *source) handleReqResp(br *broker, req *fetchRequest, resp *kmsg.FetchRe
 							epoch: partOffset.lastConsumedEpoch,
 						},
 					})
+				} else {
+					// If the broker does not support offset for leader epoch but
+					// does support follower fetching for some reason, we have to
+					// fallback to listing.
+					addList(-1)
 				}
 			}
 
-			if keep {
-				fetchTopic.Partitions = append(fetchTopic.Partitions, fp)
+		case kerr.FencedLeaderEpoch:
+			// With fenced leader epoch, we notify an error only
+			// if necessary after we find out if loss occurred.
+			// If we have consumed nothing, then we got unlucky
+			// by being fenced right after we grabbed metadata.
+			// We just refresh metadata and try again.
+			//
+			// It would be odd for a broker to reply we are fenced
+			// but not support offset for leader epoch, so we do
+			// not check KIP-320 support here.
+			if partOffset.lastConsumedEpoch >= 0 {
+				reloadOffsets.addLoad(topic, partition, loadTypeEpoch, offsetLoad{
+					replica: -1,
+					Offset: Offset{
+						at:    partOffset.offset,
+						epoch: partOffset.lastConsumedEpoch,
+					},
+				})
 			}
 		}
 
-		if len(fetchTopic.Partitions) > 0 {
-			f.Topics = append(f.Topics, fetchTopic)
+		if keep {
+			fetchTopic.Partitions = append(fetchTopic.Partitions, fp)
 		}
 	}
+	flushTopic()
 
 	return f, reloadOffsets, preferreds, req.numOffsets == numErrsStripped, updateMeta, updateWhy.reason("fetch had inner topic errors")
 }
@@ -1110,8 +1537,10 @@ func (o *cursorOffsetNext) processRespPartition(br *broker, rp *kmsg.FetchRespon
 			break
 		}
 
+		raw := in[:length:length]
 		in = in[length:]
 
+		kept := len(fp.Records)
 		var m FetchBatchMetrics
 
 		switch t := r.(type) {
@@ -1128,7 +1557,15 @@ func (o *cursorOffsetNext) processRespPartition(br *broker, rp *kmsg.FetchRespon
 		case *kmsg.RecordBatch:
 			m.CompressedBytes = len(t.Records) // for record batches, we only track the record batch length
 			m.CompressionType = uint8(t.Attributes) & 0b0000_0111
-			m.NumRecords, m.UncompressedBytes = o.processRecordBatch(&fp, t, aborter, decompressor)
+			if o.from.rawBatches {
+				m.NumRecords = o.processRawRecordBatch(&fp, t, raw, aborter)
+			} else {
+				m.NumRecords, m.UncompressedBytes = o.processRecordBatch(&fp, t, aborter, decompressor)
+			}
+		}
+
+		if kept < len(fp.Records) {
+			fp.batches = append(fp.batches, newFetchBatch(r, offset, fp.Records[kept:]))
 		}
 
 		if m.UncompressedBytes == 0 {
@@ -1144,6 +1581,50 @@ func (o *cursorOffsetNext) processRespPartition(br *broker, rp *kmsg.FetchRespon
 	return fp
 }
 
+// newFetchBatch returns the metadata, without records, of a batch that the
+// given records were kept from.
+func newFetchBatch(r readerFrom, offset int64, kept []*Record) FetchBatch {
+	if rb, ok := r.(*kmsg.RecordBatch); ok {
+		return FetchBatch{
+			FirstOffset:          rb.FirstOffset,
+			LastOffset:           rb.FirstOffset + int64(rb.LastOffsetDelta),
+			ProducerID:           rb.ProducerID,
+			ProducerEpoch:        rb.ProducerEpoch,
+			BaseSequence:         rb.FirstSequence,
+			PartitionLeaderEpoch: rb.PartitionLeaderEpoch,
+			Attrs:                RecordAttrs{uint8(rb.Attributes)},
+			FirstTimestamp:       timeFromMillis(rb.FirstTimestamp),
+			MaxTimestamp:         timeFromMillis(rb.MaxTimestamp),
+		}
+	}
+
+	// For message sets, the outer message offset is the offset of the
+	// last inner message. The kept records have the inner attributes and
+	// timestamps we need.
+	b := FetchBatch{
+		FirstOffset:          kept[0].Offset,
+		LastOffset:           offset,
+		ProducerID:           -1,
+		ProducerEpoch:        -1,
+		BaseSequence:         -1,
+		PartitionLeaderEpoch: -1,
+		Attrs:                kept[0].Attrs,
+		FirstTimestamp:       kept[0].Timestamp,
+	}
+	switch m := r.(type) {
+	case *kmsg.MessageV0:
+		b.Attrs = messageAttrsToRecordAttrs(m.Attributes, true)
+	case *kmsg.MessageV1:
+		b.Attrs = messageAttrsToRecordAttrs(m.Attributes, false)
+	}
+	for _, r := range kept {
+		if r.Timestamp.After(b.MaxTimestamp) {
+			b.MaxTimestamp = r.Timestamp
+		}
+	}
+	return b
+}
+
 type aborter map[int64][]int64
 
 func buildAborter(rp *kmsg.FetchResponseTopicPartition) aborter {
@@ -1223,9 +1704,18 @@ func (o *cursorOffsetNext) processRecordBatch(
 	}
 
 	rawRecords := batch.Records
+	var slab *fetchSlab
 	if compression := byte(batch.Attributes & 0x0007); compression != 0 {
 		var err error
-		if rawRecords, err = decompressor.decompress(rawRecords, compression); err != nil {
+		if o.from.poolBuffers && !decompressor.isCustom(compression) {
+			buf := fetchSlabs.Get().(*[]byte)
+			if rawRecords, err = decompressor.decompressTo(*buf, rawRecords, compression); err != nil {
+				fetchSlabs.Put(buf)
+				return 0, 0 // truncated batch
+			}
+			*buf = rawRecords[:0] // decompressing may have grown the buffer
+			slab = &fetchSlab{buf: buf}
+		} else if rawRecords, err = decompressor.decompress(rawRecords, compression); err != nil {
 			return 0, 0 // truncated batch
 		}
 	}
@@ -1253,6 +1743,7 @@ func (o *cursorOffsetNext) processRecordBatch(
 	}()
 
 	abortBatch := aborter.shouldAbortBatch(batch)
+	kept := len(fp.Records)
 	for i := range krecords {
 		record := recordToRecord(
 			o.from.topic,
@@ -1271,10 +1762,94 @@ func (o *cursorOffsetNext) processRecordBatch(
 			}
 		}
 	}
+	if slab != nil {
+		slab.track(fp.Records[kept:])
+	}
 
 	return len(krecords), uncompressedBytes
 }
 
+// processRawRecordBatch keeps a record batch as is, without decoding its
+// records, for ConsumeRawBatches.
+func (o *cursorOffsetNext) processRawRecordBatch(
+	fp *FetchPartition,
+	batch *kmsg.RecordBatch,
+	raw []byte,
+	aborter aborter,
+) int {
+	if batch.Magic != 2 {
+		fp.Err = fmt.Errorf("unknown batch magic %d", batch.Magic)
+		return 0
+	}
+	lastOffset := batch.FirstOffset + int64(batch.LastOffsetDelta)
+	if lastOffset < o.offset {
+		return 0 // see processRecordBatch
+	}
+
+	abortBatch := aborter.shouldAbortBatch(batch)
+	isControl := batch.Attributes&0b0010_0000 != 0
+	if abortBatch && isControl {
+		// Control batches are never compressed and contain a single
+		// record; we decode it to see if it ends the aborted
+		// transaction. See processRecordBatch.
+		for _, record := range readRawRecords(int(batch.NumRecords), batch.Records) {
+			if key := record.Key; len(key) >= 4 && key[2] == 0 && key[3] == 0 {
+				aborter.trackAbortedPID(batch.ProducerID)
+			}
+		}
+	}
+	if isControl {
+		abortBatch = !o.from.keepControl
+	}
+	if !abortBatch {
+		b := newFetchBatch(batch, lastOffset, nil)
+		b.Raw = raw
+		fp.RawBatches = append(fp.RawBatches, b)
+	}
+
+	o.offset = lastOffset + 1
+	o.lastConsumedEpoch = batch.PartitionLeaderEpoch
+	return int(batch.NumRecords)
+}
+
+// fetchSlabs pools buffers that record batches are decompressed into if
+// PoolFetchBuffers is used.
+var fetchSlabs = sync.Pool{New: func() any { r := make([]byte, 0, 64<<10); return &r }}
+
+// fetchSlab is a pooled decompression buffer that is returned to the pool
+// once every record pointing into it is recycled.
+type fetchSlab struct {
+	buf  *[]byte
+	refs atomicI32
+}
+
+// fetchSlabRef is a record's hold on a slab. Copies of a record share the
+// same ref, so the slab is released once per record no matter how many
+// copies are recycled.
+type fetchSlabRef struct {
+	slab     *fetchSlab
+	released atomicBool
+}
+
+func (s *fetchSlab) track(rs []*Record) {
+	if len(rs) == 0 {
+		fetchSlabs.Put(s.buf)
+		return
+	}
+	s.refs.Store(int32(len(rs)))
+	refs := make([]fetchSlabRef, len(rs))
+	for i, r := range rs {
+		refs[i].slab = s
+		r.slab = &refs[i]
+	}
+}
+
+func (ref *fetchSlabRef) release() {
+	if !ref.released.Swap(true) && ref.slab.refs.Add(-1) == 0 {
+		fetchSlabs.Put(ref.slab.buf)
+	}
+}
+
 // Processes an outer v1 message. There could be no inner message, which makes
 // this easy, but if not, we decompress and process each inner message as
 // either v0 or v1. We only expect the inner message to be v1, but technically
@@ -1480,6 +2055,9 @@ func (o *cursorOffsetNext) maybeKeepRecord(fp *FetchPartition, record *Record, a
 		abort = !o.from.keepControl
 	}
 	if !abort {
+		if t := o.from.valueTransformer; t != nil && !record.Attrs.IsControl() {
+			transformFetchValue(t, record)
+		}
 		fp.Records = append(fp.Records, record)
 	}
 
@@ -1609,6 +2187,68 @@ type fetchRequest struct {
 	session fetchSession
 }
 
+// hedgeCopy returns a sessionless copy of the request to be issued to another
+// replica. The copy has its own snapshot of the used offsets, since whichever
+// request wins processes its response while the other may still be written.
+// The copy also has its own write ordering since writing a request can
+// reorder it.
+func (f *fetchRequest) hedgeCopy() *fetchRequest {
+	dup := *f
+	dup.usedOffsets = make(usedOffsets, len(f.usedOffsets))
+	for t, ps := range f.usedOffsets {
+		dps := make(map[int32]*cursorOffsetNext, len(ps))
+		for p, o := range ps {
+			do := *o
+			dps[p] = &do
+		}
+		dup.usedOffsets[t] = dps
+	}
+	dup.torder = append([]string(nil), f.torder...)
+	dup.porder = make(map[string][]int32, len(f.porder))
+	for t, ps := range f.porder {
+		dup.porder[t] = append([]int32(nil), ps...)
+	}
+	dup.session = fetchSession{}
+	dup.session.kill()
+	return &dup
+}
+
+// hedgeReplica returns a broker other than skip that replicates every
+// partition in the request. We prefer the partitions' shared leader, and
+// otherwise use the lowest node ID.
+func (f *fetchRequest) hedgeReplica(skip int32) (int32, bool) {
+	var (
+		counts = make(map[int32]int)
+		leader = int32(-1)
+		shared = true
+	)
+	for _, partitions := range f.usedOffsets {
+		for _, o := range partitions {
+			if leader == -1 {
+				leader = o.from.leader
+			} else if o.from.leader != leader {
+				shared = false
+			}
+			replicas, _ := o.from.replicas.Load().([]int32)
+			for _, r := range replicas {
+				if r != skip {
+					counts[r]++
+				}
+			}
+		}
+	}
+	if shared && leader >= 0 && leader != skip {
+		return leader, true
+	}
+	best := int32(-1)
+	for node, n := range counts {
+		if n == f.numOffsets && (best == -1 || node < best) {
+			best = node
+		}
+	}
+	return best, best != -1
+}
+
 func (f *fetchRequest) addCursor(c *cursor) {
 	if f.usedOffsets == nil {
 		f.usedOffsets = make(usedOffsets)
@@ -1633,6 +2273,103 @@ func (f *fetchRequest) addCursor(c *cursor) {
 	f.numOffsets++
 }
 
+// ReplicaSelection describes a partition that a ReplicaSelector can move to a
+// different replica.
+type ReplicaSelection struct {
+	Topic     string
+	Partition int32
+
+	// Leader is the current leader of the partition.
+	Leader int32
+	// Replicas are all replicas of the partition, including the leader.
+	Replicas []int32
+	// Current is the broker the partition is currently being fetched
+	// from.
+	Current int32
+	// Preferred is the preferred read replica the broker returned
+	// (KIP-392), or -1 if the broker did not return one. If the broker
+	// returned a preferred replica, the partition cannot be fetched from
+	// the current broker.
+	Preferred int32
+}
+
+// ReplicaSelector chooses which replica to consume a partition from. This is
+// the client side counterpart of the broker side replica.selector.class from
+// KIP-392, and can be used to pick replicas based on health or latency data
+// that you track yourself.
+//
+// SelectReplica is called for a partition whenever a fetch response for it
+// contains no records and no error; the client only moves partitions between
+// brokers while nothing is buffered for them. Return the node ID of a replica
+// to fetch from, or -1 to keep the default behavior: staying on the current
+// broker, or moving to the broker's preferred read replica if one was
+// returned. Returning a node that is not a replica of the partition is the
+// same as returning -1.
+//
+// A returned replica, including the current broker, is final for this fetch:
+// the client does not move to the broker's preferred read replica, nor return
+// to the leader because of PreferredReplicaMaxAge. Note that a broker that
+// returns a preferred read replica returns no records for the partition, so
+// staying on such a broker only makes sense while you expect its preference
+// to change.
+//
+// SelectReplica is called concurrently for partitions on different brokers,
+// and it must not block.
+type ReplicaSelector interface {
+	SelectReplica(ReplicaSelection) int32
+}
+
+// ReplicaSwitchReason is why a partition switched the broker it is consumed
+// from, for OnReplicaSwitch.
+type ReplicaSwitchReason int8
+
+const (
+	// ReplicaSwitchPreferred is a move to a preferred read replica
+	// returned by the broker, or to a replica chosen by a
+	// FetchReplicaSelector.
+	ReplicaSwitchPreferred ReplicaSwitchReason = iota
+	// ReplicaSwitchMaxAge is a return to the leader because the partition
+	// was on a replica for longer than PreferredReplicaMaxAge.
+	ReplicaSwitchMaxAge
+	// ReplicaSwitchMaxErrors is a return to the leader because fetching
+	// from a replica failed PreferredReplicaMaxErrors times in a row.
+	ReplicaSwitchMaxErrors
+	// ReplicaSwitchLeaderChange is a move to a new leader after a metadata
+	// update.
+	ReplicaSwitchLeaderChange
+)
+
+func (r ReplicaSwitchReason) String() string {
+	switch r {
+	case ReplicaSwitchPreferred:
+		return "preferred"
+	case ReplicaSwitchMaxAge:
+		return "max_age"
+	case ReplicaSwitchMaxErrors:
+		return "max_errors"
+	case ReplicaSwitchLeaderChange:
+		return "leader_change"
+	default:
+		return "unknown"
+	}
+}
+
+// ReplicaSwitch describes a partition switching the broker it is consumed
+// from, for OnReplicaSwitch.
+type ReplicaSwitch struct {
+	Topic     string
+	Partition int32
+
+	// From is the broker the partition was consumed from.
+	From int32
+	// To is the broker the partition is now consumed from.
+	To int32
+	// Leader is the leader of the partition.
+	Leader int32
+	// Reason is why the partition switched brokers.
+	Reason ReplicaSwitchReason
+}
+
 // PreferLagFn accepts topic and partition lag, the previously determined topic
 // order, and the previously determined per-topic partition order, and returns
 // a new topic and per-topic partition order.
@@ -1880,10 +2617,19 @@ func (f *fetchRequest) AppendTo(dst []byte) []byte {
 				usedTopic[partition] = struct{}{}
 			}
 
+			maxPartBytes := f.maxPartBytes
+			if fetchBytes := cursorOffsetNext.fetchBytes; fetchBytes > maxPartBytes {
+				maxPartBytes = fetchBytes
+				if maxPartBytes > f.maxBytes {
+					maxPartBytes = f.maxBytes
+				}
+			}
+
 			if !sessionTopic.hasPartitionAt(
 				partition,
 				cursorOffsetNext.offset,
 				cursorOffsetNext.currentLeaderEpoch,
+				maxPartBytes,
 			) {
 				if reqTopic == nil {
 					t := kmsg.NewFetchRequestTopic()
@@ -1899,7 +2645,7 @@ func (f *fetchRequest) AppendTo(dst []byte) []byte {
 				reqPartition.FetchOffset = cursorOffsetNext.offset
 				reqPartition.LastFetchedEpoch = -1
 				reqPartition.LogStartOffset = -1
-				reqPartition.PartitionMaxBytes = f.maxPartBytes
+				reqPartition.PartitionMaxBytes = maxPartBytes
 				reqTopic.Partitions = append(reqTopic.Partitions, reqPartition)
 			}
 		}
@@ -2012,18 +2758,19 @@ func (s *fetchSession) lookupTopic(topic string) fetchSessionTopic {
 }
 
 type fetchSessionOffsetEpoch struct {
-	offset int64
-	epoch  int32
+	offset   int64
+	epoch    int32
+	maxBytes int32
 }
 
 type fetchSessionTopic map[int32]fetchSessionOffsetEpoch
 
-func (s fetchSessionTopic) hasPartitionAt(partition int32, offset int64, epoch int32) bool {
+func (s fetchSessionTopic) hasPartitionAt(partition int32, offset int64, epoch, maxBytes int32) bool {
 	if s == nil { // if we are nil, the session was killed
 		return false
 	}
 	at, exists := s[partition]
-	now := fetchSessionOffsetEpoch{offset, epoch}
+	now := fetchSessionOffsetEpoch{offset, epoch, maxBytes}
 	s[partition] = now
 	return exists && at == now
 }
@@ -0,0 +1,392 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// ClientMetric is a metric the client pushes to brokers with KIP-714 client
+// telemetry; see DisableClientMetrics and HookClientMetricsPush.
+type ClientMetric struct {
+	// Name is the metric name, following KIP-714 naming, e.g.
+	// org.apache.kafka.client.connection.creations.
+	Name string
+	// Value is the value of the metric. For sums that are pushed with
+	// delta temporality, this is the change since the prior push.
+	Value int64
+	// Sum is whether the metric is a monotonic sum rather than a gauge.
+	Sum bool
+}
+
+// telemetry manages KIP-714 client metrics. The subscription fields are
+// only used in the telemetry loop, and in stopTelemetry once the loop has
+// quit.
+type telemetry struct {
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	cancel  func()
+	done    chan struct{}
+	startAt time.Time
+
+	br         *broker // broker we push to, chosen when needed
+	instanceID [16]byte
+	sub        *kmsg.GetTelemetrySubscriptionsResponse // nil if we are not pushing
+	compressor *compressor
+	prior      map[string]int64 // sums as of the last push, for delta temporality
+	priorAt    time.Time
+}
+
+// maybeStartTelemetry starts pushing client metrics if the client has not
+// already started and it has not been disabled.
+func (cl *Client) maybeStartTelemetry() {
+	if cl.cfg.disableClientMetrics {
+		return
+	}
+	t := &cl.telemetry
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.started || t.stopped {
+		return
+	}
+	t.started = true
+	t.startAt = time.Now()
+	t.done = make(chan struct{})
+	var ctx context.Context
+	ctx, t.cancel = context.WithCancel(cl.ctx)
+	go cl.telemetryLoop(ctx)
+}
+
+// stopTelemetry stops the telemetry loop and, if we are pushing metrics,
+// pushes one final time with Terminating set. This is called when closing,
+// before brokers are stopped.
+func (cl *Client) stopTelemetry() {
+	t := &cl.telemetry
+	t.mu.Lock()
+	started := t.started
+	t.stopped = true
+	t.mu.Unlock()
+	if !started {
+		return
+	}
+
+	// The loop may be stuck in a request to an unresponsive broker; we
+	// do not hold up closing for long, and only push if the loop quit.
+	t.cancel()
+	timer := time.NewTimer(time.Second)
+	defer timer.Stop()
+	select {
+	case <-t.done:
+	case <-timer.C:
+		return
+	}
+	if t.sub == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(cl.ctx, time.Second)
+	defer cancel()
+	if err := cl.pushTelemetry(ctx, true); err != nil {
+		cl.cfg.logger.Log(LogLevelDebug, "unable to push final client metrics", "err", err)
+	}
+}
+
+func (cl *Client) telemetryLoop(ctx context.Context) {
+	t := &cl.telemetry
+	defer close(t.done)
+
+	var (
+		wait     time.Duration
+		interval time.Duration
+		errs     int
+	)
+	for {
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+
+		if t.sub == nil {
+			sub, err := cl.getTelemetrySubscription(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, errBrokerTooOld) || errors.Is(err, errUnknownRequestKey) {
+				cl.cfg.logger.Log(LogLevelDebug, "broker does not support client metrics, not pushing client metrics")
+				return
+			}
+			if err != nil {
+				errs++
+				wait = cl.cfg.retryBackoff(errs)
+				cl.cfg.logger.Log(LogLevelDebug, "unable to get client metrics subscription", "err", err, "retry_after", wait)
+				continue
+			}
+			errs = 0
+
+			interval = time.Duration(sub.PushIntervalMillis) * time.Millisecond
+			if interval <= 0 {
+				interval = 5 * time.Minute
+			}
+			if len(sub.RequestedMetrics) == 0 {
+				wait = interval // nothing requested; check again later
+				continue
+			}
+			cl.cfg.logger.Log(LogLevelInfo, "pushing client metrics",
+				"subscription_id", sub.SubscriptionID,
+				"requested_metrics", sub.RequestedMetrics,
+				"push_interval", interval,
+			)
+			cl.useTelemetrySubscription(sub)
+
+			// Per KIP-714, we jitter our first push to avoid every
+			// client pushing at once.
+			wait = time.Duration(float64(interval) * (0.5 + cl.rng()))
+			continue
+		}
+
+		err := cl.pushTelemetry(ctx, false)
+		if ctx.Err() != nil {
+			return
+		}
+		wait = interval
+		switch {
+		case err == nil:
+			errs = 0
+		case errors.Is(err, kerr.UnknownSubscriptionID),
+			errors.Is(err, kerr.UnsupportedCompressionType):
+			// Our subscription changed; we resubscribe now.
+			t.sub = nil
+			wait = 0
+		case errors.Is(err, kerr.TelemetryTooLarge),
+			errors.Is(err, kerr.ThrottlingQuotaExceeded):
+			// We try again next interval.
+		default:
+			// We could have a connection error; we try a new
+			// broker next interval.
+			t.br = nil
+			cl.cfg.logger.Log(LogLevelDebug, "unable to push client metrics", "err", err)
+		}
+	}
+}
+
+// getTelemetrySubscription asks a broker which metrics to push.
+func (cl *Client) getTelemetrySubscription(ctx context.Context) (*kmsg.GetTelemetrySubscriptionsResponse, error) {
+	t := &cl.telemetry
+	if t.br == nil {
+		t.br = cl.broker()
+	}
+	req := kmsg.NewPtrGetTelemetrySubscriptionsRequest()
+	req.ClientInstanceID = t.instanceID
+	kresp, err := t.br.waitResp(ctx, req)
+	if err != nil {
+		t.br = nil
+		return nil, err
+	}
+	resp := kresp.(*kmsg.GetTelemetrySubscriptionsResponse)
+	if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// useTelemetrySubscription saves a subscription that requested metrics.
+func (cl *Client) useTelemetrySubscription(sub *kmsg.GetTelemetrySubscriptionsResponse) {
+	t := &cl.telemetry
+	t.instanceID = sub.ClientInstanceID
+	t.sub = sub
+	t.prior = nil
+	t.priorAt = t.startAt
+
+	var codecs []CompressionCodec
+	for _, typ := range sub.AcceptedCompressionTypes {
+		switch codecType(typ) {
+		case codecGzip:
+			codecs = append(codecs, GzipCompression())
+		case codecSnappy:
+			codecs = append(codecs, SnappyCompression())
+		case codecLZ4:
+			codecs = append(codecs, Lz4Compression())
+		case codecZstd:
+			codecs = append(codecs, ZstdCompression())
+		}
+	}
+	t.compressor, _ = newCompressor(codecs...) // nil if uncompressed
+}
+
+// pushTelemetry pushes the currently requested metrics.
+func (cl *Client) pushTelemetry(ctx context.Context, terminating bool) error {
+	t := &cl.telemetry
+	var (
+		sub    = t.sub
+		now    = time.Now()
+		pushed = filterClientMetrics(cl.clientMetrics(), sub.RequestedMetrics)
+		sums   = make(map[string]int64)
+	)
+	for i, m := range pushed {
+		if !m.Sum {
+			continue
+		}
+		sums[m.Name] = m.Value
+		if sub.DeltaTemporality {
+			pushed[i].Value -= t.prior[m.Name]
+		}
+	}
+	start := t.startAt
+	if sub.DeltaTemporality {
+		start = t.priorAt
+	}
+	data := appendClientMetrics(nil, pushed, start, now, sub.DeltaTemporality)
+
+	req := kmsg.NewPtrPushTelemetryRequest()
+	req.ClientInstanceID = t.instanceID
+	req.SubscriptionID = sub.SubscriptionID
+	req.Terminating = terminating
+	req.Metrics = data
+	if t.compressor != nil {
+		// The produce request version only limits zstd, which all
+		// brokers supporting KIP-714 support.
+		if compressed, codec := t.compressor.compress(new(sliceWriter), data, math.MaxInt16); compressed != nil {
+			req.Metrics = compressed
+			req.CompressionType = int8(codec)
+		}
+	}
+	if max := sub.TelemetryMaxBytes; max > 0 && len(req.Metrics) > int(max) {
+		return kerr.TelemetryTooLarge
+	}
+
+	if t.br == nil {
+		t.br = cl.broker()
+	}
+	br := t.br
+	kresp, err := br.waitResp(ctx, req)
+	if err == nil {
+		err = kerr.ErrorForCode(kresp.(*kmsg.PushTelemetryResponse).ErrorCode)
+	}
+	if err == nil {
+		t.prior, t.priorAt = sums, now
+	}
+	cl.cfg.hooks.each(func(h Hook) {
+		if h, ok := h.(HookClientMetricsPush); ok {
+			h.OnClientMetricsPush(br.meta, pushed, err)
+		}
+	})
+	return err
+}
+
+// clientMetrics returns the current value of every metric the client
+// supports pushing.
+func (cl *Client) clientMetrics() []ClientMetric {
+	var creations, active int64
+	for _, b := range cl.ClientStats().Brokers {
+		creations += b.Connects
+		active += int64(len(b.Connections))
+	}
+	ms := []ClientMetric{
+		{Name: "org.apache.kafka.client.connection.creations", Value: creations, Sum: true},
+		{Name: "org.apache.kafka.client.connection.active", Value: active},
+		{Name: "org.apache.kafka.client.producer.queue.messages", Value: cl.producer.bufferedRecords.Load()},
+		{Name: "org.apache.kafka.client.producer.queue.max.messages", Value: cl.cfg.maxBufferedRecords.load()},
+	}
+	if cl.consumer.consuming() {
+		ms = append(ms, ClientMetric{Name: "org.apache.kafka.client.consumer.record.queue.count", Value: cl.consumer.bufferedRecords.Load()})
+	}
+	return ms
+}
+
+// filterClientMetrics returns the metrics that have a requested prefix. Per
+// KIP-714, a single empty prefix requests all metrics.
+func filterClientMetrics(ms []ClientMetric, prefixes []string) []ClientMetric {
+	var keep []ClientMetric
+	for _, m := range ms {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(m.Name, prefix) {
+				keep = append(keep, m)
+				break
+			}
+		}
+	}
+	return keep
+}
+
+// appendClientMetrics appends metrics encoded as an OpenTelemetry protobuf
+// MetricsData, the format KIP-714 pushes metrics in. We only encode what we
+// use: one ResourceMetrics with one ScopeMetrics, containing a gauge or sum
+// with one integer data point per metric.
+func appendClientMetrics(dst []byte, ms []ClientMetric, start, now time.Time, delta bool) []byte {
+	temporality := uint64(2) // cumulative
+	if delta {
+		temporality = 1
+	}
+
+	var scopeMetrics []byte
+	scopeMetrics = appendProtoBytes(scopeMetrics, 1, appendProtoString(nil, 1, "franz-go")) // scope: name
+	for _, m := range ms {
+		var point []byte
+		if m.Sum {
+			point = appendProtoFixed64(point, 2, uint64(start.UnixNano())) // start_time_unix_nano
+		}
+		point = appendProtoFixed64(point, 3, uint64(now.UnixNano())) // time_unix_nano
+		point = appendProtoFixed64(point, 6, uint64(m.Value))        // as_int
+
+		var data []byte
+		data = appendProtoBytes(data, 1, point) // data_points
+		field := uint64(5)                      // gauge
+		if m.Sum {
+			data = appendProtoVarint(data, 2, temporality) // aggregation_temporality
+			data = appendProtoVarint(data, 3, 1)           // is_monotonic
+			field = 7                                      // sum
+		}
+
+		var metric []byte
+		metric = appendProtoString(metric, 1, m.Name) // name
+		metric = appendProtoBytes(metric, field, data)
+		scopeMetrics = appendProtoBytes(scopeMetrics, 2, metric) // metrics
+	}
+
+	resourceMetrics := appendProtoBytes(nil, 2, scopeMetrics) // scope_metrics
+	return appendProtoBytes(dst, 1, resourceMetrics)          // resource_metrics
+}
+
+func appendProtoUvarint(dst []byte, v uint64) []byte {
+	for v >= 0x80 {
+		dst = append(dst, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(dst, byte(v))
+}
+
+func appendProtoVarint(dst []byte, field, v uint64) []byte {
+	dst = appendProtoUvarint(dst, field<<3) // wire type 0
+	return appendProtoUvarint(dst, v)
+}
+
+func appendProtoFixed64(dst []byte, field, v uint64) []byte {
+	dst = appendProtoUvarint(dst, field<<3|1)
+	return append(dst,
+		byte(v), byte(v>>8), byte(v>>16), byte(v>>24),
+		byte(v>>32), byte(v>>40), byte(v>>48), byte(v>>56),
+	)
+}
+
+func appendProtoBytes(dst []byte, field uint64, b []byte) []byte {
+	dst = appendProtoUvarint(dst, field<<3|2)
+	dst = appendProtoUvarint(dst, uint64(len(b)))
+	return append(dst, b...)
+}
+
+func appendProtoString(dst []byte, field uint64, s string) []byte {
+	dst = appendProtoUvarint(dst, field<<3|2)
+	dst = appendProtoUvarint(dst, uint64(len(s)))
+	return append(dst, s...)
+}
@@ -0,0 +1,119 @@
+package kgo
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// protoFields parses one level of protobuf fields, returning field numbers
+// mapped to their varint, fixed64, or length delimited values.
+func protoFields(t *testing.T, b []byte) map[uint64][]any {
+	t.Helper()
+	fields := make(map[uint64][]any)
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		b = b[n:]
+		switch field, wire := tag>>3, tag&7; wire {
+		case 0:
+			v, n := binary.Uvarint(b)
+			b = b[n:]
+			fields[field] = append(fields[field], v)
+		case 1:
+			fields[field] = append(fields[field], binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			b = b[n:]
+			fields[field] = append(fields[field], b[:l])
+			b = b[l:]
+		default:
+			t.Fatalf("unexpected wire type %d", wire)
+		}
+	}
+	return fields
+}
+
+func TestAppendClientMetrics(t *testing.T) {
+	start := time.Unix(1, 0)
+	now := time.Unix(2, 0)
+	ms := []ClientMetric{
+		{Name: "org.apache.kafka.client.connection.creations", Value: 3, Sum: true},
+		{Name: "org.apache.kafka.client.connection.active", Value: -1},
+	}
+
+	for _, delta := range []bool{false, true} {
+		data := appendClientMetrics(nil, ms, start, now, delta)
+
+		resourceMetrics := protoFields(t, data)[1]
+		if len(resourceMetrics) != 1 {
+			t.Fatalf("got %d resource metrics, exp 1", len(resourceMetrics))
+		}
+		scopeMetrics := protoFields(t, resourceMetrics[0].([]byte))[2]
+		if len(scopeMetrics) != 1 {
+			t.Fatalf("got %d scope metrics, exp 1", len(scopeMetrics))
+		}
+		scope := protoFields(t, scopeMetrics[0].([]byte))
+		if name := protoFields(t, scope[1][0].([]byte))[1][0].([]byte); string(name) != "franz-go" {
+			t.Errorf("got scope name %q, exp franz-go", name)
+		}
+
+		metrics := scope[2]
+		if len(metrics) != 2 {
+			t.Fatalf("got %d metrics, exp 2", len(metrics))
+		}
+
+		sum := protoFields(t, metrics[0].([]byte))
+		if name := string(sum[1][0].([]byte)); name != ms[0].Name {
+			t.Errorf("got sum name %q, exp %q", name, ms[0].Name)
+		}
+		sumData := protoFields(t, sum[7][0].([]byte))
+		expTemporality := uint64(2)
+		if delta {
+			expTemporality = 1
+		}
+		if got := sumData[2][0].(uint64); got != expTemporality {
+			t.Errorf("delta %v: got temporality %d, exp %d", delta, got, expTemporality)
+		}
+		if monotonic := sumData[3][0].(uint64); monotonic != 1 {
+			t.Error("sum is not monotonic")
+		}
+		point := protoFields(t, sumData[1][0].([]byte))
+		if got, exp := []any{point[2][0], point[3][0], point[6][0]}, []any{uint64(1e9), uint64(2e9), uint64(3)}; !reflect.DeepEqual(got, exp) {
+			t.Errorf("got sum point %v, exp %v", got, exp)
+		}
+
+		gauge := protoFields(t, metrics[1].([]byte))
+		if _, isSum := gauge[7]; isSum {
+			t.Error("gauge encoded as a sum")
+		}
+		point = protoFields(t, protoFields(t, gauge[5][0].([]byte))[1][0].([]byte))
+		if _, hasStart := point[2]; hasStart {
+			t.Error("gauge point has a start time")
+		}
+		if v := int64(point[6][0].(uint64)); v != -1 {
+			t.Errorf("got gauge value %d, exp -1", v)
+		}
+	}
+}
+
+func TestFilterClientMetrics(t *testing.T) {
+	ms := []ClientMetric{
+		{Name: "org.apache.kafka.client.connection.creations"},
+		{Name: "org.apache.kafka.client.producer.queue.messages"},
+	}
+	for _, test := range []struct {
+		prefixes []string
+		exp      []ClientMetric
+	}{
+		{nil, nil},
+		{[]string{""}, ms},
+		{[]string{"org.apache.kafka.client.producer."}, ms[1:]},
+		{[]string{"org.apache.kafka.client.connection", "org.apache.kafka.client"}, ms},
+	} {
+		if got := filterClientMetrics(ms, test.prefixes); !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("prefixes %q: got %v, exp %v", test.prefixes, got, test.exp)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package kgo
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ReloadTLSFiles returns a function for DialTLSConfigFn that loads a client
+// certificate and key from certFile and keyFile, and a PEM CA bundle from
+// caFile, into a clone of base. The files are checked on every dial and
+// reloaded if any file's size or modification time changed, which allows
+// short-lived certificates (such as from SPIFFE or Vault) to be rotated on
+// disk without recreating the client.
+//
+// certFile and keyFile can both be empty to not use a client certificate, and
+// caFile can be empty to use base's RootCAs (or the system roots). base can be
+// nil.
+//
+// If reloading fails after the files were previously loaded successfully, the
+// previously loaded config continues to be used and loading is retried on the
+// next dial. This avoids failing dials if files are observed mid-rotation.
+func ReloadTLSFiles(base *tls.Config, certFile, keyFile, caFile string) func(context.Context) (*tls.Config, error) {
+	r := &tlsReloader{
+		base:     base,
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+	}
+	return r.load
+}
+
+type tlsReloader struct {
+	base     *tls.Config
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu    sync.Mutex
+	stats []fileStat
+	cfg   *tls.Config
+}
+
+// fileStat is what we compare to determine if a file changed.
+type fileStat struct {
+	size    int64
+	modTime time.Time
+}
+
+func (r *tlsReloader) load(context.Context) (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats, err := r.statFiles()
+	if err == nil && r.cfg != nil && sameStats(stats, r.stats) {
+		return r.cfg, nil
+	}
+	if err == nil {
+		var cfg *tls.Config
+		if cfg, err = r.loadFiles(); err == nil {
+			r.cfg, r.stats = cfg, stats
+			return cfg, nil
+		}
+	}
+	if r.cfg != nil {
+		return r.cfg, nil
+	}
+	return nil, err
+}
+
+func (r *tlsReloader) files() []string {
+	var files []string
+	for _, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+func (r *tlsReloader) statFiles() ([]fileStat, error) {
+	var stats []fileStat
+	for _, f := range r.files() {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, fileStat{fi.Size(), fi.ModTime()})
+	}
+	return stats, nil
+}
+
+func sameStats(l, r []fileStat) bool {
+	if len(l) != len(r) {
+		return false
+	}
+	for i := range l {
+		if l[i].size != r[i].size || !l[i].modTime.Equal(r[i].modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *tlsReloader) loadFiles() (*tls.Config, error) {
+	cfg := new(tls.Config)
+	if r.base != nil {
+		cfg = r.base.Clone()
+	}
+	if (r.certFile == "") != (r.keyFile == "") {
+		return nil, errors.New("both or neither of the certificate and key files must be specified")
+	}
+	if r.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if r.caFile != "" {
+		ca, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", r.caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
@@ -0,0 +1,108 @@
+package kgo
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestCert(t *testing.T, dir, cn string, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	for name, data := range map[string][]byte{
+		"cert.pem": certPEM,
+		"key.pem":  keyPEM,
+		"ca.pem":   certPEM,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReloadTLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeTestCert(t, dir, "first", now.Add(-time.Minute))
+
+	load := ReloadTLSFiles(nil,
+		filepath.Join(dir, "cert.pem"),
+		filepath.Join(dir, "key.pem"),
+		filepath.Join(dir, "ca.pem"),
+	)
+	commonName := func() string {
+		t.Helper()
+		cfg, err := load(context.Background())
+		if err != nil {
+			t.Fatalf("unable to load: %v", err)
+		}
+		if len(cfg.Certificates) != 1 || cfg.RootCAs == nil {
+			t.Fatalf("loaded config is missing certificates or CAs")
+		}
+		leaf, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+
+	if cn := commonName(); cn != "first" {
+		t.Errorf("got cn %s != exp first", cn)
+	}
+	if cn := commonName(); cn != "first" {
+		t.Errorf("got cn %s != exp first on unchanged files", cn)
+	}
+
+	writeTestCert(t, dir, "second", now)
+	if cn := commonName(); cn != "second" {
+		t.Errorf("got cn %s != exp second after rotation", cn)
+	}
+
+	// A bad rotation keeps the prior config.
+	if err := os.WriteFile(filepath.Join(dir, "key.pem"), []byte("bad"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if cn := commonName(); cn != "second" {
+		t.Errorf("got cn %s != exp second after bad rotation", cn)
+	}
+
+	// Without a prior config, errors are returned.
+	bad := ReloadTLSFiles(nil, filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), "")
+	if _, err := bad(context.Background()); err == nil {
+		t.Error("unexpected success loading a bad key")
+	}
+}
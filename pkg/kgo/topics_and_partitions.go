@@ -6,6 +6,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/twmb/franz-go/pkg/kerr"
 )
@@ -231,7 +232,8 @@ type topicPartitions struct {
 
 	partsMu     sync.Mutex
 	partitioner TopicPartitioner
-	lb          *leastBackupInput // for partitioning if the partitioner is a LoadTopicPartitioner
+	lb          *leastBackupInput       // for partitioning if the partitioner is a LoadTopicPartitioner
+	cp          *clusterPartitionsInput // for partitioning if the partitioner is a TopicClusterPartitioner
 }
 
 func (t *topicPartitions) load() *topicPartitionsData { return t.v.Load().(*topicPartitionsData) }
@@ -480,6 +482,11 @@ type topicPartition struct {
 	// whether the data changed (leader or leader epoch, etc.).
 	topicPartitionData
 
+	// replicas is every replica for this partition, as of the metadata
+	// update that created this topicPartition. Consumer cursors track
+	// this for hedged fetches.
+	replicas []int32
+
 	// If we do not have a load error, we copy the records and cursor
 	// pointers from the old after updating any necessary fields in them
 	// (see migrate functions below).
@@ -549,11 +556,14 @@ func (old *topicPartition) migrateCursorTo(
 ) {
 	stopConsumerSession()
 
+	from := old.cursor.source.nodeID
 	old.cursor.source.removeCursor(old.cursor)
 
 	// With the session stopped, we can update fields on the old cursor
 	// with no concurrency issue.
 	old.cursor.source = new.cursor.source
+	old.cursor.replicaSince = time.Time{}
+	old.cursor.replicaErrs = 0
 
 	// KIP-320: if we had consumed some messages, we need to validate the
 	// leader epoch on the new broker to see if we experienced data loss
@@ -579,4 +589,5 @@ func (old *topicPartition) migrateCursorTo(
 
 	old.cursor.source.addCursor(old.cursor)
 	new.cursor = old.cursor
+	old.cursor.hookReplicaSwitch(from, ReplicaSwitchLeaderChange)
 }
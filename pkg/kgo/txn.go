@@ -245,8 +245,8 @@ func (s *GroupTransactSession) End(ctx context.Context, commit TransactionEndTry
 	s.failMu.Lock()
 	failed := s.failed()
 
-	precommit := s.cl.CommittedOffsets()
-	postcommit := s.cl.UncommittedOffsets()
+	precommit := s.cl.committedOffsets()
+	postcommit := s.cl.uncommittedOffsets()
 	s.failMu.Unlock()
 
 	var hasAbortableCommitErr bool
@@ -418,7 +418,7 @@ retry:
 	}
 
 	if !willTryCommit || endTxnErr != nil {
-		currentCommit := s.cl.CommittedOffsets()
+		currentCommit := s.cl.committedOffsets()
 		s.cl.cfg.logger.Log(LogLevelInfo, "transact session resetting to current committed state (potentially after a rejoin)",
 			"tried_commit", willTryCommit,
 			"commit_err", endTxnErr,
@@ -450,6 +450,108 @@ retry:
 	}
 }
 
+// Transform runs a consume-transform-produce loop until an unrecoverable error
+// occurs, the context is canceled, or the client is closed. Each iteration
+// polls up to maxPollRecords records (or all buffered records if
+// maxPollRecords is <= 0), begins a transaction, calls fn with the polled
+// records, produces every record fn returns, and ends the transaction,
+// committing the produced records and the consumed offsets atomically.
+//
+// If the transaction is aborted because the group rebalanced or because
+// producing failed with a retryable error (including ErrRecordTimeout and
+// ErrRecordRetries), the session rewinds to the last committed offsets, the
+// records are polled again, and fn is called again for them. Aborts due to
+// produce failures are backed off with the client's RetryBackoffFn. Thus, fn
+// may be called more than once for the same records and must not have side
+// effects outside of the records it returns.
+//
+// If fn returns an error, the transaction is aborted and the error is
+// returned. Non-retryable produce errors and errors from ending the
+// transaction are also returned, as are ErrClientClosed and the context's
+// error. Errors injected into polls are logged and otherwise ignored, since
+// the client retries internally. Transform can be called again after it
+// returns if the returned error is recoverable.
+//
+// This function must not be called concurrently with any other function on
+// the session, and fn must not produce on its own.
+func (s *GroupTransactSession) Transform(
+	ctx context.Context,
+	maxPollRecords int,
+	fn func(context.Context, []*Record) ([]*Record, error),
+) error {
+	var aborts int
+	for {
+		fetches := s.PollRecords(ctx, maxPollRecords)
+		if fetches.IsClientClosed() {
+			return ErrClientClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fetches.EachError(func(t string, p int32, err error) {
+			s.cl.cfg.logger.Log(LogLevelWarn, "transform poll returned error, continuing", "topic", t, "partition", p, "err", err)
+		})
+		in := fetches.Records()
+		if len(in) == 0 {
+			continue
+		}
+
+		if err := s.Begin(); err != nil {
+			return err
+		}
+
+		out, err := fn(ctx, in)
+		if err != nil {
+			if _, endErr := s.End(ctx, TryAbort); endErr != nil {
+				return endErr
+			}
+			return err
+		}
+
+		e := AbortingFirstErrPromise(s.cl)
+		for _, r := range out {
+			s.Produce(ctx, r, e.Promise())
+		}
+		produceErr := e.Err()
+
+		committed, err := s.End(ctx, produceErr == nil)
+		if err != nil {
+			return err
+		}
+		if committed {
+			aborts = 0
+			continue
+		}
+
+		// We aborted; either the group rebalanced (in which case we
+		// just continue with our new assignment), or producing failed.
+		if produceErr == nil {
+			continue
+		}
+		if !isRetryableTransformErr(produceErr) {
+			return produceErr
+		}
+		aborts++
+		s.cl.cfg.logger.Log(LogLevelInfo, "transform transaction aborted after a retryable produce error, backing off and retrying", "aborts", aborts, "err", produceErr)
+		after := time.NewTimer(s.cl.cfg.retryBackoff(aborts))
+		select {
+		case <-after.C:
+		case <-ctx.Done():
+			after.Stop()
+			return ctx.Err()
+		case <-s.cl.ctx.Done():
+			after.Stop()
+			return ErrClientClosed
+		}
+	}
+}
+
+func isRetryableTransformErr(err error) bool {
+	return errors.Is(err, ErrRecordTimeout) ||
+		errors.Is(err, ErrRecordRetries) ||
+		kerr.IsRetriable(err)
+}
+
 // BeginTransaction sets the client to a transactional state, erroring if there
 // is no transactional ID, or if the producer is currently in a fatal
 // (unrecoverable) state, or if the client is already in a transaction.
@@ -0,0 +1,208 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// TransactionalPool manages a fixed set of transactional producer clients and
+// lends them out one unit of work at a time. This is useful for services that
+// run many independent transactions concurrently: a client can only have one
+// transaction open at a time, but creating a client per transaction is
+// expensive and leaks transactional IDs.
+//
+// Every client in the pool uses a stable transactional ID, "<prefix>-<n>", so
+// a restarted service using the same prefix and size fences any zombie
+// clients from its prior run. Clients are created lazily when first lent out.
+// If a client is returned to the pool with an error, the pool closes it and
+// creates a new client with the same transactional ID on the next Acquire;
+// initializing the new client's producer ID aborts any transaction the old
+// client left open and fences the old client.
+type TransactionalPool struct {
+	opts  []Opt
+	slots chan *txnPoolSlot
+	quit  chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+	all    []*txnPoolSlot
+	lent   map[*Client]*txnPoolSlot
+}
+
+type txnPoolSlot struct {
+	id string
+	cl *Client // nil until first lent out or after being replaced; guarded by the pool mu
+}
+
+// NewTransactionalPool returns a pool of size transactional clients, each
+// created with opts and a TransactionalID of "<idPrefix>-<n>" for n in [0,
+// size). Any TransactionalID in opts is overridden.
+//
+// Clients are not created until they are first acquired, so an error in opts
+// is returned from Acquire or Transact rather than from this function.
+func NewTransactionalPool(idPrefix string, size int, opts ...Opt) (*TransactionalPool, error) {
+	if idPrefix == "" {
+		return nil, errors.New("transactional pool requires a non-empty transactional ID prefix")
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid transactional pool size %d, must be positive", size)
+	}
+	p := &TransactionalPool{
+		opts:  opts[:len(opts):len(opts)], // we append our ID per client
+		slots: make(chan *txnPoolSlot, size),
+		quit:  make(chan struct{}),
+		lent:  make(map[*Client]*txnPoolSlot),
+	}
+	for i := 0; i < size; i++ {
+		slot := &txnPoolSlot{id: fmt.Sprintf("%s-%d", idPrefix, i)}
+		p.all = append(p.all, slot)
+		p.slots <- slot
+	}
+	return p, nil
+}
+
+// Acquire waits for a client to be available, returning it for exclusive use
+// until it is given back with Release. This returns the context's error if
+// the context is canceled while waiting, ErrClientClosed if the pool is
+// closed, or an error if a new client could not be created.
+//
+// The acquired client is not in a transaction; you must begin and end a
+// transaction yourself. For the simpler, recommended flow, see Transact.
+func (p *TransactionalPool) Acquire(ctx context.Context) (*Client, error) {
+	var slot *txnPoolSlot
+	select {
+	case slot = <-p.slots:
+	case <-p.quit:
+		return nil, ErrClientClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		p.slots <- slot
+		return nil, ErrClientClosed
+	}
+	if slot.cl == nil {
+		cl, err := NewClient(append(p.opts, TransactionalID(slot.id))...)
+		if err != nil {
+			p.slots <- slot
+			return nil, err
+		}
+		slot.cl = cl
+	}
+	p.lent[slot.cl] = slot
+	return slot.cl, nil
+}
+
+// Release returns a client acquired from Acquire to the pool. If err is
+// non-nil, or if the client is still in a transaction, the client is closed
+// and replaced with a new client using the same transactional ID. You should
+// pass any error from beginning, flushing, or ending the transaction.
+//
+// Releasing a client that was not acquired from this pool, or releasing a
+// client twice, is a no-op.
+func (p *TransactionalPool) Release(cl *Client, err error) {
+	p.mu.Lock()
+	slot, ok := p.lent[cl]
+	delete(p.lent, cl)
+	p.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if err == nil {
+		cl.producer.txnMu.Lock()
+		inTxn := cl.producer.inTxn
+		cl.producer.txnMu.Unlock()
+		if inTxn {
+			err = errors.New("client was released while still in a transaction")
+		}
+	}
+	if err != nil {
+		// If the pool is closed, Close already closed the client.
+		p.mu.Lock()
+		replace := !p.closed
+		if replace {
+			slot.cl = nil
+		}
+		p.mu.Unlock()
+		if replace {
+			cl.cfg.logger.Log(LogLevelInfo, "replacing pooled transactional client", "transactional_id", slot.id, "err", err)
+			cl.Close()
+		}
+	}
+	p.slots <- slot
+}
+
+// Transact acquires a client, begins a transaction, and calls fn with the
+// client. If fn returns nil, the client is flushed and the transaction is
+// committed; otherwise, buffered records are aborted, the transaction is
+// aborted, and fn's error is returned. The client is released back to the
+// pool once the transaction ends, and is replaced if beginning, flushing, or
+// ending the transaction fails.
+//
+// This returns whether the transaction was committed. fn should return an
+// error if any record it produced failed (for example, by returning
+// ProduceSync(...).FirstErr()), and fn must not retain the client.
+func (p *TransactionalPool) Transact(ctx context.Context, fn func(context.Context, *Client) error) (committed bool, err error) {
+	cl, err := p.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var releaseErr error
+	defer func() { p.Release(cl, releaseErr) }()
+
+	if err := cl.BeginTransaction(); err != nil {
+		releaseErr = err
+		return false, err
+	}
+
+	if err := fn(ctx, cl); err != nil {
+		if abortErr := cl.AbortBufferedRecords(ctx); abortErr != nil {
+			releaseErr = abortErr
+		} else if abortErr := cl.EndTransaction(ctx, TryAbort); abortErr != nil {
+			releaseErr = abortErr
+		}
+		return false, err
+	}
+
+	if err := cl.Flush(ctx); err != nil {
+		releaseErr = err
+		return false, err
+	}
+	if err := cl.EndTransaction(ctx, TryCommit); err != nil {
+		releaseErr = err
+		return false, err
+	}
+	return true, nil
+}
+
+// Close closes the pool and every client it has created, including clients
+// that are currently lent out. Any blocked or future Acquire returns
+// ErrClientClosed. Close should only be called once all transactions have
+// ended.
+func (p *TransactionalPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.quit)
+	var cls []*Client
+	for _, slot := range p.all {
+		if slot.cl != nil {
+			cls = append(cls, slot.cl)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, cl := range cls {
+		cl.Close()
+	}
+}
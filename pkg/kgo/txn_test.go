@@ -9,6 +9,8 @@ import (
 	"strconv"
 	"testing"
 	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
 )
 
 // This test is identical to TestGroupETL but based around transactions.
@@ -260,3 +262,104 @@ func (c *testConsumer) transact(txnsBeforeQuit int) {
 		c.mu.Unlock()
 	}
 }
+
+func TestTransformStops(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewGroupTransactSession(
+		TransactionalID("transform"),
+		ConsumerGroup("transform"),
+		ConsumeTopics("transform"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = s.Transform(ctx, 0, func(context.Context, []*Record) ([]*Record, error) {
+		t.Error("unexpected transform call with no records")
+		return nil, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got err %v, exp %v", err, context.DeadlineExceeded)
+	}
+
+	for _, test := range []struct {
+		err error
+		exp bool
+	}{
+		{ErrRecordTimeout, true},
+		{ErrRecordRetries, true},
+		{kerr.NotLeaderForPartition, true},
+		{kerr.MessageTooLarge, false},
+		{errors.New("other"), false},
+	} {
+		if got := isRetryableTransformErr(test.err); got != test.exp {
+			t.Errorf("%v: got retryable %v, exp %v", test.err, got, test.exp)
+		}
+	}
+}
+
+func TestTransactionalPool(t *testing.T) {
+	if _, err := NewTransactionalPool("", 1); err == nil {
+		t.Error("expected error for an empty prefix")
+	}
+	if _, err := NewTransactionalPool("svc", 0); err == nil {
+		t.Error("expected error for a non-positive size")
+	}
+
+	p, err := NewTransactionalPool("svc", 2, SeedBrokers("127.0.0.1:1"), TransactionalID("ignored"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx := context.Background()
+	txnID := func(cl *Client) string { return *cl.cfg.txnID }
+
+	cl0, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl1, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id0, id1 := txnID(cl0), txnID(cl1); id0 != "svc-0" || id1 != "svc-1" {
+		t.Errorf("got transactional IDs %q %q, exp svc-0 svc-1", id0, id1)
+	}
+
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Acquire(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got err %v acquiring from an exhausted pool, exp deadline exceeded", err)
+	}
+
+	p.Release(cl0, nil)
+	p.Release(cl0, nil) // double release is a no-op
+	if again, err := p.Acquire(ctx); err != nil || again != cl0 {
+		t.Errorf("got client %p (err %v) after a clean release, exp the same client %p", again, err, cl0)
+	}
+
+	p.Release(cl1, errors.New("fenced"))
+	replaced, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced == cl1 || txnID(replaced) != "svc-1" {
+		t.Errorf("exp a new client with ID svc-1 after an errored release, got %p (prior %p) ID %q", replaced, cl1, txnID(replaced))
+	}
+	select {
+	case <-cl1.ctx.Done():
+	default:
+		t.Error("exp the errored client to be closed")
+	}
+
+	p.Release(cl0, nil)
+	p.Close()
+	if _, err := p.Acquire(ctx); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("got err %v acquiring from a closed pool, exp ErrClientClosed", err)
+	}
+}
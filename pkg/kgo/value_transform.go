@@ -0,0 +1,67 @@
+package kgo
+
+import "context"
+
+// ValueTransformer transforms record values as they are produced and as they
+// are consumed; see WithValueTransformer. A transformer is usually symmetric,
+// with FetchValue reversing ProduceValue: for example, ProduceValue can
+// encrypt a value and FetchValue can decrypt it, or ProduceValue can add a
+// signature header and FetchValue can verify it.
+//
+// Both functions can be called concurrently: ProduceValue is called in the
+// goroutine producing the record, and FetchValue is called in the goroutine
+// processing a fetch response from a broker.
+type ValueTransformer interface {
+	// ProduceValue returns the value to write to Kafka for a record that
+	// is being produced. The record's topic is set and the record can be
+	// modified, for example to add headers describing the transformation.
+	ProduceValue(r *Record) ([]byte, error)
+
+	// FetchValue returns the value to return to the user for a record
+	// that was consumed. All record fields are set and the record can be
+	// modified.
+	FetchValue(r *Record) ([]byte, error)
+}
+
+type valueTransformErrKey struct{}
+
+// ValueTransformErr returns the error from the client's ValueTransformer if
+// the transformer failed to transform a consumed record's value. If non-nil,
+// the record has its original value from Kafka.
+func ValueTransformErr(r *Record) error {
+	if r.Context == nil {
+		return nil
+	}
+	err, _ := r.Context.Value(valueTransformErrKey{}).(error)
+	return err
+}
+
+// valueTransformerFor returns the value transformer to use for the Kafka
+// topic, if any. The topics filter is given the application's topic name.
+func (cfg *cfg) valueTransformerFor(topic string) ValueTransformer {
+	if cfg.valueTransformer == nil {
+		return nil
+	}
+	if cfg.valueTransformerTopics != nil {
+		if cfg.topicFromKafka != nil {
+			topic = cfg.topicFromKafka(topic)
+		}
+		if !cfg.valueTransformerTopics(topic) {
+			return nil
+		}
+	}
+	return cfg.valueTransformer
+}
+
+func transformFetchValue(t ValueTransformer, r *Record) {
+	value, err := t.FetchValue(r)
+	if err != nil {
+		ctx := r.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		r.Context = context.WithValue(ctx, valueTransformErrKey{}, err)
+		return
+	}
+	r.Value = value
+}
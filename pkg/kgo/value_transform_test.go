@@ -0,0 +1,88 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+type tagTransformer struct{ err error }
+
+func (t tagTransformer) ProduceValue(r *Record) ([]byte, error) {
+	return append([]byte("enc:"), r.Value...), t.err
+}
+
+func (t tagTransformer) FetchValue(r *Record) ([]byte, error) {
+	return append([]byte("dec:"), r.Value...), t.err
+}
+
+func TestValueTransformer(t *testing.T) {
+	onlyFoo := func(topic string) bool { return topic == "foo" }
+	cl, err := NewClient(WithValueTransformer(tagTransformer{errors.New("boom")}, onlyFoo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	if cl.cfg.valueTransformerFor("foo") == nil || cl.cfg.valueTransformerFor("bar") != nil {
+		t.Error("transformer was not limited to topic foo")
+	}
+
+	done := make(chan error, 1)
+	pr := promisedRec{context.Background(), func(_ *Record, err error) { done <- err }, &Record{Topic: "foo"}}
+	cl.doPartitionRecord(new(topicPartitions), new(topicPartitionsData), pr)
+	if err := <-done; err == nil {
+		t.Error("unexpected success producing with a failing transformer")
+	}
+
+	// Our test batch has values "v"; a failing transform keeps the
+	// original value.
+	in := encodeTestBatch(0, 2, 1)
+	for _, test := range []struct {
+		t      ValueTransformer
+		expVal string
+		expErr bool
+	}{
+		{nil, "v", false},
+		{tagTransformer{}, "dec:v", false},
+		{tagTransformer{errors.New("boom")}, "v", true},
+	} {
+		o := cursorOffsetNext{from: &cursor{topic: "foo", valueTransformer: test.t}}
+		fp := o.processRespPartition(nil, &kmsg.FetchResponseTopicPartition{RecordBatches: in}, newDecompressor([5]Decompressor{}), nil)
+		for _, r := range fp.Records {
+			if string(r.Value) != test.expVal {
+				t.Errorf("got value %q, exp %q", r.Value, test.expVal)
+			}
+			if err := ValueTransformErr(r); (err != nil) != test.expErr {
+				t.Errorf("got transform err %v, exp err? %v", err, test.expErr)
+			}
+		}
+	}
+}
+
+func TestValueTransformerNamespace(t *testing.T) {
+	onlyFoo := func(topic string) bool { return topic == "foo" }
+	cl, err := NewClient(
+		TopicNamespace(TopicPrefix("tenant.")),
+		WithValueTransformer(tagTransformer{errors.New("boom")}, onlyFoo),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	// Records and cursors use Kafka names, while the filter is given
+	// application names.
+	if cl.cfg.valueTransformerFor("tenant.foo") == nil || cl.cfg.valueTransformerFor("tenant.bar") != nil {
+		t.Error("transformer was not limited to application topic foo")
+	}
+
+	done := make(chan error, 1)
+	pr := promisedRec{context.Background(), func(_ *Record, err error) { done <- err }, &Record{Topic: "tenant.foo"}}
+	cl.doPartitionRecord(new(topicPartitions), new(topicPartitionsData), pr)
+	if err := <-done; err == nil {
+		t.Error("namespaced record was not transformed")
+	}
+}
@@ -13,7 +13,31 @@ import (
 
 // MaxKey is the maximum key used for any messages in this package.
 // Note that this value will change as Kafka adds more messages.
-const MaxKey = 67
+const MaxKey = 75
+
+type ConsumerGroupDescribeResponseGroupMemberAssignmentTopicPartition struct {
+	TopicID [16]byte
+
+	Topic string
+
+	Partitions []int32
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to ConsumerGroupDescribeResponseGroupMemberAssignmentTopicPartition.
+func (v *ConsumerGroupDescribeResponseGroupMemberAssignmentTopicPartition) Default() {
+}
+
+// NewConsumerGroupDescribeResponseGroupMemberAssignmentTopicPartition returns a default ConsumerGroupDescribeResponseGroupMemberAssignmentTopicPartition
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewConsumerGroupDescribeResponseGroupMemberAssignmentTopicPartition() ConsumerGroupDescribeResponseGroupMemberAssignmentTopicPartition {
+	var v ConsumerGroupDescribeResponseGroupMemberAssignmentTopicPartition
+	v.Default()
+	return v
+}
 
 // MessageV0 is the message format Kafka used prior to 0.10.
 //
@@ -15379,12 +15403,17 @@ type ListGroupsRequest struct {
 	// "Dead", or "Empty". If empty, all groups are returned.
 	StatesFilter []string // v4+
 
+	// TypesFilter, part of KIP-848 and introduced in Kafka 3.8.0, allows
+	// filtering groups by type, where a type is any of "classic" or
+	// "consumer". If empty, all groups are returned.
+	TypesFilter []string // v5+
+
 	// UnknownTags are tags Kafka sent that we do not know the purpose of.
 	UnknownTags Tags // v3+
 }
 
 func (*ListGroupsRequest) Key() int16                 { return 16 }
-func (*ListGroupsRequest) MaxVersion() int16          { return 4 }
+func (*ListGroupsRequest) MaxVersion() int16          { return 5 }
 func (v *ListGroupsRequest) SetVersion(version int16) { v.Version = version }
 func (v *ListGroupsRequest) GetVersion() int16        { return v.Version }
 func (v *ListGroupsRequest) IsFlexible() bool         { return v.Version >= 3 }
@@ -15424,6 +15453,22 @@ func (v *ListGroupsRequest) AppendTo(dst []byte) []byte {
 			}
 		}
 	}
+	if version >= 5 {
+		v := v.TypesFilter
+		if isFlexible {
+			dst = kbin.AppendCompactArrayLen(dst, len(v))
+		} else {
+			dst = kbin.AppendArrayLen(dst, len(v))
+		}
+		for i := range v {
+			v := v[i]
+			if isFlexible {
+				dst = kbin.AppendCompactString(dst, v)
+			} else {
+				dst = kbin.AppendString(dst, v)
+			}
+		}
+	}
 	if isFlexible {
 		dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
 		dst = v.UnknownTags.AppendEach(dst)
@@ -15483,6 +15528,42 @@ func (v *ListGroupsRequest) readFrom(src []byte, unsafe bool) error {
 		v = a
 		s.StatesFilter = v
 	}
+	if version >= 5 {
+		v := s.TypesFilter
+		a := v
+		var l int32
+		if isFlexible {
+			l = b.CompactArrayLen()
+		} else {
+			l = b.ArrayLen()
+		}
+		if !b.Ok() {
+			return b.Complete()
+		}
+		a = a[:0]
+		if l > 0 {
+			a = append(a, make([]string, l)...)
+		}
+		for i := int32(0); i < l; i++ {
+			var v string
+			if unsafe {
+				if isFlexible {
+					v = b.UnsafeCompactString()
+				} else {
+					v = b.UnsafeString()
+				}
+			} else {
+				if isFlexible {
+					v = b.CompactString()
+				} else {
+					v = b.String()
+				}
+			}
+			a[i] = v
+		}
+		v = a
+		s.TypesFilter = v
+	}
 	if isFlexible {
 		s.UnknownTags = internalReadTags(&b)
 	}
@@ -15520,6 +15601,9 @@ type ListGroupsResponseGroup struct {
 	// The group state.
 	GroupState string // v4+
 
+	// The group type.
+	GroupType string // v5+
+
 	// UnknownTags are tags Kafka sent that we do not know the purpose of.
 	UnknownTags Tags // v3+
 }
@@ -15565,7 +15649,7 @@ type ListGroupsResponse struct {
 }
 
 func (*ListGroupsResponse) Key() int16                         { return 16 }
-func (*ListGroupsResponse) MaxVersion() int16                  { return 4 }
+func (*ListGroupsResponse) MaxVersion() int16                  { return 5 }
 func (v *ListGroupsResponse) SetVersion(version int16)         { v.Version = version }
 func (v *ListGroupsResponse) GetVersion() int16                { return v.Version }
 func (v *ListGroupsResponse) IsFlexible() bool                 { return v.Version >= 3 }
@@ -15619,6 +15703,14 @@ func (v *ListGroupsResponse) AppendTo(dst []byte) []byte {
 					dst = kbin.AppendString(dst, v)
 				}
 			}
+			if version >= 5 {
+				v := v.GroupType
+				if isFlexible {
+					dst = kbin.AppendCompactString(dst, v)
+				} else {
+					dst = kbin.AppendString(dst, v)
+				}
+			}
 			if isFlexible {
 				dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
 				dst = v.UnknownTags.AppendEach(dst)
@@ -15727,6 +15819,23 @@ func (v *ListGroupsResponse) readFrom(src []byte, unsafe bool) error {
 				}
 				s.GroupState = v
 			}
+			if version >= 5 {
+				var v string
+				if unsafe {
+					if isFlexible {
+						v = b.UnsafeCompactString()
+					} else {
+						v = b.UnsafeString()
+					}
+				} else {
+					if isFlexible {
+						v = b.CompactString()
+					} else {
+						v = b.String()
+					}
+				}
+				s.GroupType = v
+			}
 			if isFlexible {
 				s.UnknownTags = internalReadTags(&b)
 			}
@@ -43313,6 +43422,2575 @@ func NewAllocateProducerIDsResponse() AllocateProducerIDsResponse {
 	return v
 }
 
+// Assignment contains consumer group assignments.
+type ConsumerGroupDescribeResponseGroupMemberAssignment struct {
+	// The topics & partitions assigned to the member.
+	TopicPartitions []ConsumerGroupDescribeResponseGroupMemberAssignmentTopicPartition
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to ConsumerGroupDescribeResponseGroupMemberAssignment.
+func (v *ConsumerGroupDescribeResponseGroupMemberAssignment) Default() {
+}
+
+// NewConsumerGroupDescribeResponseGroupMemberAssignment returns a default ConsumerGroupDescribeResponseGroupMemberAssignment
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewConsumerGroupDescribeResponseGroupMemberAssignment() ConsumerGroupDescribeResponseGroupMemberAssignment {
+	var v ConsumerGroupDescribeResponseGroupMemberAssignment
+	v.Default()
+	return v
+}
+
+// ConsumerGroupDescribe is a part of KIP-848; this is the
+// "next generation" equivalent of DescribeGroups.
+type ConsumerGroupDescribeRequest struct {
+	// Version is the version of this message used with a Kafka broker.
+	Version int16
+
+	// The IDs of the groups to describe.
+	Groups []string
+
+	// Whether to include authorized operations.
+	IncludeAuthorizedOperations bool
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+func (*ConsumerGroupDescribeRequest) Key() int16                   { return 69 }
+func (*ConsumerGroupDescribeRequest) MaxVersion() int16            { return 0 }
+func (v *ConsumerGroupDescribeRequest) SetVersion(version int16)   { v.Version = version }
+func (v *ConsumerGroupDescribeRequest) GetVersion() int16          { return v.Version }
+func (v *ConsumerGroupDescribeRequest) IsFlexible() bool           { return v.Version >= 0 }
+func (v *ConsumerGroupDescribeRequest) IsGroupCoordinatorRequest() {}
+func (v *ConsumerGroupDescribeRequest) ResponseKind() Response {
+	r := &ConsumerGroupDescribeResponse{Version: v.Version}
+	r.Default()
+	return r
+}
+
+// RequestWith is requests v on r and returns the response or an error.
+// For sharded requests, the response may be merged and still return an error.
+// It is better to rely on client.RequestSharded than to rely on proper merging behavior.
+func (v *ConsumerGroupDescribeRequest) RequestWith(ctx context.Context, r Requestor) (*ConsumerGroupDescribeResponse, error) {
+	kresp, err := r.Request(ctx, v)
+	resp, _ := kresp.(*ConsumerGroupDescribeResponse)
+	return resp, err
+}
+
+func (v *ConsumerGroupDescribeRequest) AppendTo(dst []byte) []byte {
+	version := v.Version
+	_ = version
+	isFlexible := version >= 0
+	_ = isFlexible
+	{
+		v := v.Groups
+		if isFlexible {
+			dst = kbin.AppendCompactArrayLen(dst, len(v))
+		} else {
+			dst = kbin.AppendArrayLen(dst, len(v))
+		}
+		for i := range v {
+			v := v[i]
+			if isFlexible {
+				dst = kbin.AppendCompactString(dst, v)
+			} else {
+				dst = kbin.AppendString(dst, v)
+			}
+		}
+	}
+	{
+		v := v.IncludeAuthorizedOperations
+		dst = kbin.AppendBool(dst, v)
+	}
+	if isFlexible {
+		dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+		dst = v.UnknownTags.AppendEach(dst)
+	}
+	return dst
+}
+
+func (v *ConsumerGroupDescribeRequest) ReadFrom(src []byte) error {
+	return v.readFrom(src, false)
+}
+
+func (v *ConsumerGroupDescribeRequest) UnsafeReadFrom(src []byte) error {
+	return v.readFrom(src, true)
+}
+
+func (v *ConsumerGroupDescribeRequest) readFrom(src []byte, unsafe bool) error {
+	v.Default()
+	b := kbin.Reader{Src: src}
+	version := v.Version
+	_ = version
+	isFlexible := version >= 0
+	_ = isFlexible
+	s := v
+	{
+		v := s.Groups
+		a := v
+		var l int32
+		if isFlexible {
+			l = b.CompactArrayLen()
+		} else {
+			l = b.ArrayLen()
+		}
+		if !b.Ok() {
+			return b.Complete()
+		}
+		a = a[:0]
+		if l > 0 {
+			a = append(a, make([]string, l)...)
+		}
+		for i := int32(0); i < l; i++ {
+			var v string
+			if unsafe {
+				if isFlexible {
+					v = b.UnsafeCompactString()
+				} else {
+					v = b.UnsafeString()
+				}
+			} else {
+				if isFlexible {
+					v = b.CompactString()
+				} else {
+					v = b.String()
+				}
+			}
+			a[i] = v
+		}
+		v = a
+		s.Groups = v
+	}
+	{
+		v := b.Bool()
+		s.IncludeAuthorizedOperations = v
+	}
+	if isFlexible {
+		s.UnknownTags = internalReadTags(&b)
+	}
+	return b.Complete()
+}
+
+// NewPtrConsumerGroupDescribeRequest returns a pointer to a default ConsumerGroupDescribeRequest
+// This is a shortcut for creating a new(struct) and calling Default yourself.
+func NewPtrConsumerGroupDescribeRequest() *ConsumerGroupDescribeRequest {
+	var v ConsumerGroupDescribeRequest
+	v.Default()
+	return &v
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to ConsumerGroupDescribeRequest.
+func (v *ConsumerGroupDescribeRequest) Default() {
+}
+
+// NewConsumerGroupDescribeRequest returns a default ConsumerGroupDescribeRequest
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewConsumerGroupDescribeRequest() ConsumerGroupDescribeRequest {
+	var v ConsumerGroupDescribeRequest
+	v.Default()
+	return v
+}
+
+type ConsumerGroupDescribeResponseGroupMember struct {
+	// The member ID.
+	MemberID string
+
+	// The member instance ID, if any.
+	InstanceID *string
+
+	// The member rack ID, if any.
+	RackID *string
+
+	// The current member epoch.
+	MemberEpoch int32
+
+	// The client ID.
+	ClientID string
+
+	// The client host.
+	ClientHost string
+
+	// The subscribed topic names.
+	SubscribedTopics []string
+
+	// The subscribed topic regex, if any.
+	SubscribedTopicRegex *string
+
+	// The current assignment.
+	Assignment ConsumerGroupDescribeResponseGroupMemberAssignment
+
+	// The target assignment.
+	TargetAssignment ConsumerGroupDescribeResponseGroupMemberAssignment
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to ConsumerGroupDescribeResponseGroupMember.
+func (v *ConsumerGroupDescribeResponseGroupMember) Default() {
+	{
+		v := &v.Assignment
+		_ = v
+	}
+	{
+		v := &v.TargetAssignment
+		_ = v
+	}
+}
+
+// NewConsumerGroupDescribeResponseGroupMember returns a default ConsumerGroupDescribeResponseGroupMember
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewConsumerGroupDescribeResponseGroupMember() ConsumerGroupDescribeResponseGroupMember {
+	var v ConsumerGroupDescribeResponseGroupMember
+	v.Default()
+	return v
+}
+
+type ConsumerGroupDescribeResponseGroup struct {
+	// ErrorCode is the error for this response.
+	//
+	// Supported errors:
+	// - GROUP_AUTHORIZATION_FAILED (version 0+)
+	// - NOT_COORDINATOR (version 0+)
+	// - COORDINATOR_NOT_AVAILABLE (version 0+)
+	// - COORDINATOR_LOAD_IN_PROGRESS (version 0+)
+	// - INVALID_REQUEST (version 0+)
+	// - INVALID_GROUP_ID (version 0+)
+	// - GROUP_ID_NOT_FOUND (version 0+)
+	ErrorCode int16
+
+	// A supplementary message if this errored.
+	ErrorMessage *string
+
+	// The group ID.
+	Group string
+
+	// The group state.
+	State string
+
+	// The group epoch.
+	Epoch int32
+
+	// The assignment epoch.
+	AssignmentEpoch int32
+
+	// The selected assignor.
+	AssignorName string
+
+	// Members of the group.
+	Members []ConsumerGroupDescribeResponseGroupMember
+
+	// 32 bit bitfield representing authorized operations for the group.
+	//
+	// This field has a default of -2147483648.
+	AuthorizedOperations int32
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to ConsumerGroupDescribeResponseGroup.
+func (v *ConsumerGroupDescribeResponseGroup) Default() {
+	v.AuthorizedOperations = -2147483648
+}
+
+// NewConsumerGroupDescribeResponseGroup returns a default ConsumerGroupDescribeResponseGroup
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewConsumerGroupDescribeResponseGroup() ConsumerGroupDescribeResponseGroup {
+	var v ConsumerGroupDescribeResponseGroup
+	v.Default()
+	return v
+}
+
+// ConsumerGroupDescribeResponse is returned from a ConsumerGroupDescribeRequest.
+type ConsumerGroupDescribeResponse struct {
+	// Version is the version of this message used with a Kafka broker.
+	Version int16
+
+	// ThrottleMillis is how long of a throttle Kafka will apply to the client
+	// after responding to this request.
+	ThrottleMillis int32
+
+	Groups []ConsumerGroupDescribeResponseGroup
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+func (*ConsumerGroupDescribeResponse) Key() int16                 { return 69 }
+func (*ConsumerGroupDescribeResponse) MaxVersion() int16          { return 0 }
+func (v *ConsumerGroupDescribeResponse) SetVersion(version int16) { v.Version = version }
+func (v *ConsumerGroupDescribeResponse) GetVersion() int16        { return v.Version }
+func (v *ConsumerGroupDescribeResponse) IsFlexible() bool         { return v.Version >= 0 }
+func (v *ConsumerGroupDescribeResponse) Throttle() (int32, bool) {
+	return v.ThrottleMillis, v.Version >= 0
+}
+
+func (v *ConsumerGroupDescribeResponse) SetThrottle(throttleMillis int32) {
+	v.ThrottleMillis = throttleMillis
+}
+
+func (v *ConsumerGroupDescribeResponse) RequestKind() Request {
+	return &ConsumerGroupDescribeRequest{Version: v.Version}
+}
+
+func (v *ConsumerGroupDescribeResponse) AppendTo(dst []byte) []byte {
+	version := v.Version
+	_ = version
+	isFlexible := version >= 0
+	_ = isFlexible
+	{
+		v := v.ThrottleMillis
+		dst = kbin.AppendInt32(dst, v)
+	}
+	{
+		v := v.Groups
+		if isFlexible {
+			dst = kbin.AppendCompactArrayLen(dst, len(v))
+		} else {
+			dst = kbin.AppendArrayLen(dst, len(v))
+		}
+		for i := range v {
+			v := &v[i]
+			{
+				v := v.ErrorCode
+				dst = kbin.AppendInt16(dst, v)
+			}
+			{
+				v := v.ErrorMessage
+				if isFlexible {
+					dst = kbin.AppendCompactNullableString(dst, v)
+				} else {
+					dst = kbin.AppendNullableString(dst, v)
+				}
+			}
+			{
+				v := v.Group
+				if isFlexible {
+					dst = kbin.AppendCompactString(dst, v)
+				} else {
+					dst = kbin.AppendString(dst, v)
+				}
+			}
+			{
+				v := v.State
+				if isFlexible {
+					dst = kbin.AppendCompactString(dst, v)
+				} else {
+					dst = kbin.AppendString(dst, v)
+				}
+			}
+			{
+				v := v.Epoch
+				dst = kbin.AppendInt32(dst, v)
+			}
+			{
+				v := v.AssignmentEpoch
+				dst = kbin.AppendInt32(dst, v)
+			}
+			{
+				v := v.AssignorName
+				if isFlexible {
+					dst = kbin.AppendCompactString(dst, v)
+				} else {
+					dst = kbin.AppendString(dst, v)
+				}
+			}
+			{
+				v := v.Members
+				if isFlexible {
+					dst = kbin.AppendCompactArrayLen(dst, len(v))
+				} else {
+					dst = kbin.AppendArrayLen(dst, len(v))
+				}
+				for i := range v {
+					v := &v[i]
+					{
+						v := v.MemberID
+						if isFlexible {
+							dst = kbin.AppendCompactString(dst, v)
+						} else {
+							dst = kbin.AppendString(dst, v)
+						}
+					}
+					{
+						v := v.InstanceID
+						if isFlexible {
+							dst = kbin.AppendCompactNullableString(dst, v)
+						} else {
+							dst = kbin.AppendNullableString(dst, v)
+						}
+					}
+					{
+						v := v.RackID
+						if isFlexible {
+							dst = kbin.AppendCompactNullableString(dst, v)
+						} else {
+							dst = kbin.AppendNullableString(dst, v)
+						}
+					}
+					{
+						v := v.MemberEpoch
+						dst = kbin.AppendInt32(dst, v)
+					}
+					{
+						v := v.ClientID
+						if isFlexible {
+							dst = kbin.AppendCompactString(dst, v)
+						} else {
+							dst = kbin.AppendString(dst, v)
+						}
+					}
+					{
+						v := v.ClientHost
+						if isFlexible {
+							dst = kbin.AppendCompactString(dst, v)
+						} else {
+							dst = kbin.AppendString(dst, v)
+						}
+					}
+					{
+						v := v.SubscribedTopics
+						if isFlexible {
+							dst = kbin.AppendCompactArrayLen(dst, len(v))
+						} else {
+							dst = kbin.AppendArrayLen(dst, len(v))
+						}
+						for i := range v {
+							v := v[i]
+							if isFlexible {
+								dst = kbin.AppendCompactString(dst, v)
+							} else {
+								dst = kbin.AppendString(dst, v)
+							}
+						}
+					}
+					{
+						v := v.SubscribedTopicRegex
+						if isFlexible {
+							dst = kbin.AppendCompactNullableString(dst, v)
+						} else {
+							dst = kbin.AppendNullableString(dst, v)
+						}
+					}
+					{
+						v := &v.Assignment
+						{
+							v := v.TopicPartitions
+							if isFlexible {
+								dst = kbin.AppendCompactArrayLen(dst, len(v))
+							} else {
+								dst = kbin.AppendArrayLen(dst, len(v))
+							}
+							for i := range v {
+								v := &v[i]
+								{
+									v := v.TopicID
+									dst = kbin.AppendUuid(dst, v)
+								}
+								{
+									v := v.Topic
+									if isFlexible {
+										dst = kbin.AppendCompactString(dst, v)
+									} else {
+										dst = kbin.AppendString(dst, v)
+									}
+								}
+								{
+									v := v.Partitions
+									if isFlexible {
+										dst = kbin.AppendCompactArrayLen(dst, len(v))
+									} else {
+										dst = kbin.AppendArrayLen(dst, len(v))
+									}
+									for i := range v {
+										v := v[i]
+										dst = kbin.AppendInt32(dst, v)
+									}
+								}
+								if isFlexible {
+									dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+									dst = v.UnknownTags.AppendEach(dst)
+								}
+							}
+						}
+						if isFlexible {
+							dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+							dst = v.UnknownTags.AppendEach(dst)
+						}
+					}
+					{
+						v := &v.TargetAssignment
+						{
+							v := v.TopicPartitions
+							if isFlexible {
+								dst = kbin.AppendCompactArrayLen(dst, len(v))
+							} else {
+								dst = kbin.AppendArrayLen(dst, len(v))
+							}
+							for i := range v {
+								v := &v[i]
+								{
+									v := v.TopicID
+									dst = kbin.AppendUuid(dst, v)
+								}
+								{
+									v := v.Topic
+									if isFlexible {
+										dst = kbin.AppendCompactString(dst, v)
+									} else {
+										dst = kbin.AppendString(dst, v)
+									}
+								}
+								{
+									v := v.Partitions
+									if isFlexible {
+										dst = kbin.AppendCompactArrayLen(dst, len(v))
+									} else {
+										dst = kbin.AppendArrayLen(dst, len(v))
+									}
+									for i := range v {
+										v := v[i]
+										dst = kbin.AppendInt32(dst, v)
+									}
+								}
+								if isFlexible {
+									dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+									dst = v.UnknownTags.AppendEach(dst)
+								}
+							}
+						}
+						if isFlexible {
+							dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+							dst = v.UnknownTags.AppendEach(dst)
+						}
+					}
+					if isFlexible {
+						dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+						dst = v.UnknownTags.AppendEach(dst)
+					}
+				}
+			}
+			{
+				v := v.AuthorizedOperations
+				dst = kbin.AppendInt32(dst, v)
+			}
+			if isFlexible {
+				dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+				dst = v.UnknownTags.AppendEach(dst)
+			}
+		}
+	}
+	if isFlexible {
+		dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+		dst = v.UnknownTags.AppendEach(dst)
+	}
+	return dst
+}
+
+func (v *ConsumerGroupDescribeResponse) ReadFrom(src []byte) error {
+	return v.readFrom(src, false)
+}
+
+func (v *ConsumerGroupDescribeResponse) UnsafeReadFrom(src []byte) error {
+	return v.readFrom(src, true)
+}
+
+func (v *ConsumerGroupDescribeResponse) readFrom(src []byte, unsafe bool) error {
+	v.Default()
+	b := kbin.Reader{Src: src}
+	version := v.Version
+	_ = version
+	isFlexible := version >= 0
+	_ = isFlexible
+	s := v
+	{
+		v := b.Int32()
+		s.ThrottleMillis = v
+	}
+	{
+		v := s.Groups
+		a := v
+		var l int32
+		if isFlexible {
+			l = b.CompactArrayLen()
+		} else {
+			l = b.ArrayLen()
+		}
+		if !b.Ok() {
+			return b.Complete()
+		}
+		a = a[:0]
+		if l > 0 {
+			a = append(a, make([]ConsumerGroupDescribeResponseGroup, l)...)
+		}
+		for i := int32(0); i < l; i++ {
+			v := &a[i]
+			v.Default()
+			s := v
+			{
+				v := b.Int16()
+				s.ErrorCode = v
+			}
+			{
+				var v *string
+				if isFlexible {
+					if unsafe {
+						v = b.UnsafeCompactNullableString()
+					} else {
+						v = b.CompactNullableString()
+					}
+				} else {
+					if unsafe {
+						v = b.UnsafeNullableString()
+					} else {
+						v = b.NullableString()
+					}
+				}
+				s.ErrorMessage = v
+			}
+			{
+				var v string
+				if unsafe {
+					if isFlexible {
+						v = b.UnsafeCompactString()
+					} else {
+						v = b.UnsafeString()
+					}
+				} else {
+					if isFlexible {
+						v = b.CompactString()
+					} else {
+						v = b.String()
+					}
+				}
+				s.Group = v
+			}
+			{
+				var v string
+				if unsafe {
+					if isFlexible {
+						v = b.UnsafeCompactString()
+					} else {
+						v = b.UnsafeString()
+					}
+				} else {
+					if isFlexible {
+						v = b.CompactString()
+					} else {
+						v = b.String()
+					}
+				}
+				s.State = v
+			}
+			{
+				v := b.Int32()
+				s.Epoch = v
+			}
+			{
+				v := b.Int32()
+				s.AssignmentEpoch = v
+			}
+			{
+				var v string
+				if unsafe {
+					if isFlexible {
+						v = b.UnsafeCompactString()
+					} else {
+						v = b.UnsafeString()
+					}
+				} else {
+					if isFlexible {
+						v = b.CompactString()
+					} else {
+						v = b.String()
+					}
+				}
+				s.AssignorName = v
+			}
+			{
+				v := s.Members
+				a := v
+				var l int32
+				if isFlexible {
+					l = b.CompactArrayLen()
+				} else {
+					l = b.ArrayLen()
+				}
+				if !b.Ok() {
+					return b.Complete()
+				}
+				a = a[:0]
+				if l > 0 {
+					a = append(a, make([]ConsumerGroupDescribeResponseGroupMember, l)...)
+				}
+				for i := int32(0); i < l; i++ {
+					v := &a[i]
+					v.Default()
+					s := v
+					{
+						var v string
+						if unsafe {
+							if isFlexible {
+								v = b.UnsafeCompactString()
+							} else {
+								v = b.UnsafeString()
+							}
+						} else {
+							if isFlexible {
+								v = b.CompactString()
+							} else {
+								v = b.String()
+							}
+						}
+						s.MemberID = v
+					}
+					{
+						var v *string
+						if isFlexible {
+							if unsafe {
+								v = b.UnsafeCompactNullableString()
+							} else {
+								v = b.CompactNullableString()
+							}
+						} else {
+							if unsafe {
+								v = b.UnsafeNullableString()
+							} else {
+								v = b.NullableString()
+							}
+						}
+						s.InstanceID = v
+					}
+					{
+						var v *string
+						if isFlexible {
+							if unsafe {
+								v = b.UnsafeCompactNullableString()
+							} else {
+								v = b.CompactNullableString()
+							}
+						} else {
+							if unsafe {
+								v = b.UnsafeNullableString()
+							} else {
+								v = b.NullableString()
+							}
+						}
+						s.RackID = v
+					}
+					{
+						v := b.Int32()
+						s.MemberEpoch = v
+					}
+					{
+						var v string
+						if unsafe {
+							if isFlexible {
+								v = b.UnsafeCompactString()
+							} else {
+								v = b.UnsafeString()
+							}
+						} else {
+							if isFlexible {
+								v = b.CompactString()
+							} else {
+								v = b.String()
+							}
+						}
+						s.ClientID = v
+					}
+					{
+						var v string
+						if unsafe {
+							if isFlexible {
+								v = b.UnsafeCompactString()
+							} else {
+								v = b.UnsafeString()
+							}
+						} else {
+							if isFlexible {
+								v = b.CompactString()
+							} else {
+								v = b.String()
+							}
+						}
+						s.ClientHost = v
+					}
+					{
+						v := s.SubscribedTopics
+						a := v
+						var l int32
+						if isFlexible {
+							l = b.CompactArrayLen()
+						} else {
+							l = b.ArrayLen()
+						}
+						if !b.Ok() {
+							return b.Complete()
+						}
+						a = a[:0]
+						if l > 0 {
+							a = append(a, make([]string, l)...)
+						}
+						for i := int32(0); i < l; i++ {
+							var v string
+							if unsafe {
+								if isFlexible {
+									v = b.UnsafeCompactString()
+								} else {
+									v = b.UnsafeString()
+								}
+							} else {
+								if isFlexible {
+									v = b.CompactString()
+								} else {
+									v = b.String()
+								}
+							}
+							a[i] = v
+						}
+						v = a
+						s.SubscribedTopics = v
+					}
+					{
+						var v *string
+						if isFlexible {
+							if unsafe {
+								v = b.UnsafeCompactNullableString()
+							} else {
+								v = b.CompactNullableString()
+							}
+						} else {
+							if unsafe {
+								v = b.UnsafeNullableString()
+							} else {
+								v = b.NullableString()
+							}
+						}
+						s.SubscribedTopicRegex = v
+					}
+					{
+						v := &s.Assignment
+						v.Default()
+						s := v
+						{
+							v := s.TopicPartitions
+							a := v
+							var l int32
+							if isFlexible {
+								l = b.CompactArrayLen()
+							} else {
+								l = b.ArrayLen()
+							}
+							if !b.Ok() {
+								return b.Complete()
+							}
+							a = a[:0]
+							if l > 0 {
+								a = append(a, make([]ConsumerGroupDescribeResponseGroupMemberAssignmentTopicPartition, l)...)
+							}
+							for i := int32(0); i < l; i++ {
+								v := &a[i]
+								v.Default()
+								s := v
+								{
+									v := b.Uuid()
+									s.TopicID = v
+								}
+								{
+									var v string
+									if unsafe {
+										if isFlexible {
+											v = b.UnsafeCompactString()
+										} else {
+											v = b.UnsafeString()
+										}
+									} else {
+										if isFlexible {
+											v = b.CompactString()
+										} else {
+											v = b.String()
+										}
+									}
+									s.Topic = v
+								}
+								{
+									v := s.Partitions
+									a := v
+									var l int32
+									if isFlexible {
+										l = b.CompactArrayLen()
+									} else {
+										l = b.ArrayLen()
+									}
+									if !b.Ok() {
+										return b.Complete()
+									}
+									a = a[:0]
+									if l > 0 {
+										a = append(a, make([]int32, l)...)
+									}
+									for i := int32(0); i < l; i++ {
+										v := b.Int32()
+										a[i] = v
+									}
+									v = a
+									s.Partitions = v
+								}
+								if isFlexible {
+									s.UnknownTags = internalReadTags(&b)
+								}
+							}
+							v = a
+							s.TopicPartitions = v
+						}
+						if isFlexible {
+							s.UnknownTags = internalReadTags(&b)
+						}
+					}
+					{
+						v := &s.TargetAssignment
+						v.Default()
+						s := v
+						{
+							v := s.TopicPartitions
+							a := v
+							var l int32
+							if isFlexible {
+								l = b.CompactArrayLen()
+							} else {
+								l = b.ArrayLen()
+							}
+							if !b.Ok() {
+								return b.Complete()
+							}
+							a = a[:0]
+							if l > 0 {
+								a = append(a, make([]ConsumerGroupDescribeResponseGroupMemberAssignmentTopicPartition, l)...)
+							}
+							for i := int32(0); i < l; i++ {
+								v := &a[i]
+								v.Default()
+								s := v
+								{
+									v := b.Uuid()
+									s.TopicID = v
+								}
+								{
+									var v string
+									if unsafe {
+										if isFlexible {
+											v = b.UnsafeCompactString()
+										} else {
+											v = b.UnsafeString()
+										}
+									} else {
+										if isFlexible {
+											v = b.CompactString()
+										} else {
+											v = b.String()
+										}
+									}
+									s.Topic = v
+								}
+								{
+									v := s.Partitions
+									a := v
+									var l int32
+									if isFlexible {
+										l = b.CompactArrayLen()
+									} else {
+										l = b.ArrayLen()
+									}
+									if !b.Ok() {
+										return b.Complete()
+									}
+									a = a[:0]
+									if l > 0 {
+										a = append(a, make([]int32, l)...)
+									}
+									for i := int32(0); i < l; i++ {
+										v := b.Int32()
+										a[i] = v
+									}
+									v = a
+									s.Partitions = v
+								}
+								if isFlexible {
+									s.UnknownTags = internalReadTags(&b)
+								}
+							}
+							v = a
+							s.TopicPartitions = v
+						}
+						if isFlexible {
+							s.UnknownTags = internalReadTags(&b)
+						}
+					}
+					if isFlexible {
+						s.UnknownTags = internalReadTags(&b)
+					}
+				}
+				v = a
+				s.Members = v
+			}
+			{
+				v := b.Int32()
+				s.AuthorizedOperations = v
+			}
+			if isFlexible {
+				s.UnknownTags = internalReadTags(&b)
+			}
+		}
+		v = a
+		s.Groups = v
+	}
+	if isFlexible {
+		s.UnknownTags = internalReadTags(&b)
+	}
+	return b.Complete()
+}
+
+// NewPtrConsumerGroupDescribeResponse returns a pointer to a default ConsumerGroupDescribeResponse
+// This is a shortcut for creating a new(struct) and calling Default yourself.
+func NewPtrConsumerGroupDescribeResponse() *ConsumerGroupDescribeResponse {
+	var v ConsumerGroupDescribeResponse
+	v.Default()
+	return &v
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to ConsumerGroupDescribeResponse.
+func (v *ConsumerGroupDescribeResponse) Default() {
+}
+
+// NewConsumerGroupDescribeResponse returns a default ConsumerGroupDescribeResponse
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewConsumerGroupDescribeResponse() ConsumerGroupDescribeResponse {
+	var v ConsumerGroupDescribeResponse
+	v.Default()
+	return v
+}
+
+// GetTelemetrySubscriptionsRequest, introduced in Kafka 3.7 with KIP-714,
+// asks a broker which client metrics the client should push, and how often.
+// The first request from a client uses a zero client instance ID, and the
+// broker assigns an ID in the response.
+type GetTelemetrySubscriptionsRequest struct {
+	// Version is the version of this message used with a Kafka broker.
+	Version int16
+
+	// The client instance ID, or a zero UUID if the client does not yet have
+	// one.
+	ClientInstanceID [16]byte
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+func (*GetTelemetrySubscriptionsRequest) Key() int16                 { return 71 }
+func (*GetTelemetrySubscriptionsRequest) MaxVersion() int16          { return 0 }
+func (v *GetTelemetrySubscriptionsRequest) SetVersion(version int16) { v.Version = version }
+func (v *GetTelemetrySubscriptionsRequest) GetVersion() int16        { return v.Version }
+func (v *GetTelemetrySubscriptionsRequest) IsFlexible() bool         { return v.Version >= 0 }
+func (v *GetTelemetrySubscriptionsRequest) ResponseKind() Response {
+	r := &GetTelemetrySubscriptionsResponse{Version: v.Version}
+	r.Default()
+	return r
+}
+
+// RequestWith is requests v on r and returns the response or an error.
+// For sharded requests, the response may be merged and still return an error.
+// It is better to rely on client.RequestSharded than to rely on proper merging behavior.
+func (v *GetTelemetrySubscriptionsRequest) RequestWith(ctx context.Context, r Requestor) (*GetTelemetrySubscriptionsResponse, error) {
+	kresp, err := r.Request(ctx, v)
+	resp, _ := kresp.(*GetTelemetrySubscriptionsResponse)
+	return resp, err
+}
+
+func (v *GetTelemetrySubscriptionsRequest) AppendTo(dst []byte) []byte {
+	version := v.Version
+	_ = version
+	isFlexible := version >= 0
+	_ = isFlexible
+	{
+		v := v.ClientInstanceID
+		dst = kbin.AppendUuid(dst, v)
+	}
+	if isFlexible {
+		dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+		dst = v.UnknownTags.AppendEach(dst)
+	}
+	return dst
+}
+
+func (v *GetTelemetrySubscriptionsRequest) ReadFrom(src []byte) error {
+	return v.readFrom(src, false)
+}
+
+func (v *GetTelemetrySubscriptionsRequest) UnsafeReadFrom(src []byte) error {
+	return v.readFrom(src, true)
+}
+
+func (v *GetTelemetrySubscriptionsRequest) readFrom(src []byte, unsafe bool) error {
+	v.Default()
+	b := kbin.Reader{Src: src}
+	version := v.Version
+	_ = version
+	isFlexible := version >= 0
+	_ = isFlexible
+	s := v
+	{
+		v := b.Uuid()
+		s.ClientInstanceID = v
+	}
+	if isFlexible {
+		s.UnknownTags = internalReadTags(&b)
+	}
+	return b.Complete()
+}
+
+// NewPtrGetTelemetrySubscriptionsRequest returns a pointer to a default GetTelemetrySubscriptionsRequest
+// This is a shortcut for creating a new(struct) and calling Default yourself.
+func NewPtrGetTelemetrySubscriptionsRequest() *GetTelemetrySubscriptionsRequest {
+	var v GetTelemetrySubscriptionsRequest
+	v.Default()
+	return &v
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to GetTelemetrySubscriptionsRequest.
+func (v *GetTelemetrySubscriptionsRequest) Default() {
+}
+
+// NewGetTelemetrySubscriptionsRequest returns a default GetTelemetrySubscriptionsRequest
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewGetTelemetrySubscriptionsRequest() GetTelemetrySubscriptionsRequest {
+	var v GetTelemetrySubscriptionsRequest
+	v.Default()
+	return v
+}
+
+// GetTelemetrySubscriptionsResponse is returned from a
+// GetTelemetrySubscriptionsRequest.
+type GetTelemetrySubscriptionsResponse struct {
+	// Version is the version of this message used with a Kafka broker.
+	Version int16
+
+	// ThrottleMillis is how long of a throttle Kafka will apply to the client
+	// after responding to this request.
+	ThrottleMillis int32
+
+	// The error code, or 0 if there was no error.
+	ErrorCode int16
+
+	// The client instance ID, assigned by the broker if the request used a
+	// zero UUID.
+	ClientInstanceID [16]byte
+
+	// A unique identifier for the current subscription set for this client
+	// instance.
+	SubscriptionID int32
+
+	// The compression types the broker accepts for PushTelemetry metrics, in
+	// order of preference; an empty list means uncompressed only.
+	AcceptedCompressionTypes []int8
+
+	// How often the client should push metrics, in milliseconds.
+	PushIntervalMillis int32
+
+	// The maximum size, in bytes, of the metrics in a PushTelemetry request.
+	TelemetryMaxBytes int32
+
+	// Whether the client should push delta temporality metrics, rather than
+	// cumulative.
+	DeltaTemporality bool
+
+	// The metric name prefixes the client should push: an empty list means no
+	// metrics, and a list containing only an empty string means all metrics.
+	RequestedMetrics []string
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+func (*GetTelemetrySubscriptionsResponse) Key() int16                 { return 71 }
+func (*GetTelemetrySubscriptionsResponse) MaxVersion() int16          { return 0 }
+func (v *GetTelemetrySubscriptionsResponse) SetVersion(version int16) { v.Version = version }
+func (v *GetTelemetrySubscriptionsResponse) GetVersion() int16        { return v.Version }
+func (v *GetTelemetrySubscriptionsResponse) IsFlexible() bool         { return v.Version >= 0 }
+func (v *GetTelemetrySubscriptionsResponse) Throttle() (int32, bool) {
+	return v.ThrottleMillis, v.Version >= 0
+}
+
+func (v *GetTelemetrySubscriptionsResponse) SetThrottle(throttleMillis int32) {
+	v.ThrottleMillis = throttleMillis
+}
+
+func (v *GetTelemetrySubscriptionsResponse) RequestKind() Request {
+	return &GetTelemetrySubscriptionsRequest{Version: v.Version}
+}
+
+func (v *GetTelemetrySubscriptionsResponse) AppendTo(dst []byte) []byte {
+	version := v.Version
+	_ = version
+	isFlexible := version >= 0
+	_ = isFlexible
+	{
+		v := v.ThrottleMillis
+		dst = kbin.AppendInt32(dst, v)
+	}
+	{
+		v := v.ErrorCode
+		dst = kbin.AppendInt16(dst, v)
+	}
+	{
+		v := v.ClientInstanceID
+		dst = kbin.AppendUuid(dst, v)
+	}
+	{
+		v := v.SubscriptionID
+		dst = kbin.AppendInt32(dst, v)
+	}
+	{
+		v := v.AcceptedCompressionTypes
+		if isFlexible {
+			dst = kbin.AppendCompactArrayLen(dst, len(v))
+		} else {
+			dst = kbin.AppendArrayLen(dst, len(v))
+		}
+		for i := range v {
+			v := v[i]
+			dst = kbin.AppendInt8(dst, v)
+		}
+	}
+	{
+		v := v.PushIntervalMillis
+		dst = kbin.AppendInt32(dst, v)
+	}
+	{
+		v := v.TelemetryMaxBytes
+		dst = kbin.AppendInt32(dst, v)
+	}
+	{
+		v := v.DeltaTemporality
+		dst = kbin.AppendBool(dst, v)
+	}
+	{
+		v := v.RequestedMetrics
+		if isFlexible {
+			dst = kbin.AppendCompactArrayLen(dst, len(v))
+		} else {
+			dst = kbin.AppendArrayLen(dst, len(v))
+		}
+		for i := range v {
+			v := v[i]
+			if isFlexible {
+				dst = kbin.AppendCompactString(dst, v)
+			} else {
+				dst = kbin.AppendString(dst, v)
+			}
+		}
+	}
+	if isFlexible {
+		dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+		dst = v.UnknownTags.AppendEach(dst)
+	}
+	return dst
+}
+
+func (v *GetTelemetrySubscriptionsResponse) ReadFrom(src []byte) error {
+	return v.readFrom(src, false)
+}
+
+func (v *GetTelemetrySubscriptionsResponse) UnsafeReadFrom(src []byte) error {
+	return v.readFrom(src, true)
+}
+
+func (v *GetTelemetrySubscriptionsResponse) readFrom(src []byte, unsafe bool) error {
+	v.Default()
+	b := kbin.Reader{Src: src}
+	version := v.Version
+	_ = version
+	isFlexible := version >= 0
+	_ = isFlexible
+	s := v
+	{
+		v := b.Int32()
+		s.ThrottleMillis = v
+	}
+	{
+		v := b.Int16()
+		s.ErrorCode = v
+	}
+	{
+		v := b.Uuid()
+		s.ClientInstanceID = v
+	}
+	{
+		v := b.Int32()
+		s.SubscriptionID = v
+	}
+	{
+		v := s.AcceptedCompressionTypes
+		a := v
+		var l int32
+		if isFlexible {
+			l = b.CompactArrayLen()
+		} else {
+			l = b.ArrayLen()
+		}
+		if !b.Ok() {
+			return b.Complete()
+		}
+		a = a[:0]
+		if l > 0 {
+			a = append(a, make([]int8, l)...)
+		}
+		for i := int32(0); i < l; i++ {
+			v := b.Int8()
+			a[i] = v
+		}
+		v = a
+		s.AcceptedCompressionTypes = v
+	}
+	{
+		v := b.Int32()
+		s.PushIntervalMillis = v
+	}
+	{
+		v := b.Int32()
+		s.TelemetryMaxBytes = v
+	}
+	{
+		v := b.Bool()
+		s.DeltaTemporality = v
+	}
+	{
+		v := s.RequestedMetrics
+		a := v
+		var l int32
+		if isFlexible {
+			l = b.CompactArrayLen()
+		} else {
+			l = b.ArrayLen()
+		}
+		if !b.Ok() {
+			return b.Complete()
+		}
+		a = a[:0]
+		if l > 0 {
+			a = append(a, make([]string, l)...)
+		}
+		for i := int32(0); i < l; i++ {
+			var v string
+			if unsafe {
+				if isFlexible {
+					v = b.UnsafeCompactString()
+				} else {
+					v = b.UnsafeString()
+				}
+			} else {
+				if isFlexible {
+					v = b.CompactString()
+				} else {
+					v = b.String()
+				}
+			}
+			a[i] = v
+		}
+		v = a
+		s.RequestedMetrics = v
+	}
+	if isFlexible {
+		s.UnknownTags = internalReadTags(&b)
+	}
+	return b.Complete()
+}
+
+// NewPtrGetTelemetrySubscriptionsResponse returns a pointer to a default GetTelemetrySubscriptionsResponse
+// This is a shortcut for creating a new(struct) and calling Default yourself.
+func NewPtrGetTelemetrySubscriptionsResponse() *GetTelemetrySubscriptionsResponse {
+	var v GetTelemetrySubscriptionsResponse
+	v.Default()
+	return &v
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to GetTelemetrySubscriptionsResponse.
+func (v *GetTelemetrySubscriptionsResponse) Default() {
+}
+
+// NewGetTelemetrySubscriptionsResponse returns a default GetTelemetrySubscriptionsResponse
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewGetTelemetrySubscriptionsResponse() GetTelemetrySubscriptionsResponse {
+	var v GetTelemetrySubscriptionsResponse
+	v.Default()
+	return v
+}
+
+// PushTelemetryRequest, introduced in Kafka 3.7 with KIP-714, pushes client
+// metrics to a broker in the OpenTelemetry protobuf MetricsData format.
+type PushTelemetryRequest struct {
+	// Version is the version of this message used with a Kafka broker.
+	Version int16
+
+	// The client instance ID, as returned in GetTelemetrySubscriptions.
+	ClientInstanceID [16]byte
+
+	// The subscription ID, as returned in GetTelemetrySubscriptions.
+	SubscriptionID int32
+
+	// Whether the client is terminating, in which case this is the final push.
+	Terminating bool
+
+	// The compression type the metrics are compressed with.
+	CompressionType int8
+
+	// The OpenTelemetry protobuf encoded metrics, compressed with
+	// CompressionType.
+	Metrics []byte
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+func (*PushTelemetryRequest) Key() int16                 { return 72 }
+func (*PushTelemetryRequest) MaxVersion() int16          { return 0 }
+func (v *PushTelemetryRequest) SetVersion(version int16) { v.Version = version }
+func (v *PushTelemetryRequest) GetVersion() int16        { return v.Version }
+func (v *PushTelemetryRequest) IsFlexible() bool         { return v.Version >= 0 }
+func (v *PushTelemetryRequest) ResponseKind() Response {
+	r := &PushTelemetryResponse{Version: v.Version}
+	r.Default()
+	return r
+}
+
+// RequestWith is requests v on r and returns the response or an error.
+// For sharded requests, the response may be merged and still return an error.
+// It is better to rely on client.RequestSharded than to rely on proper merging behavior.
+func (v *PushTelemetryRequest) RequestWith(ctx context.Context, r Requestor) (*PushTelemetryResponse, error) {
+	kresp, err := r.Request(ctx, v)
+	resp, _ := kresp.(*PushTelemetryResponse)
+	return resp, err
+}
+
+func (v *PushTelemetryRequest) AppendTo(dst []byte) []byte {
+	version := v.Version
+	_ = version
+	isFlexible := version >= 0
+	_ = isFlexible
+	{
+		v := v.ClientInstanceID
+		dst = kbin.AppendUuid(dst, v)
+	}
+	{
+		v := v.SubscriptionID
+		dst = kbin.AppendInt32(dst, v)
+	}
+	{
+		v := v.Terminating
+		dst = kbin.AppendBool(dst, v)
+	}
+	{
+		v := v.CompressionType
+		dst = kbin.AppendInt8(dst, v)
+	}
+	{
+		v := v.Metrics
+		if isFlexible {
+			dst = kbin.AppendCompactBytes(dst, v)
+		} else {
+			dst = kbin.AppendBytes(dst, v)
+		}
+	}
+	if isFlexible {
+		dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+		dst = v.UnknownTags.AppendEach(dst)
+	}
+	return dst
+}
+
+func (v *PushTelemetryRequest) ReadFrom(src []byte) error {
+	return v.readFrom(src, false)
+}
+
+func (v *PushTelemetryRequest) UnsafeReadFrom(src []byte) error {
+	return v.readFrom(src, true)
+}
+
+func (v *PushTelemetryRequest) readFrom(src []byte, unsafe bool) error {
+	v.Default()
+	b := kbin.Reader{Src: src}
+	version := v.Version
+	_ = version
+	isFlexible := version >= 0
+	_ = isFlexible
+	s := v
+	{
+		v := b.Uuid()
+		s.ClientInstanceID = v
+	}
+	{
+		v := b.Int32()
+		s.SubscriptionID = v
+	}
+	{
+		v := b.Bool()
+		s.Terminating = v
+	}
+	{
+		v := b.Int8()
+		s.CompressionType = v
+	}
+	{
+		var v []byte
+		if isFlexible {
+			v = b.CompactBytes()
+		} else {
+			v = b.Bytes()
+		}
+		s.Metrics = v
+	}
+	if isFlexible {
+		s.UnknownTags = internalReadTags(&b)
+	}
+	return b.Complete()
+}
+
+// NewPtrPushTelemetryRequest returns a pointer to a default PushTelemetryRequest
+// This is a shortcut for creating a new(struct) and calling Default yourself.
+func NewPtrPushTelemetryRequest() *PushTelemetryRequest {
+	var v PushTelemetryRequest
+	v.Default()
+	return &v
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to PushTelemetryRequest.
+func (v *PushTelemetryRequest) Default() {
+}
+
+// NewPushTelemetryRequest returns a default PushTelemetryRequest
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewPushTelemetryRequest() PushTelemetryRequest {
+	var v PushTelemetryRequest
+	v.Default()
+	return v
+}
+
+// PushTelemetryResponse is returned from a PushTelemetryRequest.
+type PushTelemetryResponse struct {
+	// Version is the version of this message used with a Kafka broker.
+	Version int16
+
+	// ThrottleMillis is how long of a throttle Kafka will apply to the client
+	// after responding to this request.
+	ThrottleMillis int32
+
+	// The error code, or 0 if there was no error.
+	ErrorCode int16
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+func (*PushTelemetryResponse) Key() int16                         { return 72 }
+func (*PushTelemetryResponse) MaxVersion() int16                  { return 0 }
+func (v *PushTelemetryResponse) SetVersion(version int16)         { v.Version = version }
+func (v *PushTelemetryResponse) GetVersion() int16                { return v.Version }
+func (v *PushTelemetryResponse) IsFlexible() bool                 { return v.Version >= 0 }
+func (v *PushTelemetryResponse) Throttle() (int32, bool)          { return v.ThrottleMillis, v.Version >= 0 }
+func (v *PushTelemetryResponse) SetThrottle(throttleMillis int32) { v.ThrottleMillis = throttleMillis }
+func (v *PushTelemetryResponse) RequestKind() Request {
+	return &PushTelemetryRequest{Version: v.Version}
+}
+
+func (v *PushTelemetryResponse) AppendTo(dst []byte) []byte {
+	version := v.Version
+	_ = version
+	isFlexible := version >= 0
+	_ = isFlexible
+	{
+		v := v.ThrottleMillis
+		dst = kbin.AppendInt32(dst, v)
+	}
+	{
+		v := v.ErrorCode
+		dst = kbin.AppendInt16(dst, v)
+	}
+	if isFlexible {
+		dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+		dst = v.UnknownTags.AppendEach(dst)
+	}
+	return dst
+}
+
+func (v *PushTelemetryResponse) ReadFrom(src []byte) error {
+	return v.readFrom(src, false)
+}
+
+func (v *PushTelemetryResponse) UnsafeReadFrom(src []byte) error {
+	return v.readFrom(src, true)
+}
+
+func (v *PushTelemetryResponse) readFrom(src []byte, unsafe bool) error {
+	v.Default()
+	b := kbin.Reader{Src: src}
+	version := v.Version
+	_ = version
+	isFlexible := version >= 0
+	_ = isFlexible
+	s := v
+	{
+		v := b.Int32()
+		s.ThrottleMillis = v
+	}
+	{
+		v := b.Int16()
+		s.ErrorCode = v
+	}
+	if isFlexible {
+		s.UnknownTags = internalReadTags(&b)
+	}
+	return b.Complete()
+}
+
+// NewPtrPushTelemetryResponse returns a pointer to a default PushTelemetryResponse
+// This is a shortcut for creating a new(struct) and calling Default yourself.
+func NewPtrPushTelemetryResponse() *PushTelemetryResponse {
+	var v PushTelemetryResponse
+	v.Default()
+	return &v
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to PushTelemetryResponse.
+func (v *PushTelemetryResponse) Default() {
+}
+
+// NewPushTelemetryResponse returns a default PushTelemetryResponse
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewPushTelemetryResponse() PushTelemetryResponse {
+	var v PushTelemetryResponse
+	v.Default()
+	return v
+}
+
+type DescribeTopicPartitionsRequestTopic struct {
+	// The topic name.
+	Topic string
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to DescribeTopicPartitionsRequestTopic.
+func (v *DescribeTopicPartitionsRequestTopic) Default() {
+}
+
+// NewDescribeTopicPartitionsRequestTopic returns a default DescribeTopicPartitionsRequestTopic
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewDescribeTopicPartitionsRequestTopic() DescribeTopicPartitionsRequestTopic {
+	var v DescribeTopicPartitionsRequestTopic
+	v.Default()
+	return v
+}
+
+type DescribeTopicPartitionsRequestCursor struct {
+	// The name of the topic to start from.
+	Topic string
+
+	// The partition index to start from.
+	Partition int32
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to DescribeTopicPartitionsRequestCursor.
+func (v *DescribeTopicPartitionsRequestCursor) Default() {
+}
+
+// NewDescribeTopicPartitionsRequestCursor returns a default DescribeTopicPartitionsRequestCursor
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewDescribeTopicPartitionsRequestCursor() DescribeTopicPartitionsRequestCursor {
+	var v DescribeTopicPartitionsRequestCursor
+	v.Default()
+	return v
+}
+
+// DescribeTopicPartitionsRequest, introduced in Kafka 3.7 with KIP-966, is
+// a paginated alternative to Metadata requests for describing topics and
+// partitions. Responses are limited to a number of partitions, and a cursor is
+// returned to continue describing from where the response stopped.
+type DescribeTopicPartitionsRequest struct {
+	// Version is the version of this message used with a Kafka broker.
+	Version int16
+
+	// The topics to describe.
+	Topics []DescribeTopicPartitionsRequestTopic
+
+	// The maximum number of partitions included in the response.
+	//
+	// This field has a default of 2000.
+	ResponsePartitionLimit int32
+
+	// The first topic and partition index to fetch details for, or null to
+	// start from the beginning.
+	Cursor *DescribeTopicPartitionsRequestCursor
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+func (*DescribeTopicPartitionsRequest) Key() int16                 { return 75 }
+func (*DescribeTopicPartitionsRequest) MaxVersion() int16          { return 0 }
+func (v *DescribeTopicPartitionsRequest) SetVersion(version int16) { v.Version = version }
+func (v *DescribeTopicPartitionsRequest) GetVersion() int16        { return v.Version }
+func (v *DescribeTopicPartitionsRequest) IsFlexible() bool         { return v.Version >= 0 }
+func (v *DescribeTopicPartitionsRequest) ResponseKind() Response {
+	r := &DescribeTopicPartitionsResponse{Version: v.Version}
+	r.Default()
+	return r
+}
+
+// RequestWith is requests v on r and returns the response or an error.
+// For sharded requests, the response may be merged and still return an error.
+// It is better to rely on client.RequestSharded than to rely on proper merging behavior.
+func (v *DescribeTopicPartitionsRequest) RequestWith(ctx context.Context, r Requestor) (*DescribeTopicPartitionsResponse, error) {
+	kresp, err := r.Request(ctx, v)
+	resp, _ := kresp.(*DescribeTopicPartitionsResponse)
+	return resp, err
+}
+
+func (v *DescribeTopicPartitionsRequest) AppendTo(dst []byte) []byte {
+	version := v.Version
+	_ = version
+	isFlexible := version >= 0
+	_ = isFlexible
+	{
+		v := v.Topics
+		if isFlexible {
+			dst = kbin.AppendCompactArrayLen(dst, len(v))
+		} else {
+			dst = kbin.AppendArrayLen(dst, len(v))
+		}
+		for i := range v {
+			v := &v[i]
+			{
+				v := v.Topic
+				if isFlexible {
+					dst = kbin.AppendCompactString(dst, v)
+				} else {
+					dst = kbin.AppendString(dst, v)
+				}
+			}
+			if isFlexible {
+				dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+				dst = v.UnknownTags.AppendEach(dst)
+			}
+		}
+	}
+	{
+		v := v.ResponsePartitionLimit
+		dst = kbin.AppendInt32(dst, v)
+	}
+	{
+		v := v.Cursor
+		if v == nil {
+			dst = append(dst, 255)
+		} else {
+			dst = append(dst, 1)
+			{
+				v := v.Topic
+				if isFlexible {
+					dst = kbin.AppendCompactString(dst, v)
+				} else {
+					dst = kbin.AppendString(dst, v)
+				}
+			}
+			{
+				v := v.Partition
+				dst = kbin.AppendInt32(dst, v)
+			}
+			if isFlexible {
+				dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+				dst = v.UnknownTags.AppendEach(dst)
+			}
+		}
+	}
+	if isFlexible {
+		dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+		dst = v.UnknownTags.AppendEach(dst)
+	}
+	return dst
+}
+
+func (v *DescribeTopicPartitionsRequest) ReadFrom(src []byte) error {
+	return v.readFrom(src, false)
+}
+
+func (v *DescribeTopicPartitionsRequest) UnsafeReadFrom(src []byte) error {
+	return v.readFrom(src, true)
+}
+
+func (v *DescribeTopicPartitionsRequest) readFrom(src []byte, unsafe bool) error {
+	v.Default()
+	b := kbin.Reader{Src: src}
+	version := v.Version
+	_ = version
+	isFlexible := version >= 0
+	_ = isFlexible
+	s := v
+	{
+		v := s.Topics
+		a := v
+		var l int32
+		if isFlexible {
+			l = b.CompactArrayLen()
+		} else {
+			l = b.ArrayLen()
+		}
+		if !b.Ok() {
+			return b.Complete()
+		}
+		a = a[:0]
+		if l > 0 {
+			a = append(a, make([]DescribeTopicPartitionsRequestTopic, l)...)
+		}
+		for i := int32(0); i < l; i++ {
+			v := &a[i]
+			v.Default()
+			s := v
+			{
+				var v string
+				if unsafe {
+					if isFlexible {
+						v = b.UnsafeCompactString()
+					} else {
+						v = b.UnsafeString()
+					}
+				} else {
+					if isFlexible {
+						v = b.CompactString()
+					} else {
+						v = b.String()
+					}
+				}
+				s.Topic = v
+			}
+			if isFlexible {
+				s.UnknownTags = internalReadTags(&b)
+			}
+		}
+		v = a
+		s.Topics = v
+	}
+	{
+		v := b.Int32()
+		s.ResponsePartitionLimit = v
+	}
+	{
+		if present := b.Int8(); present != -1 && b.Ok() {
+			s.Cursor = new(DescribeTopicPartitionsRequestCursor)
+			v := s.Cursor
+			v.Default()
+			s := v
+			{
+				var v string
+				if unsafe {
+					if isFlexible {
+						v = b.UnsafeCompactString()
+					} else {
+						v = b.UnsafeString()
+					}
+				} else {
+					if isFlexible {
+						v = b.CompactString()
+					} else {
+						v = b.String()
+					}
+				}
+				s.Topic = v
+			}
+			{
+				v := b.Int32()
+				s.Partition = v
+			}
+			if isFlexible {
+				s.UnknownTags = internalReadTags(&b)
+			}
+		}
+	}
+	if isFlexible {
+		s.UnknownTags = internalReadTags(&b)
+	}
+	return b.Complete()
+}
+
+// NewPtrDescribeTopicPartitionsRequest returns a pointer to a default DescribeTopicPartitionsRequest
+// This is a shortcut for creating a new(struct) and calling Default yourself.
+func NewPtrDescribeTopicPartitionsRequest() *DescribeTopicPartitionsRequest {
+	var v DescribeTopicPartitionsRequest
+	v.Default()
+	return &v
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to DescribeTopicPartitionsRequest.
+func (v *DescribeTopicPartitionsRequest) Default() {
+	v.ResponsePartitionLimit = 2000
+	{
+		v := &v.Cursor
+		_ = v
+	}
+}
+
+// NewDescribeTopicPartitionsRequest returns a default DescribeTopicPartitionsRequest
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewDescribeTopicPartitionsRequest() DescribeTopicPartitionsRequest {
+	var v DescribeTopicPartitionsRequest
+	v.Default()
+	return v
+}
+
+type DescribeTopicPartitionsResponseTopicPartition struct {
+	// The partition error, or 0 if there was no error.
+	ErrorCode int16
+
+	// The partition index.
+	Partition int32
+
+	// The ID of the leader broker.
+	Leader int32
+
+	// The leader epoch of this partition.
+	//
+	// This field has a default of -1.
+	LeaderEpoch int32
+
+	// The set of all nodes that host this partition.
+	Replicas []int32
+
+	// The set of nodes that are in sync with the leader for this partition.
+	ISR []int32
+
+	// The new eligible leader replicas otherwise.
+	EligibleLeaderReplicas []int32
+
+	// The last known ELR.
+	LastKnownELR []int32
+
+	// The set of offline replicas of this partition.
+	OfflineReplicas []int32
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to DescribeTopicPartitionsResponseTopicPartition.
+func (v *DescribeTopicPartitionsResponseTopicPartition) Default() {
+	v.LeaderEpoch = -1
+}
+
+// NewDescribeTopicPartitionsResponseTopicPartition returns a default DescribeTopicPartitionsResponseTopicPartition
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewDescribeTopicPartitionsResponseTopicPartition() DescribeTopicPartitionsResponseTopicPartition {
+	var v DescribeTopicPartitionsResponseTopicPartition
+	v.Default()
+	return v
+}
+
+type DescribeTopicPartitionsResponseTopic struct {
+	// The topic error, or 0 if there was no error.
+	ErrorCode int16
+
+	// The topic name.
+	Topic *string
+
+	// The topic ID.
+	TopicID [16]byte
+
+	// Whether the topic is internal.
+	IsInternal bool
+
+	// Each partition in the topic.
+	Partitions []DescribeTopicPartitionsResponseTopicPartition
+
+	// 32-bit bitfield to represent authorized operations for this topic.
+	//
+	// This field has a default of -2147483648.
+	AuthorizedOperations int32
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to DescribeTopicPartitionsResponseTopic.
+func (v *DescribeTopicPartitionsResponseTopic) Default() {
+	v.AuthorizedOperations = -2147483648
+}
+
+// NewDescribeTopicPartitionsResponseTopic returns a default DescribeTopicPartitionsResponseTopic
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewDescribeTopicPartitionsResponseTopic() DescribeTopicPartitionsResponseTopic {
+	var v DescribeTopicPartitionsResponseTopic
+	v.Default()
+	return v
+}
+
+type DescribeTopicPartitionsResponseNextCursor struct {
+	// The name for the first topic to process.
+	Topic string
+
+	// The partition index to start with.
+	Partition int32
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to DescribeTopicPartitionsResponseNextCursor.
+func (v *DescribeTopicPartitionsResponseNextCursor) Default() {
+}
+
+// NewDescribeTopicPartitionsResponseNextCursor returns a default DescribeTopicPartitionsResponseNextCursor
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewDescribeTopicPartitionsResponseNextCursor() DescribeTopicPartitionsResponseNextCursor {
+	var v DescribeTopicPartitionsResponseNextCursor
+	v.Default()
+	return v
+}
+
+// DescribeTopicPartitionsResponse is returned from a
+// DescribeTopicPartitionsRequest.
+type DescribeTopicPartitionsResponse struct {
+	// Version is the version of this message used with a Kafka broker.
+	Version int16
+
+	// ThrottleMillis is how long of a throttle Kafka will apply to the client
+	// after responding to this request.
+	ThrottleMillis int32
+
+	// Each topic in the response.
+	Topics []DescribeTopicPartitionsResponseTopic
+
+	// The next topic and partition index to fetch details for, or null if
+	// there are no more partitions.
+	NextCursor *DescribeTopicPartitionsResponseNextCursor
+
+	// UnknownTags are tags Kafka sent that we do not know the purpose of.
+	UnknownTags Tags
+}
+
+func (*DescribeTopicPartitionsResponse) Key() int16                 { return 75 }
+func (*DescribeTopicPartitionsResponse) MaxVersion() int16          { return 0 }
+func (v *DescribeTopicPartitionsResponse) SetVersion(version int16) { v.Version = version }
+func (v *DescribeTopicPartitionsResponse) GetVersion() int16        { return v.Version }
+func (v *DescribeTopicPartitionsResponse) IsFlexible() bool         { return v.Version >= 0 }
+func (v *DescribeTopicPartitionsResponse) Throttle() (int32, bool) {
+	return v.ThrottleMillis, v.Version >= 0
+}
+
+func (v *DescribeTopicPartitionsResponse) SetThrottle(throttleMillis int32) {
+	v.ThrottleMillis = throttleMillis
+}
+
+func (v *DescribeTopicPartitionsResponse) RequestKind() Request {
+	return &DescribeTopicPartitionsRequest{Version: v.Version}
+}
+
+func (v *DescribeTopicPartitionsResponse) AppendTo(dst []byte) []byte {
+	version := v.Version
+	_ = version
+	isFlexible := version >= 0
+	_ = isFlexible
+	{
+		v := v.ThrottleMillis
+		dst = kbin.AppendInt32(dst, v)
+	}
+	{
+		v := v.Topics
+		if isFlexible {
+			dst = kbin.AppendCompactArrayLen(dst, len(v))
+		} else {
+			dst = kbin.AppendArrayLen(dst, len(v))
+		}
+		for i := range v {
+			v := &v[i]
+			{
+				v := v.ErrorCode
+				dst = kbin.AppendInt16(dst, v)
+			}
+			{
+				v := v.Topic
+				if isFlexible {
+					dst = kbin.AppendCompactNullableString(dst, v)
+				} else {
+					dst = kbin.AppendNullableString(dst, v)
+				}
+			}
+			{
+				v := v.TopicID
+				dst = kbin.AppendUuid(dst, v)
+			}
+			{
+				v := v.IsInternal
+				dst = kbin.AppendBool(dst, v)
+			}
+			{
+				v := v.Partitions
+				if isFlexible {
+					dst = kbin.AppendCompactArrayLen(dst, len(v))
+				} else {
+					dst = kbin.AppendArrayLen(dst, len(v))
+				}
+				for i := range v {
+					v := &v[i]
+					{
+						v := v.ErrorCode
+						dst = kbin.AppendInt16(dst, v)
+					}
+					{
+						v := v.Partition
+						dst = kbin.AppendInt32(dst, v)
+					}
+					{
+						v := v.Leader
+						dst = kbin.AppendInt32(dst, v)
+					}
+					{
+						v := v.LeaderEpoch
+						dst = kbin.AppendInt32(dst, v)
+					}
+					{
+						v := v.Replicas
+						if isFlexible {
+							dst = kbin.AppendCompactArrayLen(dst, len(v))
+						} else {
+							dst = kbin.AppendArrayLen(dst, len(v))
+						}
+						for i := range v {
+							v := v[i]
+							dst = kbin.AppendInt32(dst, v)
+						}
+					}
+					{
+						v := v.ISR
+						if isFlexible {
+							dst = kbin.AppendCompactArrayLen(dst, len(v))
+						} else {
+							dst = kbin.AppendArrayLen(dst, len(v))
+						}
+						for i := range v {
+							v := v[i]
+							dst = kbin.AppendInt32(dst, v)
+						}
+					}
+					{
+						v := v.EligibleLeaderReplicas
+						if isFlexible {
+							dst = kbin.AppendCompactNullableArrayLen(dst, len(v), v == nil)
+						} else {
+							dst = kbin.AppendNullableArrayLen(dst, len(v), v == nil)
+						}
+						for i := range v {
+							v := v[i]
+							dst = kbin.AppendInt32(dst, v)
+						}
+					}
+					{
+						v := v.LastKnownELR
+						if isFlexible {
+							dst = kbin.AppendCompactNullableArrayLen(dst, len(v), v == nil)
+						} else {
+							dst = kbin.AppendNullableArrayLen(dst, len(v), v == nil)
+						}
+						for i := range v {
+							v := v[i]
+							dst = kbin.AppendInt32(dst, v)
+						}
+					}
+					{
+						v := v.OfflineReplicas
+						if isFlexible {
+							dst = kbin.AppendCompactArrayLen(dst, len(v))
+						} else {
+							dst = kbin.AppendArrayLen(dst, len(v))
+						}
+						for i := range v {
+							v := v[i]
+							dst = kbin.AppendInt32(dst, v)
+						}
+					}
+					if isFlexible {
+						dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+						dst = v.UnknownTags.AppendEach(dst)
+					}
+				}
+			}
+			{
+				v := v.AuthorizedOperations
+				dst = kbin.AppendInt32(dst, v)
+			}
+			if isFlexible {
+				dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+				dst = v.UnknownTags.AppendEach(dst)
+			}
+		}
+	}
+	{
+		v := v.NextCursor
+		if v == nil {
+			dst = append(dst, 255)
+		} else {
+			dst = append(dst, 1)
+			{
+				v := v.Topic
+				if isFlexible {
+					dst = kbin.AppendCompactString(dst, v)
+				} else {
+					dst = kbin.AppendString(dst, v)
+				}
+			}
+			{
+				v := v.Partition
+				dst = kbin.AppendInt32(dst, v)
+			}
+			if isFlexible {
+				dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+				dst = v.UnknownTags.AppendEach(dst)
+			}
+		}
+	}
+	if isFlexible {
+		dst = kbin.AppendUvarint(dst, 0+uint32(v.UnknownTags.Len()))
+		dst = v.UnknownTags.AppendEach(dst)
+	}
+	return dst
+}
+
+func (v *DescribeTopicPartitionsResponse) ReadFrom(src []byte) error {
+	return v.readFrom(src, false)
+}
+
+func (v *DescribeTopicPartitionsResponse) UnsafeReadFrom(src []byte) error {
+	return v.readFrom(src, true)
+}
+
+func (v *DescribeTopicPartitionsResponse) readFrom(src []byte, unsafe bool) error {
+	v.Default()
+	b := kbin.Reader{Src: src}
+	version := v.Version
+	_ = version
+	isFlexible := version >= 0
+	_ = isFlexible
+	s := v
+	{
+		v := b.Int32()
+		s.ThrottleMillis = v
+	}
+	{
+		v := s.Topics
+		a := v
+		var l int32
+		if isFlexible {
+			l = b.CompactArrayLen()
+		} else {
+			l = b.ArrayLen()
+		}
+		if !b.Ok() {
+			return b.Complete()
+		}
+		a = a[:0]
+		if l > 0 {
+			a = append(a, make([]DescribeTopicPartitionsResponseTopic, l)...)
+		}
+		for i := int32(0); i < l; i++ {
+			v := &a[i]
+			v.Default()
+			s := v
+			{
+				v := b.Int16()
+				s.ErrorCode = v
+			}
+			{
+				var v *string
+				if isFlexible {
+					if unsafe {
+						v = b.UnsafeCompactNullableString()
+					} else {
+						v = b.CompactNullableString()
+					}
+				} else {
+					if unsafe {
+						v = b.UnsafeNullableString()
+					} else {
+						v = b.NullableString()
+					}
+				}
+				s.Topic = v
+			}
+			{
+				v := b.Uuid()
+				s.TopicID = v
+			}
+			{
+				v := b.Bool()
+				s.IsInternal = v
+			}
+			{
+				v := s.Partitions
+				a := v
+				var l int32
+				if isFlexible {
+					l = b.CompactArrayLen()
+				} else {
+					l = b.ArrayLen()
+				}
+				if !b.Ok() {
+					return b.Complete()
+				}
+				a = a[:0]
+				if l > 0 {
+					a = append(a, make([]DescribeTopicPartitionsResponseTopicPartition, l)...)
+				}
+				for i := int32(0); i < l; i++ {
+					v := &a[i]
+					v.Default()
+					s := v
+					{
+						v := b.Int16()
+						s.ErrorCode = v
+					}
+					{
+						v := b.Int32()
+						s.Partition = v
+					}
+					{
+						v := b.Int32()
+						s.Leader = v
+					}
+					{
+						v := b.Int32()
+						s.LeaderEpoch = v
+					}
+					{
+						v := s.Replicas
+						a := v
+						var l int32
+						if isFlexible {
+							l = b.CompactArrayLen()
+						} else {
+							l = b.ArrayLen()
+						}
+						if !b.Ok() {
+							return b.Complete()
+						}
+						a = a[:0]
+						if l > 0 {
+							a = append(a, make([]int32, l)...)
+						}
+						for i := int32(0); i < l; i++ {
+							v := b.Int32()
+							a[i] = v
+						}
+						v = a
+						s.Replicas = v
+					}
+					{
+						v := s.ISR
+						a := v
+						var l int32
+						if isFlexible {
+							l = b.CompactArrayLen()
+						} else {
+							l = b.ArrayLen()
+						}
+						if !b.Ok() {
+							return b.Complete()
+						}
+						a = a[:0]
+						if l > 0 {
+							a = append(a, make([]int32, l)...)
+						}
+						for i := int32(0); i < l; i++ {
+							v := b.Int32()
+							a[i] = v
+						}
+						v = a
+						s.ISR = v
+					}
+					{
+						v := s.EligibleLeaderReplicas
+						a := v
+						var l int32
+						if isFlexible {
+							l = b.CompactArrayLen()
+						} else {
+							l = b.ArrayLen()
+						}
+						if version < 0 || l == 0 {
+							a = []int32{}
+						}
+						if !b.Ok() {
+							return b.Complete()
+						}
+						a = a[:0]
+						if l > 0 {
+							a = append(a, make([]int32, l)...)
+						}
+						for i := int32(0); i < l; i++ {
+							v := b.Int32()
+							a[i] = v
+						}
+						v = a
+						s.EligibleLeaderReplicas = v
+					}
+					{
+						v := s.LastKnownELR
+						a := v
+						var l int32
+						if isFlexible {
+							l = b.CompactArrayLen()
+						} else {
+							l = b.ArrayLen()
+						}
+						if version < 0 || l == 0 {
+							a = []int32{}
+						}
+						if !b.Ok() {
+							return b.Complete()
+						}
+						a = a[:0]
+						if l > 0 {
+							a = append(a, make([]int32, l)...)
+						}
+						for i := int32(0); i < l; i++ {
+							v := b.Int32()
+							a[i] = v
+						}
+						v = a
+						s.LastKnownELR = v
+					}
+					{
+						v := s.OfflineReplicas
+						a := v
+						var l int32
+						if isFlexible {
+							l = b.CompactArrayLen()
+						} else {
+							l = b.ArrayLen()
+						}
+						if !b.Ok() {
+							return b.Complete()
+						}
+						a = a[:0]
+						if l > 0 {
+							a = append(a, make([]int32, l)...)
+						}
+						for i := int32(0); i < l; i++ {
+							v := b.Int32()
+							a[i] = v
+						}
+						v = a
+						s.OfflineReplicas = v
+					}
+					if isFlexible {
+						s.UnknownTags = internalReadTags(&b)
+					}
+				}
+				v = a
+				s.Partitions = v
+			}
+			{
+				v := b.Int32()
+				s.AuthorizedOperations = v
+			}
+			if isFlexible {
+				s.UnknownTags = internalReadTags(&b)
+			}
+		}
+		v = a
+		s.Topics = v
+	}
+	{
+		if present := b.Int8(); present != -1 && b.Ok() {
+			s.NextCursor = new(DescribeTopicPartitionsResponseNextCursor)
+			v := s.NextCursor
+			v.Default()
+			s := v
+			{
+				var v string
+				if unsafe {
+					if isFlexible {
+						v = b.UnsafeCompactString()
+					} else {
+						v = b.UnsafeString()
+					}
+				} else {
+					if isFlexible {
+						v = b.CompactString()
+					} else {
+						v = b.String()
+					}
+				}
+				s.Topic = v
+			}
+			{
+				v := b.Int32()
+				s.Partition = v
+			}
+			if isFlexible {
+				s.UnknownTags = internalReadTags(&b)
+			}
+		}
+	}
+	if isFlexible {
+		s.UnknownTags = internalReadTags(&b)
+	}
+	return b.Complete()
+}
+
+// NewPtrDescribeTopicPartitionsResponse returns a pointer to a default DescribeTopicPartitionsResponse
+// This is a shortcut for creating a new(struct) and calling Default yourself.
+func NewPtrDescribeTopicPartitionsResponse() *DescribeTopicPartitionsResponse {
+	var v DescribeTopicPartitionsResponse
+	v.Default()
+	return &v
+}
+
+// Default sets any default fields. Calling this allows for future compatibility
+// if new fields are added to DescribeTopicPartitionsResponse.
+func (v *DescribeTopicPartitionsResponse) Default() {
+	{
+		v := &v.NextCursor
+		_ = v
+	}
+}
+
+// NewDescribeTopicPartitionsResponse returns a default DescribeTopicPartitionsResponse
+// This is a shortcut for creating a struct and calling Default yourself.
+func NewDescribeTopicPartitionsResponse() DescribeTopicPartitionsResponse {
+	var v DescribeTopicPartitionsResponse
+	v.Default()
+	return v
+}
+
 // RequestForKey returns the request corresponding to the given request key
 // or nil if the key is unknown.
 func RequestForKey(key int16) Request {
@@ -43455,6 +46133,14 @@ func RequestForKey(key int16) Request {
 		return NewPtrListTransactionsRequest()
 	case 67:
 		return NewPtrAllocateProducerIDsRequest()
+	case 69:
+		return NewPtrConsumerGroupDescribeRequest()
+	case 71:
+		return NewPtrGetTelemetrySubscriptionsRequest()
+	case 72:
+		return NewPtrPushTelemetryRequest()
+	case 75:
+		return NewPtrDescribeTopicPartitionsRequest()
 	}
 }
 
@@ -43600,6 +46286,14 @@ func ResponseForKey(key int16) Response {
 		return NewPtrListTransactionsResponse()
 	case 67:
 		return NewPtrAllocateProducerIDsResponse()
+	case 69:
+		return NewPtrConsumerGroupDescribeResponse()
+	case 71:
+		return NewPtrGetTelemetrySubscriptionsResponse()
+	case 72:
+		return NewPtrPushTelemetryResponse()
+	case 75:
+		return NewPtrDescribeTopicPartitionsResponse()
 	}
 }
 
@@ -43745,6 +46439,14 @@ func NameForKey(key int16) string {
 		return "ListTransactions"
 	case 67:
 		return "AllocateProducerIDs"
+	case 69:
+		return "ConsumerGroupDescribe"
+	case 71:
+		return "GetTelemetrySubscriptions"
+	case 72:
+		return "PushTelemetry"
+	case 75:
+		return "DescribeTopicPartitions"
 	}
 }
 
@@ -43820,6 +46522,10 @@ const (
 	DescribeTransactions         Key = 65
 	ListTransactions             Key = 66
 	AllocateProducerIDs          Key = 67
+	ConsumerGroupDescribe        Key = 69
+	GetTelemetrySubscriptions    Key = 71
+	PushTelemetry                Key = 72
+	DescribeTopicPartitions      Key = 75
 )
 
 // Name returns the name for this key.
@@ -52,6 +52,13 @@ type Auth struct {
 	//
 	UserAgent string
 
+	// Region, if non-empty, is the region to sign requests for, overriding
+	// detecting the region from the broker's hostname. If the region is
+	// not set and cannot be detected from the hostname (for example, when
+	// connecting through a private DNS name), the AWS_REGION or
+	// AWS_DEFAULT_REGION environment variable is used.
+	Region string
+
 	_ struct{} // require explicit field initialization
 }
 
@@ -115,15 +122,19 @@ func challenge(auth Auth, host string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	region, err := identifyRegion(host)
-	if err != nil {
-		return nil, err
+	region := auth.Region
+	if region == "" {
+		if region, err = identifyRegion(host); err != nil {
+			if region = envRegion(); region == "" {
+				return nil, err
+			}
+		}
 	}
 
 	var (
 		timestamp = time.Now().UTC().Format("20060102T150405Z")
 		date      = timestamp[:8] // 20060102
-		scope     = scope(date, region)
+		scope     = scope(date, region, service)
 		v         = make(url.Values)
 	)
 
@@ -141,7 +152,7 @@ func challenge(auth Auth, host string) ([]byte, error) {
 
 	canonicalRequest := task1(host, qps)
 	sts := task2(timestamp, scope, canonicalRequest)
-	signature := task3(auth.SecretKey, region, date, sts)
+	signature := task3(auth.SecretKey, region, service, date, sts)
 
 	v.Set("X-Amz-Signature", signature) // task4
 
@@ -169,7 +180,7 @@ func challenge(auth Auth, host string) ([]byte, error) {
 
 // https://docs.aws.amazon.com/general/latest/gr/sigv4-create-string-to-sign.html
 // "CredentialScope", Part 3
-func scope(date, region string) string {
+func scope(date, region, service string) string {
 	return strings.Join([]string{date, region, service, "aws4_request"}, "/")
 }
 
@@ -223,7 +234,7 @@ func task2(timestamp, scope string, canonicalRequest []byte) []byte {
 var aws4requestBytes = []byte("aws4_request")
 
 // https://docs.aws.amazon.com/general/latest/gr/sigv4-calculate-signature.html
-func task3(secretKey, region, date string, sts []byte) string {
+func task3(secretKey, region, service, date string, sts []byte) string {
 	key := make([]byte, 0, 100)
 	key = append(key, "AWS4"...)
 	key = append(key, secretKey...)
@@ -273,3 +284,11 @@ func identifyRegion(host string) (string, error) {
 	}
 	return "", fmt.Errorf("cannot determine the region in %+q", host)
 }
+
+// envRegion returns the region from the environment, if any.
+func envRegion() string {
+	if r := os.Getenv("AWS_REGION"); r != "" {
+		return r
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
@@ -0,0 +1,452 @@
+package aws
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// credentials are credentials from a provider along with when they expire.
+// A zero expiry means the provider did not say when the credentials expire;
+// these credentials are not cached and are loaded again on every use.
+type credentials struct {
+	auth    Auth
+	expires time.Time
+}
+
+type provider func(context.Context) (credentials, error)
+
+// errNoCredentials is returned from providers in the default chain when the
+// provider is not configured, signaling that the next provider should be
+// tried.
+var errNoCredentials = errors.New("no credentials")
+
+// refreshBefore is how long before credentials expire that they are
+// refreshed, giving headroom for clock skew and in-flight authentication.
+const refreshBefore = 5 * time.Minute
+
+// cache returns a function that caches credentials from p until they are
+// close to expiring. Credentials without an expiry are not cached: static
+// credentials are cheap to load again (and may have been rotated), and we
+// cannot know when credentials from a metadata service without an expiry
+// stop being valid.
+func cache(p provider) func(context.Context) (Auth, error) {
+	var (
+		mu     sync.Mutex
+		cached credentials
+		loaded bool
+	)
+	return func(ctx context.Context) (Auth, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if loaded && time.Now().Add(refreshBefore).Before(cached.expires) {
+			return cached.auth, nil
+		}
+		c, err := p(ctx)
+		if err != nil {
+			// If refreshing fails but our old credentials have not
+			// expired yet, we continue to use them.
+			if loaded && time.Now().Before(cached.expires) {
+				return cached.auth, nil
+			}
+			return Auth{}, err
+		}
+		cached, loaded = c, true
+		return c.auth, nil
+	}
+}
+
+// DefaultCredentials returns a function that loads credentials from the
+// default AWS credential chain, for use with ManagedStreamingIAM:
+//
+//	sasl := aws.ManagedStreamingIAM(aws.DefaultCredentials())
+//
+// Credentials are searched for in the same order as the AWS SDKs:
+//
+//  1. the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN
+//     environment variables
+//  2. a web identity token, as used by EKS IAM roles for service accounts,
+//     from AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN (and optionally
+//     AWS_ROLE_SESSION_NAME)
+//  3. the shared credentials file, from AWS_SHARED_CREDENTIALS_FILE or
+//     ~/.aws/credentials, using the AWS_PROFILE profile or "default"
+//  4. ECS container credentials, from AWS_CONTAINER_CREDENTIALS_RELATIVE_URI
+//     or AWS_CONTAINER_CREDENTIALS_FULL_URI
+//  5. EC2 instance role credentials, from the instance metadata service
+//     (IMDSv2), unless AWS_EC2_METADATA_DISABLED is true
+//
+// Temporary credentials are cached and refreshed five minutes before they
+// expire. Credentials without an expiry, such as those from the environment
+// or the shared credentials file, are loaded again every time they are used.
+// The chain is evaluated anew on every refresh.
+func DefaultCredentials() func(context.Context) (Auth, error) {
+	chain := []provider{
+		envCredentials,
+		webIdentityCredentials,
+		sharedFileCredentials,
+		containerCredentials,
+		instanceCredentials,
+	}
+	return cache(func(ctx context.Context) (credentials, error) {
+		for _, p := range chain {
+			c, err := p(ctx)
+			if err == errNoCredentials {
+				continue
+			}
+			return c, err
+		}
+		return credentials{}, errors.New("unable to find AWS credentials in the environment, shared credentials file, container, or instance metadata")
+	})
+}
+
+// AssumeRole returns a function that loads credentials for roleARN by calling
+// STS AssumeRole with the base credentials, for use with ManagedStreamingIAM:
+//
+//	creds := aws.AssumeRole(aws.DefaultCredentials(), "arn:aws:iam::123456789012:role/kafka", "my-app")
+//	sasl := aws.ManagedStreamingIAM(creds)
+//
+// If sessionName is empty, a session name is generated. STS is called in the
+// region from the AWS_REGION or AWS_DEFAULT_REGION environment variable, or
+// through the global STS endpoint if no region is set. The assumed role
+// credentials are cached and refreshed five minutes before they expire.
+func AssumeRole(base func(context.Context) (Auth, error), roleARN, sessionName string) func(context.Context) (Auth, error) {
+	return cache(func(ctx context.Context) (credentials, error) {
+		auth, err := base(ctx)
+		if err != nil {
+			return credentials{}, err
+		}
+		v := make(url.Values)
+		v.Set("Action", "AssumeRole")
+		v.Set("RoleArn", roleARN)
+		v.Set("RoleSessionName", defaultSessionName(sessionName))
+		return callSTS(ctx, &auth, v)
+	})
+}
+
+func defaultSessionName(name string) string {
+	if name != "" {
+		return name
+	}
+	return "franz-go-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+func envCredentials(context.Context) (credentials, error) {
+	id := os.Getenv("AWS_ACCESS_KEY_ID")
+	if id == "" {
+		id = os.Getenv("AWS_ACCESS_KEY")
+	}
+	secret := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if secret == "" {
+		secret = os.Getenv("AWS_SECRET_KEY")
+	}
+	if id == "" || secret == "" {
+		return credentials{}, errNoCredentials
+	}
+	return credentials{auth: Auth{
+		AccessKey:    id,
+		SecretKey:    secret,
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}}, nil
+}
+
+func webIdentityCredentials(ctx context.Context) (credentials, error) {
+	tokenFile, role := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN")
+	if tokenFile == "" || role == "" {
+		return credentials{}, errNoCredentials
+	}
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return credentials{}, fmt.Errorf("unable to read web identity token file: %w", err)
+	}
+	v := make(url.Values)
+	v.Set("Action", "AssumeRoleWithWebIdentity")
+	v.Set("RoleArn", role)
+	v.Set("RoleSessionName", defaultSessionName(os.Getenv("AWS_ROLE_SESSION_NAME")))
+	v.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+	return callSTS(ctx, nil, v)
+}
+
+func sharedFileCredentials(context.Context) (credentials, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return credentials{}, errNoCredentials
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return credentials{}, errNoCredentials
+	}
+	defer f.Close()
+
+	var (
+		auth    Auth
+		section string
+		scanner = bufio.NewScanner(f)
+	)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[' && line[len(line)-1] == ']':
+			section = strings.TrimSpace(line[1 : len(line)-1])
+		case section == profile:
+			k, v, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			switch strings.TrimSpace(k) {
+			case "aws_access_key_id":
+				auth.AccessKey = strings.TrimSpace(v)
+			case "aws_secret_access_key":
+				auth.SecretKey = strings.TrimSpace(v)
+			case "aws_session_token":
+				auth.SessionToken = strings.TrimSpace(v)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return credentials{}, fmt.Errorf("unable to read shared credentials file %s: %w", path, err)
+	}
+	if auth.AccessKey == "" || auth.SecretKey == "" {
+		return credentials{}, errNoCredentials
+	}
+	return credentials{auth: auth}, nil
+}
+
+// metadataClient is used for container and instance metadata requests, which
+// are local and should fail fast if the service does not exist.
+var metadataClient = &http.Client{Timeout: 2 * time.Second}
+
+// jsonCredentials is the credential format returned from both the container
+// and instance metadata services.
+type jsonCredentials struct {
+	Code            string
+	Message         string
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	Token           string
+	Expiration      time.Time
+}
+
+func (j jsonCredentials) into() (credentials, error) {
+	if j.Code != "" && j.Code != "Success" {
+		return credentials{}, fmt.Errorf("unable to load credentials: %s: %s", j.Code, j.Message)
+	}
+	if j.AccessKeyID == "" || j.SecretAccessKey == "" {
+		return credentials{}, errors.New("credentials response is missing access key or secret key")
+	}
+	return credentials{
+		auth: Auth{
+			AccessKey:    j.AccessKeyID,
+			SecretKey:    j.SecretAccessKey,
+			SessionToken: j.Token,
+		},
+		expires: j.Expiration,
+	}, nil
+}
+
+func containerCredentials(ctx context.Context) (credentials, error) {
+	var u string
+	if rel := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); rel != "" {
+		u = "http://169.254.170.2" + rel
+	} else if full := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); full != "" {
+		u = full
+	} else {
+		return credentials{}, errNoCredentials
+	}
+	hdr := make(http.Header)
+	if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		hdr.Set("Authorization", token)
+	}
+	body, err := metadataRequest(ctx, http.MethodGet, u, hdr)
+	if err != nil {
+		return credentials{}, fmt.Errorf("unable to load container credentials: %w", err)
+	}
+	var j jsonCredentials
+	if err := json.Unmarshal(body, &j); err != nil {
+		return credentials{}, fmt.Errorf("unable to decode container credentials: %w", err)
+	}
+	return j.into()
+}
+
+// instanceMetadataBase is the base URL of the EC2 instance metadata service.
+var instanceMetadataBase = "http://169.254.169.254/latest"
+
+func instanceCredentials(ctx context.Context) (credentials, error) {
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return credentials{}, errNoCredentials
+	}
+	base := instanceMetadataBase
+
+	token, err := metadataRequest(ctx, http.MethodPut, base+"/api/token", http.Header{
+		"X-Aws-Ec2-Metadata-Token-Ttl-Seconds": {"21600"},
+	})
+	if err != nil {
+		return credentials{}, errNoCredentials // not on EC2
+	}
+	hdr := http.Header{"X-Aws-Ec2-Metadata-Token": {string(token)}}
+
+	roles, err := metadataRequest(ctx, http.MethodGet, base+"/meta-data/iam/security-credentials/", hdr)
+	if err != nil {
+		return credentials{}, fmt.Errorf("unable to list instance roles: %w", err)
+	}
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if role == "" {
+		return credentials{}, errNoCredentials
+	}
+	body, err := metadataRequest(ctx, http.MethodGet, base+"/meta-data/iam/security-credentials/"+role, hdr)
+	if err != nil {
+		return credentials{}, fmt.Errorf("unable to load instance credentials: %w", err)
+	}
+	var j jsonCredentials
+	if err := json.Unmarshal(body, &j); err != nil {
+		return credentials{}, fmt.Errorf("unable to decode instance credentials: %w", err)
+	}
+	return j.into()
+}
+
+func metadataRequest(ctx context.Context, method, u string, hdr http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range hdr {
+		req.Header[k] = vs
+	}
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+// stsClient is used for STS requests.
+var stsClient = &http.Client{Timeout: 30 * time.Second}
+
+// stsURL, if non-empty, is used in place of the regional or global STS
+// endpoint.
+var stsURL string
+
+// callSTS issues an STS query API call, returning the credentials in the
+// response. If auth is non-nil, the request is signed with SigV4.
+func callSTS(ctx context.Context, auth *Auth, v url.Values) (credentials, error) {
+	region := envRegion()
+	host := "sts.amazonaws.com"
+	if region != "" {
+		host = "sts." + region + ".amazonaws.com"
+	} else {
+		region = "us-east-1" // the global endpoint signs for us-east-1
+	}
+	v.Set("Version", "2011-06-15")
+
+	if auth != nil {
+		const stsService = "sts"
+		var (
+			timestamp = time.Now().UTC().Format("20060102T150405Z")
+			date      = timestamp[:8]
+			scope     = scope(date, region, stsService)
+		)
+		v.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+		v.Set("X-Amz-Credential", auth.AccessKey+"/"+scope)
+		v.Set("X-Amz-Date", timestamp)
+		v.Set("X-Amz-Expires", "300")
+		v.Set("X-Amz-SignedHeaders", "host")
+		if auth.SessionToken != "" {
+			v.Set("X-Amz-Security-Token", auth.SessionToken)
+		}
+		qps := strings.ReplaceAll(v.Encode(), "+", "%20")
+		sts := task2(timestamp, scope, task1(host, qps))
+		v.Set("X-Amz-Signature", task3(auth.SecretKey, region, stsService, date, sts))
+	}
+
+	u := "https://" + host
+	if stsURL != "" {
+		u = stsURL
+	}
+	u += "/?" + strings.ReplaceAll(v.Encode(), "+", "%20")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return credentials{}, err
+	}
+	resp, err := stsClient.Do(req)
+	if err != nil {
+		return credentials{}, fmt.Errorf("unable to call STS %s: %w", v.Get("Action"), err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return credentials{}, fmt.Errorf("unable to read STS %s response: %w", v.Get("Action"), err)
+	}
+	return parseSTSResponse(v.Get("Action"), resp.StatusCode, body)
+}
+
+func parseSTSResponse(action string, status int, body []byte) (credentials, error) {
+	if status != http.StatusOK {
+		var e struct {
+			Error struct {
+				Code    string
+				Message string
+			}
+		}
+		if xml.Unmarshal(body, &e) == nil && e.Error.Code != "" {
+			return credentials{}, fmt.Errorf("STS %s failed: %s: %s", action, e.Error.Code, e.Error.Message)
+		}
+		return credentials{}, fmt.Errorf("STS %s failed with status %d", action, status)
+	}
+
+	// Both AssumeRole and AssumeRoleWithWebIdentity responses have the
+	// credentials one level below the root, in <Action>Result.
+	var r struct {
+		Result struct {
+			Credentials struct {
+				AccessKeyID     string `xml:"AccessKeyId"`
+				SecretAccessKey string
+				SessionToken    string
+				Expiration      time.Time
+			}
+		} `xml:",any"`
+	}
+	if err := xml.Unmarshal(body, &r); err != nil {
+		return credentials{}, fmt.Errorf("unable to decode STS %s response: %w", action, err)
+	}
+	c := r.Result.Credentials
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return credentials{}, fmt.Errorf("STS %s response is missing credentials", action)
+	}
+	return credentials{
+		auth: Auth{
+			AccessKey:    c.AccessKeyID,
+			SecretKey:    c.SecretAccessKey,
+			SessionToken: c.SessionToken,
+		},
+		expires: c.Expiration,
+	}, nil
+}
@@ -0,0 +1,366 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const stsResponse = `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <%[1]sResult>
+    <Credentials>
+      <AccessKeyId>sts-id</AccessKeyId>
+      <SecretAccessKey>sts-secret</SecretAccessKey>
+      <SessionToken>sts-token</SessionToken>
+      <Expiration>2030-01-02T03:04:05Z</Expiration>
+    </Credentials>
+  </%[1]sResult>
+</%[1]sResponse>`
+
+const stsErrorResponse = `<ErrorResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <Error>
+    <Type>Sender</Type>
+    <Code>AccessDenied</Code>
+    <Message>not authorized</Message>
+  </Error>
+</ErrorResponse>`
+
+const metadataResponse = `{
+  "Code": "Success",
+  "AccessKeyId": "meta-id",
+  "SecretAccessKey": "meta-secret",
+  "Token": "meta-token",
+  "Expiration": "2030-01-02T03:04:05Z"
+}`
+
+func TestProviders(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, test := range []struct {
+		name     string
+		provider provider
+		env      map[string]string
+		file     string // shared credentials file contents, if non-empty
+		handler  func(t *testing.T) http.HandlerFunc
+
+		exp    credentials
+		expErr string // if non-empty, the expected error substring
+		noCred bool   // if true, the provider returns errNoCredentials
+	}{
+		{
+			name:     "env",
+			provider: envCredentials,
+			env: map[string]string{
+				"AWS_ACCESS_KEY_ID":     "env-id",
+				"AWS_SECRET_ACCESS_KEY": "env-secret",
+				"AWS_SESSION_TOKEN":     "env-token",
+			},
+			exp: credentials{auth: Auth{AccessKey: "env-id", SecretKey: "env-secret", SessionToken: "env-token"}},
+		},
+		{
+			name:     "env missing secret",
+			provider: envCredentials,
+			env:      map[string]string{"AWS_ACCESS_KEY_ID": "env-id"},
+			noCred:   true,
+		},
+
+		{
+			name:     "shared file profile",
+			provider: sharedFileCredentials,
+			env:      map[string]string{"AWS_PROFILE": "other"},
+			file: `
+[default]
+aws_access_key_id = default-id
+aws_secret_access_key = default-secret
+
+# comment
+[other]
+aws_access_key_id = other-id
+aws_secret_access_key = other-secret
+aws_session_token = other-token
+`,
+			exp: credentials{auth: Auth{AccessKey: "other-id", SecretKey: "other-secret", SessionToken: "other-token"}},
+		},
+		{
+			name:     "shared file missing profile",
+			provider: sharedFileCredentials,
+			env:      map[string]string{"AWS_PROFILE": "missing"},
+			file:     "[default]\naws_access_key_id = id\naws_secret_access_key = secret\n",
+			noCred:   true,
+		},
+
+		{
+			name:     "container",
+			provider: containerCredentials,
+			env:      map[string]string{"AWS_CONTAINER_AUTHORIZATION_TOKEN": "auth"},
+			handler: func(t *testing.T) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if got := r.Header.Get("Authorization"); got != "auth" {
+						t.Errorf("got authorization %q, exp auth", got)
+					}
+					fmt.Fprint(w, metadataResponse)
+				}
+			},
+			exp: credentials{auth: Auth{AccessKey: "meta-id", SecretKey: "meta-secret", SessionToken: "meta-token"}, expires: expires},
+		},
+		{
+			name:     "container error code",
+			provider: containerCredentials,
+			handler: func(*testing.T) http.HandlerFunc {
+				return func(w http.ResponseWriter, _ *http.Request) {
+					fmt.Fprint(w, `{"Code": "Failure", "Message": "no role"}`)
+				}
+			},
+			expErr: "Failure: no role",
+		},
+		{
+			name:     "container bad status",
+			provider: containerCredentials,
+			handler: func(*testing.T) http.HandlerFunc {
+				return func(w http.ResponseWriter, _ *http.Request) {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			},
+			expErr: "unexpected status 500",
+		},
+
+		{
+			name:     "instance",
+			provider: instanceCredentials,
+			handler: func(t *testing.T) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					switch r.URL.Path {
+					case "/api/token":
+						if r.Method != http.MethodPut {
+							t.Errorf("got token method %s, exp PUT", r.Method)
+						}
+						fmt.Fprint(w, "imds-token")
+						return
+					}
+					if got := r.Header.Get("X-Aws-Ec2-Metadata-Token"); got != "imds-token" {
+						t.Errorf("got metadata token %q, exp imds-token", got)
+					}
+					switch r.URL.Path {
+					case "/meta-data/iam/security-credentials/":
+						fmt.Fprint(w, "role\n")
+					case "/meta-data/iam/security-credentials/role":
+						fmt.Fprint(w, metadataResponse)
+					default:
+						w.WriteHeader(http.StatusNotFound)
+					}
+				}
+			},
+			exp: credentials{auth: Auth{AccessKey: "meta-id", SecretKey: "meta-secret", SessionToken: "meta-token"}, expires: expires},
+		},
+		{
+			name:     "instance no token",
+			provider: instanceCredentials,
+			handler: func(*testing.T) http.HandlerFunc {
+				return func(w http.ResponseWriter, _ *http.Request) {
+					w.WriteHeader(http.StatusForbidden)
+				}
+			},
+			noCred: true,
+		},
+		{
+			name:     "instance disabled",
+			provider: instanceCredentials,
+			env:      map[string]string{"AWS_EC2_METADATA_DISABLED": "true"},
+			noCred:   true,
+		},
+
+		{
+			name:     "web identity",
+			provider: webIdentityCredentials,
+			env: map[string]string{
+				"AWS_ROLE_ARN":          "arn:aws:iam::123456789012:role/kafka",
+				"AWS_ROLE_SESSION_NAME": "session",
+			},
+			handler: func(t *testing.T) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					q := r.URL.Query()
+					for k, v := range map[string]string{
+						"Action":           "AssumeRoleWithWebIdentity",
+						"RoleArn":          "arn:aws:iam::123456789012:role/kafka",
+						"RoleSessionName":  "session",
+						"WebIdentityToken": "web-token",
+					} {
+						if got := q.Get(k); got != v {
+							t.Errorf("got %s %q, exp %q", k, got, v)
+						}
+					}
+					if q.Get("X-Amz-Signature") != "" {
+						t.Error("web identity request is unexpectedly signed")
+					}
+					fmt.Fprintf(w, stsResponse, "AssumeRoleWithWebIdentity")
+				}
+			},
+			exp: credentials{auth: Auth{AccessKey: "sts-id", SecretKey: "sts-secret", SessionToken: "sts-token"}, expires: expires},
+		},
+		{
+			name:     "web identity error",
+			provider: webIdentityCredentials,
+			env:      map[string]string{"AWS_ROLE_ARN": "arn:aws:iam::123456789012:role/kafka"},
+			handler: func(*testing.T) http.HandlerFunc {
+				return func(w http.ResponseWriter, _ *http.Request) {
+					w.WriteHeader(http.StatusForbidden)
+					fmt.Fprint(w, stsErrorResponse)
+				}
+			},
+			expErr: "AccessDenied: not authorized",
+		},
+
+		{
+			name: "assume role",
+			provider: func(ctx context.Context) (credentials, error) {
+				base := func(context.Context) (Auth, error) { return Auth{AccessKey: "base-id", SecretKey: "base-secret"}, nil }
+				auth, err := AssumeRole(base, "arn:aws:iam::123456789012:role/kafka", "session")(ctx)
+				return credentials{auth: auth, expires: expires}, err
+			},
+			handler: func(t *testing.T) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					q := r.URL.Query()
+					if got := q.Get("Action"); got != "AssumeRole" {
+						t.Errorf("got action %q, exp AssumeRole", got)
+					}
+					if got := q.Get("X-Amz-Credential"); !strings.HasPrefix(got, "base-id/") {
+						t.Errorf("got credential %q, exp it to start with the base access key", got)
+					}
+					if q.Get("X-Amz-Signature") == "" {
+						t.Error("assume role request is not signed")
+					}
+					fmt.Fprintf(w, stsResponse, "AssumeRole")
+				}
+			},
+			exp: credentials{auth: Auth{AccessKey: "sts-id", SecretKey: "sts-secret", SessionToken: "sts-token"}, expires: expires},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			for _, k := range []string{
+				"AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY", "AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY", "AWS_SESSION_TOKEN",
+				"AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_ROLE_ARN", "AWS_ROLE_SESSION_NAME",
+				"AWS_SHARED_CREDENTIALS_FILE", "AWS_PROFILE",
+				"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI", "AWS_CONTAINER_AUTHORIZATION_TOKEN",
+				"AWS_EC2_METADATA_DISABLED", "AWS_REGION", "AWS_DEFAULT_REGION",
+			} {
+				t.Setenv(k, "")
+			}
+			for k, v := range test.env {
+				t.Setenv(k, v)
+			}
+
+			dir := t.TempDir()
+			if test.file != "" {
+				path := filepath.Join(dir, "credentials")
+				if err := os.WriteFile(path, []byte(test.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+			}
+			if test.env["AWS_ROLE_ARN"] != "" {
+				path := filepath.Join(dir, "token")
+				if err := os.WriteFile(path, []byte("web-token\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", path)
+			}
+
+			// Every endpoint points at the test server, if any, or
+			// at a closed port so that nothing leaves the host.
+			base := "http://127.0.0.1:1"
+			if test.handler != nil {
+				srv := httptest.NewServer(test.handler(t))
+				defer srv.Close()
+				base = srv.URL
+			}
+			t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", base+"/creds")
+			defer func(prior string) { instanceMetadataBase = prior }(instanceMetadataBase)
+			defer func(prior string) { stsURL = prior }(stsURL)
+			instanceMetadataBase, stsURL = base, base
+
+			got, err := test.provider(context.Background())
+			switch {
+			case test.noCred:
+				if err != errNoCredentials {
+					t.Errorf("got err %v, exp errNoCredentials", err)
+				}
+			case test.expErr != "":
+				if err == nil || !strings.Contains(err.Error(), test.expErr) {
+					t.Errorf("got err %v, exp it to contain %q", err, test.expErr)
+				}
+			case err != nil:
+				t.Errorf("unexpected err: %v", err)
+			case got.auth != test.exp.auth || !got.expires.Equal(test.exp.expires):
+				t.Errorf("got %+v, exp %+v", got, test.exp)
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	var (
+		loads int
+		next  credentials
+		err   error
+	)
+	load := cache(func(context.Context) (credentials, error) {
+		loads++
+		return next, err
+	})
+	check := func(expKey string, expLoads int) {
+		t.Helper()
+		auth, lerr := load(context.Background())
+		if lerr != nil {
+			t.Fatalf("unexpected err: %v", lerr)
+		}
+		if auth.AccessKey != expKey || loads != expLoads {
+			t.Fatalf("got key %q after %d loads, exp %q after %d", auth.AccessKey, loads, expKey, expLoads)
+		}
+	}
+
+	// Credentials without an expiry are loaded on every use.
+	next = credentials{auth: Auth{AccessKey: "static"}}
+	check("static", 1)
+	check("static", 2)
+
+	// Credentials with an expiry are cached until they are close to
+	// expiring.
+	next = credentials{auth: Auth{AccessKey: "temp"}, expires: time.Now().Add(time.Hour)}
+	check("temp", 3)
+	next = credentials{auth: Auth{AccessKey: "temp2"}, expires: time.Now().Add(time.Hour)}
+	check("temp", 3)
+
+	// If refreshing fails, unexpired credentials continue to be used.
+	load = cache(func(context.Context) (credentials, error) {
+		loads++
+		return next, err
+	})
+	loads = 0
+	next = credentials{auth: Auth{AccessKey: "expiring"}, expires: time.Now().Add(time.Minute)}
+	check("expiring", 1)
+	err = errors.New("refresh failed")
+	check("expiring", 2)
+
+	// Expired credentials are not used if refreshing fails.
+	load = cache(func(context.Context) (credentials, error) {
+		loads++
+		return next, err
+	})
+	err = nil
+	next = credentials{auth: Auth{AccessKey: "expired"}, expires: time.Now().Add(-time.Minute)}
+	loads = 0
+	check("expired", 1)
+	err = errors.New("refresh failed")
+	if _, lerr := load(context.Background()); lerr == nil {
+		t.Error("expected an error refreshing expired credentials")
+	}
+}
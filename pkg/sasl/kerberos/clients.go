@@ -0,0 +1,100 @@
+package kerberos
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+)
+
+// NewKeytabClient returns a Kerberos client that logs in as username in the
+// given realm using the keys in the keytab file at keytabPath. This is the
+// usual way for services to authenticate to a Kerberos-only cluster.
+//
+// The Kerberos configuration is loaded from confPath, or if confPath is empty,
+// from the KRB5_CONFIG environment variable, or /etc/krb5.conf. Settings, such
+// as client.DisablePAFXFAST (often needed for Active Directory), are passed
+// through to the client.
+//
+// The returned client can be used in Auth.Client:
+//
+//	c, err := kerberos.NewKeytabClient("kafka-client", "EXAMPLE.COM", "/etc/security/client.keytab", "")
+//	// handle err
+//	mech := kerberos.Auth{Client: c, Service: "kafka"}.AsMechanismWithClose()
+func NewKeytabClient(username, realm, keytabPath, confPath string, settings ...func(*client.Settings)) (*client.Client, error) {
+	cfg, err := loadConfig(confPath)
+	if err != nil {
+		return nil, err
+	}
+	kt, err := keytab.Load(keytabPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load keytab %s: %w", keytabPath, err)
+	}
+	return client.NewWithKeytab(username, realm, kt, cfg, settings...), nil
+}
+
+// NewCCacheClient returns a Kerberos client that uses the tickets in the
+// credential cache at ccachePath, such as one populated by kinit. If
+// ccachePath is empty, the KRB5CCNAME environment variable is used (with any
+// FILE: prefix removed), or /tmp/krb5cc_<uid>.
+//
+// A client from a credential cache cannot renew its tickets past their
+// renewable lifetime; once the tickets expire, kinit must be run again.
+//
+// The Kerberos configuration is loaded as described in NewKeytabClient.
+func NewCCacheClient(ccachePath, confPath string, settings ...func(*client.Settings)) (*client.Client, error) {
+	cfg, err := loadConfig(confPath)
+	if err != nil {
+		return nil, err
+	}
+	if ccachePath == "" {
+		if ccachePath, err = defaultCCachePath(); err != nil {
+			return nil, err
+		}
+	}
+	cc, err := credentials.LoadCCache(ccachePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load credential cache %s: %w", ccachePath, err)
+	}
+	c, err := client.NewFromCCache(cc, cfg, settings...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create client from credential cache %s: %w", ccachePath, err)
+	}
+	return c, nil
+}
+
+func loadConfig(path string) (*config.Config, error) {
+	if path == "" {
+		path = os.Getenv("KRB5_CONFIG")
+	}
+	if path == "" {
+		path = "/etc/krb5.conf"
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load Kerberos config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func defaultCCachePath() (string, error) {
+	if name := os.Getenv("KRB5CCNAME"); name != "" {
+		if strings.HasPrefix(name, "FILE:") {
+			return strings.TrimPrefix(name, "FILE:"), nil
+		}
+		if strings.Contains(name, ":") {
+			return "", fmt.Errorf("unsupported credential cache type in KRB5CCNAME %q, only FILE caches are supported", name)
+		}
+		return name, nil
+	}
+	u, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine the default credential cache: %w", err)
+	}
+	return "/tmp/krb5cc_" + u.Uid, nil
+}
@@ -0,0 +1,175 @@
+package kerberos
+
+import (
+	"encoding/hex"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jcmturner/gokrb5/v8/test/testdata"
+)
+
+// The test credential cache has a TGT and an HTTP/host.test.gokrb5 service
+// ticket for testuser1@TEST.GOKRB5, both of which expired in 2017. Each
+// ticket's times are encoded as authtime, starttime, endtime, renew-till; we
+// can push the end and renew-till times past 2038 to have unexpired tickets.
+const (
+	expiredTimes = "5967044e5967ad08"
+	validTimes   = "7ffffff07ffffff0"
+)
+
+func writeHex(t *testing.T, dir, name, h string) string {
+	t.Helper()
+	b, err := hex.DecodeString(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func writeConf(t *testing.T, dir string) string {
+	t.Helper()
+	path := filepath.Join(dir, "krb5.conf")
+	if err := os.WriteFile(path, []byte(testdata.KRB5_CONF), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewKeytabClient(t *testing.T) {
+	dir := t.TempDir()
+	conf := writeConf(t, dir)
+	kt := writeHex(t, dir, "keytab", testdata.KEYTAB_TESTUSER1_TEST_GOKRB5)
+
+	c, err := NewKeytabClient("testuser1", "TEST.GOKRB5", kt, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := c.IsConfigured(); !ok {
+		t.Errorf("keytab client is not configured: %v", err)
+	}
+	if !c.Credentials.HasKeytab() || c.Credentials.UserName() != "testuser1" {
+		t.Errorf("got user %q with keytab %v, exp testuser1 with a keytab", c.Credentials.UserName(), c.Credentials.HasKeytab())
+	}
+
+	// With no config path, KRB5_CONFIG is used.
+	t.Setenv("KRB5_CONFIG", conf)
+	if _, err := NewKeytabClient("testuser1", "TEST.GOKRB5", kt, ""); err != nil {
+		t.Errorf("unable to create client with KRB5_CONFIG: %v", err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		kt     string
+		conf   string
+		expErr string
+	}{
+		{"missing keytab", filepath.Join(dir, "missing"), conf, "unable to load keytab"},
+		{"missing config", kt, filepath.Join(dir, "missing.conf"), "unable to load Kerberos config"},
+		{"invalid keytab", conf, conf, "unable to load keytab"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewKeytabClient("testuser1", "TEST.GOKRB5", test.kt, test.conf); err == nil || !strings.Contains(err.Error(), test.expErr) {
+				t.Errorf("got err %v, exp it to contain %q", err, test.expErr)
+			}
+		})
+	}
+}
+
+func TestNewCCacheClient(t *testing.T) {
+	dir := t.TempDir()
+	conf := writeConf(t, dir)
+
+	// Unexpired tickets are used from the cache without contacting the
+	// KDC: logging in uses the cached TGT and the service ticket is a
+	// cache hit.
+	valid := writeHex(t, dir, "valid", strings.ReplaceAll(testdata.CCACHE_TEST, expiredTimes, validTimes))
+	c, err := NewCCacheClient(valid, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Credentials.UserName() != "testuser1" || c.Credentials.Domain() != "TEST.GOKRB5" {
+		t.Errorf("got principal %s@%s, exp testuser1@TEST.GOKRB5", c.Credentials.UserName(), c.Credentials.Domain())
+	}
+	if err := c.AffirmLogin(); err != nil {
+		t.Errorf("unable to log in with an unexpired cached TGT: %v", err)
+	}
+	if _, _, ok := c.GetCachedTicket("HTTP/host.test.gokrb5"); !ok {
+		t.Error("unexpired service ticket was not found in the cache")
+	}
+
+	// Expired tickets cannot be renewed nor replaced: a client from a
+	// credential cache has no keys to log in with.
+	expired := writeHex(t, dir, "expired", testdata.CCACHE_TEST)
+	c, err = NewCCacheClient(expired, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AffirmLogin(); err == nil || !strings.Contains(err.Error(), "no valid existing session") {
+		t.Errorf("got err %v logging in with an expired cached TGT, exp no valid existing session", err)
+	}
+
+	// With no cache path, KRB5CCNAME is used.
+	t.Setenv("KRB5CCNAME", "FILE:"+valid)
+	if _, err := NewCCacheClient("", conf); err != nil {
+		t.Errorf("unable to create client with KRB5CCNAME: %v", err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		path   string
+		conf   string
+		expErr string
+	}{
+		{"missing cache", filepath.Join(dir, "missing"), conf, "unable to load credential cache"},
+		{"invalid cache", conf, conf, "unable to load credential cache"},
+		{"missing config", valid, filepath.Join(dir, "missing.conf"), "unable to load Kerberos config"},
+		{
+			"no tgt",
+			// Renaming the krbtgt server principal leaves only
+			// service tickets in the cache.
+			writeHex(t, dir, "notgt", strings.Replace(testdata.CCACHE_TEST, hex.EncodeToString([]byte("krbtgt")), hex.EncodeToString([]byte("krbtgx")), 1)),
+			conf,
+			"unable to create client from credential cache",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewCCacheClient(test.path, test.conf); err == nil || !strings.Contains(err.Error(), test.expErr) {
+				t.Errorf("got err %v, exp it to contain %q", err, test.expErr)
+			}
+		})
+	}
+}
+
+func TestDefaultCCachePath(t *testing.T) {
+	u, err := user.Current()
+	if err != nil {
+		t.Skipf("unable to determine the current user: %v", err)
+	}
+
+	for _, test := range []struct {
+		env    string
+		exp    string
+		expErr bool
+	}{
+		{"", "/tmp/krb5cc_" + u.Uid, false},
+		{"/tmp/cache", "/tmp/cache", false},
+		{"FILE:/tmp/cache", "/tmp/cache", false},
+		{"KEYRING:persistent:1000", "", true},
+	} {
+		t.Setenv("KRB5CCNAME", test.env)
+		got, err := defaultCCachePath()
+		if gotErr := err != nil; gotErr != test.expErr {
+			t.Errorf("KRB5CCNAME=%q: got err %v, exp err? %v", test.env, err, test.expErr)
+		}
+		if got != test.exp {
+			t.Errorf("KRB5CCNAME=%q: got %q, exp %q", test.env, got, test.exp)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/sasl"
+)
+
+// ClientCredentials contains configuration for fetching OAUTHBEARER tokens
+// with the OpenID Connect / OAuth 2.0 client credentials flow (RFC 6749
+// section 4.4).
+//
+// Tokens are cached and shared across all sessions of the mechanism returned
+// from AsMechanism, and are refreshed shortly before they expire.
+type ClientCredentials struct {
+	// TokenURL is the token endpoint of the identity provider, e.g.
+	// https://idp.example.com/oauth2/token.
+	TokenURL string
+	// ClientID is the client ID to authenticate to the token endpoint as.
+	ClientID string
+	// ClientSecret is the secret for the client ID.
+	ClientSecret string
+	// Scopes are optional scopes to request.
+	Scopes []string
+	// Params are optional additional form parameters to send to the
+	// token endpoint, such as an audience.
+	Params url.Values
+
+	// Zid is an optional authorization ID to use in authenticating to
+	// Kafka.
+	Zid string
+	// Extensions are key value pairs to add to the authentication request
+	// to Kafka.
+	Extensions map[string]string
+
+	// HTTPClient is the client to use for token requests, overriding
+	// http.DefaultClient.
+	HTTPClient *http.Client
+
+	// RefreshBefore is how long before a token expires that it is
+	// refreshed, overriding the default of one minute. This should be
+	// large enough to account for clock skew between this client, the
+	// identity provider, and Kafka.
+	RefreshBefore time.Duration
+
+	// Retries is how many times to retry a failed token request before
+	// giving up. Zero (the default) means failed requests are not
+	// retried. Retries back off exponentially starting at 250ms. If a
+	// refresh fails but the cached token has not yet expired, the cached
+	// token continues to be used.
+	Retries int
+
+	_ struct{} // require explicit field initialization
+}
+
+// AsMechanism returns an OAUTHBEARER sasl mechanism that fetches tokens from
+// the token endpoint with the client credentials flow.
+func (c ClientCredentials) AsMechanism() sasl.Mechanism {
+	if c.HTTPClient == nil {
+		c.HTTPClient = http.DefaultClient
+	}
+	if c.RefreshBefore <= 0 {
+		c.RefreshBefore = time.Minute
+	}
+	if c.Retries < 0 {
+		c.Retries = 0
+	}
+	s := &tokenSource{cfg: c}
+	return Oauth(s.auth)
+}
+
+// tokenSource caches a token and refreshes it when it is close to expiring.
+type tokenSource struct {
+	cfg ClientCredentials
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (s *tokenSource) auth(ctx context.Context) (Auth, error) {
+	token, err := s.load(ctx)
+	if err != nil {
+		return Auth{}, err
+	}
+	return Auth{
+		Zid:        s.cfg.Zid,
+		Token:      token,
+		Extensions: s.cfg.Extensions,
+	}, nil
+}
+
+func (s *tokenSource) load(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.token != "" && now.Add(s.cfg.RefreshBefore).Before(s.expires) {
+		return s.token, nil
+	}
+
+	var err error
+	backoff := 250 * time.Millisecond
+	for tries := 0; ; tries++ {
+		var token string
+		var expires time.Time
+		if token, expires, err = s.fetch(ctx); err == nil {
+			s.token, s.expires = token, expires
+			return token, nil
+		}
+		if tries >= s.cfg.Retries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-time.After(backoff):
+			backoff *= 2
+			continue
+		}
+		break
+	}
+
+	// If we failed to refresh but our old token is still valid, we use
+	// it and try again the next time we authenticate.
+	if s.token != "" && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+	return "", err
+}
+
+// fetch requests a new token, returning it and when it expires.
+func (s *tokenSource) fetch(ctx context.Context) (string, time.Time, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	for k, vs := range s.cfg.Params {
+		form[k] = append(form[k], vs...)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+
+	start := time.Now() // expiry is relative to when we asked, not when we heard back
+	resp, err := s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("unable to read token response: %w", err)
+	}
+
+	var tr struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	jerr := json.Unmarshal(body, &tr)
+	if resp.StatusCode != http.StatusOK {
+		if jerr == nil && tr.Error != "" {
+			return "", time.Time{}, fmt.Errorf("token request failed with status %d: %s: %s", resp.StatusCode, tr.Error, tr.ErrorDescription)
+		}
+		return "", time.Time{}, fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+	if jerr != nil {
+		return "", time.Time{}, fmt.Errorf("unable to decode token response: %w", jerr)
+	}
+	if tr.AccessToken == "" {
+		return "", time.Time{}, errors.New("token response is missing access_token")
+	}
+	if tr.TokenType != "" && !strings.EqualFold(tr.TokenType, "bearer") {
+		return "", time.Time{}, fmt.Errorf("unexpected token type %q, expected bearer", tr.TokenType)
+	}
+
+	// If the provider does not tell us when the token expires, we
+	// refresh on every authentication.
+	var expires time.Time
+	if tr.ExpiresIn > 0 {
+		expires = start.Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return tr.AccessToken, expires, nil
+}
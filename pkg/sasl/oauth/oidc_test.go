@@ -0,0 +1,243 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tokenServer is a token endpoint that replies with the next queued response,
+// repeating the last response once the queue is drained.
+type tokenServer struct {
+	t *testing.T
+
+	mu        sync.Mutex
+	responses []tokenResponse
+	requests  int
+}
+
+type tokenResponse struct {
+	status int
+	body   string
+}
+
+func (s *tokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.t.Errorf("unable to parse form: %v", err)
+	}
+	if id, secret, ok := r.BasicAuth(); !ok || id != "id" || secret != "secret" {
+		s.t.Errorf("got basic auth %q %q %v, exp id secret true", id, secret, ok)
+	}
+	for k, v := range map[string]string{
+		"grant_type": "client_credentials",
+		"scope":      "a b",
+		"audience":   "kafka",
+	} {
+		if got := r.PostForm.Get(k); got != v {
+			s.t.Errorf("got form %s %q, exp %q", k, got, v)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	resp := s.responses[0]
+	if len(s.responses) > 1 {
+		s.responses = s.responses[1:]
+	}
+	w.WriteHeader(resp.status)
+	fmt.Fprint(w, resp.body)
+}
+
+func (s *tokenServer) reqs() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func token(name string, expiresIn int) tokenResponse {
+	return tokenResponse{http.StatusOK, fmt.Sprintf(`{"access_token": %q, "token_type": "Bearer", "expires_in": %d}`, name, expiresIn)}
+}
+
+func TestClientCredentials(t *testing.T) {
+	t.Parallel()
+
+	badRequest := tokenResponse{http.StatusBadRequest, `{"error": "invalid_client", "error_description": "bad secret"}`}
+
+	for _, test := range []struct {
+		name      string
+		retries   int
+		responses []tokenResponse
+		loads     int // how many times to load a token
+
+		exp      string // the last loaded token
+		expErr   string // if non-empty, the expected error substring of the last load
+		expFetch int    // how many token requests are expected
+	}{
+		{
+			name:      "cached until refresh",
+			responses: []tokenResponse{token("a", 3600), token("b", 3600)},
+			loads:     3,
+			exp:       "a",
+			expFetch:  1,
+		},
+		{
+			name:      "refreshed within refresh before",
+			responses: []tokenResponse{token("a", 30), token("b", 30)},
+			loads:     2,
+			exp:       "b",
+			expFetch:  2,
+		},
+		{
+			name:      "no expiry refreshes every time",
+			responses: []tokenResponse{token("a", 0), token("b", 0)},
+			loads:     2,
+			exp:       "b",
+			expFetch:  2,
+		},
+		{
+			name:      "error response not retried",
+			responses: []tokenResponse{badRequest, token("a", 3600)},
+			loads:     1,
+			expErr:    "status 400: invalid_client: bad secret",
+			expFetch:  1,
+		},
+		{
+			name:      "error response retried",
+			retries:   1,
+			responses: []tokenResponse{badRequest, token("a", 3600)},
+			loads:     1,
+			exp:       "a",
+			expFetch:  2,
+		},
+		{
+			name:      "unstructured error",
+			responses: []tokenResponse{{http.StatusInternalServerError, "oops"}},
+			loads:     1,
+			expErr:    "status 500",
+			expFetch:  1,
+		},
+		{
+			name:      "wrong token type",
+			responses: []tokenResponse{{http.StatusOK, `{"access_token": "a", "token_type": "mac"}`}},
+			loads:     1,
+			expErr:    `unexpected token type "mac"`,
+			expFetch:  1,
+		},
+		{
+			name:      "missing token",
+			responses: []tokenResponse{{http.StatusOK, `{"token_type": "bearer"}`}},
+			loads:     1,
+			expErr:    "missing access_token",
+			expFetch:  1,
+		},
+		{
+			name:      "unexpired token used if refresh fails",
+			responses: []tokenResponse{token("a", 30), badRequest},
+			loads:     2,
+			exp:       "a",
+			expFetch:  2,
+		},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ts := &tokenServer{t: t, responses: test.responses}
+			srv := httptest.NewServer(ts)
+			defer srv.Close()
+
+			s := &tokenSource{cfg: ClientCredentials{
+				TokenURL:      srv.URL,
+				ClientID:      "id",
+				ClientSecret:  "secret",
+				Scopes:        []string{"a", "b"},
+				Params:        map[string][]string{"audience": {"kafka"}},
+				HTTPClient:    srv.Client(),
+				RefreshBefore: time.Minute,
+				Retries:       test.retries,
+			}}
+
+			var (
+				got string
+				err error
+			)
+			for i := 0; i < test.loads; i++ {
+				got, err = s.load(context.Background())
+			}
+			if test.expErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expErr) {
+					t.Errorf("got err %v, exp it to contain %q", err, test.expErr)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected err: %v", err)
+			} else if got != test.exp {
+				t.Errorf("got token %q, exp %q", got, test.exp)
+			}
+			if reqs := ts.reqs(); reqs != test.expFetch {
+				t.Errorf("got %d token requests, exp %d", reqs, test.expFetch)
+			}
+		})
+	}
+}
+
+func TestClientCredentialsMechanism(t *testing.T) {
+	t.Parallel()
+
+	ts := &tokenServer{t: t, responses: []tokenResponse{token("tok", 3600)}}
+	srv := httptest.NewServer(ts)
+	defer srv.Close()
+
+	m := ClientCredentials{
+		TokenURL:     srv.URL,
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Scopes:       []string{"a", "b"},
+		Params:       map[string][]string{"audience": {"kafka"}},
+		Zid:          "zid",
+		HTTPClient:   srv.Client(),
+		Retries:      -1,
+	}.AsMechanism()
+
+	for i := 0; i < 2; i++ {
+		_, msg, err := m.Authenticate(context.Background(), "host:9092")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(msg), "auth=Bearer tok") || !strings.HasPrefix(string(msg), "n,a=zid,") {
+			t.Errorf("got unexpected initial message %q", msg)
+		}
+	}
+	if reqs := ts.reqs(); reqs != 1 {
+		t.Errorf("got %d token requests, exp 1 for a cached token", reqs)
+	}
+}
+
+func TestClientCredentialsNoRetries(t *testing.T) {
+	t.Parallel()
+
+	ts := &tokenServer{t: t, responses: []tokenResponse{{http.StatusUnauthorized, `{"error": "invalid_client"}`}}}
+	srv := httptest.NewServer(ts)
+	defer srv.Close()
+
+	m := ClientCredentials{
+		TokenURL:     srv.URL,
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Scopes:       []string{"a", "b"},
+		Params:       map[string][]string{"audience": {"kafka"}},
+		HTTPClient:   srv.Client(),
+	}.AsMechanism()
+
+	if _, _, err := m.Authenticate(context.Background(), "host:9092"); err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Errorf("got err %v, exp invalid_client", err)
+	}
+	if reqs := ts.reqs(); reqs != 1 {
+		t.Errorf("got %d token requests, exp 1 with no retries", reqs)
+	}
+}